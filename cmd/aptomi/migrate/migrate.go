@@ -0,0 +1,60 @@
+package migrate
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Aptomi/aptomi/pkg/config"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/server"
+	"github.com/spf13/cobra"
+)
+
+// NewMigrateCommand returns instance of cobra command that copies all objects from the configured DB to another one
+// (e.g. from bolt to etcd or from yaml to gob codec), preserving all generations
+func NewMigrateCommand(cfg *config.Server) *cobra.Command {
+	to := config.DB{}
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Copy all objects from the configured DB to another DB backend",
+		Long:  "Copy all objects with all their generations from the configured DB to another DB backend. Server should be stopped while migrating, migration could be safely re-run",
+
+		Run: func(cmd *cobra.Command, args []string) {
+			from, err := server.NewStore(cfg.DB)
+			if err != nil {
+				panic(fmt.Sprintf("can't create source %s store: %s", cfg.DB.GetBackend(), err))
+			}
+			defer from.Close() // nolint: errcheck
+
+			dest, err := server.NewStore(to)
+			if err != nil {
+				panic(fmt.Sprintf("can't create destination %s store: %s", to.GetBackend(), err))
+			}
+			defer dest.Close() // nolint: errcheck
+
+			result, err := registry.Migrate(from, dest)
+			if err != nil {
+				panic(fmt.Sprintf("error while migrating from %s to %s: %s", cfg.DB.GetBackend(), to.GetBackend(), err))
+			}
+
+			kinds := make([]string, 0, len(result))
+			for kind := range result {
+				kinds = append(kinds, kind)
+			}
+			sort.Strings(kinds)
+			for _, kind := range kinds {
+				fmt.Printf("%s: %d\n", kind, result[kind])
+			}
+			fmt.Printf("Migration from %s to %s completed\n", cfg.DB.GetBackend(), to.GetBackend())
+		},
+	}
+
+	cmd.Flags().StringVar(&to.Backend, "to-backend", config.DBBackendEtcd, "Destination DB backend (etcd or bolt)")
+	cmd.Flags().StringSliceVar(&to.Endpoints, "to-endpoints", []string{"127.0.0.1:2379"}, "Destination etcd endpoints")
+	cmd.Flags().StringVar(&to.Prefix, "to-prefix", "", "Destination etcd prefix")
+	cmd.Flags().StringVar(&to.Bolt.Path, "to-bolt-path", "", "Destination DB file for bolt backend")
+	cmd.Flags().StringVar(&to.Codec, "to-codec", config.DBCodecYAML, "Destination DB codec (yaml or gob)")
+
+	return cmd
+}
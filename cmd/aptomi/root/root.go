@@ -4,6 +4,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/Aptomi/aptomi/cmd/aptomi/migrate"
 	"github.com/Aptomi/aptomi/cmd/aptomi/server"
 	"github.com/Aptomi/aptomi/cmd/aptomi/version"
 	"github.com/Aptomi/aptomi/cmd/common"
@@ -43,19 +44,43 @@ func init() {
 	common.AddDefaultFlags(Command, envPrefix)
 
 	// add server-specific flags
+	common.AddStringFlag(Command, "db.backend", "db-backend", "", "etcd", envPrefix+"_DB_BACKEND", "DB backend (etcd or bolt)")
 	common.AddStringSliceFlag(Command, "db.endpoints", "db", "", []string{"127.0.0.1:2379"}, envPrefix+"_DB_ENDPOINTS", "DB endpoints")
+	common.AddStringFlag(Command, "db.bolt.path", "db-bolt-path", "", "/var/lib/aptomi/db.bolt", envPrefix+"_DB_BOLT_PATH", "Path to the DB file for bolt backend")
+	common.AddStringFlag(Command, "db.codec", "db-codec", "", "yaml", envPrefix+"_DB_CODEC", "DB codec to store objects (yaml or gob)")
+	common.AddStringFlag(Command, "db.compression", "db-compression", "", "", envPrefix+"_DB_COMPRESSION", "Compression of stored objects (gzip or snappy), disabled by default")
+	common.AddIntFlag(Command, "db.compressionLevel", "db-compression-level", "", 0, envPrefix+"_DB_COMPRESSION_LEVEL", "Gzip compression level of stored objects (1-9, 0 means default)")
+	common.AddBoolFlag(Command, "db.metrics", "db-metrics", "", false, envPrefix+"_DB_METRICS", "Enable collecting metrics for all DB operations")
 	common.AddStringFlag(Command, "ui.schema", "ui-schema", "", "http", envPrefix+"_SCHEMA", "Server UI schema")
 	common.AddBoolFlag(Command, "ui.enable", "ui", "", true, envPrefix+"_UI", "Enable server to serve UI")
 	common.AddDurationFlag(Command, "enforcer.interval", "enforcer-interval", "", 60*time.Second, envPrefix+"_ENFORCER_INTERVAL", "Desired state enforcer interval")
 	common.AddIntFlag(Command, "enforcer.maxConcurrentActions", "enforcer-max-concurrent-actions", "", 30, envPrefix+"_ENFORCER_MAX_CONCURRENT_ACTIONS", "Desired state enforcer max concurrent actions")
+	common.AddBoolFlag(Command, "enforcer.failureInjection", "enforcer-failure-injection", "", false, envPrefix+"_ENFORCER_FAILURE_INJECTION", "Enable failure injection into applied actions via admin API (testing only, never enable in production)")
+	common.AddBoolFlag(Command, "enforcer.leaderElection", "enforcer-leader-election", "", false, envPrefix+"_ENFORCER_LEADER_ELECTION", "Elect a single leader running desired state enforcement among servers sharing the same etcd (etcd backend only)")
+	common.AddStringFlag(Command, "enforcer.leaderIdentity", "enforcer-leader-identity", "", "", envPrefix+"_ENFORCER_LEADER_IDENTITY", "Identity of the server in leader election (host name and process id by default)")
+	common.AddIntFlag(Command, "pipeline.queueSize", "pipeline-queue-size", "", 100, envPrefix+"_PIPELINE_QUEUE_SIZE", "Max number of policy changes waiting for resolution in the background")
+	common.AddIntFlag(Command, "pipeline.workers", "pipeline-workers", "", 2, envPrefix+"_PIPELINE_WORKERS", "Number of policy changes resolved in the background in parallel")
+	common.AddIntFlag(Command, "pipeline.syncMaxObjects", "pipeline-sync-max-objects", "", 20, envPrefix+"_PIPELINE_SYNC_MAX_OBJECTS", "Max number of objects in a policy change, which still gets resolved synchronously within API request")
+	common.AddDurationFlag(Command, "pipeline.jobRetention", "pipeline-job-retention", "", 24*time.Hour, envPrefix+"_PIPELINE_JOB_RETENTION", "How long jobs of asynchronous policy changes are kept after they finish")
+	common.AddIntFlag(Command, "resolver.maxConcurrency", "resolver-max-concurrency", "", 0, envPrefix+"_RESOLVER_MAX_CONCURRENCY", "Max number of claims resolved concurrently within a single policy resolution (0 means the number of CPUs)")
+	common.AddIntFlag(Command, "resolver.cacheSize", "resolver-cache-size", "", 10, envPrefix+"_RESOLVER_CACHE_SIZE", "Max number of desired states of policy generations cached in memory by API (negative value disables caching)")
 	common.AddDurationFlag(Command, "updater.interval", "updater-interval", "", 60*time.Second, envPrefix+"_UPDATER_INTERVAL", "Actual state updater interval")
 	common.AddIntFlag(Command, "updater.maxConcurrentActions", "updater-max-concurrent-actions", "", 30, envPrefix+"_UPDATER_MAX_CONCURRENT_ACTIONS", "Actual state updater max concurrent actions")
+	common.AddBoolFlag(Command, "gc.disabled", "gc-disabled", "", false, envPrefix+"_GC_DISABLED", "Disable periodic garbage collection of old generations of revisions, policies and policy objects")
+	common.AddDurationFlag(Command, "gc.interval", "gc-interval", "", time.Hour, envPrefix+"_GC_INTERVAL", "Garbage collection interval")
+	common.AddIntFlag(Command, "gc.keepLast", "gc-keep-last", "", 10, envPrefix+"_GC_KEEP_LAST", "Number of last generations kept for every policy object by garbage collection")
+	common.AddIntFlag(Command, "gc.keepLastHistory", "gc-keep-last-history", "", 100, envPrefix+"_GC_KEEP_LAST_HISTORY", "Number of last generations of policy and revision kept by garbage collection")
 	common.AddStringFlag(Command, "profile.cpu", "cpuprofile", "", "", envPrefix+"_CPU_PROFILE", "File to write debug CPU profiling information using Go runtime/pprof")
 	common.AddStringFlag(Command, "profile.trace", "traceprofile", "", "", envPrefix+"_TRACE_PROFILE", "File to write debug tracing information using Go runtime/trace")
+	common.AddBoolFlag(Command, "tracing.enabled", "tracing", "", false, envPrefix+"_TRACING", "Enable exporting OpenTelemetry traces to the collector")
+	common.AddStringFlag(Command, "tracing.endpoint", "tracing-endpoint", "", "http://127.0.0.1:14268/api/traces", envPrefix+"_TRACING_ENDPOINT", "Jaeger collector HTTP endpoint to export traces to")
+	common.AddIntFlag(Command, "eventlog.maxentries", "eventlog-max-entries", "", 0, envPrefix+"_EVENTLOG_MAX_ENTRIES", "Max number of events kept in memory per event log (0 means unlimited)")
+	common.AddStringFlag(Command, "eventlog.overflow", "eventlog-overflow", "", "spill", envPrefix+"_EVENTLOG_OVERFLOW", "What to do with older debug/info events when event log exceeds its limit (spill or drop)")
 
 	Command.AddCommand(
 		version.NewVersionCommand(),
 		server.NewServerCommand(Config),
+		migrate.NewMigrateCommand(Config),
 	)
 }
 
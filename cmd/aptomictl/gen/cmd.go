@@ -16,6 +16,7 @@ func NewCommand(cfg *config.Client) *cobra.Command {
 	cmd.AddCommand(
 		newClusterCommand(cfg),
 		newUserCommand(cfg),
+		newPolicyCommand(cfg),
 	)
 
 	return cmd
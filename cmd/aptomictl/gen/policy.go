@@ -0,0 +1,57 @@
+package gen
+
+import (
+	"os"
+
+	"github.com/Aptomi/aptomi/pkg/config"
+	"github.com/Aptomi/aptomi/pkg/engine/enginetest"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newPolicyCommand(_ *config.Client) *cobra.Command {
+	params := enginetest.SyntheticPolicySmall
+	var usersFile, password string
+
+	cmd := &cobra.Command{
+		Use:   "policy",
+		Short: "gen synthetic policy",
+		Long:  "gen synthetic policy of a given shape for load testing. Policy objects get printed to stdout, users get written into a users file for the server",
+
+		Run: func(cmd *cobra.Command, args []string) {
+			synthetic := enginetest.NewSyntheticPolicy(params)
+			if len(password) > 0 {
+				synthetic.SetPassword(password)
+			}
+
+			var err error
+			if len(usersFile) > 0 {
+				var users *os.File
+				users, err = os.Create(usersFile)
+				if err != nil {
+					log.Fatalf("error while creating users file: %s", err)
+				}
+				defer users.Close() // nolint: errcheck
+				err = synthetic.WriteYAML(os.Stdout, users)
+			} else {
+				err = synthetic.WriteYAML(os.Stdout, nil)
+			}
+			if err != nil {
+				log.Fatalf("error while writing synthetic policy: %s", err)
+			}
+		},
+	}
+
+	cmd.Flags().Int64Var(&params.Seed, "seed", params.Seed, "Seed for random generator, the same seed always produces the same policy")
+	cmd.Flags().IntVar(&params.Namespaces, "namespaces", params.Namespaces, "Number of namespaces")
+	cmd.Flags().IntVar(&params.ServicesPerNamespace, "services", params.ServicesPerNamespace, "Number of services per namespace")
+	cmd.Flags().IntVar(&params.FanOut, "fanout", params.FanOut, "Number of services every bundle depends on")
+	cmd.Flags().IntVar(&params.Rules, "rules", params.Rules, "Number of rules")
+	cmd.Flags().IntVar(&params.Clusters, "clusters", params.Clusters, "Number of clusters")
+	cmd.Flags().IntVar(&params.Claims, "claims", params.Claims, "Number of claims")
+	cmd.Flags().IntVar(&params.Users, "users", params.Users, "Number of users")
+	cmd.Flags().StringVar(&usersFile, "users-file", "", "File to write generated users to")
+	cmd.Flags().StringVar(&password, "password", "", "Password to set for all generated users")
+
+	return cmd
+}
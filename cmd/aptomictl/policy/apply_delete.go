@@ -19,6 +19,7 @@ func newHandlePolicyChangesCommand(cfg *config.Client, createUpdate bool) *cobra
 	paths := make([]string, 0)
 	var wait bool
 	var noop bool
+	var continueOnError bool
 	var waitInterval time.Duration
 	var waitTime time.Duration
 	var logLevel string
@@ -47,9 +48,9 @@ func newHandlePolicyChangesCommand(cfg *config.Client, createUpdate bool) *cobra
 			clientObj := rest.New(cfg, http.NewClient(cfg))
 			var result *api.PolicyUpdateResult
 			if createUpdate {
-				result, err = clientObj.Policy().Apply(allObjects, noop, logLevelObj)
+				result, err = clientObj.Policy().Apply(allObjects, noop, logLevelObj, continueOnError)
 			} else {
-				result, err = clientObj.Policy().Delete(allObjects, noop, logLevelObj)
+				result, err = clientObj.Policy().Delete(allObjects, noop, logLevelObj, continueOnError)
 			}
 			if err != nil {
 				log.Fatalf("error while calling %s on policy: %s", commandType, err)
@@ -71,6 +72,7 @@ func newHandlePolicyChangesCommand(cfg *config.Client, createUpdate bool) *cobra
 		panic(err)
 	}
 	cmd.Flags().BoolVar(&noop, "noop", false, "Produce action plan for the given changes in policy, but do not run any actions to update the state")
+	cmd.Flags().BoolVar(&continueOnError, "continue-on-error", false, "Update policy even if some claims can't be resolved, enforcing the rest of claims")
 	cmd.Flags().BoolVar(&wait, "wait", false, "Wait until all actions are fully applied")
 	cmd.Flags().DurationVar(&waitInterval, "wait-interval", 2*time.Second, "Seconds to sleep between wait attempts")
 	cmd.Flags().DurationVar(&waitTime, "wait-time", 10*time.Minute, "Max time to wait before failing the wait process")
@@ -15,6 +15,9 @@ func NewCommand(cfg *config.Client) *cobra.Command {
 
 	cmd.AddCommand(
 		newShowCommand(cfg),                       // show
+		newHistoryCommand(cfg),                    // history
+		newDiffCommand(cfg),                       // diff
+		newRollbackCommand(cfg),                   // rollback
 		newHandlePolicyChangesCommand(cfg, true),  // apply
 		newHandlePolicyChangesCommand(cfg, false), // delete
 	)
@@ -0,0 +1,57 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Aptomi/aptomi/cmd/common"
+	"github.com/Aptomi/aptomi/pkg/client/rest"
+	"github.com/Aptomi/aptomi/pkg/client/rest/http"
+	"github.com/Aptomi/aptomi/pkg/config"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newDiffCommand(cfg *config.Client) *cobra.Command {
+	var from, to uint64 // == runtime.Generation
+	var resolve bool
+
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "policy diff",
+		Long:  "policy diff shows policy objects changed between two policy generations and, optionally, the action plan to get from one of them to another",
+
+		Run: func(cmd *cobra.Command, args []string) {
+			if from == 0 || to == 0 {
+				log.Fatalf("both policy generations (--from and --to) should be specified")
+			}
+
+			result, err := rest.New(cfg, http.NewClient(cfg)).Policy().Diff(runtime.Generation(from), runtime.Generation(to), resolve)
+			if err != nil {
+				log.Fatalf("error while getting policy diff: %s", err)
+			}
+
+			data, err := common.Format(cfg.Output, false, result)
+			if err != nil {
+				log.Fatalf("error while formatting policy diff: %s", err)
+			}
+			fmt.Println(string(data))
+
+			// diffs of modified objects don't fit into the table, so they get printed after it
+			if strings.ToLower(cfg.Output) == common.Text {
+				for _, obj := range result.Objects {
+					if len(obj.Diff) > 0 {
+						fmt.Println(obj.Diff)
+					}
+				}
+			}
+		},
+	}
+
+	cmd.Flags().Uint64VarP(&from, "from", "f", 0, "Policy generation to compare from")
+	cmd.Flags().Uint64VarP(&to, "to", "t", 0, "Policy generation to compare to")
+	cmd.Flags().BoolVarP(&resolve, "resolve", "r", false, "Resolve both policy generations and show the action plan between them")
+
+	return cmd
+}
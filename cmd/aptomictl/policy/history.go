@@ -0,0 +1,51 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/Aptomi/aptomi/cmd/common"
+	"github.com/Aptomi/aptomi/pkg/client/rest"
+	"github.com/Aptomi/aptomi/pkg/client/rest/http"
+	"github.com/Aptomi/aptomi/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newHistoryCommand(cfg *config.Client) *cobra.Command {
+	var limit int
+	var token string
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "policy history",
+		Long:  "policy history shows policy generations starting from the latest one, along with who made them and when",
+
+		Run: func(cmd *cobra.Command, args []string) {
+			result, err := rest.New(cfg, http.NewClient(cfg)).Policy().History(limit, token)
+			if err != nil {
+				log.Fatalf("error while getting policy history: %s", err)
+			}
+
+			rows := result.AsDisplayableList()
+			if len(rows) == 0 {
+				fmt.Println("No policy generations found")
+				return
+			}
+
+			data, err := common.Format(cfg.Output, true, rows...)
+			if err != nil {
+				log.Fatalf("error while formatting policy history: %s", err)
+			}
+			fmt.Println(string(data))
+
+			if len(result.Continue) > 0 {
+				fmt.Printf("More generations could be shown with --continue %s\n", result.Continue)
+			}
+		},
+	}
+
+	cmd.Flags().IntVarP(&limit, "limit", "l", 0, "Max number of policy generations to show (all by default)")
+	cmd.Flags().StringVar(&token, "continue", "", "Token to show the next page of policy generations")
+
+	return cmd
+}
@@ -0,0 +1,63 @@
+package policy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Aptomi/aptomi/cmd/aptomictl/util"
+	"github.com/Aptomi/aptomi/pkg/client/rest"
+	"github.com/Aptomi/aptomi/pkg/client/rest/http"
+	"github.com/Aptomi/aptomi/pkg/config"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newRollbackCommand(cfg *config.Client) *cobra.Command {
+	var gen uint64 // == runtime.Generation
+	var wait bool
+	var noop bool
+	var waitInterval time.Duration
+	var waitTime time.Duration
+	var logLevel string
+
+	cmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "rollback policy",
+		Long:  "rollback policy makes a new policy generation with the same objects as in a given generation",
+
+		Run: func(cmd *cobra.Command, args []string) {
+			if gen == 0 {
+				log.Fatalf("policy generation to roll back to (--generation) should be specified")
+			}
+
+			logLevelObj, err := log.ParseLevel(logLevel)
+			if err != nil {
+				logLevelObj = log.WarnLevel
+			}
+
+			clientObj := rest.New(cfg, http.NewClient(cfg))
+			result, err := clientObj.Policy().Rollback(runtime.Generation(gen), noop, logLevelObj)
+			if err != nil {
+				log.Fatalf("error while rolling back policy: %s", err)
+			}
+
+			// print policy update result to the screen
+			util.PrintPolicyUpdateResult(result, logLevelObj, cfg)
+
+			// wait for actions to finish, if needed
+			if wait {
+				util.WaitForRevisionActionsToFinish(waitTime, waitInterval, clientObj, result)
+			}
+		},
+	}
+
+	cmd.Flags().Uint64VarP(&gen, "generation", "g", 0, "Policy generation to roll back to")
+	cmd.Flags().BoolVar(&noop, "noop", false, "Produce action plan for the rollback, but do not run any actions to update the state")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait until all actions are fully applied")
+	cmd.Flags().DurationVar(&waitInterval, "wait-interval", 2*time.Second, "Seconds to sleep between wait attempts")
+	cmd.Flags().DurationVar(&waitTime, "wait-time", 10*time.Minute, "Max time to wait before failing the wait process")
+	cmd.Flags().StringVar(&logLevel, "log-level", log.WarnLevel.String(), fmt.Sprintf("Retrieve logs from the server using the specified log level (%s)", log.AllLevels))
+
+	return cmd
+}
@@ -40,7 +40,7 @@ func WaitForRevisionActionsToFinish(maxTime time.Duration, interval time.Duratio
 		}
 
 		// if the engine already started processing the revision, show its progress
-		if rev.Status != engine.RevisionStatusWaiting {
+		if rev.Status != engine.RevisionStatusWaiting && rev.Status != engine.RevisionStatusResolving {
 			if progressBar == nil {
 				fmt.Println()
 
@@ -50,14 +50,14 @@ func WaitForRevisionActionsToFinish(maxTime time.Duration, interval time.Duratio
 					progressBar.SetTotal(int(rev.Result.Total))
 				}
 			}
-			for progressBar != nil && progressLast < int(rev.Result.Success+rev.Result.Failed+rev.Result.Skipped) {
+			for progressBar != nil && progressLast < int(rev.Result.Processed()) {
 				progressBar.Advance()
 				progressLast++
 			}
 		}
 
-		// exit when revision is in completed or error status
-		return rev.Status == engine.RevisionStatusCompleted || rev.Status == engine.RevisionStatusError
+		// exit when revision is in completed, error or superseded status
+		return rev.IsFinished()
 	})
 
 	// stop progress bar
@@ -70,10 +70,14 @@ func WaitForRevisionActionsToFinish(maxTime time.Duration, interval time.Duratio
 		log.Fatalf("Revision %d timeout! Has not been applied in %s\n", rev.GetGeneration(), maxTime)
 	} else if rev.Status == engine.RevisionStatusCompleted {
 		if rev.Result.Total > 0 {
-			fmt.Printf("Revision %d completed. Actions: %d succeeded, %d failed, %d skipped\n", rev.GetGeneration(), rev.Result.Success, rev.Result.Failed, rev.Result.Skipped)
+			fmt.Printf("Revision %d completed. Actions: %d succeeded, %d failed, %d skipped, %d previously completed\n", rev.GetGeneration(), rev.Result.Success, rev.Result.Failed, rev.Result.Skipped, rev.Result.PreviouslyCompleted)
 		} else {
 			fmt.Printf("Revision %d completed\n", rev.GetGeneration())
 		}
+	} else if rev.Status == engine.RevisionStatusSuperseded {
+		fmt.Printf("Revision %d superseded by a newer revision, which includes its changes\n", rev.GetGeneration())
+	} else if rev.Status == engine.RevisionStatusError && len(rev.ResolutionError) > 0 {
+		log.Fatalf("Revision %d failed: %s\n", rev.GetGeneration(), rev.ResolutionError)
 	} else if rev.Status == engine.RevisionStatusError {
 		log.Fatalf("Revision %d failed\n", rev.GetGeneration())
 	} else {
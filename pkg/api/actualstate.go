@@ -36,17 +36,17 @@ func isDomainAdmin(user *lang.User, policy *lang.Policy) bool {
 	return false
 }
 
-func (api *coreAPI) handleStateEnforce(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+func (api *coreAPI) handleStateEnforce(writer http.ResponseWriter, request *http.Request, params httprouter.Params) error {
 	// Load current policy
 	policy, policyGen, err := api.registry.GetPolicy(runtime.LastOrEmptyGen)
 	if err != nil {
-		panic(fmt.Sprintf("error while loading latest policy: %s", err))
+		return fmt.Errorf("error while loading latest policy: %s", err)
 	}
 
 	// check that user is a domain admin
 	user := api.getUserRequired(request)
 	if !isDomainAdmin(user, policy) {
-		panic(fmt.Sprintf("user is not allowed to trigger actual state enforcement"))
+		return newRequestError(http.StatusForbidden, ErrorCodeForbidden, "user is not allowed to trigger actual state enforcement")
 	}
 
 	// See if noop flag is set
@@ -56,25 +56,31 @@ func (api *coreAPI) handleStateEnforce(writer http.ResponseWriter, request *http
 	}
 
 	// See that would happen if we reset the actual state, calculate resolution log and action plan
-	resolveLog := event.NewLog(logrus.InfoLevel, "api-state-enforce").AddConsoleHook(api.logLevel)
-	desiredState := resolve.NewPolicyResolver(policy, api.externalData, resolveLog).ResolveAllClaims()
+	resolveLog := api.newEventLog(logrus.InfoLevel, "api-state-enforce")
+	desiredState, err := api.resolvePolicy(request.Context(), policy, policyGen, resolveLog)
+	if err != nil {
+		return resolutionError(err)
+	}
 	actionPlan := diff.NewPolicyResolutionDiff(desiredState, resolve.NewPolicyResolution()).ActionPlan
 
 	// If we are in noop mode, just return expected changes in a form of an action plan
 	if noop {
 		api.contentType.WriteOne(writer, request, &PolicyUpdateResult{
 			TypeKind:         TypePolicyUpdateResult.GetTypeKind(),
-			PolicyGeneration: policyGen,                // policy generation didn't change
-			PolicyChanged:    false,                    // policy has not been updated in the registry
-			WaitForRevision:  runtime.MaxGeneration,    // nothing to wait for
-			PlanAsText:       actionPlan.AsText(),      // return action plan, so it can be printed by the client
-			EventLog:         resolveLog.AsAPIEvents(), // return policy resolution log
+			PolicyGeneration: policyGen,                                      // policy generation didn't change
+			PolicyChanged:    false,                                          // policy has not been updated in the registry
+			WaitForRevision:  runtime.MaxGeneration,                          // nothing to wait for
+			PlanAsText:       filterActionPlan(request, actionPlan).AsText(), // return action plan, so it can be printed by the client
+			EventLog:         resolveLog.AsAPIEvents(),                       // return policy resolution log
 		})
-		return
+		return nil
 	}
 
 	// Keep policy the same, but create another special revision for it to enforce the state
-	revisionGen := api.createStateEnforceRevision(policyGen, desiredState, actionPlan)
+	revisionGen, err := api.createStateEnforceRevision(policyGen, desiredState, resolveLog.AsAPIEvents(), actionPlan)
+	if err != nil {
+		return err
+	}
 
 	api.contentType.WriteOne(writer, request, &PolicyUpdateResult{
 		TypeKind:         TypePolicyUpdateResult.GetTypeKind(),
@@ -86,10 +92,12 @@ func (api *coreAPI) handleStateEnforce(writer http.ResponseWriter, request *http
 	})
 
 	// signal to the channel that actual state has changed, that will trigger the enforcement right away
-	api.runDesiredStateEnforcement <- true
+	api.triggerEnforcement()
+
+	return nil
 }
 
-func (api *coreAPI) createStateEnforceRevision(policyGen runtime.Generation, desiredState *resolve.PolicyResolution, actionPlan *action.Plan) runtime.Generation {
+func (api *coreAPI) createStateEnforceRevision(policyGen runtime.Generation, desiredState *resolve.PolicyResolution, resolutionEvents []*event.APIEvent, actionPlan *action.Plan) (runtime.Generation, error) {
 	// Here we need to take mutex to handle policy and revision updates
 	api.policyAndRevisionUpdateMutex.Lock()
 	defer api.policyAndRevisionUpdateMutex.Unlock()
@@ -100,10 +108,15 @@ func (api *coreAPI) createStateEnforceRevision(policyGen runtime.Generation, des
 		// If there are changes, create a new revision and say that we should wait for it
 		newRevision, newRevisionErr := api.registry.NewRevision(policyGen, desiredState, true)
 		if newRevisionErr != nil {
-			panic(fmt.Errorf("unable to create new revision for policy gen %d", policyGen))
+			return runtime.MaxGeneration, fmt.Errorf("unable to create new revision for policy gen %d: %s", policyGen, newRevisionErr)
 		}
 		revisionGen = newRevision.GetGeneration()
+
+		saveErr := api.registry.SaveResolutionLog(newRevision, resolutionEvents)
+		if saveErr != nil {
+			return runtime.MaxGeneration, fmt.Errorf("unable to save resolution log for revision %d: %s", revisionGen, saveErr)
+		}
 	}
 
-	return revisionGen
+	return revisionGen, nil
 }
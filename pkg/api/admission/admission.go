@@ -0,0 +1,252 @@
+package admission
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"gopkg.in/yaml.v2"
+)
+
+// Operation is a type of policy change being admitted
+type Operation string
+
+const (
+	// OperationUpdate is when objects are added to the policy or updated in it
+	OperationUpdate Operation = "update"
+
+	// OperationDelete is when objects are deleted from the policy
+	OperationDelete Operation = "delete"
+)
+
+// Request is a batch of objects being changed in the policy by a given user
+type Request struct {
+	Operation Operation
+	User      *lang.User
+	Objects   []lang.Base
+}
+
+// Response is a decision of a single webhook on a given request
+type Response struct {
+	// Allowed is true if the policy change is allowed
+	Allowed bool
+
+	// Messages explain the decision (e.g. why the policy change has been denied)
+	Messages []string
+
+	// Objects, if not nil, replace objects from the request. Webhook may change objects, but it can't add or remove
+	// them. Mutations are ignored for deletes
+	Objects []lang.Base
+}
+
+// Webhook is a single step of the admission, which decides whether a given policy change should be allowed
+type Webhook interface {
+	// GetName returns name of the webhook to be used in the event log and results
+	GetName() string
+
+	// Admit returns the decision on a given request. Error means that webhook wasn't able to make a decision (e.g.
+	// it's not reachable), so the failure policy of the webhook applies
+	Admit(ctx context.Context, request *Request) (*Response, error)
+}
+
+// Result is an outcome of calling a single webhook, which gets returned to the client and recorded in the event log
+type Result struct {
+	Webhook  string        `yaml:"webhook"`
+	Allowed  bool          `yaml:"allowed"`
+	Messages []string      `yaml:"messages,omitempty"`
+	Mutated  []string      `yaml:"mutated,omitempty"`
+	Error    string        `yaml:"error,omitempty"`
+	Duration time.Duration `yaml:"duration"`
+}
+
+// Review is an outcome of the admission of a given request by all webhooks in the chain
+type Review struct {
+	// Objects are the resulting objects after all mutations
+	Objects []lang.Base
+
+	// Results are outcomes of all called webhooks in the order of calls. Once a webhook denies the request, the
+	// following webhooks don't get called
+	Results []*Result
+
+	// Allowed is true if the policy change has been allowed by all webhooks
+	Allowed bool
+}
+
+// DeniedMessages returns messages of the webhook, which denied the policy change
+func (review *Review) DeniedMessages() []string {
+	result := []string{}
+	for _, res := range review.Results {
+		if res.Allowed {
+			continue
+		}
+		if len(res.Error) > 0 {
+			result = append(result, fmt.Sprintf("%s: webhook call failed: %s", res.Webhook, res.Error))
+		}
+		for _, msg := range res.Messages {
+			result = append(result, fmt.Sprintf("%s: %s", res.Webhook, msg))
+		}
+		if len(res.Error) <= 0 && len(res.Messages) <= 0 {
+			result = append(result, fmt.Sprintf("%s: denied", res.Webhook))
+		}
+	}
+	return result
+}
+
+// Log records the outcomes of all called webhooks in the event log
+func (review *Review) Log(eventLog *event.Log) {
+	for _, res := range review.Results {
+		switch {
+		case !res.Allowed && len(res.Error) > 0:
+			eventLog.NewEntry().Errorf("Admission webhook '%s' failed, policy change denied (fail-closed): %s", res.Webhook, res.Error)
+		case !res.Allowed:
+			eventLog.NewEntry().Errorf("Admission webhook '%s' denied policy change: %s", res.Webhook, strings.Join(res.Messages, "; "))
+		case len(res.Error) > 0:
+			eventLog.NewEntry().Warningf("Admission webhook '%s' failed, policy change allowed (fail-open): %s", res.Webhook, res.Error)
+		default:
+			eventLog.NewEntry().Infof("Admission webhook '%s' allowed policy change in %s", res.Webhook, res.Duration)
+		}
+		for _, msg := range res.Messages {
+			if res.Allowed {
+				eventLog.NewEntry().Warningf("Admission webhook '%s': %s", res.Webhook, msg)
+			}
+		}
+		if len(res.Mutated) > 0 {
+			eventLog.NewEntry().Infof("Admission webhook '%s' mutated objects: %s", res.Webhook, strings.Join(res.Mutated, ", "))
+		}
+	}
+}
+
+// chainEntry is a webhook along with its failure policy
+type chainEntry struct {
+	webhook  Webhook
+	failOpen bool
+}
+
+// Chain calls webhooks one by one in the order they have been added, passing objects mutated by a webhook to the
+// next one. Empty chain allows everything
+type Chain struct {
+	entries []*chainEntry
+}
+
+// NewChain creates a new empty Chain
+func NewChain() *Chain {
+	return &Chain{}
+}
+
+// Add adds a webhook to the chain. If failOpen is true, policy change gets allowed when webhook fails to make a
+// decision, otherwise it gets denied
+func (chain *Chain) Add(webhook Webhook, failOpen bool) *Chain {
+	chain.entries = append(chain.entries, &chainEntry{webhook: webhook, failOpen: failOpen})
+	return chain
+}
+
+// Len returns the number of webhooks in the chain
+func (chain *Chain) Len() int {
+	return len(chain.entries)
+}
+
+// Admit runs a given request through all webhooks in the chain and returns the review
+func (chain *Chain) Admit(ctx context.Context, request *Request) *Review {
+	review := &Review{
+		Objects: request.Objects,
+		Results: []*Result{},
+		Allowed: true,
+	}
+
+	for _, entry := range chain.entries {
+		started := time.Now()
+		response, err := entry.webhook.Admit(ctx, &Request{Operation: request.Operation, User: request.User, Objects: review.Objects})
+
+		var mutated []string
+		var objects []lang.Base
+		if err == nil && response.Allowed && response.Objects != nil && request.Operation == OperationUpdate {
+			objects, mutated, err = getMutated(review.Objects, response.Objects)
+		}
+
+		result := &Result{
+			Webhook:  entry.webhook.GetName(),
+			Duration: time.Since(started),
+		}
+		review.Results = append(review.Results, result)
+
+		if err != nil {
+			result.Error = err.Error()
+			result.Allowed = entry.failOpen
+			if !result.Allowed {
+				review.Allowed = false
+				return review
+			}
+			continue
+		}
+
+		result.Allowed = response.Allowed
+		result.Messages = response.Messages
+		if !result.Allowed {
+			review.Allowed = false
+			return review
+		}
+
+		if len(mutated) > 0 {
+			result.Mutated = mutated
+			review.Objects = objects
+		}
+	}
+
+	return review
+}
+
+// getMutated matches objects returned by a webhook with the original ones by their keys and returns them in the
+// original order, along with the sorted keys of the changed objects. Webhook isn't allowed to add or remove objects
+func getMutated(original []lang.Base, returned []lang.Base) ([]lang.Base, []string, error) {
+	returnedByKey := make(map[string]lang.Base, len(returned))
+	for _, obj := range returned {
+		key := runtime.KeyForStorable(obj)
+		if _, exist := returnedByKey[key]; exist {
+			return nil, nil, fmt.Errorf("webhook returned object %s more than once", key)
+		}
+		returnedByKey[key] = obj
+	}
+	if len(returnedByKey) != len(original) {
+		return nil, nil, fmt.Errorf("webhook returned %d objects instead of %d, objects can't be added or removed", len(returnedByKey), len(original))
+	}
+
+	result := make([]lang.Base, 0, len(original))
+	mutated := []string{}
+	for _, obj := range original {
+		key := runtime.KeyForStorable(obj)
+		returnedObj, exist := returnedByKey[key]
+		if !exist {
+			return nil, nil, fmt.Errorf("webhook didn't return object %s, objects can't be added or removed", key)
+		}
+
+		changed, err := objectChanged(obj, returnedObj)
+		if err != nil {
+			return nil, nil, err
+		}
+		if changed {
+			mutated = append(mutated, key)
+		}
+		result = append(result, returnedObj)
+	}
+	sort.Strings(mutated)
+
+	return result, mutated, nil
+}
+
+// objectChanged returns true if objects are different, when compared in their yaml representation
+func objectChanged(prev lang.Base, next lang.Base) (bool, error) {
+	prevData, err := yaml.Marshal(prev)
+	if err != nil {
+		return false, fmt.Errorf("error while marshaling object %s: %s", runtime.KeyForStorable(prev), err)
+	}
+	nextData, err := yaml.Marshal(next)
+	if err != nil {
+		return false, fmt.Errorf("error while marshaling object %s: %s", runtime.KeyForStorable(next), err)
+	}
+	return string(prevData) != string(nextData), nil
+}
@@ -0,0 +1,157 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeClaim(name string, labels map[string]string) *lang.Claim {
+	return &lang.Claim{
+		TypeKind: lang.TypeClaim.GetTypeKind(),
+		Metadata: lang.Metadata{
+			Namespace: "main",
+			Name:      name,
+		},
+		User:    "alice",
+		Service: "service",
+		Labels:  labels,
+	}
+}
+
+func makeRequest(objects ...lang.Base) *Request {
+	return &Request{
+		Operation: OperationUpdate,
+		User:      &lang.User{Name: "alice", PasswordHash: "hash"},
+		Objects:   objects,
+	}
+}
+
+// newWebhookServer starts a webhook server, which sets team label on all claims and denies claims named "forbidden"
+func newWebhookServer(t *testing.T) *httptest.Server {
+	jsonCodec := codec.NewJSONCodec(runtime.NewTypes().Append(lang.PolicyTypes...))
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, "Bearer token", request.Header.Get("Authorization"), "Bearer token should be sent")
+
+		body := &webhookRequest{}
+		if !assert.NoError(t, json.NewDecoder(request.Body).Decode(body), "Request should be decoded") {
+			return
+		}
+		assert.Equal(t, "alice", body.User.Name, "User should be sent")
+
+		response := &webhookResponse{Allowed: true}
+		for _, data := range body.Objects {
+			obj, err := jsonCodec.DecodeOne(data)
+			if !assert.NoError(t, err, "Object should be decoded") {
+				return
+			}
+			claim := obj.(*lang.Claim)
+			if claim.Name == "forbidden" {
+				response.Allowed = false
+				response.Messages = append(response.Messages, "claim name is forbidden")
+			}
+			if claim.Labels == nil {
+				claim.Labels = map[string]string{}
+			}
+			claim.Labels["team"] = "platform"
+			data, err = jsonCodec.EncodeOne(claim)
+			if !assert.NoError(t, err, "Object should be encoded") {
+				return
+			}
+			response.Objects = append(response.Objects, data)
+		}
+
+		assert.NoError(t, json.NewEncoder(writer).Encode(response), "Response should be encoded")
+	}))
+}
+
+func TestHTTPWebhook(t *testing.T) {
+	server := newWebhookServer(t)
+	defer server.Close()
+
+	webhook, err := NewHTTPWebhook(&WebhookConfig{Name: "labels", URL: server.URL, Auth: WebhookAuth{BearerToken: "token"}})
+	if !assert.NoError(t, err, "Webhook should be created") {
+		t.FailNow()
+	}
+	chain := NewChain().Add(webhook, false)
+
+	// objects get mutated
+	review := chain.Admit(context.Background(), makeRequest(makeClaim("first", nil), makeClaim("second", map[string]string{"team": "platform"})))
+	assert.True(t, review.Allowed, "Policy change should be allowed")
+	assert.Equal(t, "platform", review.Objects[0].(*lang.Claim).Labels["team"], "Object should be mutated")
+	if assert.Len(t, review.Results, 1, "There should be a single result") {
+		assert.Equal(t, []string{"main/claim/first"}, review.Results[0].Mutated, "Only changed object should be reported as mutated")
+	}
+
+	// policy change gets denied
+	review = chain.Admit(context.Background(), makeRequest(makeClaim("forbidden", nil)))
+	assert.False(t, review.Allowed, "Policy change should be denied")
+	assert.Equal(t, []string{"labels: claim name is forbidden"}, review.DeniedMessages(), "Denial reason should be returned")
+
+	// mutations are ignored for deletes
+	request := makeRequest(makeClaim("first", nil))
+	request.Operation = OperationDelete
+	review = chain.Admit(context.Background(), request)
+	assert.True(t, review.Allowed, "Delete should be allowed")
+	assert.Nil(t, review.Objects[0].(*lang.Claim).Labels, "Deleted object should not be mutated")
+}
+
+func TestHTTPWebhookFailurePolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	failClosed, err := NewHTTPWebhook(&WebhookConfig{Name: "closed", URL: server.URL, Timeout: 10 * time.Millisecond})
+	assert.NoError(t, err, "Webhook should be created")
+	failOpen, err := NewHTTPWebhook(&WebhookConfig{Name: "open", URL: server.URL, Timeout: 10 * time.Millisecond, FailOpen: true})
+	assert.NoError(t, err, "Webhook should be created")
+
+	review := NewChain().Add(failOpen, true).Admit(context.Background(), makeRequest(makeClaim("claim", nil)))
+	assert.True(t, review.Allowed, "Policy change should be allowed by fail-open webhook")
+	assert.NotEmpty(t, review.Results[0].Error, "Webhook error should be recorded")
+
+	review = NewChain().Add(failOpen, true).Add(failClosed, false).Admit(context.Background(), makeRequest(makeClaim("claim", nil)))
+	assert.False(t, review.Allowed, "Policy change should be denied by fail-closed webhook")
+	assert.Len(t, review.Results, 2, "Both webhooks should be called")
+}
+
+func TestBuiltinRules(t *testing.T) {
+	chain, err := NewChainFromConfig(Config{
+		Builtin: &BuiltinRulesConfig{
+			Naming:         []*NamingRule{{Kinds: []string{lang.TypeClaim.Kind}, Pattern: "[a-z]+-[a-z]+"}},
+			RequiredLabels: []*RequiredLabelsRule{{Labels: []string{"team"}}},
+		},
+	})
+	if !assert.NoError(t, err, "Chain should be created") {
+		t.FailNow()
+	}
+
+	review := chain.Admit(context.Background(), makeRequest(makeClaim("app-prod", map[string]string{"team": "platform"})))
+	assert.True(t, review.Allowed, "Valid claim should be allowed")
+
+	review = chain.Admit(context.Background(), makeRequest(makeClaim("app", nil)))
+	assert.False(t, review.Allowed, "Invalid claim should be denied")
+	assert.Equal(t, []string{
+		"builtin-rules: name of main/claim/app doesn't match pattern '[a-z]+-[a-z]+'",
+		"builtin-rules: main/claim/app doesn't have required label 'team'",
+	}, review.DeniedMessages(), "All violations should be reported")
+
+	// objects without labels are not checked for labels
+	review = chain.Admit(context.Background(), makeRequest(&lang.Service{
+		TypeKind: lang.TypeService.GetTypeKind(),
+		Metadata: lang.Metadata{Namespace: "main", Name: "service"},
+	}))
+	assert.True(t, review.Allowed, "Object without labels should be allowed")
+
+	_, err = NewChainFromConfig(Config{Builtin: &BuiltinRulesConfig{Naming: []*NamingRule{{Pattern: "("}}}})
+	assert.Error(t, err, "Invalid pattern should be rejected")
+}
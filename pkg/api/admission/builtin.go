@@ -0,0 +1,134 @@
+package admission
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+// BuiltinRulesName is the name of the builtin rules webhook in the event log and results
+const BuiltinRulesName = "builtin-rules"
+
+// BuiltinRulesConfig represents config of the builtin admission rules, which cover the most common organization
+// rules without running an external webhook
+type BuiltinRulesConfig struct {
+	Naming         []*NamingRule         `validate:"dive"`
+	RequiredLabels []*RequiredLabelsRule `validate:"dive"`
+}
+
+// NamingRule requires names of objects to match a given regular expression
+type NamingRule struct {
+	// Kinds, if set, limits the rule to objects of given kinds only
+	Kinds []string `validate:"-"`
+
+	// Pattern is a regular expression, which object names must match (implicitly anchored at both ends)
+	Pattern string `validate:"required"`
+}
+
+// RequiredLabelsRule requires objects to have given labels set to non-empty values. It only applies to objects,
+// which have labels (e.g. claims, clusters and bundles)
+type RequiredLabelsRule struct {
+	// Kinds, if set, limits the rule to objects of given kinds only
+	Kinds []string `validate:"-"`
+
+	// Labels are names of the labels, which have to be set
+	Labels []string `validate:"required,min=1"`
+}
+
+// compiledNamingRule is a naming rule along with its compiled regular expression
+type compiledNamingRule struct {
+	*NamingRule
+	pattern *regexp.Regexp
+}
+
+// BuiltinRules is a Webhook, which checks objects against the builtin rules locally. It never mutates objects and
+// never fails
+type BuiltinRules struct {
+	naming         []*compiledNamingRule
+	requiredLabels []*RequiredLabelsRule
+}
+
+// NewBuiltinRules creates a new BuiltinRules from a given config
+func NewBuiltinRules(cfg *BuiltinRulesConfig) (*BuiltinRules, error) {
+	result := &BuiltinRules{requiredLabels: cfg.RequiredLabels}
+	for idx, rule := range cfg.Naming {
+		pattern, err := regexp.Compile("^(?:" + rule.Pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern in naming rule #%d: %s", idx, err)
+		}
+		result.naming = append(result.naming, &compiledNamingRule{NamingRule: rule, pattern: pattern})
+	}
+	return result, nil
+}
+
+// GetName returns name of the builtin rules webhook
+func (rules *BuiltinRules) GetName() string {
+	return BuiltinRulesName
+}
+
+// Admit checks all objects being added to the policy against the rules. Deletes are always allowed
+func (rules *BuiltinRules) Admit(ctx context.Context, request *Request) (*Response, error) {
+	response := &Response{Allowed: true}
+	if request.Operation != OperationUpdate {
+		return response, nil
+	}
+
+	for _, obj := range request.Objects {
+		key := runtime.KeyForStorable(obj)
+		for _, rule := range rules.naming {
+			if matchesKind(rule.Kinds, obj.GetKind()) && !rule.pattern.MatchString(obj.GetName()) {
+				response.Messages = append(response.Messages, fmt.Sprintf("name of %s doesn't match pattern '%s'", key, rule.Pattern))
+			}
+		}
+
+		if len(rules.requiredLabels) <= 0 {
+			continue
+		}
+		labels, hasLabels := getLabels(obj)
+		if !hasLabels {
+			continue
+		}
+		for _, rule := range rules.requiredLabels {
+			if !matchesKind(rule.Kinds, obj.GetKind()) {
+				continue
+			}
+			for _, label := range rule.Labels {
+				if len(labels[label]) <= 0 {
+					response.Messages = append(response.Messages, fmt.Sprintf("%s doesn't have required label '%s'", key, label))
+				}
+			}
+		}
+	}
+
+	response.Allowed = len(response.Messages) <= 0
+	return response, nil
+}
+
+// matchesKind returns true if kind is in a given list of kinds or the list is empty
+func matchesKind(kinds []string, kind string) bool {
+	if len(kinds) <= 0 {
+		return true
+	}
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// getLabels returns labels of a given object and whether object kind supports labels at all
+func getLabels(obj lang.Base) (map[string]string, bool) {
+	switch o := obj.(type) {
+	case *lang.Claim:
+		return o.Labels, true
+	case *lang.Cluster:
+		return o.Labels, true
+	case *lang.Bundle:
+		return o.Labels, true
+	}
+	return nil, false
+}
@@ -0,0 +1,40 @@
+package admission
+
+import (
+	"fmt"
+)
+
+// Config represents config of the admission. Builtin rules, if set, run first, followed by external webhooks in the
+// order they are listed
+type Config struct {
+	Builtin  *BuiltinRulesConfig `validate:"omitempty"`
+	Webhooks []*WebhookConfig    `validate:"dive"`
+}
+
+// NewChainFromConfig creates a Chain of webhooks from a given config
+func NewChainFromConfig(cfg Config) (*Chain, error) {
+	chain := NewChain()
+	if cfg.Builtin != nil {
+		rules, err := NewBuiltinRules(cfg.Builtin)
+		if err != nil {
+			return nil, fmt.Errorf("invalid builtin admission rules: %s", err)
+		}
+		chain.Add(rules, false)
+	}
+
+	names := make(map[string]bool)
+	for _, webhookCfg := range cfg.Webhooks {
+		if names[webhookCfg.Name] || webhookCfg.Name == BuiltinRulesName {
+			return nil, fmt.Errorf("duplicate admission webhook name: %s", webhookCfg.Name)
+		}
+		names[webhookCfg.Name] = true
+
+		webhook, err := NewHTTPWebhook(webhookCfg)
+		if err != nil {
+			return nil, err
+		}
+		chain.Add(webhook, webhookCfg.FailOpen)
+	}
+
+	return chain, nil
+}
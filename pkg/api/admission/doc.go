@@ -0,0 +1,5 @@
+// Package admission implements admission webhooks for policy changes. Every batch of objects being added to or
+// deleted from the policy goes through the chain of webhooks (external HTTP endpoints configured in server config, as
+// well as builtin rules), which may allow it, deny it with messages or mutate the objects being added. It allows to
+// enforce organization-specific rules (e.g. naming conventions and mandatory labels) without changing Aptomi itself.
+package admission
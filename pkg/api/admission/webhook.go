@@ -0,0 +1,261 @@
+package admission
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+// defaultWebhookTimeout is used when webhook timeout isn't set in config
+const defaultWebhookTimeout = 10 * time.Second
+
+// maxWebhookResponseSize is the max size of the webhook response body
+const maxWebhookResponseSize = 16 * 1024 * 1024
+
+// WebhookConfig represents config of an external admission webhook
+type WebhookConfig struct {
+	// Name identifies webhook in the event log and results
+	Name string `validate:"required"`
+
+	// URL is where requests get sent to using HTTP POST
+	URL string `validate:"required,url"`
+
+	// Timeout of a single webhook call. If not set, 10s is used
+	Timeout time.Duration `validate:"-"`
+
+	// FailOpen allows policy changes when webhook fails to make a decision (e.g. it's not reachable or times out).
+	// By default, such policy changes get denied
+	FailOpen bool `validate:"-"`
+
+	TLS  WebhookTLS  `validate:"-"`
+	Auth WebhookAuth `validate:"-"`
+}
+
+// WebhookTLS represents TLS options for calling webhook over HTTPS
+type WebhookTLS struct {
+	// CAFile is a file with PEM encoded CA certificates to verify webhook server certificate with. If not set, system
+	// CA certificates are used
+	CAFile string
+
+	// CertFile and KeyFile are files with PEM encoded client certificate and key, if webhook requires client
+	// certificate authentication
+	CertFile string
+	KeyFile  string
+
+	// InsecureSkipVerify disables verification of webhook server certificate. It should only be used for testing
+	InsecureSkipVerify bool
+}
+
+// WebhookAuth represents authentication options for calling webhook. Bearer token takes precedence over basic auth
+type WebhookAuth struct {
+	// BearerToken is sent in the Authorization header
+	BearerToken string
+
+	// BearerTokenFile is a file with the bearer token, which gets re-read on every call (so it could be rotated)
+	BearerTokenFile string
+
+	// Username and Password are used for basic auth
+	Username string
+	Password string
+}
+
+// webhookUser is a user making policy change, as it's sent to webhooks (without any credentials)
+type webhookUser struct {
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	DomainAdmin bool              `json:"domainAdmin,omitempty"`
+}
+
+// webhookRequest is a body of the request sent to webhook
+type webhookRequest struct {
+	Operation Operation         `json:"operation"`
+	User      *webhookUser      `json:"user"`
+	Objects   []json.RawMessage `json:"objects"`
+}
+
+// webhookResponse is a body of the response expected from webhook
+type webhookResponse struct {
+	Allowed  bool              `json:"allowed"`
+	Messages []string          `json:"messages,omitempty"`
+	Objects  []json.RawMessage `json:"objects,omitempty"`
+}
+
+// HTTPWebhook is a Webhook, which sends requests to an external HTTP endpoint as JSON:
+//
+//	{"operation": "update", "user": {"name": "alice", "labels": {...}}, "objects": [...]}
+//
+// and expects JSON response in the form of:
+//
+//	{"allowed": true, "messages": ["..."], "objects": [...]}
+//
+// where objects are only needed if webhook mutates them
+type HTTPWebhook struct {
+	cfg    *WebhookConfig
+	client *http.Client
+	codec  codec.Interface
+}
+
+// NewHTTPWebhook creates a new HTTPWebhook from a given config
+func NewHTTPWebhook(cfg *WebhookConfig) (*HTTPWebhook, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.TLS.InsecureSkipVerify} // nolint: gas
+	if len(cfg.TLS.CAFile) > 0 {
+		data, err := ioutil.ReadFile(cfg.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't read CA file for webhook %s: %s", cfg.Name, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in CA file %s for webhook %s", cfg.TLS.CAFile, cfg.Name)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(cfg.TLS.CertFile) > 0 || len(cfg.TLS.KeyFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't load client certificate for webhook %s: %s", cfg.Name, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+
+	return &HTTPWebhook{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+		codec: codec.NewJSONCodec(runtime.NewTypes().Append(lang.PolicyTypes...)),
+	}, nil
+}
+
+// GetName returns name of the webhook
+func (webhook *HTTPWebhook) GetName() string {
+	return webhook.cfg.Name
+}
+
+// Admit sends a given request to the webhook endpoint and returns its decision
+func (webhook *HTTPWebhook) Admit(ctx context.Context, request *Request) (*Response, error) {
+	body, err := webhook.encodeRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	httpRequest, err := http.NewRequest("POST", webhook.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("can't create request: %s", err)
+	}
+	httpRequest = httpRequest.WithContext(ctx)
+	httpRequest.Header.Set("Content-Type", codec.JSON)
+	httpRequest.Header.Set("Accept", codec.JSON)
+	err = webhook.setAuth(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	httpResponse, err := webhook.client.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResponse.Body.Close() // nolint: errcheck
+
+	data, err := ioutil.ReadAll(io.LimitReader(httpResponse.Body, maxWebhookResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("can't read response: %s", err)
+	}
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected response status %d: %s", httpResponse.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	return webhook.decodeResponse(data)
+}
+
+func (webhook *HTTPWebhook) setAuth(request *http.Request) error {
+	token := webhook.cfg.Auth.BearerToken
+	if len(webhook.cfg.Auth.BearerTokenFile) > 0 {
+		data, err := ioutil.ReadFile(webhook.cfg.Auth.BearerTokenFile)
+		if err != nil {
+			return fmt.Errorf("can't read bearer token file: %s", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	if len(token) > 0 {
+		request.Header.Set("Authorization", "Bearer "+token)
+	} else if len(webhook.cfg.Auth.Username) > 0 {
+		request.SetBasicAuth(webhook.cfg.Auth.Username, webhook.cfg.Auth.Password)
+	}
+
+	return nil
+}
+
+func (webhook *HTTPWebhook) encodeRequest(request *Request) ([]byte, error) {
+	body := &webhookRequest{
+		Operation: request.Operation,
+		Objects:   make([]json.RawMessage, 0, len(request.Objects)),
+	}
+	if request.User != nil {
+		body.User = &webhookUser{
+			Name:        request.User.Name,
+			Labels:      request.User.Labels,
+			DomainAdmin: request.User.DomainAdmin,
+		}
+	}
+	for _, obj := range request.Objects {
+		data, err := webhook.codec.EncodeOne(obj)
+		if err != nil {
+			return nil, fmt.Errorf("error while encoding object %s: %s", runtime.KeyForStorable(obj), err)
+		}
+		body.Objects = append(body.Objects, data)
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error while encoding request: %s", err)
+	}
+	return data, nil
+}
+
+func (webhook *HTTPWebhook) decodeResponse(data []byte) (*Response, error) {
+	body := &webhookResponse{}
+	err := json.Unmarshal(data, body)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode response: %s", err)
+	}
+
+	response := &Response{
+		Allowed:  body.Allowed,
+		Messages: body.Messages,
+	}
+	if body.Objects != nil {
+		response.Objects = make([]lang.Base, 0, len(body.Objects))
+		for _, objData := range body.Objects {
+			obj, decodeErr := webhook.codec.DecodeOne(objData)
+			if decodeErr != nil {
+				return nil, fmt.Errorf("can't decode object returned in response: %s", decodeErr)
+			}
+			langObj, ok := obj.(lang.Base)
+			if !ok {
+				return nil, fmt.Errorf("non-policy object of kind %s returned in response", obj.GetKind())
+			}
+			response.Objects = append(response.Objects, langObj)
+		}
+	}
+
+	return response, nil
+}
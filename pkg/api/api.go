@@ -1,90 +1,323 @@
 package api
 
 import (
+	"context"
+	"net/http"
 	"sync"
+	"time"
 
+	"github.com/Aptomi/aptomi/pkg/api/admission"
 	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/engine/apply/chaos"
+	"github.com/Aptomi/aptomi/pkg/engine/enforce"
+	"github.com/Aptomi/aptomi/pkg/engine/pipeline"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
 	"github.com/Aptomi/aptomi/pkg/external"
 	"github.com/Aptomi/aptomi/pkg/plugin"
 	"github.com/Aptomi/aptomi/pkg/runtime"
 	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
 	"github.com/julienschmidt/httprouter"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 )
 
+// Clock provides current time to the API server
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is a Clock which returns the current local time
+type SystemClock struct{}
+
+// Now returns the current local time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Enforcer enforces desired state. It returns true if some of the actions were successfully applied, meaning that
+// enforcement should be triggered again right away. Once a given context is cancelled (e.g. leadership is lost), no
+// new actions should be started
+type Enforcer interface {
+	Enforce(ctx context.Context) (bool, error)
+}
+
+// Options defines all dependencies of the API server. It allows to embed Aptomi into another binary, providing
+// its own registry, external data, plugins, authentication and so on
+type Options struct {
+	// Registry is where policy, revisions and actual state are stored
+	Registry registry.Interface
+
+	// ExternalData provides users and secrets
+	ExternalData *external.Data
+
+	// PluginRegistryFactory creates plugin registries for talking to clusters
+	PluginRegistryFactory plugin.RegistryFactory
+
+	// EventHooks get attached to all event logs created by the API server (e.g. to mirror them to the console)
+	EventHooks []logrus.Hook
+
+	// AuthProvider issues and verifies user tokens
+	AuthProvider AuthProvider
+
+	// Clock provides current time. If not set, SystemClock is used
+	Clock Clock
+
+	// Enforcer enforces desired state in Run(). If not set, DesiredStateEnforcer is created from the dependencies above
+	Enforcer Enforcer
+
+	// EnforcerInterval is how often desired state enforcement runs, if not triggered by policy changes. If not set, 60s is used
+	EnforcerInterval time.Duration
+
+	// EnforcerMaxConcurrentActions is the max number of actions applied in parallel by the default Enforcer. If not set, 30 is used
+	EnforcerMaxConcurrentActions int
+
+	// ReconciliationThreshold is the max number of actual state corrections after restoring registry from a backup,
+	// which don't require operator acknowledgment. If not set, 10 is used
+	ReconciliationThreshold int
+
+	// FailureInjector allows domain admins to inject failures into applied actions via API. It should only be set when
+	// failure injection is explicitly enabled, otherwise failure injection endpoints return an error
+	FailureInjector *chaos.Injector
+
+	// Pipeline resolves policy changes in the background. If not set, all policy changes get resolved synchronously
+	// within API requests
+	Pipeline *pipeline.Pipeline
+
+	// ResolutionCacheSize is the max number of desired states of policy generations kept in memory, so policy
+	// generations which have been resolved before don't get resolved again (e.g. when comparing generations or rolling
+	// back). If not set, 10 is used. Negative value disables caching
+	ResolutionCacheSize int
+
+	// PipelineSyncMaxObjects is the max number of objects in a policy change, which still gets resolved synchronously
+	// when Pipeline is set. Larger policy changes get queued. If not set, 20 is used
+	PipelineSyncMaxObjects int
+
+	// PolicyJobRetention is how long jobs of asynchronous policy changes are kept after they finish, so their outcome
+	// could be polled. If not set, 24h is used
+	PolicyJobRetention time.Duration
+
+	// GCRetention defines how many last generations of versioned objects garbage collection triggered via API keeps. If
+	// not set, registry defaults are used
+	GCRetention *registry.GCRetention
+
+	// BackupCodec is the codec used to encode objects in registry backups. If not set, YAML codec is used
+	BackupCodec store.Codec
+
+	// Admission is a chain of webhooks, which allow, deny or mutate every policy change before it gets validated. If
+	// not set, all policy changes get admitted
+	Admission *admission.Chain
+
+	// Election elects a single leader among API servers sharing the same registry, so only the leader does desired
+	// state enforcement in Run(), while others keep serving API requests. If not set, enforcement always runs
+	Election store.Election
+
+	// Metrics are served at /metrics along with metrics registered globally (e.g. by engine packages). If not set,
+	// only globally registered metrics are served
+	Metrics prometheus.Gatherer
+}
+
+// Server is an embeddable Aptomi API server. It serves REST API as http.Handler, while Run() does continuous
+// desired state enforcement
+type Server struct {
+	api      *coreAPI
+	router   *httprouter.Router
+	enforcer Enforcer
+	interval time.Duration
+}
+
 type coreAPI struct {
 	contentType                  *codec.ContentTypeHandler
 	registry                     registry.Interface
 	externalData                 *external.Data
 	pluginRegistryFactory        plugin.RegistryFactory
-	secret                       string
-	logLevel                     logrus.Level
+	eventHooks                   []logrus.Hook
+	authProvider                 AuthProvider
+	clock                        Clock
+	reconciliationThreshold      int
+	failureInjector              *chaos.Injector
+	pipeline                     *pipeline.Pipeline
+	pipelineSyncMaxObjects       int
+	policyJobRetention           time.Duration
+	resolutionCache              *resolve.Cache
+	admission                    *admission.Chain
+	gcRetention                  *registry.GCRetention
+	backupCodec                  store.Codec
+	election                     store.Election
+	metrics                      prometheus.Gatherer
 	runDesiredStateEnforcement   chan bool
 	policyAndRevisionUpdateMutex sync.Mutex
+	apiDocsOnce                  sync.Once
+	apiDocs                      *APIDocs
 }
 
-// Serve initializes everything needed by REST API and registers all API endpoints in the provided http router
-func Serve(router *httprouter.Router, registry registry.Interface, externalData *external.Data, pluginRegistryFactory plugin.RegistryFactory, secret string, logLevel logrus.Level, runDesiredStateEnforcement chan bool) {
-	contentTypeHandler := codec.NewContentTypeHandler(runtime.NewTypes().Append(Types...))
-	api := &coreAPI{
-		contentType:                contentTypeHandler,
-		registry:                   registry,
-		externalData:               externalData,
-		pluginRegistryFactory:      pluginRegistryFactory,
-		secret:                     secret,
-		logLevel:                   logLevel,
-		runDesiredStateEnforcement: runDesiredStateEnforcement,
-	}
-	api.serve(router)
+// NewServer creates a new API server from the given options and registers all API endpoints
+func NewServer(opts Options) *Server {
+	if opts.Clock == nil {
+		opts.Clock = SystemClock{}
+	}
+	if opts.EnforcerMaxConcurrentActions <= 0 {
+		opts.EnforcerMaxConcurrentActions = 30
+	}
+	if opts.Enforcer == nil {
+		opts.Enforcer = enforce.NewDesiredStateEnforcer(opts.Registry, opts.ExternalData, opts.PluginRegistryFactory, opts.EnforcerMaxConcurrentActions, opts.EventHooks...).SetFailureInjector(opts.FailureInjector).SetClock(opts.Clock)
+	}
+	if opts.EnforcerInterval <= 0 {
+		opts.EnforcerInterval = 60 * time.Second
+	}
+	if opts.ReconciliationThreshold <= 0 {
+		opts.ReconciliationThreshold = 10
+	}
+	if opts.PipelineSyncMaxObjects <= 0 {
+		opts.PipelineSyncMaxObjects = 20
+	}
+	if opts.PolicyJobRetention <= 0 {
+		opts.PolicyJobRetention = 24 * time.Hour
+	}
+	if opts.ResolutionCacheSize == 0 {
+		opts.ResolutionCacheSize = 10
+	}
+	if opts.Admission == nil {
+		opts.Admission = admission.NewChain()
+	}
+	if opts.BackupCodec == nil {
+		opts.BackupCodec = store.NewYAMLCodec()
+	}
+	metrics := prometheus.Gatherer(prometheus.DefaultGatherer)
+	if opts.Metrics != nil {
+		metrics = prometheus.Gatherers{opts.Metrics, prometheus.DefaultGatherer}
+	}
+
+	server := &Server{
+		api: &coreAPI{
+			contentType:                codec.NewContentTypeHandler(runtime.NewTypes().Append(Types...)),
+			registry:                   opts.Registry,
+			externalData:               opts.ExternalData,
+			pluginRegistryFactory:      opts.PluginRegistryFactory,
+			eventHooks:                 opts.EventHooks,
+			authProvider:               opts.AuthProvider,
+			clock:                      opts.Clock,
+			reconciliationThreshold:    opts.ReconciliationThreshold,
+			failureInjector:            opts.FailureInjector,
+			pipeline:                   opts.Pipeline,
+			pipelineSyncMaxObjects:     opts.PipelineSyncMaxObjects,
+			policyJobRetention:         opts.PolicyJobRetention,
+			resolutionCache:            resolve.NewCache(opts.ResolutionCacheSize),
+			admission:                  opts.Admission,
+			gcRetention:                opts.GCRetention,
+			backupCodec:                opts.BackupCodec,
+			election:                   opts.Election,
+			metrics:                    metrics,
+			runDesiredStateEnforcement: make(chan bool, 2048),
+		},
+		router:   httprouter.New(),
+		enforcer: opts.Enforcer,
+		interval: opts.EnforcerInterval,
+	}
+	server.api.serve(server.router)
+
+	// trigger enforcement right away once policy change has been resolved in the background
+	if opts.Pipeline != nil {
+		opts.Pipeline.SetResolvedHook(server.api.triggerEnforcement)
+	}
+
+	return server
 }
 
-func (api *coreAPI) serve(router *httprouter.Router) {
-	auth := api.auth
+// ServeHTTP serves API requests
+func (server *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	server.router.ServeHTTP(writer, request)
+}
 
-	// todo consider moving to a separate port for security (should be nothing sensetive?)
-	// prometheus metrics handler
-	router.Handler("GET", "/metrics", promhttp.Handler())
+// Router returns the router with all API endpoints registered, so additional endpoints can be served alongside
+func (server *Server) Router() *httprouter.Router {
+	return server.router
+}
 
-	// authenticate user
-	router.POST("/api/v1/user/login", api.handleLogin)
+// FailInterruptedPolicyApplyJobs fails policy apply jobs, which have been left unfinished by the server stopped in the
+// middle of async policy change, and rolls back policy generations stored by them. It should be called on startup,
+// before API requests get served, so such jobs don't hang forever and the policy doesn't keep unresolved objects
+func (server *Server) FailInterruptedPolicyApplyJobs() error {
+	return server.api.failInterruptedPolicyApplyJobs()
+}
 
-	// get all users and their roles
-	router.GET("/api/v1/user/roles", auth(api.handleUserRoles))
+// Run does continuous desired state enforcement until the context is cancelled. Enforcement runs periodically, as
+// well as right away after every policy change. If election is set, enforcement only runs while this server is the
+// leader. Revision pipeline, if set, runs alongside
+func (server *Server) Run(ctx context.Context) error {
+	if server.api.pipeline != nil {
+		go func() {
+			err := server.api.pipeline.Run(ctx)
+			if err != nil && ctx.Err() == nil {
+				logrus.Errorf("revision pipeline stopped: %s", err)
+			}
+		}()
+	}
 
-	// retrieve policy (latest + by a given generation)
-	router.GET("/api/v1/policy", auth(api.handlePolicyGet))
-	router.GET("/api/v1/policy/gen/:gen", auth(api.handlePolicyGet))
+	if server.api.election == nil {
+		return server.enforce(ctx)
+	}
 
-	// retrieve specific object from the policy
-	router.GET("/api/v1/policy/gen/:gen/object/:ns/:kind/:name", auth(api.handlePolicyObjectGet))
+	return server.enforceWhileLeader(ctx)
+}
 
-	// update policy
-	router.POST("/api/v1/policy", auth(api.handlePolicyUpdate))
-	router.POST("/api/v1/policy/noop/:noop/loglevel/:loglevel", auth(api.handlePolicyUpdate))
-	router.DELETE("/api/v1/policy", auth(api.handlePolicyDelete))
-	router.DELETE("/api/v1/policy/noop/:noop/loglevel/:loglevel", auth(api.handlePolicyDelete))
+// enforce does continuous desired state enforcement until the context is cancelled
+func (server *Server) enforce(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
-	// policy & object diagrams
-	router.GET("/api/v1/policy/diagram/object/:ns/:kind/:name", auth(api.handleObjectDiagram))
-	router.GET("/api/v1/policy/diagram/mode/:mode", auth(api.handlePolicyDiagram))
-	router.GET("/api/v1/policy/diagram/mode/:mode/gen/:gen", auth(api.handlePolicyDiagram))
-	router.GET("/api/v1/policy/diagram/compare/mode/:mode/gen/:gen/genBase/:genBase", auth(api.handlePolicyDiagramCompare))
+		applied, err := server.enforcer.Enforce(ctx)
+		if err != nil {
+			logrus.Errorf("error while enforcing desired state: %s", err)
+		}
 
-	// retrieve claim along with its status
-	router.GET("/api/v1/policy/claim/status/:queryFlag/:idList", auth(api.handleClaimStatusGet))
-	router.GET("/api/v1/policy/claim/resources/:ns/:name", auth(api.handleClaimResourcesGet))
+		// let's try again immediately until no actions were successfully applied
+		if applied {
+			continue
+		}
 
-	// retrieve revision (latest + by a given generation)
-	router.GET("/api/v1/revision", auth(api.handleRevisionGet))
-	router.GET("/api/v1/revision/gen/:gen", auth(api.handleRevisionGet))
+		// sleep for a specified time or wait until policy has changed, whichever comes first
+		timer := time.NewTimer(server.interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-server.api.runDesiredStateEnforcement:
+			break // nolint: megacheck
+		case <-timer.C:
+			break // nolint: megacheck
+		}
+		timer.Stop()
+	}
+}
 
-	// retrieve revision(s) (for a given policy)
-	router.GET("/api/v1/revisions/policy/:policy", auth(api.handleRevisionsGetByPolicy))
+// triggerEnforcement signals that enforcement should run right away. It never blocks, as there may be no one
+// consuming signals (e.g. when this server isn't the leader) and a single pending signal is enough anyway
+func (api *coreAPI) triggerEnforcement() {
+	select {
+	case api.runDesiredStateEnforcement <- true:
+	default:
+	}
+}
 
-	router.POST("/api/v1/state/enforce/noop/:noop", auth(api.handleStateEnforce))
+// newEventLog creates a new event log with all event hooks attached to it
+func (api *coreAPI) newEventLog(level logrus.Level, scope string) *event.Log {
+	eventLog := event.NewLog(level, scope)
+	for _, hook := range api.eventHooks {
+		eventLog.AddHook(hook)
+	}
+	return eventLog
+}
 
-	// return aptomi version
-	router.GET("/version", api.handleVersion)
-	router.GET("/api/v1/version", api.handleVersion)
+func (api *coreAPI) serve(router *httprouter.Router) {
+	for _, r := range api.routes() {
+		handle := r.handle
+		if r.auth {
+			handle = api.auth(handle)
+		}
+		router.Handle(r.method, r.path, traced(r.method, r.path, handle))
+	}
 }
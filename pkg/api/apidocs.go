@@ -0,0 +1,137 @@
+package api
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/julienschmidt/httprouter"
+)
+
+// TypeAPIDocs is an informational data structure with Kind and Constructor for APIDocs
+var TypeAPIDocs = &runtime.TypeInfo{
+	Kind:        "api-docs",
+	Constructor: func() runtime.Object { return &APIDocs{} },
+}
+
+// APIDocs is a machine-readable description of all API endpoints
+type APIDocs struct {
+	runtime.TypeKind `yaml:",inline"`
+	Routes           []*RouteDoc
+}
+
+// RouteDoc describes a single API endpoint
+type RouteDoc struct {
+	Method       string
+	Path         string
+	PathParams   []string
+	Description  string
+	AuthRequired bool
+	Accepts      []string
+	Returns      string
+	Examples     []*RouteExample
+}
+
+// RouteExample is an example request to the API endpoint along with the response returned by it
+type RouteExample struct {
+	Title          string
+	Request        string
+	RequestBody    string
+	ResponseStatus int
+	ResponseBody   string
+}
+
+// routeID returns ID of the route, which is unique across all routes
+func routeID(method string, path string) string {
+	return method + " " + path
+}
+
+// pathParams returns names of the parameters in a given route path (":param" and "*param" segments)
+func pathParams(path string) []string {
+	result := []string{}
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			result = append(result, segment[1:])
+		}
+	}
+	return result
+}
+
+// getAPIDocs returns description of all API endpoints. Examples are generated only once, on the first call
+func (api *coreAPI) getAPIDocs() *APIDocs {
+	api.apiDocsOnce.Do(func() {
+		examples, err := generateExamples()
+		if err != nil {
+			panic(fmt.Sprintf("error while generating API examples: %s", err))
+		}
+
+		docs := &APIDocs{TypeKind: TypeAPIDocs.GetTypeKind()}
+		for _, r := range api.routes() {
+			accepts := make([]string, 0, len(r.accepts))
+			for _, info := range r.accepts {
+				accepts = append(accepts, info.Kind)
+			}
+			docs.Routes = append(docs.Routes, &RouteDoc{
+				Method:       r.method,
+				Path:         r.path,
+				PathParams:   pathParams(r.path),
+				Description:  r.description,
+				AuthRequired: r.auth,
+				Accepts:      accepts,
+				Returns:      r.returns,
+				Examples:     examples[routeID(r.method, r.path)],
+			})
+		}
+		api.apiDocs = docs
+	})
+	return api.apiDocs
+}
+
+func (api *coreAPI) handleAPIDocs(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	data, err := api.contentType.GetCodecByContentType(codec.JSON).EncodeOne(api.getAPIDocs())
+	if err != nil {
+		panic(fmt.Sprintf("error while encoding API docs: %s", err))
+	}
+
+	writer.Header().Set("Content-Type", codec.JSON)
+	writer.WriteHeader(http.StatusOK)
+	_, err = writer.Write(data)
+	if err != nil {
+		panic(fmt.Sprintf("error while writing API docs: %s", err))
+	}
+}
+
+var apiDocsIndexTemplate = template.Must(template.New("apidocs").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Aptomi API</title></head>
+<body>
+<h1>Aptomi API</h1>
+<p>Machine-readable version is available at <a href="/apidocs">/apidocs</a></p>
+<table border="1" cellpadding="4">
+<tr><th>Method</th><th>Path</th><th>Auth</th><th>Description</th><th>Accepts</th><th>Returns</th></tr>
+{{range .Routes}}<tr><td>{{.Method}}</td><td><a href="#{{.Method}}{{.Path}}">{{.Path}}</a></td><td>{{if .AuthRequired}}yes{{else}}no{{end}}</td><td>{{.Description}}</td><td>{{range .Accepts}}{{.}} {{end}}</td><td>{{.Returns}}</td></tr>
+{{end}}</table>
+{{range .Routes}}{{$route := .}}{{range .Examples}}
+<h2 id="{{$route.Method}}{{$route.Path}}">{{.Title}}</h2>
+<h3>Request</h3>
+<pre>{{.Request}}
+
+{{.RequestBody}}</pre>
+<h3>Response ({{.ResponseStatus}})</h3>
+<pre>{{.ResponseBody}}</pre>
+{{end}}{{end}}
+</body>
+</html>
+`))
+
+func (api *coreAPI) handleAPIDocsIndex(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	writer.WriteHeader(http.StatusOK)
+	err := apiDocsIndexTemplate.Execute(writer, api.getAPIDocs())
+	if err != nil {
+		panic(fmt.Sprintf("error while rendering API docs: %s", err))
+	}
+}
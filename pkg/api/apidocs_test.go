@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIDocsAllRoutesDescribed(t *testing.T) {
+	ids := make(map[string]bool)
+	for _, r := range (&coreAPI{}).routes() {
+		id := routeID(r.method, r.path)
+		assert.False(t, ids[id], "Route %s is registered more than once", id)
+		ids[id] = true
+
+		assert.NotEmpty(t, r.description, "Route %s should have a description", id)
+		assert.NotEmpty(t, r.returns, "Route %s should have a response type", id)
+		assert.NotNil(t, r.handle, "Route %s should have a handler", id)
+	}
+
+	assert.Equal(t, []string{"gen", "ns", "kind", "name"}, pathParams("/api/v1/policy/gen/:gen/object/:ns/:kind/:name"))
+}
+
+// timeRegex matches event timestamps, which are different every time request is executed
+var timeRegex = regexp.MustCompile(`(?m)^\s*(- )?time: .*$`)
+
+func TestAPIDocsExamplesRoundTrip(t *testing.T) {
+	policy := newExamplePolicy()
+	server, err := newExampleServer(policy)
+	if !assert.NoError(t, err, "Sandbox API server should be created") {
+		t.FailNow()
+	}
+
+	// retrieve docs via API
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest("GET", "/apidocs", nil))
+	if !assert.Equal(t, http.StatusOK, recorder.Code, "API docs should be served") {
+		t.FailNow()
+	}
+	types := runtime.NewTypes().Append(Types...)
+	obj, err := codec.NewJSONCodec(types).DecodeOne(recorder.Body.Bytes())
+	if !assert.NoError(t, err, "API docs should be decoded") {
+		t.FailNow()
+	}
+	docs := obj.(*APIDocs)
+
+	routes := make(map[string]*RouteDoc)
+	for _, r := range docs.Routes {
+		routes[routeID(r.Method, r.Path)] = r
+	}
+
+	// replay all examples against a fresh sandbox and make sure real handlers return the same responses
+	cod := codec.NewYAMLCodec(types)
+	for _, req := range policy.requests() {
+		id := routeID(req.method, req.path)
+		r := routes[id]
+		if !assert.NotNil(t, r, "Route %s should be documented", id) {
+			continue
+		}
+
+		var example *RouteExample
+		for _, e := range r.Examples {
+			if e.Title == req.title {
+				example = e
+			}
+		}
+		if !assert.NotNil(t, example, "Example '%s' should be present for route %s", req.title, id) {
+			continue
+		}
+
+		// request body should consist of the objects accepted by the route
+		if len(example.RequestBody) > 0 {
+			objects, decodeErr := cod.DecodeOneOrMany([]byte(example.RequestBody))
+			assert.NoError(t, decodeErr, "Request body of example '%s' should be decoded", req.title)
+			for _, o := range objects {
+				assert.Contains(t, r.Accepts, o.GetKind(), "Route %s should accept objects of kind %s", id, o.GetKind())
+			}
+		}
+
+		parts := strings.SplitN(example.Request, " ", 2)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(parts[0], parts[1], strings.NewReader(example.RequestBody)))
+		assert.Equal(t, example.ResponseStatus, recorder.Code, "Example '%s' should return the same status", req.title)
+		assert.Equal(t, timeRegex.ReplaceAllString(example.ResponseBody, ""), timeRegex.ReplaceAllString(recorder.Body.String(), ""), "Example '%s' should return the same response", req.title)
+
+		// response should be of the documented type
+		response, decodeErr := cod.DecodeOne(recorder.Body.Bytes())
+		if assert.NoError(t, decodeErr, "Response of example '%s' should be decoded", req.title) {
+			assert.Equal(t, r.Returns, response.GetKind(), "Example '%s' should return documented type", req.title)
+		}
+
+		if req.enforce {
+			_, err = server.enforcer.Enforce(context.Background())
+			assert.NoError(t, err, "Desired state should be enforced after example '%s'", req.title)
+		}
+	}
+}
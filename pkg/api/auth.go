@@ -48,11 +48,40 @@ func (api *coreAPI) handleLogin(writer http.ResponseWriter, request *http.Reques
 	if err != nil {
 		serverErr := NewServerError(fmt.Sprintf("Authentication error: %s", err))
 		api.contentType.WriteOne(writer, request, serverErr)
-	} else {
-		api.contentType.WriteOne(writer, request, &AuthSuccess{
-			TypeKind: TypeAuthSuccess.GetTypeKind(),
-			Token:    api.newToken(user),
-		})
+		return
+	}
+
+	token, err := api.authProvider.NewToken(user)
+	if err != nil {
+		panic(fmt.Errorf("error while issuing token: %s", err))
+	}
+
+	api.contentType.WriteOne(writer, request, &AuthSuccess{
+		TypeKind: TypeAuthSuccess.GetTypeKind(),
+		Token:    token,
+	})
+}
+
+// AuthProvider issues tokens for authenticated users and verifies tokens attached to API requests
+type AuthProvider interface {
+	// NewToken issues a new token for a given user, after user has been successfully authenticated
+	NewToken(user *lang.User) (string, error)
+
+	// VerifyToken verifies the token attached to a given request and returns the name of the user it was issued for
+	VerifyToken(request *http.Request) (string, error)
+}
+
+// jwtAuthProvider is an AuthProvider which issues JWT tokens signed with a shared secret
+type jwtAuthProvider struct {
+	secret string
+	clock  Clock
+}
+
+// NewJWTAuthProvider creates an AuthProvider which issues JWT tokens signed with a given secret
+func NewJWTAuthProvider(secret string, clock Clock) AuthProvider {
+	return &jwtAuthProvider{
+		secret: secret,
+		clock:  clock,
 	}
 }
 
@@ -71,22 +100,45 @@ func (claims Claims) Valid() error {
 	return claims.StandardClaims.Valid()
 }
 
-func (api *coreAPI) newToken(user *lang.User) string {
+// NewToken issues a new JWT token for a given user
+func (provider *jwtAuthProvider) NewToken(user *lang.User) (string, error) {
+	now := provider.clock.Now()
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
 		Name: user.Name,
 		StandardClaims: jwt.StandardClaims{
-			IssuedAt:  time.Now().Unix(),
-			ExpiresAt: time.Now().Add(30 * 24 * time.Hour).Unix(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(30 * 24 * time.Hour).Unix(),
 		},
 	})
 
 	// Sign and get the complete encoded token as a string using the secret
-	tokenString, err := token.SignedString([]byte(api.secret))
+	tokenString, err := token.SignedString([]byte(provider.secret))
 	if err != nil {
-		panic(fmt.Errorf("error while signing token: %s", err))
+		return "", fmt.Errorf("error while signing token: %s", err)
 	}
 
-	return tokenString
+	return tokenString, nil
+}
+
+// VerifyToken verifies JWT token attached to a given request and returns the name of the user
+func (provider *jwtAuthProvider) VerifyToken(request *http.Request) (string, error) {
+	token, err := jwtreq.ParseFromRequestWithClaims(request, jwtreq.AuthorizationHeaderExtractor, &Claims{},
+		func(token *jwt.Token) (interface{}, error) {
+			return []byte(provider.secret), nil
+		})
+	if err != nil {
+		return "", err
+	}
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return "", fmt.Errorf("unexpected token signing method: %s", token.Header["alg"])
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok {
+		return "", fmt.Errorf("unexpected token claims, can't be casted to *Claims: %s", token.Claims)
+	}
+
+	return claims.Name, nil
 }
 
 func (api *coreAPI) auth(handle httprouter.Handle) httprouter.Handle {
@@ -111,25 +163,14 @@ const (
 )
 
 func (api *coreAPI) checkToken(request *http.Request) error {
-	token, err := jwtreq.ParseFromRequestWithClaims(request, jwtreq.AuthorizationHeaderExtractor, &Claims{},
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(api.secret), nil
-		})
+	name, err := api.authProvider.VerifyToken(request)
 	if err != nil {
 		return err
 	}
-	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-		return fmt.Errorf("unexpected token signing method: %s", token.Header["alg"])
-	}
-
-	claims, ok := token.Claims.(*Claims)
-	if !ok {
-		return fmt.Errorf("unexpected token claims, can't be casted to *Claims: %s", token.Claims)
-	}
 
-	user := api.externalData.UserLoader.LoadUserByName(claims.Name)
+	user := api.externalData.UserLoader.LoadUserByName(name)
 	if user == nil {
-		return fmt.Errorf("token refers to non-existing user: %s", claims.Name)
+		return fmt.Errorf("token refers to non-existing user: %s", name)
 	}
 
 	// registry user into the request
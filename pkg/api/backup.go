@@ -0,0 +1,77 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+// TypeRestoreResult is an informational data structure with Kind and Constructor for RestoreResult
+var TypeRestoreResult = &runtime.TypeInfo{
+	Kind:        "restore-result",
+	Constructor: func() runtime.Object { return &RestoreResult{} },
+}
+
+// RestoreResult represents result of restoring registry from a backup
+type RestoreResult struct {
+	runtime.TypeKind `yaml:",inline"`
+
+	// Objects is the number of restored objects by kind
+	Objects map[runtime.Kind]int
+
+	// Total is the total number of restored objects
+	Total int
+}
+
+// handleBackup streams all objects from the registry as a backup, which could be restored on a fresh server
+func (api *coreAPI) handleBackup(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	api.checkDomainAdmin(request, "back up registry")
+
+	writer.Header().Set("Content-Type", "application/octet-stream")
+	writer.Header().Set("Content-Disposition", "attachment; filename=aptomi.backup")
+	writer.WriteHeader(http.StatusOK)
+
+	result, err := api.registry.Export(writer, api.backupCodec)
+	if err != nil {
+		// response status has been sent already, so the stream just gets terminated and restoring it will fail
+		log.Errorf("Error while backing up registry: %s", err)
+		return
+	}
+	log.Infof("Registry has been backed up by %s: %d objects", api.getUserRequired(request).Name, result.Total())
+}
+
+// handleRestore restores registry from a backup passed in the request body and marks it as restored, so actual state
+// gets reconciled with clusters before the next enforcement. Restoring into non-empty registry requires ?force=true
+func (api *coreAPI) handleRestore(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	api.checkDomainAdmin(request, "restore registry")
+
+	force := request.URL.Query().Get("force") == "true"
+	result, err := api.registry.Import(request.Body, api.backupCodec, force)
+	if store.IsNotEmpty(err) {
+		api.contentType.WriteOneWithStatus(writer, request, NewServerError(err.Error()), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		panic(fmt.Sprintf("error while restoring registry: %s", err))
+	}
+	user := api.getUserRequired(request)
+	log.Warnf("Registry has been restored from a backup by %s: %d objects", user.Name, result.Total())
+
+	// actual state describes the world as of the backup time, so it has to be reconciled before the next enforcement
+	err = api.registry.UpdateReconciliation(engine.NewReconciliation(api.clock.Now(), user.Name, api.reconciliationThreshold))
+	if err != nil {
+		panic(fmt.Sprintf("error while saving reconciliation: %s", err))
+	}
+	api.triggerEnforcement()
+
+	api.contentType.WriteOne(writer, request, &RestoreResult{
+		TypeKind: TypeRestoreResult.GetTypeKind(),
+		Objects:  result.Objects,
+		Total:    result.Total(),
+	})
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackupRestore(t *testing.T) {
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	user := b.AddUser()
+	user.DomainAdmin = true
+	claim := b.AddClaim(user, service)
+
+	newServer := func() (registry.Interface, *Server) {
+		reg := registry.New(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()))
+		return reg, NewServer(Options{
+			Registry:                reg,
+			ExternalData:            b.External(),
+			AuthProvider:            &userAuthProvider{user: user},
+			ReconciliationThreshold: 3,
+		})
+	}
+
+	source, sourceServer := newServer()
+	if !assert.NoError(t, source.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+	_, _, err := source.UpdatePolicy([]lang.Base{cluster, bundle, service, claim}, user.Name)
+	if !assert.NoError(t, err, "Policy should be updated") {
+		t.FailNow()
+	}
+
+	recorder := httptest.NewRecorder()
+	sourceServer.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/admin/backup", nil))
+	if !assert.Equal(t, http.StatusOK, recorder.Code, "Registry should be backed up: %s", recorder.Body.String()) {
+		t.FailNow()
+	}
+	backup := recorder.Body.Bytes()
+
+	// restored registry should be marked for reconciliation right away
+	restored, restoredServer := newServer()
+	recorder = httptest.NewRecorder()
+	restoredServer.ServeHTTP(recorder, httptest.NewRequest("POST", "/api/v1/admin/restore", bytes.NewReader(backup)))
+	if !assert.Equal(t, http.StatusOK, recorder.Code, "Registry should be restored: %s", recorder.Body.String()) {
+		t.FailNow()
+	}
+	obj, err := codec.NewYAMLCodec(runtime.NewTypes().Append(Types...)).DecodeOne(recorder.Body.Bytes())
+	if assert.NoError(t, err, "Restore result should be decoded") {
+		assert.NotZero(t, obj.(*RestoreResult).Total, "Objects should be restored")
+	}
+
+	reconciliation, err := restored.GetReconciliation()
+	if assert.NoError(t, err, "Reconciliation should be loaded") && assert.NotNil(t, reconciliation, "Reconciliation should be written") {
+		assert.Equal(t, engine.ReconciliationStatusPending, reconciliation.Status, "Reconciliation should be pending")
+		assert.Equal(t, user.Name, reconciliation.RestoredBy, "Reconciliation should record who restored the registry")
+		assert.Equal(t, 3, reconciliation.Threshold, "Reconciliation should use the configured threshold")
+	}
+
+	// restoring into non-empty registry requires force
+	recorder = httptest.NewRecorder()
+	restoredServer.ServeHTTP(recorder, httptest.NewRequest("POST", "/api/v1/admin/restore", bytes.NewReader(backup)))
+	assert.Equal(t, http.StatusConflict, recorder.Code, "Restore into non-empty registry should fail unless forced")
+}
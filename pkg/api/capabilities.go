@@ -0,0 +1,55 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+)
+
+// checkClusterCapability returns an error if plugin of any of the given clusters doesn't support a given capability
+func checkClusterCapability(plugins plugin.Registry, clusters []*lang.Cluster, capability plugin.Capability) error {
+	for _, cluster := range clusters {
+		clusterPlugin, err := plugins.ForCluster(cluster)
+		if err != nil {
+			return fmt.Errorf("can't get plugin for cluster '%s/%s': %s", cluster.Namespace, cluster.Name, err)
+		}
+		capabilities := clusterPlugin.Capabilities()
+		if !capabilities.Has(capability) {
+			return fmt.Errorf("plugin for cluster '%s/%s' of type '%s' doesn't support %s (supported: %s)", cluster.Namespace, cluster.Name, cluster.Type, capability, capabilities)
+		}
+	}
+
+	return nil
+}
+
+// claimClusters returns all clusters, where code component instances of a given claim are placed, sorted by name
+func claimClusters(policy *lang.Policy, state *resolve.PolicyResolution, claimKey string) ([]*lang.Cluster, error) {
+	clusterMap := make(map[string]*lang.Cluster)
+	for _, instance := range state.ComponentInstanceMap {
+		if _, ok := instance.ClaimKeys[claimKey]; !ok || !instance.IsCode {
+			continue
+		}
+		clusterObj, err := policy.GetObject(lang.TypeCluster.Kind, instance.Metadata.Key.ClusterName, instance.Metadata.Key.ClusterNameSpace)
+		if err != nil {
+			return nil, err
+		}
+		if clusterObj == nil {
+			return nil, fmt.Errorf("can't find cluster '%s/%s'", instance.Metadata.Key.ClusterNameSpace, instance.Metadata.Key.ClusterName)
+		}
+		cluster := clusterObj.(*lang.Cluster) // nolint: errcheck
+		clusterMap[cluster.Namespace+"/"+cluster.Name] = cluster
+	}
+
+	result := make([]*lang.Cluster, 0, len(clusterMap))
+	for _, cluster := range clusterMap {
+		result = append(result, cluster)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Namespace+"/"+result[i].Name < result[j].Namespace+"/"+result[j].Name
+	})
+
+	return result, nil
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/config"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+	"github.com/Aptomi/aptomi/pkg/plugin/fake"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaimResourcesPreviewGatedByCapabilities(t *testing.T) {
+	reg := registry.New(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()))
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	// one claim goes to the cluster which supports preview, another one goes to the cluster which doesn't
+	b := builder.NewPolicyBuilder()
+	clusterPreview := b.AddCluster()
+	clusterLegacy := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	user := b.AddUser()
+	claimPreview := b.AddClaim(user, service)
+	claimPreview.Labels[lang.LabelTarget] = clusterPreview.Name
+	claimLegacy := b.AddClaim(user, service)
+	claimLegacy.Labels[lang.LabelTarget] = clusterLegacy.Name
+
+	_, policyData, err := reg.UpdatePolicy([]lang.Base{clusterPreview, clusterLegacy, bundle, service, claimPreview, claimLegacy}, "admin")
+	if !assert.NoError(t, err, "Policy should be updated") {
+		t.FailNow()
+	}
+	desiredState, resolveErr := resolve.NewPolicyResolver(b.Policy(), b.External(), event.NewLog(logrus.WarnLevel, "test-resolve")).ResolveAllClaims(context.Background())
+	if !assert.NoError(t, resolveErr, "Policy should be resolved without errors") {
+		t.FailNow()
+	}
+	if !assert.NoError(t, desiredState.Validate(b.Policy()), "Policy should be resolved without errors") {
+		t.FailNow()
+	}
+	_, err = reg.NewRevision(policyData.GetGeneration(), desiredState, false)
+	if !assert.NoError(t, err, "Revision should be created") {
+		t.FailNow()
+	}
+
+	server := NewServer(Options{
+		Registry:     reg,
+		ExternalData: b.External(),
+		PluginRegistryFactory: func() plugin.Registry {
+			codeTypes := map[string]plugin.CodePluginConstructor{
+				"helm": func(cluster plugin.ClusterPlugin, cfg config.Plugins) (plugin.CodePlugin, error) {
+					return fake.NewNoOpCodePlugin(0), nil
+				},
+			}
+			return plugin.NewRegistry(
+				config.Plugins{},
+				map[string]plugin.ClusterPluginConstructor{
+					"kubernetes": func(cluster *lang.Cluster, cfg config.Plugins) (plugin.ClusterPlugin, error) {
+						if cluster.Name == clusterLegacy.Name {
+							return &legacyClusterPlugin{ClusterPlugin: fake.NewNoOpClusterPlugin(0)}, nil
+						}
+						return fake.NewNoOpClusterPlugin(0), nil
+					},
+				},
+				map[string]map[string]plugin.CodePluginConstructor{"kubernetes": codeTypes},
+			)
+		},
+		AuthProvider: &userAuthProvider{user: user},
+	})
+
+	get := func(claim *lang.Claim, query string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", "/api/v1/policy/claim/resources/"+claim.Namespace+"/"+claim.Name+query, nil)
+		request.Header.Set("Content-Type", codec.JSON)
+		server.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	assert.Equal(t, http.StatusOK, get(claimPreview, "?preview=true").Code, "Preview should be allowed for cluster which supports it")
+	assert.Equal(t, http.StatusOK, get(claimLegacy, "").Code, "Resources should be returned without preview for any cluster")
+
+	response := get(claimLegacy, "?preview=true")
+	assert.Equal(t, http.StatusUnprocessableEntity, response.Code, "Preview should be rejected for cluster which doesn't support it")
+	assert.Contains(t, response.Body.String(), "of type 'kubernetes' doesn't support preview (supported: none)", "Error should explain which cluster can't preview")
+
+	// there is nothing to preview, until the latest policy generation gets a revision
+	claimPreview.Labels["updated"] = "true"
+	_, _, err = reg.UpdatePolicy([]lang.Base{claimPreview}, "admin")
+	if !assert.NoError(t, err, "Policy should be updated") {
+		t.FailNow()
+	}
+	assert.Equal(t, http.StatusNotFound, get(claimPreview, "?preview=true").Code, "Preview should not be found without revision")
+
+	claimMissing := b.AddClaim(user, service)
+	assert.Equal(t, http.StatusNotFound, get(claimMissing, "").Code, "Missing claim should not be found")
+}
+
+// legacyClusterPlugin is a cluster plugin, which doesn't support any of the optional features
+type legacyClusterPlugin struct {
+	plugin.ClusterPlugin
+}
+
+func (*legacyClusterPlugin) Capabilities() plugin.ClusterCapabilities {
+	return plugin.NewClusterCapabilities()
+}
+
+// userAuthProvider authenticates all requests as the same user
+type userAuthProvider struct {
+	user *lang.User
+}
+
+func (provider *userAuthProvider) NewToken(user *lang.User) (string, error) {
+	return user.Name, nil
+}
+
+func (provider *userAuthProvider) VerifyToken(request *http.Request) (string, error) {
+	return provider.user.Name, nil
+}
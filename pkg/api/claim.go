@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"net/http"
 	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -52,6 +54,57 @@ type ClaimStatus struct {
 	Endpoints map[string]map[string]string
 }
 
+// GetDefaultColumns returns default set of columns of claim status rows
+func (claimsStatus *ClaimsStatus) GetDefaultColumns() []string {
+	return (&ClaimStatusRow{}).GetDefaultColumns()
+}
+
+// AsDisplayableList returns claim statuses as a list of rows (sorted by claim key), so they could be rendered as table
+func (claimsStatus *ClaimsStatus) AsDisplayableList() []runtime.Displayable {
+	keys := make([]string, 0, len(claimsStatus.Status))
+	for key := range claimsStatus.Status {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]runtime.Displayable, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, &ClaimStatusRow{ClaimKey: key, ClaimStatus: claimsStatus.Status[key]})
+	}
+
+	return result
+}
+
+// ClaimStatusRow is a single row of ClaimsStatus, which holds claim key together with its status
+type ClaimStatusRow struct {
+	ClaimKey string
+	*ClaimStatus
+}
+
+// GetDefaultColumns returns default set of columns to be displayed
+func (row *ClaimStatusRow) GetDefaultColumns() []string {
+	return []string{"Claim", "Found", "Deployed", "Ready", "Endpoints"}
+}
+
+// AsColumns returns ClaimStatusRow representation as columns
+func (row *ClaimStatusRow) AsColumns() map[string]string {
+	endpoints := make([]string, 0)
+	for component, componentEndpoints := range row.Endpoints {
+		for name, url := range componentEndpoints {
+			endpoints = append(endpoints, fmt.Sprintf("%s/%s=%s", component, name, url))
+		}
+	}
+	sort.Strings(endpoints)
+
+	return map[string]string{
+		"Claim":     row.ClaimKey,
+		"Found":     strconv.FormatBool(row.Found),
+		"Deployed":  strconv.FormatBool(row.Deployed),
+		"Ready":     strconv.FormatBool(row.Ready),
+		"Endpoints": strings.Join(endpoints, " "),
+	}
+}
+
 func (api *coreAPI) handleClaimStatusGet(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
 	// parse query mode flag (deployment status vs. readiness status) as well as the list of claim IDs
 	flag := ClaimQueryFlag(params.ByName("queryFlag"))
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/util"
+	"github.com/julienschmidt/httprouter"
+	"github.com/sirupsen/logrus"
+)
+
+// TypeClaimResolutionResult is an informational data structure with Kind and Constructor for ClaimResolutionResult
+var TypeClaimResolutionResult = &runtime.TypeInfo{
+	Kind:        "claim-resolution-result",
+	Constructor: func() runtime.Object { return &ClaimResolutionResult{} },
+}
+
+// ClaimResolutionResult represents the outcome of resolving a single claim of a given policy generation, including
+// component instances of its transitive component tree and the resolution log. Nothing is changed while it's calculated
+type ClaimResolutionResult struct {
+	runtime.TypeKind `yaml:",inline"`
+	PolicyGeneration runtime.Generation
+	ClaimKey         string
+	Resolved         bool
+
+	// ComponentInstanceKey is the key of bundle instance the claim got resolved to
+	ComponentInstanceKey string `yaml:",omitempty"`
+
+	// Instances are all component instances used by the claim, ordered by key
+	Instances []*ClaimResolutionInstance
+	EventLog  []*event.APIEvent
+}
+
+// ClaimResolutionInstance is a single component instance used by the claim along with its calculated parameters
+type ClaimResolutionInstance struct {
+	Key     string
+	Cluster string
+
+	// Depth is 0 for the bundle instance claim got resolved to and grows as components of other services get involved
+	Depth int
+
+	CodeParams      util.NestedParameterMap `yaml:",omitempty"`
+	DiscoveryParams util.NestedParameterMap `yaml:",omitempty"`
+	Error           string                  `yaml:",omitempty"`
+}
+
+func (api *coreAPI) handleClaimResolve(writer http.ResponseWriter, request *http.Request, params httprouter.Params) error {
+	gen, err := parseGenParam(params, "gen")
+	if err != nil {
+		return err
+	}
+
+	policy, policyGen, err := api.registry.GetPolicy(gen)
+	if err != nil {
+		return fmt.Errorf("error while getting requested policy: %s", err)
+	}
+	if policy == nil {
+		return newRequestError(http.StatusNotFound, ErrorCodeNotFound, "policy generation %s doesn't exist or was compacted", gen)
+	}
+
+	ns := params.ByName("ns")
+	name := params.ByName("claim")
+	if _, ok := policy.Namespace[ns]; !ok {
+		return newRequestError(http.StatusNotFound, ErrorCodeNotFound, "namespace %s doesn't exist in policy #%s", ns, policyGen)
+	}
+	obj, err := policy.GetObject(lang.TypeClaim.Kind, name, ns)
+	if err != nil {
+		return newRequestError(http.StatusBadRequest, ErrorCodeInvalidRequest, "error while getting claim %s/%s in policy #%s: %s", ns, name, policyGen, err)
+	}
+	if obj == nil {
+		return newRequestError(http.StatusNotFound, ErrorCodeNotFound, "claim %s/%s doesn't exist in policy #%s", ns, name, policyGen)
+	}
+	claim := obj.(*lang.Claim) // nolint: errcheck
+	claimKey := runtime.KeyForStorable(claim)
+
+	// Resolution is made for debugging, so all informational events are returned by default
+	logLevel, logLevelErr := logrus.ParseLevel(request.URL.Query().Get("loglevel"))
+	if logLevelErr != nil {
+		logLevel = logrus.InfoLevel
+	}
+
+	eventLog := api.newEventLog(getResolutionLogLevel(logLevel), "api-claim-resolve")
+	resolution, err := resolve.NewPolicyResolver(policy, api.externalData, eventLog).SetPluginRegistry(api.pluginRegistryFactory()).ResolveClaim(request.Context(), claimKey)
+	if err != nil {
+		return resolutionError(err)
+	}
+
+	claimResolution := resolution.GetClaimResolution(claim)
+	api.contentType.WriteOne(writer, request, &ClaimResolutionResult{
+		TypeKind:             TypeClaimResolutionResult.GetTypeKind(),
+		PolicyGeneration:     policyGen,
+		ClaimKey:             claimKey,
+		Resolved:             claimResolution.Resolved,
+		ComponentInstanceKey: claimResolution.ComponentInstanceKey,
+		Instances:            getClaimResolutionInstances(resolution, claimKey),
+		EventLog:             event.FilterAPIEvents(eventLog.AsAPIEvents(), logLevel),
+	})
+	return nil
+}
+
+// getClaimResolutionInstances returns all component instances used by a given claim ordered by key
+func getClaimResolutionInstances(resolution *resolve.PolicyResolution, claimKey string) []*ClaimResolutionInstance {
+	keys := make([]string, 0, len(resolution.ComponentInstanceMap))
+	for key, instance := range resolution.ComponentInstanceMap {
+		if _, found := instance.ClaimKeys[claimKey]; found {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	result := make([]*ClaimResolutionInstance, 0, len(keys))
+	for _, key := range keys {
+		instance := resolution.ComponentInstanceMap[key]
+		resolved := &ClaimResolutionInstance{
+			Key:             key,
+			Cluster:         instance.Metadata.Key.GetCluster(),
+			Depth:           instance.ClaimKeys[claimKey],
+			CodeParams:      instance.CalculatedCodeParams,
+			DiscoveryParams: instance.CalculatedDiscovery,
+		}
+		if instance.Error != nil {
+			resolved.Error = instance.Error.Error()
+		}
+		result = append(result, resolved)
+	}
+
+	return result
+}
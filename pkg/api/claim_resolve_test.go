@@ -0,0 +1,96 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/config"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+	"github.com/Aptomi/aptomi/pkg/plugin/fake"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/Aptomi/aptomi/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaimResolve(t *testing.T) {
+	reg := registry.New(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()))
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(util.NestedParameterMap{"name": "{{ .Labels.name }}"}, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	rule := b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+	claim := b.AddClaim(b.AddUser(), service)
+	claim.Labels["name"] = "first"
+	otherClaim := b.AddClaim(b.AddUser(), service)
+	otherClaim.Labels["name"] = "second"
+
+	_, _, err := reg.UpdatePolicy([]lang.Base{cluster, bundle, service, rule, claim, otherClaim}, "test")
+	if !assert.NoError(t, err, "Policy should be updated") {
+		t.FailNow()
+	}
+
+	server := NewServer(Options{
+		Registry:     reg,
+		ExternalData: b.External(),
+		PluginRegistryFactory: func() plugin.Registry {
+			return plugin.NewRegistry(
+				config.Plugins{},
+				map[string]plugin.ClusterPluginConstructor{
+					"kubernetes": func(cluster *lang.Cluster, cfg config.Plugins) (plugin.ClusterPlugin, error) {
+						return fake.NewNoOpClusterPlugin(0), nil
+					},
+				},
+				map[string]map[string]plugin.CodePluginConstructor{},
+			)
+		},
+		AuthProvider: &userAuthProvider{user: b.AddUser()},
+	})
+	apiCodec := codec.NewYAMLCodec(runtime.NewTypes().Append(Types...))
+
+	get := func(path string) (int, runtime.Object) {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		obj, decodeErr := apiCodec.DecodeOne(recorder.Body.Bytes())
+		if !assert.NoError(t, decodeErr, "Response should be decoded") {
+			t.FailNow()
+		}
+		return recorder.Code, obj
+	}
+
+	code, obj := get("/api/v1/policy/gen/2/resolve/" + claim.Namespace + "/" + claim.Name)
+	if !assert.Equal(t, http.StatusOK, code, "Claim should be resolved: %v", obj) {
+		t.FailNow()
+	}
+	result := obj.(*ClaimResolutionResult)
+	assert.EqualValues(t, 2, result.PolicyGeneration)
+	assert.Equal(t, runtime.KeyForStorable(claim), result.ClaimKey)
+	assert.True(t, result.Resolved, "Claim should be resolved")
+	assert.NotEmpty(t, result.ComponentInstanceKey, "Bundle instance of the claim should be returned")
+	assert.NotEmpty(t, result.EventLog, "Resolution log should be returned")
+
+	codeParams := []interface{}{}
+	for _, instance := range result.Instances {
+		assert.Equal(t, cluster.Namespace+"/"+cluster.Name, instance.Cluster, "Instance should be placed on the cluster")
+		if len(instance.CodeParams) > 0 {
+			codeParams = append(codeParams, instance.CodeParams["name"])
+		}
+	}
+	assert.Equal(t, []interface{}{"first"}, codeParams, "Only code params calculated for the claim should be returned")
+
+	code, _ = get("/api/v1/policy/gen/2/resolve/" + claim.Namespace + "/missing")
+	assert.Equal(t, http.StatusNotFound, code, "Missing claim should not be found")
+	code, _ = get("/api/v1/policy/gen/42/resolve/" + claim.Namespace + "/" + claim.Name)
+	assert.Equal(t, http.StatusNotFound, code, "Missing policy generation should not be found")
+}
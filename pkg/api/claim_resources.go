@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"runtime/debug"
+	"strconv"
 	"sync"
 
 	"github.com/Aptomi/aptomi/pkg/engine/resolve"
@@ -11,6 +12,7 @@ import (
 	"github.com/Aptomi/aptomi/pkg/lang"
 	"github.com/Aptomi/aptomi/pkg/plugin"
 	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
 	"github.com/julienschmidt/httprouter"
 	"github.com/sirupsen/logrus"
 )
@@ -25,7 +27,7 @@ func (g *claimResourcesWrapper) GetKind() string {
 
 func (api *coreAPI) handleClaimResourcesGet(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
 	gen := runtime.LastOrEmptyGen
-	policy, _, err := api.registry.GetPolicy(gen)
+	policy, policyGen, err := api.registry.GetPolicy(gen)
 	if err != nil {
 		panic(fmt.Sprintf("error while getting requested policy: %s", err))
 	}
@@ -40,17 +42,56 @@ func (api *coreAPI) handleClaimResourcesGet(writer http.ResponseWriter, request
 	}
 	if obj == nil {
 		api.contentType.WriteOneWithStatus(writer, request, nil, http.StatusNotFound)
+		return
 	}
 
-	// once claim is loaded, we need to find its state in the actual state
+	// once claim is loaded, we need to find its state in the actual state. in preview mode, desired state is used
+	// instead, so resources of component instances which haven't been deployed yet get rendered as well
 	claim := obj.(*lang.Claim) // nolint: errcheck
-	actualState, err := api.registry.GetActualState()
-	if err != nil {
-		panic(fmt.Sprintf("Can't load actual state to get endpoints: %s", err))
+	preview, _ := strconv.ParseBool(request.URL.Query().Get("preview"))
+	var actualState *resolve.PolicyResolution
+	if preview {
+		revision, errRevision := api.registry.GetLastRevisionForPolicy(policyGen)
+		if errRevision != nil {
+			panic(fmt.Sprintf("error while loading revision from the registry: %s", errRevision))
+		}
+		if revision == nil {
+			serverErr := NewServerError(fmt.Sprintf("there is no revision for policy generation %s, resources of claim can't be previewed", policyGen))
+			api.contentType.WriteOneWithStatus(writer, request, serverErr, http.StatusNotFound)
+			return
+		}
+		actualState, err = api.registry.GetDesiredState(revision)
+		if registry.IsDesiredStateNotFound(err) {
+			api.contentType.WriteOneWithStatus(writer, request, NewServerError(err.Error()), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			panic(fmt.Sprintf("can't load desired state from revision: %s", err))
+		}
+	} else {
+		actualState, err = api.registry.GetActualState()
+		if err != nil {
+			panic(fmt.Sprintf("Can't load actual state to get endpoints: %s", err))
+		}
 	}
 
 	plugins := api.pluginRegistryFactory()
 	depKey := runtime.KeyForStorable(claim)
+
+	// preview is only possible if plugins of all clusters, where claim is deployed, support it
+	if preview {
+		clusters, errClusters := claimClusters(policy, actualState, depKey)
+		if errClusters != nil {
+			panic(fmt.Sprintf("can't get clusters of claim '%s': %s", depKey, errClusters))
+		}
+		err = checkClusterCapability(plugins, clusters, plugin.CapabilityPreview)
+		if err != nil {
+			serverErr := NewServerError(fmt.Sprintf("resources of claim '%s' can't be previewed: %s", depKey, err))
+			api.contentType.WriteOneWithStatus(writer, request, serverErr, http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
 	resources := make(plugin.Resources)
 	rMergeMutex := sync.Mutex{}
 	var wg sync.WaitGroup
@@ -82,11 +123,15 @@ func (api *coreAPI) handleClaimResourcesGet(writer http.ResponseWriter, request
 					panic(fmt.Sprintf("Can't get plugin for component instance %s: %s", instance.GetKey(), pluginErr))
 				}
 
+				pluginParams := map[string]string{plugin.ParamTargetSuffix: instance.Metadata.Key.TargetSuffix}
+				if preview {
+					pluginParams[plugin.ParamPreview] = "true"
+				}
 				instanceResources, resErr := codePlugin.Resources(
 					&plugin.CodePluginInvocationParams{
 						DeployName:   instance.GetDeployName(),
 						Params:       instance.CalculatedCodeParams,
-						PluginParams: map[string]string{plugin.ParamTargetSuffix: instance.Metadata.Key.TargetSuffix},
+						PluginParams: pluginParams,
 						EventLog:     event.NewLog(logrus.WarnLevel, "resources"),
 					},
 				)
@@ -87,7 +87,10 @@ func (handler *ContentTypeHandler) GetContentType(header http.Header) string {
 
 // ReadOne runtime object from the provided request using correct content type (taken from request)
 func (handler *ContentTypeHandler) ReadOne(request *http.Request) runtime.Object {
-	objects := handler.Read(request)
+	objects, err := handler.Read(request)
+	if err != nil {
+		panic(err)
+	}
 	if len(objects) != 1 {
 		panic(fmt.Sprintf("Expected 1 but read %d from request", len(objects)))
 	}
@@ -95,20 +98,20 @@ func (handler *ContentTypeHandler) ReadOne(request *http.Request) runtime.Object
 	return objects[0]
 }
 
-// Read runtime object(s) from the provided request using correct content type (taken from the request)
-func (handler *ContentTypeHandler) Read(request *http.Request) []runtime.Object {
+// Read runtime object(s) from the provided request using correct content type (taken from the request). Error is
+// returned if request body can't be read or decoded, so it could be reported to the client as a bad request
+func (handler *ContentTypeHandler) Read(request *http.Request) ([]runtime.Object, error) {
 	body, err := ioutil.ReadAll(request.Body)
 	if err != nil {
-		panic(fmt.Sprintf("Error while reading bytes from request Body: %s", err))
+		return nil, fmt.Errorf("error while reading bytes from request body: %s", err)
 	}
 
 	objects, err := handler.GetCodec(request.Header).DecodeOneOrMany(body)
 	if err != nil {
-		// todo response with some bad request status code
-		panic(fmt.Sprintf("Error decoding policy update request: %s", err))
+		return nil, fmt.Errorf("error while decoding request: %s", err)
 	}
 
-	return objects
+	return objects, nil
 }
 
 // WriteOne runtime object into the provided response writer using correct content type (taken from provided request)
@@ -120,6 +123,13 @@ func (handler *ContentTypeHandler) WriteOne(writer http.ResponseWriter, request
 // WriteOneWithStatus runtime object into the provided response writer using correct content type (taken from provided request)
 // with specified http status
 func (handler *ContentTypeHandler) WriteOneWithStatus(writer http.ResponseWriter, request *http.Request, body runtime.Object, status int) {
+	if body != nil && IsCSVRequested(request) {
+		if objs, defaultColumns, ok := AsDisplayableList(body); ok {
+			handler.writeCSV(writer, request, objs, defaultColumns, status)
+			return
+		}
+	}
+
 	writer.Header().Set("Content-Type", handler.GetContentType(request.Header))
 	writer.WriteHeader(status)
 
@@ -145,6 +155,26 @@ func (handler *ContentTypeHandler) WriteMany(writer http.ResponseWriter, request
 // WriteManyWithStatus runtime objects into the provided response writer using correct content type (taken from provided request)
 // with specified http status
 func (handler *ContentTypeHandler) WriteManyWithStatus(writer http.ResponseWriter, request *http.Request, body []runtime.Object, status int) {
+	if body != nil && IsCSVRequested(request) {
+		objs := make([]runtime.Displayable, 0, len(body))
+		for _, obj := range body {
+			if displayable, ok := obj.(runtime.Displayable); ok {
+				objs = append(objs, displayable)
+			}
+		}
+
+		// header of an empty list could only be written if columns are requested, as there is no object to take
+		// default columns from
+		if len(objs) > 0 && len(objs) == len(body) {
+			handler.writeCSV(writer, request, objs, objs[0].GetDefaultColumns(), status)
+			return
+		}
+		if len(body) == 0 && len(RequestedColumns(request)) > 0 {
+			handler.writeCSV(writer, request, objs, nil, status)
+			return
+		}
+	}
+
 	writer.Header().Set("Content-Type", handler.GetContentType(request.Header))
 	writer.WriteHeader(status)
 
@@ -164,3 +194,20 @@ func (handler *ContentTypeHandler) WriteManyWithStatus(writer http.ResponseWrite
 		}
 	}
 }
+
+// writeCSV writes displayable objects into the provided response writer as CSV with header row, honoring requested
+// columns (taken from provided request) and falling back to provided default columns
+func (handler *ContentTypeHandler) writeCSV(writer http.ResponseWriter, request *http.Request, objs []runtime.Displayable, defaultColumns []string, status int) {
+	data, err := EncodeCSV(objs, defaultColumns, RequestedColumns(request))
+	if err != nil {
+		panic(fmt.Sprintf("Error while encoding body as csv: %s", err))
+	}
+
+	writer.Header().Set("Content-Type", CSV)
+	writer.WriteHeader(status)
+
+	_, wErr := writer.Write(data)
+	if wErr != nil {
+		panic(fmt.Sprintf("Error while writing body: %s", wErr))
+	}
+}
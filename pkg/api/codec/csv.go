@@ -0,0 +1,116 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+const (
+	// CSV is the csv content type, it's only supported for writing objects implementing runtime.Displayable or
+	// runtime.DisplayableList
+	CSV = "text/csv"
+
+	// FormatParam is the name of the query parameter that could be used to request specific format (e.g. format=csv)
+	FormatParam = "format"
+
+	// ColumnsParam is the name of the query parameter with comma-separated list of columns to be included into
+	// table-like output
+	ColumnsParam = "columns"
+)
+
+// IsCSVRequested returns true if CSV output requested either using Accept header or format query parameter
+func IsCSVRequested(request *http.Request) bool {
+	if strings.EqualFold(request.URL.Query().Get(FormatParam), "csv") {
+		return true
+	}
+
+	for _, accept := range strings.Split(request.Header.Get("Accept"), ",") {
+		mediaType := strings.TrimSpace(strings.Split(accept, ";")[0])
+		if strings.EqualFold(mediaType, CSV) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RequestedColumns returns list of columns requested using columns query parameter or nil if not specified
+func RequestedColumns(request *http.Request) []string {
+	value := request.URL.Query().Get(ColumnsParam)
+	if len(value) == 0 {
+		return nil
+	}
+
+	columns := make([]string, 0)
+	for _, column := range strings.Split(value, ",") {
+		column = strings.TrimSpace(column)
+		if len(column) > 0 {
+			columns = append(columns, column)
+		}
+	}
+
+	return columns
+}
+
+// AsDisplayableList returns list of displayable objects for provided object if it's runtime.Displayable or
+// runtime.DisplayableList along with their default columns, third returned value will be false if object couldn't be
+// displayed as table
+func AsDisplayableList(obj interface{}) ([]runtime.Displayable, []string, bool) {
+	if list, ok := obj.(runtime.DisplayableList); ok {
+		return list.AsDisplayableList(), list.GetDefaultColumns(), true
+	}
+	if displayable, ok := obj.(runtime.Displayable); ok {
+		return []runtime.Displayable{displayable}, displayable.GetDefaultColumns(), true
+	}
+
+	return nil, nil, false
+}
+
+// EncodeCSV returns CSV representation (with header row) of the provided displayable objects. If columns are not
+// specified, provided default columns will be used, so header row is written even if there are no objects. Escaping is
+// done according to RFC 4180.
+func EncodeCSV(objs []runtime.Displayable, defaultColumns []string, columns []string) ([]byte, error) {
+	if objs == nil {
+		return nil, fmt.Errorf("no list of displayable objects to write as csv")
+	}
+	if len(columns) == 0 {
+		columns = defaultColumns
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns to write as csv header")
+	}
+
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	writer.UseCRLF = true
+
+	err := writer.Write(columns)
+	if err != nil {
+		return nil, fmt.Errorf("error while writing csv header: %s", err)
+	}
+
+	row := make([]string, len(columns))
+	for _, obj := range objs {
+		allColumns := obj.AsColumns()
+		for idx, column := range columns {
+			row[idx] = allColumns[column]
+		}
+
+		err := writer.Write(row)
+		if err != nil {
+			return nil, fmt.Errorf("error while writing csv row: %s", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, fmt.Errorf("error while flushing csv: %s", err)
+	}
+
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,138 @@
+package codec_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/apply/action"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	revisions := []runtime.Object{
+		makeRevision(1, 10, engine.RevisionStatusCompleted),
+		makeRevision(2, 11, "status with \"quotes\", commas\nand new lines"),
+	}
+
+	handler := codec.NewContentTypeHandler(runtime.NewTypes().Append(engine.TypeRevision))
+
+	// get csv representation
+	request := httptest.NewRequest("GET", "/revisions?format=csv", nil)
+	recorder := httptest.NewRecorder()
+	handler.WriteMany(recorder, request, revisions)
+	assert.Equal(t, codec.CSV, recorder.Header().Get("Content-Type"))
+
+	records, err := csv.NewReader(bytes.NewReader(recorder.Body.Bytes())).ReadAll()
+	assert.NoError(t, err, "Produced CSV should be parsed without errors")
+	if !assert.Len(t, records, len(revisions)+1, "CSV should contain header row and row per object") {
+		t.FailNow()
+	}
+	assert.Equal(t, (&engine.Revision{}).GetDefaultColumns(), records[0], "Header row should contain default columns")
+
+	// get json representation
+	request = httptest.NewRequest("GET", "/revisions", nil)
+	request.Header.Set("Content-Type", codec.JSON)
+	recorder = httptest.NewRecorder()
+	handler.WriteMany(recorder, request, revisions)
+
+	var jsonRevisions []map[string]interface{}
+	err = json.Unmarshal(recorder.Body.Bytes(), &jsonRevisions)
+	assert.NoError(t, err, "Produced JSON should be parsed without errors")
+	assert.Len(t, jsonRevisions, len(revisions))
+
+	for idx, jsonRevision := range jsonRevisions {
+		row := toMap(records[0], records[idx+1])
+		metadata := jsonRevision["metadata"].(map[string]interface{})
+		assert.Equal(t, fmt.Sprint(metadata["generation"]), row["Revision"])
+		assert.Equal(t, fmt.Sprint(jsonRevision["policygen"]), row["Policy"])
+		assert.Equal(t, jsonRevision["status"], row["Status"])
+	}
+}
+
+func TestCSVColumnsSelectionAndAcceptHeader(t *testing.T) {
+	handler := codec.NewContentTypeHandler(runtime.NewTypes())
+	events := &eventList{events: []*event.APIEvent{
+		{LogLevel: "info", Message: "first"},
+		{LogLevel: "error", Message: "second, with comma"},
+	}}
+
+	request := httptest.NewRequest("GET", "/events?columns=Message,Level", nil)
+	request.Header.Set("Accept", "application/json;q=0.5, text/csv")
+	recorder := httptest.NewRecorder()
+	handler.WriteOneWithStatus(recorder, request, events, http.StatusOK)
+
+	assert.Equal(t, codec.CSV, recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "Message,Level\r\nfirst,info\r\n\"second, with comma\",error\r\n", recorder.Body.String())
+}
+
+func TestCSVEmptyList(t *testing.T) {
+	handler := codec.NewContentTypeHandler(runtime.NewTypes().Append(engine.TypeRevision))
+
+	// empty list gets header row with default columns
+	request := httptest.NewRequest("GET", "/events?format=csv", nil)
+	recorder := httptest.NewRecorder()
+	handler.WriteOneWithStatus(recorder, request, &eventList{events: []*event.APIEvent{}}, http.StatusOK)
+	assert.Equal(t, codec.CSV, recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "Time,Level,Message\r\n", recorder.Body.String(), "Header row should be written for empty list")
+
+	// empty list of objects gets header row only if columns are requested, as there is no object to take them from
+	request = httptest.NewRequest("GET", "/revisions?format=csv&columns=Revision,Status", nil)
+	recorder = httptest.NewRecorder()
+	handler.WriteMany(recorder, request, []runtime.Object{})
+	assert.Equal(t, codec.CSV, recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "Revision,Status\r\n", recorder.Body.String(), "Header row should be written for empty list")
+
+	// list, which can't provide displayable objects, is an error rather than empty csv
+	request = httptest.NewRequest("GET", "/events?format=csv", nil)
+	assert.Panics(t, func() {
+		handler.WriteOneWithStatus(httptest.NewRecorder(), request, &eventList{}, http.StatusOK)
+	}, "Nil list of displayable objects should not be written as csv")
+}
+
+type eventList struct {
+	events []*event.APIEvent
+}
+
+func (list *eventList) GetKind() string {
+	return "events"
+}
+
+func (list *eventList) GetDefaultColumns() []string {
+	return (&event.APIEvent{}).GetDefaultColumns()
+}
+
+func (list *eventList) AsDisplayableList() []runtime.Displayable {
+	if list.events == nil {
+		return nil
+	}
+
+	result := make([]runtime.Displayable, 0, len(list.events))
+	for _, e := range list.events {
+		result = append(result, e)
+	}
+	return result
+}
+
+func makeRevision(gen runtime.Generation, policyGen runtime.Generation, status string) *engine.Revision {
+	revision := engine.NewRevision(gen, policyGen, false)
+	revision.Status = status
+	revision.Result = &action.ApplyResult{Success: 1, Total: 2}
+	return revision
+}
+
+func toMap(header []string, record []string) map[string]string {
+	result := make(map[string]string)
+	for idx, column := range header {
+		result[column] = record[idx]
+	}
+	return result
+}
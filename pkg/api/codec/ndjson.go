@@ -0,0 +1,67 @@
+package codec
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+// NDJSON is the newline-delimited json content type, it's only supported for streaming objects one by one using
+// NDJSONWriter
+const NDJSON = "application/x-ndjson"
+
+// ndjsonFlushEvery is the number of objects written into response between flushes
+const ndjsonFlushEvery = 100
+
+// NDJSONWriter writes runtime objects into the http response one by one as newline-delimited json, so they could be
+// streamed without collecting all of them in memory first
+type NDJSONWriter struct {
+	writer  http.ResponseWriter
+	codec   Interface
+	written int
+}
+
+// NewNDJSONWriter writes NDJSON content type with specified http status into the provided response writer and returns
+// NDJSONWriter for streaming objects into it
+func (handler *ContentTypeHandler) NewNDJSONWriter(writer http.ResponseWriter, status int) *NDJSONWriter {
+	writer.Header().Set("Content-Type", NDJSON)
+	writer.WriteHeader(status)
+
+	return &NDJSONWriter{
+		writer: writer,
+		codec:  handler.codecs[JSON],
+	}
+}
+
+// Write writes single object as a line of json into the response, flushing it periodically
+func (w *NDJSONWriter) Write(obj runtime.Object) error {
+	data, err := w.codec.EncodeOne(obj)
+	if err != nil {
+		return fmt.Errorf("error while encoding object of kind %s: %s", obj.GetKind(), err)
+	}
+
+	_, err = w.writer.Write(append(data, '\n'))
+	if err != nil {
+		return fmt.Errorf("error while writing object of kind %s: %s", obj.GetKind(), err)
+	}
+
+	w.written++
+	if w.written%ndjsonFlushEvery == 0 {
+		w.Flush()
+	}
+
+	return nil
+}
+
+// Flush sends all buffered data to the client, if the response writer supports it
+func (w *NDJSONWriter) Flush() {
+	if flusher, ok := w.writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Written returns the number of objects written so far
+func (w *NDJSONWriter) Written() int {
+	return w.written
+}
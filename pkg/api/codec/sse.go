@@ -0,0 +1,93 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// EventStream is the content type of Server-Sent Events, it's only supported for streaming messages using SSEWriter
+const EventStream = "text/event-stream"
+
+// StreamParam is a name of the query parameter, which allows to request Server-Sent Events without setting Accept header
+const StreamParam = "stream"
+
+// IsEventStreamRequested returns true if client requested Server-Sent Events using Accept header or stream query
+// parameter
+func IsEventStreamRequested(request *http.Request) bool {
+	if stream, err := strconv.ParseBool(request.URL.Query().Get(StreamParam)); err == nil && stream {
+		return true
+	}
+
+	for _, accept := range strings.Split(request.Header.Get("Accept"), ",") {
+		mediaType := strings.TrimSpace(strings.Split(accept, ";")[0])
+		if strings.EqualFold(mediaType, EventStream) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SSEWriter writes messages into the http response as Server-Sent Events with json data, flushing every message, so
+// the client gets them as soon as they're written
+type SSEWriter struct {
+	writer  http.ResponseWriter
+	codec   *yamlCodec
+	written int
+}
+
+// NewSSEWriter writes event stream content type with specified http status into the provided response writer and
+// returns SSEWriter for streaming messages into it
+func (handler *ContentTypeHandler) NewSSEWriter(writer http.ResponseWriter, status int) *SSEWriter {
+	writer.Header().Set("Content-Type", EventStream)
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.WriteHeader(status)
+
+	result := &SSEWriter{
+		writer: writer,
+		codec:  &yamlCodec{json: true},
+	}
+	result.Flush()
+
+	return result
+}
+
+// Write writes a single message with a given event name and data encoded as json into the response and flushes it
+func (w *SSEWriter) Write(eventName string, data interface{}) error {
+	encoded, err := w.codec.encode(data)
+	if err != nil {
+		return fmt.Errorf("error while encoding %s event: %s", eventName, err)
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "event: %s\n", eventName)
+	for _, line := range bytes.Split(bytes.TrimRight(encoded, "\n"), []byte("\n")) {
+		fmt.Fprintf(buf, "data: %s\n", line)
+	}
+	buf.WriteByte('\n')
+
+	_, err = w.writer.Write(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("error while writing %s event: %s", eventName, err)
+	}
+
+	w.written++
+	w.Flush()
+
+	return nil
+}
+
+// Flush sends all buffered data to the client, if the response writer supports it
+func (w *SSEWriter) Flush() {
+	if flusher, ok := w.writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Written returns the number of messages written so far
+func (w *SSEWriter) Written() int {
+	return w.written
+}
@@ -1,13 +1,18 @@
 package codec
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 
 	"github.com/Aptomi/aptomi/pkg/runtime"
 	utilyaml "github.com/ghodss/yaml"
 	"gopkg.in/yaml.v2"
 )
 
+// documentSeparator starts every document of multi-document YAML stream except the first one
+var documentSeparator = []byte("---")
+
 type yamlCodec struct {
 	types *runtime.Types
 	json  bool
@@ -70,6 +75,63 @@ func (cod *yamlCodec) encode(obj interface{}) ([]byte, error) {
 }
 
 func (cod *yamlCodec) decodeOneOrMany(data []byte, strictOne bool) ([]runtime.Object, error) {
+	// don't decode data twice if it can't have multiple documents
+	if !bytes.HasPrefix(data, documentSeparator) && !bytes.Contains(data, append([]byte("\n"), documentSeparator...)) {
+		return cod.decodeDocument(data, strictOne)
+	}
+
+	docs, err := splitDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) <= 1 {
+		return cod.decodeDocument(data, strictOne)
+	}
+	if strictOne {
+		return nil, fmt.Errorf("single object expected, but found %d documents", len(docs))
+	}
+
+	// multi-document stream (e.g. exported policy) is decoded as concatenation of all its documents
+	result := make([]runtime.Object, 0)
+	for idx, doc := range docs {
+		objects, docErr := cod.decodeDocument(doc, false)
+		if docErr != nil {
+			return nil, fmt.Errorf("error while decoding document #%d: %s", idx, docErr)
+		}
+		result = append(result, objects...)
+	}
+
+	return result, nil
+}
+
+// splitDocuments returns all non-empty documents of the YAML stream, each re-encoded separately
+func splitDocuments(data []byte) ([][]byte, error) {
+	result := make([][]byte, 0)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var raw interface{}
+		err := decoder.Decode(&raw)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error while decoding data to raw interface{}: %s", err)
+		}
+		if raw == nil {
+			continue
+		}
+
+		doc, err := yaml.Marshal(raw)
+		if err != nil {
+			return nil, fmt.Errorf("error while splitting documents: %s", err)
+		}
+		result = append(result, doc)
+	}
+
+	return result, nil
+}
+
+func (cod *yamlCodec) decodeDocument(data []byte, strictOne bool) ([]runtime.Object, error) {
 	raw := new(interface{})
 	err := yaml.Unmarshal(data, raw)
 	if err != nil {
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/util"
+	"github.com/julienschmidt/httprouter"
+)
+
+// TypeDesiredStateInstanceKeys is an informational data structure with Kind and Constructor for DesiredStateInstanceKeys
+var TypeDesiredStateInstanceKeys = &runtime.TypeInfo{
+	Kind:        "desired-state-instance-keys",
+	Constructor: func() runtime.Object { return &DesiredStateInstanceKeys{} },
+}
+
+// DesiredStateInstanceKeys represents keys of component instances in desired state of a given revision
+type DesiredStateInstanceKeys struct {
+	runtime.TypeKind `yaml:",inline"`
+	RevisionGen      runtime.Generation
+	Keys             []string
+}
+
+// redactedValue replaces values of sensitive parameters in component instances returned via API
+const redactedValue = "<redacted>"
+
+// sensitiveParamNames are substrings of parameter names, which values are considered sensitive
+var sensitiveParamNames = []string{"password", "passwd", "secret", "token", "credential", "private_key", "privatekey"}
+
+func (api *coreAPI) handleDesiredStateInstancesGet(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	revision, err := api.registry.GetRevision(runtime.ParseGeneration(params.ByName("gen")))
+	if err != nil {
+		panic(fmt.Sprintf("error while getting requested revision: %s", err))
+	}
+	if revision == nil {
+		api.contentType.WriteOneWithStatus(writer, request, nil, http.StatusNotFound)
+		return
+	}
+
+	keys, err := api.registry.GetDesiredStateInstanceKeys(revision)
+	if registry.IsDesiredStateNotFound(err) {
+		api.contentType.WriteOneWithStatus(writer, request, nil, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		panic(fmt.Sprintf("error while getting desired state instance keys: %s", err))
+	}
+
+	result := &DesiredStateInstanceKeys{
+		TypeKind:    TypeDesiredStateInstanceKeys.GetTypeKind(),
+		RevisionGen: revision.GetGeneration(),
+		Keys:        []string{},
+	}
+	prefix := request.URL.Query().Get("prefix")
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			result.Keys = append(result.Keys, key)
+		}
+	}
+
+	api.contentType.WriteOne(writer, request, result)
+}
+
+func (api *coreAPI) handleDesiredStateInstanceGet(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	revision, err := api.registry.GetRevision(runtime.ParseGeneration(params.ByName("gen")))
+	if err != nil {
+		panic(fmt.Sprintf("error while getting requested revision: %s", err))
+	}
+	if revision == nil {
+		api.contentType.WriteOneWithStatus(writer, request, nil, http.StatusNotFound)
+		return
+	}
+
+	instance, err := api.registry.GetDesiredStateInstance(revision, params.ByName("key"))
+	if registry.IsDesiredStateNotFound(err) {
+		api.contentType.WriteOneWithStatus(writer, request, nil, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		panic(fmt.Sprintf("error while getting desired state instance: %s", err))
+	}
+	if instance == nil {
+		api.contentType.WriteOneWithStatus(writer, request, nil, http.StatusNotFound)
+		return
+	}
+
+	api.contentType.WriteOne(writer, request, redactInstance(instance))
+}
+
+// redactInstance returns a shallow copy of component instance with values of sensitive code and discovery
+// parameters replaced
+func redactInstance(instance *resolve.ComponentInstance) *resolve.ComponentInstance {
+	result := *instance
+	result.CalculatedCodeParams = redactParams(instance.CalculatedCodeParams)
+	result.CalculatedDiscovery = redactParams(instance.CalculatedDiscovery)
+	return &result
+}
+
+// redactParams returns a copy of parameter map with values of sensitive parameters (at any depth) replaced
+func redactParams(params util.NestedParameterMap) util.NestedParameterMap {
+	if params == nil {
+		return nil
+	}
+	result := util.NestedParameterMap{}
+	for key, value := range params {
+		if isSensitiveParam(key) {
+			result[key] = redactedValue
+		} else if nested, ok := value.(util.NestedParameterMap); ok {
+			result[key] = redactParams(nested)
+		} else {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+func isSensitiveParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, sensitive := range sensitiveParamNames {
+		if strings.Contains(name, sensitive) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/Aptomi/aptomi/pkg/util"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDesiredStateInstances(t *testing.T) {
+	st := memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec())
+	reg := registry.New(st)
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	// resolve a policy with a code component, which has sensitive parameters
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(util.NestedParameterMap{
+		"image": "app",
+		"db": util.NestedParameterMap{
+			"user":     "app",
+			"password": "s3cr3t",
+		},
+	}, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+	user := b.AddUser()
+	b.AddClaim(user, service)
+	resolution, resolveErr := resolve.NewPolicyResolver(b.Policy(), b.External(), event.NewLog(logrus.WarnLevel, "test")).ResolveAllClaims(context.Background())
+	if !assert.NoError(t, resolveErr, "Policy should be resolved without errors") {
+		t.FailNow()
+	}
+
+	// revision with addressable instances
+	revision, err := reg.NewRevision(runtime.FirstGen, resolution, false)
+	if !assert.NoError(t, err, "Revision should be created") {
+		t.FailNow()
+	}
+
+	// revision with desired state saved as a single object only, the way it was before instances became addressable
+	legacyRevision, err := reg.NewResolvingRevision(runtime.FirstGen)
+	if !assert.NoError(t, err, "Revision should be created") {
+		t.FailNow()
+	}
+	_, err = st.Save(engine.NewDesiredState(legacyRevision, resolution))
+	if !assert.NoError(t, err, "Desired state should be saved") {
+		t.FailNow()
+	}
+
+	server := NewServer(Options{Registry: reg, ExternalData: b.External(), AuthProvider: &userAuthProvider{user: user}})
+	apiCodec := codec.NewYAMLCodec(runtime.NewTypes().Append(Types...))
+
+	var codeKey string
+	for key, instance := range resolution.ComponentInstanceMap {
+		if instance.IsCode {
+			codeKey = key
+		}
+	}
+
+	for _, gen := range []runtime.Generation{revision.GetGeneration(), legacyRevision.GetGeneration()} {
+		// list all instance keys
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/revision/gen/"+gen.String()+"/desiredstate/instances", nil))
+		if !assert.Equal(t, http.StatusOK, recorder.Code, "Instance keys should be returned: %s", recorder.Body.String()) {
+			t.FailNow()
+		}
+		obj, err := apiCodec.DecodeOne(recorder.Body.Bytes())
+		if !assert.NoError(t, err, "Instance keys should be decoded") {
+			t.FailNow()
+		}
+		assert.Len(t, obj.(*DesiredStateInstanceKeys).Keys, len(resolution.ComponentInstanceMap), "All instance keys should be returned for revision %s", gen)
+
+		// list instance keys by prefix
+		recorder = httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/revision/gen/"+gen.String()+"/desiredstate/instances?prefix="+url.QueryEscape(codeKey), nil))
+		obj, err = apiCodec.DecodeOne(recorder.Body.Bytes())
+		if !assert.NoError(t, err, "Instance keys should be decoded") {
+			t.FailNow()
+		}
+		assert.Equal(t, []string{codeKey}, obj.(*DesiredStateInstanceKeys).Keys, "Instance keys should be filtered by prefix for revision %s", gen)
+
+		// get a single instance with sensitive parameters redacted
+		recorder = httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/revision/gen/"+gen.String()+"/desiredstate/instance/"+url.PathEscape(codeKey), nil))
+		if !assert.Equal(t, http.StatusOK, recorder.Code, "Instance should be returned: %s", recorder.Body.String()) {
+			t.FailNow()
+		}
+		obj, err = apiCodec.DecodeOne(recorder.Body.Bytes())
+		if !assert.NoError(t, err, "Instance should be decoded") {
+			t.FailNow()
+		}
+		instance := obj.(*resolve.ComponentInstance)
+		assert.Equal(t, codeKey, instance.GetKey(), "Requested instance should be returned for revision %s", gen)
+		assert.Equal(t, "app", instance.CalculatedCodeParams["image"], "Regular parameter should be returned as is")
+		assert.Equal(t, "app", instance.CalculatedCodeParams.GetNestedMap("db")["user"], "Regular nested parameter should be returned as is")
+		assert.Equal(t, redactedValue, instance.CalculatedCodeParams.GetNestedMap("db")["password"], "Sensitive parameter should be redacted")
+
+		// non-existing instance
+		recorder = httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/revision/gen/"+gen.String()+"/desiredstate/instance/unknown", nil))
+		assert.Equal(t, http.StatusNotFound, recorder.Code, "Non-existing instance should not be found")
+	}
+
+	// redaction should not affect stored desired state
+	assert.Equal(t, "s3cr3t", resolution.ComponentInstanceMap[codeKey].CalculatedCodeParams.GetNestedMap("db")["password"], "Desired state should not be modified")
+
+	// non-existing revision
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/revision/gen/42/desiredstate/instances", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code, "Non-existing revision should not be found")
+}
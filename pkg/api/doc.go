@@ -1,3 +1,5 @@
 // Package api implements REST API support for Aptomi, including user-level and admin-level calls.
-// It relies on httprouter to process HTTP requests and serve HTTP responses.
+// It relies on httprouter to process HTTP requests and serve HTTP responses. API server can be embedded into other
+// binaries by constructing it via NewServer with all dependencies provided in Options.
+// Description of all API endpoints along with examples is served at /apidocs (JSON) and /apidocs/index.html (HTML).
 package api
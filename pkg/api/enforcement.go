@@ -0,0 +1,30 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/julienschmidt/httprouter"
+)
+
+func (api *coreAPI) handleEnforcementGet(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	id, err := strconv.ParseUint(params.ByName("id"), 10, 64)
+	if err != nil || id == 0 {
+		serverErr := NewServerError(fmt.Sprintf("invalid enforcement id: %s", params.ByName("id")))
+		api.contentType.WriteOneWithStatus(writer, request, serverErr, http.StatusBadRequest)
+		return
+	}
+
+	enforcement, err := api.registry.GetEnforcement(runtime.Generation(id))
+	if err != nil {
+		panic(fmt.Sprintf("error while getting requested enforcement: %s", err))
+	}
+
+	if enforcement == nil {
+		api.contentType.WriteOneWithStatus(writer, request, nil, http.StatusNotFound)
+	} else {
+		api.contentType.WriteOne(writer, request, enforcement)
+	}
+}
@@ -1,6 +1,34 @@
 package api
 
-import "github.com/Aptomi/aptomi/pkg/runtime"
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+// Error codes returned in ServerError, so clients could tell failures apart without matching error messages
+const (
+	// ErrorCodeInvalidRequest is returned when request can't be parsed (e.g. malformed body or generation)
+	ErrorCodeInvalidRequest = "invalid-request"
+	// ErrorCodeNotFound is returned when requested object doesn't exist
+	ErrorCodeNotFound = "not-found"
+	// ErrorCodeInvalidPolicy is returned when policy objects or policy with them applied are invalid
+	ErrorCodeInvalidPolicy = "invalid-policy"
+	// ErrorCodeForbidden is returned when user isn't allowed to manage policy objects
+	ErrorCodeForbidden = "forbidden"
+	// ErrorCodeConflict is returned when changes conflict with the ones made concurrently
+	ErrorCodeConflict = "conflict"
+	// ErrorCodeClusterPlugin is returned when cluster plugin fails to validate or connect to a cluster
+	ErrorCodeClusterPlugin = "cluster-plugin-failure"
+	// ErrorCodeQueueFull is returned when policy change can't be queued for resolution right now
+	ErrorCodeQueueFull = "queue-full"
+	// ErrorCodeInternal is returned on all other failures
+	ErrorCodeInternal = "internal"
+)
 
 // TypeServerError contains TypeInfo for the Error type
 var TypeServerError = &runtime.TypeInfo{
@@ -11,6 +39,7 @@ var TypeServerError = &runtime.TypeInfo{
 // ServerError represents error that could be returned from the API
 type ServerError struct {
 	runtime.TypeKind `yaml:",inline"`
+	Code             string `yaml:",omitempty"`
 	Error            string
 }
 
@@ -18,3 +47,57 @@ type ServerError struct {
 func NewServerError(error string) *ServerError {
 	return &ServerError{TypeKind: TypeServerError.GetTypeKind(), Error: error}
 }
+
+// RequestError represents failure of handling API request, which gets reported to the client with a given HTTP status
+// and error code
+type RequestError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (err *RequestError) Error() string {
+	return err.Message
+}
+
+// newRequestError returns RequestError with a given status, code and formatted message
+func newRequestError(status int, code string, format string, args ...interface{}) *RequestError {
+	return &RequestError{Status: status, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// ErrorResponse returns ServerError and HTTP status, which should be returned to the client for a given error. Errors
+// other than RequestError and duplicate values of unique fields are reported as internal
+func ErrorResponse(err error) (*ServerError, int) {
+	serverErr := NewServerError(err.Error())
+	if reqErr, ok := err.(*RequestError); ok {
+		serverErr.Code = reqErr.Code
+		return serverErr, reqErr.Status
+	}
+	if store.IsDuplicateValue(err) {
+		// conflicts with other objects are caused by the request itself, so they're reported as such
+		serverErr.Code = ErrorCodeConflict
+		return serverErr, http.StatusConflict
+	}
+	serverErr.Code = ErrorCodeInternal
+	return serverErr, http.StatusInternalServerError
+}
+
+// errorHandle is an API handler, which returns errors instead of panicking, so they get reported with proper status
+type errorHandle func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) error
+
+// withErrors returns handler, which writes the error returned by a given handler as ServerError with corresponding
+// HTTP status
+func (api *coreAPI) withErrors(handle errorHandle) httprouter.Handle {
+	return func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		err := handle(writer, request, params)
+		if err == nil {
+			return
+		}
+
+		serverErr, status := ErrorResponse(err)
+		if status >= http.StatusInternalServerError {
+			log.WithField("request", request).Errorf("Error while serving request: %s", err)
+		}
+		api.contentType.WriteOneWithStatus(writer, request, serverErr, status)
+	}
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/config"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorResponse(t *testing.T) {
+	serverErr, status := ErrorResponse(newRequestError(http.StatusBadRequest, ErrorCodeInvalidPolicy, "policy is %s", "invalid"))
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, ErrorCodeInvalidPolicy, serverErr.Code)
+	assert.Equal(t, "policy is invalid", serverErr.Error)
+
+	_, status = ErrorResponse(&store.ErrDuplicateValue{})
+	assert.Equal(t, http.StatusConflict, status, "Duplicate values of unique fields should be reported as conflicts")
+
+	serverErr, status = ErrorResponse(fmt.Errorf("registry is down"))
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Equal(t, ErrorCodeInternal, serverErr.Code)
+}
+
+func TestPolicyUpdateErrors(t *testing.T) {
+	reg := registry.New(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()))
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	rule := b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+	user := b.AddUser()
+	user.DomainAdmin = true
+
+	// cluster can't be passed through the API, as its plugin is unreachable, but claims need it to get to the bundle
+	_, policyData, err := reg.UpdatePolicy([]lang.Base{cluster, rule}, user.Name)
+	if !assert.NoError(t, err, "Policy should be updated") {
+		t.FailNow()
+	}
+	_, err = reg.NewRevision(policyData.GetGeneration(), resolve.NewPolicyResolution(), false)
+	if !assert.NoError(t, err, "Revision should be created") {
+		t.FailNow()
+	}
+
+	server := NewServer(Options{
+		Registry:     reg,
+		ExternalData: b.External(),
+		PluginRegistryFactory: func() plugin.Registry {
+			return plugin.NewRegistry(
+				config.Plugins{},
+				map[string]plugin.ClusterPluginConstructor{
+					"kubernetes": func(cluster *lang.Cluster, cfg config.Plugins) (plugin.ClusterPlugin, error) {
+						return nil, fmt.Errorf("cluster is unreachable")
+					},
+				},
+				map[string]map[string]plugin.CodePluginConstructor{},
+			)
+		},
+		AuthProvider: &userAuthProvider{user: user},
+	})
+	apiCodec := codec.NewYAMLCodec(runtime.NewTypes().Append(Types...))
+
+	update := func(objects ...runtime.Object) (int, *ServerError) {
+		body, err := apiCodec.EncodeMany(objects)
+		if !assert.NoError(t, err, "Policy objects should be encoded") {
+			t.FailNow()
+		}
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("POST", "/api/v1/policy", bytes.NewReader(body)))
+		obj, err := apiCodec.DecodeOne(recorder.Body.Bytes())
+		if !assert.NoError(t, err, "Error should be decoded") {
+			t.FailNow()
+		}
+		serverErr, ok := obj.(*ServerError)
+		if !assert.True(t, ok, "Error should be returned: %s", recorder.Body.String()) {
+			t.FailNow()
+		}
+		return recorder.Code, serverErr
+	}
+
+	rule.Criteria.RequireAll = []string{"specialname + 'a' +"}
+	status, serverErr := update(rule)
+	assert.Equal(t, http.StatusBadRequest, status, "Invalid policy should be reported as bad request")
+	assert.Equal(t, ErrorCodeInvalidPolicy, serverErr.Code)
+	assert.Contains(t, serverErr.Error, "is not a valid expression", "Validation error should be returned to the client")
+
+	status, serverErr = update(cluster)
+	assert.Equal(t, http.StatusBadGateway, status, "Cluster plugin failure should be reported as bad gateway")
+	assert.Equal(t, ErrorCodeClusterPlugin, serverErr.Code)
+	assert.Contains(t, serverErr.Error, "cluster is unreachable")
+
+	// bundle depending on the service, which allocates the same bundle, and claim running into the cycle
+	bundle := b.AddBundle()
+	service := b.AddService(bundle, b.CriteriaTrue())
+	b.AddBundleComponent(bundle, b.ServiceComponent(service))
+	claimCycle := b.AddClaim(user, service)
+	status, serverErr = update(bundle, service, claimCycle)
+	assert.Equal(t, http.StatusBadRequest, status, "Dependency cycle should be reported as bad request")
+	assert.Equal(t, ErrorCodeInvalidPolicy, serverErr.Code)
+	assert.Contains(t, serverErr.Error, "dependency cycle detected", "Cycle should be returned to the client")
+	assert.Contains(t, serverErr.Error, bundle.Name, "Bundle in the cycle should be named")
+	assert.Contains(t, serverErr.Error, service.Name, "Service in the cycle should be named")
+
+	// claim, which can't be resolved, as context of the service doesn't match
+	bundleUnmatched := b.AddBundle()
+	serviceUnmatched := b.AddService(bundleUnmatched, b.Criteria("label1 == 'value1'", "true", "false"))
+	claim := b.AddClaim(user, serviceUnmatched)
+	status, serverErr = update(bundleUnmatched, serviceUnmatched, claim)
+	assert.Equal(t, http.StatusBadRequest, status, "Claims which can't be resolved should be reported as bad request")
+	assert.Equal(t, ErrorCodeInvalidPolicy, serverErr.Code)
+	assert.Contains(t, serverErr.Error, "1 of 1 claims can't be resolved", "Failed claims should be returned to the client")
+	assert.Contains(t, serverErr.Error, runtime.KeyForStorable(claim), "Failed claim should be named")
+
+	// nothing should be changed by failed updates
+	_, policyGen, err := reg.GetPolicy(runtime.LastOrEmptyGen)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, policyGen, "Failed updates shouldn't change policy")
+}
+
+func TestRequestErrors(t *testing.T) {
+	reg := registry.New(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()))
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	b := builder.NewPolicyBuilder()
+	user := b.AddUser()
+	user.DomainAdmin = true
+
+	server := NewServer(Options{
+		Registry:     reg,
+		ExternalData: b.External(),
+		AuthProvider: &userAuthProvider{user: user},
+	})
+	apiCodec := codec.NewYAMLCodec(runtime.NewTypes().Append(Types...))
+
+	request := func(method string, path string, body []byte) (int, *ServerError) {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(method, path, bytes.NewReader(body)))
+		obj, err := apiCodec.DecodeOne(recorder.Body.Bytes())
+		if !assert.NoError(t, err, "Error should be decoded") {
+			t.FailNow()
+		}
+		serverErr, ok := obj.(*ServerError)
+		if !assert.True(t, ok, "Error should be returned: %s", recorder.Body.String()) {
+			t.FailNow()
+		}
+		return recorder.Code, serverErr
+	}
+
+	status, serverErr := request("POST", "/api/v1/policy", []byte("{not yaml"))
+	assert.Equal(t, http.StatusBadRequest, status, "Malformed request body should be reported as bad request")
+	assert.Equal(t, ErrorCodeInvalidRequest, serverErr.Code)
+
+	status, serverErr = request("GET", "/api/v1/policy/gen/abc", nil)
+	assert.Equal(t, http.StatusBadRequest, status, "Invalid generation should be reported as bad request")
+	assert.Equal(t, ErrorCodeInvalidRequest, serverErr.Code)
+
+	status, serverErr = request("GET", "/api/v1/policy/gen/42", nil)
+	assert.Equal(t, http.StatusNotFound, status, "Missing policy generation should not be found")
+	assert.Equal(t, ErrorCodeNotFound, serverErr.Code)
+
+	status, serverErr = request("GET", "/api/v1/policy/gen/1/object/main/bundle/missing", nil)
+	assert.Equal(t, http.StatusNotFound, status, "Missing policy object should not be found")
+	assert.Equal(t, ErrorCodeNotFound, serverErr.Code)
+
+	status, serverErr = request("POST", "/api/v1/policy/rollback/42", nil)
+	assert.Equal(t, http.StatusNotFound, status, "Rollback to missing policy generation should not be found")
+	assert.Equal(t, ErrorCodeNotFound, serverErr.Code)
+}
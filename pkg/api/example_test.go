@@ -0,0 +1,132 @@
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/api"
+	"github.com/Aptomi/aptomi/pkg/client/rest"
+	resthttp "github.com/Aptomi/aptomi/pkg/client/rest/http"
+	"github.com/Aptomi/aptomi/pkg/config"
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+	"github.com/Aptomi/aptomi/pkg/plugin/fake"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/Aptomi/aptomi/pkg/util"
+	"github.com/sirupsen/logrus"
+)
+
+// staticAuthProvider authenticates all requests as the same user
+type staticAuthProvider struct {
+	user *lang.User
+}
+
+func (provider *staticAuthProvider) NewToken(user *lang.User) (string, error) {
+	return user.Name, nil
+}
+
+func (provider *staticAuthProvider) VerifyToken(request *http.Request) (string, error) {
+	return provider.user.Name, nil
+}
+
+// This example embeds API server with in-memory store and noop plugins, applies policy via REST client and waits
+// until the corresponding revision gets enforced
+func ExampleNewServer() {
+	// registry on top of in-memory store
+	reg := registry.New(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()))
+	if err := reg.InitPolicy(); err != nil {
+		panic(err)
+	}
+
+	// policy with a single claim for a service with a single code component
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(util.NestedParameterMap{"param": "value"}, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	rule := b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+	user := b.AddUser()
+	claim := b.AddClaim(user, service)
+
+	// noop plugins for kubernetes clusters
+	pluginRegistryFactory := func() plugin.Registry {
+		clusterTypes := map[string]plugin.ClusterPluginConstructor{
+			"kubernetes": func(cluster *lang.Cluster, cfg config.Plugins) (plugin.ClusterPlugin, error) {
+				return fake.NewNoOpClusterPlugin(0), nil
+			},
+		}
+		codeTypes := map[string]map[string]plugin.CodePluginConstructor{
+			"kubernetes": {
+				"helm": func(cluster plugin.ClusterPlugin, cfg config.Plugins) (plugin.CodePlugin, error) {
+					return fake.NewNoOpCodePlugin(0), nil
+				},
+			},
+		}
+		return plugin.NewRegistry(config.Plugins{}, clusterTypes, codeTypes)
+	}
+
+	server := api.NewServer(api.Options{
+		Registry:              reg,
+		ExternalData:          b.External(),
+		PluginRegistryFactory: pluginRegistryFactory,
+		AuthProvider:          &staticAuthProvider{user: user},
+		EnforcerInterval:      100 * time.Millisecond,
+	})
+
+	// serve API and run enforcement in background
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.Run(ctx) // nolint: errcheck
+
+	// apply policy via REST client
+	serverURL, err := url.Parse(httpServer.URL)
+	if err != nil {
+		panic(err)
+	}
+	port, err := strconv.Atoi(serverURL.Port())
+	if err != nil {
+		panic(err)
+	}
+	cfg := &config.Client{
+		API:  config.API{Schema: "http", Host: serverURL.Hostname(), Port: port, APIPrefix: "api/v1"},
+		Auth: config.ClientAuth{Token: user.Name},
+		HTTP: config.HTTP{Timeout: 10 * time.Second},
+	}
+	client := rest.New(cfg, resthttp.NewClient(cfg))
+
+	result, err := client.Policy().Apply([]runtime.Object{cluster, bundle, service, rule, claim}, false, logrus.WarnLevel, false)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Policy changed: %t\n", result.PolicyChanged)
+
+	// wait for the revision to be enforced
+	for {
+		revision, err := client.Revision().Show(result.WaitForRevision)
+		if err != nil {
+			panic(err)
+		}
+		if revision.Status == engine.RevisionStatusCompleted {
+			fmt.Printf("Revision status: %s, failed actions: %d\n", revision.Status, revision.Result.Failed)
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// Output:
+	// Policy changed: true
+	// Revision status: completed, failed actions: 0
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/config"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+	"github.com/Aptomi/aptomi/pkg/plugin/fake"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/Aptomi/aptomi/pkg/util"
+)
+
+// exampleRequest is a canned API request for one of the main API flows
+type exampleRequest struct {
+	title   string
+	method  string
+	path    string // route the example belongs to
+	url     string
+	body    []runtime.Object
+	enforce bool // run desired state enforcement after the request
+}
+
+// examplePolicy is the same policy fixture as unit tests use: a single claim for a service with a single code component
+type examplePolicy struct {
+	builder *builder.PolicyBuilder
+	user    *lang.User
+	objects []runtime.Object
+	claim   *lang.Claim
+}
+
+func newExamplePolicy() *examplePolicy {
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(util.NestedParameterMap{"param": "value"}, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	rule := b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+	user := b.AddUser()
+	claim := b.AddClaim(user, service)
+
+	return &examplePolicy{
+		builder: b,
+		user:    user,
+		objects: []runtime.Object{cluster, bundle, service, rule, claim},
+		claim:   claim,
+	}
+}
+
+// requests returns example requests for the main API flows (policy noop, policy apply, claim status), which have
+// to be executed in order
+func (policy *examplePolicy) requests() []*exampleRequest {
+	return []*exampleRequest{
+		{
+			title:  "Policy update in noop mode",
+			method: "POST",
+			path:   "/api/v1/policy/noop/:noop/loglevel/:loglevel",
+			url:    "/api/v1/policy/noop/true/loglevel/warning",
+			body:   policy.objects,
+		},
+		{
+			title:   "Policy apply",
+			method:  "POST",
+			path:    "/api/v1/policy",
+			url:     "/api/v1/policy",
+			body:    policy.objects,
+			enforce: true,
+		},
+		{
+			title:  "Claim status",
+			method: "GET",
+			path:   "/api/v1/policy/claim/status/:queryFlag/:idList",
+			url:    fmt.Sprintf("/api/v1/policy/claim/status/%s/%s^%s", ClaimQueryDeploymentStatusOnly, policy.claim.Namespace, policy.claim.Name),
+		},
+	}
+}
+
+// exampleAuthProvider authenticates all requests as the user from the example policy
+type exampleAuthProvider struct {
+	user *lang.User
+}
+
+func (provider *exampleAuthProvider) NewToken(user *lang.User) (string, error) {
+	return user.Name, nil
+}
+
+func (provider *exampleAuthProvider) VerifyToken(request *http.Request) (string, error) {
+	return provider.user.Name, nil
+}
+
+// newExampleServer creates a sandbox API server with in-memory store and noop plugins for a given policy
+func newExampleServer(policy *examplePolicy) (*Server, error) {
+	reg := registry.New(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()))
+	if err := reg.InitPolicy(); err != nil {
+		return nil, fmt.Errorf("error while initializing policy: %s", err)
+	}
+
+	pluginRegistryFactory := func() plugin.Registry {
+		clusterTypes := map[string]plugin.ClusterPluginConstructor{
+			"kubernetes": func(cluster *lang.Cluster, cfg config.Plugins) (plugin.ClusterPlugin, error) {
+				return fake.NewNoOpClusterPlugin(0), nil
+			},
+		}
+		codeTypes := map[string]map[string]plugin.CodePluginConstructor{
+			"kubernetes": {
+				"helm": func(cluster plugin.ClusterPlugin, cfg config.Plugins) (plugin.CodePlugin, error) {
+					return fake.NewNoOpCodePlugin(0), nil
+				},
+			},
+		}
+		return plugin.NewRegistry(config.Plugins{}, clusterTypes, codeTypes)
+	}
+
+	return NewServer(Options{
+		Registry:              reg,
+		ExternalData:          policy.builder.External(),
+		PluginRegistryFactory: pluginRegistryFactory,
+		AuthProvider:          &exampleAuthProvider{user: policy.user},
+	}), nil
+}
+
+// generateExamples executes example requests against a sandbox API server and returns them along with the
+// responses, grouped by route ID. Examples are always produced by the real handlers, so they can't go stale
+func generateExamples() (map[string][]*RouteExample, error) {
+	policy := newExamplePolicy()
+	server, err := newExampleServer(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	cod := codec.NewYAMLCodec(runtime.NewTypes().Append(Types...))
+	result := make(map[string][]*RouteExample)
+	for _, req := range policy.requests() {
+		var body []byte
+		if len(req.body) > 0 {
+			body, err = cod.EncodeMany(req.body)
+			if err != nil {
+				return nil, fmt.Errorf("error while encoding example request '%s': %s", req.title, err)
+			}
+		}
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(req.method, req.url, bytes.NewReader(body)))
+		if recorder.Code != http.StatusOK {
+			return nil, fmt.Errorf("example request '%s' failed with status %d: %s", req.title, recorder.Code, recorder.Body.String())
+		}
+
+		id := routeID(req.method, req.path)
+		result[id] = append(result[id], &RouteExample{
+			Title:          req.title,
+			Request:        req.method + " " + req.url,
+			RequestBody:    string(body),
+			ResponseStatus: recorder.Code,
+			ResponseBody:   recorder.Body.String(),
+		})
+
+		if req.enforce {
+			if _, err := server.enforcer.Enforce(context.Background()); err != nil {
+				return nil, fmt.Errorf("error while enforcing desired state after example request '%s': %s", req.title, err)
+			}
+		}
+	}
+
+	return result, nil
+}
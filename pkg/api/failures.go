@@ -0,0 +1,62 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Aptomi/aptomi/pkg/engine/apply/chaos"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+// TypeFailureInjection is an informational data structure with Kind and Constructor for FailureInjection
+var TypeFailureInjection = &runtime.TypeInfo{
+	Kind:        "failure-injection",
+	Constructor: func() runtime.Object { return &FailureInjection{} },
+}
+
+// FailureInjection is a set of rules for injecting failures into actions applied by desired state enforcer. It's only
+// available when failure injection is explicitly enabled in server config
+type FailureInjection struct {
+	runtime.TypeKind `yaml:",inline"`
+	Rules            []*chaos.Rule
+}
+
+func (api *coreAPI) handleFailureInjectionGet(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	api.checkFailureInjectionEnabled(request, "see failure injection rules")
+
+	api.contentType.WriteOne(writer, request, &FailureInjection{
+		TypeKind: TypeFailureInjection.GetTypeKind(),
+		Rules:    api.failureInjector.GetRules(),
+	})
+}
+
+func (api *coreAPI) handleFailureInjectionUpdate(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	api.checkFailureInjectionEnabled(request, "change failure injection rules")
+
+	failureInjection, ok := api.contentType.ReadOne(request).(*FailureInjection)
+	if !ok {
+		panic(fmt.Sprintf("Unexpected object received: %v", failureInjection))
+	}
+
+	err := api.failureInjector.SetRules(failureInjection.Rules)
+	if err != nil {
+		panic(fmt.Sprintf("invalid failure injection rules: %s", err))
+	}
+	log.Warnf("Failure injection rules have been set by %s: %d rules", api.getUserRequired(request).Name, len(failureInjection.Rules))
+
+	api.contentType.WriteOne(writer, request, &FailureInjection{
+		TypeKind: TypeFailureInjection.GetTypeKind(),
+		Rules:    api.failureInjector.GetRules(),
+	})
+}
+
+// checkFailureInjectionEnabled panics if failure injection isn't enabled or the user making a given request is not
+// a domain admin
+func (api *coreAPI) checkFailureInjectionEnabled(request *http.Request, action string) {
+	if api.failureInjector == nil {
+		panic("failure injection is disabled in server config")
+	}
+	api.checkDomainAdmin(request, action)
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+// TypeGCResult is an informational data structure with Kind and Constructor for GCResult
+var TypeGCResult = &runtime.TypeInfo{
+	Kind:        "gc-result",
+	Constructor: func() runtime.Object { return &GCResult{} },
+}
+
+// GCResult represents result of garbage collection of old generations of revisions, policies and policy objects
+type GCResult struct {
+	runtime.TypeKind `yaml:",inline"`
+
+	// Removed is the number of removed object keys (generations) by kind
+	Removed map[runtime.Kind]int
+
+	// Total is the total number of removed object keys
+	Total int
+}
+
+func (api *coreAPI) handleGarbageCollection(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	api.checkDomainAdmin(request, "collect garbage")
+
+	result, err := api.registry.CollectGarbage(api.gcRetention, api.clock.Now())
+	if err != nil {
+		panic(fmt.Sprintf("error while collecting garbage: %s", err))
+	}
+	log.Infof("Garbage collection requested by %s removed %d old object generations", api.getUserRequired(request).Name, result.Total())
+
+	api.contentType.WriteOne(writer, request, &GCResult{
+		TypeKind: TypeGCResult.GetTypeKind(),
+		Removed:  result.Removed,
+		Total:    result.Total(),
+	})
+}
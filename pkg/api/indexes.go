@@ -0,0 +1,59 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+// TypeIndexRebuildResult is an informational data structure with Kind and Constructor for IndexRebuildResult
+var TypeIndexRebuildResult = &runtime.TypeInfo{
+	Kind:        "index-rebuild-result",
+	Constructor: func() runtime.Object { return &IndexRebuildResult{} },
+}
+
+// IndexRebuildResult represents result of checking and repairing indexes of all versioned objects
+type IndexRebuildResult struct {
+	runtime.TypeKind `yaml:",inline"`
+
+	// DryRun is true if inconsistencies were only reported without repairing them
+	DryRun bool
+
+	// Kinds is the result of rebuilding indexes by kind
+	Kinds map[runtime.Kind]*store.IndexRebuildResult
+
+	// Inconsistent is the total number of missing, divergent and dangling index entries
+	Inconsistent int
+}
+
+// handleIndexRebuild checks indexes of all versioned objects and repairs them unless ?dryRun=true is set
+func (api *coreAPI) handleIndexRebuild(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	api.checkDomainAdmin(request, "rebuild indexes")
+
+	dryRun := request.URL.Query().Get("dryRun") == "true"
+	kinds, err := api.registry.RebuildIndexes(dryRun)
+	if err != nil {
+		panic(fmt.Sprintf("error while rebuilding indexes: %s", err))
+	}
+
+	inconsistent := 0
+	for _, result := range kinds {
+		inconsistent += result.Added + result.Updated + result.Removed
+	}
+	if dryRun {
+		log.Infof("Index check requested by %s found %d inconsistent index entries", api.getUserRequired(request).Name, inconsistent)
+	} else {
+		log.Infof("Index rebuild requested by %s repaired %d inconsistent index entries", api.getUserRequired(request).Name, inconsistent)
+	}
+
+	api.contentType.WriteOne(writer, request, &IndexRebuildResult{
+		TypeKind:     TypeIndexRebuildResult.GetTypeKind(),
+		DryRun:       dryRun,
+		Kinds:        kinds,
+		Inconsistent: inconsistent,
+	})
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/julienschmidt/httprouter"
+)
+
+// TypeInstanceConsumers is an informational data structure with Kind and Constructor for InstanceConsumers
+var TypeInstanceConsumers = &runtime.TypeInfo{
+	Kind:        "instance-consumers",
+	Constructor: func() runtime.Object { return &InstanceConsumers{} },
+}
+
+// InstanceConsumers represents consumers (claims and dependent component instances) of a given component instance in
+// both desired and actual states. It's useful for debugging why component instance is (or isn't) getting deleted
+type InstanceConsumers struct {
+	runtime.TypeKind `yaml:",inline"`
+	Key              string
+	Desired          *resolve.ComponentInstanceConsumers
+	Actual           *resolve.ComponentInstanceConsumers
+}
+
+func (api *coreAPI) handleInstanceConsumersGet(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	key := params.ByName("key")
+
+	// load the latest policy
+	_, policyGen, err := api.registry.GetPolicy(runtime.LastOrEmptyGen)
+	if err != nil {
+		panic(fmt.Sprintf("error while loading latest policy from the registry: %s", err))
+	}
+
+	// load the latest revision for the given policy
+	revision, err := api.registry.GetLastRevisionForPolicy(policyGen)
+	if err != nil {
+		panic(fmt.Sprintf("error while loading latest revision from the registry: %s", err))
+	}
+
+	// load desired state
+	desiredState, err := api.registry.GetDesiredState(revision)
+	if err != nil {
+		panic(fmt.Sprintf("can't load desired state from revision: %s", err))
+	}
+
+	// load actual state
+	actualState, err := api.registry.GetActualState()
+	if err != nil {
+		panic(fmt.Sprintf("can't load actual state from the registry: %s", err))
+	}
+
+	result := &InstanceConsumers{
+		TypeKind: TypeInstanceConsumers.GetTypeKind(),
+		Key:      key,
+		Desired:  desiredState.GetConsumers(key),
+		Actual:   actualState.GetConsumers(key),
+	}
+
+	if !result.Desired.Found && !result.Actual.Found {
+		api.contentType.WriteOneWithStatus(writer, request, nil, http.StatusNotFound)
+		return
+	}
+
+	api.contentType.WriteOne(writer, request, result)
+}
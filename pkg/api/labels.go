@@ -0,0 +1,40 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/julienschmidt/httprouter"
+)
+
+// TypeLabelUsageReport is an informational data structure with Kind and Constructor for LabelUsageReport
+var TypeLabelUsageReport = &runtime.TypeInfo{
+	Kind:        "label-usage-report",
+	Constructor: func() runtime.Object { return &LabelUsageReport{} },
+}
+
+// LabelUsageReport shows which policy objects use which labels, as well as labels referenced from the policy which
+// none of the users has, and user labels which aren't used by the policy
+type LabelUsageReport struct {
+	runtime.TypeKind `yaml:",inline"`
+	PolicyGeneration runtime.Generation
+	Usage            *lang.LabelUsage
+	Warnings         []string
+}
+
+func (api *coreAPI) handleLabelUsageGet(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	policy, policyGen, err := api.registry.GetPolicy(runtime.LastOrEmptyGen)
+	if err != nil {
+		panic(fmt.Sprintf("error while getting requested policy: %s", err))
+	}
+
+	usage := lang.NewLabelUsage(policy, api.externalData.UserLoader.LoadUsersAll())
+	api.contentType.WriteOne(writer, request, &LabelUsageReport{
+		TypeKind:         TypeLabelUsageReport.GetTypeKind(),
+		PolicyGeneration: policyGen,
+		Usage:            usage,
+		Warnings:         usage.Warnings(),
+	})
+}
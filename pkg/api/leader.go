@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/julienschmidt/httprouter"
+	"github.com/sirupsen/logrus"
+)
+
+// campaignRetryInterval is how long to wait before campaigning again after campaign failed (e.g. store isn't available)
+var campaignRetryInterval = 5 * time.Second
+
+// TypeLeaderStatus is an informational data structure with Kind and Constructor for LeaderStatus
+var TypeLeaderStatus = &runtime.TypeInfo{
+	Kind:        "leader-status",
+	Constructor: func() runtime.Object { return &LeaderStatus{} },
+}
+
+// LeaderStatus shows which of the servers sharing the same registry is the leader running desired state enforcement
+type LeaderStatus struct {
+	runtime.TypeKind `yaml:",inline"`
+
+	// ElectionEnabled is false if leader election isn't used, so every server runs enforcement
+	ElectionEnabled bool
+
+	// Leader is the identity of the current leader, it's empty if there is no leader at the moment
+	Leader string `yaml:",omitempty"`
+
+	// Identity is the identity of the server which served the request
+	Identity string `yaml:",omitempty"`
+
+	// IsLeader is true if the server which served the request is the leader
+	IsLeader bool
+}
+
+// GetDefaultColumns returns default set of columns to be displayed
+func (status *LeaderStatus) GetDefaultColumns() []string {
+	return []string{"Leader", "Served By", "Is Leader"}
+}
+
+// AsColumns returns LeaderStatus representation as columns
+func (status *LeaderStatus) AsColumns() map[string]string {
+	leader := status.Leader
+	if !status.ElectionEnabled {
+		leader = "(election disabled)"
+	} else if len(leader) == 0 {
+		leader = "(none)"
+	}
+	return map[string]string{
+		"Leader":    leader,
+		"Served By": status.Identity,
+		"Is Leader": fmt.Sprintf("%t", status.IsLeader),
+	}
+}
+
+// enforceWhileLeader campaigns for leadership and does desired state enforcement while this server is the leader,
+// until the context is cancelled. Once leadership is lost, enforcement gets interrupted and server campaigns again
+func (server *Server) enforceWhileLeader(ctx context.Context) error {
+	election := server.api.election
+	for {
+		logrus.Infof("Campaigning for leadership as %s", election.Identity())
+		leaderCtx, err := election.Campaign(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logrus.Errorf("error while campaigning for leadership: %s", err)
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(campaignRetryInterval):
+			}
+			continue
+		}
+
+		// drop signals received while being a follower, as enforcement runs right away anyway
+		server.api.drainEnforcementTriggers()
+
+		logrus.Infof("Became the leader as %s, starting desired state enforcement", election.Identity())
+		_ = server.enforce(leaderCtx) // nolint: errcheck
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		logrus.Warnf("Lost leadership as %s, desired state enforcement stopped", election.Identity())
+	}
+}
+
+// drainEnforcementTriggers removes all pending enforcement signals
+func (api *coreAPI) drainEnforcementTriggers() {
+	for {
+		select {
+		case <-api.runDesiredStateEnforcement:
+		default:
+			return
+		}
+	}
+}
+
+func (api *coreAPI) handleLeaderGet(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	status := &LeaderStatus{
+		TypeKind:        TypeLeaderStatus.GetTypeKind(),
+		ElectionEnabled: api.election != nil,
+	}
+
+	if api.election != nil {
+		leader, err := api.election.Leader(request.Context())
+		if err != nil {
+			panic(fmt.Sprintf("error while getting current leader: %s", err))
+		}
+		status.Leader = leader
+		status.Identity = api.election.Identity()
+		status.IsLeader = leader == status.Identity
+	}
+
+	api.contentType.WriteOne(writer, request, status)
+}
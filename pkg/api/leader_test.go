@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnforcementOnlyWhileLeader(t *testing.T) {
+	b := builder.NewPolicyBuilder()
+	user := b.AddUser()
+	election := &fakeElection{identity: "server-1", grant: make(chan bool)}
+	enforcer := &fakeEnforcer{calls: make(chan context.Context, 100)}
+
+	server := NewServer(Options{
+		Registry:         registry.New(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec())),
+		ExternalData:     b.External(),
+		AuthProvider:     &userAuthProvider{user: user},
+		Enforcer:         enforcer,
+		EnforcerInterval: time.Hour,
+		Election:         election,
+	})
+
+	getStatus := func() *LeaderStatus {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/state/leader", nil))
+		if !assert.Equal(t, http.StatusOK, recorder.Code, "Leader status should be returned: %s", recorder.Body.String()) {
+			t.FailNow()
+		}
+		obj, err := codec.NewYAMLCodec(runtime.NewTypes().Append(Types...)).DecodeOne(recorder.Body.Bytes())
+		if !assert.NoError(t, err, "Leader status should be decoded") {
+			t.FailNow()
+		}
+		return obj.(*LeaderStatus)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.Run(ctx) // nolint: errcheck
+
+	// followers keep accepting enforcement signals, while not enforcing anything
+	for i := 0; i < 3000; i++ {
+		server.api.triggerEnforcement()
+	}
+	select {
+	case <-enforcer.calls:
+		t.Fatal("Enforcement shouldn't run before becoming the leader")
+	case <-time.After(100 * time.Millisecond):
+	}
+	status := getStatus()
+	assert.True(t, status.ElectionEnabled)
+	assert.False(t, status.IsLeader, "Server shouldn't be the leader before winning election")
+
+	// leader enforces right away
+	election.grant <- true
+	var leaderCtx context.Context
+	select {
+	case leaderCtx = <-enforcer.calls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Enforcement should run once becoming the leader")
+	}
+	status = getStatus()
+	assert.Equal(t, "server-1", status.Leader)
+	assert.True(t, status.IsLeader, "Server should be the leader after winning election")
+
+	// once leadership is lost, enforcement gets interrupted and server campaigns again
+	election.lose()
+	select {
+	case <-leaderCtx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Enforcement should be interrupted once leadership is lost")
+	}
+	server.api.triggerEnforcement()
+	select {
+	case <-enforcer.calls:
+		t.Fatal("Enforcement shouldn't run after leadership is lost")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// new leadership leads to enforcement again
+	election.grant <- true
+	select {
+	case <-enforcer.calls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Enforcement should run once becoming the leader again")
+	}
+}
+
+// fakeElection grants leadership once signaled via grant channel
+type fakeElection struct {
+	identity string
+	grant    chan bool
+
+	mutex  sync.Mutex
+	leader string
+	cancel context.CancelFunc
+}
+
+func (e *fakeElection) Campaign(ctx context.Context) (context.Context, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-e.grant:
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	leaderCtx, cancel := context.WithCancel(ctx)
+	e.leader = e.identity
+	e.cancel = cancel
+	return leaderCtx, nil
+}
+
+func (e *fakeElection) lose() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.leader = ""
+	e.cancel()
+}
+
+func (e *fakeElection) Leader(ctx context.Context) (string, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.leader, nil
+}
+
+func (e *fakeElection) Identity() string {
+	return e.identity
+}
+
+// fakeEnforcer records contexts of all enforcements
+type fakeEnforcer struct {
+	calls chan context.Context
+}
+
+func (enforcer *fakeEnforcer) Enforce(ctx context.Context) (bool, error) {
+	enforcer.calls <- ctx
+	return false, nil
+}
@@ -14,8 +14,9 @@ type prometheusHandler struct {
 	responseSize *prometheus.HistogramVec
 }
 
-// NewMetricsHandler returns middleware that collects HTTP req/resp specific metrics
-func NewMetricsHandler(svcName string, handler http.Handler) http.Handler {
+// NewMetricsHandler returns middleware that collects HTTP req/resp specific metrics and registers them with a given
+// registerer
+func NewMetricsHandler(svcName string, registerer prometheus.Registerer, handler http.Handler) http.Handler {
 	requests := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name:        "http_requests_total",
@@ -24,7 +25,7 @@ func NewMetricsHandler(svcName string, handler http.Handler) http.Handler {
 		},
 		[]string{"code", "method", "path"},
 	)
-	prometheus.MustRegister(requests)
+	registerer.MustRegister(requests)
 
 	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name:        "http_request_duration_seconds",
@@ -34,7 +35,7 @@ func NewMetricsHandler(svcName string, handler http.Handler) http.Handler {
 	},
 		[]string{"code", "method", "path"},
 	)
-	prometheus.MustRegister(duration)
+	registerer.MustRegister(duration)
 
 	responseSize := prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name:        "http_response_size_bytes",
@@ -44,7 +45,7 @@ func NewMetricsHandler(svcName string, handler http.Handler) http.Handler {
 	},
 		[]string{"code", "method", "path"},
 	)
-	prometheus.MustRegister(responseSize)
+	registerer.MustRegister(responseSize)
 
 	return &prometheusHandler{
 		handler:      handler,
@@ -91,3 +92,10 @@ func (w *infoResponseWriter) WriteHeader(status int) {
 	w.ResponseWriter.WriteHeader(status)
 	w.status = status
 }
+
+// Flush sends buffered data to the client, so streaming responses aren't held back by the metrics wrapper
+func (w *infoResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/api"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsPerServer(t *testing.T) {
+	b := builder.NewPolicyBuilder()
+
+	// every server has its own metrics, so they don't clash with each other
+	for _, name := range []string{"first", "second"} {
+		metrics := prometheus.NewRegistry()
+		s := store.WithMetrics(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()), metrics)
+		server := api.NewServer(api.Options{
+			Registry:     registry.New(s),
+			ExternalData: b.External(),
+			Metrics:      metrics,
+		})
+		handler := NewMetricsHandler(name, metrics, server)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/version", nil))
+		assert.Equal(t, http.StatusOK, recorder.Code, "Version should be returned: %s", recorder.Body.String())
+
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+		if assert.Equal(t, http.StatusOK, recorder.Code, "Metrics should be returned: %s", recorder.Body.String()) {
+			assert.Contains(t, recorder.Body.String(), `http_requests_total{code="OK",method="GET",path="/api/v1/version",service="`+name+`"} 1`, "Metrics of the server should be served")
+			assert.Contains(t, recorder.Body.String(), "go_goroutines", "Globally registered metrics should be served")
+		}
+	}
+}
@@ -31,9 +31,14 @@ func (h *panicHandler) ServeHTTP(writer http.ResponseWriter, request *http.Reque
 				log.Debug(string(debug.Stack()))
 			}
 
-			serverErr := api.NewServerError(fmt.Sprintf("%s", err))
+			// typed errors get the same status as if they were returned by the handler
+			typedErr, ok := err.(error)
+			if !ok {
+				typedErr = fmt.Errorf("%s", err)
+			}
+			serverErr, status := api.ErrorResponse(typedErr)
 
-			h.contentType.WriteOneWithStatus(writer, request, serverErr, http.StatusInternalServerError)
+			h.contentType.WriteOneWithStatus(writer, request, serverErr, status)
 		}
 	}()
 
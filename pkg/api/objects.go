@@ -11,10 +11,25 @@ var (
 	// Types is a list of all objects used in API
 	Types = runtime.AppendAllTypes([]*runtime.TypeInfo{
 		TypeClaimsStatus,
+		TypeClaimResolutionResult,
+		TypeInstanceConsumers,
+		TypeDesiredStateInstanceKeys,
 		TypePolicyUpdateResult,
+		TypePolicyDiffResult,
+		TypePolicyValidationResult,
+		TypePolicyHistory,
+		TypeCapacitySimulationResult,
+		TypeLabelUsageReport,
+		TypeFailureInjection,
+		TypeEnforcementStatus,
+		TypeLeaderStatus,
+		TypeGCResult,
+		TypeRestoreResult,
+		TypeIndexRebuildResult,
 		TypeAuthSuccess,
 		TypeAuthRequest,
 		TypeServerError,
+		TypeAPIDocs,
 		version.TypeBuildInfo,
 	}, lang.PolicyTypes, engine.Types)
 )
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+// handleObjectsScan streams all objects of a given non-versioned kind as NDJSON, one object per line. Objects are read
+// from the store in batches and written into the response as they come, so memory usage doesn't depend on the number
+// of objects
+func (api *coreAPI) handleObjectsScan(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	api.checkDomainAdmin(request, "list objects")
+
+	kind := params.ByName("kind")
+	info := registry.GetObjectType(kind)
+	if info == nil {
+		api.contentType.WriteOneWithStatus(writer, request, NewServerError(fmt.Sprintf("unknown kind '%s'", kind)), http.StatusNotFound)
+		return
+	}
+	if info.Versioned {
+		api.contentType.WriteOneWithStatus(writer, request, NewServerError(fmt.Sprintf("objects of versioned kind '%s' can't be listed", kind)), http.StatusBadRequest)
+		return
+	}
+
+	stream := api.contentType.NewNDJSONWriter(writer, http.StatusOK)
+	err := api.registry.ScanObjects(kind, stream.Write)
+	if err != nil {
+		// response status has been sent already, so the stream just gets terminated and client will see an incomplete
+		// result (without the trailing newline in most cases)
+		log.Errorf("Error while streaming objects of kind %s after %d objects: %s", kind, stream.Written(), err)
+		return
+	}
+	stream.Flush()
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectsScan(t *testing.T) {
+	st := memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec())
+	reg := registry.New(st)
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	// lots of non-versioned objects, more than a single flush batch
+	count := 1234
+	for i := 0; i < count; i++ {
+		_, err := st.Save(engine.NewActionMarker(runtime.FirstGen, fmt.Sprintf("action-%04d", i), "hash"))
+		if !assert.NoError(t, err, "Action marker should be saved") {
+			t.FailNow()
+		}
+	}
+
+	b := builder.NewPolicyBuilder()
+	user := b.AddUser()
+	user.DomainAdmin = true
+	server := NewServer(Options{Registry: reg, ExternalData: b.External(), AuthProvider: &userAuthProvider{user: user}})
+	jsonCodec := codec.NewJSONCodec(runtime.NewTypes().Append(Types...))
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/admin/objects/"+engine.TypeActionMarker.Kind, nil))
+	if !assert.Equal(t, http.StatusOK, recorder.Code, "Objects should be streamed: %s", recorder.Body.String()) {
+		t.FailNow()
+	}
+	assert.Equal(t, codec.NDJSON, recorder.Header().Get("Content-Type"), "Objects should be streamed as NDJSON")
+
+	// every line should be a valid json object
+	actionKeys := make(map[string]bool)
+	lines := 0
+	scanner := bufio.NewScanner(recorder.Body)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		lines++
+		var raw map[string]interface{}
+		if !assert.NoError(t, json.Unmarshal(line, &raw), "Line should be a valid json: %s", line) {
+			t.FailNow()
+		}
+		obj, err := jsonCodec.DecodeOne(line)
+		if !assert.NoError(t, err, "Line should be decoded: %s", line) {
+			t.FailNow()
+		}
+		actionKeys[obj.(*engine.ActionMarker).ActionKey] = true
+	}
+	assert.NoError(t, scanner.Err(), "Response should be read")
+	assert.Equal(t, count, lines, "Every object should be streamed as a single line")
+	assert.Len(t, actionKeys, count, "All objects should be streamed exactly once")
+	assert.True(t, actionKeys["action-0000"], "First object should be streamed")
+	assert.True(t, actionKeys[fmt.Sprintf("action-%04d", count-1)], "Last object should be streamed")
+
+	// versioned and unknown kinds
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/admin/objects/"+engine.TypePolicyData.Kind, nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code, "Versioned kind should be rejected")
+
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/admin/objects/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code, "Unknown kind should not be found")
+
+	// only domain admins are allowed to list objects
+	user.DomainAdmin = false
+	assert.Panics(t, func() {
+		server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/admin/objects/"+engine.TypeActionMarker.Kind, nil))
+	}, "Non-admin user should not be allowed to list objects")
+}
@@ -1,12 +1,13 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"net/http"
-	"strconv"
-
 	"sort"
+	"strconv"
 
+	"github.com/Aptomi/aptomi/pkg/api/admission"
 	"github.com/Aptomi/aptomi/pkg/engine"
 	"github.com/Aptomi/aptomi/pkg/engine/apply/action"
 	"github.com/Aptomi/aptomi/pkg/engine/diff"
@@ -14,55 +15,61 @@ import (
 	"github.com/Aptomi/aptomi/pkg/event"
 	"github.com/Aptomi/aptomi/pkg/lang"
 	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
 	"github.com/julienschmidt/httprouter"
 	"github.com/sirupsen/logrus"
 )
 
-func (api *coreAPI) handlePolicyGet(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
-	gen := params.ByName("gen")
-
-	if len(gen) == 0 {
-		gen = strconv.Itoa(int(runtime.LastOrEmptyGen))
+func (api *coreAPI) handlePolicyGet(writer http.ResponseWriter, request *http.Request, params httprouter.Params) error {
+	gen, err := parseGenParam(params, "gen")
+	if err != nil {
+		return err
 	}
 
-	policyData, err := api.registry.GetPolicyData(runtime.ParseGeneration(gen))
+	policyData, err := api.registry.GetPolicyData(gen)
 	if err != nil {
-		panic(fmt.Sprintf("error while getting requested policy: %s", err))
+		return fmt.Errorf("error while getting requested policy: %s", err)
 	}
-
 	if policyData == nil {
-		// policy with the given generation not found
-		api.contentType.WriteOneWithStatus(writer, request, nil, http.StatusNotFound)
-	} else {
-		api.contentType.WriteOne(writer, request, policyData)
+		return newRequestError(http.StatusNotFound, ErrorCodeNotFound, "policy generation %s doesn't exist or was compacted", gen)
 	}
-}
 
-func (api *coreAPI) handlePolicyObjectGet(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
-	gen := params.ByName("gen")
+	api.contentType.WriteOne(writer, request, policyData)
+	return nil
+}
 
-	if len(gen) == 0 {
-		gen = strconv.Itoa(int(runtime.LastOrEmptyGen))
+func (api *coreAPI) handlePolicyObjectGet(writer http.ResponseWriter, request *http.Request, params httprouter.Params) error {
+	gen, err := parseGenParam(params, "gen")
+	if err != nil {
+		return err
 	}
 
-	policy, _, err := api.registry.GetPolicy(runtime.ParseGeneration(gen))
+	policy, _, err := api.registry.GetPolicy(gen)
 	if err != nil {
-		panic(fmt.Sprintf("error while getting requested policy: %s", err))
+		return fmt.Errorf("error while getting requested policy: %s", err)
+	}
+	if policy == nil {
+		return newRequestError(http.StatusNotFound, ErrorCodeNotFound, "policy generation %s doesn't exist or was compacted", gen)
 	}
 
 	ns := params.ByName("ns")
 	kind := params.ByName("kind")
 	name := params.ByName("name")
+	if _, ok := policy.Namespace[ns]; !ok {
+		return newRequestError(http.StatusNotFound, ErrorCodeNotFound, "namespace %s doesn't exist in policy #%s", ns, gen)
+	}
 
 	obj, err := policy.GetObject(kind, name, ns)
 	if err != nil {
-		panic(fmt.Sprintf("error while getting object %s/%s/%s in policy #%s", ns, kind, name, gen))
+		return newRequestError(http.StatusBadRequest, ErrorCodeInvalidRequest, "error while getting object %s/%s/%s in policy #%s: %s", ns, kind, name, gen, err)
 	}
 	if obj == nil {
-		api.contentType.WriteOneWithStatus(writer, request, nil, http.StatusNotFound)
+		return newRequestError(http.StatusNotFound, ErrorCodeNotFound, "object %s/%s/%s doesn't exist in policy #%s", ns, kind, name, gen)
 	}
 
 	api.contentType.WriteOne(writer, request, obj)
+	return nil
 }
 
 // TypePolicyUpdateResult is an informational data structure with Kind and Constructor for PolicyUpdateResult
@@ -79,6 +86,33 @@ type PolicyUpdateResult struct {
 	WaitForRevision  runtime.Generation
 	PlanAsText       *action.PlanAsText
 	EventLog         []*event.APIEvent
+
+	// EnforcementID identifies enforcement of the new revision, so its progress and outcome could be polled
+	EnforcementID runtime.Generation `yaml:",omitempty"`
+
+	// Queued is true if policy change has been queued for resolution in the background, so the action plan isn't
+	// known yet
+	Queued bool `yaml:",omitempty"`
+
+	// Defaulted maps keys of updated objects into the lists of their fields, which have been filled from namespace
+	// defaults instead of being set explicitly
+	Defaulted map[string][]string `yaml:",omitempty"`
+
+	// RolledBackTo is the policy generation, which objects have been restored by the rollback
+	RolledBackTo runtime.Generation `yaml:",omitempty"`
+
+	// ClaimsResolved and ClaimsTotal are the number of claims resolved successfully and the total number of claims
+	// in the updated policy. They are only reported if continueOnError flag is set
+	ClaimsResolved int `yaml:",omitempty"`
+	ClaimsTotal    int `yaml:",omitempty"`
+
+	// FailedClaims are the keys of claims, which can't be resolved. Policy could only be updated with such claims,
+	// if continueOnError flag is set, so they are only reported then
+	FailedClaims []string `yaml:",omitempty"`
+
+	// Admission contains results of all admission webhooks called for the policy change, including the keys of
+	// objects they mutated
+	Admission []*admission.Result `yaml:",omitempty"`
 }
 
 // GetDefaultColumns returns default set of columns to be displayed
@@ -94,8 +128,16 @@ func (result *PolicyUpdateResult) AsColumns() map[string]string {
 	} else {
 		policyChangesStr = fmt.Sprintf("%d", result.PolicyGeneration)
 	}
+	if result.RolledBackTo > 0 {
+		policyChangesStr = fmt.Sprintf("%s (rolled back to %d)", policyChangesStr, result.RolledBackTo)
+	}
+	if len(result.FailedClaims) > 0 {
+		policyChangesStr = fmt.Sprintf("%s (%d of %d claims resolved)", policyChangesStr, result.ClaimsResolved, result.ClaimsTotal)
+	}
 	var actionPlanStr = result.PlanAsText.String()
-	if len(actionPlanStr) <= 0 {
+	if result.Queued {
+		actionPlanStr = "(queued for resolution)"
+	} else if len(actionPlanStr) <= 0 {
 		actionPlanStr = "(none)"
 	}
 	return map[string]string{
@@ -104,6 +146,55 @@ func (result *PolicyUpdateResult) AsColumns() map[string]string {
 	}
 }
 
+// ClusterParam is a name of the query parameter, which allows to see only the part of the action plan that
+// targets a given cluster (in form namespace/name)
+const ClusterParam = "cluster"
+
+// filterActionPlan returns an action plan filtered by the cluster from the request query parameters. If the cluster
+// is not specified, the whole action plan is returned
+func filterActionPlan(request *http.Request, actionPlan *action.Plan) *action.Plan {
+	cluster := request.URL.Query().Get(ClusterParam)
+	if len(cluster) <= 0 {
+		return actionPlan
+	}
+	return actionPlan.FilterByCluster(cluster)
+}
+
+// ContinueOnErrorParam is a name of the query parameter, which allows to update policy even if some of its claims
+// can't be resolved. Such claims are reported in the policy update result and the rest of claims get enforced
+const ContinueOnErrorParam = "continueOnError"
+
+// getFlagParam returns the value of a boolean flag with a given name from the request query parameters. Flags are not
+// set by default
+func getFlagParam(request *http.Request, name string) (bool, error) {
+	value := request.URL.Query().Get(name)
+	if len(value) <= 0 {
+		return false, nil
+	}
+	flag, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, newRequestError(http.StatusBadRequest, ErrorCodeInvalidRequest, "invalid %s flag: %s", name, value)
+	}
+	return flag, nil
+}
+
+// countClaims returns the number of claims in a given policy
+func countClaims(policy *lang.Policy) int {
+	return len(policy.GetObjectsByKind(lang.TypeClaim.Kind))
+}
+
+// claimsResolution returns the number of claims resolved successfully by a given resolver, the total number of claims
+// in a given policy and the keys of claims, which can't be resolved. They are only returned if policy gets updated with
+// continueOnError flag, as otherwise policy can't be updated with failed claims at all
+func claimsResolution(resolver *resolve.PolicyResolver, policy *lang.Policy, continueOnError bool) (int, int, []string) {
+	if !continueOnError {
+		return 0, 0, nil
+	}
+	failedClaims := resolver.FailedClaims()
+	claimsTotal := countClaims(policy)
+	return claimsTotal - len(failedClaims), claimsTotal, failedClaims
+}
+
 type apiObjectSorter []lang.Base
 
 func (rs apiObjectSorter) Len() int {
@@ -128,51 +219,88 @@ func (rs apiObjectSorter) Weight(obj lang.Base) int { // nolint: interfacer
 	return 1
 }
 
-func (api *coreAPI) handlePolicyUpdate(writer http.ResponseWriter, request *http.Request, params httprouter.Params) { // nolint: gocyclo
-	objects := api.readLang(request)
+// handlePolicyUpdate adds objects to the policy. Invalid policy objects, ACL violations, cluster plugin failures and
+// conflicts with concurrent changes are returned as typed errors, so they get reported with proper status
+func (api *coreAPI) handlePolicyUpdate(writer http.ResponseWriter, request *http.Request, params httprouter.Params) error { // nolint: gocyclo
+	_, readSpan := startSpan(request.Context(), SpanReadObjects)
+	objects, err := api.readLang(request)
+	readSpan.End()
+	if err != nil {
+		return err
+	}
 	user := api.getUserRequired(request)
 
+	// Let admission webhooks allow, deny or mutate objects before anything else
+	review := api.admitPolicyChange(writer, request, admission.OperationUpdate, user, objects)
+	if review == nil {
+		return nil
+	}
+	objects = review.Objects
+
+	// Store operations made while loading policy get traced as a single step
+	loadCtx, loadSpan := startSpan(request.Context(), SpanLoadPolicy)
+	reg := api.registry.WithContext(loadCtx)
+
 	// Load the latest policy
-	_, policyGen, err := api.registry.GetPolicy(runtime.LastOrEmptyGen)
+	_, policyGen, err := reg.GetPolicy(runtime.LastOrEmptyGen)
 	if err != nil {
-		panic(fmt.Sprintf("error while loading current policy: %s", err))
+		loadSpan.End()
+		return fmt.Errorf("error while loading current policy: %s", err)
 	}
 
 	// load the latest revision for the given policy
-	revision, err := api.registry.GetLastRevisionForPolicy(policyGen)
+	revision, err := reg.GetLastRevisionForPolicy(policyGen)
 	if err != nil {
-		panic(fmt.Sprintf("error while loading latest revision from the registry: %s", err))
+		loadSpan.End()
+		return fmt.Errorf("error while loading latest revision from the registry: %s", err)
 	}
 
 	// load desired state
-	desiredState, err := api.registry.GetDesiredState(revision)
+	desiredState, err := reg.GetDesiredState(revision)
 	if err != nil {
-		panic(fmt.Sprintf("can't load desired state from revision: %s", err))
+		loadSpan.End()
+		return fmt.Errorf("can't load desired state from revision: %s", err)
 	}
 
 	// Make a copy of the latest policy, so we can apply changes to it
-	policyUpdated, _, err := api.registry.GetPolicy(policyGen)
+	policyUpdated, _, err := reg.GetPolicy(policyGen)
 	if err != nil {
-		panic(fmt.Sprintf("error while loading current policy: %s", err))
+		loadSpan.End()
+		return fmt.Errorf("error while loading current policy: %s", err)
 	}
+	loadSpan.End()
+
+	// Remember defaults of the affected namespaces, so defaulted fields can be re-evaluated if they change
+	prevDefaults := getDefaultsByNamespace(policyUpdated, objects)
 
 	// Add objects to the policy in a sorted order (e.g. make sure ACL Rules go first)
 	sort.Sort(apiObjectSorter(objects))
 	for _, obj := range objects {
 		errManage := policyUpdated.View(user).ManageObject(obj)
 		if errManage != nil {
-			panic(fmt.Sprintf("error while adding updated object to policy: %s", errManage))
+			return newRequestError(http.StatusForbidden, ErrorCodeForbidden, "error while adding updated object to policy: %s", errManage)
 		}
 		errAdd := policyUpdated.AddObject(obj)
 		if errAdd != nil {
-			panic(fmt.Sprintf("error while adding updated object to policy: %s", errAdd))
+			return newRequestError(http.StatusBadRequest, ErrorCodeInvalidPolicy, "error while adding updated object to policy: %s", errAdd)
 		}
 	}
 
+	// Fill unset fields from namespace defaults
+	objects, defaulted, err := applyDefaults(policyUpdated, user, prevDefaults, objects)
+	if err != nil {
+		return newRequestError(http.StatusBadRequest, ErrorCodeInvalidPolicy, "updated policy is invalid: %s", err)
+	}
+
 	// Check that the policy is valid
-	err = policyUpdated.Validate()
+	validator := lang.NewPolicyValidator(policyUpdated)
+	err = validator.Validate()
+	if uniquenessErr, ok := err.(*lang.UniquenessError); ok {
+		// policy objects are well-formed, but conflict with each other
+		return newRequestError(http.StatusUnprocessableEntity, ErrorCodeInvalidPolicy, "updated policy is invalid: %s", uniquenessErr)
+	}
 	if err != nil {
-		panic(fmt.Sprintf("updated policy is invalid: %s", err))
+		return newRequestError(http.StatusBadRequest, ErrorCodeInvalidPolicy, "updated policy is invalid: %s", err)
 	}
 
 	// Validate clusters using corresponding cluster plugins and make sure there are no conflicts
@@ -183,12 +311,12 @@ func (api *coreAPI) handlePolicyUpdate(writer http.ResponseWriter, request *http
 			// validate via plugin that connection to it can be established
 			plugin, pluginErr := plugins.ForCluster(cluster)
 			if pluginErr != nil {
-				panic(fmt.Sprintf("error while getting cluster plugin for cluster %s of type %s: %s", cluster.Name, cluster.Type, pluginErr))
+				return newRequestError(http.StatusBadGateway, ErrorCodeClusterPlugin, "error while getting cluster plugin for cluster %s of type %s: %s", cluster.Name, cluster.Type, pluginErr)
 			}
 
 			valErr := plugin.Validate()
 			if valErr != nil {
-				panic(fmt.Sprintf("error while validating cluster %s of type %s: %s", cluster.Name, cluster.Type, valErr))
+				return newRequestError(http.StatusBadGateway, ErrorCodeClusterPlugin, "error while validating cluster %s of type %s: %s", cluster.Name, cluster.Type, valErr)
 			}
 		}
 	}
@@ -205,90 +333,172 @@ func (api *coreAPI) handlePolicyUpdate(writer http.ResponseWriter, request *http
 		logLevel = logrus.WarnLevel
 	}
 
-	// Process policy changes, calculate resolution log and action plan
-	eventLog := event.NewLog(logLevel, "api-policy-update").AddConsoleHook(api.logLevel)
-	desiredStateUpdated := resolve.NewPolicyResolver(policyUpdated, api.externalData, eventLog).ResolveAllClaims()
-	err = desiredStateUpdated.Validate(policyUpdated)
+	// See if policy should be updated even if some claims can't be resolved
+	continueOnError, err := getFlagParam(request, ContinueOnErrorParam)
 	if err != nil {
-		panic(fmt.Sprintf("policy change cannon be made: %s", err))
+		return err
 	}
 
-	actionPlan := diff.NewPolicyResolutionDiff(desiredStateUpdated, desiredState).ActionPlan
-
-	// If we are in noop mode, just return expected changes in a form of an action plan
-	if noop {
-		api.contentType.WriteOne(writer, request, &PolicyUpdateResult{
-			TypeKind:         TypePolicyUpdateResult.GetTypeKind(),
-			PolicyGeneration: policyGen,              // policy generation didn't change
-			PolicyChanged:    false,                  // policy has not been updated in the registry
-			WaitForRevision:  runtime.MaxGeneration,  // nothing to wait for
-			PlanAsText:       actionPlan.AsText(),    // return action plan, so it can be printed by the client
-			EventLog:         eventLog.AsAPIEvents(), // return policy resolution log
-		})
-		return
+	// See if policy should be resolved in the background, while client polls the job for the outcome
+	async, err := getFlagParam(request, AsyncParam)
+	if err != nil {
+		return err
+	}
+	if async && noop {
+		return newRequestError(http.StatusBadRequest, ErrorCodeInvalidRequest, "policy change can't be made asynchronously in noop mode")
 	}
 
-	// Update policy
-	changed, policyGen, revisionGen := api.changePolicy(objects, user, desiredStateUpdated, false)
+	// Process policy changes, calculate resolution log and action plan
+	eventLog := api.newEventLog(getResolutionLogLevel(logLevel), "api-policy-update")
+	review.Log(eventLog)
+	for _, warning := range validator.Warnings() {
+		eventLog.NewEntry().Warningf("Policy validation: %s", warning)
+	}
+	for _, warning := range lang.NewLabelUsage(policyUpdated, api.externalData.UserLoader.LoadUsersAll()).Warnings() {
+		eventLog.NewEntry().Warningf("Policy validation: %s", warning)
+	}
 
-	// Return the result back via API
-	api.contentType.WriteOne(writer, request, &PolicyUpdateResult{
-		TypeKind:         TypePolicyUpdateResult.GetTypeKind(),
-		PolicyChanged:    changed,                // have any policy object in the registry been changed or not
-		PolicyGeneration: policyGen,              // policy now has a new generation
-		WaitForRevision:  revisionGen,            // which revision to wait for
-		PlanAsText:       actionPlan.AsText(),    // return action plan, so it can be printed by the client
-		EventLog:         eventLog.AsAPIEvents(), // return policy resolution log
-	})
+	if async {
+		return api.startPolicyApplyJob(writer, request, objects, user, revision, desiredState, eventLog, logLevel, continueOnError)
+	}
 
-	if changed {
-		// signal to the channel that policy has changed, that will trigger the enforcement right away
-		api.runDesiredStateEnforcement <- true
+	// Large (or explicitly queued) policy changes get resolved in the background. Pipeline always skips claims, which
+	// can't be resolved, so continueOnError flag isn't checked for them
+	priority, queued, err := api.getResolutionPriority(params, objects, noop, revision)
+	if err != nil {
+		return err
+	}
+	if queued {
+		return api.queuePolicyChange(writer, request, objects, user, priority, false, eventLog, logLevel)
 	}
 
+	// Stream resolution events to the client as they're produced, if requested
+	stream := api.startEventStream(writer, request, eventLog, logLevel)
+	ctx := policyUpdateContext(request, stream)
+
+	return api.writePolicyUpdateResult(writer, request, stream, func() (*PolicyUpdateResult, error) {
+		resolver := resolve.NewPolicyResolver(policyUpdated, api.externalData, eventLog).SetPluginRegistry(api.pluginRegistryFactory()).SetContinueOnError(continueOnError)
+		desiredStateUpdated, err := resolver.ResolveAllClaims(ctx)
+		if err != nil {
+			return nil, resolutionError(err)
+		}
+		claimsResolved, claimsTotal, failedClaims := claimsResolution(resolver, policyUpdated, continueOnError)
+		err = desiredStateUpdated.Validate(policyUpdated)
+		if err != nil {
+			return nil, newRequestError(http.StatusBadRequest, ErrorCodeInvalidPolicy, "policy change cannot be made: %s", err)
+		}
+
+		_, diffSpan := startSpan(ctx, SpanDiff)
+		actionPlan := diff.NewPolicyResolutionDiff(desiredStateUpdated, desiredState).ActionPlan
+		diffSpan.End()
+
+		// If we are in noop mode, just return expected changes in a form of an action plan
+		if noop {
+			return &PolicyUpdateResult{
+				TypeKind:         TypePolicyUpdateResult.GetTypeKind(),
+				PolicyGeneration: policyGen,                                               // policy generation didn't change
+				PolicyChanged:    false,                                                   // policy has not been updated in the registry
+				WaitForRevision:  runtime.MaxGeneration,                                   // nothing to wait for
+				PlanAsText:       filterActionPlan(request, actionPlan).AsText(),          // return action plan, so it can be printed by the client
+				EventLog:         event.FilterAPIEvents(eventLog.AsAPIEvents(), logLevel), // return policy resolution log
+				Defaulted:        defaulted,                                               // return fields filled from defaults
+				Admission:        review.Results,                                          // return admission results
+				ClaimsResolved:   claimsResolved,                                          // return how many claims got resolved
+				ClaimsTotal:      claimsTotal,                                             // out of all claims in the policy
+				FailedClaims:     failedClaims,                                            // return claims, which can't be resolved
+			}, nil
+		}
+
+		// Update policy
+		events := eventLog.AsAPIEvents()
+		change, err := api.changePolicy(ctx, objects, user, revision, desiredStateUpdated, events, actionPlan.NumberOfActions() > 0, false)
+		if err != nil {
+			return nil, err
+		}
+		if change.Changed {
+			api.cacheResolution(change.PolicyGen, desiredStateUpdated, events, eventLog.GetLevel())
+		}
+
+		// Return the result back via API
+		return &PolicyUpdateResult{
+			TypeKind:         TypePolicyUpdateResult.GetTypeKind(),
+			PolicyChanged:    change.Changed,                          // have any policy object in the registry been changed or not
+			PolicyGeneration: change.PolicyGen,                        // policy now has a new generation
+			WaitForRevision:  change.RevisionGen,                      // which revision to wait for
+			EnforcementID:    change.EnforcementID,                    // which enforcement to poll
+			PlanAsText:       actionPlan.AsText(),                     // return action plan, so it can be printed by the client
+			EventLog:         event.FilterAPIEvents(events, logLevel), // return policy resolution log
+			Defaulted:        defaulted,                               // return fields filled from defaults
+			Admission:        review.Results,                          // return admission results
+			ClaimsResolved:   claimsResolved,                          // return how many claims got resolved
+			ClaimsTotal:      claimsTotal,                             // out of all claims in the policy
+			FailedClaims:     failedClaims,                            // return claims, which can't be resolved
+		}, nil
+	})
 }
 
-func (api *coreAPI) handlePolicyDelete(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
-	objects := api.readLang(request)
+// handlePolicyDelete removes objects from the policy, reporting errors the same way as handlePolicyUpdate does
+func (api *coreAPI) handlePolicyDelete(writer http.ResponseWriter, request *http.Request, params httprouter.Params) error {
+	_, readSpan := startSpan(request.Context(), SpanReadObjects)
+	objects, err := api.readLang(request)
+	readSpan.End()
+	if err != nil {
+		return err
+	}
 	user := api.getUserRequired(request)
 
+	// Let admission webhooks allow or deny deletion
+	review := api.admitPolicyChange(writer, request, admission.OperationDelete, user, objects)
+	if review == nil {
+		return nil
+	}
+
+	// Store operations made while loading policy get traced as a single step
+	loadCtx, loadSpan := startSpan(request.Context(), SpanLoadPolicy)
+	reg := api.registry.WithContext(loadCtx)
+
 	// Load the latest policy gen
-	_, policyGen, err := api.registry.GetPolicy(runtime.LastOrEmptyGen)
+	_, policyGen, err := reg.GetPolicy(runtime.LastOrEmptyGen)
 	if err != nil {
-		panic(fmt.Sprintf("error while loading current policy: %s", err))
+		loadSpan.End()
+		return fmt.Errorf("error while loading current policy: %s", err)
 	}
 
 	// Load the latest revision for the given policy
-	revision, err := api.registry.GetLastRevisionForPolicy(policyGen)
+	revision, err := reg.GetLastRevisionForPolicy(policyGen)
 	if err != nil {
-		panic(fmt.Sprintf("error while loading latest revision from the registry: %s", err))
+		loadSpan.End()
+		return fmt.Errorf("error while loading latest revision from the registry: %s", err)
 	}
 
 	// Load desired state
-	desiredState, err := api.registry.GetDesiredState(revision)
+	desiredState, err := reg.GetDesiredState(revision)
 	if err != nil {
-		panic(fmt.Sprintf("can't load desired state from revision: %s", err))
+		loadSpan.End()
+		return fmt.Errorf("can't load desired state from revision: %s", err)
 	}
 
 	// Make a copy of the latest policy, so we can apply changes to it
-	policyUpdated, _, err := api.registry.GetPolicy(policyGen)
+	policyUpdated, _, err := reg.GetPolicy(policyGen)
 	if err != nil {
-		panic(fmt.Sprintf("error while loading current policy: %s", err))
+		loadSpan.End()
+		return fmt.Errorf("error while loading current policy: %s", err)
 	}
+	loadSpan.End()
 
 	// Delete objects from the policy in a reversed sorted order (e.g. make sure ACL Rules go last)
 	sort.Sort(sort.Reverse(apiObjectSorter(objects)))
 	for _, obj := range objects {
 		errManage := policyUpdated.View(user).ManageObject(obj)
 		if errManage != nil {
-			panic(fmt.Sprintf("Error while removing object from policy: %s", errManage))
+			return newRequestError(http.StatusForbidden, ErrorCodeForbidden, "error while removing object from policy: %s", errManage)
 		}
 		policyUpdated.RemoveObject(obj)
 	}
 
 	err = policyUpdated.Validate()
 	if err != nil {
-		panic(fmt.Sprintf("Updated policy is invalid: %s", err))
+		return newRequestError(http.StatusBadRequest, ErrorCodeInvalidPolicy, "updated policy is invalid: %s", err)
 	}
 
 	// See if noop flag is set
@@ -303,74 +513,343 @@ func (api *coreAPI) handlePolicyDelete(writer http.ResponseWriter, request *http
 		logLevel = logrus.WarnLevel
 	}
 
+	// See if policy should be updated even if some claims can't be resolved
+	continueOnError, err := getFlagParam(request, ContinueOnErrorParam)
+	if err != nil {
+		return err
+	}
+
 	// Process policy changes, calculate and return resolution log + action plan
-	eventLog := event.NewLog(logLevel, "api-policy-delete").AddConsoleHook(api.logLevel)
-	desiredStateUpdated := resolve.NewPolicyResolver(policyUpdated, api.externalData, eventLog).ResolveAllClaims()
+	eventLog := api.newEventLog(getResolutionLogLevel(logLevel), "api-policy-delete")
+	review.Log(eventLog)
+
+	// Large (or explicitly queued) policy changes get resolved in the background
+	priority, queued, err := api.getResolutionPriority(params, objects, noop, revision)
+	if err != nil {
+		return err
+	}
+	if queued {
+		return api.queuePolicyChange(writer, request, objects, user, priority, true, eventLog, logLevel)
+	}
+
+	resolver := resolve.NewPolicyResolver(policyUpdated, api.externalData, eventLog).SetPluginRegistry(api.pluginRegistryFactory()).SetContinueOnError(continueOnError)
+	desiredStateUpdated, err := resolver.ResolveAllClaims(request.Context())
+	if err != nil {
+		return resolutionError(err)
+	}
+	claimsResolved, claimsTotal, failedClaims := claimsResolution(resolver, policyUpdated, continueOnError)
 	err = desiredStateUpdated.Validate(policyUpdated)
 	if err != nil {
-		panic(fmt.Sprintf("policy change cannon be made: %s", err))
+		return newRequestError(http.StatusBadRequest, ErrorCodeInvalidPolicy, "policy change cannot be made: %s", err)
 	}
 
+	_, diffSpan := startSpan(request.Context(), SpanDiff)
 	actionPlan := diff.NewPolicyResolutionDiff(desiredStateUpdated, desiredState).ActionPlan
+	diffSpan.End()
 
 	// If we are in noop mode, just return expected changes in a form of an action plan
 	if noop {
 		api.contentType.WriteOne(writer, request, &PolicyUpdateResult{
 			TypeKind:         TypePolicyUpdateResult.GetTypeKind(),
-			PolicyGeneration: policyGen,              // policy generation didn't change
-			PolicyChanged:    false,                  // policy has not been updated in the registry
-			WaitForRevision:  runtime.MaxGeneration,  // nothing to wait for
-			PlanAsText:       actionPlan.AsText(),    // return action plan, so it can be printed by the client
-			EventLog:         eventLog.AsAPIEvents(), // return policy resolution log
+			PolicyGeneration: policyGen,                                               // policy generation didn't change
+			PolicyChanged:    false,                                                   // policy has not been updated in the registry
+			WaitForRevision:  runtime.MaxGeneration,                                   // nothing to wait for
+			PlanAsText:       filterActionPlan(request, actionPlan).AsText(),          // return action plan, so it can be printed by the client
+			EventLog:         event.FilterAPIEvents(eventLog.AsAPIEvents(), logLevel), // return policy resolution log
+			Admission:        review.Results,                                          // return admission results
+			ClaimsResolved:   claimsResolved,                                          // return how many claims got resolved
+			ClaimsTotal:      claimsTotal,                                             // out of all claims in the policy
+			FailedClaims:     failedClaims,                                            // return claims, which can't be resolved
 		})
-		return
+		return nil
 	}
 
 	// Update policy
-	changed, policyGen, revisionGen := api.changePolicy(objects, user, desiredStateUpdated, true)
+	events := eventLog.AsAPIEvents()
+	change, err := api.changePolicy(request.Context(), objects, user, revision, desiredStateUpdated, events, actionPlan.NumberOfActions() > 0, true)
+	if err != nil {
+		return err
+	}
+	if change.Changed {
+		api.cacheResolution(change.PolicyGen, desiredStateUpdated, events, eventLog.GetLevel())
+	}
 
 	// Return the result back via API
 	api.contentType.WriteOne(writer, request, &PolicyUpdateResult{
 		TypeKind:         TypePolicyUpdateResult.GetTypeKind(),
-		PolicyChanged:    changed,                // have any policy object in the registry been changed or not
-		PolicyGeneration: policyGen,              // policy now has a new generation
-		WaitForRevision:  revisionGen,            // which revision to wait for
-		PlanAsText:       actionPlan.AsText(),    // return action plan, so it can be printed by the client
-		EventLog:         eventLog.AsAPIEvents(), // return policy resolution log
+		PolicyChanged:    change.Changed,                          // have any policy object in the registry been changed or not
+		PolicyGeneration: change.PolicyGen,                        // policy now has a new generation
+		WaitForRevision:  change.RevisionGen,                      // which revision to wait for
+		EnforcementID:    change.EnforcementID,                    // which enforcement to poll
+		PlanAsText:       actionPlan.AsText(),                     // return action plan, so it can be printed by the client
+		EventLog:         event.FilterAPIEvents(events, logLevel), // return policy resolution log
+		Admission:        review.Results,                          // return admission results
+		ClaimsResolved:   claimsResolved,                          // return how many claims got resolved
+		ClaimsTotal:      claimsTotal,                             // out of all claims in the policy
+		FailedClaims:     failedClaims,                            // return claims, which can't be resolved
 	})
+	return nil
+}
 
-	if changed {
-		// signal to the channel that policy has changed, that will trigger the enforcement right away
-		api.runDesiredStateEnforcement <- true
+func (api *coreAPI) handlePolicyRollback(writer http.ResponseWriter, request *http.Request, params httprouter.Params) error {
+	user := api.getUserRequired(request)
+
+	targetGen, err := parseGenParam(params, "gen")
+	if err != nil {
+		return err
+	}
+	if targetGen == runtime.LastOrEmptyGen {
+		return newRequestError(http.StatusBadRequest, ErrorCodeInvalidRequest, "invalid gen: %s", params.ByName("gen"))
 	}
 
+	// Store operations made while loading policy get traced as a single step
+	loadCtx, loadSpan := startSpan(request.Context(), SpanLoadPolicy)
+	reg := api.registry.WithContext(loadCtx)
+
+	// Load the latest policy
+	policy, policyGen, err := reg.GetPolicy(runtime.LastOrEmptyGen)
+	if err != nil {
+		loadSpan.End()
+		return fmt.Errorf("error while loading current policy: %s", err)
+	}
+
+	// Load the policy to roll back to
+	policyTarget, _, err := reg.GetPolicy(targetGen)
+	if err != nil {
+		loadSpan.End()
+		return fmt.Errorf("error while loading policy #%s: %s", targetGen, err)
+	}
+	if policyTarget == nil {
+		loadSpan.End()
+		return newRequestError(http.StatusNotFound, ErrorCodeNotFound, "policy generation %s doesn't exist or was compacted", targetGen)
+	}
+
+	// load the latest revision for the given policy
+	revision, err := reg.GetLastRevisionForPolicy(policyGen)
+	if err != nil {
+		loadSpan.End()
+		return fmt.Errorf("error while loading latest revision from the registry: %s", err)
+	}
+
+	// load desired state
+	desiredState, err := reg.GetDesiredState(revision)
+	if err != nil {
+		loadSpan.End()
+		return fmt.Errorf("can't load desired state from revision: %s", err)
+	}
+	loadSpan.End()
+
+	// Rollback changes the whole policy, so user should be able to manage all objects in both policies
+	for _, p := range []*lang.Policy{policy, policyTarget} {
+		for _, info := range lang.PolicyTypes {
+			for _, obj := range p.GetObjectsByKind(info.Kind) {
+				errManage := policy.View(user).ManageObject(obj)
+				if errManage != nil {
+					return newRequestError(http.StatusForbidden, ErrorCodeForbidden, "error while rolling back policy: %s", errManage)
+				}
+			}
+		}
+	}
+
+	// Check that the policy is still valid
+	err = lang.NewPolicyValidator(policyTarget).Validate()
+	if err != nil {
+		return newRequestError(http.StatusBadRequest, ErrorCodeInvalidPolicy, "policy #%s is invalid: %s", targetGen, err)
+	}
+
+	// See if noop flag is set
+	noop, noopErr := strconv.ParseBool(params.ByName("noop"))
+	if noopErr != nil {
+		noop = false
+	}
+
+	// See what log level is set (either in path or in query)
+	logLevelStr := params.ByName("loglevel")
+	if len(logLevelStr) == 0 {
+		logLevelStr = request.URL.Query().Get("loglevel")
+	}
+	logLevel, logLevelErr := logrus.ParseLevel(logLevelStr)
+	if logLevelErr != nil {
+		logLevel = logrus.WarnLevel
+	}
+
+	// Calculate desired state of the old policy, resolution log and action plan
+	eventLog := api.newEventLog(getResolutionLogLevel(logLevel), "api-policy-rollback")
+	eventLog.NewEntry().Infof("Rolling back policy from #%s to #%s", policyGen, targetGen)
+
+	desiredStateUpdated, err := api.resolvePolicy(request.Context(), policyTarget, targetGen, eventLog)
+	if err != nil {
+		return resolutionError(err)
+	}
+	err = desiredStateUpdated.Validate(policyTarget)
+	if err != nil {
+		return newRequestError(http.StatusBadRequest, ErrorCodeInvalidPolicy, "policy change cannot be made: %s", err)
+	}
+
+	_, diffSpan := startSpan(request.Context(), SpanDiff)
+	actionPlan := diff.NewPolicyResolutionDiff(desiredStateUpdated, desiredState).ActionPlan
+	diffSpan.End()
+
+	// If we are in noop mode, just return expected changes in a form of an action plan
+	if noop {
+		api.contentType.WriteOne(writer, request, &PolicyUpdateResult{
+			TypeKind:         TypePolicyUpdateResult.GetTypeKind(),
+			PolicyGeneration: policyGen,                                               // policy generation didn't change
+			PolicyChanged:    false,                                                   // policy has not been updated in the registry
+			WaitForRevision:  runtime.MaxGeneration,                                   // nothing to wait for
+			PlanAsText:       filterActionPlan(request, actionPlan).AsText(),          // return action plan, so it can be printed by the client
+			EventLog:         event.FilterAPIEvents(eventLog.AsAPIEvents(), logLevel), // return policy resolution log
+			RolledBackTo:     targetGen,                                               // generation policy would be rolled back to
+		})
+		return nil
+	}
+
+	// Roll back policy as a new generation
+	events := eventLog.AsAPIEvents()
+	change, err := api.applyPolicyChange(request.Context(), revision, desiredStateUpdated, events, actionPlan.NumberOfActions() > 0, func(reg registry.Interface) (bool, *engine.PolicyData, error) {
+		return reg.RollbackPolicy(targetGen, user.Name)
+	})
+	if err != nil {
+		return err
+	}
+	if change.Changed {
+		api.cacheResolution(change.PolicyGen, desiredStateUpdated, events, eventLog.GetLevel())
+	}
+
+	// Return the result back via API
+	api.contentType.WriteOne(writer, request, &PolicyUpdateResult{
+		TypeKind:         TypePolicyUpdateResult.GetTypeKind(),
+		PolicyChanged:    change.Changed,                          // have any policy object in the registry been changed or not
+		PolicyGeneration: change.PolicyGen,                        // policy now has a new generation
+		WaitForRevision:  change.RevisionGen,                      // which revision to wait for
+		EnforcementID:    change.EnforcementID,                    // which enforcement to poll
+		PlanAsText:       actionPlan.AsText(),                     // return action plan, so it can be printed by the client
+		EventLog:         event.FilterAPIEvents(events, logLevel), // return policy resolution log
+		RolledBackTo:     targetGen,                               // generation policy has been rolled back to
+	})
+	return nil
+}
+
+// policyChange is the outcome of making policy change in the registry
+type policyChange struct {
+	// Changed is true if any policy object in the registry has been changed
+	Changed bool
+
+	// PolicyGen is the generation of policy after the change
+	PolicyGen runtime.Generation
+
+	// RevisionGen is the revision to wait for (MaxGeneration if there is nothing to wait for)
+	RevisionGen runtime.Generation
+
+	// EnforcementID is the generation of enforcement tracking the new revision (zero if there is no new revision)
+	EnforcementID runtime.Generation
+}
+
+// changePolicy makes object changes in the registry and creates a new revision for the new policy generation along with
+// its resolution log and enforcement, which tracks the revision. If desired state has changed, it triggers the
+// enforcement right away. Otherwise (e.g. only annotations were changed), the new revision gets completed immediately
+// without any enforcement, as long as the previous revision was successfully applied. Tracing span from a given context
+// gets recorded on the new revision. Conflicts with concurrent changes are returned as RequestError
+func (api *coreAPI) changePolicy(ctx context.Context, objects []lang.Base, user *lang.User, prevRevision *engine.Revision, desiredStateUpdated *resolve.PolicyResolution, resolutionEvents []*event.APIEvent, desiredStateChanged bool, delete bool) (*policyChange, error) {
+	return api.applyPolicyChange(ctx, prevRevision, desiredStateUpdated, resolutionEvents, desiredStateChanged, func(reg registry.Interface) (bool, *engine.PolicyData, error) {
+		if delete {
+			return reg.DeleteFromPolicy(objects, user.Name)
+		}
+		return reg.UpdatePolicy(objects, user.Name)
+	})
 }
 
-func (api *coreAPI) changePolicy(objects []lang.Base, user *lang.User, desiredStateUpdated *resolve.PolicyResolution, delete bool) (bool, runtime.Generation, runtime.Generation) {
+// applyPolicyChange makes policy change in the registry using a given function and creates a new revision for the new
+// policy generation the same way as changePolicy does
+func (api *coreAPI) applyPolicyChange(ctx context.Context, prevRevision *engine.Revision, desiredStateUpdated *resolve.PolicyResolution, resolutionEvents []*event.APIEvent, desiredStateChanged bool, change func(reg registry.Interface) (bool, *engine.PolicyData, error)) (*policyChange, error) {
 	// Make sure to take the mutex, before making any policy and revision changes
 	api.policyAndRevisionUpdateMutex.Lock()
 	defer api.policyAndRevisionUpdateMutex.Unlock()
 
+	return api.applyPolicyChangeLocked(ctx, prevRevision, desiredStateUpdated, resolutionEvents, desiredStateChanged, change)
+}
+
+// applyPolicyChangeLocked does the same as applyPolicyChange, while the caller is holding policyAndRevisionUpdateMutex
+func (api *coreAPI) applyPolicyChangeLocked(ctx context.Context, prevRevision *engine.Revision, desiredStateUpdated *resolve.PolicyResolution, resolutionEvents []*event.APIEvent, desiredStateChanged bool, change func(reg registry.Interface) (bool, *engine.PolicyData, error)) (*policyChange, error) {
+	ctx, span := startSpan(ctx, SpanChangePolicy)
+	defer span.End()
+	reg := api.registry.WithContext(ctx)
+
 	// Make object changes in the registry
-	var changed bool
-	var policyData *engine.PolicyData
-	var err error
-	if delete {
-		changed, policyData, err = api.registry.DeleteFromPolicy(objects, user.Name)
-	} else {
-		changed, policyData, err = api.registry.UpdatePolicy(objects, user.Name)
+	changed, policyData, err := change(reg)
+	if store.IsDuplicateValue(err) {
+		return nil, newRequestError(http.StatusConflict, ErrorCodeConflict, "policy change conflicts with concurrent changes: %s", err)
 	}
 	if err != nil {
-		panic(fmt.Sprintf("error while making changes to objects in the policy: %s", err))
+		return nil, fmt.Errorf("error while making changes to objects in the policy: %s", err)
 	}
 	// If there are changes, create a new revision and say that we should wait for it
 	revisionGen := runtime.MaxGeneration
 	if changed {
-		newRevision, newRevisionErr := api.registry.NewRevision(policyData.GetGeneration(), desiredStateUpdated, false)
+		newRevision, newRevisionErr := reg.NewRevision(policyData.GetGeneration(), desiredStateUpdated, false)
 		if newRevisionErr != nil {
-			panic(fmt.Errorf("unable to create new revision for policy gen %d", policyData.GetGeneration()))
+			return nil, fmt.Errorf("unable to create new revision for policy gen %d: %s", policyData.GetGeneration(), newRevisionErr)
 		}
 		revisionGen = newRevision.GetGeneration()
+
+		// Keep trace of the request, so enforcement of the revision could be linked to it
+		if setRevisionTrace(ctx, newRevision) {
+			updateErr := reg.UpdateRevision(newRevision)
+			if updateErr != nil {
+				return nil, fmt.Errorf("unable to update revision %d: %s", revisionGen, updateErr)
+			}
+		}
+
+		// Keep resolution log, so it could be retrieved for the revision later
+		saveErr := reg.SaveResolutionLog(newRevision, resolutionEvents)
+		if saveErr != nil {
+			return nil, fmt.Errorf("unable to save resolution log for revision %d: %s", revisionGen, saveErr)
+		}
+
+		// Keep track of the revision enforcement, so its progress and outcome could be polled
+		enforcement, enforcementErr := reg.NewEnforcement(revisionGen, api.clock.Now())
+		if enforcementErr != nil {
+			return nil, fmt.Errorf("unable to create enforcement for revision %d: %s", revisionGen, enforcementErr)
+		}
+		result := &policyChange{Changed: changed, PolicyGen: policyData.GetGeneration(), RevisionGen: revisionGen, EnforcementID: enforcement.GetGeneration()}
+
+		// If desired state is the same and it has been already applied, there is nothing to enforce
+		if !desiredStateChanged && isRevisionApplied(prevRevision) {
+			newRevision.Status = engine.RevisionStatusCompleted
+			newRevision.AppliedAt = api.clock.Now()
+			updateErr := reg.UpdateRevision(newRevision)
+			if updateErr != nil {
+				return nil, fmt.Errorf("unable to update revision %d: %s", revisionGen, updateErr)
+			}
+
+			enforcement.Status = engine.EnforcementStatusSucceeded
+			enforcement.StartedAt = newRevision.AppliedAt
+			enforcement.FinishedAt = newRevision.AppliedAt
+			enforcement.AppliedRevisionGen = revisionGen
+			updateErr = reg.UpdateEnforcement(enforcement)
+			if updateErr != nil {
+				return nil, fmt.Errorf("unable to update enforcement %d: %s", enforcement.GetGeneration(), updateErr)
+			}
+			return result, nil
+		}
+
+		// signal to the channel that policy has changed, that will trigger the enforcement right away
+		api.triggerEnforcement()
+		return result, nil
+	}
+	return &policyChange{Changed: changed, PolicyGen: policyData.GetGeneration(), RevisionGen: revisionGen}, nil
+}
+
+// isRevisionApplied returns true if revision has been completed without any failed actions
+func isRevisionApplied(revision *engine.Revision) bool {
+	return revision != nil && revision.Status == engine.RevisionStatusCompleted && (revision.Result == nil || revision.Result.Failed == 0)
+}
+
+// getResolutionLogLevel returns level of the event log for policy resolution. Resolution log gets saved along with the
+// revision, so it's always kept at least at info level regardless of the level requested by the client
+func getResolutionLogLevel(requested logrus.Level) logrus.Level {
+	if requested > logrus.InfoLevel {
+		return requested
 	}
-	return changed, policyData.GetGeneration(), revisionGen
+	return logrus.InfoLevel
 }
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Aptomi/aptomi/pkg/api/admission"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	log "github.com/sirupsen/logrus"
+)
+
+// admitPolicyChange runs objects being changed in the policy through the admission webhooks. If policy change gets
+// denied, it writes the error response and returns nil. Otherwise it returns the review with objects mutated by
+// webhooks, which still have to go through ACL checks and policy validation
+func (api *coreAPI) admitPolicyChange(writer http.ResponseWriter, request *http.Request, operation admission.Operation, user *lang.User, objects []lang.Base) *admission.Review {
+	review := api.admission.Admit(request.Context(), &admission.Request{
+		Operation: operation,
+		User:      user,
+		Objects:   objects,
+	})
+
+	if !review.Allowed {
+		messages := strings.Join(review.DeniedMessages(), "; ")
+		log.Warnf("Policy %s by %s denied by admission: %s", operation, user.Name, messages)
+
+		serverErr := NewServerError(fmt.Sprintf("policy change denied by admission: %s", messages))
+		api.contentType.WriteOneWithStatus(writer, request, serverErr, http.StatusForbidden)
+		return nil
+	}
+
+	return review
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/config"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+	"github.com/Aptomi/aptomi/pkg/plugin/fake"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyUpdateContinueOnError(t *testing.T) {
+	reg := registry.New(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()))
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	rule := b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+	user := b.AddUser()
+	user.DomainAdmin = true
+
+	// one claim can be resolved, while context of the other service doesn't match
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	claim := b.AddClaim(user, service)
+	serviceUnmatched := b.AddService(bundle, b.Criteria("label1 == 'value1'", "true", "false"))
+	claimUnmatched := b.AddClaim(user, serviceUnmatched)
+
+	server := NewServer(Options{
+		Registry:     reg,
+		ExternalData: b.External(),
+		PluginRegistryFactory: func() plugin.Registry {
+			return plugin.NewRegistry(
+				config.Plugins{},
+				map[string]plugin.ClusterPluginConstructor{
+					"kubernetes": func(cluster *lang.Cluster, cfg config.Plugins) (plugin.ClusterPlugin, error) {
+						return fake.NewNoOpClusterPlugin(0), nil
+					},
+				},
+				map[string]map[string]plugin.CodePluginConstructor{
+					"kubernetes": {
+						"helm": func(cluster plugin.ClusterPlugin, cfg config.Plugins) (plugin.CodePlugin, error) {
+							return fake.NewNoOpCodePlugin(0), nil
+						},
+					},
+				},
+			)
+		},
+		AuthProvider: &userAuthProvider{user: user},
+	})
+	apiCodec := codec.NewYAMLCodec(runtime.NewTypes().Append(Types...))
+
+	body, err := apiCodec.EncodeMany([]runtime.Object{cluster, rule, bundle, service, serviceUnmatched, claim, claimUnmatched})
+	if !assert.NoError(t, err, "Policy objects should be encoded") {
+		t.FailNow()
+	}
+	update := func(query string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("POST", "/api/v1/policy/noop/false/loglevel/warning"+query, bytes.NewReader(body)))
+		return recorder
+	}
+
+	// by default, policy should not be updated
+	recorder := update("")
+	assert.Equal(t, http.StatusBadRequest, recorder.Code, "Policy should not be updated in strict mode: %s", recorder.Body.String())
+	recorder = update("?continueOnError=maybe")
+	assert.Equal(t, http.StatusBadRequest, recorder.Code, "Invalid flag should be rejected")
+
+	// policy should be updated with all claims, which can be resolved
+	recorder = update("?continueOnError=true")
+	if !assert.Equal(t, http.StatusOK, recorder.Code, "Policy should be updated: %s", recorder.Body.String()) {
+		t.FailNow()
+	}
+	obj, err := apiCodec.DecodeOne(recorder.Body.Bytes())
+	if !assert.NoError(t, err, "Policy update result should be decoded") {
+		t.FailNow()
+	}
+	result := obj.(*PolicyUpdateResult)
+	assert.True(t, result.PolicyChanged, "Policy should be changed")
+	assert.Equal(t, 1, result.ClaimsResolved, "Only one claim should be resolved")
+	assert.Equal(t, 2, result.ClaimsTotal, "All claims should be counted")
+	assert.Equal(t, []string{runtime.KeyForStorable(claimUnmatched)}, result.FailedClaims, "Failed claim should be reported")
+	assert.Contains(t, result.AsColumns()["Policy Generation"], "(1 of 2 claims resolved)", "Number of resolved claims should be displayed")
+
+	// claims shouldn't be counted without the flag, e.g. when the failed claim gets deleted in strict mode
+	deleteBody, err := apiCodec.EncodeMany([]runtime.Object{serviceUnmatched, claimUnmatched})
+	if !assert.NoError(t, err, "Policy objects should be encoded") {
+		t.FailNow()
+	}
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest("DELETE", "/api/v1/policy/noop/true/loglevel/warning", bytes.NewReader(deleteBody)))
+	if !assert.Equal(t, http.StatusOK, recorder.Code, "Policy objects should be deleted: %s", recorder.Body.String()) {
+		t.FailNow()
+	}
+	obj, err = apiCodec.DecodeOne(recorder.Body.Bytes())
+	if !assert.NoError(t, err, "Policy update result should be decoded") {
+		t.FailNow()
+	}
+	result = obj.(*PolicyUpdateResult)
+	assert.Zero(t, result.ClaimsTotal, "Claims shouldn't be counted without the flag")
+	assert.Empty(t, result.FailedClaims, "Failed claims shouldn't be reported without the flag")
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"gopkg.in/yaml.v2"
+)
+
+// getDefaultsByNamespace returns current Defaults of all namespaces, which given objects belong to
+func getDefaultsByNamespace(policy *lang.Policy, objects []lang.Base) map[string]*lang.Defaults {
+	result := make(map[string]*lang.Defaults)
+	for _, obj := range objects {
+		if _, exist := result[obj.GetNamespace()]; !exist {
+			result[obj.GetNamespace()] = policy.GetDefaults(obj.GetNamespace())
+		}
+	}
+	return result
+}
+
+// applyDefaults fills unset fields of the objects being added to the policy from Defaults of their namespaces. If
+// Defaults object itself is being changed, all other objects in its namespace get re-evaluated and the ones with
+// changed effective values get added to the list of updated objects. Updated policy gets modified in place. It
+// returns the resulting list of updated objects, as well as the list of defaulted fields for every object key.
+//
+// Deleted Defaults are not handled here on purpose, as objects keep effective values already recorded in them
+func applyDefaults(policy *lang.Policy, user *lang.User, prevDefaults map[string]*lang.Defaults, objects []lang.Base) ([]lang.Base, map[string][]string, error) {
+	result := make([]lang.Base, 0, len(objects))
+	defaulted := make(map[string][]string)
+	updated := make(map[string]bool)
+
+	for _, obj := range objects {
+		key := runtime.KeyForStorable(obj)
+		updated[key] = true
+
+		objDefaulted, fields, err := lang.ApplyDefaults(obj, prevDefaults[obj.GetNamespace()], policy.GetDefaults(obj.GetNamespace()))
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(fields) > 0 {
+			defaulted[key] = fields
+		}
+		if objDefaulted != obj {
+			err = policy.AddObject(objDefaulted)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error while adding defaulted object %s to policy: %s", key, err)
+			}
+		}
+		result = append(result, objDefaulted)
+	}
+
+	// re-evaluate other objects in namespaces with changed Defaults
+	for _, obj := range objects {
+		if obj.GetKind() != lang.TypeDefaults.Kind {
+			continue
+		}
+		ns := obj.GetNamespace()
+		for _, typeInfo := range lang.PolicyTypes {
+			if typeInfo.Kind == lang.TypeDefaults.Kind {
+				continue
+			}
+			for _, existing := range policy.GetObjectsByKind(typeInfo.Kind) {
+				key := runtime.KeyForStorable(existing)
+				if existing.GetNamespace() != ns || updated[key] {
+					continue
+				}
+
+				existingDefaulted, fields, err := lang.ApplyDefaults(existing, prevDefaults[ns], policy.GetDefaults(ns))
+				if err != nil {
+					return nil, nil, err
+				}
+				changed, err := objectChanged(existing, existingDefaulted)
+				if err != nil {
+					return nil, nil, err
+				}
+				if !changed {
+					continue
+				}
+
+				err = policy.View(user).ManageObject(existingDefaulted)
+				if err != nil {
+					return nil, nil, fmt.Errorf("error while re-evaluating defaults of %s: %s", key, err)
+				}
+				err = policy.AddObject(existingDefaulted)
+				if err != nil {
+					return nil, nil, fmt.Errorf("error while adding defaulted object %s to policy: %s", key, err)
+				}
+
+				updated[key] = true
+				if len(fields) > 0 {
+					defaulted[key] = fields
+				}
+				result = append(result, existingDefaulted)
+			}
+		}
+	}
+
+	return result, defaulted, nil
+}
+
+// objectChanged returns true if objects are different, when compared in their yaml representation
+func objectChanged(prev lang.Base, next lang.Base) (bool, error) {
+	prevData, err := yaml.Marshal(prev)
+	if err != nil {
+		return false, fmt.Errorf("error while marshaling object %s: %s", runtime.KeyForStorable(prev), err)
+	}
+	nextData, err := yaml.Marshal(next)
+	if err != nil {
+		return false, fmt.Errorf("error while marshaling object %s: %s", runtime.KeyForStorable(next), err)
+	}
+	return string(prevData) != string(nextData), nil
+}
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Aptomi/aptomi/pkg/engine/apply/action"
+	"github.com/Aptomi/aptomi/pkg/engine/diff"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// TypePolicyDiffResult is an informational data structure with Kind and Constructor for PolicyDiffResult
+var TypePolicyDiffResult = &runtime.TypeInfo{
+	Kind:        "policy-diff-result",
+	Constructor: func() runtime.Object { return &PolicyDiffResult{} },
+}
+
+// PolicyDiffResult represents the difference between two policy generations. It always includes the list of policy
+// objects added, removed and modified between generations. If requested, it also includes the action plan, which gets
+// desired state of one policy generation to desired state of another one. Nothing is changed while it's calculated
+type PolicyDiffResult struct {
+	runtime.TypeKind `yaml:",inline"`
+	From             runtime.Generation
+	To               runtime.Generation
+	Objects          []*PolicyObjectDiff
+	PlanAsText       *action.PlanAsText  `yaml:",omitempty"`
+	Actions          []*PolicyDiffAction `yaml:",omitempty"`
+}
+
+// Policy object changes between policy generations
+const (
+	PolicyObjectAdded    = "added"
+	PolicyObjectRemoved  = "removed"
+	PolicyObjectModified = "modified"
+)
+
+// PolicyObjectDiff is a single policy object, which has been added, removed or modified between policy generations
+type PolicyObjectDiff struct {
+	Namespace string
+	Kind      string
+	Name      string
+	Change    string
+
+	// Diff is the unified diff between YAML representations of the modified object
+	Diff string `yaml:",omitempty"`
+}
+
+// PolicyDiffAction is a single action from the action plan in structured form
+type PolicyDiffAction struct {
+	// Key is the key of component instance the action belongs to
+	Key     string
+	Kind    string
+	Name    string
+	Cluster string `yaml:",omitempty"`
+
+	// DependsOn is the list of keys of component instances, which actions get executed before this one
+	DependsOn []string `yaml:",omitempty"`
+}
+
+// GetDefaultColumns returns default set of columns to be displayed
+func (result *PolicyDiffResult) GetDefaultColumns() []string {
+	return []string{"Policy Generations", "Objects", "Action Plan"}
+}
+
+// AsColumns returns PolicyDiffResult representation as columns
+func (result *PolicyDiffResult) AsColumns() map[string]string {
+	objects := make([]string, 0, len(result.Objects))
+	for _, obj := range result.Objects {
+		objects = append(objects, fmt.Sprintf("%s %s/%s/%s", obj.Change, obj.Namespace, obj.Kind, obj.Name))
+	}
+	objectsStr := strings.Join(objects, "\n")
+	if len(objectsStr) <= 0 {
+		objectsStr = "(none)"
+	}
+
+	actionPlanStr := "(not calculated)"
+	if result.PlanAsText != nil {
+		actionPlanStr = result.PlanAsText.String()
+		if len(actionPlanStr) <= 0 {
+			actionPlanStr = "(none)"
+		}
+	}
+
+	return map[string]string{
+		"Policy Generations": fmt.Sprintf("%d -> %d", result.From, result.To),
+		"Objects":            objectsStr,
+		"Action Plan":        actionPlanStr,
+	}
+}
+
+func (api *coreAPI) handlePolicyDiff(writer http.ResponseWriter, request *http.Request, params httprouter.Params) error {
+	gens := make([]runtime.Generation, 0, 2)
+	for _, name := range []string{"from", "to"} {
+		gen, err := parseGenParam(params, name)
+		if err != nil {
+			return err
+		}
+		if gen == 0 {
+			return newRequestError(http.StatusBadRequest, ErrorCodeInvalidRequest, "invalid policy generation: %s", params.ByName(name))
+		}
+		gens = append(gens, gen)
+	}
+
+	// See if desired states of both policies should be calculated and compared as well
+	resolvePolicies := false
+	if value := request.URL.Query().Get("resolve"); len(value) > 0 {
+		var err error
+		resolvePolicies, err = strconv.ParseBool(value)
+		if err != nil {
+			return newRequestError(http.StatusBadRequest, ErrorCodeInvalidRequest, "invalid resolve flag: %s", value)
+		}
+	}
+
+	// Store operations made while loading policies get traced as a single step
+	loadCtx, loadSpan := startSpan(request.Context(), SpanLoadPolicy)
+	reg := api.registry.WithContext(loadCtx)
+
+	policies := make([]*lang.Policy, 0, len(gens))
+	for _, gen := range gens {
+		policy, _, err := reg.GetPolicy(gen)
+		if err != nil {
+			loadSpan.End()
+			return fmt.Errorf("error while loading policy #%s: %s", gen, err)
+		}
+		if policy == nil {
+			loadSpan.End()
+			return newRequestError(http.StatusNotFound, ErrorCodeNotFound, "policy generation %s doesn't exist or was compacted", gen)
+		}
+		policies = append(policies, policy)
+	}
+	loadSpan.End()
+
+	objects, err := diffPolicyObjects(policies[0], policies[1], gens[0], gens[1])
+	if err != nil {
+		return err
+	}
+
+	result := &PolicyDiffResult{
+		TypeKind: TypePolicyDiffResult.GetTypeKind(),
+		From:     gens[0],
+		To:       gens[1],
+		Objects:  objects,
+	}
+
+	if resolvePolicies {
+		// Calculate desired state of both policies without saving anything (or take it from cache)
+		eventLog := api.newEventLog(logrus.WarnLevel, "api-policy-diff")
+		desiredStates := make([]*resolve.PolicyResolution, 0, len(policies))
+		for i, policy := range policies {
+			desiredState, resolveErr := api.resolvePolicy(request.Context(), policy, gens[i], eventLog)
+			if resolveErr != nil {
+				return resolutionError(resolveErr)
+			}
+			desiredStates = append(desiredStates, desiredState)
+		}
+
+		_, diffSpan := startSpan(request.Context(), SpanDiff)
+		actionPlan := filterActionPlan(request, diff.NewPolicyResolutionDiff(desiredStates[1], desiredStates[0]).ActionPlan)
+		diffSpan.End()
+
+		result.PlanAsText = actionPlan.AsText()
+		result.Actions = getPolicyDiffActions(actionPlan)
+	}
+
+	api.contentType.WriteOne(writer, request, result)
+	return nil
+}
+
+// diffPolicyObjects returns policy objects added, removed and modified between two policies ordered by namespace, kind
+// and name. Objects are compared in their YAML representation, which is also used to produce diffs of modified objects
+func diffPolicyObjects(from *lang.Policy, to *lang.Policy, fromGen runtime.Generation, toGen runtime.Generation) ([]*PolicyObjectDiff, error) {
+	fromObjects := make(map[runtime.Key]lang.Base)
+	toObjects := make(map[runtime.Key]lang.Base)
+	keys := []runtime.Key{}
+	for _, info := range lang.PolicyTypes {
+		for _, obj := range from.GetObjectsByKind(info.Kind) {
+			key := runtime.KeyForStorable(obj)
+			fromObjects[key] = obj
+			keys = append(keys, key)
+		}
+		for _, obj := range to.GetObjectsByKind(info.Kind) {
+			key := runtime.KeyForStorable(obj)
+			toObjects[key] = obj
+			if _, exists := fromObjects[key]; !exists {
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	result := make([]*PolicyObjectDiff, 0)
+	for _, key := range keys {
+		fromObj, toObj := fromObjects[key], toObjects[key]
+		switch {
+		case fromObj == nil:
+			result = append(result, newPolicyObjectDiff(toObj, PolicyObjectAdded, ""))
+		case toObj == nil:
+			result = append(result, newPolicyObjectDiff(fromObj, PolicyObjectRemoved, ""))
+		default:
+			fromData, err := yaml.Marshal(fromObj)
+			if err != nil {
+				return nil, fmt.Errorf("error while marshaling object %s: %s", key, err)
+			}
+			toData, err := yaml.Marshal(toObj)
+			if err != nil {
+				return nil, fmt.Errorf("error while marshaling object %s: %s", key, err)
+			}
+			if string(fromData) == string(toData) {
+				continue
+			}
+
+			objDiff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+				A:        difflib.SplitLines(string(fromData)),
+				B:        difflib.SplitLines(string(toData)),
+				FromFile: fmt.Sprintf("%s (#%s)", key, fromGen),
+				ToFile:   fmt.Sprintf("%s (#%s)", key, toGen),
+				Context:  3,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("error while calculating diff for object %s: %s", key, err)
+			}
+			result = append(result, newPolicyObjectDiff(toObj, PolicyObjectModified, objDiff))
+		}
+	}
+
+	return result, nil
+}
+
+func newPolicyObjectDiff(obj lang.Base, change string, objDiff string) *PolicyObjectDiff {
+	return &PolicyObjectDiff{
+		Namespace: obj.GetNamespace(),
+		Kind:      obj.GetKind(),
+		Name:      obj.GetName(),
+		Change:    change,
+		Diff:      objDiff,
+	}
+}
+
+// getPolicyDiffActions returns all actions of the action plan in structured form ordered by component instance key
+func getPolicyDiffActions(actionPlan *action.Plan) []*PolicyDiffAction {
+	keys := make([]string, 0, len(actionPlan.NodeMap))
+	for key := range actionPlan.NodeMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]*PolicyDiffAction, 0)
+	for _, key := range keys {
+		node := actionPlan.NodeMap[key]
+		var dependsOn []string
+		for _, before := range node.Before {
+			dependsOn = append(dependsOn, before.Key)
+		}
+		sort.Strings(dependsOn)
+
+		for _, act := range node.Actions {
+			result = append(result, &PolicyDiffAction{
+				Key:       key,
+				Kind:      act.GetKind(),
+				Name:      act.GetName(),
+				Cluster:   act.GetCluster(),
+				DependsOn: dependsOn,
+			})
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/config"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+	"github.com/Aptomi/aptomi/pkg/plugin/fake"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyDiff(t *testing.T) {
+	reg := registry.New(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()))
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	rule := b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+	user := b.AddUser()
+	user.DomainAdmin = true
+	claim := b.AddClaim(user, service)
+
+	server := NewServer(Options{
+		Registry:     reg,
+		ExternalData: b.External(),
+		PluginRegistryFactory: func() plugin.Registry {
+			return plugin.NewRegistry(
+				config.Plugins{},
+				map[string]plugin.ClusterPluginConstructor{
+					"kubernetes": func(cluster *lang.Cluster, cfg config.Plugins) (plugin.ClusterPlugin, error) {
+						return fake.NewNoOpClusterPlugin(0), nil
+					},
+				},
+				map[string]map[string]plugin.CodePluginConstructor{
+					"kubernetes": {
+						"helm": func(cluster plugin.ClusterPlugin, cfg config.Plugins) (plugin.CodePlugin, error) {
+							return fake.NewNoOpCodePlugin(0), nil
+						},
+					},
+				},
+			)
+		},
+		AuthProvider: &userAuthProvider{user: user},
+	})
+	apiCodec := codec.NewYAMLCodec(runtime.NewTypes().Append(Types...))
+
+	// commit policy as generation 2
+	body, err := apiCodec.EncodeMany([]runtime.Object{cluster, bundle, service, rule, claim})
+	if !assert.NoError(t, err, "Policy objects should be encoded") {
+		t.FailNow()
+	}
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest("POST", "/api/v1/policy/noop/false/loglevel/warning", bytes.NewReader(body)))
+	if !assert.Equal(t, http.StatusOK, recorder.Code, "Policy should be updated: %s", recorder.Body.String()) {
+		t.FailNow()
+	}
+
+	getDiff := func(from, to string, query string) (int, runtime.Object) {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/policy/diff/"+from+"/"+to+query, nil))
+		obj, errDecode := apiCodec.DecodeOne(recorder.Body.Bytes())
+		if !assert.NoError(t, errDecode, "Policy diff should be decoded") {
+			t.FailNow()
+		}
+		return recorder.Code, obj
+	}
+	getResult := func(from, to string, query string) *PolicyDiffResult {
+		code, obj := getDiff(from, to, query)
+		if !assert.Equal(t, http.StatusOK, code, "Policy diff should be returned") {
+			t.FailNow()
+		}
+		return obj.(*PolicyDiffResult)
+	}
+
+	result := getResult("1", "2", "?resolve=true")
+	assert.EqualValues(t, 1, result.From)
+	assert.EqualValues(t, 2, result.To)
+	assert.Len(t, result.Objects, 5, "All objects added in policy #2 should be returned")
+	for _, obj := range result.Objects {
+		assert.Equal(t, PolicyObjectAdded, obj.Change, "Object %s/%s should be added", obj.Kind, obj.Name)
+	}
+	assert.NotEmpty(t, result.PlanAsText.Actions, "Claim added in policy #2 should result in actions")
+	assert.Len(t, result.Actions, len(result.PlanAsText.Actions), "All actions should be returned in structured form")
+
+	result = getResult("2", "1", "?resolve=true")
+	assert.Len(t, result.Objects, 5, "All objects should be removed in policy #1")
+	for _, obj := range result.Objects {
+		assert.Equal(t, PolicyObjectRemoved, obj.Change, "Object %s/%s should be removed", obj.Kind, obj.Name)
+	}
+	assert.NotEmpty(t, result.Actions, "Going back to empty policy should result in actions")
+
+	result = getResult("2", "2", "?resolve=true")
+	assert.Empty(t, result.Objects, "Policy objects shouldn't differ from themselves")
+	assert.Empty(t, result.Actions, "Policy shouldn't differ from itself")
+
+	// commit policy with modified bundle as generation 3
+	bundle.Labels = map[string]string{"team": "dev"}
+	body, err = apiCodec.EncodeMany([]runtime.Object{bundle})
+	if !assert.NoError(t, err, "Policy objects should be encoded") {
+		t.FailNow()
+	}
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest("POST", "/api/v1/policy/noop/false/loglevel/warning", bytes.NewReader(body)))
+	if !assert.Equal(t, http.StatusOK, recorder.Code, "Policy should be updated: %s", recorder.Body.String()) {
+		t.FailNow()
+	}
+
+	// action plan shouldn't be calculated unless requested
+	result = getResult("2", "3", "")
+	assert.Nil(t, result.PlanAsText, "Action plan shouldn't be calculated without resolve flag")
+	assert.Empty(t, result.Actions, "Action plan shouldn't be calculated without resolve flag")
+	if assert.Len(t, result.Objects, 1, "Only modified bundle should be returned") {
+		assert.Equal(t, PolicyObjectModified, result.Objects[0].Change)
+		assert.Equal(t, bundle.Name, result.Objects[0].Name)
+		assert.Contains(t, result.Objects[0].Diff, "+  team: dev", "Diff of modified bundle should be returned")
+	}
+
+	code, obj := getDiff("1", "4", "")
+	assert.Equal(t, http.StatusNotFound, code, "Missing policy generation should not be found")
+	if serverErr, ok := obj.(*ServerError); assert.True(t, ok, "Error should be returned") {
+		assert.Contains(t, serverErr.Error, "generation 4", "Missing generation should be named")
+	}
+	code, _ = getDiff("one", "2", "")
+	assert.Equal(t, http.StatusBadRequest, code, "Invalid policy generation should be rejected")
+	code, _ = getDiff("1", "2", "?resolve=maybe")
+	assert.Equal(t, http.StatusBadRequest, code, "Invalid resolve flag should be rejected")
+
+	// nothing should be changed by diff
+	_, policyGen, err := reg.GetPolicy(runtime.LastOrEmptyGen)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, policyGen, "Diff shouldn't change policy")
+}
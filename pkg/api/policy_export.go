@@ -0,0 +1,74 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/julienschmidt/httprouter"
+)
+
+// handlePolicyExport returns all objects of the policy as a multi-document YAML stream, which could be saved to a file
+// and applied again (e.g. to keep policy under version control or to move it to another environment)
+func (api *coreAPI) handlePolicyExport(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	gen := params.ByName("gen")
+
+	if len(gen) == 0 {
+		gen = strconv.Itoa(int(runtime.LastOrEmptyGen))
+	}
+
+	policy, policyGen, err := api.registry.GetPolicy(runtime.ParseGeneration(gen))
+	if err != nil {
+		panic(fmt.Sprintf("error while getting requested policy: %s", err))
+	}
+	if policy == nil {
+		serverErr := NewServerError(fmt.Sprintf("policy generation %s doesn't exist or was compacted", gen))
+		api.contentType.WriteOneWithStatus(writer, request, serverErr, http.StatusNotFound)
+		return
+	}
+
+	data, err := exportPolicyObjects(policy)
+	if err != nil {
+		panic(fmt.Sprintf("error while exporting policy #%s: %s", policyGen, err))
+	}
+
+	writer.Header().Set("Content-Type", codec.YAML)
+	writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=policy-%s.yaml", policyGen))
+	writer.WriteHeader(http.StatusOK)
+	_, err = writer.Write(data)
+	if err != nil {
+		panic(fmt.Sprintf("error while writing exported policy #%s: %s", policyGen, err))
+	}
+}
+
+// exportPolicyObjects encodes all policy objects with the same codec policy files are read with, each one as a separate
+// YAML document. Objects are ordered the same way they're added to the policy on update (e.g. ACL rules go first)
+func exportPolicyObjects(policy *lang.Policy) ([]byte, error) {
+	objects := make([]lang.Base, 0)
+	for _, info := range lang.PolicyTypes {
+		objects = append(objects, policy.GetObjectsByKind(info.Kind)...)
+	}
+
+	// order objects by key first, so export of the same policy is always the same
+	sort.Slice(objects, func(i, j int) bool {
+		return runtime.KeyForStorable(objects[i]) < runtime.KeyForStorable(objects[j])
+	})
+	sort.Stable(apiObjectSorter(objects))
+
+	yamlCodec := codec.NewYAMLCodec(runtime.NewTypes().Append(lang.PolicyTypes...))
+	result := make([]byte, 0)
+	for _, obj := range objects {
+		data, err := yamlCodec.EncodeOne(obj)
+		if err != nil {
+			return nil, fmt.Errorf("error while encoding %s: %s", runtime.KeyForStorable(obj), err)
+		}
+		result = append(result, "---\n"...)
+		result = append(result, data...)
+	}
+
+	return result, nil
+}
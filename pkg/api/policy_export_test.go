@@ -0,0 +1,61 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportPolicyObjects(t *testing.T) {
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+	b.AddClaim(b.AddUser(), service)
+	aclRule := &lang.ACLRule{
+		TypeKind: lang.TypeACLRule.GetTypeKind(),
+		Metadata: lang.Metadata{Namespace: runtime.SystemNS, Name: "admins"},
+		Criteria: &lang.Criteria{RequireAll: []string{"is_domain_admin"}},
+		Actions:  &lang.ACLRuleActions{AddRole: map[string]string{lang.DomainAdmin.ID: "*"}},
+	}
+	if !assert.NoError(t, b.Policy().AddObject(aclRule), "ACL rule should be added") {
+		t.FailNow()
+	}
+
+	data, err := exportPolicyObjects(b.Policy())
+	if !assert.NoError(t, err, "Policy should be exported") {
+		t.FailNow()
+	}
+	again, err := exportPolicyObjects(b.Policy())
+	if assert.NoError(t, err) {
+		assert.Equal(t, string(data), string(again), "Export of the same policy should be the same")
+	}
+
+	// exported policy should be read the same way policy files are
+	objects, err := codec.NewYAMLCodec(runtime.NewTypes().Append(lang.PolicyTypes...)).DecodeOneOrMany(data)
+	if !assert.NoError(t, err, "Exported policy should be decoded") {
+		t.FailNow()
+	}
+
+	expected := 0
+	for _, info := range lang.PolicyTypes {
+		expected += len(b.Policy().GetObjectsByKind(info.Kind))
+	}
+	if assert.Len(t, objects, expected, "All policy objects should be exported") {
+		assert.Equal(t, lang.TypeACLRule.Kind, objects[0].GetKind(), "ACL rules should go first")
+	}
+
+	imported := lang.NewPolicy()
+	for _, obj := range objects {
+		assert.NoError(t, imported.AddObject(obj.(lang.Base)), "Exported object should be added to policy")
+	}
+	for _, info := range lang.PolicyTypes {
+		assert.Len(t, imported.GetObjectsByKind(info.Kind), len(b.Policy().GetObjectsByKind(info.Kind)), "All objects of kind %s should be imported", info.Kind)
+	}
+}
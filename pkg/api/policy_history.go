@@ -0,0 +1,181 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/julienschmidt/httprouter"
+)
+
+// TypePolicyHistory is an informational data structure with Kind and Constructor for PolicyHistory
+var TypePolicyHistory = &runtime.TypeInfo{
+	Kind:        "policy-history",
+	Constructor: func() runtime.Object { return &PolicyHistory{} },
+}
+
+// PolicyHistory represents a page of policy generations, starting from the latest one and going back in time
+type PolicyHistory struct {
+	runtime.TypeKind `yaml:",inline"`
+	Generations      []*PolicyGenerationSummary
+
+	// Continue is the token to get the next page of policy generations, it's empty if there are no more pages
+	Continue string `yaml:",omitempty"`
+}
+
+// GetDefaultColumns returns default set of columns of policy generations
+func (history *PolicyHistory) GetDefaultColumns() []string {
+	return (&PolicyGenerationSummary{}).GetDefaultColumns()
+}
+
+// AsDisplayableList returns policy generations as a list of displayable objects, so they could be rendered as table
+func (history *PolicyHistory) AsDisplayableList() []runtime.Displayable {
+	result := make([]runtime.Displayable, 0, len(history.Generations))
+	for _, summary := range history.Generations {
+		result = append(result, summary)
+	}
+
+	return result
+}
+
+// PolicyGenerationSummary describes a single policy generation, who made it and when, and how many objects have been
+// changed compared to the previous generation
+type PolicyGenerationSummary struct {
+	Generation runtime.Generation
+	UpdatedAt  time.Time
+	UpdatedBy  string
+	Objects    int
+
+	// Changes are not set if the previous generation has been compacted
+	Changes *PolicyObjectChanges `yaml:",omitempty"`
+}
+
+// PolicyObjectChanges represents the number of policy objects added, changed and removed by a policy generation
+type PolicyObjectChanges struct {
+	Added   int
+	Changed int
+	Removed int
+}
+
+// GetDefaultColumns returns default set of columns to be displayed
+func (summary *PolicyGenerationSummary) GetDefaultColumns() []string {
+	return []string{"Generation", "Time", "Author", "Objects", "Added", "Changed", "Removed"}
+}
+
+// AsColumns returns PolicyGenerationSummary representation as columns
+func (summary *PolicyGenerationSummary) AsColumns() map[string]string {
+	result := map[string]string{
+		"Generation": summary.Generation.String(),
+		"Time":       summary.UpdatedAt.Format(time.RFC3339),
+		"Author":     summary.UpdatedBy,
+		"Objects":    strconv.Itoa(summary.Objects),
+		"Added":      "-",
+		"Changed":    "-",
+		"Removed":    "-",
+	}
+	if summary.Changes != nil {
+		result["Added"] = strconv.Itoa(summary.Changes.Added)
+		result["Changed"] = strconv.Itoa(summary.Changes.Changed)
+		result["Removed"] = strconv.Itoa(summary.Changes.Removed)
+	}
+
+	return result
+}
+
+// newPolicyGenerationSummary returns summary of a given policy generation. Previous generation could be nil, if it
+// doesn't exist (all objects are counted as added then) or it has been compacted (changes are not counted then)
+func newPolicyGenerationSummary(policyData *engine.PolicyData, prevData *engine.PolicyData) *PolicyGenerationSummary {
+	objects := policyObjectGens(policyData)
+	summary := &PolicyGenerationSummary{
+		Generation: policyData.GetGeneration(),
+		UpdatedAt:  policyData.Metadata.UpdatedAt,
+		UpdatedBy:  policyData.Metadata.UpdatedBy,
+		Objects:    len(objects),
+	}
+
+	if prevData == nil && policyData.GetGeneration() > runtime.FirstGen {
+		return summary
+	}
+
+	prevObjects := policyObjectGens(prevData)
+	summary.Changes = &PolicyObjectChanges{}
+	for key, gen := range objects {
+		prevGen, found := prevObjects[key]
+		if !found {
+			summary.Changes.Added++
+		} else if prevGen != gen {
+			summary.Changes.Changed++
+		}
+	}
+	for key := range prevObjects {
+		if _, found := objects[key]; !found {
+			summary.Changes.Removed++
+		}
+	}
+
+	return summary
+}
+
+// policyObjectGens returns generations of all objects referenced by a given policy generation by object keys
+func policyObjectGens(policyData *engine.PolicyData) map[runtime.Key]runtime.Generation {
+	result := make(map[runtime.Key]runtime.Generation)
+	if policyData == nil {
+		return result
+	}
+	for ns, kindNameGen := range policyData.Objects {
+		for kind, nameGen := range kindNameGen {
+			for name, gen := range nameGen {
+				result[runtime.KeyFromParts(ns, kind, name)] = gen
+			}
+		}
+	}
+
+	return result
+}
+
+// handlePolicyHistoryGet returns policy generations along with who made them and when, starting from the latest one.
+// All generations are returned, unless 'limit' is set to get a page of them
+func (api *coreAPI) handlePolicyHistoryGet(writer http.ResponseWriter, request *http.Request, params httprouter.Params) error {
+	limit := 0
+	if value := request.URL.Query().Get("limit"); len(value) > 0 {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return newRequestError(http.StatusBadRequest, ErrorCodeInvalidRequest, "invalid limit '%s'", value)
+		}
+	}
+
+	policies, next, err := api.registry.GetPolicyHistoryPage(limit, request.URL.Query().Get("continue"))
+	if store.IsInvalidContinueToken(err) {
+		return newRequestError(http.StatusBadRequest, ErrorCodeInvalidRequest, "%s", err)
+	}
+	if err != nil {
+		return fmt.Errorf("error while getting policy history: %s", err)
+	}
+
+	result := &PolicyHistory{
+		TypeKind:    TypePolicyHistory.GetTypeKind(),
+		Generations: make([]*PolicyGenerationSummary, 0, len(policies)),
+		Continue:    next,
+	}
+	for idx, policyData := range policies {
+		// generations are going back in time, so the previous generation is usually the next one on the page
+		var prevData *engine.PolicyData
+		if idx+1 < len(policies) && policies[idx+1].GetGeneration() == policyData.GetGeneration()-1 {
+			prevData = policies[idx+1]
+		} else if policyData.GetGeneration() > runtime.FirstGen {
+			prevData, err = api.registry.GetPolicyData(policyData.GetGeneration() - 1)
+			if err != nil {
+				return fmt.Errorf("error while getting policy #%s: %s", policyData.GetGeneration()-1, err)
+			}
+		}
+		result.Generations = append(result.Generations, newPolicyGenerationSummary(policyData, prevData))
+	}
+
+	api.contentType.WriteOne(writer, request, result)
+	return nil
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyHistoryGet(t *testing.T) {
+	reg := registry.New(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()))
+	b := builder.NewPolicyBuilder()
+	server := NewServer(Options{
+		Registry:     reg,
+		ExternalData: b.External(),
+		AuthProvider: &userAuthProvider{user: b.AddUser()},
+	})
+	apiCodec := codec.NewYAMLCodec(runtime.NewTypes().Append(Types...))
+
+	get := func(path string) (int, *PolicyHistory) {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		if recorder.Code != http.StatusOK {
+			return recorder.Code, nil
+		}
+		obj, err := apiCodec.DecodeOne(recorder.Body.Bytes())
+		if !assert.NoError(t, err, "Policy history should be decoded") {
+			t.FailNow()
+		}
+		return recorder.Code, obj.(*PolicyHistory)
+	}
+
+	code, history := get("/api/v1/policy/history")
+	if assert.Equal(t, http.StatusOK, code, "Policy history of empty registry should be returned") {
+		assert.Empty(t, history.Generations, "There should be no policy generations in empty registry")
+	}
+
+	// make a few policy generations: add two clusters, update one of them, then delete another one
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+	cluster1 := b.AddCluster()
+	cluster2 := b.AddCluster()
+	_, _, err := reg.UpdatePolicy([]lang.Base{cluster1, cluster2}, "alice")
+	if !assert.NoError(t, err, "Policy should be updated") {
+		t.FailNow()
+	}
+	cluster1.Labels = map[string]string{"team": "dev"}
+	_, _, err = reg.UpdatePolicy([]lang.Base{cluster1}, "bob")
+	if !assert.NoError(t, err, "Policy should be updated") {
+		t.FailNow()
+	}
+	_, _, err = reg.DeleteFromPolicy([]lang.Base{cluster2}, "carol")
+	if !assert.NoError(t, err, "Policy should be updated") {
+		t.FailNow()
+	}
+
+	code, history = get("/api/v1/policy/history?limit=2")
+	if !assert.Equal(t, http.StatusOK, code, "Policy history should be returned") || !assert.Len(t, history.Generations, 2, "Page of policy history should be returned") {
+		t.FailNow()
+	}
+	assert.EqualValues(t, 4, history.Generations[0].Generation, "Latest generation should be returned first")
+	assert.Equal(t, "carol", history.Generations[0].UpdatedBy)
+	assert.Equal(t, 1, history.Generations[0].Objects)
+	assert.Equal(t, &PolicyObjectChanges{Removed: 1}, history.Generations[0].Changes, "Deleted object should be counted")
+	assert.Equal(t, "bob", history.Generations[1].UpdatedBy)
+	assert.Equal(t, &PolicyObjectChanges{Changed: 1}, history.Generations[1].Changes, "Updated object should be counted")
+	assert.NotEmpty(t, history.Continue, "There should be the next page")
+
+	code, history = get("/api/v1/policy/history?limit=2&continue=" + history.Continue)
+	if !assert.Equal(t, http.StatusOK, code, "Next page of policy history should be returned") || !assert.Len(t, history.Generations, 2, "Page of policy history should be returned") {
+		t.FailNow()
+	}
+	assert.Equal(t, "alice", history.Generations[0].UpdatedBy)
+	assert.Equal(t, &PolicyObjectChanges{Added: 2}, history.Generations[0].Changes, "Added objects should be counted")
+	assert.EqualValues(t, runtime.FirstGen, history.Generations[1].Generation, "First generation should be returned last")
+	assert.Empty(t, history.Continue, "There should be no more pages")
+
+	code, _ = get("/api/v1/policy/history?limit=2&continue=abc")
+	assert.Equal(t, http.StatusBadRequest, code, "Invalid continue token should be reported as bad request")
+
+	// policy history could be exported as csv audit trail
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/policy/history?format=csv&columns=Generation,Author,Added,Changed,Removed", nil))
+	if !assert.Equal(t, http.StatusOK, recorder.Code, "Policy history should be returned as csv") {
+		t.FailNow()
+	}
+	assert.Equal(t, codec.CSV, recorder.Header().Get("Content-Type"))
+	records, err := csv.NewReader(bytes.NewReader(recorder.Body.Bytes())).ReadAll()
+	assert.NoError(t, err, "Produced CSV should be parsed without errors")
+	assert.Equal(t, [][]string{
+		{"Generation", "Author", "Added", "Changed", "Removed"},
+		{"4", "carol", "0", "0", "1"},
+		{"3", "bob", "0", "1", "0"},
+		{"2", "alice", "2", "0", "0"},
+		{"1", history.Generations[1].UpdatedBy, "0", "0", "0"},
+	}, records, "Policy generations should be written as csv rows")
+}
@@ -0,0 +1,249 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/apply/action"
+	"github.com/Aptomi/aptomi/pkg/engine/diff"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/julienschmidt/httprouter"
+	"github.com/sirupsen/logrus"
+)
+
+// AsyncParam is a name of the query parameter, which allows to resolve policy change in the background. Objects get
+// stored in the policy right away and the client gets PolicyApplyJob to poll for the outcome of the change
+const AsyncParam = "async"
+
+// startPolicyApplyJob stores objects in the policy and creates PolicyApplyJob, which resolves the new policy
+// generation and creates the revision for it in the background. The mutex is taken before objects are stored and it's
+// handed over to the job, which releases it once finished, so async policy changes get serialized with each other and
+// with the synchronous ones
+func (api *coreAPI) startPolicyApplyJob(writer http.ResponseWriter, request *http.Request, objects []lang.Base, user *lang.User, prevRevision *engine.Revision, desiredState *resolve.PolicyResolution, eventLog *event.Log, logLevel logrus.Level, continueOnError bool) error {
+	api.policyAndRevisionUpdateMutex.Lock()
+	handedOver := false
+	defer func() {
+		if !handedOver {
+			api.policyAndRevisionUpdateMutex.Unlock()
+		}
+	}()
+
+	// Clean up jobs, which nobody is interested in anymore
+	now := api.clock.Now()
+	if _, err := api.registry.DeleteExpiredPolicyApplyJobs(now); err != nil {
+		return fmt.Errorf("error while deleting expired policy apply jobs: %s", err)
+	}
+
+	// Make object changes in the registry
+	changed, policyData, err := api.registry.UpdatePolicy(objects, user.Name)
+	if store.IsDuplicateValue(err) {
+		return newRequestError(http.StatusConflict, ErrorCodeConflict, "policy change conflicts with concurrent changes: %s", err)
+	}
+	if err != nil {
+		return fmt.Errorf("error while making changes to objects in the policy: %s", err)
+	}
+
+	job, err := api.registry.NewPolicyApplyJob(user.Name, now)
+	if err != nil {
+		return err
+	}
+	job.PolicyGen = policyData.GetGeneration()
+	job.PolicyChanged = changed
+
+	if changed {
+		// desired state of the new generation gets calculated in the background
+		eventLog.NewEntry().Infof("Policy change stored as generation %s, it will be resolved in the background by job %s", policyData.GetGeneration(), job.ID)
+	} else {
+		// nothing has changed, so there is nothing to resolve
+		api.finishPolicyApplyJob(job, &engine.PolicyApplyJobResult{
+			RevisionGen: runtime.MaxGeneration,
+			PlanAsText:  action.NewPlanAsText(),
+			EventLog:    event.FilterAPIEvents(eventLog.AsAPIEvents(), logLevel),
+		}, nil)
+	}
+	err = api.registry.UpdatePolicyApplyJob(job)
+	if err != nil {
+		return err
+	}
+
+	// job gets written before it's started, as it gets modified in the background
+	api.contentType.WriteOneWithStatus(writer, request, job, http.StatusAccepted)
+
+	if changed {
+		handedOver = true
+		go api.runPolicyApplyJob(detachedContext{request.Context()}, job, policyData, prevRevision, desiredState, eventLog, logLevel, continueOnError)
+	}
+
+	return nil
+}
+
+// runPolicyApplyJob resolves policy generation stored by a given job and creates the revision for it, updating the
+// job as it progresses. If policy change can't be made, the policy gets rolled back to the previous generation. It
+// releases policyAndRevisionUpdateMutex, which is taken by startPolicyApplyJob, once finished
+func (api *coreAPI) runPolicyApplyJob(ctx context.Context, job *engine.PolicyApplyJob, policyData *engine.PolicyData, prevRevision *engine.Revision, desiredState *resolve.PolicyResolution, eventLog *event.Log, logLevel logrus.Level, continueOnError bool) {
+	defer api.policyAndRevisionUpdateMutex.Unlock()
+
+	// make sure the job gets finished, even if resolution panics
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			api.failPolicyApplyJob(job, fmt.Errorf("panic: %s", recovered))
+		}
+	}()
+
+	job.State = engine.PolicyApplyJobStateRunning
+	job.StartedAt = api.clock.Now()
+	api.updatePolicyApplyJobProgress(job, "resolving policy")
+
+	policy, _, err := api.registry.GetPolicy(job.PolicyGen)
+	if err != nil {
+		api.failPolicyApplyJob(job, fmt.Errorf("error while loading policy: %s", err))
+		return
+	}
+
+	resolver := resolve.NewPolicyResolver(policy, api.externalData, eventLog).SetPluginRegistry(api.pluginRegistryFactory()).SetContinueOnError(continueOnError)
+	desiredStateUpdated, err := resolver.ResolveAllClaims(ctx)
+	if err == nil {
+		err = desiredStateUpdated.Validate(policy)
+	}
+	if err != nil {
+		api.failPolicyApplyJob(job, fmt.Errorf("policy change cannot be made: %s", err))
+		return
+	}
+
+	_, diffSpan := startSpan(ctx, SpanDiff)
+	actionPlan := diff.NewPolicyResolutionDiff(desiredStateUpdated, desiredState).ActionPlan
+	diffSpan.End()
+
+	api.updatePolicyApplyJobProgress(job, "creating revision")
+	events := eventLog.AsAPIEvents()
+	change, err := api.applyPolicyChangeLocked(ctx, prevRevision, desiredStateUpdated, events, actionPlan.NumberOfActions() > 0, func(reg registry.Interface) (bool, *engine.PolicyData, error) {
+		// objects have been stored already, when the job was created
+		return true, policyData, nil
+	})
+	if err != nil {
+		api.failPolicyApplyJob(job, err)
+		return
+	}
+	api.cacheResolution(change.PolicyGen, desiredStateUpdated, events, eventLog.GetLevel())
+
+	claimsResolved, claimsTotal, failedClaims := claimsResolution(resolver, policy, continueOnError)
+	api.finishPolicyApplyJob(job, &engine.PolicyApplyJobResult{
+		RevisionGen:    change.RevisionGen,
+		EnforcementID:  change.EnforcementID,
+		PlanAsText:     actionPlan.AsText(),
+		EventLog:       event.FilterAPIEvents(events, logLevel),
+		ClaimsResolved: claimsResolved,
+		ClaimsTotal:    claimsTotal,
+		FailedClaims:   failedClaims,
+	}, nil)
+	api.savePolicyApplyJob(job)
+}
+
+// failPolicyApplyJob finishes a given job with a given error and rolls back the policy generation stored by the job,
+// so the policy doesn't contain objects, which haven't been resolved. It's called while holding the mutex
+func (api *coreAPI) failPolicyApplyJob(job *engine.PolicyApplyJob, cause error) {
+	_, _, err := api.registry.RollbackPolicy(job.PolicyGen-1, job.CreatedBy)
+	if err != nil {
+		cause = fmt.Errorf("%s (policy generation %s can't be rolled back: %s)", cause, job.PolicyGen, err)
+	}
+
+	api.finishPolicyApplyJob(job, nil, cause)
+	api.savePolicyApplyJob(job)
+}
+
+// failInterruptedPolicyApplyJobs fails all jobs, which have been left pending or running, as the server has been
+// stopped before they finished. Policy generation stored by such job gets rolled back, unless the revision has been
+// created for it already or it has been followed by other policy changes
+func (api *coreAPI) failInterruptedPolicyApplyJobs() error {
+	api.policyAndRevisionUpdateMutex.Lock()
+	defer api.policyAndRevisionUpdateMutex.Unlock()
+
+	jobs, err := api.registry.GetUnfinishedPolicyApplyJobs()
+	if err != nil {
+		return err
+	}
+
+	policyData, err := api.registry.GetPolicyData(runtime.LastOrEmptyGen)
+	if err != nil {
+		return fmt.Errorf("error while getting latest policy: %s", err)
+	}
+
+	for _, job := range jobs {
+		cause := fmt.Errorf("policy change has been interrupted by server restart")
+
+		// policy generation might not have been recorded by the job before the server stopped
+		if job.PolicyGen == runtime.LastOrEmptyGen || policyData == nil {
+			api.finishPolicyApplyJob(job, nil, cause)
+			api.savePolicyApplyJob(job)
+			continue
+		}
+		if job.PolicyGen != policyData.GetGeneration() {
+			api.finishPolicyApplyJob(job, nil, fmt.Errorf("%s (policy generation %s has been kept, as it's been followed by other policy changes)", cause, job.PolicyGen))
+			api.savePolicyApplyJob(job)
+			continue
+		}
+
+		revision, errRevision := api.registry.GetLastRevisionForPolicy(job.PolicyGen)
+		if errRevision != nil {
+			return fmt.Errorf("error while getting revision for policy generation %s: %s", job.PolicyGen, errRevision)
+		}
+		if revision != nil {
+			api.finishPolicyApplyJob(job, nil, fmt.Errorf("%s (policy generation %s has been kept, as revision %s has been created for it)", cause, job.PolicyGen, revision.GetGeneration()))
+			api.savePolicyApplyJob(job)
+			continue
+		}
+
+		api.failPolicyApplyJob(job, cause)
+		logrus.Warnf("Policy apply job %s has been interrupted by server restart, policy generation %s has been rolled back", job.ID, job.PolicyGen)
+	}
+
+	return nil
+}
+
+// finishPolicyApplyJob marks a given job as finished with a given result or error and sets its expiration time
+func (api *coreAPI) finishPolicyApplyJob(job *engine.PolicyApplyJob, result *engine.PolicyApplyJobResult, err error) {
+	job.FinishedAt = api.clock.Now()
+	job.ExpiresAt = job.FinishedAt.Add(api.policyJobRetention)
+	job.Progress = "done"
+	job.Result = result
+	job.State = engine.PolicyApplyJobStateSucceeded
+	if err != nil {
+		job.State = engine.PolicyApplyJobStateFailed
+		job.Error = err.Error()
+	}
+}
+
+// updatePolicyApplyJobProgress saves a given job with a given description of the step it's making
+func (api *coreAPI) updatePolicyApplyJobProgress(job *engine.PolicyApplyJob, progress string) {
+	job.Progress = progress
+	api.savePolicyApplyJob(job)
+}
+
+// savePolicyApplyJob saves a given job from the background, where the error can only be logged. If the job doesn't
+// get saved, client will see it in its previous state until it expires
+func (api *coreAPI) savePolicyApplyJob(job *engine.PolicyApplyJob) {
+	if err := api.registry.UpdatePolicyApplyJob(job); err != nil {
+		logrus.Errorf("error while saving policy apply job %s: %s", job.ID, err)
+	}
+}
+
+func (api *coreAPI) handlePolicyApplyJobGet(writer http.ResponseWriter, request *http.Request, params httprouter.Params) error {
+	id := params.ByName("id")
+	job, err := api.registry.GetPolicyApplyJob(id)
+	if err != nil {
+		return fmt.Errorf("error while getting requested policy apply job: %s", err)
+	}
+	if job == nil || job.IsExpired(api.clock.Now()) {
+		return newRequestError(http.StatusNotFound, ErrorCodeNotFound, "policy apply job %s doesn't exist or has expired", id)
+	}
+
+	api.contentType.WriteOne(writer, request, job)
+	return nil
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/config"
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+	"github.com/Aptomi/aptomi/pkg/plugin/fake"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyApplyJob(t *testing.T) {
+	reg := registry.New(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()))
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	rule := b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+	user := b.AddUser()
+	user.DomainAdmin = true
+	claim := b.AddClaim(user, service)
+
+	server := NewServer(Options{
+		Registry:     reg,
+		ExternalData: b.External(),
+		PluginRegistryFactory: func() plugin.Registry {
+			return plugin.NewRegistry(
+				config.Plugins{},
+				map[string]plugin.ClusterPluginConstructor{
+					"kubernetes": func(cluster *lang.Cluster, cfg config.Plugins) (plugin.ClusterPlugin, error) {
+						return fake.NewNoOpClusterPlugin(0), nil
+					},
+				},
+				map[string]map[string]plugin.CodePluginConstructor{
+					"kubernetes": {
+						"helm": func(cluster plugin.ClusterPlugin, cfg config.Plugins) (plugin.CodePlugin, error) {
+							return fake.NewNoOpCodePlugin(0), nil
+						},
+					},
+				},
+			)
+		},
+		AuthProvider: &userAuthProvider{user: user},
+	})
+	apiCodec := codec.NewYAMLCodec(runtime.NewTypes().Append(Types...))
+
+	// start async policy update and wait for the job to finish
+	apply := func(objects ...runtime.Object) *engine.PolicyApplyJob {
+		body, err := apiCodec.EncodeMany(objects)
+		if !assert.NoError(t, err, "Policy objects should be encoded") {
+			t.FailNow()
+		}
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("POST", "/api/v1/policy/noop/false/loglevel/warning?async=true", bytes.NewReader(body)))
+		if !assert.Equal(t, http.StatusAccepted, recorder.Code, "Policy update should be accepted: %s", recorder.Body.String()) {
+			t.FailNow()
+		}
+		obj, err := apiCodec.DecodeOne(recorder.Body.Bytes())
+		if !assert.NoError(t, err, "Policy apply job should be decoded") {
+			t.FailNow()
+		}
+		job := obj.(*engine.PolicyApplyJob)
+		if !assert.NotEmpty(t, job.ID, "Job should have an ID") {
+			t.FailNow()
+		}
+
+		for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			recorder = httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/policy/job/"+job.ID, nil))
+			if !assert.Equal(t, http.StatusOK, recorder.Code, "Job should be returned: %s", recorder.Body.String()) {
+				t.FailNow()
+			}
+			obj, err = apiCodec.DecodeOne(recorder.Body.Bytes())
+			if !assert.NoError(t, err, "Policy apply job should be decoded") {
+				t.FailNow()
+			}
+			job = obj.(*engine.PolicyApplyJob)
+			if job.IsFinished() {
+				return job
+			}
+		}
+		assert.Fail(t, "Job should be finished", "job %s is %s", job.ID, job.State)
+		t.FailNow()
+		return nil
+	}
+
+	job := apply(cluster, bundle, service, rule, claim)
+	assert.Equal(t, engine.PolicyApplyJobStateSucceeded, job.State, "Job should succeed: %s", job.Error)
+	assert.True(t, job.PolicyChanged, "Policy should be changed")
+	assert.EqualValues(t, 2, job.PolicyGen, "Objects should be stored in the new policy generation")
+	if assert.NotNil(t, job.Result, "Job should have the result") {
+		assert.NotEqual(t, runtime.MaxGeneration, job.Result.RevisionGen, "Revision should be created")
+		assert.NotEmpty(t, job.Result.PlanAsText.Actions, "Action plan should be returned")
+		assert.Zero(t, job.Result.ClaimsTotal, "Claims shouldn't be counted without continueOnError")
+	}
+	revision, err := reg.GetLastRevisionForPolicy(job.PolicyGen)
+	if assert.NoError(t, err, "Revision should be loaded") && assert.NotNil(t, revision, "Revision should be created for the policy") {
+		assert.Equal(t, job.Result.RevisionGen, revision.GetGeneration(), "Job should return the created revision")
+	}
+
+	// claim, which can't be resolved, should fail the job and the policy should be rolled back
+	serviceUnmatched := b.AddService(bundle, b.Criteria("label1 == 'value1'", "true", "false"))
+	claimUnmatched := b.AddClaim(user, serviceUnmatched)
+	job = apply(serviceUnmatched, claimUnmatched)
+	assert.Equal(t, engine.PolicyApplyJobStateFailed, job.State, "Job should fail")
+	assert.Contains(t, job.Error, "can't be resolved", "Job should report the failure")
+	policy, policyGen, err := reg.GetPolicy(runtime.LastOrEmptyGen)
+	if assert.NoError(t, err, "Policy should be loaded") {
+		assert.EqualValues(t, 4, policyGen, "Policy should be rolled back as a new generation")
+		obj, _ := policy.GetObject(lang.TypeClaim.Kind, claimUnmatched.Name, claimUnmatched.Namespace)
+		assert.Nil(t, obj, "Claim should be removed from the policy")
+	}
+
+	// unknown jobs and noop mode
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/policy/job/missing", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code, "Missing job should not be found")
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest("POST", "/api/v1/policy/noop/true/loglevel/warning?async=true", bytes.NewReader([]byte{})))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code, "Async policy update should not be allowed in noop mode")
+}
+
+func TestFailInterruptedPolicyApplyJobs(t *testing.T) {
+	reg := registry.New(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()))
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	b := builder.NewPolicyBuilder()
+	bundle := b.AddBundle()
+	service := b.AddService(bundle, b.CriteriaTrue())
+	user := b.AddUser()
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// jobs left running, as if the server has been stopped in the middle of async policy change
+	newJob := func(objects ...lang.Base) *engine.PolicyApplyJob {
+		_, policyData, err := reg.UpdatePolicy(objects, user.Name)
+		if !assert.NoError(t, err, "Policy should be updated") {
+			t.FailNow()
+		}
+		job, err := reg.NewPolicyApplyJob(user.Name, now)
+		if !assert.NoError(t, err, "Job should be created") {
+			t.FailNow()
+		}
+		job.PolicyGen = policyData.GetGeneration()
+		job.PolicyChanged = true
+		job.State = engine.PolicyApplyJobStateRunning
+		if !assert.NoError(t, reg.UpdatePolicyApplyJob(job), "Job should be updated") {
+			t.FailNow()
+		}
+		return job
+	}
+	followed := newJob(bundle)
+	interrupted := newJob(service)
+
+	// policy generation of the last job should be rolled back, while the one followed by other changes should be kept
+
+	server := NewServer(Options{
+		Registry:           reg,
+		ExternalData:       b.External(),
+		PolicyJobRetention: time.Hour,
+		Clock:              &fixedClock{now: now},
+	})
+	if !assert.NoError(t, server.FailInterruptedPolicyApplyJobs(), "Interrupted jobs should be failed") {
+		t.FailNow()
+	}
+
+	for _, job := range []*engine.PolicyApplyJob{followed, interrupted} {
+		loaded, err := reg.GetPolicyApplyJob(job.ID)
+		if assert.NoError(t, err, "Job should be loaded") && assert.NotNil(t, loaded, "Job should exist") {
+			assert.Equal(t, engine.PolicyApplyJobStateFailed, loaded.State, "Interrupted job should fail")
+			assert.Contains(t, loaded.Error, "interrupted by server restart", "Job should report the failure")
+			assert.True(t, now.Add(time.Hour).Equal(loaded.ExpiresAt), "Interrupted job should expire")
+		}
+	}
+
+	policy, policyGen, err := reg.GetPolicy(runtime.LastOrEmptyGen)
+	if assert.NoError(t, err, "Policy should be loaded") {
+		assert.EqualValues(t, interrupted.PolicyGen+1, policyGen, "Policy should be rolled back as a new generation")
+		obj, _ := policy.GetObject(lang.TypeService.Kind, service.Name, service.Namespace)
+		assert.Nil(t, obj, "Service stored by interrupted job should be removed from the policy")
+		obj, _ = policy.GetObject(lang.TypeBundle.Kind, bundle.Name, bundle.Namespace)
+		assert.NotNil(t, obj, "Bundle stored by job followed by other changes should be kept")
+	}
+
+	unfinished, err := reg.GetUnfinishedPolicyApplyJobs()
+	assert.NoError(t, err, "Unfinished jobs should be loaded")
+	assert.Empty(t, unfinished, "No unfinished jobs should be left")
+}
+
+// fixedClock is a Clock, which always returns the same time
+type fixedClock struct {
+	now time.Time
+}
+
+func (clock *fixedClock) Now() time.Time {
+	return clock.now
+}
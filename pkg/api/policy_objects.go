@@ -0,0 +1,95 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/expression"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/julienschmidt/httprouter"
+)
+
+// LabelParam is a name of the query parameter with label expression, which listed policy objects should match. It
+// could be repeated, all expressions should match then
+const LabelParam = "label"
+
+// handlePolicyObjectsList returns all objects of a given kind within a given namespace from policy with a given
+// generation, optionally filtered by label expressions. Empty list is returned if there are no such objects
+func (api *coreAPI) handlePolicyObjectsList(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	gen := params.ByName("gen")
+
+	if len(gen) == 0 {
+		gen = strconv.Itoa(int(runtime.LastOrEmptyGen))
+	}
+
+	ns := params.ByName("ns")
+	kind := params.ByName("kind")
+	if !lang.IsPolicyObject(&runtime.TypeKind{Kind: kind}) {
+		serverErr := NewServerError(fmt.Sprintf("unknown policy object kind: %s", kind))
+		api.contentType.WriteOneWithStatus(writer, request, serverErr, http.StatusBadRequest)
+		return
+	}
+
+	policy, _, err := api.registry.GetPolicy(runtime.ParseGeneration(gen))
+	if err != nil {
+		panic(fmt.Sprintf("error while getting requested policy: %s", err))
+	}
+	if policy == nil {
+		serverErr := NewServerError(fmt.Sprintf("policy generation %s doesn't exist or was compacted", gen))
+		api.contentType.WriteOneWithStatus(writer, request, serverErr, http.StatusNotFound)
+		return
+	}
+
+	objects, err := filterPolicyObjects(policy, ns, kind, &lang.Criteria{RequireAll: request.URL.Query()[LabelParam]})
+	if err != nil {
+		serverErr := NewServerError(fmt.Sprintf("invalid label expression: %s", err))
+		api.contentType.WriteOneWithStatus(writer, request, serverErr, http.StatusBadRequest)
+		return
+	}
+
+	api.contentType.WriteMany(writer, request, objects)
+}
+
+// filterPolicyObjects returns objects of a given kind within a given namespace sorted by name, which labels match
+// a given criteria. Objects without labels are matched against the empty set of labels
+func filterPolicyObjects(policy *lang.Policy, ns string, kind string, criteria *lang.Criteria) ([]runtime.Object, error) {
+	// expressions are compiled upfront, so invalid ones are reported even if there are no objects to match
+	cache := expression.NewCache()
+	for _, expr := range criteria.RequireAll {
+		if _, err := cache.Compile(expr); err != nil {
+			return nil, err
+		}
+	}
+
+	found := make([]lang.Base, 0)
+	for _, obj := range policy.GetObjectsByKind(kind) {
+		if obj.GetNamespace() != ns {
+			continue
+		}
+
+		labels := map[string]string{}
+		if labeled, ok := obj.(lang.Labeled); ok {
+			labels = labeled.GetLabels()
+		}
+		matches, err := criteria.MatchesLabels(labels, cache)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			found = append(found, obj)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].GetName() < found[j].GetName()
+	})
+
+	result := make([]runtime.Object, 0, len(found))
+	for _, obj := range found {
+		result = append(result, obj)
+	}
+	return result, nil
+}
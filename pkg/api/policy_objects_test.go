@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterPolicyObjects(t *testing.T) {
+	b := builder.NewPolicyBuilder()
+	dev := b.AddCluster()
+	dev.Labels = map[string]string{"team": "dev"}
+	prod := b.AddCluster()
+	prod.Labels = map[string]string{"team": "prod"}
+	b.AddCluster()
+
+	objects, err := filterPolicyObjects(b.Policy(), runtime.SystemNS, lang.TypeCluster.Kind, &lang.Criteria{})
+	assert.NoError(t, err)
+	assert.Len(t, objects, 3, "All clusters should be listed without label expressions")
+
+	objects, err = filterPolicyObjects(b.Policy(), runtime.SystemNS, lang.TypeCluster.Kind, &lang.Criteria{RequireAll: []string{"team == 'dev'"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []runtime.Object{dev}, objects, "Only clusters with matching labels should be listed")
+
+	objects, err = filterPolicyObjects(b.Policy(), "main", lang.TypeCluster.Kind, &lang.Criteria{})
+	assert.NoError(t, err)
+	assert.Empty(t, objects, "Objects from other namespaces shouldn't be listed")
+
+	_, err = filterPolicyObjects(b.Policy(), "main", lang.TypeCluster.Kind, &lang.Criteria{RequireAll: []string{"team == "}})
+	assert.Error(t, err, "Invalid expression should be reported even if there are no objects")
+}
+
+func TestPolicyObjectsList(t *testing.T) {
+	reg := registry.New(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()))
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+	b := builder.NewPolicyBuilder()
+	server := NewServer(Options{
+		Registry:     reg,
+		ExternalData: b.External(),
+		AuthProvider: &userAuthProvider{user: b.AddUser()},
+	})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		return recorder
+	}
+
+	recorder := get("/api/v1/policy/gen/1/objects/main/bundle")
+	if assert.Equal(t, http.StatusOK, recorder.Code, "Empty list should be returned: %s", recorder.Body.String()) {
+		objects, err := codec.NewYAMLCodec(runtime.NewTypes().Append(Types...)).DecodeOneOrMany(recorder.Body.Bytes())
+		assert.NoError(t, err)
+		assert.Empty(t, objects)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, get("/api/v1/policy/gen/1/objects/main/unknown").Code, "Unknown kind should be reported")
+	assert.Equal(t, http.StatusNotFound, get("/api/v1/policy/gen/42/objects/main/bundle").Code, "Missing policy should be reported")
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/apply/action"
+	"github.com/Aptomi/aptomi/pkg/engine/pipeline"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/julienschmidt/httprouter"
+	"github.com/sirupsen/logrus"
+)
+
+// getResolutionPriority returns whether policy change should be queued for resolution in the background and with
+// which priority. Priority can be requested explicitly, otherwise only policy changes with more objects than the
+// configured threshold get queued with interactive priority, as well as policy changes made while the latest revision is
+// still being resolved in the background (so they don't get ahead of it). Noop policy changes are always resolved
+// synchronously, as they have to return the action plan. Priority, which can't be used, is reported as bad request
+func (api *coreAPI) getResolutionPriority(params httprouter.Params, objects []lang.Base, noop bool, revision *engine.Revision) (string, bool, error) {
+	priority := params.ByName("priority")
+	if len(priority) > 0 {
+		if api.pipeline == nil {
+			return "", false, newRequestError(http.StatusBadRequest, ErrorCodeInvalidRequest, "policy changes can't be queued, revision pipeline is disabled in server config")
+		}
+		if engine.ResolutionPriorityRank(priority) < 0 {
+			return "", false, newRequestError(http.StatusBadRequest, ErrorCodeInvalidRequest, "unknown resolution priority '%s', should be one of %v", priority, engine.ResolutionPriorities)
+		}
+		return priority, true, nil
+	}
+
+	if api.pipeline == nil || noop {
+		return "", false, nil
+	}
+	if len(objects) <= api.pipelineSyncMaxObjects && revision.Status != engine.RevisionStatusResolving {
+		return "", false, nil
+	}
+	return engine.ResolutionPriorityInteractive, true, nil
+}
+
+// queuePolicyChange makes object changes in the registry and queues the new policy generation for resolution in the
+// background. If the queue is full, policy doesn't get changed and the client is asked to retry later
+func (api *coreAPI) queuePolicyChange(writer http.ResponseWriter, request *http.Request, objects []lang.Base, user *lang.User, priority string, delete bool, eventLog *event.Log, logLevel logrus.Level) error {
+	changed, policyGen, revisionGen, err := api.changePolicyQueued(objects, user, priority, delete)
+	if err == pipeline.ErrQueueFull {
+		return newRequestError(http.StatusServiceUnavailable, ErrorCodeQueueFull, "policy change can't be made right now: %s, retry later", err)
+	}
+	if err != nil {
+		return fmt.Errorf("error while queueing policy change: %s", err)
+	}
+
+	if changed {
+		eventLog.NewEntry().Infof("Policy change queued for resolution with priority '%s' as revision %d", priority, revisionGen)
+	}
+
+	api.contentType.WriteOne(writer, request, &PolicyUpdateResult{
+		TypeKind:         TypePolicyUpdateResult.GetTypeKind(),
+		PolicyChanged:    changed,                                                 // have any policy object in the registry been changed or not
+		PolicyGeneration: policyGen,                                               // policy now has a new generation
+		WaitForRevision:  revisionGen,                                             // which revision to wait for
+		PlanAsText:       action.NewPlanAsText(),                                  // action plan will be calculated in the background
+		EventLog:         event.FilterAPIEvents(eventLog.AsAPIEvents(), logLevel), // return policy validation log
+		Queued:           changed,
+	})
+	return nil
+}
+
+// changePolicyQueued makes object changes in the registry and queues the new policy generation for resolution. It
+// returns pipeline.ErrQueueFull without making any changes if the queue is full
+func (api *coreAPI) changePolicyQueued(objects []lang.Base, user *lang.User, priority string, delete bool) (bool, runtime.Generation, runtime.Generation, error) {
+	// Make sure to take the mutex, before making any policy and revision changes
+	api.policyAndRevisionUpdateMutex.Lock()
+	defer api.policyAndRevisionUpdateMutex.Unlock()
+
+	// Nobody else adds to the queue while we are holding the mutex, so it can't get full after this check
+	full, err := api.pipeline.IsFull()
+	if err != nil {
+		return false, 0, 0, err
+	}
+	if full {
+		return false, 0, 0, pipeline.ErrQueueFull
+	}
+
+	// Make object changes in the registry
+	var changed bool
+	var policyData *engine.PolicyData
+	if delete {
+		changed, policyData, err = api.registry.DeleteFromPolicy(objects, user.Name)
+	} else {
+		changed, policyData, err = api.registry.UpdatePolicy(objects, user.Name)
+	}
+	if err != nil {
+		return false, 0, 0, fmt.Errorf("error while making changes to objects in the policy: %s", err)
+	}
+
+	// If there are changes, queue a new revision and say that we should wait for it
+	revisionGen := runtime.MaxGeneration
+	if changed {
+		// desired state of the new generation gets calculated in the background
+		revision, enqueueErr := api.pipeline.Enqueue(policyData.GetGeneration(), priority, user.Name)
+		if enqueueErr != nil {
+			return false, 0, 0, fmt.Errorf("unable to queue policy gen %d for resolution: %s", policyData.GetGeneration(), enqueueErr)
+		}
+		revisionGen = revision.GetGeneration()
+	}
+
+	return changed, policyData.GetGeneration(), revisionGen, nil
+}
+
+// TypeEnforcementStatus is an informational data structure with Kind and Constructor for EnforcementStatus
+var TypeEnforcementStatus = &runtime.TypeInfo{
+	Kind:        "enforcement-status",
+	Constructor: func() runtime.Object { return &EnforcementStatus{} },
+}
+
+// EnforcementStatus shows the backlog of policy changes: the state of the resolution queue and revisions, which
+// haven't been enforced yet
+type EnforcementStatus struct {
+	runtime.TypeKind `yaml:",inline"`
+
+	// Queue is the state of the resolution queue or nil if revision pipeline is disabled
+	Queue *pipeline.Status
+
+	// Unprocessed is the list of revisions, which haven't been enforced yet, in the order they will be processed
+	Unprocessed []*UnprocessedRevision
+}
+
+// UnprocessedRevision is a short summary of a revision, which hasn't been enforced yet
+type UnprocessedRevision struct {
+	Revision  runtime.Generation
+	PolicyGen runtime.Generation
+	Status    string
+	Waiting   time.Duration
+}
+
+func (api *coreAPI) handleEnforcementStatusGet(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	status := &EnforcementStatus{
+		TypeKind:    TypeEnforcementStatus.GetTypeKind(),
+		Unprocessed: []*UnprocessedRevision{},
+	}
+
+	if api.pipeline != nil {
+		queueStatus, err := api.pipeline.Status()
+		if err != nil {
+			panic(fmt.Sprintf("error while getting resolution queue status: %s", err))
+		}
+		status.Queue = queueStatus
+	}
+
+	revisions, err := api.registry.GetUnprocessedRevisions()
+	if err != nil {
+		panic(fmt.Sprintf("error while loading unprocessed revisions: %s", err))
+	}
+	now := api.clock.Now()
+	for _, revision := range revisions {
+		status.Unprocessed = append(status.Unprocessed, &UnprocessedRevision{
+			Revision:  revision.GetGeneration(),
+			PolicyGen: revision.PolicyGen,
+			Status:    revision.Status,
+			Waiting:   now.Sub(revision.CreatedAt),
+		})
+	}
+
+	api.contentType.WriteOne(writer, request, status)
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/config"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+	"github.com/Aptomi/aptomi/pkg/plugin/fake"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyRollback(t *testing.T) {
+	reg := registry.New(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()))
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	rule := b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+	user := b.AddUser()
+	user.DomainAdmin = true
+	claim := b.AddClaim(user, service)
+	extraBundle := b.AddBundle()
+	b.AddBundleComponent(extraBundle, b.CodeComponent(nil, nil))
+
+	server := NewServer(Options{
+		Registry:     reg,
+		ExternalData: b.External(),
+		PluginRegistryFactory: func() plugin.Registry {
+			return plugin.NewRegistry(
+				config.Plugins{},
+				map[string]plugin.ClusterPluginConstructor{
+					"kubernetes": func(cluster *lang.Cluster, cfg config.Plugins) (plugin.ClusterPlugin, error) {
+						return fake.NewNoOpClusterPlugin(0), nil
+					},
+				},
+				map[string]map[string]plugin.CodePluginConstructor{
+					"kubernetes": {
+						"helm": func(cluster plugin.ClusterPlugin, cfg config.Plugins) (plugin.CodePlugin, error) {
+							return fake.NewNoOpCodePlugin(0), nil
+						},
+					},
+				},
+			)
+		},
+		AuthProvider: &userAuthProvider{user: user},
+	})
+	apiCodec := codec.NewYAMLCodec(runtime.NewTypes().Append(Types...))
+
+	post := func(path string, objects ...runtime.Object) *PolicyUpdateResult {
+		var body []byte
+		if len(objects) > 0 {
+			var err error
+			body, err = apiCodec.EncodeMany(objects)
+			if !assert.NoError(t, err, "Policy objects should be encoded") {
+				t.FailNow()
+			}
+		}
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("POST", path, bytes.NewReader(body)))
+		if !assert.Equal(t, http.StatusOK, recorder.Code, "Request should be successful: %s", recorder.Body.String()) {
+			t.FailNow()
+		}
+		obj, err := apiCodec.DecodeOne(recorder.Body.Bytes())
+		if !assert.NoError(t, err, "Policy update result should be decoded") {
+			t.FailNow()
+		}
+		return obj.(*PolicyUpdateResult)
+	}
+
+	// commit policy as generation 2 and add one more bundle as generation 3
+	committed := post("/api/v1/policy/noop/false/loglevel/warning", cluster, bundle, service, rule, claim)
+	post("/api/v1/policy/noop/false/loglevel/warning", extraBundle)
+
+	// noop rollback should only return the action plan
+	result := post("/api/v1/policy/rollback/1/noop/true/loglevel/warning")
+	assert.False(t, result.PolicyChanged, "Policy shouldn't be changed in noop mode")
+	assert.EqualValues(t, 3, result.PolicyGeneration, "Policy generation shouldn't change in noop mode")
+	assert.EqualValues(t, 1, result.RolledBackTo, "Target generation should be returned")
+	assert.NotEmpty(t, result.PlanAsText.Actions, "Rolling back to empty policy should result in actions")
+	_, policyGen, err := reg.GetPolicy(runtime.LastOrEmptyGen)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, policyGen, "Noop rollback shouldn't change policy")
+
+	// rollback should restore objects of generation 2 and remove the ones added since then
+	result = post("/api/v1/policy/rollback/2/noop/false/loglevel/warning")
+	assert.True(t, result.PolicyChanged, "Policy should be changed by rollback")
+	assert.EqualValues(t, 4, result.PolicyGeneration, "Rollback should make a new policy generation")
+	assert.EqualValues(t, 2, result.RolledBackTo, "Target generation should be returned")
+
+	// desired state of generation 2 is taken from cache, so resolution log of the rollback revision should have the
+	// events logged while generation 2 was resolved
+	committedLog, err := reg.GetResolutionLog(committed.WaitForRevision)
+	if assert.NoError(t, err) && assert.NotNil(t, committedLog, "Resolution log should be saved") {
+		rollbackLog, errLog := reg.GetResolutionLog(result.WaitForRevision)
+		if assert.NoError(t, errLog) && assert.NotNil(t, rollbackLog, "Resolution log should be saved for rollback") {
+			messages := make(map[string]bool)
+			for _, e := range rollbackLog.Events {
+				messages[e.Message] = true
+			}
+			assert.NotEmpty(t, committedLog.Events, "Resolution events should be logged")
+			for _, e := range committedLog.Events {
+				assert.True(t, messages[e.Message], "Cached resolution event should be replayed: %s", e.Message)
+			}
+		}
+	}
+
+	policy, _, err := reg.GetPolicy(4)
+	if !assert.NoError(t, err, "Rolled back policy should be loaded") {
+		t.FailNow()
+	}
+	obj, err := policy.GetObject(lang.TypeBundle.Kind, bundle.Name, bundle.Namespace)
+	assert.NoError(t, err)
+	assert.NotNil(t, obj, "Bundle from generation 2 should be kept")
+	obj, err = policy.GetObject(lang.TypeBundle.Kind, extraBundle.Name, extraBundle.Namespace)
+	assert.NoError(t, err)
+	assert.Nil(t, obj, "Bundle added after generation 2 should be removed")
+}
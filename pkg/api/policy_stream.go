@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/event"
+	log "github.com/sirupsen/logrus"
+)
+
+// Names of the Server-Sent Events streamed by the policy update endpoint, if client requested text/event-stream
+const (
+	// StreamEventLog is the event carrying a single APIEvent of the resolution log as soon as it's logged
+	StreamEventLog = "event"
+	// StreamEventResult is the final event carrying the complete PolicyUpdateResult
+	StreamEventResult = "result"
+	// StreamEventError is the final event carrying ServerError, if policy update failed after streaming has started
+	StreamEventError = "error"
+)
+
+// startEventStream starts streaming events of a given log of a given level (or more severe ones) as Server-Sent Events,
+// if client requested it. It returns nil if client expects a single object in response instead
+func (api *coreAPI) startEventStream(writer http.ResponseWriter, request *http.Request, eventLog *event.Log, logLevel log.Level) *codec.SSEWriter {
+	if !codec.IsEventStreamRequested(request) {
+		return nil
+	}
+
+	stream := api.contentType.NewSSEWriter(writer, http.StatusOK)
+	eventLog.AddStreamHook(logLevel, func(e *event.APIEvent) {
+		if err := stream.Write(StreamEventLog, e); err != nil {
+			// client has most likely disconnected, it'll get the complete event log from the resolution log anyway
+			log.WithField("request", request).Debugf("Error while streaming event log: %s", err)
+		}
+	})
+	return stream
+}
+
+// detachedContext keeps values of the parent context (e.g. tracing span), but never gets canceled
+type detachedContext struct {
+	context.Context
+}
+
+func (ctx detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (ctx detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (ctx detachedContext) Err() error {
+	return nil
+}
+
+// policyUpdateContext returns context to finish policy update with. When events are streamed, client may disconnect
+// before the update is done, so request cancellation is ignored and policy update gets finished anyway
+func policyUpdateContext(request *http.Request, stream *codec.SSEWriter) context.Context {
+	if stream == nil {
+		return request.Context()
+	}
+	return detachedContext{request.Context()}
+}
+
+// writePolicyUpdateResult calls a given function to finish policy update and writes its result either as a single
+// object or as the final message of the event stream. Response status can't be changed once the stream has started,
+// so errors (including panics) are reported as the final stream message instead
+func (api *coreAPI) writePolicyUpdateResult(writer http.ResponseWriter, request *http.Request, stream *codec.SSEWriter, finish func() (*PolicyUpdateResult, error)) (err error) {
+	if stream == nil {
+		result, finishErr := finish()
+		if finishErr != nil {
+			return finishErr
+		}
+		api.contentType.WriteOne(writer, request, result)
+		return nil
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			recoveredErr, ok := recovered.(error)
+			if !ok {
+				recoveredErr = fmt.Errorf("%s", recovered)
+			}
+			api.writeStreamError(request, stream, recoveredErr)
+		}
+	}()
+
+	result, err := finish()
+	if err != nil {
+		api.writeStreamError(request, stream, err)
+		return nil
+	}
+	if err = stream.Write(StreamEventResult, result); err != nil {
+		log.WithField("request", request).Warnf("Error while streaming policy update result: %s", err)
+	}
+	return nil
+}
+
+// writeStreamError writes a given error as ServerError into the event stream, with the error code corresponding to it
+func (api *coreAPI) writeStreamError(request *http.Request, stream *codec.SSEWriter, err error) {
+	serverErr, status := ErrorResponse(err)
+	if status >= http.StatusInternalServerError {
+		log.WithField("request", request).Errorf("Error while serving request: %s", err)
+	}
+	if writeErr := stream.Write(StreamEventError, serverErr); writeErr != nil {
+		log.WithField("request", request).Warnf("Error while streaming error: %s", writeErr)
+	}
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/config"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+	"github.com/Aptomi/aptomi/pkg/plugin/fake"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type streamMessage struct {
+	event string
+	data  []byte
+}
+
+// readStream splits Server-Sent Events response into messages
+func readStream(t *testing.T, body []byte) []streamMessage {
+	t.Helper()
+	result := make([]streamMessage, 0)
+	for _, block := range strings.Split(strings.TrimSpace(string(body)), "\n\n") {
+		msg := streamMessage{}
+		for _, line := range strings.Split(block, "\n") {
+			if strings.HasPrefix(line, "event: ") {
+				msg.event = strings.TrimPrefix(line, "event: ")
+			} else if strings.HasPrefix(line, "data: ") {
+				msg.data = append(msg.data, strings.TrimPrefix(line, "data: ")...)
+			} else {
+				assert.Fail(t, "Unexpected line in event stream", line)
+			}
+		}
+		result = append(result, msg)
+	}
+	return result
+}
+
+func TestPolicyUpdateStream(t *testing.T) {
+	reg := registry.New(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()))
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	rule := b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+	user := b.AddUser()
+	user.DomainAdmin = true
+	claim := b.AddClaim(user, service)
+
+	server := NewServer(Options{
+		Registry:     reg,
+		ExternalData: b.External(),
+		PluginRegistryFactory: func() plugin.Registry {
+			return plugin.NewRegistry(
+				config.Plugins{},
+				map[string]plugin.ClusterPluginConstructor{
+					"kubernetes": func(cluster *lang.Cluster, cfg config.Plugins) (plugin.ClusterPlugin, error) {
+						return fake.NewNoOpClusterPlugin(0), nil
+					},
+				},
+				map[string]map[string]plugin.CodePluginConstructor{
+					"kubernetes": {
+						"helm": func(cluster plugin.ClusterPlugin, cfg config.Plugins) (plugin.CodePlugin, error) {
+							return fake.NewNoOpCodePlugin(0), nil
+						},
+					},
+				},
+			)
+		},
+		AuthProvider: &userAuthProvider{user: user},
+	})
+	apiCodec := codec.NewYAMLCodec(runtime.NewTypes().Append(Types...))
+	jsonCodec := codec.NewJSONCodec(runtime.NewTypes().Append(Types...))
+
+	body, err := apiCodec.EncodeMany([]runtime.Object{cluster, bundle, service, rule, claim})
+	if !assert.NoError(t, err, "Policy objects should be encoded") {
+		t.FailNow()
+	}
+	request := httptest.NewRequest("POST", "/api/v1/policy/noop/true/loglevel/debug", bytes.NewReader(body))
+	request.Header.Set("Accept", codec.EventStream)
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	if !assert.Equal(t, http.StatusOK, recorder.Code, "Policy update should be streamed: %s", recorder.Body.String()) {
+		t.FailNow()
+	}
+	assert.Equal(t, codec.EventStream, recorder.Header().Get("Content-Type"), "Policy update should be streamed as Server-Sent Events")
+
+	messages := readStream(t, recorder.Body.Bytes())
+	if !assert.True(t, len(messages) > 1, "Events should be streamed before the result") {
+		t.FailNow()
+	}
+	last := messages[len(messages)-1]
+	if !assert.Equal(t, StreamEventResult, last.event, "Result should be streamed in the end: %s", last.data) {
+		t.FailNow()
+	}
+	obj, err := jsonCodec.DecodeOne(last.data)
+	if !assert.NoError(t, err, "Policy update result should be decoded") {
+		t.FailNow()
+	}
+	result := obj.(*PolicyUpdateResult)
+	assert.False(t, result.PolicyChanged, "Policy shouldn't be changed in noop mode")
+	assert.NotEmpty(t, result.PlanAsText.Actions, "Action plan should be returned")
+
+	streamed := messages[:len(messages)-1]
+	if assert.Len(t, streamed, len(result.EventLog), "All events returned in the result should be streamed") {
+		for idx, msg := range streamed {
+			assert.Equal(t, StreamEventLog, msg.event)
+			apiEvent := map[string]interface{}{}
+			if assert.NoError(t, json.Unmarshal(msg.data, &apiEvent), "Streamed event should be valid json") {
+				assert.Equal(t, result.EventLog[idx].Message, apiEvent["message"], "Events should be streamed in order")
+			}
+		}
+	}
+
+	// stream could be requested via query parameter and policy should be updated even if client disconnects
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	request = httptest.NewRequest("POST", "/api/v1/policy/noop/false/loglevel/debug?stream=true", bytes.NewReader(body)).WithContext(ctx)
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	assert.Equal(t, codec.EventStream, recorder.Header().Get("Content-Type"), "Policy update should be streamed as Server-Sent Events")
+	messages = readStream(t, recorder.Body.Bytes())
+	if assert.NotEmpty(t, messages, "Events should be streamed") {
+		assert.Equal(t, StreamEventResult, messages[len(messages)-1].event, "Result should be streamed in the end: %s", messages[len(messages)-1].data)
+	}
+	_, policyGen, err := reg.GetPolicy(runtime.LastOrEmptyGen)
+	assert.NoError(t, err, "Policy should be loaded")
+	assert.EqualValues(t, 2, policyGen, "Policy should be updated even though client has disconnected")
+}
+
+func TestPolicyUpdateStreamError(t *testing.T) {
+	api := &coreAPI{contentType: codec.NewContentTypeHandler(runtime.NewTypes().Append(Types...))}
+	request := httptest.NewRequest("POST", "/api/v1/policy", nil)
+	request.Header.Set("Accept", "text/event-stream, application/yaml;q=0.5")
+	recorder := httptest.NewRecorder()
+
+	eventLog := event.NewLog(logrus.DebugLevel, "test")
+	eventLog.NewEntry().Warning("policy validation warning")
+	stream := api.startEventStream(recorder, request, eventLog, logrus.WarnLevel)
+	if !assert.NotNil(t, stream, "Stream should be started") {
+		t.FailNow()
+	}
+	err := api.writePolicyUpdateResult(recorder, request, stream, func() (*PolicyUpdateResult, error) {
+		eventLog.NewEntry().Debug("not streamed")
+		eventLog.NewEntry().Error("resolution error")
+		return nil, newRequestError(http.StatusBadRequest, ErrorCodeInvalidPolicy, "policy change cannot be made")
+	})
+	assert.NoError(t, err, "Error should be streamed instead of returned")
+	assert.Equal(t, http.StatusOK, recorder.Code, "Status should be sent before the error")
+
+	messages := readStream(t, recorder.Body.Bytes())
+	if assert.Len(t, messages, 3, "Events of the requested level and the error should be streamed") {
+		assert.Equal(t, StreamEventLog, messages[0].event)
+		assert.Equal(t, StreamEventLog, messages[1].event)
+		assert.Equal(t, StreamEventError, messages[2].event)
+		serverErr := map[string]interface{}{}
+		if assert.NoError(t, json.Unmarshal(messages[2].data, &serverErr), "Streamed error should be valid json") {
+			assert.Equal(t, ErrorCodeInvalidPolicy, serverErr["code"], "Error code should be streamed")
+		}
+	}
+
+	// without streaming, error is returned to be written with the corresponding status
+	request.Header.Del("Accept")
+	assert.Nil(t, api.startEventStream(recorder, request, eventLog, logrus.WarnLevel), "Stream shouldn't be started")
+	err = api.writePolicyUpdateResult(httptest.NewRecorder(), request, nil, func() (*PolicyUpdateResult, error) {
+		return nil, newRequestError(http.StatusBadRequest, ErrorCodeInvalidPolicy, "policy change cannot be made")
+	})
+	assert.Error(t, err, "Error should be returned if not streaming")
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/diff"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/util"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyChangeAnnotationsOnly(t *testing.T) {
+	b := builder.NewPolicyBuilder()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(util.NestedParameterMap{"param": "value"}, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	cluster := b.AddCluster()
+	b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+	claim := b.AddClaim(b.AddUser(), service)
+	desiredState := resolvePolicy(t, b)
+
+	// change only annotations, desired state should stay the same
+	claim.Annotations = map[string]string{"ticket": "https://example.com/tickets/239"}
+	desiredStateUpdated := resolvePolicy(t, b)
+	actionPlan := diff.NewPolicyResolutionDiff(desiredStateUpdated, desiredState).ActionPlan
+	assert.EqualValues(t, 0, actionPlan.NumberOfActions(), "Changing annotations should not result in any actions")
+
+	// policy should get a new generation, but enforcement should not be triggered
+	reg := &fakeRegistry{policyGen: 2, nextRevisionGen: 5}
+	api := &coreAPI{registry: reg, clock: SystemClock{}, runDesiredStateEnforcement: make(chan bool, 1)}
+	prevRevision := &engine.Revision{Status: engine.RevisionStatusCompleted}
+	change, err := api.changePolicy(context.Background(), []lang.Base{claim}, &lang.User{Name: "test"}, prevRevision, desiredStateUpdated, nil, actionPlan.NumberOfActions() > 0, false)
+	if !assert.NoError(t, err, "Policy should be changed without errors") {
+		t.FailNow()
+	}
+
+	assert.True(t, change.Changed, "Policy should be changed")
+	assert.EqualValues(t, 2, change.PolicyGen, "Policy should get a new generation")
+	assert.EqualValues(t, 5, change.RevisionGen, "New revision should be created")
+	assert.Equal(t, engine.RevisionStatusCompleted, reg.updatedRevision.Status, "New revision should be completed right away")
+	assert.Len(t, api.runDesiredStateEnforcement, 0, "Enforcement should not be triggered")
+	assert.EqualValues(t, 1, change.EnforcementID, "Enforcement should be created for the new revision")
+	assert.Equal(t, engine.EnforcementStatusSucceeded, reg.updatedEnforcement.Status, "Enforcement should succeed right away")
+
+	// once desired state changes, enforcement should be triggered
+	reg = &fakeRegistry{policyGen: 3, nextRevisionGen: 6}
+	api.registry = reg
+	change, err = api.changePolicy(context.Background(), []lang.Base{claim}, &lang.User{Name: "test"}, prevRevision, desiredStateUpdated, nil, true, false)
+	assert.NoError(t, err, "Policy should be changed without errors")
+	assert.Nil(t, reg.updatedRevision, "New revision should be left for enforcer to process")
+	assert.Nil(t, reg.updatedEnforcement, "Enforcement should be left pending for enforcer to process")
+	assert.EqualValues(t, 1, change.EnforcementID, "Enforcement should be created for the new revision")
+	assert.Len(t, api.runDesiredStateEnforcement, 1, "Enforcement should be triggered")
+}
+
+func resolvePolicy(t *testing.T, b *builder.PolicyBuilder) *resolve.PolicyResolution {
+	t.Helper()
+	eventLog := event.NewLog(logrus.WarnLevel, "test-resolve")
+	result, resolveErr := resolve.NewPolicyResolver(b.Policy(), b.External(), eventLog).ResolveAllClaims(context.Background())
+	if !assert.NoError(t, resolveErr, "Policy should be resolved without errors") {
+		t.FailNow()
+	}
+	if !assert.NoError(t, result.Validate(b.Policy()), "Policy should be resolved without errors") {
+		t.FailNow()
+	}
+	return result
+}
+
+// fakeRegistry implements only registry methods required for making policy changes
+type fakeRegistry struct {
+	registry.Interface
+
+	policyGen          runtime.Generation
+	nextRevisionGen    runtime.Generation
+	updatedRevision    *engine.Revision
+	updatedEnforcement *engine.Enforcement
+}
+
+func (reg *fakeRegistry) WithContext(ctx context.Context) registry.Interface {
+	return reg
+}
+
+func (reg *fakeRegistry) UpdatePolicy(updated []lang.Base, performedBy string) (bool, *engine.PolicyData, error) {
+	return true, &engine.PolicyData{Metadata: engine.PolicyDataMetadata{Generation: reg.policyGen}}, nil
+}
+
+func (reg *fakeRegistry) NewRevision(policyGen runtime.Generation, desiredState *resolve.PolicyResolution, recalculateAll bool) (*engine.Revision, error) {
+	return engine.NewRevision(reg.nextRevisionGen, policyGen, recalculateAll), nil
+}
+
+func (reg *fakeRegistry) SaveResolutionLog(revision *engine.Revision, events []*event.APIEvent) error {
+	return nil
+}
+
+func (reg *fakeRegistry) UpdateRevision(revision *engine.Revision) error {
+	reg.updatedRevision = revision
+	return nil
+}
+
+func (reg *fakeRegistry) NewEnforcement(revisionGen runtime.Generation, createdAt time.Time) (*engine.Enforcement, error) {
+	return engine.NewEnforcement(runtime.FirstGen, revisionGen, createdAt), nil
+}
+
+func (reg *fakeRegistry) UpdateEnforcement(enforcement *engine.Enforcement) error {
+	reg.updatedEnforcement = enforcement
+	return nil
+}
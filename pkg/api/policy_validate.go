@@ -0,0 +1,140 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/julienschmidt/httprouter"
+)
+
+// TypePolicyValidationResult is an informational data structure with Kind and Constructor for PolicyValidationResult
+var TypePolicyValidationResult = &runtime.TypeInfo{
+	Kind:        "policy-validation-result",
+	Constructor: func() runtime.Object { return &PolicyValidationResult{} },
+}
+
+// PolicyValidationResult represents result of validating policy objects against the latest policy without changing it
+type PolicyValidationResult struct {
+	runtime.TypeKind `yaml:",inline"`
+
+	// PolicyGeneration is the generation of the latest policy, which objects were validated against
+	PolicyGeneration runtime.Generation
+
+	// Valid is true if policy with the objects applied has no validation errors
+	Valid bool
+
+	// Errors is the list of validation errors
+	Errors []*lang.ValidationError `yaml:",omitempty"`
+
+	// Warnings is the list of problems, which don't make policy invalid (e.g. references to unknown variables)
+	Warnings []string `yaml:",omitempty"`
+}
+
+// GetDefaultColumns returns default set of columns to be displayed
+func (result *PolicyValidationResult) GetDefaultColumns() []string {
+	return []string{"Policy Generation", "Valid", "Errors", "Warnings"}
+}
+
+// AsColumns returns PolicyValidationResult representation as columns
+func (result *PolicyValidationResult) AsColumns() map[string]string {
+	errors := ""
+	for _, err := range result.Errors {
+		errors += err.String() + "\n"
+	}
+	warnings := ""
+	for _, warning := range result.Warnings {
+		warnings += warning + "\n"
+	}
+	return map[string]string{
+		"Policy Generation": result.PolicyGeneration.String(),
+		"Valid":             fmt.Sprintf("%t", result.Valid),
+		"Errors":            errors,
+		"Warnings":          warnings,
+	}
+}
+
+// handlePolicyValidate validates policy objects the same way policy update does, but without resolving desired state
+// and without changing anything. Validation errors are returned in structured form with 200, so they can be told
+// apart from failures of the request itself
+func (api *coreAPI) handlePolicyValidate(writer http.ResponseWriter, request *http.Request, params httprouter.Params) error {
+	objects, err := api.readLang(request)
+	if err != nil {
+		return err
+	}
+	user := api.getUserRequired(request)
+
+	// Make a copy of the latest policy, so we can apply changes to it
+	policyUpdated, policyGen, err := api.registry.GetPolicy(runtime.LastOrEmptyGen)
+	if err != nil {
+		return fmt.Errorf("error while loading current policy: %s", err)
+	}
+
+	errors, warnings := api.validatePolicyChange(policyUpdated, user, objects)
+	api.contentType.WriteOne(writer, request, &PolicyValidationResult{
+		TypeKind:         TypePolicyValidationResult.GetTypeKind(),
+		PolicyGeneration: policyGen,
+		Valid:            len(errors) == 0,
+		Errors:           errors,
+		Warnings:         warnings,
+	})
+	return nil
+}
+
+// validatePolicyChange adds objects to a given policy and returns validation errors and warnings of the result
+func (api *coreAPI) validatePolicyChange(policyUpdated *lang.Policy, user *lang.User, objects []lang.Base) ([]*lang.ValidationError, []string) {
+	errors := make([]*lang.ValidationError, 0)
+
+	// Add objects to the policy in a sorted order (e.g. make sure ACL Rules go first)
+	prevDefaults := getDefaultsByNamespace(policyUpdated, objects)
+	sort.Sort(apiObjectSorter(objects))
+	for _, obj := range objects {
+		errManage := policyUpdated.View(user).ManageObject(obj)
+		if errManage == nil {
+			errManage = policyUpdated.AddObject(obj)
+		}
+		if errManage != nil {
+			errors = append(errors, newObjectValidationError(obj, errManage))
+		}
+	}
+	if len(errors) > 0 {
+		return errors, nil
+	}
+
+	// Fill unset fields from namespace defaults
+	objects, _, err := applyDefaults(policyUpdated, user, prevDefaults, objects)
+	if err != nil {
+		return append(errors, &lang.ValidationError{Message: err.Error()}), nil
+	}
+
+	validator := lang.NewPolicyValidator(policyUpdated)
+	errors = append(errors, lang.GetValidationErrors(validator.Validate())...)
+
+	// Validate clusters using corresponding cluster plugins
+	plugins := api.pluginRegistryFactory()
+	for _, obj := range objects {
+		if cluster, ok := obj.(*lang.Cluster); ok {
+			plugin, pluginErr := plugins.ForCluster(cluster)
+			if pluginErr == nil {
+				pluginErr = plugin.Validate()
+			}
+			if pluginErr != nil {
+				errors = append(errors, newObjectValidationError(cluster, pluginErr))
+			}
+		}
+	}
+
+	return errors, validator.Warnings()
+}
+
+// newObjectValidationError returns validation error pointing to a given object
+func newObjectValidationError(obj lang.Base, err error) *lang.ValidationError {
+	return &lang.ValidationError{
+		Namespace: obj.GetNamespace(),
+		Kind:      obj.GetKind(),
+		Name:      obj.GetName(),
+		Message:   err.Error(),
+	}
+}
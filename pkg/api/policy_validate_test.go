@@ -0,0 +1,91 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/config"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+	"github.com/Aptomi/aptomi/pkg/plugin/fake"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyValidate(t *testing.T) {
+	reg := registry.New(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()))
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	rule := b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+	user := b.AddUser()
+	user.DomainAdmin = true
+	claim := b.AddClaim(user, service)
+
+	server := NewServer(Options{
+		Registry:     reg,
+		ExternalData: b.External(),
+		PluginRegistryFactory: func() plugin.Registry {
+			return plugin.NewRegistry(
+				config.Plugins{},
+				map[string]plugin.ClusterPluginConstructor{
+					"kubernetes": func(cluster *lang.Cluster, cfg config.Plugins) (plugin.ClusterPlugin, error) {
+						return fake.NewNoOpClusterPlugin(0), nil
+					},
+				},
+				map[string]map[string]plugin.CodePluginConstructor{},
+			)
+		},
+		AuthProvider: &userAuthProvider{user: user},
+	})
+	apiCodec := codec.NewYAMLCodec(runtime.NewTypes().Append(Types...))
+
+	validate := func(objects ...runtime.Object) *PolicyValidationResult {
+		body, err := apiCodec.EncodeMany(objects)
+		if !assert.NoError(t, err, "Policy objects should be encoded") {
+			t.FailNow()
+		}
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("POST", "/api/v1/policy/validate", bytes.NewReader(body)))
+		if !assert.Equal(t, http.StatusOK, recorder.Code, "Validation result should be returned: %s", recorder.Body.String()) {
+			t.FailNow()
+		}
+		obj, err := apiCodec.DecodeOne(recorder.Body.Bytes())
+		if !assert.NoError(t, err, "Validation result should be decoded") {
+			t.FailNow()
+		}
+		return obj.(*PolicyValidationResult)
+	}
+
+	result := validate(cluster, bundle, service, rule, claim)
+	assert.True(t, result.Valid, "Valid policy objects should pass validation: %v", result.Errors)
+	assert.Empty(t, result.Errors)
+
+	rule.Criteria.RequireAll = []string{"specialname + 'a' +"}
+	result = validate(cluster, bundle, service, rule, claim)
+	assert.False(t, result.Valid, "Invalid policy objects should fail validation")
+	if assert.Len(t, result.Errors, 1, "Single validation error should be returned") {
+		assert.Equal(t, rule.Namespace, result.Errors[0].Namespace)
+		assert.Equal(t, lang.TypeRule.Kind, result.Errors[0].Kind)
+		assert.Equal(t, rule.Name, result.Errors[0].Name)
+		assert.Equal(t, "Criteria.RequireAll[0]", result.Errors[0].Field)
+	}
+
+	// nothing should be changed by validation
+	_, policyGen, err := reg.GetPolicy(runtime.LastOrEmptyGen)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, policyGen, "Validation shouldn't change policy")
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/julienschmidt/httprouter"
+)
+
+// handleStateRestored writes reconciliation marker, which tells enforcer to reconcile actual state with clusters before
+// the next enforcement. It has to be called right after the underlying store has been restored from a backup
+func (api *coreAPI) handleStateRestored(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	api.checkDomainAdmin(request, "mark registry as restored")
+
+	reconciliation := engine.NewReconciliation(api.clock.Now(), api.getUserRequired(request).Name, api.reconciliationThreshold)
+	err := api.registry.UpdateReconciliation(reconciliation)
+	if err != nil {
+		panic(fmt.Sprintf("error while saving reconciliation: %s", err))
+	}
+
+	// signal to the channel that actual state has to be reconciled
+	api.triggerEnforcement()
+
+	api.contentType.WriteOne(writer, request, reconciliation)
+}
+
+func (api *coreAPI) handleReconciliationGet(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	reconciliation, err := api.registry.GetReconciliation()
+	if err != nil {
+		panic(fmt.Sprintf("error while getting reconciliation: %s", err))
+	}
+
+	if reconciliation == nil {
+		// registry has never been restored
+		api.contentType.WriteOneWithStatus(writer, request, nil, http.StatusNotFound)
+	} else {
+		api.contentType.WriteOne(writer, request, reconciliation)
+	}
+}
+
+func (api *coreAPI) handleReconciliationAck(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	api.checkDomainAdmin(request, "acknowledge actual state corrections")
+
+	reconciliation, err := api.registry.GetReconciliation()
+	if err != nil {
+		panic(fmt.Sprintf("error while getting reconciliation: %s", err))
+	}
+	if reconciliation == nil || reconciliation.Status != engine.ReconciliationStatusWaitingForAck {
+		panic("there are no actual state corrections waiting for acknowledgment")
+	}
+
+	reconciliation.Status = engine.ReconciliationStatusCompleted
+	reconciliation.AcknowledgedBy = api.getUserRequired(request).Name
+	reconciliation.CompletedAt = api.clock.Now()
+	err = api.registry.UpdateReconciliation(reconciliation)
+	if err != nil {
+		panic(fmt.Sprintf("error while saving reconciliation: %s", err))
+	}
+
+	// signal to the channel that enforcement can proceed
+	api.triggerEnforcement()
+
+	api.contentType.WriteOne(writer, request, reconciliation)
+}
+
+// checkDomainAdmin panics if the user making a given request is not a domain admin
+func (api *coreAPI) checkDomainAdmin(request *http.Request, action string) {
+	policy, _, err := api.registry.GetPolicy(runtime.LastOrEmptyGen)
+	if err != nil {
+		panic(fmt.Sprintf("error while loading latest policy: %s", err))
+	}
+	if policy == nil {
+		// registry is empty (e.g. it's being restored from a backup), so there are no ACL rules defined yet
+		policy = lang.NewPolicy()
+	}
+
+	if !isDomainAdmin(api.getUserRequired(request), policy) {
+		panic(fmt.Sprintf("user is not allowed to %s", action))
+	}
+}
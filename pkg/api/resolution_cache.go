@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/sirupsen/logrus"
+)
+
+// resolutionCacheKey returns key of the resolution cache for a given policy generation and the current version of
+// external data
+func (api *coreAPI) resolutionCacheKey(policyGen runtime.Generation) resolve.CacheKey {
+	return resolve.CacheKey{PolicyGen: policyGen, ExternalDataVersion: api.externalData.Version()}
+}
+
+// resolvePolicy returns desired state of a given policy, which has been saved in the registry with a given generation.
+// Desired state is taken from the resolution cache, if the same policy generation has been resolved before with the
+// same external data, and events logged while it was calculated get replayed to a given event log. Claims, which can't
+// be resolved, are skipped the same way as they are when revision is created by the pipeline. Returned desired state
+// may be shared, so it must not be modified
+func (api *coreAPI) resolvePolicy(ctx context.Context, policy *lang.Policy, policyGen runtime.Generation, eventLog *event.Log) (*resolve.PolicyResolution, error) {
+	key := api.resolutionCacheKey(policyGen)
+	if cached, ok := api.resolutionCache.Get(key, eventLog.GetLevel()); ok {
+		eventLog.AppendAPIEvents(cached.Events)
+		return cached.Resolution, nil
+	}
+
+	// resolution events are collected separately, so they could be cached along with desired state
+	resolveLog := event.NewLog(eventLog.GetLevel(), eventLog.GetScope())
+	defer resolveLog.Close() // nolint: errcheck
+
+	resolution, err := resolve.NewPolicyResolver(policy, api.externalData, resolveLog).SetPluginRegistry(api.pluginRegistryFactory()).SetContinueOnError(true).ResolveAllClaims(ctx)
+	events := resolveLog.AsAPIEvents()
+	eventLog.AppendAPIEvents(events)
+	if err != nil {
+		return nil, err
+	}
+	api.resolutionCache.Put(key, &resolve.CachedResolution{Resolution: resolution, Events: events, LogLevel: eventLog.GetLevel()})
+	return resolution, nil
+}
+
+// resolutionError converts an error returned by policy resolver into the error reported to the client. Dependency
+// cycles between services and bundles, as well as claims which can't be resolved in strict mode, make policy change
+// invalid, so they are reported as bad request
+func resolutionError(err error) error {
+	if resolve.IsCycleError(err) || resolve.IsClaimsNotResolved(err) {
+		return newRequestError(http.StatusBadRequest, ErrorCodeInvalidPolicy, "policy change cannot be made: %s", err)
+	}
+	return fmt.Errorf("error while resolving policy: %s", err)
+}
+
+// cacheResolution caches desired state of a new policy generation, which has been calculated before the generation
+// got saved, along with a given event log of the calculation. Saved policy generations never change, so nothing
+// cached before has to be invalidated
+func (api *coreAPI) cacheResolution(policyGen runtime.Generation, resolution *resolve.PolicyResolution, events []*event.APIEvent, logLevel logrus.Level) {
+	api.resolutionCache.Put(api.resolutionCacheKey(policyGen), &resolve.CachedResolution{Resolution: resolution, Events: events, LogLevel: logLevel})
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/config"
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+	"github.com/Aptomi/aptomi/pkg/plugin/fake"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolutionLogSavedWithRevision(t *testing.T) {
+	reg := registry.New(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()))
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	rule := b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+	user := b.AddUser()
+	user.DomainAdmin = true
+	claim := b.AddClaim(user, service)
+
+	server := NewServer(Options{
+		Registry:     reg,
+		ExternalData: b.External(),
+		PluginRegistryFactory: func() plugin.Registry {
+			return plugin.NewRegistry(
+				config.Plugins{},
+				map[string]plugin.ClusterPluginConstructor{
+					"kubernetes": func(cluster *lang.Cluster, cfg config.Plugins) (plugin.ClusterPlugin, error) {
+						return fake.NewNoOpClusterPlugin(0), nil
+					},
+				},
+				map[string]map[string]plugin.CodePluginConstructor{
+					"kubernetes": {
+						"helm": func(cluster plugin.ClusterPlugin, cfg config.Plugins) (plugin.CodePlugin, error) {
+							return fake.NewNoOpCodePlugin(0), nil
+						},
+					},
+				},
+			)
+		},
+		AuthProvider: &userAuthProvider{user: user},
+	})
+	apiCodec := codec.NewYAMLCodec(runtime.NewTypes().Append(Types...))
+
+	// commit policy
+	body, err := apiCodec.EncodeMany([]runtime.Object{cluster, bundle, service, rule, claim})
+	if !assert.NoError(t, err, "Policy objects should be encoded") {
+		t.FailNow()
+	}
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest("POST", "/api/v1/policy/noop/false/loglevel/warning", bytes.NewReader(body)))
+	if !assert.Equal(t, http.StatusOK, recorder.Code, "Policy should be updated: %s", recorder.Body.String()) {
+		t.FailNow()
+	}
+	obj, err := apiCodec.DecodeOne(recorder.Body.Bytes())
+	if !assert.NoError(t, err, "Policy update result should be decoded") {
+		t.FailNow()
+	}
+	result := obj.(*PolicyUpdateResult)
+	for _, e := range result.EventLog {
+		assert.Contains(t, []string{"warning", "error"}, e.LogLevel, "Only events of the requested level should be returned inline")
+	}
+
+	// fetch resolution log of the revision later
+	getLog := func(gen runtime.Generation, query string) (*httptest.ResponseRecorder, *engine.ResolutionLog) {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/revision/gen/"+gen.String()+"/log"+query, nil))
+		if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") == codec.CSV {
+			return recorder, nil
+		}
+		obj, err := apiCodec.DecodeOne(recorder.Body.Bytes())
+		if !assert.NoError(t, err, "Resolution log should be decoded") {
+			t.FailNow()
+		}
+		return recorder, obj.(*engine.ResolutionLog)
+	}
+
+	_, resolutionLog := getLog(result.WaitForRevision, "")
+	if !assert.NotNil(t, resolutionLog, "Resolution log should be returned") || !assert.NotEmpty(t, resolutionLog.Events, "Resolution log should be saved with revision") {
+		t.FailNow()
+	}
+	assert.Equal(t, result.WaitForRevision, resolutionLog.RevisionGen, "Resolution log should belong to the revision")
+	for idx, e := range resolutionLog.Events {
+		assert.Equal(t, idx+1, e.Seq, "Events should be numbered in order")
+	}
+	assert.True(t, len(resolutionLog.Events) > len(result.EventLog), "Resolution log should be saved with more details than requested inline")
+
+	// filter by level
+	_, filtered := getLog(result.WaitForRevision, "?level=warning")
+	if assert.NotNil(t, filtered, "Filtered resolution log should be returned") {
+		for _, e := range filtered.Events {
+			level, _ := logrus.ParseLevel(e.LogLevel)
+			assert.True(t, level <= logrus.WarnLevel, "Only warning events and more severe ones should be returned")
+		}
+	}
+
+	// filter by sequence number
+	last := len(resolutionLog.Events)
+	_, filtered = getLog(result.WaitForRevision, "?after="+strconv.Itoa(last-1))
+	if assert.NotNil(t, filtered, "Filtered resolution log should be returned") && assert.Len(t, filtered.Events, 1, "Only events after the given one should be returned") {
+		assert.Equal(t, last, filtered.Events[0].Seq, "The last event should be returned")
+	}
+
+	// resolution log could be exported as csv
+	recorder, _ = getLog(result.WaitForRevision, "?after="+strconv.Itoa(last-1)+"&format=csv")
+	if assert.Equal(t, http.StatusOK, recorder.Code, "Resolution log should be returned as csv") {
+		assert.Equal(t, codec.CSV, recorder.Header().Get("Content-Type"))
+		records, err := csv.NewReader(bytes.NewReader(recorder.Body.Bytes())).ReadAll()
+		assert.NoError(t, err, "Produced CSV should be parsed without errors")
+		if assert.Len(t, records, 2, "CSV should contain header row and row per event") {
+			assert.Equal(t, (&event.APIEvent{}).GetDefaultColumns(), records[0], "Header row should contain default columns")
+			assert.Equal(t, resolutionLog.Events[last-1].Message, records[1][2], "Event should be written as csv row")
+		}
+	}
+
+	recorder, _ = getLog(result.WaitForRevision, "?level=verbose")
+	assert.Equal(t, http.StatusBadRequest, recorder.Code, "Unknown level should be rejected")
+	recorder, _ = getLog(result.WaitForRevision.Next(), "")
+	assert.Equal(t, http.StatusNotFound, recorder.Code, "Resolution log of missing revision should not be found")
+}
@@ -5,8 +5,11 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/Aptomi/aptomi/pkg/engine"
 	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
 	"github.com/julienschmidt/httprouter"
+	"github.com/sirupsen/logrus"
 )
 
 func (api *coreAPI) handleRevisionGet(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
@@ -30,20 +33,71 @@ func (api *coreAPI) handleRevisionGet(writer http.ResponseWriter, request *http.
 
 type revisionsWrapper struct {
 	Data interface{}
+	// Continue is the token to get the next page of revisions, it's empty if there are no more pages
+	Continue string `yaml:",omitempty"`
 }
 
 func (g *revisionsWrapper) GetKind() string {
 	return "revisions"
 }
 
+// GetDefaultColumns returns default set of columns of revisions
+func (g *revisionsWrapper) GetDefaultColumns() []string {
+	return (&engine.Revision{}).GetDefaultColumns()
+}
+
+// AsDisplayableList returns revisions as a list of displayable objects, so they could be rendered as table
+func (g *revisionsWrapper) AsDisplayableList() []runtime.Displayable {
+	revisions, ok := g.Data.([]*engine.Revision)
+	if !ok {
+		return nil
+	}
+
+	result := make([]runtime.Displayable, 0, len(revisions))
+	for _, revision := range revisions {
+		result = append(result, revision)
+	}
+
+	return result
+}
+
 func (api *coreAPI) handleRevisionsGetByPolicy(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
 	policyGen := params.ByName("policy")
+	if len(policyGen) == 0 {
+		policyGen = params.ByName("gen")
+	}
 
 	if len(policyGen) == 0 {
 		policyGen = strconv.Itoa(int(runtime.LastOrEmptyGen))
 	}
+	gen, err := strconv.ParseUint(policyGen, 10, 64)
+	if err != nil {
+		api.contentType.WriteOneWithStatus(writer, request, NewServerError(fmt.Sprintf("invalid policy generation '%s'", policyGen)), http.StatusBadRequest)
+		return
+	}
+
+	// revisions are paged only if limit is requested, all revisions are returned otherwise
+	if value := request.URL.Query().Get("limit"); len(value) > 0 {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			api.contentType.WriteOneWithStatus(writer, request, NewServerError(fmt.Sprintf("invalid limit '%s'", value)), http.StatusBadRequest)
+			return
+		}
+
+		revisions, next, err := api.registry.GetRevisionsForPolicyPage(runtime.Generation(gen), limit, request.URL.Query().Get("continue"))
+		if store.IsInvalidContinueToken(err) {
+			api.contentType.WriteOneWithStatus(writer, request, NewServerError(err.Error()), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			panic(fmt.Sprintf("error while getting requested revisions: %s", err))
+		}
+
+		api.contentType.WriteOne(writer, request, &revisionsWrapper{Data: revisions, Continue: next})
+		return
+	}
 
-	revisions, err := api.registry.GetAllRevisionsForPolicy(runtime.ParseGeneration(policyGen))
+	revisions, err := api.registry.GetAllRevisionsForPolicy(runtime.Generation(gen))
 	if err != nil {
 		panic(fmt.Sprintf("error while getting requested revisions: %s", err))
 	}
@@ -54,3 +108,87 @@ func (api *coreAPI) handleRevisionsGetByPolicy(writer http.ResponseWriter, reque
 		api.contentType.WriteOne(writer, request, &revisionsWrapper{Data: revisions})
 	}
 }
+
+type actionMarkersWrapper struct {
+	Data []*engine.ActionMarker
+}
+
+func (g *actionMarkersWrapper) GetKind() string {
+	return "action markers"
+}
+
+// GetDefaultColumns returns default set of columns of action markers
+func (g *actionMarkersWrapper) GetDefaultColumns() []string {
+	return (&engine.ActionMarker{}).GetDefaultColumns()
+}
+
+// AsDisplayableList returns action markers as a list of displayable objects, so they could be rendered as table
+func (g *actionMarkersWrapper) AsDisplayableList() []runtime.Displayable {
+	result := make([]runtime.Displayable, 0, len(g.Data))
+	for _, marker := range g.Data {
+		result = append(result, marker)
+	}
+
+	return result
+}
+
+func (api *coreAPI) handleActionMarkersGet(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	revision, err := api.registry.GetRevision(runtime.ParseGeneration(params.ByName("gen")))
+	if err != nil {
+		panic(fmt.Sprintf("error while getting requested revision: %s", err))
+	}
+	if revision == nil {
+		api.contentType.WriteOneWithStatus(writer, request, nil, http.StatusNotFound)
+		return
+	}
+
+	markers, err := api.registry.GetActionMarkers(revision.GetGeneration())
+	if err != nil {
+		panic(fmt.Sprintf("error while getting action markers: %s", err))
+	}
+
+	api.contentType.WriteOne(writer, request, &actionMarkersWrapper{Data: markers})
+}
+
+func (api *coreAPI) handleResolutionLogGet(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	// all saved events are returned by default
+	level := logrus.DebugLevel
+	if value := request.URL.Query().Get("level"); len(value) > 0 {
+		var err error
+		level, err = logrus.ParseLevel(value)
+		if err != nil {
+			api.contentType.WriteOneWithStatus(writer, request, NewServerError(fmt.Sprintf("invalid log level '%s': %s", value, err)), http.StatusBadRequest)
+			return
+		}
+	}
+	afterSeq := 0
+	if value := request.URL.Query().Get("after"); len(value) > 0 {
+		var err error
+		afterSeq, err = strconv.Atoi(value)
+		if err != nil || afterSeq < 0 {
+			api.contentType.WriteOneWithStatus(writer, request, NewServerError(fmt.Sprintf("invalid sequence number '%s'", value)), http.StatusBadRequest)
+			return
+		}
+	}
+
+	revision, err := api.registry.GetRevision(runtime.ParseGeneration(params.ByName("gen")))
+	if err != nil {
+		panic(fmt.Sprintf("error while getting requested revision: %s", err))
+	}
+	if revision == nil {
+		api.contentType.WriteOneWithStatus(writer, request, nil, http.StatusNotFound)
+		return
+	}
+
+	resolutionLog, err := api.registry.GetResolutionLog(revision.GetGeneration())
+	if err != nil {
+		panic(fmt.Sprintf("error while getting resolution log: %s", err))
+	}
+
+	// revisions created before resolution logs were saved don't have them
+	if resolutionLog == nil {
+		resolutionLog = engine.NewResolutionLog(revision, nil)
+	}
+
+	api.contentType.WriteOne(writer, request, resolutionLog.Filter(level, afterSeq))
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestRevisionsGetByPolicyGen(t *testing.T) {
+	reg := registry.New(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()))
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+	b := builder.NewPolicyBuilder()
+	server := NewServer(Options{
+		Registry:     reg,
+		ExternalData: b.External(),
+		AuthProvider: &userAuthProvider{user: b.AddUser()},
+	})
+
+	// initial policy gets the first revision, make two more revisions for it and one for the next policy generation
+	for i := 0; i < 2; i++ {
+		_, err := reg.NewRevision(runtime.FirstGen, resolve.NewPolicyResolution(), false)
+		if !assert.NoError(t, err, "Revision should be created") {
+			t.FailNow()
+		}
+	}
+	_, policyData, err := reg.UpdatePolicy([]lang.Base{b.AddCluster()}, "alice")
+	if !assert.NoError(t, err, "Policy should be updated") {
+		t.FailNow()
+	}
+	policyGen := policyData.GetGeneration()
+	_, err = reg.NewRevision(policyGen, resolve.NewPolicyResolution(), false)
+	if !assert.NoError(t, err, "Revision should be created") {
+		t.FailNow()
+	}
+
+	get := func(path string) (int, []*engine.Revision) {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		if recorder.Code != http.StatusOK {
+			return recorder.Code, nil
+		}
+		result := &struct{ Data []*engine.Revision }{}
+		if !assert.NoError(t, yaml.Unmarshal(recorder.Body.Bytes(), result), "Revisions should be decoded") {
+			t.FailNow()
+		}
+		return recorder.Code, result.Data
+	}
+
+	code, revisions := get("/api/v1/policy/gen/1/revisions")
+	if assert.Equal(t, http.StatusOK, code, "Revisions should be returned") && assert.Len(t, revisions, 3, "All revisions for policy generation should be returned") {
+		for idx, revision := range revisions {
+			assert.EqualValues(t, idx+1, revision.GetGeneration(), "Revisions should be returned in the order of generations")
+			assert.Equal(t, runtime.FirstGen, revision.PolicyGen, "Only revisions for requested policy generation should be returned")
+		}
+	}
+
+	code, revisions = get("/api/v1/policy/gen/" + policyGen.String() + "/revisions")
+	if assert.Equal(t, http.StatusOK, code, "Revisions should be returned") && assert.Len(t, revisions, 1, "Revision for the latest policy generation should be returned") {
+		assert.Equal(t, policyGen, revisions[0].PolicyGen)
+	}
+
+	// generation, which doesn't exist (or which revisions have been compacted), has no revisions
+	code, _ = get("/api/v1/policy/gen/42/revisions")
+	assert.Equal(t, http.StatusNotFound, code, "Unknown policy generation should be reported")
+
+	code, _ = get("/api/v1/policy/gen/latest/revisions")
+	assert.Equal(t, http.StatusBadRequest, code, "Invalid policy generation should be rejected")
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/version"
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// route is a single API endpoint together with its description, which gets served as API documentation
+type route struct {
+	method      string
+	path        string
+	handle      httprouter.Handle
+	auth        bool
+	description string
+	accepts     []*runtime.TypeInfo
+	returns     string
+}
+
+// routes returns the table of all API endpoints. Every endpoint has to be described, as it's used for serving
+// API documentation at /apidocs
+func (api *coreAPI) routes() []*route {
+	return []*route{
+		// todo consider moving to a separate port for security (should be nothing sensetive?)
+		// prometheus metrics handler
+		{method: "GET", path: "/metrics", handle: handler(promhttp.HandlerFor(api.metrics, promhttp.HandlerOpts{})), description: "Returns Prometheus metrics of the server", returns: "prometheus metrics"},
+
+		// API documentation
+		{method: "GET", path: "/apidocs", handle: api.handleAPIDocs, description: "Returns description of all API endpoints along with examples as JSON", returns: TypeAPIDocs.Kind},
+		{method: "GET", path: "/apidocs/index.html", handle: api.handleAPIDocsIndex, description: "Returns description of all API endpoints along with examples as HTML", returns: "html"},
+
+		// authenticate user
+		{method: "POST", path: "/api/v1/user/login", handle: api.handleLogin, description: "Authenticates user and returns a token for subsequent API calls", accepts: []*runtime.TypeInfo{TypeAuthRequest}, returns: TypeAuthSuccess.Kind},
+
+		// get all users and their roles
+		{method: "GET", path: "/api/v1/user/roles", handle: api.handleUserRoles, auth: true, description: "Returns all users along with their roles in every namespace", returns: "user roles"},
+
+		// retrieve policy (latest + by a given generation)
+		{method: "GET", path: "/api/v1/policy", handle: api.withErrors(api.handlePolicyGet), auth: true, description: "Returns the latest policy", returns: engine.TypePolicyData.Kind},
+		{method: "GET", path: "/api/v1/policy/gen/:gen", handle: api.withErrors(api.handlePolicyGet), auth: true, description: "Returns policy with a given generation", returns: engine.TypePolicyData.Kind},
+
+		// list policy generations along with who made them and when
+		{method: "GET", path: "/api/v1/policy/history", handle: api.withErrors(api.handlePolicyHistoryGet), auth: true, description: "Returns policy generations starting from the latest one, along with who made them and when, the number of objects in them and the number of objects added, changed and removed compared to the previous generation. All generations are returned, or a page of them if 'limit' is set (next page is requested with 'continue' token from the response)", returns: TypePolicyHistory.Kind},
+
+		// export all policy objects as a YAML file, which could be applied again
+		{method: "GET", path: "/api/v1/policy/gen/:gen/export", handle: api.handlePolicyExport, auth: true, description: "Returns all objects of policy with a given generation as a multi-document YAML file, ordered so it could be applied again (e.g. to keep policy under version control or to move it to another environment)", returns: "policy objects"},
+
+		// compare desired states of two policy generations
+		{method: "GET", path: "/api/v1/policy/diff/:from/:to", handle: api.withErrors(api.handlePolicyDiff), auth: true, description: "Returns policy objects added, removed and modified (with YAML diff) between two policy generations. With ?resolve=true it also returns action plan (as text and in structured form), which gets desired state of one policy generation to desired state of another one, without changing anything. The plan could be filtered by cluster (?cluster=)", returns: TypePolicyDiffResult.Kind},
+
+		// retrieve revisions made for the policy
+		{method: "GET", path: "/api/v1/policy/gen/:gen/revisions", handle: api.handleRevisionsGetByPolicy, auth: true, description: "Returns all revisions for policy with a given generation along with their status, or a page of them if 'limit' is set (next page is requested with 'continue' token from the response)", returns: "revisions"},
+
+		// retrieve specific object or all objects of a given kind from the policy
+		{method: "GET", path: "/api/v1/policy/gen/:gen/object/:ns/:kind/:name", handle: api.withErrors(api.handlePolicyObjectGet), auth: true, description: "Returns a single object from policy with a given generation", returns: "policy object"},
+		{method: "GET", path: "/api/v1/policy/gen/:gen/objects/:ns/:kind", handle: api.handlePolicyObjectsList, auth: true, description: "Returns all objects of a given kind within a given namespace from policy with a given generation (empty list if there are none). Objects could be filtered by labels with ?label=<expression> (e.g. ?label=team=='dev'), using the same expressions as rule criteria", returns: "policy objects"},
+
+		// resolve a single claim from the policy (for debugging)
+		{method: "GET", path: "/api/v1/policy/gen/:gen/resolve/:ns/:claim", handle: api.withErrors(api.handleClaimResolve), auth: true, description: "Resolves a single claim of policy with a given generation without changing anything and returns component instances it uses along with their calculated parameters and resolution log. Log level could be set with ?loglevel=", returns: TypeClaimResolutionResult.Kind},
+
+		// update policy
+		{method: "POST", path: "/api/v1/policy", handle: api.withErrors(api.handlePolicyUpdate), auth: true, description: "Adds or updates policy objects and returns action plan to be applied along with ID of the enforcement, which could be polled to see its progress and outcome", accepts: lang.PolicyTypes, returns: TypePolicyUpdateResult.Kind},
+		{method: "POST", path: "/api/v1/policy/noop/:noop/loglevel/:loglevel", handle: api.withErrors(api.handlePolicyUpdate), auth: true, description: "Adds or updates policy objects, optionally in noop mode (only calculating action plan) and with a given level of returned event log. With Accept: text/event-stream (or stream=true query parameter), event log is streamed as Server-Sent Events while policy is resolved, followed by the result event. With async=true query parameter, objects are stored right away and policy is resolved in the background, returning 202 with the job to poll", accepts: lang.PolicyTypes, returns: TypePolicyUpdateResult.Kind},
+		{method: "DELETE", path: "/api/v1/policy", handle: api.withErrors(api.handlePolicyDelete), auth: true, description: "Deletes policy objects and returns action plan to be applied", accepts: lang.PolicyTypes, returns: TypePolicyUpdateResult.Kind},
+		{method: "DELETE", path: "/api/v1/policy/noop/:noop/loglevel/:loglevel", handle: api.withErrors(api.handlePolicyDelete), auth: true, description: "Deletes policy objects, optionally in noop mode (only calculating action plan) and with a given level of returned event log", accepts: lang.PolicyTypes, returns: TypePolicyUpdateResult.Kind},
+		{method: "POST", path: "/api/v1/policy/validate", handle: api.withErrors(api.handlePolicyValidate), auth: true, description: "Validates policy objects against the latest policy the same way policy update does, but without resolving desired state and without changing anything. Validation errors are returned in structured form (object namespace, kind, name, field and message) with 200", accepts: lang.PolicyTypes, returns: TypePolicyValidationResult.Kind},
+		{method: "POST", path: "/api/v1/policy/rollback/:gen", handle: api.withErrors(api.handlePolicyRollback), auth: true, description: "Rolls back policy to a given generation by making a new generation with the same objects (objects added since then get deleted) and returns action plan to be applied", returns: TypePolicyUpdateResult.Kind},
+		{method: "POST", path: "/api/v1/policy/rollback/:gen/noop/:noop/loglevel/:loglevel", handle: api.withErrors(api.handlePolicyRollback), auth: true, description: "Rolls back policy to a given generation and returns action plan to be applied. In noop mode, it only returns action plan without changing anything", returns: TypePolicyUpdateResult.Kind},
+		{method: "POST", path: "/api/v1/policy/queue/:priority", handle: api.withErrors(api.handlePolicyUpdate), auth: true, description: "Adds or updates policy objects and queues them for resolution in the background with a given priority (interactive, gitops or drift). Returns right away without the action plan", accepts: lang.PolicyTypes, returns: TypePolicyUpdateResult.Kind},
+		{method: "DELETE", path: "/api/v1/policy/queue/:priority", handle: api.withErrors(api.handlePolicyDelete), auth: true, description: "Deletes policy objects and queues policy for resolution in the background with a given priority (interactive, gitops or drift). Returns right away without the action plan", accepts: lang.PolicyTypes, returns: TypePolicyUpdateResult.Kind},
+
+		// poll asynchronous policy changes (made with ?async=true)
+		{method: "GET", path: "/api/v1/policy/job/:id", handle: api.withErrors(api.handlePolicyApplyJobGet), auth: true, description: "Returns job of asynchronous policy change with a given ID along with its state (pending, running, succeeded or failed), progress and the outcome of the change, once it's finished. Jobs expire after the configured retention", returns: engine.TypePolicyApplyJob.Kind},
+
+		// resolve hypothetical claims and see how many of them fit into capacity of the clusters
+		{method: "POST", path: "/api/v1/policy/simulate/capacity", handle: api.withErrors(api.handleCapacitySimulation), auth: true, description: "Resolves hypothetical claims on top of the latest policy without saving them and reports how many of them fit into capacity of the clusters", accepts: []*runtime.TypeInfo{lang.TypeClaim}, returns: TypeCapacitySimulationResult.Kind},
+
+		// report which labels are used by the policy
+		{method: "GET", path: "/api/v1/policy/labels/usage", handle: api.handleLabelUsageGet, auth: true, description: "Returns which policy objects use which labels, along with labels referenced from the policy which none of the users has and user labels not used by the policy", returns: TypeLabelUsageReport.Kind},
+
+		// policy & object diagrams
+		{method: "GET", path: "/api/v1/policy/diagram/object/:ns/:kind/:name", handle: api.handleObjectDiagram, auth: true, description: "Returns diagram for a given policy object", returns: "graph"},
+		{method: "GET", path: "/api/v1/policy/diagram/mode/:mode", handle: api.handlePolicyDiagram, auth: true, description: "Returns diagram of the latest policy in a given mode (policy, desired or actual)", returns: "graph"},
+		{method: "GET", path: "/api/v1/policy/diagram/mode/:mode/gen/:gen", handle: api.handlePolicyDiagram, auth: true, description: "Returns diagram of policy with a given generation in a given mode (policy, desired or actual)", returns: "graph"},
+		{method: "GET", path: "/api/v1/policy/diagram/compare/mode/:mode/gen/:gen/genBase/:genBase", handle: api.handlePolicyDiagramCompare, auth: true, description: "Returns diagram showing the difference between two policy generations in a given mode", returns: "graph"},
+
+		// retrieve claim along with its status
+		{method: "GET", path: "/api/v1/policy/claim/status/:queryFlag/:idList", handle: api.handleClaimStatusGet, auth: true, description: "Returns status (deployed or ready) for a comma-separated list of claims in 'namespace^name' format", returns: TypeClaimsStatus.Kind},
+		{method: "GET", path: "/api/v1/policy/claim/resources/:ns/:name", handle: api.handleClaimResourcesGet, auth: true, description: "Returns cluster resources created for a given claim. With ?preview=true, resources get rendered from desired state (even if they are not deployed yet), which requires plugins of all affected clusters to support preview", returns: "claim resources"},
+
+		// retrieve consumers of a component instance (for debugging)
+		{method: "GET", path: "/api/v1/instance/:key/consumers", handle: api.handleInstanceConsumersGet, auth: true, description: "Returns consumers of a given component instance", returns: TypeInstanceConsumers.Kind},
+
+		// retrieve revision (latest + by a given generation)
+		{method: "GET", path: "/api/v1/revision", handle: api.handleRevisionGet, auth: true, description: "Returns the latest revision", returns: engine.TypeRevision.Kind},
+		{method: "GET", path: "/api/v1/revision/gen/:gen", handle: api.handleRevisionGet, auth: true, description: "Returns revision with a given generation", returns: engine.TypeRevision.Kind},
+		{method: "GET", path: "/api/v1/revision/gen/:gen/log", handle: api.handleResolutionLogGet, auth: true, description: "Returns event log of policy resolution saved for revision with a given generation. Events could be filtered by level (?level=info returns info events and more severe ones) and by sequence number (?after=N returns events after the N-th one)", returns: engine.TypeResolutionLog.Kind},
+		{method: "GET", path: "/api/v1/revision/gen/:gen/actions/completed", handle: api.handleActionMarkersGet, auth: true, description: "Returns markers of actions completed while applying revision with a given generation. Actions with markers and unchanged inputs are not executed again if revision gets re-applied", returns: "action markers"},
+		{method: "GET", path: "/api/v1/revision/gen/:gen/desiredstate/instances", handle: api.handleDesiredStateInstancesGet, auth: true, description: "Returns keys of component instances in desired state of revision with a given generation. Keys could be filtered by prefix (?prefix=)", returns: TypeDesiredStateInstanceKeys.Kind},
+		{method: "GET", path: "/api/v1/revision/gen/:gen/desiredstate/instance/:key", handle: api.handleDesiredStateInstanceGet, auth: true, description: "Returns a single component instance from desired state of revision with a given generation, with values of sensitive parameters redacted", returns: resolve.TypeComponentInstance.Kind},
+
+		// retrieve revision(s) (for a given policy)
+		{method: "GET", path: "/api/v1/revisions/policy/:policy", handle: api.handleRevisionsGetByPolicy, auth: true, description: "Returns all revisions for policy with a given generation, or a page of them if 'limit' is set (next page is requested with 'continue' token from the response)", returns: "revisions"},
+
+		{method: "POST", path: "/api/v1/state/enforce/noop/:noop", handle: api.withErrors(api.handleStateEnforce), auth: true, description: "Refreshes actual state from clusters and enforces desired state, optionally in noop mode", returns: TypePolicyUpdateResult.Kind},
+
+		// poll progress and outcome of enforcement of a single policy change
+		{method: "GET", path: "/api/v1/enforcement/:id", handle: api.handleEnforcementGet, auth: true, description: "Returns enforcement with a given ID (returned by policy update as EnforcementID) along with its status (pending, running, succeeded or failed), results of all executed actions and the event log of applying them", returns: engine.TypeEnforcement.Kind},
+
+		// see the backlog of policy changes waiting to be resolved and enforced
+		{method: "GET", path: "/api/v1/state/enforcement", handle: api.handleEnforcementStatusGet, auth: true, description: "Returns the state of the resolution queue (depth, wait times and throughput by priority) along with revisions waiting to be enforced", returns: TypeEnforcementStatus.Kind},
+
+		// see which server is the leader running desired state enforcement
+		{method: "GET", path: "/api/v1/state/leader", handle: api.handleLeaderGet, auth: true, description: "Returns identity of the server, which is the leader running desired state enforcement, when multiple servers share the same registry", returns: TypeLeaderStatus.Kind},
+
+		// back up and restore the whole registry (domain admins only)
+		{method: "GET", path: "/api/v1/admin/backup", handle: api.handleBackup, auth: true, description: "Streams all objects from the registry (all generations of policies, policy objects and revisions, desired states and actual state) as a backup, which could be restored on a fresh server", returns: "backup"},
+		{method: "POST", path: "/api/v1/admin/restore", handle: api.handleRestore, auth: true, description: "Restores registry from a backup passed in the request body, keeping generations of all objects. Restoring into non-empty registry fails with 409 unless ?force=true is set. Registry gets marked as restored, so actual state gets reconciled with clusters before the next enforcement", returns: TypeRestoreResult.Kind},
+
+		// reconcile actual state after registry has been restored from a backup
+		{method: "POST", path: "/api/v1/state/restored", handle: api.handleStateRestored, auth: true, description: "Marks that registry has been restored from a backup, so actual state gets reconciled with clusters before the next enforcement", returns: engine.TypeReconciliation.Kind},
+		{method: "GET", path: "/api/v1/state/reconciliation", handle: api.handleReconciliationGet, auth: true, description: "Returns progress of actual state reconciliation along with the report of all corrections made", returns: engine.TypeReconciliation.Kind},
+		{method: "POST", path: "/api/v1/state/reconciliation/ack", handle: api.handleReconciliationAck, auth: true, description: "Acknowledges actual state corrections, which exceeded the threshold, and allows enforcement to proceed", returns: engine.TypeReconciliation.Kind},
+
+		// inject failures into applied actions (only when enabled in server config)
+		{method: "GET", path: "/api/v1/admin/failures", handle: api.handleFailureInjectionGet, auth: true, description: "Returns rules for injecting failures into applied actions. Failure injection has to be enabled in server config", returns: TypeFailureInjection.Kind},
+		{method: "POST", path: "/api/v1/admin/failures", handle: api.handleFailureInjectionUpdate, auth: true, description: "Replaces rules for injecting failures into applied actions (empty list disables injection). Failure injection has to be enabled in server config", accepts: []*runtime.TypeInfo{TypeFailureInjection}, returns: TypeFailureInjection.Kind},
+
+		// remove old generations of revisions, policies and policy objects right away (domain admins only)
+		{method: "POST", path: "/api/v1/admin/gc", handle: api.handleGarbageCollection, auth: true, description: "Removes old generations of revisions, policies and policy objects according to the retention from server config and returns how many object keys were removed. Generations of the latest policy and revisions still being processed or holding desired state in effect are always kept", returns: TypeGCResult.Kind},
+
+		// check and repair indexes of all versioned objects (domain admins only)
+		{method: "POST", path: "/api/v1/admin/indexes/rebuild", handle: api.handleIndexRebuild, auth: true, description: "Checks indexes of all versioned objects against stored objects and repairs missing, divergent and dangling index entries. With ?dryRun=true inconsistencies are only reported without changing anything", returns: TypeIndexRebuildResult.Kind},
+
+		// stream all objects of a given non-versioned kind (domain admins only)
+		{method: "GET", path: "/api/v1/admin/objects/:kind", handle: api.handleObjectsScan, auth: true, description: "Streams all objects of a given non-versioned kind as newline-delimited json (application/x-ndjson), one object per line", returns: "objects"},
+
+		// return aptomi version
+		{method: "GET", path: "/version", handle: api.handleVersion, description: "Returns version of the server", returns: version.TypeBuildInfo.Kind},
+		{method: "GET", path: "/api/v1/version", handle: api.handleVersion, description: "Returns version of the server", returns: version.TypeBuildInfo.Kind},
+	}
+}
+
+// handler adapts http.Handler to be used in the route table
+func handler(h http.Handler) httprouter.Handle {
+	return func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		h.ServeHTTP(writer, request)
+	}
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/julienschmidt/httprouter"
+	"github.com/sirupsen/logrus"
+)
+
+// TypeCapacitySimulationResult is an informational data structure with Kind and Constructor for CapacitySimulationResult
+var TypeCapacitySimulationResult = &runtime.TypeInfo{
+	Kind:        "capacity-simulation-result",
+	Constructor: func() runtime.Object { return &CapacitySimulationResult{} },
+}
+
+// CapacitySimulationResult represents results of resolving hypothetical claims on top of the current policy, showing
+// how many of them fit into the capacity of the clusters
+type CapacitySimulationResult struct {
+	runtime.TypeKind `yaml:",inline"`
+	PolicyGeneration runtime.Generation
+	Report           *resolve.CapacityReport
+	EventLog         []*event.APIEvent
+}
+
+// GetDefaultColumns returns default set of columns to be displayed
+func (result *CapacitySimulationResult) GetDefaultColumns() []string {
+	return []string{"Policy Generation", "Claims", "Fit", "Not Fit", "Not Resolved"}
+}
+
+// AsColumns returns CapacitySimulationResult representation as columns
+func (result *CapacitySimulationResult) AsColumns() map[string]string {
+	return map[string]string{
+		"Policy Generation": fmt.Sprintf("%d", result.PolicyGeneration),
+		"Claims":            fmt.Sprintf("%d", result.Report.Claims),
+		"Fit":               fmt.Sprintf("%d", result.Report.ClaimsFit),
+		"Not Fit":           fmt.Sprintf("%d", len(result.Report.ClaimsNotFit)),
+		"Not Resolved":      fmt.Sprintf("%d", len(result.Report.ClaimsNotResolved)),
+	}
+}
+
+// handleCapacitySimulation resolves hypothetical claims on top of the latest policy and reports how many of them fit
+// into the capacity of the clusters. Nothing gets saved into the registry
+func (api *coreAPI) handleCapacitySimulation(writer http.ResponseWriter, request *http.Request, params httprouter.Params) error {
+	objects, err := api.readLang(request)
+	if err != nil {
+		return err
+	}
+	user := api.getUserRequired(request)
+
+	// Load the latest policy, it's a copy, so hypothetical claims can be added to it
+	policy, policyGen, err := api.registry.GetPolicy(runtime.LastOrEmptyGen)
+	if err != nil {
+		return fmt.Errorf("error while loading current policy: %s", err)
+	}
+
+	claims := make([]*lang.Claim, 0, len(objects))
+	for _, obj := range objects {
+		claim, ok := obj.(*lang.Claim)
+		if !ok {
+			return newRequestError(http.StatusBadRequest, ErrorCodeInvalidRequest, "only claims can be simulated, while object of kind %s found", obj.GetKind())
+		}
+
+		errManage := policy.View(user).ManageObject(claim)
+		if errManage != nil {
+			return newRequestError(http.StatusForbidden, ErrorCodeForbidden, "error while adding claim to policy: %s", errManage)
+		}
+		errAdd := policy.AddObject(claim)
+		if errAdd != nil {
+			return newRequestError(http.StatusBadRequest, ErrorCodeInvalidPolicy, "error while adding claim to policy: %s", errAdd)
+		}
+		claims = append(claims, claim)
+	}
+
+	// Check that the policy is valid
+	err = lang.NewPolicyValidator(policy).Validate()
+	if err != nil {
+		return newRequestError(http.StatusBadRequest, ErrorCodeInvalidPolicy, "policy with simulated claims is invalid: %s", err)
+	}
+
+	// Resolve all claims, including hypothetical ones, and see how many of them fit
+	eventLog := api.newEventLog(logrus.WarnLevel, "api-capacity-simulation")
+	resolution, err := resolve.NewPolicyResolver(policy, api.externalData, eventLog).SetPluginRegistry(api.pluginRegistryFactory()).SetContinueOnError(true).ResolveAllClaims(request.Context())
+	if err != nil {
+		return resolutionError(err)
+	}
+
+	api.contentType.WriteOne(writer, request, &CapacitySimulationResult{
+		TypeKind:         TypeCapacitySimulationResult.GetTypeKind(),
+		PolicyGeneration: policyGen,
+		Report:           resolve.NewCapacityReport(policy, resolution, claims),
+		EventLog:         eventLog.AsAPIEvents(),
+	})
+	return nil
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/julienschmidt/httprouter"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the tracer used by API server to report tracing spans
+const TracerName = "aptomi/api"
+
+// TraceIDHeader is the name of the response header with ID of the trace, which covers processing of the request. It's
+// only returned when tracing is enabled
+const TraceIDHeader = "X-Trace-Id"
+
+const (
+	// SpanReadObjects is the name of the tracing span covering reading of policy objects from the request
+	SpanReadObjects = "api.read-objects"
+	// SpanLoadPolicy is the name of the tracing span covering loading of the current policy, revision and desired state
+	SpanLoadPolicy = "api.load-policy"
+	// SpanDiff is the name of the tracing span covering calculation of the action plan
+	SpanDiff = "api.diff"
+	// SpanChangePolicy is the name of the tracing span covering saving of policy changes and the new revision
+	SpanChangePolicy = "api.change-policy"
+)
+
+// traced wraps a given handle, so processing of every request gets covered by a tracing span named after the route.
+// Incoming trace context (e.g. traceparent header) is respected, so the span could be a part of the client's trace
+func traced(method string, path string, handle httprouter.Handle) httprouter.Handle {
+	name := "api " + method + " " + path
+	return func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		ctx := otel.GetTextMapPropagator().Extract(request.Context(), propagation.HeaderCarrier(request.Header))
+		ctx, span := otel.Tracer(TracerName).Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attribute.String("http.method", method), attribute.String("http.route", path)),
+		)
+		defer func() {
+			// error messages may contain object contents, so they are not recorded
+			if err := recover(); err != nil {
+				span.SetStatus(codes.Error, "request failed")
+				span.End()
+				panic(err)
+			}
+			span.End()
+		}()
+
+		if span.SpanContext().IsValid() {
+			writer.Header().Set(TraceIDHeader, span.SpanContext().TraceID().String())
+		}
+
+		handle(writer, request.WithContext(ctx), params)
+	}
+}
+
+// startSpan starts a tracing span for a single step of request processing
+func startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name)
+}
+
+// setRevisionTrace records the trace from a given context on the revision. It returns false if there is no trace to
+// record (e.g. tracing is disabled)
+func setRevisionTrace(ctx context.Context, revision *engine.Revision) bool {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return false
+	}
+	revision.TraceID = spanContext.TraceID().String()
+	revision.SpanID = spanContext.SpanID().String()
+	return true
+}
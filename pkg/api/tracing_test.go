@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/api/codec"
+	"github.com/Aptomi/aptomi/pkg/config"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+	"github.com/Aptomi/aptomi/pkg/plugin/fake"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// spanTree allows to look up spans by name and check their parents
+type spanTree struct {
+	spans tracetest.SpanStubs
+}
+
+func (tree *spanTree) find(name string) []tracetest.SpanStub {
+	result := []tracetest.SpanStub{}
+	for _, span := range tree.spans {
+		if span.Name == name {
+			result = append(result, span)
+		}
+	}
+	return result
+}
+
+func (tree *spanTree) assertChild(t *testing.T, parentName string, childName string) {
+	t.Helper()
+	parents := tree.find(parentName)
+	children := tree.find(childName)
+	if !assert.Len(t, parents, 1, "There should be a single span %s", parentName) || !assert.NotEmpty(t, children, "There should be span %s", childName) {
+		return
+	}
+	for _, child := range children {
+		assert.Equal(t, parents[0].SpanContext.SpanID(), child.Parent.SpanID(), "Span %s should be a child of %s", childName, parentName)
+		assert.Equal(t, parents[0].SpanContext.TraceID(), child.SpanContext.TraceID(), "Span %s should be a part of the same trace", childName)
+	}
+}
+
+func TestPolicyUpdateTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prevProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(prevProvider)
+
+	reg := registry.New(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()))
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	rule := b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+	user := b.AddUser()
+	user.DomainAdmin = true
+	claim := b.AddClaim(user, service)
+
+	server := NewServer(Options{
+		Registry:     reg,
+		ExternalData: b.External(),
+		PluginRegistryFactory: func() plugin.Registry {
+			return plugin.NewRegistry(
+				config.Plugins{},
+				map[string]plugin.ClusterPluginConstructor{
+					"kubernetes": func(cluster *lang.Cluster, cfg config.Plugins) (plugin.ClusterPlugin, error) {
+						return fake.NewNoOpClusterPlugin(0), nil
+					},
+				},
+				map[string]map[string]plugin.CodePluginConstructor{
+					"kubernetes": {
+						"helm": func(cluster plugin.ClusterPlugin, cfg config.Plugins) (plugin.CodePlugin, error) {
+							return fake.NewNoOpCodePlugin(0), nil
+						},
+					},
+				},
+			)
+		},
+		AuthProvider: &userAuthProvider{user: user},
+	})
+	apiCodec := codec.NewYAMLCodec(runtime.NewTypes().Append(Types...))
+	body, err := apiCodec.EncodeMany([]runtime.Object{cluster, bundle, service, rule, claim})
+	if !assert.NoError(t, err, "Policy objects should be encoded") {
+		t.FailNow()
+	}
+
+	// noop apply
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest("POST", "/api/v1/policy/noop/true/loglevel/warning", bytes.NewReader(body)))
+	if !assert.Equal(t, http.StatusOK, recorder.Code, "Policy update should be calculated: %s", recorder.Body.String()) {
+		t.FailNow()
+	}
+
+	requestSpan := "api POST /api/v1/policy/noop/:noop/loglevel/:loglevel"
+	tree := &spanTree{spans: exporter.GetSpans()}
+	if assert.Len(t, tree.find(requestSpan), 1, "Request should be traced") {
+		assert.Equal(t, tree.find(requestSpan)[0].SpanContext.TraceID().String(), recorder.Header().Get(TraceIDHeader), "Trace ID should be returned to the client")
+	}
+	tree.assertChild(t, requestSpan, SpanReadObjects)
+	tree.assertChild(t, requestSpan, SpanLoadPolicy)
+	tree.assertChild(t, SpanLoadPolicy, store.SpanFind)
+	tree.assertChild(t, requestSpan, resolve.SpanResolveAllClaims)
+	tree.assertChild(t, resolve.SpanResolveAllClaims, resolve.SpanResolveClaim)
+	tree.assertChild(t, requestSpan, SpanDiff)
+	assert.Empty(t, tree.find(SpanChangePolicy), "Policy should not be changed in noop mode")
+
+	// real apply records the trace on the revision
+	exporter.Reset()
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest("POST", "/api/v1/policy", bytes.NewReader(body)))
+	if !assert.Equal(t, http.StatusOK, recorder.Code, "Policy should be updated: %s", recorder.Body.String()) {
+		t.FailNow()
+	}
+	obj, err := apiCodec.DecodeOne(recorder.Body.Bytes())
+	if !assert.NoError(t, err, "Policy update result should be decoded") {
+		t.FailNow()
+	}
+
+	tree = &spanTree{spans: exporter.GetSpans()}
+	tree.assertChild(t, "api POST /api/v1/policy", SpanChangePolicy)
+	tree.assertChild(t, SpanChangePolicy, store.SpanSave)
+
+	revision, err := reg.GetRevision(obj.(*PolicyUpdateResult).WaitForRevision)
+	if !assert.NoError(t, err, "Revision should be loaded") || !assert.NotNil(t, revision, "Revision should exist") {
+		t.FailNow()
+	}
+	assert.Equal(t, recorder.Header().Get(TraceIDHeader), revision.TraceID, "Trace ID should be recorded on the revision")
+	assert.NotEmpty(t, revision.SpanID, "Span ID should be recorded on the revision")
+}
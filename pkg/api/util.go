@@ -1,32 +1,55 @@
 package api
 
 import (
-	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/Aptomi/aptomi/pkg/lang"
 	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/julienschmidt/httprouter"
 )
 
-func (api *coreAPI) readLang(request *http.Request) []lang.Base {
-	result := make([]lang.Base, 0)
+// readLang reads policy objects from the request body. Malformed body, non-policy objects and duplicates are
+// reported as bad request
+func (api *coreAPI) readLang(request *http.Request) ([]lang.Base, error) {
+	objects, err := api.contentType.Read(request)
+	if err != nil {
+		return nil, newRequestError(http.StatusBadRequest, ErrorCodeInvalidRequest, "%s", err)
+	}
 
-	exists := make(map[string]bool, len(result))
-	for _, obj := range api.contentType.Read(request) {
+	result := make([]lang.Base, 0, len(objects))
+	exists := make(map[string]bool, len(objects))
+	for _, obj := range objects {
 		langObj, ok := obj.(lang.Base)
 
 		if !ok {
-			panic(fmt.Sprintf("Trying to read lang objects while non-lang ones found: %s", obj.GetKind()))
+			return nil, newRequestError(http.StatusBadRequest, ErrorCodeInvalidRequest, "trying to read lang objects while non-lang ones found: %s", obj.GetKind())
 		}
 
 		objKey := runtime.KeyForStorable(langObj)
 		if exists[objKey] {
-			panic(fmt.Sprintf("Duplicate objects with key %s detected in the request", objKey))
+			return nil, newRequestError(http.StatusBadRequest, ErrorCodeInvalidRequest, "duplicate objects with key %s detected in the request", objKey)
 		}
 		exists[objKey] = true
 
 		result = append(result, langObj)
 	}
 
-	return result
+	return result, nil
+}
+
+// parseGenParam returns generation passed in a given request parameter or the latest generation if it's empty.
+// Malformed generation is reported as bad request
+func parseGenParam(params httprouter.Params, name string) (runtime.Generation, error) {
+	gen := params.ByName(name)
+	if len(gen) == 0 {
+		return runtime.LastOrEmptyGen, nil
+	}
+
+	val, err := strconv.ParseUint(gen, 10, 64)
+	if err != nil {
+		return 0, newRequestError(http.StatusBadRequest, ErrorCodeInvalidRequest, "invalid %s: %s", name, gen)
+	}
+
+	return runtime.Generation(val), nil
 }
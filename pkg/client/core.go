@@ -22,8 +22,12 @@ type Core interface {
 // Policy is the interface for managing Policy
 type Policy interface {
 	Show(gen runtime.Generation) (*engine.PolicyData, error)
-	Apply([]runtime.Object, bool, logrus.Level) (*api.PolicyUpdateResult, error)
-	Delete([]runtime.Object, bool, logrus.Level) (*api.PolicyUpdateResult, error)
+	History(limit int, token string) (*api.PolicyHistory, error)
+	Diff(from runtime.Generation, to runtime.Generation, resolve bool) (*api.PolicyDiffResult, error)
+	Rollback(gen runtime.Generation, noop bool, logLevel logrus.Level) (*api.PolicyUpdateResult, error)
+	Apply([]runtime.Object, bool, logrus.Level, bool) (*api.PolicyUpdateResult, error)
+	Delete([]runtime.Object, bool, logrus.Level, bool) (*api.PolicyUpdateResult, error)
+	SimulateCapacity([]runtime.Object) (*api.CapacitySimulationResult, error)
 }
 
 // Claim is the interface for managing Claim
@@ -126,7 +126,8 @@ func (client *httpClient) request(method string, path string, expected *runtime.
 			return nil, fmt.Errorf("server error, but it couldn't be casted to api.ServerError")
 		}
 
-		return nil, fmt.Errorf("server error: %s", serverErr.Error)
+		// status and code are kept, so callers could tell failures apart without matching error messages
+		return nil, &api.RequestError{Status: resp.StatusCode, Code: serverErr.Code, Message: fmt.Sprintf("server error: %s", serverErr.Error)}
 	}
 
 	if expected != nil && obj.GetKind() != expected.Kind {
@@ -2,6 +2,8 @@ package rest
 
 import (
 	"fmt"
+	"net/url"
+	"strconv"
 
 	"github.com/Aptomi/aptomi/pkg/api"
 	"github.com/Aptomi/aptomi/pkg/client/rest/http"
@@ -29,8 +31,48 @@ func (client *policyClient) Show(gen runtime.Generation) (*engine.PolicyData, er
 	return response.(*engine.PolicyData), nil
 }
 
-func (client *policyClient) Apply(updated []runtime.Object, noop bool, logLevel logrus.Level) (*api.PolicyUpdateResult, error) {
-	response, err := client.httpClient.POSTSlice(fmt.Sprintf("/policy/noop/%t/loglevel/%s", noop, logLevel.String()), api.TypePolicyUpdateResult, updated)
+func (client *policyClient) History(limit int, token string) (*api.PolicyHistory, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if len(token) > 0 {
+		query.Set("continue", token)
+	}
+
+	path := "/policy/history"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	response, err := client.httpClient.GET(path, api.TypePolicyHistory)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.(*api.PolicyHistory), nil
+}
+
+func (client *policyClient) Diff(from runtime.Generation, to runtime.Generation, resolve bool) (*api.PolicyDiffResult, error) {
+	path := fmt.Sprintf("/policy/diff/%d/%d", from, to)
+	if resolve {
+		path += "?resolve=true"
+	}
+
+	response, err := client.httpClient.GET(path, api.TypePolicyDiffResult)
+	if err != nil {
+		return nil, err
+	}
+
+	if serverError, ok := response.(*api.ServerError); ok {
+		return nil, fmt.Errorf("server error: %s", serverError.Error)
+	}
+
+	return response.(*api.PolicyDiffResult), nil
+}
+
+func (client *policyClient) Rollback(gen runtime.Generation, noop bool, logLevel logrus.Level) (*api.PolicyUpdateResult, error) {
+	response, err := client.httpClient.POST(fmt.Sprintf("/policy/rollback/%d/noop/%t/loglevel/%s", gen, noop, logLevel.String()), api.TypePolicyUpdateResult, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -42,8 +84,12 @@ func (client *policyClient) Apply(updated []runtime.Object, noop bool, logLevel
 	return response.(*api.PolicyUpdateResult), nil
 }
 
-func (client *policyClient) Delete(updated []runtime.Object, noop bool, logLevel logrus.Level) (*api.PolicyUpdateResult, error) {
-	response, err := client.httpClient.DELETESlice(fmt.Sprintf("/policy/noop/%t/loglevel/%s", noop, logLevel.String()), api.TypePolicyUpdateResult, updated)
+func (client *policyClient) Apply(updated []runtime.Object, noop bool, logLevel logrus.Level, continueOnError bool) (*api.PolicyUpdateResult, error) {
+	path := fmt.Sprintf("/policy/noop/%t/loglevel/%s", noop, logLevel.String())
+	if continueOnError {
+		path += "?" + api.ContinueOnErrorParam + "=true"
+	}
+	response, err := client.httpClient.POSTSlice(path, api.TypePolicyUpdateResult, updated)
 	if err != nil {
 		return nil, err
 	}
@@ -54,3 +100,33 @@ func (client *policyClient) Delete(updated []runtime.Object, noop bool, logLevel
 
 	return response.(*api.PolicyUpdateResult), nil
 }
+
+func (client *policyClient) Delete(updated []runtime.Object, noop bool, logLevel logrus.Level, continueOnError bool) (*api.PolicyUpdateResult, error) {
+	path := fmt.Sprintf("/policy/noop/%t/loglevel/%s", noop, logLevel.String())
+	if continueOnError {
+		path += "?" + api.ContinueOnErrorParam + "=true"
+	}
+	response, err := client.httpClient.DELETESlice(path, api.TypePolicyUpdateResult, updated)
+	if err != nil {
+		return nil, err
+	}
+
+	if serverError, ok := response.(*api.ServerError); ok {
+		return nil, fmt.Errorf("server error: %s", serverError.Error)
+	}
+
+	return response.(*api.PolicyUpdateResult), nil
+}
+
+func (client *policyClient) SimulateCapacity(claims []runtime.Object) (*api.CapacitySimulationResult, error) {
+	response, err := client.httpClient.POSTSlice("/policy/simulate/capacity", api.TypeCapacitySimulationResult, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if serverError, ok := response.(*api.ServerError); ok {
+		return nil, fmt.Errorf("server error: %s", serverError.Error)
+	}
+
+	return response.(*api.CapacitySimulationResult), nil
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"time"
 
+	"github.com/Aptomi/aptomi/pkg/api/admission"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/bolt"
 	"github.com/Aptomi/aptomi/pkg/runtime/store/etcd"
 	"github.com/sirupsen/logrus"
 )
@@ -18,9 +21,15 @@ type Server struct {
 	SecretsDir           string               `validate:"omitempty,dir"` // secrets is not a first-class citizen yet, so it's not required
 	Enforcer             DesiredStateEnforcer `validate:"required"`
 	Updater              ActualStateUpdater   `validate:"required"`
+	Pipeline             RevisionPipeline     `validate:"-"`
+	Resolver             Resolver             `validate:"-"`
+	GC                   GC                   `validate:"-"`
 	DomainAdminOverrides map[string]bool      `validate:"-"`
 	Auth                 ServerAuth           `validate:"-"`
 	Profile              Profile              `validate:"-"`
+	Tracing              Tracing              `validate:"-"`
+	EventLog             EventLog             `validate:"-"`
+	Admission            Admission            `validate:"omitempty"`
 }
 
 // IsDebug returns true if debug mode enabled
@@ -42,9 +51,85 @@ type UserSources struct {
 	File []string `validate:"dive,file"`
 }
 
-// DB represents configs for DB
+const (
+	// DBBackendEtcd is the default DB backend, which stores all data in etcd cluster
+	DBBackendEtcd = "etcd"
+	// DBBackendBolt is the DB backend, which stores all data in a local file. It's intended for single-node installs
+	DBBackendBolt = "bolt"
+)
+
+const (
+	// DBCodecYAML is the default DB codec, which stores objects in human readable YAML
+	DBCodecYAML = "yaml"
+	// DBCodecGob is the DB codec, which stores objects in compact binary gob format. It's faster and produces smaller
+	// objects, which matters for large desired states
+	DBCodecGob = "gob"
+)
+
+// DB represents configs for DB. Etcd options are kept at the top level for compatibility, while options of other
+// backends are nested under their names
 // todo reconsider for better approach for plugin/backend specific configs
-type DB = etcd.Config
+type DB struct {
+	// Backend is the DB backend to use, either "etcd" or "bolt". If not set, etcd is used
+	Backend string `validate:"omitempty,eq=etcd|eq=bolt"`
+
+	// Codec is the codec used to store objects, either "yaml" or "gob". If not set, yaml is used. Objects stored with
+	// one codec couldn't be read with the other, so it should be changed only together with data migration
+	Codec string `validate:"omitempty,eq=yaml|eq=gob"`
+
+	// Compression is the algorithm used to compress stored objects, either "gzip" or "snappy". If not set, objects
+	// aren't compressed. Objects stored without compression are still readable after it's enabled
+	Compression string `validate:"omitempty,eq=gzip|eq=snappy"`
+
+	// CompressionLevel is the gzip compression level from 1 (best speed) to 9 (best compression), 0 means default
+	CompressionLevel int `validate:"min=0,max=9"`
+
+	// EncryptionKeys are the keys used to encrypt stored objects with AES-GCM. The key with the highest version is
+	// used to encrypt objects, while others are only used to decrypt objects stored before the key was rotated. If not
+	// set, objects aren't encrypted. Objects stored without encryption are still readable after it's enabled
+	EncryptionKeys []DBEncryptionKey `validate:"dive"`
+
+	// Metrics enables collection of Prometheus metrics (number, duration and transaction retries) for all store operations
+	Metrics bool
+
+	etcd.Config `mapstructure:",squash" yaml:",inline"`
+
+	Bolt bolt.Config
+}
+
+// DBEncryptionKey represents AES key (16, 24 or 32 bytes) used to encrypt stored objects. Key should be set base64
+// encoded either directly or as a path to the file it's stored in
+type DBEncryptionKey struct {
+	// Version identifies the key in encrypted objects, so it should never be reused for a different key
+	Version int `validate:"min=1,max=255"`
+
+	Key string `yaml:",omitempty"`
+
+	KeyFile string `yaml:",omitempty"`
+}
+
+// GetBackend returns the DB backend to use
+func (db DB) GetBackend() string {
+	if len(db.Backend) == 0 {
+		return DBBackendEtcd
+	}
+	return db.Backend
+}
+
+// GetCodec returns the DB codec to use
+func (db DB) GetCodec() string {
+	if len(db.Codec) == 0 {
+		return DBCodecYAML
+	}
+	return db.Codec
+}
+
+// EventLog represents config for the in-memory buffer of event logs (e.g. max number of events kept in memory)
+type EventLog = event.BufferConfig
+
+// Admission represents config of admission webhooks, which allow, deny or mutate every policy change (e.g. to enforce
+// naming conventions and mandatory labels)
+type Admission = admission.Config
 
 // DesiredStateEnforcer represents config for desired state enforcer background process that periodically gets latest policy, calculating
 // difference between it and actual state and then applying calculated actions
@@ -54,6 +139,17 @@ type DesiredStateEnforcer struct {
 	Noop                 bool          `validate:"-"`
 	NoopSleep            time.Duration `validate:"-"`
 	MaxConcurrentActions int           `validate:"-"`
+
+	// FailureInjection enables injecting failures into applied actions, configured via admin API. It's only intended
+	// for testing how enforcement behaves under failures and must never be enabled in production
+	FailureInjection bool `validate:"-"`
+
+	// LeaderElection makes servers sharing the same DB elect a single leader, so only the leader enforces desired
+	// state, while others keep serving API requests. It's only supported by etcd DB backend
+	LeaderElection bool `validate:"-"`
+
+	// LeaderIdentity identifies this server in leader election. If not set, host name and process id are used
+	LeaderIdentity string `validate:"-"`
 }
 
 // ActualStateUpdater represents config for actual state updater background process that periodically refreshes actual state
@@ -66,6 +162,53 @@ type ActualStateUpdater struct {
 	MaxConcurrentActions int           `validate:"-"`
 }
 
+// RevisionPipeline represents config for revision pipeline, which resolves large policy changes in the background
+// instead of doing it synchronously within API requests. It runs along with desired state enforcer
+type RevisionPipeline struct {
+	Disabled bool `validate:"-"`
+
+	// QueueSize is the max number of policy changes waiting for resolution. Policy changes get rejected when it's full
+	QueueSize int `validate:"-"`
+
+	// Workers is the number of policy changes resolved in parallel
+	Workers int `validate:"-"`
+
+	// SyncMaxObjects is the max number of objects in a policy change, which still gets resolved synchronously
+	SyncMaxObjects int `validate:"-"`
+
+	// JobRetention is how long jobs of asynchronous policy changes are kept after they finish
+	JobRetention time.Duration `validate:"-"`
+}
+
+// Resolver represents config for policy resolution
+type Resolver struct {
+	// MaxConcurrency is the max number of claims resolved concurrently within a single policy resolution (0 means the
+	// number of CPUs)
+	MaxConcurrency int `validate:"-"`
+
+	// CacheSize is the max number of desired states of policy generations kept in memory by API, so policy generations
+	// which have been resolved before don't get resolved again (0 means the default size, negative value disables it)
+	CacheSize int `validate:"-"`
+}
+
+// GC represents config for garbage collection, which periodically removes old generations of revisions, policies and
+// policy objects, so they don't accumulate in the store forever
+type GC struct {
+	Disabled bool `validate:"-"`
+
+	// Interval is how often garbage collection runs in the background
+	Interval time.Duration `validate:"-"`
+
+	// KeepLast is the number of last generations kept for every policy object
+	KeepLast int `validate:"-"`
+
+	// KeepLastHistory is the number of last generations kept for policy and revision
+	KeepLastHistory int `validate:"-"`
+
+	// KeepLastByKind overrides the number of last generations kept for objects of specific kinds
+	KeepLastByKind map[string]int `validate:"-"`
+}
+
 // ServerAuth represents server auth config
 type ServerAuth struct {
 	Secret string `validate:"-"`
@@ -76,3 +219,16 @@ type Profile struct {
 	CPU   string
 	Trace string
 }
+
+// Tracing represents config for exporting OpenTelemetry traces (API requests, policy resolution, store operations and
+// enforcement) to the collector. Traces are sent to Endpoint, which is the URL of Jaeger collector HTTP endpoint
+// (e.g. http://127.0.0.1:14268/api/traces), so TLS is used if the URL has https scheme. When tracing is disabled,
+// no-op tracer is used
+type Tracing struct {
+	Enabled  bool
+	Endpoint string
+
+	// SampleRatio is the fraction of requests to be traced (e.g. 0.1 to trace every 10th request). Requests which
+	// are already traced by the client are always traced. If not set, all requests are traced
+	SampleRatio float64
+}
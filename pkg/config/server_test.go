@@ -10,3 +10,13 @@ func TestConfigServer(t *testing.T) {
 	config := &Server{}
 	assert.Equal(t, false, config.IsDebug(), "IsDebug() must be false for default server config")
 }
+
+func TestConfigServerDBBackend(t *testing.T) {
+	assert.Equal(t, DBBackendEtcd, DB{}.GetBackend(), "Etcd must be used by default")
+	assert.Equal(t, DBBackendBolt, DB{Backend: DBBackendBolt}.GetBackend(), "Bolt must be used if it's set")
+}
+
+func TestConfigServerDBCodec(t *testing.T) {
+	assert.Equal(t, DBCodecYAML, DB{}.GetCodec(), "YAML must be used by default")
+	assert.Equal(t, DBCodecGob, DB{Codec: DBCodecGob}.GetCodec(), "Gob must be used if it's set")
+}
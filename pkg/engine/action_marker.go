@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+// ActionMarkerResultSuccess is a result of action, which has been completed successfully
+const ActionMarkerResultSuccess = "success"
+
+// TypeActionMarker is an informational data structure with Kind and Constructor for ActionMarker
+var TypeActionMarker = &runtime.TypeInfo{
+	Kind:        "action-marker",
+	Storable:    true,
+	Versioned:   false,
+	Constructor: func() runtime.Object { return &ActionMarker{} },
+}
+
+// ActionMarker records that an action has been completed while applying specific revision. Markers are stored
+// per revision and only apply to it, so they get deleted once the revision is completed. When a revision gets
+// re-applied after being interrupted, actions with markers and unchanged inputs are not executed again
+type ActionMarker struct {
+	runtime.TypeKind `yaml:",inline"`
+
+	RevisionGen runtime.Generation
+	ActionKey   string
+	InputHash   string
+	Result      string
+	CompletedAt time.Time
+}
+
+// NewActionMarker creates a new ActionMarker for successfully completed action
+func NewActionMarker(revisionGen runtime.Generation, actionKey string, inputHash string) *ActionMarker {
+	return &ActionMarker{
+		TypeKind:    TypeActionMarker.GetTypeKind(),
+		RevisionGen: revisionGen,
+		ActionKey:   actionKey,
+		InputHash:   inputHash,
+		Result:      ActionMarkerResultSuccess,
+		CompletedAt: time.Now(),
+	}
+}
+
+// GetName returns name of the ActionMarker
+func (marker *ActionMarker) GetName() string {
+	return GetActionMarkersPrefix(marker.RevisionGen) + marker.ActionKey
+}
+
+// GetNamespace returns namespace of the ActionMarker
+func (marker *ActionMarker) GetNamespace() string {
+	return runtime.SystemNS
+}
+
+// GetDefaultColumns returns default set of columns to be displayed
+func (marker *ActionMarker) GetDefaultColumns() []string {
+	return []string{"Revision", "Action", "Result", "Completed"}
+}
+
+// AsColumns returns ActionMarker representation as columns
+func (marker *ActionMarker) AsColumns() map[string]string {
+	return map[string]string{
+		"Revision":   marker.RevisionGen.String(),
+		"Action":     marker.ActionKey,
+		"Input Hash": marker.InputHash,
+		"Result":     marker.Result,
+		"Completed":  formatTime(marker.CompletedAt),
+	}
+}
+
+// GetActionMarkersPrefix returns common name prefix of all ActionMarkers for specific Revision generation
+func GetActionMarkersPrefix(revisionGen runtime.Generation) string {
+	return fmt.Sprintf("revision-%s-action-", revisionGen)
+}
@@ -8,6 +8,7 @@ import (
 type Interface interface {
 	GetKind() string
 	GetName() string
+	GetCluster() string
 	Apply(*Context) error
 	DescribeChanges() util.NestedParameterMap
 }
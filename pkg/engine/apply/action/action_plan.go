@@ -30,6 +30,26 @@ func (plan *Plan) GetActionGraphNode(key string) *GraphNode {
 	return result
 }
 
+// FilterByCluster returns a new action plan, which only contains actions targeting a given cluster. The structure of
+// the action graph is preserved, so the actions will be executed in the same order as in the original plan
+func (plan *Plan) FilterByCluster(cluster string) *Plan {
+	result := NewPlan()
+	for key, node := range plan.NodeMap {
+		resultNode := result.GetActionGraphNode(key)
+		for _, act := range node.Actions {
+			if act.GetCluster() == cluster {
+				resultNode.Actions = append(resultNode.Actions, act)
+			}
+		}
+	}
+	for key, node := range plan.NodeMap {
+		for _, before := range node.Before {
+			result.GetActionGraphNode(key).AddBefore(result.GetActionGraphNode(before.Key))
+		}
+	}
+	return result
+}
+
 // Apply applies the action plan. It may call fn in multiple go routines, executing the plan in parallel
 func (plan *Plan) Apply(fn ApplyFunction, resultUpdater ApplyResultUpdater) *ApplyResult {
 	// make sure we are converting panics into errors
@@ -111,7 +131,9 @@ func (plan *Plan) applyActions(key string, fn ApplyFunction, queue chan string,
 		} else {
 			// Otherwise, let's run the action and see if it failed or not
 			err := fn(action)
-			if err != nil {
+			if err == ErrPreviouslyCompleted {
+				resultUpdater.AddPreviouslyCompleted()
+			} else if err != nil {
 				resultUpdater.AddFailed()
 				foundErr = err
 			} else {
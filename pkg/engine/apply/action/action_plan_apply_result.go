@@ -10,7 +10,17 @@ type ApplyResult struct {
 	Success uint32
 	Failed  uint32
 	Skipped uint32
-	Total   uint32
+
+	// PreviouslyCompleted is the number of actions, which haven't been executed, because they have been completed
+	// with the same inputs before the revision got interrupted
+	PreviouslyCompleted uint32
+
+	Total uint32
+}
+
+// Processed returns the number of actions processed so far
+func (result *ApplyResult) Processed() uint32 {
+	return result.Success + result.Failed + result.Skipped + result.PreviouslyCompleted
 }
 
 // ApplyResultUpdater is an interface for handling revision progress stats (# of processed actions) when applying action plan
@@ -19,6 +29,7 @@ type ApplyResultUpdater interface {
 	AddSuccess()
 	AddFailed()
 	AddSkipped()
+	AddPreviouslyCompleted()
 	Done() *ApplyResult
 }
 
@@ -54,10 +65,15 @@ func (updater *ApplyResultUpdaterImpl) AddSkipped() {
 	atomic.AddUint32(&updater.Result.Skipped, 1)
 }
 
+// AddPreviouslyCompleted safely increments the number of actions, which have been completed previously
+func (updater *ApplyResultUpdaterImpl) AddPreviouslyCompleted() {
+	atomic.AddUint32(&updater.Result.PreviouslyCompleted, 1)
+}
+
 // Done does nothing except doing an integrity check for default implementation
 func (updater *ApplyResultUpdaterImpl) Done() *ApplyResult {
-	if updater.Result.Success+updater.Result.Failed+updater.Result.Skipped != updater.Result.Total {
-		panic(fmt.Sprintf("error while applying actions: %d (success) + %d (failed) + %d (skipped) != %d (total)", updater.Result.Success, updater.Result.Failed, updater.Result.Skipped, updater.Result.Total))
+	if updater.Result.Processed() != updater.Result.Total {
+		panic(fmt.Sprintf("error while applying actions: %d (success) + %d (failed) + %d (skipped) + %d (previously completed) != %d (total)", updater.Result.Success, updater.Result.Failed, updater.Result.Skipped, updater.Result.PreviouslyCompleted, updater.Result.Total))
 	}
 	return updater.Result
 }
@@ -0,0 +1,54 @@
+package action
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ErrPreviouslyCompleted is returned by ApplyFunction when action hasn't been executed, because it has already been
+// completed with the same inputs (e.g. by the run of the same revision, which got interrupted)
+var ErrPreviouslyCompleted = errors.New("action has been completed previously with the same inputs")
+
+// CompletionMarkers keeps track of completed actions, so they don't get executed again when a revision gets
+// re-applied after being interrupted (crash, cancellation, leader failover)
+type CompletionMarkers interface {
+	// IsCompleted returns true if action has been completed with the same inputs
+	IsCompleted(act Interface, inputHash string) bool
+
+	// MarkCompleted durably records that action has been completed with given inputs
+	MarkCompleted(act Interface, inputHash string) error
+}
+
+// ActualStateRecorder is implemented by actions, which are able to record their changes in actual state without
+// executing them. When action gets skipped, because it has been completed previously, its changes still have to be
+// recorded, as applying could have been interrupted before actual state got saved. Actions, which don't implement
+// it, get executed again
+type ActualStateRecorder interface {
+	// RecordActualState makes the same changes in actual state as action does, but without calling plugins
+	RecordActualState(context *Context) error
+}
+
+// InputHash returns content hash of action inputs. If inputs of an action change, its hash changes as well and
+// previously recorded completion of the action no longer applies
+func InputHash(act Interface) string {
+	inputs := make(map[string]interface{})
+	for key, value := range act.DescribeChanges() {
+		// pretty description is for humans only
+		if key != "pretty" {
+			inputs[key] = value
+		}
+	}
+
+	// yaml marshals maps with sorted keys, so the same inputs always produce the same hash
+	data, err := yaml.Marshal(inputs)
+	if err != nil {
+		panic(fmt.Sprintf("error while calculating inputs hash of action '%s': %s", act.GetName(), err))
+	}
+	hash := sha256.Sum256(data)
+
+	return hex.EncodeToString(hash[:])
+}
@@ -21,7 +21,7 @@ type AttachClaimAction struct {
 // NewAttachClaimAction creates new AttachClaimAction
 func NewAttachClaimAction(componentKey string, claimKey string, depth int) *AttachClaimAction {
 	return &AttachClaimAction{
-		Metadata:     action.NewMetadata("action-component-claim-attach", componentKey, claimKey),
+		Metadata:     newMetadata("action-component-claim-attach", componentKey, claimKey),
 		ComponentKey: componentKey,
 		ClaimKey:     claimKey,
 		Depth:        depth,
@@ -41,6 +41,11 @@ func (a *AttachClaimAction) Apply(context *action.Context) (errResult error) {
 
 	context.EventLog.NewEntry().Debugf("Attaching claim '%s' to component instance: '%s'", a.ClaimKey, a.ComponentKey)
 
+	return a.RecordActualState(context)
+}
+
+// RecordActualState attaches claim to component instance in actual state. It's the only change action makes
+func (a *AttachClaimAction) RecordActualState(context *action.Context) error {
 	return context.ActualStateUpdater.UpdateComponentInstance(a.ComponentKey, func(obj *resolve.ComponentInstance) {
 		obj.ClaimKeys[a.ClaimKey] = a.Depth
 	})
@@ -49,9 +54,10 @@ func (a *AttachClaimAction) Apply(context *action.Context) (errResult error) {
 // DescribeChanges returns text-based description of changes that will be applied
 func (a *AttachClaimAction) DescribeChanges() util.NestedParameterMap {
 	return util.NestedParameterMap{
-		"kind":   a.Kind,
-		"key":    a.ComponentKey,
-		"claim":  a.ClaimKey,
-		"pretty": fmt.Sprintf("[>] %s = %s", a.ComponentKey, a.ClaimKey),
+		"kind":    a.Kind,
+		"cluster": a.Cluster,
+		"key":     a.ComponentKey,
+		"claim":   a.ClaimKey,
+		"pretty":  fmt.Sprintf("[>] %s = %s", a.ComponentKey, a.ClaimKey),
 	}
 }
@@ -20,7 +20,7 @@ type DetachClaimAction struct {
 // NewDetachClaimAction creates new DetachClaimAction
 func NewDetachClaimAction(componentKey string, claimKey string) *DetachClaimAction {
 	return &DetachClaimAction{
-		Metadata:     action.NewMetadata("action-component-claim-detach", componentKey, claimKey),
+		Metadata:     newMetadata("action-component-claim-detach", componentKey, claimKey),
 		ComponentKey: componentKey,
 		ClaimKey:     claimKey,
 	}
@@ -39,6 +39,11 @@ func (a *DetachClaimAction) Apply(context *action.Context) (errResult error) {
 
 	context.EventLog.NewEntry().Debugf("Detaching claim '%s' from component instance: '%s'", a.ClaimKey, a.ComponentKey)
 
+	return a.RecordActualState(context)
+}
+
+// RecordActualState detaches claim from component instance in actual state. It's the only change action makes
+func (a *DetachClaimAction) RecordActualState(context *action.Context) error {
 	return context.ActualStateUpdater.UpdateComponentInstance(a.ComponentKey, func(obj *resolve.ComponentInstance) {
 		delete(obj.ClaimKeys, a.ClaimKey)
 	})
@@ -47,9 +52,10 @@ func (a *DetachClaimAction) Apply(context *action.Context) (errResult error) {
 // DescribeChanges returns text-based description of changes that will be applied
 func (a *DetachClaimAction) DescribeChanges() util.NestedParameterMap {
 	return util.NestedParameterMap{
-		"kind":   a.Kind,
-		"key":    a.ComponentKey,
-		"claim":  a.ClaimKey,
-		"pretty": fmt.Sprintf("[<] %s = %s", a.ComponentKey, a.ClaimKey),
+		"kind":    a.Kind,
+		"cluster": a.Cluster,
+		"key":     a.ComponentKey,
+		"claim":   a.ClaimKey,
+		"pretty":  fmt.Sprintf("[<] %s = %s", a.ComponentKey, a.ClaimKey),
 	}
 }
@@ -22,7 +22,7 @@ type CreateAction struct {
 // NewCreateAction creates new CreateAction
 func NewCreateAction(componentKey string, params util.NestedParameterMap) *CreateAction {
 	return &CreateAction{
-		Metadata:     action.NewMetadata("action-component-create", componentKey),
+		Metadata:     newMetadata("action-component-create", componentKey),
 		ComponentKey: componentKey,
 		Params:       params,
 	}
@@ -51,13 +51,29 @@ func (a *CreateAction) Apply(context *action.Context) (errResult error) {
 	return context.ActualStateUpdater.CreateComponentInstance(instance)
 }
 
+// RecordActualState records component instance in actual state without deploying it
+func (a *CreateAction) RecordActualState(context *action.Context) error {
+	if context.ActualStateUpdater.GetComponentInstance(a.ComponentKey) != nil {
+		// instance has been recorded already
+		return nil
+	}
+
+	instance := context.DesiredState.ComponentInstanceMap[a.ComponentKey]
+	if instance == nil {
+		return fmt.Errorf("component instance not found in desired state: %s", a.ComponentKey)
+	}
+
+	return context.ActualStateUpdater.CreateComponentInstance(instance)
+}
+
 // DescribeChanges returns text-based description of changes that will be applied
 func (a *CreateAction) DescribeChanges() util.NestedParameterMap {
 	return util.NestedParameterMap{
-		"kind":   a.Kind,
-		"key":    a.ComponentKey,
-		"params": a.Params,
-		"pretty": fmt.Sprintf("[+] %s", a.ComponentKey),
+		"kind":    a.Kind,
+		"cluster": a.Cluster,
+		"key":     a.ComponentKey,
+		"params":  a.Params,
+		"pretty":  fmt.Sprintf("[+] %s", a.ComponentKey),
 	}
 }
 
@@ -22,7 +22,7 @@ type DeleteAction struct {
 // NewDeleteAction creates new DeleteAction
 func NewDeleteAction(componentKey string, params util.NestedParameterMap) *DeleteAction {
 	return &DeleteAction{
-		Metadata:     action.NewMetadata("action-component-delete", componentKey),
+		Metadata:     newMetadata("action-component-delete", componentKey),
 		ComponentKey: componentKey,
 		Params:       params,
 	}
@@ -51,13 +51,24 @@ func (a *DeleteAction) Apply(context *action.Context) (errResult error) {
 	return context.ActualStateUpdater.DeleteComponentInstance(instance.GetKey())
 }
 
+// RecordActualState deletes component instance from actual state without destroying it in the cloud
+func (a *DeleteAction) RecordActualState(context *action.Context) error {
+	if context.ActualStateUpdater.GetComponentInstance(a.ComponentKey) == nil {
+		// instance has been deleted already
+		return nil
+	}
+
+	return context.ActualStateUpdater.DeleteComponentInstance(a.ComponentKey)
+}
+
 // DescribeChanges returns text-based description of changes that will be applied
 func (a *DeleteAction) DescribeChanges() util.NestedParameterMap {
 	return util.NestedParameterMap{
-		"kind":   a.Kind,
-		"key":    a.ComponentKey,
-		"params": a.Params,
-		"pretty": fmt.Sprintf("[-] %s", a.ComponentKey),
+		"kind":    a.Kind,
+		"cluster": a.Cluster,
+		"key":     a.ComponentKey,
+		"params":  a.Params,
+		"pretty":  fmt.Sprintf("[-] %s", a.ComponentKey),
 	}
 }
 
@@ -21,7 +21,7 @@ type EndpointsAction struct {
 // NewEndpointsAction creates new EndpointsAction
 func NewEndpointsAction(componentKey string) *EndpointsAction {
 	return &EndpointsAction{
-		Metadata:     action.NewMetadata("action-component-endpoints", componentKey),
+		Metadata:     newMetadata("action-component-endpoints", componentKey),
 		ComponentKey: componentKey,
 	}
 }
@@ -56,6 +56,7 @@ func (a *EndpointsAction) Apply(context *action.Context) (errResult error) {
 func (a *EndpointsAction) DescribeChanges() util.NestedParameterMap {
 	return util.NestedParameterMap{
 		"kind":       a.Kind,
+		"cluster":    a.Cluster,
 		"key":        a.ComponentKey,
 		"pretty":     fmt.Sprintf("[@] %s", a.ComponentKey),
 		"prettyOmit": "true", // do not print endpoint lines in pretty output
@@ -0,0 +1,13 @@
+package component
+
+import (
+	"github.com/Aptomi/aptomi/pkg/engine/apply/action"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+)
+
+// newMetadata creates metadata for a component action, setting the cluster which the component instance is targeting
+func newMetadata(kind string, componentKey string, keys ...string) *action.Metadata {
+	result := action.NewMetadata(kind, append([]string{componentKey}, keys...)...)
+	result.Cluster = resolve.GetClusterFromKey(componentKey)
+	return result
+}
@@ -23,7 +23,7 @@ type UpdateAction struct {
 // NewUpdateAction creates new UpdateAction
 func NewUpdateAction(componentKey string, paramsBefore util.NestedParameterMap, params util.NestedParameterMap) *UpdateAction {
 	return &UpdateAction{
-		Metadata:     action.NewMetadata("action-component-update", componentKey),
+		Metadata:     newMetadata("action-component-update", componentKey),
 		ComponentKey: componentKey,
 		ParamsBefore: paramsBefore,
 		Params:       params,
@@ -49,7 +49,21 @@ func (a *UpdateAction) Apply(context *action.Context) (errResult error) {
 		return fmt.Errorf("unable to update component instance '%s': %s", a.ComponentKey, err)
 	}
 
-	// update component instance code params in actual state
+	return a.updateActualState(context, instance)
+}
+
+// RecordActualState records code params of component instance in actual state without updating it in the cloud
+func (a *UpdateAction) RecordActualState(context *action.Context) error {
+	instance := context.DesiredState.ComponentInstanceMap[a.ComponentKey]
+	if instance == nil {
+		return fmt.Errorf("component instance not found desired state: %s", a.ComponentKey)
+	}
+
+	return a.updateActualState(context, instance)
+}
+
+// updateActualState updates component instance code params in actual state
+func (a *UpdateAction) updateActualState(context *action.Context, instance *resolve.ComponentInstance) error {
 	if instance.CalculatedCodeParams != nil {
 		return context.ActualStateUpdater.UpdateComponentInstance(instance.GetKey(), func(obj *resolve.ComponentInstance) {
 			obj.EndpointsUpToDate = false // invalidate endpoints, so we retrieve them again later
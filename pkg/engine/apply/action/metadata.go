@@ -8,8 +8,9 @@ import (
 
 // Metadata is an object metadata for all state update actions
 type Metadata struct {
-	Kind string
-	Name string
+	Kind    string
+	Name    string
+	Cluster string
 }
 
 // NewMetadata creates new Metadata
@@ -32,6 +33,11 @@ func (meta *Metadata) GetName() string {
 	return meta.Name
 }
 
+// GetCluster returns the cluster, which an action is targeting, in form namespace/name
+func (meta *Metadata) GetCluster() string {
+	return meta.Cluster
+}
+
 func (meta *Metadata) String() string {
 	return meta.GetName()
 }
@@ -1,6 +1,7 @@
 package apply
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -128,8 +129,11 @@ func applyAndCheckBenchmark(b *testing.B, apply *EngineApply, expectedResult act
 func resolvePolicyBenchmark(b *testing.B, policy *lang.Policy, externalData *external.Data, expectedNonEmpty bool) *resolve.PolicyResolution {
 	b.Helper()
 	eventLog := event.NewLog(logrus.DebugLevel, "test-resolve")
-	resolver := resolve.NewPolicyResolver(policy, externalData, eventLog)
-	result := resolver.ResolveAllClaims()
+	resolver := resolve.NewPolicyResolver(policy, externalData, eventLog).SetContinueOnError(true)
+	result, resolveErr := resolver.ResolveAllClaims(context.Background())
+	if resolveErr != nil {
+		b.Fatalf("policy should be resolved without errors: %s", resolveErr)
+	}
 	t := &testing.T{}
 
 	claims := policy.GetObjectsByKind(lang.TypeClaim.Kind)
@@ -0,0 +1,5 @@
+// Package chaos implements failure injection for the apply engine. It allows to verify that enforcement behaves
+// correctly under failures (e.g. failed revisions get retried, interrupted revisions get resumed), without
+// hand-editing plugins. Failure injection is only available when explicitly enabled in server config and must never
+// be enabled in production.
+package chaos
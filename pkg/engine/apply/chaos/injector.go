@@ -0,0 +1,239 @@
+package chaos
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/engine/apply/action"
+	"github.com/Aptomi/aptomi/pkg/event"
+	log "github.com/sirupsen/logrus"
+)
+
+// Rule defines which actions should be affected by failure injection and how. Matching actions get counted
+// separately for every rule
+type Rule struct {
+	// Cluster, if set, matches only actions targeting a given cluster (in form namespace/name)
+	Cluster string `yaml:"cluster,omitempty"`
+
+	// Action, if set, is a regular expression, which matches only actions with matching names
+	Action string `yaml:"action,omitempty"`
+
+	// FailEvery makes every N-th matching action fail with a transient error
+	FailEvery int `yaml:"fail-every,omitempty"`
+
+	// Hang makes every matching action hang for a given duration before being applied
+	Hang time.Duration `yaml:"hang,omitempty"`
+
+	// CrashAfter crashes the process after a given number of matching actions have been applied. The last of them
+	// succeeds and the process crashes once its result has been recorded
+	CrashAfter int `yaml:"crash-after,omitempty"`
+}
+
+func (rule *Rule) String() string {
+	return fmt.Sprintf("cluster=%q action=%q fail-every=%d hang=%s crash-after=%d", rule.Cluster, rule.Action, rule.FailEvery, rule.Hang, rule.CrashAfter)
+}
+
+// compiledRule is a rule along with its compiled action regular expression and the number of matched actions
+type compiledRule struct {
+	*Rule
+	action  *regexp.Regexp
+	matched int
+}
+
+// Injector injects failures into actions according to a set of rules. It's safe to use from multiple go routines
+type Injector struct {
+	mutex sync.Mutex
+	rules []*compiledRule
+	crash func(reason string)
+
+	// crashReason is set once one of the rules has scheduled a crash, crashed is set once the crash has happened
+	crashReason string
+	crashed     bool
+}
+
+// NewInjector creates a new Injector without any rules. Crashing exits the process
+func NewInjector() *Injector {
+	return &Injector{
+		crash: func(reason string) {
+			log.Errorf("Failure injection: crashing the process: %s", reason)
+			os.Exit(1)
+		},
+	}
+}
+
+// SetCrashFunc overrides what happens when the process should crash (e.g. to block forever in tests, simulating a
+// dead process)
+func (injector *Injector) SetCrashFunc(crash func(reason string)) *Injector {
+	injector.crash = crash
+	return injector
+}
+
+// SetRules validates and sets failure injection rules, resetting all counters. Empty list of rules disables
+// failure injection
+func (injector *Injector) SetRules(rules []*Rule) error {
+	compiled := make([]*compiledRule, 0, len(rules))
+	for idx, rule := range rules {
+		if rule.FailEvery < 0 || rule.Hang < 0 || rule.CrashAfter < 0 {
+			return fmt.Errorf("rule %d has negative values: %s", idx, rule)
+		}
+		if rule.FailEvery == 0 && rule.Hang == 0 && rule.CrashAfter == 0 {
+			return fmt.Errorf("rule %d doesn't inject any failures, at least one of fail-every, hang or crash-after should be set: %s", idx, rule)
+		}
+		var actionRegex *regexp.Regexp
+		if rule.Action != "" {
+			var err error
+			actionRegex, err = regexp.Compile(rule.Action)
+			if err != nil {
+				return fmt.Errorf("rule %d has invalid action regular expression: %s", idx, err)
+			}
+		}
+		compiled = append(compiled, &compiledRule{Rule: rule, action: actionRegex})
+	}
+
+	injector.mutex.Lock()
+	defer injector.mutex.Unlock()
+
+	injector.rules = compiled
+	injector.crashReason = ""
+	injector.crashed = false
+	if len(rules) > 0 {
+		for _, rule := range rules {
+			log.Warnf("FAILURE INJECTION IS ACTIVE: %s", rule)
+		}
+	} else {
+		log.Warnf("Failure injection rules have been cleared")
+	}
+
+	return nil
+}
+
+// GetRules returns current failure injection rules
+func (injector *Injector) GetRules() []*Rule {
+	injector.mutex.Lock()
+	defer injector.mutex.Unlock()
+
+	result := make([]*Rule, 0, len(injector.rules))
+	for _, rule := range injector.rules {
+		result = append(result, rule.Rule)
+	}
+	return result
+}
+
+// IsActive returns true if there are failure injection rules set
+func (injector *Injector) IsActive() bool {
+	injector.mutex.Lock()
+	defer injector.mutex.Unlock()
+
+	return len(injector.rules) > 0
+}
+
+// Wrap wraps apply function, so failures get injected into actions matching the rules. All injected failures get
+// reported into a given event log
+func (injector *Injector) Wrap(fn action.ApplyFunction, eventLog *event.Log) action.ApplyFunction {
+	return func(act action.Interface) error {
+		inj := injector.match(act)
+
+		// once crash has been scheduled, no more actions get applied
+		if inj.crashScheduled {
+			injector.doCrash()
+			return fmt.Errorf("action '%s' isn't applied, because process has crashed", act.GetName())
+		}
+		if inj.err != nil {
+			eventLog.NewEntry().Warningf("Failure injection: %s", inj.err)
+			return inj.err
+		}
+		if inj.hang > 0 {
+			eventLog.NewEntry().Warningf("Failure injection: action '%s' hangs for %s", act.GetName(), inj.hang)
+			time.Sleep(inj.hang)
+		}
+
+		err := fn(act)
+
+		// result of the action gets returned as is, so it's recorded before the process crashes on the next action
+		// (or once all actions are processed, see CrashIfScheduled)
+		if inj.crashAfter != nil {
+			eventLog.NewEntry().Warningf("Failure injection: process is going to crash after %d matching actions applied (%s)", inj.crashAfter.CrashAfter, inj.crashAfter.Rule)
+		}
+
+		return err
+	}
+}
+
+// CrashIfScheduled crashes the process, if one of the rules has scheduled a crash, but it hasn't happened yet as no
+// actions have been applied since then. It should be called once all actions are processed
+func (injector *Injector) CrashIfScheduled() {
+	injector.mutex.Lock()
+	scheduled := injector.crashReason != "" && !injector.crashed
+	injector.mutex.Unlock()
+
+	if scheduled {
+		injector.doCrash()
+	}
+}
+
+// injection describes failures to be injected into a single action
+type injection struct {
+	hang           time.Duration
+	err            error
+	crashAfter     *compiledRule
+	crashScheduled bool
+}
+
+// match matches action against the rules, updates counters and returns failures to be injected into the action
+func (injector *Injector) match(act action.Interface) *injection {
+	injector.mutex.Lock()
+	defer injector.mutex.Unlock()
+
+	result := &injection{crashScheduled: injector.crashReason != ""}
+	if result.crashScheduled {
+		return result
+	}
+
+	for _, rule := range injector.rules {
+		if !rule.matches(act) {
+			continue
+		}
+		rule.matched++
+		if rule.FailEvery > 0 && rule.matched%rule.FailEvery == 0 {
+			result.err = fmt.Errorf("injected transient error for action '%s' on cluster '%s' (%s)", act.GetName(), act.GetCluster(), rule.Rule)
+		}
+		if rule.Hang > result.hang {
+			result.hang = rule.Hang
+		}
+		if rule.CrashAfter > 0 && rule.matched == rule.CrashAfter && result.err == nil {
+			result.crashAfter = rule
+		}
+	}
+
+	if result.err != nil {
+		result.hang = 0
+		result.crashAfter = nil
+	}
+	if result.crashAfter != nil {
+		injector.crashReason = fmt.Sprintf("%d matching actions applied (%s)", result.crashAfter.CrashAfter, result.crashAfter.Rule)
+	}
+
+	return result
+}
+
+func (injector *Injector) doCrash() {
+	injector.mutex.Lock()
+	crash, reason := injector.crash, injector.crashReason
+	injector.crashed = true
+	injector.mutex.Unlock()
+
+	crash(reason)
+}
+
+func (rule *compiledRule) matches(act action.Interface) bool {
+	if rule.Cluster != "" && rule.Cluster != act.GetCluster() {
+		return false
+	}
+	if rule.action != nil && !rule.action.MatchString(act.GetName()) {
+		return false
+	}
+	return true
+}
@@ -1,13 +1,31 @@
 package apply
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/Aptomi/aptomi/pkg/engine/actual"
 	"github.com/Aptomi/aptomi/pkg/engine/apply/action"
+	"github.com/Aptomi/aptomi/pkg/engine/apply/chaos"
 	"github.com/Aptomi/aptomi/pkg/engine/resolve"
 	"github.com/Aptomi/aptomi/pkg/event"
 	"github.com/Aptomi/aptomi/pkg/external"
 	"github.com/Aptomi/aptomi/pkg/lang"
 	"github.com/Aptomi/aptomi/pkg/plugin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the tracer used by apply engine to report tracing spans
+const TracerName = "aptomi/apply"
+
+const (
+	// SpanApplyAll is the name of the tracing span covering execution of the whole action plan
+	SpanApplyAll = "apply.all-actions"
+	// SpanApplyAction is the name of the tracing span covering execution of a single action, including plugin calls
+	SpanApplyAction = "apply.action"
 )
 
 // EngineApply executes actions to get from an actual state to desired state
@@ -27,6 +45,18 @@ type EngineApply struct {
 
 	// Result/progress updater
 	updater action.ApplyResultUpdater
+
+	// Failure injector (only set when failure injection is enabled)
+	failureInjector *chaos.Injector
+
+	// Completion markers of actions (only set when completed actions should be skipped on re-apply)
+	completionMarkers action.CompletionMarkers
+
+	// Listener called with the outcome of every executed action (only set when per-action results are collected)
+	actionListener ActionListener
+
+	// Context for reporting tracing spans
+	ctx context.Context
 }
 
 // NewEngineApply creates an instance of EngineApply
@@ -42,9 +72,42 @@ func NewEngineApply(desiredPolicy *lang.Policy, desiredState *resolve.PolicyReso
 		actionPlan:         actionPlan,
 		eventLog:           eventLog,
 		updater:            updater,
+		ctx:                context.Background(),
 	}
 }
 
+// SetFailureInjector sets failure injector, which injects failures into actions according to its rules
+func (apply *EngineApply) SetFailureInjector(failureInjector *chaos.Injector) *EngineApply {
+	apply.failureInjector = failureInjector
+	return apply
+}
+
+// SetCompletionMarkers sets completion markers, so actions which have already been completed with the same inputs
+// don't get executed again and all successfully executed actions get recorded as completed
+func (apply *EngineApply) SetCompletionMarkers(completionMarkers action.CompletionMarkers) *EngineApply {
+	apply.completionMarkers = completionMarkers
+	return apply
+}
+
+// ActionListener gets called with every action after it has been executed along with its error (nil if it succeeded or
+// action.ErrPreviouslyCompleted if it hasn't been executed, because it has been completed before). It may be called
+// from different go routines concurrently
+type ActionListener func(act action.Interface, err error)
+
+// SetActionListener sets listener, which gets called with the outcome of every executed action. Actions skipped
+// because of failed dependencies are only counted in the apply result
+func (apply *EngineApply) SetActionListener(listener ActionListener) *EngineApply {
+	apply.actionListener = listener
+	return apply
+}
+
+// SetContext sets context, which tracing spans of executed actions get reported as children of. Once it's cancelled,
+// no new actions are started and all remaining actions fail
+func (apply *EngineApply) SetContext(ctx context.Context) *EngineApply {
+	apply.ctx = ctx
+	return apply
+}
+
 // Apply method executes all actions, actions call plugins to apply changes and roll them out to the cloud.
 // It returns the updated actual state inside PolicyResolution and event log, as well as result/stats about how many actions
 // have been applied successfully vs. failed vs. skipped.
@@ -63,15 +126,94 @@ func (apply *EngineApply) Apply(maxConcurrentActions int) (*resolve.PolicyResolu
 		apply.eventLog,
 	)
 
+	fn := func(act action.Interface) error {
+		return act.Apply(context)
+	}
+	if apply.failureInjector != nil {
+		fn = apply.failureInjector.Wrap(fn, apply.eventLog)
+	}
+	if apply.completionMarkers != nil {
+		fn = apply.skipCompleted(context, fn)
+	}
+
+	fn = interruptible(apply.ctx, fn)
+
+	ctx, span := otel.Tracer(TracerName).Start(apply.ctx, SpanApplyAll, trace.WithAttributes(attribute.Int("actions", int(apply.actionPlan.NumberOfActions()))))
+	defer span.End()
+	fn = traceAction(ctx, fn)
+
 	// Note that the action plan will call function in different go routines by apply
 	result := apply.actionPlan.Apply(action.WrapParallelWithLimit(maxConcurrentActions, func(act action.Interface) error {
-		err := act.Apply(context)
-		if err != nil {
+		err := fn(act)
+		if err != nil && err != action.ErrPreviouslyCompleted {
 			context.EventLog.NewEntry().Errorf("error while applying action '%s': %s", act, err)
 		}
+		if apply.actionListener != nil {
+			apply.actionListener(act, err)
+		}
 		return err
 	}), apply.updater)
 
+	// Crash scheduled by failure injection happens once results of all actions are recorded
+	if apply.failureInjector != nil {
+		apply.failureInjector.CrashIfScheduled()
+	}
+
 	// No errors occurred
 	return apply.actualStateUpdater.GetUpdatedActualState(), result
 }
+
+// skipCompleted wraps apply function, so actions completed with the same inputs are not executed again and actions
+// which succeeded get recorded as completed. Changes of skipped actions still get recorded in actual state
+func (apply *EngineApply) skipCompleted(context *action.Context, fn action.ApplyFunction) action.ApplyFunction {
+	return func(act action.Interface) error {
+		inputHash := action.InputHash(act)
+		if recorder, ok := act.(action.ActualStateRecorder); ok && apply.completionMarkers.IsCompleted(act, inputHash) {
+			apply.eventLog.NewEntry().Infof("Action '%s' has been completed previously with the same inputs, skipping it", act)
+			err := recorder.RecordActualState(context)
+			if err != nil {
+				return fmt.Errorf("unable to record actual state of previously completed action: %s", err)
+			}
+			return action.ErrPreviouslyCompleted
+		}
+
+		err := fn(act)
+		if err == nil {
+			// action has been applied already, so failing to record its completion only means it may run again
+			markErr := apply.completionMarkers.MarkCompleted(act, inputHash)
+			if markErr != nil {
+				apply.eventLog.NewEntry().Warningf("unable to record completion of action '%s': %s", act, markErr)
+			}
+		}
+		return err
+	}
+}
+
+// interruptible wraps apply function, so actions aren't started once a given context is cancelled (e.g. enforcement
+// has been interrupted because leadership is lost). Actions, which have already been started, run to completion
+func interruptible(ctx context.Context, fn action.ApplyFunction) action.ApplyFunction {
+	return func(act action.Interface) error {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("action hasn't been started, as applying actions has been interrupted: %s", err)
+		}
+		return fn(act)
+	}
+}
+
+// traceAction wraps apply function, so every action gets reported as a tracing span. Only action names get recorded,
+// as errors may contain sensitive parameters
+func traceAction(ctx context.Context, fn action.ApplyFunction) action.ApplyFunction {
+	tracer := otel.Tracer(TracerName)
+	return func(act action.Interface) error {
+		_, span := tracer.Start(ctx, SpanApplyAction, trace.WithAttributes(attribute.String("action", act.GetName())))
+		defer span.End()
+
+		err := fn(act)
+		if err == action.ErrPreviouslyCompleted {
+			span.SetAttributes(attribute.Bool("skipped", true))
+		} else if err != nil {
+			span.SetStatus(codes.Error, "action failed")
+		}
+		return err
+	}
+}
@@ -0,0 +1,129 @@
+package apply
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/engine/actual"
+	"github.com/Aptomi/aptomi/pkg/engine/apply/action"
+	"github.com/Aptomi/aptomi/pkg/engine/diff"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplySkipsPreviouslyCompletedActions(t *testing.T) {
+	desired := newTestData(t, makePolicyBuilder())
+	markers := &memoryCompletionMarkers{completed: make(map[string]string)}
+
+	newApplier := func() *EngineApply {
+		// actual state is always empty, as if revision got interrupted before actual state has been saved
+		actualState := newTestData(t, builder.NewPolicyBuilder()).resolution()
+		return NewEngineApply(
+			desired.policy(),
+			desired.resolution(),
+			actual.NewNoOpActionStateUpdater(actualState),
+			desired.external(),
+			mockRegistry(true, false),
+			diff.NewPolicyResolutionDiff(desired.resolution(), actualState).ActionPlan,
+			event.NewLog(logrus.DebugLevel, "test-apply"),
+			action.NewApplyResultUpdaterImpl(),
+		).SetCompletionMarkers(markers)
+	}
+
+	// all actions get executed and recorded as completed
+	applyAndCheck(t, newApplier(), action.ApplyResult{Success: 4})
+	assert.Len(t, markers.completed, 4, "All executed actions should be marked as completed")
+
+	// re-applied actions with the same inputs are not executed again
+	applyAndCheck(t, newApplier(), action.ApplyResult{PreviouslyCompleted: 4})
+
+	// action with changed inputs gets executed again
+	for key := range markers.completed {
+		markers.completed[key] = "changed"
+		break
+	}
+	applyAndCheck(t, newApplier(), action.ApplyResult{Success: 1, PreviouslyCompleted: 3})
+}
+
+func TestApplyRecordsActualStateOfPreviouslyCompletedActions(t *testing.T) {
+	// actual state has all components of the initial policy running
+	newActualState := func() *resolve.PolicyResolution {
+		initial := newTestData(t, makePolicyBuilder())
+		actualState := newTestData(t, builder.NewPolicyBuilder()).resolution()
+		applier := NewEngineApply(
+			initial.policy(),
+			initial.resolution(),
+			actual.NewNoOpActionStateUpdater(actualState),
+			initial.external(),
+			mockRegistry(true, false),
+			diff.NewPolicyResolutionDiff(initial.resolution(), actualState).ActionPlan,
+			event.NewLog(logrus.DebugLevel, "test-apply"),
+			action.NewApplyResultUpdaterImpl(),
+		)
+		return applyAndCheck(t, applier, action.ApplyResult{Success: 4})
+	}
+
+	// desired state attaches a new claim and changes code params of the running component
+	desired := newTestData(t, makePolicyBuilder())
+	service := desired.policy().GetObjectsByKind(lang.TypeService.Kind)[0].(*lang.Service) // nolint: errcheck
+	desired.pBuilder.AddClaim(desired.pBuilder.AddUser(), service)
+	for _, claim := range desired.policy().GetObjectsByKind(lang.TypeClaim.Kind) {
+		claim.(*lang.Claim).Labels["param"] = "value2"
+	}
+
+	markers := &memoryCompletionMarkers{completed: make(map[string]string)}
+	newApplier := func(actualState *resolve.PolicyResolution) *EngineApply {
+		return NewEngineApply(
+			desired.policy(),
+			desired.resolution(),
+			actual.NewNoOpActionStateUpdater(actualState),
+			desired.external(),
+			mockRegistry(true, false),
+			diff.NewPolicyResolutionDiff(desired.resolution(), actualState).ActionPlan,
+			event.NewLog(logrus.DebugLevel, "test-apply"),
+			action.NewApplyResultUpdaterImpl(),
+		).SetCompletionMarkers(markers)
+	}
+
+	// all actions get executed
+	_, result := newApplier(newActualState()).Apply(50)
+	assert.EqualValues(t, 0, result.Failed, "All actions should succeed")
+	assert.True(t, result.Success > 0, "Actions should be executed")
+
+	// apply gets resumed with actual state, which doesn't have any changes made by completed actions
+	actualState, resumed := newApplier(newActualState()).Apply(50)
+	assert.EqualValues(t, 0, resumed.Failed, "Previously completed actions should not fail")
+	assert.EqualValues(t, 0, resumed.Success, "Previously completed actions should not be executed again")
+	assert.Equal(t, result.Success, resumed.PreviouslyCompleted, "All actions should be completed previously")
+
+	// changes of skipped actions should be recorded in actual state
+	assert.EqualValues(t, 0, diff.NewPolicyResolutionDiff(desired.resolution(), actualState).ActionPlan.NumberOfActions(), "Actual state should match desired state")
+	for key, instance := range desired.resolution().ComponentInstanceMap {
+		actualInstance := getInstanceInternal(t, key, actualState)
+		assert.Equal(t, instance.CalculatedCodeParams, actualInstance.CalculatedCodeParams, "Code params should be recorded in actual state: %s", key)
+		assert.Equal(t, instance.ClaimKeys, actualInstance.ClaimKeys, "Attached claims should be recorded in actual state: %s", key)
+	}
+}
+
+// memoryCompletionMarkers keeps completion markers in memory
+type memoryCompletionMarkers struct {
+	completed map[string]string
+	mutex     sync.Mutex
+}
+
+func (markers *memoryCompletionMarkers) IsCompleted(act action.Interface, inputHash string) bool {
+	markers.mutex.Lock()
+	defer markers.mutex.Unlock()
+	return markers.completed[act.GetName()] == inputHash
+}
+
+func (markers *memoryCompletionMarkers) MarkCompleted(act action.Interface, inputHash string) error {
+	markers.mutex.Lock()
+	defer markers.mutex.Unlock()
+	markers.completed[act.GetName()] = inputHash
+	return nil
+}
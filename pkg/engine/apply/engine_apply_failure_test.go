@@ -0,0 +1,122 @@
+package apply
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/engine/actual"
+	"github.com/Aptomi/aptomi/pkg/engine/apply/action"
+	"github.com/Aptomi/aptomi/pkg/engine/apply/chaos"
+	"github.com/Aptomi/aptomi/pkg/engine/diff"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyFailureInjectionRetry(t *testing.T) {
+	actualState := newTestData(t, builder.NewPolicyBuilder()).resolution()
+	desired := newTestData(t, makePolicyBuilder())
+
+	// every action fails with a transient error, so nothing should be applied
+	injector := chaos.NewInjector()
+	err := injector.SetRules([]*chaos.Rule{{FailEvery: 1}})
+	assert.NoError(t, err, "Rules should be valid")
+	result := newFailureTestApply(desired, actualState, injector).applyFailure(t)
+	assert.EqualValues(t, 0, result.Success, "No actions should succeed")
+	assert.True(t, result.Failed > 0, "Actions should fail")
+	assert.Equal(t, 0, len(actualState.ComponentInstanceMap), "Actual state should not be updated")
+
+	// once transient errors are gone, retry should bring actual state in line with desired state
+	err = injector.SetRules(nil)
+	assert.NoError(t, err, "Rules should be cleared")
+	actualState = applyAndCheck(t, newFailureTestApply(desired, actualState, injector).EngineApply, action.ApplyResult{Success: 4, Failed: 0, Skipped: 0})
+	assert.Equal(t, 2, len(actualState.ComponentInstanceMap), "Actual state should match desired state after retry")
+}
+
+func TestApplyFailureInjectionCrash(t *testing.T) {
+	actualState := newTestData(t, builder.NewPolicyBuilder()).resolution()
+	desired := newTestData(t, makePolicyBuilder())
+
+	// process crashes after the first action has been applied, so the rest shouldn't get applied
+	crashed := 0
+	injector := chaos.NewInjector().SetCrashFunc(func(reason string) { crashed++ })
+	err := injector.SetRules([]*chaos.Rule{{CrashAfter: 1}})
+	assert.NoError(t, err, "Rules should be valid")
+	result := newFailureTestApply(desired, actualState, injector).applyFailure(t)
+	assert.True(t, crashed > 0, "Process should crash")
+	assert.EqualValues(t, 1, result.Success, "Action applied before crash should succeed")
+	assert.EqualValues(t, result.Total-1, result.Failed+result.Skipped, "Actions should not be applied after crash")
+
+	// another process (e.g. new leader) picks up from the actual state left by the crashed one
+	result = newFailureTestApply(desired, actualState, nil).applyFailure(t)
+	assert.EqualValues(t, 0, result.Failed, "No actions should fail after resume")
+	assert.True(t, result.Success > 0 && result.Success < 4, "Only actions not applied before crash should be applied after resume")
+	assert.Equal(t, 2, len(actualState.ComponentInstanceMap), "Actual state should match desired state after resume")
+}
+
+func TestApplyFailureInjectionCrashAfterLastAction(t *testing.T) {
+	actualState := newTestData(t, builder.NewPolicyBuilder()).resolution()
+	desired := newTestData(t, makePolicyBuilder())
+
+	// process crashes once all actions are applied and their results are recorded
+	crashed := 0
+	injector := chaos.NewInjector().SetCrashFunc(func(reason string) { crashed++ })
+	err := injector.SetRules([]*chaos.Rule{{CrashAfter: 4}})
+	assert.NoError(t, err, "Rules should be valid")
+	actualState = applyAndCheck(t, newFailureTestApply(desired, actualState, injector).EngineApply, action.ApplyResult{Success: 4, Failed: 0, Skipped: 0})
+	assert.Equal(t, 1, crashed, "Process should crash once")
+	assert.Equal(t, 2, len(actualState.ComponentInstanceMap), "All actions should be applied before crash")
+}
+
+func TestApplyFailureInjectionHang(t *testing.T) {
+	actualState := newTestData(t, builder.NewPolicyBuilder()).resolution()
+	desired := newTestData(t, makePolicyBuilder())
+
+	hang := 50 * time.Millisecond
+	injector := chaos.NewInjector()
+	err := injector.SetRules([]*chaos.Rule{{Action: "component-create", Hang: hang}})
+	assert.NoError(t, err, "Rules should be valid")
+
+	start := time.Now()
+	actualState = applyAndCheck(t, newFailureTestApply(desired, actualState, injector).EngineApply, action.ApplyResult{Success: 4, Failed: 0, Skipped: 0})
+	assert.True(t, time.Since(start) >= hang, "Hanging actions should delay apply")
+	assert.Equal(t, 2, len(actualState.ComponentInstanceMap), "Hanging actions should still be applied")
+}
+
+func TestFailureInjectionRulesValidation(t *testing.T) {
+	injector := chaos.NewInjector()
+	assert.Error(t, injector.SetRules([]*chaos.Rule{{Cluster: "cluster"}}), "Rule without failures should be rejected")
+	assert.Error(t, injector.SetRules([]*chaos.Rule{{FailEvery: -1}}), "Rule with negative values should be rejected")
+	assert.Error(t, injector.SetRules([]*chaos.Rule{{Action: "[", FailEvery: 1}}), "Rule with invalid regular expression should be rejected")
+	assert.False(t, injector.IsActive(), "Invalid rules should not be set")
+}
+
+type failureTestApply struct {
+	*EngineApply
+}
+
+func newFailureTestApply(desired *testData, actualState *resolve.PolicyResolution, injector *chaos.Injector) *failureTestApply {
+	applier := NewEngineApply(
+		desired.policy(),
+		desired.resolution(),
+		actual.NewNoOpActionStateUpdater(actualState),
+		desired.external(),
+		mockRegistry(true, false),
+		diff.NewPolicyResolutionDiff(desired.resolution(), actualState).ActionPlan,
+		event.NewLog(logrus.DebugLevel, "test-apply"),
+		action.NewApplyResultUpdaterImpl(),
+	)
+	if injector != nil {
+		applier.SetFailureInjector(injector)
+	}
+	return &failureTestApply{applier}
+}
+
+func (apply *failureTestApply) applyFailure(t *testing.T) *action.ApplyResult {
+	t.Helper()
+	_, result := apply.Apply(1)
+	assert.Equal(t, result.Processed(), result.Total, "All actions should be processed")
+	return result
+}
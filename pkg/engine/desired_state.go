@@ -2,6 +2,7 @@ package engine
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/Aptomi/aptomi/pkg/engine/resolve"
 	"github.com/Aptomi/aptomi/pkg/runtime"
@@ -15,7 +16,8 @@ var TypeDesiredState = &runtime.TypeInfo{
 	Constructor: func() runtime.Object { return &DesiredState{} },
 }
 
-// DesiredState represents snapshot of the state to be achieved by specific revision
+// DesiredState represents snapshot of the state to be achieved by specific revision. It's only read for revisions
+// created before component instances were saved separately with DesiredStateIndex
 type DesiredState struct {
 	runtime.TypeKind `yaml:",inline"`
 
@@ -46,3 +48,93 @@ func (ds *DesiredState) GetNamespace() string {
 func GetDesiredStateName(revisionGen runtime.Generation) string {
 	return fmt.Sprintf("revision-%s-desired-state", revisionGen)
 }
+
+// TypeDesiredStateIndex is an informational data structure with Kind and Constructor for DesiredStateIndex
+var TypeDesiredStateIndex = &runtime.TypeInfo{
+	Kind:        "desired-state-index",
+	Storable:    true,
+	Versioned:   false,
+	Constructor: func() runtime.Object { return &DesiredStateIndex{} },
+}
+
+// DesiredStateIndex is a lightweight index of component instances in desired state of specific revision. Every
+// component instance is stored as a separate DesiredStateInstance, so a single instance can be retrieved without
+// loading the whole desired state. Index gets saved after all instances, so its presence means all instances of the
+// revision have been saved
+type DesiredStateIndex struct {
+	runtime.TypeKind `yaml:",inline"`
+
+	RevisionGen runtime.Generation
+	Keys        []string
+}
+
+// NewDesiredStateIndex creates new DesiredStateIndex instance from revision and policy resolution
+func NewDesiredStateIndex(revision *Revision, resolution *resolve.PolicyResolution) *DesiredStateIndex {
+	keys := make([]string, 0, len(resolution.ComponentInstanceMap))
+	for key := range resolution.ComponentInstanceMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return &DesiredStateIndex{
+		TypeKind:    TypeDesiredStateIndex.GetTypeKind(),
+		RevisionGen: revision.GetGeneration(),
+		Keys:        keys,
+	}
+}
+
+// GetName returns name of the DesiredStateIndex
+func (index *DesiredStateIndex) GetName() string {
+	return GetDesiredStateIndexName(index.RevisionGen)
+}
+
+// GetNamespace returns namespace of the DesiredStateIndex
+func (index *DesiredStateIndex) GetNamespace() string {
+	return runtime.SystemNS
+}
+
+// GetDesiredStateIndexName returns name of the DesiredStateIndex for specific Revision generation
+func GetDesiredStateIndexName(revisionGen runtime.Generation) string {
+	return fmt.Sprintf("revision-%s-desired-state-index", revisionGen)
+}
+
+// TypeDesiredStateInstance is an informational data structure with Kind and Constructor for DesiredStateInstance
+var TypeDesiredStateInstance = &runtime.TypeInfo{
+	Kind:        "desired-state-instance",
+	Storable:    true,
+	Versioned:   false,
+	Constructor: func() runtime.Object { return &DesiredStateInstance{} },
+}
+
+// DesiredStateInstance is a single component instance from desired state of specific revision
+type DesiredStateInstance struct {
+	runtime.TypeKind `yaml:",inline"`
+
+	RevisionGen runtime.Generation
+	Instance    *resolve.ComponentInstance
+}
+
+// NewDesiredStateInstance creates new DesiredStateInstance from revision and component instance
+func NewDesiredStateInstance(revision *Revision, instance *resolve.ComponentInstance) *DesiredStateInstance {
+	return &DesiredStateInstance{
+		TypeKind:    TypeDesiredStateInstance.GetTypeKind(),
+		RevisionGen: revision.GetGeneration(),
+		Instance:    instance,
+	}
+}
+
+// GetName returns name of the DesiredStateInstance
+func (dsInstance *DesiredStateInstance) GetName() string {
+	return GetDesiredStateInstanceName(dsInstance.RevisionGen, dsInstance.Instance.GetKey())
+}
+
+// GetNamespace returns namespace of the DesiredStateInstance
+func (dsInstance *DesiredStateInstance) GetNamespace() string {
+	return runtime.SystemNS
+}
+
+// GetDesiredStateInstanceName returns name of the DesiredStateInstance for specific Revision generation and
+// component instance key
+func GetDesiredStateInstanceName(revisionGen runtime.Generation, key string) string {
+	return fmt.Sprintf("revision-%s-desired-state-instance-%s", revisionGen, key)
+}
@@ -0,0 +1,49 @@
+package diff
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/engine/enginetest"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/sirupsen/logrus"
+)
+
+func BenchmarkNewPolicyResolutionDiffSmall(b *testing.B) {
+	benchmarkNewPolicyResolutionDiff(b, enginetest.SyntheticPolicySmall)
+}
+
+func BenchmarkNewPolicyResolutionDiffLarge(b *testing.B) {
+	benchmarkNewPolicyResolutionDiff(b, enginetest.SyntheticPolicyLarge)
+}
+
+// benchmarkNewPolicyResolutionDiff calculates diff between resolutions of two synthetic policies with the same seed,
+// where the next one has 10% more claims on top of the previous one
+func benchmarkNewPolicyResolutionDiff(b *testing.B, params enginetest.SyntheticPolicyParams) {
+	next := resolveSyntheticPolicy(b, params)
+	params.Claims = params.Claims * 9 / 10
+	prev := resolveSyntheticPolicy(b, params)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		diff := NewPolicyResolutionDiff(next, prev)
+		if diff.ActionPlan.NumberOfActions() == 0 {
+			b.Fatal("diff between synthetic policies should not be empty")
+		}
+	}
+}
+
+func resolveSyntheticPolicy(b *testing.B, params enginetest.SyntheticPolicyParams) *resolve.PolicyResolution {
+	b.Helper()
+	synthetic := enginetest.NewSyntheticPolicy(params)
+	resolution, resolveErr := resolve.NewPolicyResolver(synthetic.Policy, synthetic.External, event.NewLog(logrus.WarnLevel, "bench-resolve")).SetContinueOnError(true).ResolveAllClaims(context.Background())
+	if resolveErr != nil {
+		b.Fatalf("policy should be resolved without errors: %s", resolveErr)
+	}
+	if err := resolution.Validate(synthetic.Policy); err != nil {
+		b.Fatalf("synthetic policy should be resolved without errors: %s", err)
+	}
+	return resolution
+}
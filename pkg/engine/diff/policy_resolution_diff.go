@@ -46,9 +46,21 @@ func (diff *PolicyResolutionDiff) compareAndProduceActions() {
 		allCompInstances[keyNext] = true
 	}
 
+	// Calculate which component instances depend on every component instance in desired state
+	dependentsNext := make(map[string]map[string]bool)
+	for key, instance := range diff.Next.ComponentInstanceMap {
+		for keyOut := range instance.EdgesOut {
+			if dependentsNext[keyOut] == nil {
+				dependentsNext[keyOut] = make(map[string]bool)
+			}
+			dependentsNext[keyOut][key] = true
+		}
+	}
+
 	// Build a flat list of actions for every component instance
+	deleted := make(map[string]bool)
 	for key := range allCompInstances {
-		diff.buildActions(key)
+		deleted[key] = diff.buildActions(key, dependentsNext[key])
 	}
 
 	// Generate dependencies between actions
@@ -64,10 +76,23 @@ func (diff *PolicyResolutionDiff) compareAndProduceActions() {
 			diff.ActionPlan.GetActionGraphNode(key).AddBefore(diff.ActionPlan.GetActionGraphNode(keyOut))
 		}
 	}
+
+	// Generate dependencies between delete actions. Instances get deleted in the reverse order, i.e. component
+	// instance can only be deleted after all deleted instances which depend on it are gone
+	for key := range deleted {
+		if !deleted[key] {
+			continue
+		}
+		for keyOutPrev := range diff.Prev.ComponentInstanceMap[key].EdgesOut {
+			if deleted[keyOutPrev] {
+				diff.ActionPlan.GetActionGraphNode(keyOutPrev).AddBefore(diff.ActionPlan.GetActionGraphNode(key))
+			}
+		}
+	}
 }
 
-// Traverse a graph for a given component instance
-func (diff *PolicyResolutionDiff) buildActions(key string) { // nolint: gocyclo
+// Traverse a graph for a given component instance. Returns true if component instance is going to be deleted
+func (diff *PolicyResolutionDiff) buildActions(key string, dependentsNext map[string]bool) bool { // nolint: gocyclo
 	// Get action graph node for a given component key
 	node := diff.ActionPlan.GetActionGraphNode(key)
 
@@ -96,10 +121,11 @@ func (diff *PolicyResolutionDiff) buildActions(key string) { // nolint: gocyclo
 		}
 	}
 
-	// See if a component needs to be destructed
-	if len(claimKeysPrev) > 0 && len(claimKeysNext) <= 0 {
+	// See if a component needs to be destructed. It only happens when its set of consumers becomes empty, i.e. there
+	// are no claims and no other component instances in desired state which are using it
+	if len(claimKeysPrev) > 0 && len(claimKeysNext) <= 0 && len(dependentsNext) <= 0 {
 		node.AddAction(component.NewDeleteAction(key, prevInstance.CalculatedCodeParams), diff.Prev, true)
-		return // exit right away
+		return true // exit right away
 	}
 
 	/*
@@ -135,4 +161,6 @@ func (diff *PolicyResolutionDiff) buildActions(key string) { // nolint: gocyclo
 			node.AddAction(component.NewAttachClaimAction(key, claimKey, depth), diff.Prev, true)
 		}
 	}
+
+	return false
 }
@@ -2,6 +2,7 @@
 package diff
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -102,6 +103,105 @@ func TestDiffComponentWithBundleSharing(t *testing.T) {
 	verifyDiff(t, diff, 7, 0, 0, 9, 0)
 }
 
+func TestDiffComponentDeleteWithSharingChange(t *testing.T) {
+	b := makePolicyBuilderWithBundleSharing()
+	resolvedPrev := resolvePolicy(t, b)
+
+	// change sharing, so that all claims share the first bundle as well. and delete one of the claims
+	for _, obj := range b.Policy().GetObjectsByKind(lang.TypeService.Kind) {
+		obj.(*lang.Service).Contexts[0].Allocation.Keys = nil
+	}
+	claimDeleted := b.Policy().GetObjectsByKind(lang.TypeClaim.Kind)[0]
+	b.Policy().RemoveObject(claimDeleted)
+	resolvedNext := resolvePolicy(t, b)
+
+	// per-claim instances of the first bundle should be deleted (root + service component) and shared instance
+	// created instead. shared instance of the second bundle should only have the deleted claim detached
+	diff := NewPolicyResolutionDiff(resolvedNext, resolvedPrev)
+	verifyDiff(t, diff, 2, 6, 0, 4, 7)
+
+	// instance of the second bundle, which is still used by the remaining claims, should never be deleted
+	for key, instance := range resolvedPrev.ComponentInstanceMap {
+		if len(instance.ClaimKeys) < 3 {
+			continue
+		}
+		consumers := resolvedNext.GetConsumers(key)
+		assert.True(t, consumers.Found, "Shared instance should be present in desired state")
+		assert.Len(t, consumers.Claims, 2, "Shared instance should be used by the remaining claims")
+		assert.Len(t, consumers.Instances, 1, "Shared instance should be used by a single instance of the first bundle")
+		for _, act := range diff.ActionPlan.GetActionGraphNode(key).Actions {
+			if _, isDelete := act.(*component.DeleteAction); isDelete {
+				t.Fatalf("Shared instance %s should not be deleted", key)
+			}
+		}
+	}
+}
+
+func TestDiffComponentDeleteOrder(t *testing.T) {
+	b := makePolicyBuilderWithBundleSharing()
+	resolvedPrev := resolvePolicy(t, b)
+	resolvedEmpty := resolvePolicy(t, builder.NewPolicyBuilder())
+
+	// record the order in which instances get deleted
+	deleted := make(map[string]int)
+	fn := func(act action.Interface) error {
+		if deleteAction, isDelete := act.(*component.DeleteAction); isDelete {
+			deleted[deleteAction.ComponentKey] = len(deleted)
+		}
+		return nil
+	}
+
+	diff := NewPolicyResolutionDiff(resolvedEmpty, resolvedPrev)
+	_ = diff.ActionPlan.Apply(action.WrapSequential(fn), action.NewApplyResultUpdaterImpl())
+
+	// every instance should be deleted after all instances which depend on it
+	assert.Len(t, deleted, len(resolvedPrev.ComponentInstanceMap), "All instances should be deleted")
+	for key, instance := range resolvedPrev.ComponentInstanceMap {
+		for keyOut := range instance.EdgesOut {
+			assert.True(t, deleted[key] < deleted[keyOut], "Instance %s should be deleted before %s, which it depends on", key, keyOut)
+		}
+	}
+}
+
+func TestDiffFilterByCluster(t *testing.T) {
+	b := builder.NewPolicyBuilder()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(util.NestedParameterMap{"param": "value"}, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+
+	// add two clusters, every claim goes to its own cluster
+	clusters := []*lang.Cluster{}
+	for _, name := range []string{"first", "second"} {
+		clusterObj := b.AddCluster()
+		clusters = append(clusters, clusterObj)
+		b.AddRule(&lang.Criteria{RequireAll: []string{fmt.Sprintf("cluster == '%s'", name)}}, b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, clusterObj.Name)))
+		claim := b.AddClaim(b.AddUser(), service)
+		claim.Labels["cluster"] = name
+	}
+	resolvedNext := resolvePolicy(t, b)
+	resolvedEmpty := resolvePolicy(t, builder.NewPolicyBuilder())
+
+	// full plan should contain actions for both clusters
+	diff := NewPolicyResolutionDiff(resolvedNext, resolvedEmpty)
+	verifyDiff(t, diff, 4, 0, 0, 4, 0)
+
+	// filtered plan should only contain actions for the specified cluster
+	for _, clusterObj := range clusters {
+		cluster := clusterObj.Namespace + "/" + clusterObj.Name
+		plan := diff.ActionPlan.FilterByCluster(cluster)
+		assert.EqualValues(t, 4, plan.NumberOfActions(), "Filtered plan should contain actions for cluster %s", cluster)
+		fn := func(act action.Interface) error {
+			assert.Equal(t, cluster, act.GetCluster(), "Filtered plan should only contain actions for cluster %s", cluster)
+			return nil
+		}
+		_ = plan.Apply(action.WrapSequential(fn), action.NewApplyResultUpdaterImpl())
+	}
+
+	// filtering by unknown cluster or cluster with the same name from another namespace should result in an empty plan
+	assert.EqualValues(t, 0, diff.ActionPlan.FilterByCluster("unknown").NumberOfActions(), "Filtered plan for unknown cluster should be empty")
+	assert.EqualValues(t, 0, diff.ActionPlan.FilterByCluster("other/"+clusters[0].Name).NumberOfActions(), "Filtered plan for cluster from another namespace should be empty")
+}
+
 /*
 	Helpers
 */
@@ -154,8 +254,11 @@ func makePolicyBuilderWithBundleSharing() *builder.PolicyBuilder {
 func resolvePolicy(t *testing.T, builder *builder.PolicyBuilder) *resolve.PolicyResolution {
 	t.Helper()
 	eventLog := event.NewLog(logrus.DebugLevel, "test-resolve")
-	resolver := resolve.NewPolicyResolver(builder.Policy(), builder.External(), eventLog)
-	result := resolver.ResolveAllClaims()
+	resolver := resolve.NewPolicyResolver(builder.Policy(), builder.External(), eventLog).SetContinueOnError(true)
+	result, resolveErr := resolver.ResolveAllClaims(context.Background())
+	if !assert.NoError(t, resolveErr, "Policy should be resolved without errors") {
+		t.FailNow()
+	}
 
 	claims := builder.Policy().GetObjectsByKind(lang.TypeClaim.Kind)
 	for _, claim := range claims {
@@ -0,0 +1,4 @@
+// Package enforce implements desired state enforcement. It picks policy revisions which need to be processed,
+// calculates the difference between desired and actual state, and applies the corresponding actions.
+// After registry has been restored from a backup, enforcement is blocked until actual state gets reconciled.
+package enforce
@@ -0,0 +1,83 @@
+package enforce
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/apply/action"
+	"github.com/Aptomi/aptomi/pkg/event"
+	log "github.com/sirupsen/logrus"
+)
+
+// actionRecorder collects results of all executed actions. It's safe to use from different go routines
+type actionRecorder struct {
+	mutex   sync.Mutex
+	actions []*engine.EnforcementAction
+}
+
+// record is an apply.ActionListener, which records the outcome of a given action
+func (recorder *actionRecorder) record(act action.Interface, err error) {
+	result := &engine.EnforcementAction{ActionKey: act.GetName(), Result: engine.EnforcementActionSuccess}
+	if err == action.ErrPreviouslyCompleted {
+		result.Result = engine.EnforcementActionPreviouslyCompleted
+	} else if err != nil {
+		result.Result = engine.EnforcementActionFailed
+		result.Error = err.Error()
+	}
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.actions = append(recorder.actions, result)
+}
+
+// startEnforcements marks enforcements, which are tracking a given revision or the revisions superseded by it, as
+// running. Enforcements, which have been interrupted before, are started again
+func (enforcer *DesiredStateEnforcer) startEnforcements(revision *engine.Revision) ([]*engine.Enforcement, error) {
+	unfinished, err := enforcer.registry.GetUnfinishedEnforcements()
+	if err != nil {
+		return nil, fmt.Errorf("unable to load unfinished enforcements: %s", err)
+	}
+
+	result := []*engine.Enforcement{}
+	for _, enforcement := range unfinished {
+		if enforcement.RevisionGen > revision.GetGeneration() {
+			continue
+		}
+
+		enforcement.Status = engine.EnforcementStatusRunning
+		enforcement.StartedAt = enforcer.clock.Now()
+		enforcement.AppliedRevisionGen = revision.GetGeneration()
+		updateErr := enforcer.registry.UpdateEnforcement(enforcement)
+		if updateErr != nil {
+			return nil, fmt.Errorf("unable to update enforcement %d: %s", enforcement.GetGeneration(), updateErr)
+		}
+		log.Infof("(enforce-%d) Enforcement %d is running for revision %d", enforcer.idx, enforcement.GetGeneration(), revision.GetGeneration())
+		result = append(result, enforcement)
+	}
+
+	return result, nil
+}
+
+// finishEnforcements saves the outcome of applying a given revision into enforcements, which were tracking it
+func (enforcer *DesiredStateEnforcer) finishEnforcements(enforcements []*engine.Enforcement, revision *engine.Revision, recorder *actionRecorder, applyLog *event.Log) error {
+	status := engine.EnforcementStatusSucceeded
+	if revision.Result.Failed > 0 {
+		status = engine.EnforcementStatusFailed
+	}
+
+	for _, enforcement := range enforcements {
+		enforcement.Status = status
+		enforcement.FinishedAt = enforcer.clock.Now()
+		enforcement.Result = revision.Result
+		enforcement.Actions = recorder.actions
+		enforcement.Log = applyLog.AsAPIEvents()
+		err := enforcer.registry.UpdateEnforcement(enforcement)
+		if err != nil {
+			return fmt.Errorf("unable to update enforcement %d: %s", enforcement.GetGeneration(), err)
+		}
+		log.Infof("(enforce-%d) Enforcement %d %s", enforcer.idx, enforcement.GetGeneration(), status)
+	}
+
+	return nil
+}
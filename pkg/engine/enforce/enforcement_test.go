@@ -0,0 +1,93 @@
+package enforce
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/apply/chaos"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnforcementSucceeded(t *testing.T) {
+	test := newEnforcerTest(t)
+	assert.Equal(t, engine.EnforcementStatusPending, test.enforcement(t).Status, "New enforcement should be pending")
+
+	// actions hang for a while, so enforcement could be seen running
+	injector := chaos.NewInjector()
+	assert.NoError(t, injector.SetRules([]*chaos.Rule{{Hang: 100 * time.Millisecond}}), "Rules should be valid")
+	startedAt := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &testClock{now: startedAt}
+	finished := make(chan bool)
+	go func() {
+		_, _ = test.newEnforcer(injector).SetClock(clock).Enforce(context.Background())
+		close(finished)
+	}()
+
+	enforcement := test.enforcement(t)
+	for deadline := time.Now().Add(10 * time.Second); enforcement.Status == engine.EnforcementStatusPending && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		enforcement = test.enforcement(t)
+	}
+	assert.Equal(t, engine.EnforcementStatusRunning, enforcement.Status, "Enforcement should be running while actions are applied")
+	assert.True(t, startedAt.Equal(enforcement.StartedAt), "Enforcement should be started at the time provided by clock")
+	assert.Equal(t, test.revisionGen, enforcement.AppliedRevisionGen, "Enforcement should be running for the revision")
+
+	finishedAt := startedAt.Add(time.Minute)
+	clock.set(finishedAt)
+	<-finished
+
+	enforcement = test.enforcement(t)
+	assert.Equal(t, engine.EnforcementStatusSucceeded, enforcement.Status, "Enforcement should succeed")
+	assert.True(t, startedAt.Equal(enforcement.StartedAt), "Enforcement should keep the time it's started at")
+	assert.True(t, finishedAt.Equal(enforcement.FinishedAt), "Enforcement should be finished at the time provided by clock")
+	assert.Zero(t, enforcement.Result.Failed, "No actions should fail")
+	if assert.NotEmpty(t, enforcement.Actions, "Results of actions should be recorded") {
+		for _, act := range enforcement.Actions {
+			assert.Equal(t, engine.EnforcementActionSuccess, act.Result, "Action %s should succeed", act.ActionKey)
+		}
+	}
+	assert.NotEmpty(t, enforcement.Log, "Apply log should be recorded")
+}
+
+func TestEnforcementFailed(t *testing.T) {
+	test := newEnforcerTest(t)
+
+	injector := chaos.NewInjector()
+	assert.NoError(t, injector.SetRules([]*chaos.Rule{{FailEvery: 1}}), "Rules should be valid")
+	finishedAt := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err := test.newEnforcer(injector).SetClock(&testClock{now: finishedAt}).Enforce(context.Background())
+	assert.NoError(t, err, "Enforcement should not fail")
+
+	enforcement := test.enforcement(t)
+	assert.Equal(t, engine.EnforcementStatusFailed, enforcement.Status, "Enforcement should fail")
+	assert.True(t, finishedAt.Equal(enforcement.FinishedAt), "Enforcement should be finished at the time provided by clock")
+	assert.True(t, enforcement.Result.Failed > 0, "Actions should fail")
+	failed := 0
+	for _, act := range enforcement.Actions {
+		if act.Result == engine.EnforcementActionFailed {
+			assert.Contains(t, act.Error, "injected transient error", "Error of action %s should be recorded", act.ActionKey)
+			failed++
+		}
+	}
+	assert.EqualValues(t, enforcement.Result.Failed, failed, "All failed actions should be recorded")
+}
+
+// testClock is a Clock, which returns the time it's set to
+type testClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (clock *testClock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	return clock.now
+}
+
+func (clock *testClock) set(now time.Time) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	clock.now = now
+}
@@ -0,0 +1,386 @@
+package enforce
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/apply"
+	"github.com/Aptomi/aptomi/pkg/engine/apply/action"
+	"github.com/Aptomi/aptomi/pkg/engine/apply/chaos"
+	"github.com/Aptomi/aptomi/pkg/engine/diff"
+	"github.com/Aptomi/aptomi/pkg/engine/reconcile"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/external"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the tracer used by desired state enforcer to report tracing spans
+const TracerName = "aptomi/enforce"
+
+// SpanEnforceRevision is the name of the tracing span covering enforcement of a single revision
+const SpanEnforceRevision = "enforce.revision"
+
+// DesiredStateEnforcer takes unprocessed (or failed) revisions from the registry and brings actual state in line
+// with desired state by applying actions via plugins
+type DesiredStateEnforcer struct {
+	registry              registry.Interface
+	externalData          *external.Data
+	pluginRegistryFactory plugin.RegistryFactory
+	maxConcurrentActions  int
+	eventHooks            []log.Hook
+	failureInjector       *chaos.Injector
+	clock                 Clock
+	idx                   uint
+}
+
+// Clock provides current time to the enforcer
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is a Clock which returns the current local time
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewDesiredStateEnforcer creates a new DesiredStateEnforcer. Event hooks get attached to the apply log of every
+// processed revision
+func NewDesiredStateEnforcer(registry registry.Interface, externalData *external.Data, pluginRegistryFactory plugin.RegistryFactory, maxConcurrentActions int, eventHooks ...log.Hook) *DesiredStateEnforcer {
+	return &DesiredStateEnforcer{
+		registry:              registry,
+		externalData:          externalData,
+		pluginRegistryFactory: pluginRegistryFactory,
+		maxConcurrentActions:  maxConcurrentActions,
+		eventHooks:            eventHooks,
+		clock:                 systemClock{},
+	}
+}
+
+// SetClock sets clock, which provides the time enforcements get started and finished at
+func (enforcer *DesiredStateEnforcer) SetClock(clock Clock) *DesiredStateEnforcer {
+	enforcer.clock = clock
+	return enforcer
+}
+
+// SetFailureInjector sets failure injector, which injects failures into actions while applying revisions. It must
+// only be used for testing how enforcement behaves under failures
+func (enforcer *DesiredStateEnforcer) SetFailureInjector(failureInjector *chaos.Injector) *DesiredStateEnforcer {
+	enforcer.failureInjector = failureInjector
+	return enforcer
+}
+
+func (enforcer *DesiredStateEnforcer) getRevisionForProcessing() (*engine.Revision, error) {
+	// we are processing revision sequentially, so let's get unprocessed revisions from the database
+	revisions, err := enforcer.registry.GetUnprocessedRevisions()
+	if err != nil {
+		return nil, fmt.Errorf("unable to load unprocessed revisions: %s", err)
+	}
+
+	// if there is an unprocessed revision, return it
+	if len(revisions) > 0 {
+		return enforcer.skipSupersededRevisions(revisions)
+	}
+
+	// if there are no unprocessed revisions, let's get the last one and see if it was successful or not
+	_, policyGen, err := enforcer.registry.GetPolicy(runtime.LastOrEmptyGen)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load latest policy: %s", err)
+	}
+	lastRevision, err := enforcer.registry.GetLastRevisionForPolicy(policyGen)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load latest revision: %s", err)
+	}
+
+	// now, given that we retrieved the last revision, when do we need to retry it? in one of two cases:
+	// - it's either in error status (something really bad happened)
+	// - it completed, but some actions failed and they need to be retried
+	// revisions, which failed to resolve, don't have desired state, so there is nothing to retry
+	if lastRevision != nil && len(lastRevision.ResolutionError) <= 0 && (lastRevision.Status == engine.RevisionStatusError || (lastRevision.Status == engine.RevisionStatusCompleted && lastRevision.Result.Failed > 0)) {
+		log.Infof("(enforce-%d) Found last revision %d which needs to be retried", enforcer.idx, lastRevision.GetGeneration())
+		return lastRevision, nil
+	}
+
+	// nothing to process
+	return nil, nil
+}
+
+// skipSupersededRevisions picks revision for processing out of unprocessed revisions in chronological order.
+// Revisions are processed in order, so nothing can be processed while the first one is still being resolved. Waiting
+// revisions followed by newer resolved ones get superseded, as desired state of the newest one already includes
+// all of their changes
+func (enforcer *DesiredStateEnforcer) skipSupersededRevisions(revisions []*engine.Revision) (*engine.Revision, error) {
+	revision := revisions[0]
+	if revision.Status == engine.RevisionStatusResolving {
+		log.Infof("(enforce-%d) Waiting for revision %d to be resolved", enforcer.idx, revision.GetGeneration())
+		return nil, nil
+	}
+
+	// revision which has been interrupted should be resumed as is
+	if revision.Status == engine.RevisionStatusWaiting {
+		for _, next := range revisions[1:] {
+			if next.Status != engine.RevisionStatusWaiting {
+				break
+			}
+
+			// make sure newer revision applies everything, if superseded one was supposed to
+			next.RecalculateAll = next.RecalculateAll || revision.RecalculateAll
+			revision.Status = engine.RevisionStatusSuperseded
+			err := enforcer.registry.UpdateRevision(revision)
+			if err != nil {
+				return nil, fmt.Errorf("unable to mark revision %d as superseded: %s", revision.GetGeneration(), err)
+			}
+			log.Infof("(enforce-%d) Revision %d superseded by revision %d", enforcer.idx, revision.GetGeneration(), next.GetGeneration())
+			revision = next
+		}
+	}
+
+	log.Infof("(enforce-%d) Found unprocessed revision %d", enforcer.idx, revision.GetGeneration())
+	return revision, nil
+}
+
+// Enforce processes a single revision (if there is one to process). It returns true if some of the actions were
+// successfully applied, meaning that enforcement should be triggered again right away and actual state has changed.
+// Once a given context is cancelled (e.g. leadership is lost), no new actions are started and all remaining actions
+// fail, so the revision gets retried by whoever enforces desired state next
+func (enforcer *DesiredStateEnforcer) Enforce(ctx context.Context) (applied bool, errResult error) {
+	enforcer.idx++
+
+	defer func() {
+		if err := recover(); err != nil {
+			log.Errorf("panic while enforcing desired state: %s", err)
+			log.Errorf(string(debug.Stack()))
+			errResult = fmt.Errorf("panic while enforcing desired state: %s", err)
+		}
+	}()
+
+	// after registry has been restored from a backup, actual state has to be reconciled before any enforcement
+	blocked, err := enforcer.reconcile()
+	if err != nil {
+		return false, fmt.Errorf("error while reconciling actual state: %s", err)
+	}
+	if blocked {
+		return false, nil
+	}
+
+	// get the revision for processing
+	revision, err := enforcer.getRevisionForProcessing()
+	if err != nil {
+		return false, fmt.Errorf("can't pick revision for processing: %s", err)
+	}
+	if revision == nil {
+		return false, nil
+	}
+
+	// enforcement gets traced as a part of the trace of the request, which created the revision
+	ctx, span := otel.Tracer(TracerName).Start(getRevisionTraceContext(ctx, revision), SpanEnforceRevision, trace.WithAttributes(attribute.Int64("revision", int64(revision.GetGeneration()))))
+	defer span.End()
+
+	// reset revision status and result
+	revision.Status = engine.RevisionStatusWaiting
+	revision.Result = &action.ApplyResult{}
+	revErr := enforcer.registry.UpdateRevision(revision)
+	if revErr != nil {
+		return false, fmt.Errorf("unable to update revision: %s", revErr)
+	}
+
+	// policy changes waiting for the revision get tracked as running
+	enforcements, err := enforcer.startEnforcements(revision)
+	if err != nil {
+		return false, err
+	}
+
+	// load the corresponding policy
+	policy, policyGen, err := enforcer.registry.GetPolicy(revision.PolicyGen)
+	if err != nil {
+		return false, fmt.Errorf("error while getting policy: %s", err)
+	}
+
+	// load desired state
+	desiredState, err := enforcer.registry.GetDesiredState(revision)
+	if err != nil {
+		return false, fmt.Errorf("can't load desired state from revision: %s", err)
+	}
+
+	// load the actual state
+	actualState, err := enforcer.registry.GetActualState()
+	if err != nil {
+		return false, fmt.Errorf("error while getting actual state: %s", err)
+	}
+
+	// compare desired against actual
+	var stateDiff *diff.PolicyResolutionDiff
+	if revision.RecalculateAll {
+		stateDiff = diff.NewPolicyResolutionDiff(desiredState, resolve.NewPolicyResolution())
+	} else {
+		stateDiff = diff.NewPolicyResolutionDiff(desiredState, actualState)
+	}
+
+	// policy changes while no actions needed to achieve desired state
+	actionCnt := stateDiff.ActionPlan.NumberOfActions()
+	if actionCnt > 0 {
+		log.Infof("(enforce-%d) Revision %d, policy gen %d: %d actions need to be applied", enforcer.idx, revision.GetGeneration(), policyGen, actionCnt)
+	} else {
+		log.Infof("(enforce-%d) Revision %d, policy gen %d: no changes", enforcer.idx, revision.GetGeneration(), policyGen)
+	}
+
+	// apply
+	log.Infof("(enforce-%d) Applying actions", enforcer.idx)
+	pluginRegistry := enforcer.pluginRegistryFactory()
+	applyLog := event.NewLog(log.DebugLevel, fmt.Sprintf("enforce-%d-apply", enforcer.idx))
+	for _, hook := range enforcer.eventHooks {
+		applyLog.AddHook(hook)
+	}
+	applier := apply.NewEngineApply(policy, desiredState, enforcer.registry.NewActualStateUpdater(actualState), enforcer.externalData, pluginRegistry, stateDiff.ActionPlan, applyLog, enforcer.registry.NewRevisionResultUpdater(revision))
+	if enforcer.failureInjector != nil && enforcer.failureInjector.IsActive() {
+		log.Warnf("(enforce-%d) FAILURE INJECTION IS ACTIVE: %d rules", enforcer.idx, len(enforcer.failureInjector.GetRules()))
+		applier.SetFailureInjector(enforcer.failureInjector)
+	}
+	completionMarkers, err := enforcer.registry.NewCompletionMarkers(revision)
+	if err != nil {
+		return false, fmt.Errorf("error while loading action markers: %s", err)
+	}
+	applier.SetCompletionMarkers(completionMarkers)
+	recorder := &actionRecorder{}
+	applier.SetActionListener(recorder.record)
+	applier.SetContext(ctx)
+	_, _ = applier.Apply(enforcer.maxConcurrentActions)
+
+	// save apply log
+	revision.ApplyLog = applyLog.AsAPIEvents()
+	saveErr := enforcer.registry.UpdateRevision(revision)
+	if saveErr != nil {
+		return false, fmt.Errorf("error while saving revision with apply log: %s", saveErr)
+	}
+
+	log.Infof("(enforce-%d) Revision %d processed (actions: %d succeeded, %d failed, %d skipped, %d previously completed)", enforcer.idx, revision.GetGeneration(), revision.Result.Success, revision.Result.Failed, revision.Result.Skipped, revision.Result.PreviouslyCompleted)
+	if ctx.Err() != nil {
+		return false, fmt.Errorf("enforcement of revision %d has been interrupted: %s", revision.GetGeneration(), ctx.Err())
+	}
+
+	// changes of all actions of completed revision are recorded in actual state, so its markers are no longer needed
+	if revision.Status == engine.RevisionStatusCompleted {
+		err = enforcer.registry.DeleteActionMarkers(revision.GetGeneration())
+		if err != nil {
+			return false, fmt.Errorf("error while deleting action markers: %s", err)
+		}
+	}
+
+	// interrupted enforcements are left running, so they get finished once the revision is resumed
+	err = enforcer.finishEnforcements(enforcements, revision, recorder, applyLog)
+	if err != nil {
+		return false, err
+	}
+
+	// let's try again immediately until no actions were successfully applied
+	return revision.Result.Success > 0, nil
+}
+
+// reconcile checks and corrects actual state, if registry has been restored from a backup. It returns true if
+// enforcement has to be blocked, because corrections are waiting for acknowledgment
+func (enforcer *DesiredStateEnforcer) reconcile() (bool, error) {
+	reconciliation, err := enforcer.registry.GetReconciliation()
+	if err != nil {
+		return false, err
+	}
+	if reconciliation == nil || reconciliation.Applied {
+		return false, nil
+	}
+
+	// check and correct actual state (or resume, if it was interrupted)
+	if reconciliation.Status == engine.ReconciliationStatusPending || reconciliation.Status == engine.ReconciliationStatusInProgress {
+		log.Infof("(enforce-%d) Registry has been restored at %s, reconciling actual state", enforcer.idx, reconciliation.RestoredAt)
+
+		policy, _, policyErr := enforcer.registry.GetPolicy(runtime.LastOrEmptyGen)
+		if policyErr != nil {
+			return false, fmt.Errorf("error while getting policy: %s", policyErr)
+		}
+		actualState, actualStateErr := enforcer.registry.GetActualState()
+		if actualStateErr != nil {
+			return false, fmt.Errorf("error while getting actual state: %s", actualStateErr)
+		}
+
+		reconcileLog := event.NewLog(log.DebugLevel, fmt.Sprintf("enforce-%d-reconcile", enforcer.idx))
+		for _, hook := range enforcer.eventHooks {
+			reconcileLog.AddHook(hook)
+		}
+		reconciler := reconcile.NewReconciler(policy, actualState, enforcer.registry.NewActualStateUpdater(actualState), enforcer.pluginRegistryFactory(), reconcileLog, enforcer.maxConcurrentActions)
+		err = reconciler.Reconcile(reconciliation, enforcer.registry.UpdateReconciliation)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if reconciliation.Status == engine.ReconciliationStatusWaitingForAck {
+		log.Warningf("(enforce-%d) Enforcement is blocked until %d actual state corrections are acknowledged", enforcer.idx, len(reconciliation.Corrections))
+		return true, nil
+	}
+
+	// actual state has been corrected, so the latest revision has to be enforced again
+	if len(reconciliation.Corrections) > 0 {
+		err = enforcer.newRevisionForLatestPolicy()
+		if err != nil {
+			return false, err
+		}
+	}
+
+	reconciliation.Applied = true
+	return false, enforcer.registry.UpdateReconciliation(reconciliation)
+}
+
+// newRevisionForLatestPolicy creates a new revision with the same desired state as the last revision of the latest policy
+func (enforcer *DesiredStateEnforcer) newRevisionForLatestPolicy() error {
+	_, policyGen, err := enforcer.registry.GetPolicy(runtime.LastOrEmptyGen)
+	if err != nil {
+		return fmt.Errorf("unable to load latest policy: %s", err)
+	}
+	lastRevision, err := enforcer.registry.GetLastRevisionForPolicy(policyGen)
+	if err != nil {
+		return fmt.Errorf("unable to load latest revision: %s", err)
+	}
+	if lastRevision == nil {
+		return nil
+	}
+
+	desiredState, err := enforcer.registry.GetDesiredState(lastRevision)
+	if err != nil {
+		return fmt.Errorf("can't load desired state from revision: %s", err)
+	}
+	revision, err := enforcer.registry.NewRevision(policyGen, desiredState, false)
+	if err != nil {
+		return fmt.Errorf("unable to create new revision: %s", err)
+	}
+
+	log.Infof("(enforce-%d) Created revision %d to enforce corrected actual state", enforcer.idx, revision.GetGeneration())
+	return nil
+}
+
+// getRevisionTraceContext returns a given context with the tracing span of the request, which created a given revision,
+// as a remote parent. If revision has no trace recorded, a given context is returned and enforcement starts a new trace
+func getRevisionTraceContext(ctx context.Context, revision *engine.Revision) context.Context {
+	traceID, err := trace.TraceIDFromHex(revision.TraceID)
+	if err != nil {
+		return ctx
+	}
+	spanID, err := trace.SpanIDFromHex(revision.SpanID)
+	if err != nil {
+		return ctx
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	}))
+}
@@ -0,0 +1,277 @@
+package enforce
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/config"
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/apply/chaos"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+	"github.com/Aptomi/aptomi/pkg/plugin/fake"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnforcerRetryExhaustion(t *testing.T) {
+	test := newEnforcerTest(t)
+
+	// every action keeps failing with a transient error, so every retry of the revision fails as well
+	injector := chaos.NewInjector()
+	assert.NoError(t, injector.SetRules([]*chaos.Rule{{FailEvery: 1}}), "Rules should be valid")
+	enforcer := test.newEnforcer(injector)
+	for retry := 0; retry < 3; retry++ {
+		applied, err := enforcer.Enforce(context.Background())
+		assert.NoError(t, err, "Enforcement should not fail")
+		assert.False(t, applied, "No actions should be applied")
+
+		revision := test.revision(t)
+		assert.Equal(t, engine.RevisionStatusCompleted, revision.Status, "Revision should be completed")
+		assert.Zero(t, revision.Result.Success, "No actions should succeed")
+		assert.True(t, revision.Result.Failed > 0, "Actions should fail")
+		assert.Empty(t, test.actualStateKeys(t), "Actual state should not be updated")
+	}
+	assert.Equal(t, engine.EnforcementStatusFailed, test.enforcement(t).Status, "Enforcement should fail")
+
+	// once transient errors are gone, retry should bring actual state in line with desired state
+	assert.NoError(t, injector.SetRules(nil), "Rules should be cleared")
+	applied, err := enforcer.Enforce(context.Background())
+	assert.NoError(t, err, "Enforcement should not fail")
+	assert.True(t, applied, "Actions should be applied")
+	test.checkCompleted(t)
+
+	// nothing is left to retry
+	applied, err = enforcer.Enforce(context.Background())
+	assert.NoError(t, err, "Enforcement should not fail")
+	assert.False(t, applied, "No actions should be applied")
+}
+
+func TestEnforcerCancellation(t *testing.T) {
+	test := newEnforcerTest(t)
+
+	// enforcement gets cancelled while the first action hangs, so the rest of actions shouldn't be started
+	injector := chaos.NewInjector()
+	assert.NoError(t, injector.SetRules([]*chaos.Rule{{Hang: 100 * time.Millisecond}}), "Rules should be valid")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := test.newEnforcer(injector).Enforce(ctx)
+	assert.Error(t, err, "Enforcement should be interrupted")
+
+	revision := test.revision(t)
+	assert.Equal(t, engine.RevisionStatusCompleted, revision.Status, "Interrupted revision should be completed")
+	assert.True(t, revision.Result.Failed > 0, "Actions should fail once enforcement is cancelled")
+	assert.Equal(t, engine.EnforcementStatusRunning, test.enforcement(t).Status, "Interrupted enforcement should be left running")
+
+	// next enforcement retries the revision and finishes the enforcement
+	applied, err := test.newEnforcer(nil).Enforce(context.Background())
+	assert.NoError(t, err, "Enforcement should not fail")
+	assert.True(t, applied, "Actions should be applied")
+	test.checkCompleted(t)
+	test.checkEnforcementSucceeded(t)
+}
+
+func TestEnforcerLeaderLoss(t *testing.T) {
+	test := newEnforcerTest(t)
+
+	// leadership gets lost after the first action has been applied, so the rest of actions shouldn't be started
+	ctx, loseLeadership := context.WithCancel(context.Background())
+	defer loseLeadership()
+	injector := chaos.NewInjector().SetCrashFunc(func(reason string) { loseLeadership() })
+	assert.NoError(t, injector.SetRules([]*chaos.Rule{{CrashAfter: 1}}), "Rules should be valid")
+	_, err := test.newEnforcer(injector).Enforce(ctx)
+	assert.Error(t, err, "Enforcement should be interrupted")
+
+	revision := test.revision(t)
+	assert.EqualValues(t, 1, revision.Result.Success, "Action applied before leadership loss should succeed")
+	assert.NotEmpty(t, test.actualStateKeys(t), "Action applied before leadership loss should be recorded in actual state")
+	assert.Equal(t, engine.EnforcementStatusRunning, test.enforcement(t).Status, "Interrupted enforcement should be left running")
+
+	// new leader picks up the revision and applies the rest of actions
+	applied, err := test.newEnforcer(nil).Enforce(context.Background())
+	assert.NoError(t, err, "Enforcement should not fail")
+	assert.True(t, applied, "Actions should be applied")
+	test.checkCompleted(t)
+	test.checkEnforcementSucceeded(t)
+	assert.True(t, test.revision(t).Result.Total < revision.Result.Total, "Actions applied before leadership loss should not be applied again")
+}
+
+func TestEnforcerResumeFromPersistedState(t *testing.T) {
+	test := newEnforcerTest(t)
+
+	// process dies after the first action has been applied, leaving the revision in progress
+	crashed := make(chan bool, 1)
+	dead := make(chan bool)
+	injector := chaos.NewInjector().SetCrashFunc(func(reason string) {
+		select {
+		case crashed <- true:
+		default:
+		}
+		<-dead
+	})
+	assert.NoError(t, injector.SetRules([]*chaos.Rule{{CrashAfter: 1}}), "Rules should be valid")
+	finished := make(chan bool)
+	go func() {
+		_, _ = test.newEnforcer(injector).Enforce(context.Background())
+		close(finished)
+	}()
+	select {
+	case <-crashed:
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "Process should crash")
+		t.FailNow()
+	}
+
+	// result of the action applied before crash gets recorded concurrently with the crash
+	revision := test.revision(t)
+	for deadline := time.Now().Add(10 * time.Second); revision.Result.Success < 1 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		revision = test.revision(t)
+	}
+	assert.Equal(t, engine.RevisionStatusInProgress, revision.Status, "Revision should be left in progress")
+	assert.EqualValues(t, 1, revision.Result.Success, "Action applied before crash should succeed")
+
+	// another process resumes the revision from the state persisted by the dead one
+	applied, err := test.newEnforcer(nil).Enforce(context.Background())
+	assert.NoError(t, err, "Enforcement should not fail")
+	assert.True(t, applied, "Actions should be applied")
+	test.checkCompleted(t)
+	test.checkEnforcementSucceeded(t)
+
+	close(dead)
+	<-finished
+}
+
+// enforcerTest is a registry with a single revision waiting to be enforced, which gets shared by enforcers
+// representing different processes
+type enforcerTest struct {
+	store          store.Interface
+	builder        *builder.PolicyBuilder
+	desiredState   *resolve.PolicyResolution
+	revisionGen    runtime.Generation
+	enforcementGen runtime.Generation
+}
+
+func newEnforcerTest(t *testing.T) *enforcerTest {
+	t.Helper()
+	test := &enforcerTest{
+		store:   memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()),
+		builder: builder.NewPolicyBuilder(),
+	}
+	reg := registry.New(test.store)
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	b := test.builder
+	cluster := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	rule := b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+	claim := b.AddClaim(b.AddUser(), service)
+	_, policyData, err := reg.UpdatePolicy([]lang.Base{cluster, bundle, service, rule, claim}, "admin")
+	if !assert.NoError(t, err, "Policy should be updated") {
+		t.FailNow()
+	}
+
+	test.desiredState, err = resolve.NewPolicyResolver(b.Policy(), b.External(), event.NewLog(logrus.WarnLevel, "test-resolve")).ResolveAllClaims(context.Background())
+	if !assert.NoError(t, err, "Policy should be resolved") {
+		t.FailNow()
+	}
+	revision, err := reg.NewRevision(policyData.GetGeneration(), test.desiredState, false)
+	if !assert.NoError(t, err, "Revision should be created") {
+		t.FailNow()
+	}
+	test.revisionGen = revision.GetGeneration()
+	enforcement, err := reg.NewEnforcement(revision.GetGeneration(), time.Now())
+	if !assert.NoError(t, err, "Enforcement should be created") {
+		t.FailNow()
+	}
+	test.enforcementGen = enforcement.GetGeneration()
+
+	return test
+}
+
+// newEnforcer creates enforcer of a new process, which only shares the persisted state with other processes
+func (test *enforcerTest) newEnforcer(injector *chaos.Injector) *DesiredStateEnforcer {
+	pluginRegistryFactory := func() plugin.Registry {
+		return plugin.NewRegistry(
+			config.Plugins{},
+			map[string]plugin.ClusterPluginConstructor{
+				"kubernetes": func(cluster *lang.Cluster, cfg config.Plugins) (plugin.ClusterPlugin, error) {
+					return fake.NewNoOpClusterPlugin(0), nil
+				},
+			},
+			map[string]map[string]plugin.CodePluginConstructor{
+				"kubernetes": {
+					"helm": func(cluster plugin.ClusterPlugin, cfg config.Plugins) (plugin.CodePlugin, error) {
+						return fake.NewNoOpCodePlugin(0), nil
+					},
+				},
+			},
+		)
+	}
+	return NewDesiredStateEnforcer(registry.New(test.store), test.builder.External(), pluginRegistryFactory, 1).SetFailureInjector(injector)
+}
+
+func (test *enforcerTest) revision(t *testing.T) *engine.Revision {
+	t.Helper()
+	revision, err := registry.New(test.store).GetRevision(test.revisionGen)
+	if !assert.NoError(t, err, "Revision should be loaded") || !assert.NotNil(t, revision, "Revision should exist") {
+		t.FailNow()
+	}
+	return revision
+}
+
+func (test *enforcerTest) enforcement(t *testing.T) *engine.Enforcement {
+	t.Helper()
+	enforcement, err := registry.New(test.store).GetEnforcement(test.enforcementGen)
+	if !assert.NoError(t, err, "Enforcement should be loaded") || !assert.NotNil(t, enforcement, "Enforcement should exist") {
+		t.FailNow()
+	}
+	return enforcement
+}
+
+func (test *enforcerTest) actualStateKeys(t *testing.T) []string {
+	t.Helper()
+	actualState, err := registry.New(test.store).GetActualState()
+	if !assert.NoError(t, err, "Actual state should be loaded") {
+		t.FailNow()
+	}
+	return instanceKeys(actualState)
+}
+
+// checkCompleted checks that persisted revision is completed without failures and actual state matches desired state
+func (test *enforcerTest) checkCompleted(t *testing.T) {
+	t.Helper()
+	revision := test.revision(t)
+	assert.Equal(t, engine.RevisionStatusCompleted, revision.Status, "Revision should be completed")
+	assert.Zero(t, revision.Result.Failed, "No actions should fail")
+	assert.Equal(t, instanceKeys(test.desiredState), test.actualStateKeys(t), "Actual state should match desired state")
+}
+
+// checkEnforcementSucceeded checks that persisted enforcement has been finished by the revision it tracks
+func (test *enforcerTest) checkEnforcementSucceeded(t *testing.T) {
+	t.Helper()
+	enforcement := test.enforcement(t)
+	assert.Equal(t, engine.EnforcementStatusSucceeded, enforcement.Status, "Enforcement should succeed")
+	assert.Equal(t, test.revisionGen, enforcement.AppliedRevisionGen, "Enforcement should be finished by the revision")
+}
+
+func instanceKeys(resolution *resolve.PolicyResolution) []string {
+	result := []string{}
+	for key := range resolution.ComponentInstanceMap {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
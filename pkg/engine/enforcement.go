@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/engine/apply/action"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+const (
+	// EnforcementStatusPending represents Enforcement status when policy has been changed, but enforcer hasn't picked up the revision yet
+	EnforcementStatusPending = "pending"
+	// EnforcementStatusRunning represents Enforcement status when actions are being applied
+	EnforcementStatusRunning = "running"
+	// EnforcementStatusSucceeded represents Enforcement status when all actions have been applied successfully
+	EnforcementStatusSucceeded = "succeeded"
+	// EnforcementStatusFailed represents Enforcement status when some of the actions failed
+	EnforcementStatusFailed = "failed"
+)
+
+const (
+	// EnforcementActionSuccess is a result of action, which has been applied successfully
+	EnforcementActionSuccess = "success"
+	// EnforcementActionFailed is a result of action, which failed to apply
+	EnforcementActionFailed = "failed"
+	// EnforcementActionPreviouslyCompleted is a result of action, which hasn't been executed, because it has been completed with the same inputs before
+	EnforcementActionPreviouslyCompleted = "previously-completed"
+)
+
+// EnforcementKey is the default key for the Enforcement object (there is only one Enforcement exists but with multiple generations)
+var EnforcementKey = runtime.KeyFromParts(runtime.SystemNS, TypeEnforcement.Kind, runtime.EmptyName)
+
+// TypeEnforcement is TypeInfo for Enforcement
+var TypeEnforcement = &runtime.TypeInfo{
+	Kind:        "enforcement",
+	Storable:    true,
+	Versioned:   true,
+	Constructor: func() runtime.Object { return &Enforcement{} },
+	IndexValueTransforms: map[string]runtime.ValueTransform{
+		"Status": func(val interface{}) interface{} {
+			if val.(string) == EnforcementStatusSucceeded || val.(string) == EnforcementStatusFailed {
+				return nil
+			}
+			return val
+		},
+	},
+}
+
+// Enforcement is a job, which tracks enforcement of desired state for a single policy change. It gets created along
+// with the revision for the changed policy and it's updated by the enforcer while the revision is being applied, so
+// the outcome of the policy change could be polled via API. Generation of the Enforcement is its ID
+type Enforcement struct {
+	runtime.TypeKind `yaml:",inline"`
+	Metadata         runtime.GenerationMetadata
+
+	// RevisionGen is the revision created for the policy change. If it gets superseded by a newer revision before
+	// enforcement starts, the newer revision gets applied instead
+	RevisionGen runtime.Generation `store:"index"`
+
+	Status     string `store:"index"`
+	CreatedAt  time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
+
+	// AppliedRevisionGen is the revision, which has actually been applied
+	AppliedRevisionGen runtime.Generation `yaml:",omitempty"`
+
+	Result  *action.ApplyResult
+	Actions []*EnforcementAction
+	Log     []*event.APIEvent
+}
+
+// EnforcementAction is a result of a single action executed during enforcement
+type EnforcementAction struct {
+	ActionKey string
+	Result    string
+	Error     string `yaml:",omitempty"`
+}
+
+// NewEnforcement creates a new pending Enforcement for a given revision
+func NewEnforcement(gen runtime.Generation, revisionGen runtime.Generation, createdAt time.Time) *Enforcement {
+	return &Enforcement{
+		TypeKind: TypeEnforcement.GetTypeKind(),
+		Metadata: runtime.GenerationMetadata{
+			Generation: gen,
+		},
+		RevisionGen: revisionGen,
+		Status:      EnforcementStatusPending,
+		CreatedAt:   createdAt,
+		Result:      &action.ApplyResult{},
+	}
+}
+
+// IsFinished returns true if enforcement will not be processed any further
+func (enforcement *Enforcement) IsFinished() bool {
+	return enforcement.Status == EnforcementStatusSucceeded || enforcement.Status == EnforcementStatusFailed
+}
+
+// GetName returns Enforcement name
+func (enforcement *Enforcement) GetName() string {
+	return runtime.EmptyName
+}
+
+// GetNamespace returns Enforcement namespace
+func (enforcement *Enforcement) GetNamespace() string {
+	return runtime.SystemNS
+}
+
+// GetGeneration returns Enforcement generation
+func (enforcement *Enforcement) GetGeneration() runtime.Generation {
+	return enforcement.Metadata.Generation
+}
+
+// SetGeneration sets Enforcement generation
+func (enforcement *Enforcement) SetGeneration(gen runtime.Generation) {
+	enforcement.Metadata.Generation = gen
+}
+
+// GetDefaultColumns returns default set of columns to be displayed
+func (enforcement *Enforcement) GetDefaultColumns() []string {
+	return []string{"Enforcement", "Revision", "Status", "Started", "Finished", "Success", "Failed"}
+}
+
+// AsColumns returns Enforcement representation as columns
+func (enforcement *Enforcement) AsColumns() map[string]string {
+	result := make(map[string]string)
+
+	result["Enforcement"] = enforcement.GetGeneration().String()
+	result["Revision"] = enforcement.RevisionGen.String()
+	result["Status"] = enforcement.Status
+	result["Created"] = formatTime(enforcement.CreatedAt)
+	result["Started"] = formatTime(enforcement.StartedAt)
+	result["Finished"] = formatTime(enforcement.FinishedAt)
+
+	if enforcement.Result != nil {
+		result["Success"] = strconv.FormatUint(uint64(enforcement.Result.Success), 10)
+		result["Failed"] = strconv.FormatUint(uint64(enforcement.Result.Failed), 10)
+		result["Skipped"] = strconv.FormatUint(uint64(enforcement.Result.Skipped), 10)
+		result["Total"] = strconv.FormatUint(uint64(enforcement.Result.Total), 10)
+	}
+
+	return result
+}
@@ -0,0 +1,311 @@
+package enginetest
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+
+	"github.com/Aptomi/aptomi/pkg/external"
+	"github.com/Aptomi/aptomi/pkg/external/secrets"
+	"github.com/Aptomi/aptomi/pkg/external/users"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/util"
+	"gopkg.in/yaml.v2"
+)
+
+// SyntheticPolicyParams defines the shape of a generated synthetic policy
+type SyntheticPolicyParams struct {
+	// Seed is a seed for random generator. The same params with the same seed always produce the same policy
+	Seed int64
+
+	// Namespaces is the number of namespaces with services, bundles and claims
+	Namespaces int
+
+	// ServicesPerNamespace is the number of services in every namespace. Every service has its own bundle
+	ServicesPerNamespace int
+
+	// FanOut is the number of services every bundle depends on. Dependencies always point to services generated
+	// later, so there are no cycles and the last services have fewer dependencies
+	FanOut int
+
+	// Rules is the number of rules, which don't match any claim, but still get evaluated during resolution. Every
+	// cluster gets a placement rule on top of them
+	Rules int
+
+	// Clusters is the number of clusters. Claims get spread across them based on team label of their users
+	Clusters int
+
+	// Claims is the number of claims, spread across namespaces
+	Claims int
+
+	// Users is the number of users in external data
+	Users int
+}
+
+// SyntheticPolicy is a generated synthetic policy along with the matching external data
+type SyntheticPolicy struct {
+	// Objects is a list of all generated policy objects in the order they have been generated
+	Objects []lang.Base
+
+	// Users is a list of all generated users
+	Users []*lang.User
+
+	// Policy contains all generated objects
+	Policy *lang.Policy
+
+	// External is external data with all generated users
+	External *external.Data
+}
+
+// syntheticPolicyGenerator holds the state of synthetic policy generation
+type syntheticPolicyGenerator struct {
+	params   SyntheticPolicyParams
+	random   *rand.Rand
+	services []string
+	result   *SyntheticPolicy
+}
+
+// NewSyntheticPolicy generates a deterministic synthetic policy of a given shape, which could be used for
+// benchmarking resolver, diff and store on a large realistic policy without committing it to the repo
+func NewSyntheticPolicy(params SyntheticPolicyParams) *SyntheticPolicy {
+	if params.Namespaces <= 0 || params.ServicesPerNamespace <= 0 || params.Clusters <= 0 || params.Users <= 0 {
+		panic(fmt.Sprintf("synthetic policy should have at least one namespace, service, cluster and user: %+v", params))
+	}
+
+	gen := &syntheticPolicyGenerator{
+		params: params,
+		random: rand.New(rand.NewSource(params.Seed)),
+		result: &SyntheticPolicy{Policy: lang.NewPolicy()},
+	}
+	gen.makeUsers()
+	gen.makeClusters()
+	gen.makeRules()
+	gen.makeServices()
+	gen.makeClaims()
+
+	userLoader := users.NewUserLoaderMock()
+	for _, user := range gen.result.Users {
+		userLoader.AddUser(user)
+	}
+	gen.result.External = external.NewData(userLoader, secrets.NewSecretLoaderMock())
+
+	err := gen.result.Policy.Validate()
+	if err != nil {
+		panic(fmt.Sprintf("generated synthetic policy is invalid: %s", err))
+	}
+
+	return gen.result
+}
+
+// WriteYAML writes all policy objects as a YAML list, which could be applied via 'aptomictl policy apply'. If a users
+// writer is given, users get written there in the format of the users file, which server loads users from
+func (policy *SyntheticPolicy) WriteYAML(policyWriter io.Writer, usersWriter io.Writer) error {
+	data, err := yaml.Marshal(policy.Objects)
+	if err != nil {
+		return fmt.Errorf("error while marshaling policy objects: %s", err)
+	}
+	_, err = policyWriter.Write(data)
+	if err != nil {
+		return fmt.Errorf("error while writing policy objects: %s", err)
+	}
+
+	if usersWriter == nil {
+		return nil
+	}
+	data, err = yaml.Marshal(policy.Users)
+	if err != nil {
+		return fmt.Errorf("error while marshaling users: %s", err)
+	}
+	_, err = usersWriter.Write(data)
+	if err != nil {
+		return fmt.Errorf("error while writing users: %s", err)
+	}
+
+	return nil
+}
+
+// SetPassword sets the same password for all generated users, so they could log into a running server
+func (policy *SyntheticPolicy) SetPassword(password string) {
+	passwordHash := util.HashAndSalt(password)
+	for _, user := range policy.Users {
+		user.PasswordHash = passwordHash
+	}
+}
+
+func (gen *syntheticPolicyGenerator) addObject(obj lang.Base) {
+	err := gen.result.Policy.AddObject(obj)
+	if err != nil {
+		panic(err)
+	}
+	gen.result.Objects = append(gen.result.Objects, obj)
+}
+
+func (gen *syntheticPolicyGenerator) makeUsers() {
+	for i := 0; i < gen.params.Users; i++ {
+		gen.result.Users = append(gen.result.Users, &lang.User{
+			Name: "user-" + strconv.Itoa(i),
+			Labels: map[string]string{
+				"team": "team-" + strconv.Itoa(gen.random.Intn(gen.params.Clusters)),
+				"org":  "org-" + strconv.Itoa(gen.random.Intn(gen.params.Namespaces)),
+			},
+			DomainAdmin: true,
+		})
+	}
+}
+
+func (gen *syntheticPolicyGenerator) makeClusters() {
+	for i := 0; i < gen.params.Clusters; i++ {
+		gen.addObject(&lang.Cluster{
+			TypeKind: lang.TypeCluster.GetTypeKind(),
+			Metadata: lang.Metadata{
+				Namespace: runtime.SystemNS,
+				Name:      "cluster-" + strconv.Itoa(i),
+			},
+			Type: "kubernetes",
+			Config: struct {
+				Namespace string
+			}{
+				Namespace: "default",
+			},
+		})
+	}
+}
+
+func (gen *syntheticPolicyGenerator) makeRules() {
+	// rules which never match, spread across namespaces
+	for i := 0; i < gen.params.Rules; i++ {
+		gen.addObject(&lang.Rule{
+			TypeKind: lang.TypeRule.GetTypeKind(),
+			Metadata: lang.Metadata{
+				Namespace: gen.namespace(i % gen.params.Namespaces),
+				Name:      "rule-" + strconv.Itoa(i),
+			},
+			Weight: i,
+			Criteria: &lang.Criteria{
+				RequireAll: []string{"org == 'org-none-" + util.RandomID(gen.random, 10) + "'"},
+			},
+			Actions: &lang.RuleActions{
+				Claim: lang.ClaimAction("reject"),
+			},
+		})
+	}
+
+	// global placement rules, which put claims of every team into its own cluster
+	for i := 0; i < gen.params.Clusters; i++ {
+		gen.addObject(&lang.Rule{
+			TypeKind: lang.TypeRule.GetTypeKind(),
+			Metadata: lang.Metadata{
+				Namespace: runtime.SystemNS,
+				Name:      "placement-" + strconv.Itoa(i),
+			},
+			Weight: gen.params.Rules + i,
+			Criteria: &lang.Criteria{
+				RequireAll: []string{"team == 'team-" + strconv.Itoa(i) + "'"},
+			},
+			Actions: &lang.RuleActions{
+				ChangeLabels: lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, "cluster-"+strconv.Itoa(i)),
+			},
+		})
+	}
+}
+
+func (gen *syntheticPolicyGenerator) makeServices() {
+	// service locators are known upfront, so bundles could depend on services generated later
+	for i := 0; i < gen.params.Namespaces; i++ {
+		for j := 0; j < gen.params.ServicesPerNamespace; j++ {
+			gen.services = append(gen.services, gen.namespace(i)+"/service-"+strconv.Itoa(j))
+		}
+	}
+
+	for idx := range gen.services {
+		namespace, name := gen.namespace(idx/gen.params.ServicesPerNamespace), "service-"+strconv.Itoa(idx%gen.params.ServicesPerNamespace)
+		bundleName := "bundle-" + strconv.Itoa(idx%gen.params.ServicesPerNamespace)
+
+		bundle := &lang.Bundle{
+			TypeKind: lang.TypeBundle.GetTypeKind(),
+			Metadata: lang.Metadata{
+				Namespace: namespace,
+				Name:      bundleName,
+			},
+			Components: []*lang.BundleComponent{{
+				Name: "code",
+				Code: &lang.Code{
+					Type: "helm",
+					// shared instances are consumed by users of different orgs, so parameters can't depend on user's org.
+					// team is the same for all consumers, as every team is placed into its own cluster
+					Params: util.NestedParameterMap{
+						"chartName": name,
+						"team":      "{{ .Labels.team }}",
+					},
+				},
+			}},
+		}
+		for dep := 0; dep < gen.params.FanOut && idx+1 < len(gen.services); dep++ {
+			depIdx := idx + 1 + gen.random.Intn(len(gen.services)-idx-1)
+			bundle.Components = append(bundle.Components, &lang.BundleComponent{
+				Name:    "dep-" + strconv.Itoa(dep),
+				Service: gen.services[depIdx],
+			})
+		}
+		gen.addObject(bundle)
+
+		gen.addObject(&lang.Service{
+			TypeKind: lang.TypeService.GetTypeKind(),
+			Metadata: lang.Metadata{
+				Namespace: namespace,
+				Name:      name,
+			},
+			Contexts: []*lang.Context{
+				{
+					Name: "own-org",
+					Criteria: &lang.Criteria{
+						RequireAll: []string{"org == 'org-" + strconv.Itoa(idx/gen.params.ServicesPerNamespace) + "'"},
+					},
+					Allocation: &lang.Allocation{
+						Bundle: bundleName,
+						Keys:   []string{"{{ .Labels.org }}"},
+					},
+				},
+				{
+					Name: "shared",
+					Criteria: &lang.Criteria{
+						RequireAll: []string{"true"},
+					},
+					Allocation: &lang.Allocation{
+						Bundle: bundleName,
+					},
+				},
+			},
+		})
+	}
+}
+
+func (gen *syntheticPolicyGenerator) makeClaims() {
+	for i := 0; i < gen.params.Claims; i++ {
+		nsIdx := gen.random.Intn(gen.params.Namespaces)
+		gen.addObject(&lang.Claim{
+			TypeKind: lang.TypeClaim.GetTypeKind(),
+			Metadata: lang.Metadata{
+				Namespace: gen.namespace(nsIdx),
+				Name:      "claim-" + strconv.Itoa(i),
+			},
+			User:    "user-" + strconv.Itoa(gen.random.Intn(gen.params.Users)),
+			Service: "service-" + strconv.Itoa(gen.random.Intn(gen.params.ServicesPerNamespace)),
+		})
+	}
+}
+
+func (gen *syntheticPolicyGenerator) namespace(idx int) string {
+	return "ns-" + strconv.Itoa(idx)
+}
+
+// Shapes of synthetic policies used in benchmarks
+var (
+	// SyntheticPolicySmall is a small synthetic policy, which gets resolved in a fraction of a second
+	SyntheticPolicySmall = SyntheticPolicyParams{Seed: 239, Namespaces: 5, ServicesPerNamespace: 10, FanOut: 2, Rules: 25, Clusters: 3, Claims: 500, Users: 100}
+
+	// SyntheticPolicyLarge is a large synthetic policy, comparable to a big production installation
+	SyntheticPolicyLarge = SyntheticPolicyParams{Seed: 239, Namespaces: 50, ServicesPerNamespace: 20, FanOut: 3, Rules: 200, Clusters: 10, Claims: 10000, Users: 5000}
+)
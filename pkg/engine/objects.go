@@ -11,6 +11,14 @@ var (
 		TypePolicyData,
 		TypeRevision,
 		TypeDesiredState,
+		TypeDesiredStateIndex,
+		TypeDesiredStateInstance,
+		TypeActionMarker,
+		TypeResolutionLog,
+		TypeReconciliation,
+		TypeResolutionQueue,
+		TypeEnforcement,
+		TypePolicyApplyJob,
 		resolve.TypeComponentInstance,
 	})
 )
@@ -0,0 +1,5 @@
+// Package pipeline implements revision pipeline, which calculates desired state for committed policy changes in the
+// background. Policy changes enqueue resolution work onto a bounded, persisted queue with priorities (interactive
+// API requests go above GitOps sync, which goes above drift-triggered re-resolutions). A configurable number of
+// workers consume the queue and turn revisions in resolving status into the ones waiting to be enforced.
+package pipeline
@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	mQueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "aptomi_resolution_queue_depth",
+			Help:        "Number of policy changes waiting in the resolution queue labeled with priority.",
+			ConstLabels: prometheus.Labels{"service": "aptomi"},
+		},
+		[]string{"priority"},
+	)
+
+	mQueueWait = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:        "aptomi_resolution_queue_wait_seconds",
+			Help:        "Time policy changes spent in the resolution queue before being picked up labeled with priority.",
+			ConstLabels: prometheus.Labels{"service": "aptomi"},
+			Buckets:     []float64{.01, .05, .1, .5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+		},
+		[]string{"priority"},
+	)
+
+	mResolved = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "aptomi_resolution_queue_processed_total",
+			Help:        "Number of policy changes resolved by the revision pipeline labeled with priority.",
+			ConstLabels: prometheus.Labels{"service": "aptomi"},
+		},
+		[]string{"priority", "success"},
+	)
+
+	mResolveDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:        "aptomi_resolution_duration_seconds",
+			Help:        "Duration of policy resolution done by the revision pipeline labeled with priority.",
+			ConstLabels: prometheus.Labels{"service": "aptomi"},
+			Buckets:     []float64{.01, .05, .1, .5, 1, 2.5, 5, 10, 30, 60},
+		},
+		[]string{"priority", "success"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(mQueueDepth)
+	prometheus.MustRegister(mQueueWait)
+	prometheus.MustRegister(mResolved)
+	prometheus.MustRegister(mResolveDuration)
+}
+
+// collectResolvedMetrics collects metrics for resolution work of a given priority, start time and resulting error
+func collectResolvedMetrics(priority string, start time.Time, err error) {
+	labels := []string{priority, strconv.FormatBool(err == nil)}
+
+	mResolved.WithLabelValues(labels...).Inc()
+	mResolveDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,415 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/external"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrQueueFull is returned when resolution queue has reached its max depth
+var ErrQueueFull = errors.New("resolution queue is full")
+
+// Pipeline consumes persisted resolution queue, calculating desired state for revisions in resolving status.
+// It's safe to use from multiple go routines
+type Pipeline struct {
+	registry              registry.Interface
+	externalData          *external.Data
+	pluginRegistryFactory plugin.RegistryFactory
+	maxDepth              int
+	workers               int
+	eventHooks            []log.Hook
+	resolvedHook          func()
+
+	mutex     sync.Mutex
+	queue     *engine.ResolutionQueue
+	inFlight  map[runtime.Generation]bool
+	processed map[string]int
+	lastWait  map[string]time.Duration
+	wakeup    chan bool
+}
+
+// Status shows the state of the resolution queue
+type Status struct {
+	// MaxDepth is the max number of items in the queue
+	MaxDepth int
+
+	// Workers is the number of resolver workers
+	Workers int
+
+	// Depth is the number of items in the queue (including the ones being resolved right now) by priority
+	Depth map[string]int
+
+	// InFlight is the number of items being resolved right now
+	InFlight int
+
+	// OldestWait is how long the oldest item in the queue has been waiting
+	OldestWait time.Duration
+
+	// Processed is the number of items resolved since server start by priority
+	Processed map[string]int
+
+	// LastWait is how long the last resolved item has been waiting in the queue by priority
+	LastWait map[string]time.Duration
+}
+
+// NewPipeline creates a new Pipeline. Queue is bounded by maxDepth items and gets consumed by a given number of
+// workers. Event hooks get attached to the resolution log of every processed item
+func NewPipeline(registry registry.Interface, externalData *external.Data, pluginRegistryFactory plugin.RegistryFactory, maxDepth int, workers int, eventHooks ...log.Hook) *Pipeline {
+	if maxDepth <= 0 {
+		maxDepth = 1
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+	return &Pipeline{
+		registry:              registry,
+		externalData:          externalData,
+		pluginRegistryFactory: pluginRegistryFactory,
+		maxDepth:              maxDepth,
+		workers:               workers,
+		eventHooks:            eventHooks,
+		resolvedHook:          func() {},
+		inFlight:              make(map[runtime.Generation]bool),
+		processed:             make(map[string]int),
+		lastWait:              make(map[string]time.Duration),
+		wakeup:                make(chan bool, workers),
+	}
+}
+
+// SetResolvedHook sets function, which gets called every time a revision has been resolved (e.g. to trigger enforcement)
+func (pipeline *Pipeline) SetResolvedHook(hook func()) *Pipeline {
+	pipeline.resolvedHook = hook
+	return pipeline
+}
+
+// IsFull returns true if resolution queue has reached its max depth
+func (pipeline *Pipeline) IsFull() (bool, error) {
+	pipeline.mutex.Lock()
+	defer pipeline.mutex.Unlock()
+
+	err := pipeline.load()
+	if err != nil {
+		return false, err
+	}
+	return len(pipeline.queue.Items) >= pipeline.maxDepth, nil
+}
+
+// Enqueue creates a new revision in resolving status for a given policy generation and puts it into the resolution
+// queue with a given priority. It returns ErrQueueFull if the queue has reached its max depth
+func (pipeline *Pipeline) Enqueue(policyGen runtime.Generation, priority string, enqueuedBy string) (*engine.Revision, error) {
+	if engine.ResolutionPriorityRank(priority) < 0 {
+		return nil, fmt.Errorf("unknown resolution priority '%s', should be one of %v", priority, engine.ResolutionPriorities)
+	}
+
+	pipeline.mutex.Lock()
+	defer pipeline.mutex.Unlock()
+
+	err := pipeline.load()
+	if err != nil {
+		return nil, err
+	}
+	if len(pipeline.queue.Items) >= pipeline.maxDepth {
+		return nil, ErrQueueFull
+	}
+
+	revision, err := pipeline.registry.NewResolvingRevision(policyGen)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create new revision for policy gen %d: %s", policyGen, err)
+	}
+
+	pipeline.queue.Items = append(pipeline.queue.Items, &engine.ResolutionWork{
+		RevisionGen: revision.GetGeneration(),
+		PolicyGen:   policyGen,
+		Priority:    priority,
+		EnqueuedAt:  time.Now(),
+		EnqueuedBy:  enqueuedBy,
+	})
+	err = pipeline.save()
+	if err != nil {
+		// revision isn't in the queue, so it will never get resolved. mark it as failed, so it doesn't block enforcement
+		revision.Status = engine.RevisionStatusError
+		revision.ResolutionError = fmt.Sprintf("unable to queue revision for resolution: %s", err)
+		if updateErr := pipeline.registry.UpdateRevision(revision); updateErr != nil {
+			log.Errorf("Unable to mark revision %d as failed: %s", revision.GetGeneration(), updateErr)
+		}
+		return nil, err
+	}
+	log.Infof("Revision %d for policy gen %d queued for resolution with priority '%s' (queue depth: %d)", revision.GetGeneration(), policyGen, priority, len(pipeline.queue.Items))
+
+	// wake up one of the idle workers, if there is one
+	select {
+	case pipeline.wakeup <- true:
+	default:
+	}
+
+	return revision, nil
+}
+
+// Status returns the state of the resolution queue
+func (pipeline *Pipeline) Status() (*Status, error) {
+	pipeline.mutex.Lock()
+	defer pipeline.mutex.Unlock()
+
+	err := pipeline.load()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{
+		MaxDepth:  pipeline.maxDepth,
+		Workers:   pipeline.workers,
+		Depth:     make(map[string]int),
+		InFlight:  len(pipeline.inFlight),
+		Processed: make(map[string]int),
+		LastWait:  make(map[string]time.Duration),
+	}
+	for _, priority := range engine.ResolutionPriorities {
+		status.Depth[priority] = 0
+		status.Processed[priority] = pipeline.processed[priority]
+		status.LastWait[priority] = pipeline.lastWait[priority]
+	}
+	for _, work := range pipeline.queue.Items {
+		status.Depth[work.Priority]++
+		if wait := time.Since(work.EnqueuedAt); wait > status.OldestWait {
+			status.OldestWait = wait
+		}
+	}
+
+	return status, nil
+}
+
+// Run resolves queued items until the context is cancelled. Items queued before the server restart get picked up
+// from the persisted queue
+func (pipeline *Pipeline) Run(ctx context.Context) error {
+	status, err := pipeline.Status()
+	if err != nil {
+		return err
+	}
+	log.Infof("Revision pipeline started with %d workers (queue depth: %v, max depth: %d)", pipeline.workers, status.Depth, pipeline.maxDepth)
+
+	var wg sync.WaitGroup
+	for idx := 0; idx < pipeline.workers; idx++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			pipeline.work(ctx, idx)
+		}(idx)
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// work picks items from the queue one by one and resolves them, waiting for new items when the queue is empty
+func (pipeline *Pipeline) work(ctx context.Context, idx int) {
+	for {
+		work, err := pipeline.next()
+		if err != nil {
+			log.Errorf("(pipeline-%d) Error while picking item from resolution queue: %s", idx, err)
+		}
+		if work != nil {
+			pipeline.process(ctx, idx, work)
+			continue
+		}
+
+		// wait until there are new items in the queue. check periodically as well, in case wake up was missed
+		timer := time.NewTimer(10 * time.Second)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-pipeline.wakeup:
+			break // nolint: megacheck
+		case <-timer.C:
+			break // nolint: megacheck
+		}
+		timer.Stop()
+	}
+}
+
+// next returns the item with the highest priority, which isn't being resolved yet. Items with the same priority go
+// in the order they have been queued
+func (pipeline *Pipeline) next() (*engine.ResolutionWork, error) {
+	pipeline.mutex.Lock()
+	defer pipeline.mutex.Unlock()
+
+	err := pipeline.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var result *engine.ResolutionWork
+	for _, work := range pipeline.queue.Items {
+		if pipeline.inFlight[work.RevisionGen] {
+			continue
+		}
+		if result == nil || less(work, result) {
+			result = work
+		}
+	}
+	if result != nil {
+		pipeline.inFlight[result.RevisionGen] = true
+	}
+
+	return result, nil
+}
+
+// process resolves a single item and removes it from the queue
+func (pipeline *Pipeline) process(ctx context.Context, idx int, work *engine.ResolutionWork) {
+	start := time.Now()
+	wait := start.Sub(work.EnqueuedAt)
+	mQueueWait.WithLabelValues(work.Priority).Observe(wait.Seconds())
+	log.Infof("(pipeline-%d) Resolving revision %d for policy gen %d (priority '%s', waited %s)", idx, work.RevisionGen, work.PolicyGen, work.Priority, wait)
+
+	err := pipeline.resolve(ctx, work)
+	collectResolvedMetrics(work.Priority, start, err)
+	if err != nil {
+		log.Errorf("(pipeline-%d) Error while resolving revision %d: %s", idx, work.RevisionGen, err)
+	} else {
+		log.Infof("(pipeline-%d) Revision %d resolved in %s", idx, work.RevisionGen, time.Since(start))
+	}
+
+	pipeline.mutex.Lock()
+	defer pipeline.mutex.Unlock()
+
+	delete(pipeline.inFlight, work.RevisionGen)
+	pipeline.processed[work.Priority]++
+	pipeline.lastWait[work.Priority] = wait
+
+	// remove item from the queue, even if it failed, as the revision has been marked as failed
+	loadErr := pipeline.load()
+	if loadErr != nil {
+		log.Errorf("(pipeline-%d) Error while removing revision %d from resolution queue: %s", idx, work.RevisionGen, loadErr)
+		return
+	}
+	items := make([]*engine.ResolutionWork, 0, len(pipeline.queue.Items))
+	for _, item := range pipeline.queue.Items {
+		if item.RevisionGen != work.RevisionGen {
+			items = append(items, item)
+		}
+	}
+	pipeline.queue.Items = items
+	saveErr := pipeline.save()
+	if saveErr != nil {
+		log.Errorf("(pipeline-%d) Error while removing revision %d from resolution queue: %s", idx, work.RevisionGen, saveErr)
+	}
+
+	pipeline.resolvedHook()
+}
+
+// resolve calculates desired state for the revision of a given item. If policy can't be resolved, revision gets
+// marked as failed along with the resolution log
+func (pipeline *Pipeline) resolve(ctx context.Context, work *engine.ResolutionWork) (errResult error) {
+	defer func() {
+		if err := recover(); err != nil {
+			errResult = fmt.Errorf("panic: %s\n%s", err, string(debug.Stack()))
+		}
+	}()
+
+	revision, err := pipeline.registry.GetRevision(work.RevisionGen)
+	if err != nil {
+		return fmt.Errorf("unable to load revision: %s", err)
+	}
+	if revision == nil {
+		return fmt.Errorf("revision doesn't exist")
+	}
+
+	// revision could have been resolved already, if the server got restarted before removing it from the queue
+	if revision.Status != engine.RevisionStatusResolving {
+		return nil
+	}
+
+	policy, _, err := pipeline.registry.GetPolicy(work.PolicyGen)
+	if err != nil {
+		return fmt.Errorf("error while getting policy: %s", err)
+	}
+
+	eventLog := event.NewLog(log.DebugLevel, fmt.Sprintf("pipeline-revision-%d", work.RevisionGen))
+	for _, hook := range pipeline.eventHooks {
+		eventLog.AddHook(hook)
+	}
+	// Claims, which can't be resolved, are reported in the resolution log and don't prevent others from being enforced
+	desiredState, resolveErr := resolve.NewPolicyResolver(policy, pipeline.externalData, eventLog).SetPluginRegistry(pipeline.pluginRegistryFactory()).SetContinueOnError(true).ResolveAllClaims(ctx)
+	err = pipeline.registry.SaveResolutionLog(revision, eventLog.AsAPIEvents())
+	if err != nil {
+		return err
+	}
+	validateErr := resolveErr
+	if validateErr == nil {
+		validateErr = desiredState.Validate(policy)
+	}
+	if validateErr != nil {
+		revision.Status = engine.RevisionStatusError
+		revision.ResolutionError = fmt.Sprintf("policy change cannot be made: %s", validateErr)
+		revision.ApplyLog = eventLog.AsAPIEvents()
+		err = pipeline.registry.UpdateRevision(revision)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%s", revision.ResolutionError)
+	}
+
+	err = pipeline.registry.SaveDesiredState(revision, desiredState)
+	if err != nil {
+		return err
+	}
+	revision.Status = engine.RevisionStatusWaiting
+	return pipeline.registry.UpdateRevision(revision)
+}
+
+// load loads the queue from the registry, if it hasn't been loaded yet. It must be called under the mutex
+func (pipeline *Pipeline) load() error {
+	if pipeline.queue != nil {
+		return nil
+	}
+	queue, err := pipeline.registry.GetResolutionQueue()
+	if err != nil {
+		return err
+	}
+	pipeline.queue = queue
+	pipeline.updateDepthMetrics()
+	return nil
+}
+
+// save saves the queue to the registry. It must be called under the mutex
+func (pipeline *Pipeline) save() error {
+	pipeline.updateDepthMetrics()
+	err := pipeline.registry.UpdateResolutionQueue(pipeline.queue)
+	if err != nil {
+		// make sure the queue gets reloaded from the registry next time
+		pipeline.queue = nil
+		return err
+	}
+	return nil
+}
+
+func (pipeline *Pipeline) updateDepthMetrics() {
+	depth := make(map[string]int)
+	for _, work := range pipeline.queue.Items {
+		depth[work.Priority]++
+	}
+	for _, priority := range engine.ResolutionPriorities {
+		mQueueDepth.WithLabelValues(priority).Set(float64(depth[priority]))
+	}
+}
+
+// less returns true if item a should be resolved before item b
+func less(a, b *engine.ResolutionWork) bool {
+	rankA, rankB := engine.ResolutionPriorityRank(a.Priority), engine.ResolutionPriorityRank(b.Priority)
+	if rankA != rankB {
+		return rankA < rankB
+	}
+	return a.RevisionGen < b.RevisionGen
+}
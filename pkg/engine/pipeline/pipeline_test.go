@@ -0,0 +1,152 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/config"
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+	"github.com/Aptomi/aptomi/pkg/plugin/fake"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipelineQueueFull(t *testing.T) {
+	reg, _, policyGen := makeRegistry(t)
+	pipeline := NewPipeline(reg, nil, nil, 2, 1)
+
+	_, err := pipeline.Enqueue(policyGen, "urgent", "admin")
+	assert.Error(t, err, "Unknown priority should be rejected")
+
+	for i := 0; i < 2; i++ {
+		_, err = pipeline.Enqueue(policyGen, engine.ResolutionPriorityGitOps, "admin")
+		assert.NoError(t, err, "Revision should be queued")
+	}
+	full, err := pipeline.IsFull()
+	assert.NoError(t, err, "Queue should be loaded")
+	assert.True(t, full, "Queue should be full")
+
+	_, err = pipeline.Enqueue(policyGen, engine.ResolutionPriorityInteractive, "admin")
+	assert.Equal(t, ErrQueueFull, err, "Revision should not be queued when queue is full")
+
+	// queue is persisted, so a new pipeline should see it as full as well
+	full, err = NewPipeline(reg, nil, nil, 2, 1).IsFull()
+	assert.NoError(t, err, "Queue should be loaded")
+	assert.True(t, full, "Persisted queue should be full")
+}
+
+func TestPipelinePriorityOrder(t *testing.T) {
+	reg, _, policyGen := makeRegistry(t)
+	pipeline := NewPipeline(reg, nil, nil, 10, 1)
+
+	gens := make(map[string][]runtime.Generation)
+	for _, priority := range []string{engine.ResolutionPriorityDrift, engine.ResolutionPriorityInteractive, engine.ResolutionPriorityGitOps, engine.ResolutionPriorityInteractive} {
+		revision, err := pipeline.Enqueue(policyGen, priority, "admin")
+		if !assert.NoError(t, err, "Revision should be queued") {
+			t.FailNow()
+		}
+		gens[priority] = append(gens[priority], revision.GetGeneration())
+	}
+
+	expected := []runtime.Generation{
+		gens[engine.ResolutionPriorityInteractive][0],
+		gens[engine.ResolutionPriorityInteractive][1],
+		gens[engine.ResolutionPriorityGitOps][0],
+		gens[engine.ResolutionPriorityDrift][0],
+	}
+	for _, gen := range expected {
+		work, err := pipeline.next()
+		assert.NoError(t, err, "Next item should be picked")
+		if assert.NotNil(t, work, "Next item should be picked") {
+			assert.Equal(t, gen, work.RevisionGen, "Items should be picked by priority first and then in the order they have been queued")
+		}
+	}
+
+	work, err := pipeline.next()
+	assert.NoError(t, err, "Next item should be picked")
+	assert.Nil(t, work, "Items being resolved should not be picked again")
+
+	status, err := pipeline.Status()
+	assert.NoError(t, err, "Status should be returned")
+	assert.Equal(t, 4, status.InFlight, "All items should be in flight")
+	assert.Equal(t, map[string]int{engine.ResolutionPriorityInteractive: 2, engine.ResolutionPriorityGitOps: 1, engine.ResolutionPriorityDrift: 1}, status.Depth, "Depth should be reported by priority")
+}
+
+func TestPipelineResolve(t *testing.T) {
+	reg, b, policyGen := makeRegistry(t)
+	resolved := 0
+	pipeline := NewPipeline(reg, b.External(), pluginRegistryFactory, 10, 1).SetResolvedHook(func() { resolved++ })
+
+	revision, err := pipeline.Enqueue(policyGen, engine.ResolutionPriorityInteractive, "admin")
+	if !assert.NoError(t, err, "Revision should be queued") {
+		t.FailNow()
+	}
+	assert.Equal(t, engine.RevisionStatusResolving, revision.Status, "Queued revision should be in resolving status")
+
+	work, err := pipeline.next()
+	if !assert.NoError(t, err, "Next item should be picked") || !assert.NotNil(t, work, "Next item should be picked") {
+		t.FailNow()
+	}
+	pipeline.process(context.Background(), 0, work)
+	assert.Equal(t, 1, resolved, "Resolved hook should be called")
+
+	revision, err = reg.GetRevision(revision.GetGeneration())
+	assert.NoError(t, err, "Revision should be loaded")
+	assert.Equal(t, engine.RevisionStatusWaiting, revision.Status, "Resolved revision should be waiting for enforcement")
+
+	desiredState, err := reg.GetDesiredState(revision)
+	assert.NoError(t, err, "Desired state should be loaded")
+	assert.NotEmpty(t, desiredState.ComponentInstanceMap, "Desired state should be saved for resolved revision")
+
+	status, err := pipeline.Status()
+	assert.NoError(t, err, "Status should be returned")
+	assert.Equal(t, 0, status.Depth[engine.ResolutionPriorityInteractive], "Resolved item should be removed from the queue")
+	assert.Equal(t, 0, status.InFlight, "Resolved item should not be in flight")
+	assert.Equal(t, 1, status.Processed[engine.ResolutionPriorityInteractive], "Resolved item should be counted")
+}
+
+// makeRegistry creates registry on top of in-memory store with policy containing a single claim
+func makeRegistry(t *testing.T) (registry.Interface, *builder.PolicyBuilder, runtime.Generation) {
+	t.Helper()
+	reg := registry.New(memory.New(runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec()))
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	rule := b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+	claim := b.AddClaim(b.AddUser(), service)
+
+	_, policyData, err := reg.UpdatePolicy([]lang.Base{cluster, bundle, service, rule, claim}, "admin")
+	if !assert.NoError(t, err, "Policy should be updated") {
+		t.FailNow()
+	}
+
+	return reg, b, policyData.GetGeneration()
+}
+
+func pluginRegistryFactory() plugin.Registry {
+	clusterTypes := map[string]plugin.ClusterPluginConstructor{
+		"kubernetes": func(cluster *lang.Cluster, cfg config.Plugins) (plugin.ClusterPlugin, error) {
+			return fake.NewNoOpClusterPlugin(0), nil
+		},
+	}
+	codeTypes := map[string]map[string]plugin.CodePluginConstructor{
+		"kubernetes": {
+			"helm": func(cluster plugin.ClusterPlugin, cfg config.Plugins) (plugin.CodePlugin, error) {
+				return fake.NewNoOpCodePlugin(0), nil
+			},
+		},
+	}
+	return plugin.NewRegistry(config.Plugins{}, clusterTypes, codeTypes)
+}
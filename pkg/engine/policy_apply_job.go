@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/engine/apply/action"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+const (
+	// PolicyApplyJobStatePending represents PolicyApplyJob state when objects have been stored, but policy resolution hasn't started yet
+	PolicyApplyJobStatePending = "pending"
+	// PolicyApplyJobStateRunning represents PolicyApplyJob state when policy is being resolved and revision is being created
+	PolicyApplyJobStateRunning = "running"
+	// PolicyApplyJobStateSucceeded represents PolicyApplyJob state when revision for the policy change has been created
+	PolicyApplyJobStateSucceeded = "succeeded"
+	// PolicyApplyJobStateFailed represents PolicyApplyJob state when policy change can't be resolved
+	PolicyApplyJobStateFailed = "failed"
+)
+
+// TypePolicyApplyJob is an informational data structure with Kind and Constructor for PolicyApplyJob
+var TypePolicyApplyJob = &runtime.TypeInfo{
+	Kind:        "policy-apply-job",
+	Storable:    true,
+	Versioned:   false,
+	Constructor: func() runtime.Object { return &PolicyApplyJob{} },
+}
+
+// PolicyApplyJob tracks asynchronous policy change. Objects get stored in the policy right away, while the policy gets
+// resolved and the revision gets created in the background, so the outcome could be polled via API by the job ID.
+// Finished jobs get removed once they expire
+type PolicyApplyJob struct {
+	runtime.TypeKind `yaml:",inline"`
+
+	ID        string
+	State     string
+	CreatedBy string
+
+	CreatedAt  time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
+	ExpiresAt  time.Time
+
+	// Progress describes the step the job is currently making
+	Progress string
+
+	// Error is the reason why policy change failed
+	Error string `yaml:",omitempty"`
+
+	// PolicyGen is the policy generation with the stored objects
+	PolicyGen     runtime.Generation
+	PolicyChanged bool
+
+	// Result is the outcome of the policy change, which is set once the job succeeds
+	Result *PolicyApplyJobResult `yaml:",omitempty"`
+}
+
+// PolicyApplyJobResult is the outcome of successful asynchronous policy change
+type PolicyApplyJobResult struct {
+	RevisionGen   runtime.Generation
+	EnforcementID runtime.Generation `yaml:",omitempty"`
+	PlanAsText    *action.PlanAsText
+	EventLog      []*event.APIEvent
+
+	ClaimsResolved int      `yaml:",omitempty"`
+	ClaimsTotal    int      `yaml:",omitempty"`
+	FailedClaims   []string `yaml:",omitempty"`
+}
+
+// NewPolicyApplyJob creates a new pending PolicyApplyJob with a given ID
+func NewPolicyApplyJob(id string, createdBy string, createdAt time.Time) *PolicyApplyJob {
+	return &PolicyApplyJob{
+		TypeKind:  TypePolicyApplyJob.GetTypeKind(),
+		ID:        id,
+		State:     PolicyApplyJobStatePending,
+		CreatedBy: createdBy,
+		CreatedAt: createdAt,
+		Progress:  "waiting for policy resolution",
+	}
+}
+
+// IsFinished returns true if job will not be processed any further
+func (job *PolicyApplyJob) IsFinished() bool {
+	return job.State == PolicyApplyJobStateSucceeded || job.State == PolicyApplyJobStateFailed
+}
+
+// IsExpired returns true if job has been finished and its retention period is over at a given time
+func (job *PolicyApplyJob) IsExpired(now time.Time) bool {
+	return job.IsFinished() && !job.ExpiresAt.IsZero() && !now.Before(job.ExpiresAt)
+}
+
+// GetName returns PolicyApplyJob name
+func (job *PolicyApplyJob) GetName() string {
+	return job.ID
+}
+
+// GetNamespace returns PolicyApplyJob namespace
+func (job *PolicyApplyJob) GetNamespace() string {
+	return runtime.SystemNS
+}
+
+// GetDefaultColumns returns default set of columns to be displayed
+func (job *PolicyApplyJob) GetDefaultColumns() []string {
+	return []string{"Job", "Policy Generation", "State", "Progress", "Created", "Finished"}
+}
+
+// AsColumns returns PolicyApplyJob representation as columns
+func (job *PolicyApplyJob) AsColumns() map[string]string {
+	progress := job.Progress
+	if len(job.Error) > 0 {
+		progress = job.Error
+	}
+	return map[string]string{
+		"Job":               job.ID,
+		"Policy Generation": job.PolicyGen.String(),
+		"State":             job.State,
+		"Progress":          progress,
+		"Created":           formatTime(job.CreatedAt),
+		"Started":           formatTime(job.StartedAt),
+		"Finished":          formatTime(job.FinishedAt),
+	}
+}
@@ -0,0 +1,4 @@
+// Package reconcile implements post-restore reconciliation of actual state. After registry gets restored from a
+// backup, actual state describes the world as of the backup time. Reconciler queries clusters for every component
+// instance in actual state and corrects actual state to match reality before any enforcement happens.
+package reconcile
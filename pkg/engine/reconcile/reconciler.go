@@ -0,0 +1,214 @@
+package reconcile
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/actual"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+)
+
+// Reconciler checks component instances in actual state against clusters and corrects actual state to match reality
+type Reconciler struct {
+	policy               *lang.Policy
+	actualState          *resolve.PolicyResolution
+	actualStateUpdater   actual.StateUpdater
+	plugins              plugin.Registry
+	eventLog             *event.Log
+	maxConcurrentQueries int
+
+	clusterMutex  sync.Mutex
+	clusterErrors map[string]error
+}
+
+// NewReconciler creates a new Reconciler. Clusters get queried in parallel, but no more than maxConcurrentQueries
+// at the same time
+func NewReconciler(policy *lang.Policy, actualState *resolve.PolicyResolution, actualStateUpdater actual.StateUpdater, plugins plugin.Registry, eventLog *event.Log, maxConcurrentQueries int) *Reconciler {
+	if maxConcurrentQueries <= 0 {
+		maxConcurrentQueries = 1
+	}
+	return &Reconciler{
+		policy:               policy,
+		actualState:          actualState,
+		actualStateUpdater:   actualStateUpdater,
+		plugins:              plugins,
+		eventLog:             eventLog,
+		maxConcurrentQueries: maxConcurrentQueries,
+		clusterErrors:        make(map[string]error),
+	}
+}
+
+// Reconcile checks all component instances in actual state, which haven't been checked yet, corrects actual state
+// and records corrections in a given reconciliation. Progress is saved after every checked instance, so reconciliation
+// can be resumed if interrupted. Once all instances are checked, reconciliation either gets completed or starts
+// waiting for operator acknowledgment, if the number of corrections exceeds the threshold
+func (reconciler *Reconciler) Reconcile(reconciliation *engine.Reconciliation, save func(*engine.Reconciliation) error) error {
+	if reconciliation.Checked == nil {
+		reconciliation.Checked = make(map[string]bool)
+	}
+	reconciliation.Status = engine.ReconciliationStatusInProgress
+	err := save(reconciliation)
+	if err != nil {
+		return err
+	}
+
+	// collect instances to be checked upfront, as actual state will be modified while checking
+	keys := make([]string, 0, len(reconciler.actualState.ComponentInstanceMap))
+	for key := range reconciler.actualState.ComponentInstanceMap {
+		if !reconciliation.Checked[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	instances := make([]*resolve.ComponentInstance, 0, len(keys))
+	for _, key := range keys {
+		instances = append(instances, reconciler.actualState.ComponentInstanceMap[key])
+	}
+	reconciler.eventLog.NewEntry().Infof("Reconciliation: checking %d component instances (%d checked before)", len(instances), len(reconciliation.Checked))
+
+	var mutex sync.Mutex
+	var saveErr error
+	var wg sync.WaitGroup
+	semaphore := make(chan bool, reconciler.maxConcurrentQueries)
+	for i := range instances {
+		semaphore <- true
+		wg.Add(1)
+		go func(key string, instance *resolve.ComponentInstance) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			correction := reconciler.check(key, instance)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if correction != nil {
+				reconciler.eventLog.NewEntry().Warningf("Reconciliation: component instance '%s' is %s (%s)", key, correction.Type, correction.Details)
+				reconciliation.Corrections = append(reconciliation.Corrections, correction)
+			}
+			reconciliation.Checked[key] = true
+			if err := save(reconciliation); err != nil && saveErr == nil {
+				saveErr = err
+			}
+		}(keys[i], instances[i])
+	}
+	wg.Wait()
+
+	if saveErr != nil {
+		return fmt.Errorf("error while saving reconciliation progress: %s", saveErr)
+	}
+
+	if len(reconciliation.Corrections) > reconciliation.Threshold {
+		reconciler.eventLog.NewEntry().Warningf("Reconciliation: %d corrections made, which exceeds the threshold of %d. Waiting for acknowledgment", len(reconciliation.Corrections), reconciliation.Threshold)
+		reconciliation.Status = engine.ReconciliationStatusWaitingForAck
+	} else {
+		reconciler.eventLog.NewEntry().Infof("Reconciliation: completed with %d corrections", len(reconciliation.Corrections))
+		reconciliation.Status = engine.ReconciliationStatusCompleted
+		reconciliation.CompletedAt = time.Now()
+	}
+
+	return save(reconciliation)
+}
+
+// check queries the cluster for a given component instance and corrects actual state if needed. It returns
+// correction made or nil if actual state matches reality
+func (reconciler *Reconciler) check(key string, instance *resolve.ComponentInstance) *engine.Correction {
+	// only code components get deployed to clusters
+	if !instance.IsCode {
+		return nil
+	}
+
+	clusterObj, err := reconciler.policy.GetObject(lang.TypeCluster.Kind, instance.Metadata.Key.ClusterName, instance.Metadata.Key.ClusterNameSpace)
+	if err != nil || clusterObj == nil {
+		return newCorrection(key, engine.CorrectionUnknown, "cluster '%s/%s' is not present in policy", instance.Metadata.Key.ClusterNameSpace, instance.Metadata.Key.ClusterName)
+	}
+	cluster := clusterObj.(*lang.Cluster) // nolint: errcheck
+
+	// if cluster can't be reached, there is no way to tell whether the instance is there or not
+	if err := reconciler.validateCluster(cluster); err != nil {
+		return newCorrection(key, engine.CorrectionUnknown, "cluster '%s' can't be reached: %s", cluster.Name, err)
+	}
+
+	bundleObj, err := reconciler.policy.GetObject(lang.TypeBundle.Kind, instance.Metadata.Key.BundleName, instance.Metadata.Key.Namespace)
+	if err != nil || bundleObj == nil {
+		return newCorrection(key, engine.CorrectionUnknown, "bundle '%s/%s' is not present in policy", instance.Metadata.Key.Namespace, instance.Metadata.Key.BundleName)
+	}
+	component := bundleObj.(*lang.Bundle).GetComponentsMap()[instance.Metadata.Key.ComponentName] // nolint: errcheck
+	if component == nil || component.Code == nil {
+		return newCorrection(key, engine.CorrectionUnknown, "code component '%s' is not present in bundle '%s'", instance.Metadata.Key.ComponentName, instance.Metadata.Key.BundleName)
+	}
+
+	p, err := reconciler.plugins.ForCodeType(cluster, component.Code.Type)
+	if err != nil {
+		return newCorrection(key, engine.CorrectionUnknown, "can't get plugin for code type '%s': %s", component.Code.Type, err)
+	}
+
+	params := &plugin.CodePluginInvocationParams{
+		DeployName:   instance.GetDeployName(),
+		Params:       instance.CalculatedCodeParams,
+		PluginParams: map[string]string{plugin.ParamTargetSuffix: instance.Metadata.Key.TargetSuffix},
+		EventLog:     reconciler.eventLog,
+	}
+
+	// cluster is reachable, but instance can't be found there. remove it from actual state, so it gets re-created
+	_, err = p.Status(params)
+	if err != nil {
+		if errDelete := reconciler.actualStateUpdater.DeleteComponentInstance(key); errDelete != nil {
+			return newCorrection(key, engine.CorrectionUnknown, "instance is missing (%s), but it can't be removed from actual state: %s", err, errDelete)
+		}
+		return newCorrection(key, engine.CorrectionMissing, "%s", err)
+	}
+
+	// instance is there, let's see if it has changed
+	endpoints, err := p.Endpoints(params)
+	if err != nil {
+		return newCorrection(key, engine.CorrectionUnknown, "can't get endpoints: %s", err)
+	}
+	prevEndpoints := instance.Endpoints
+	if (len(endpoints) == 0 && len(prevEndpoints) == 0) || reflect.DeepEqual(endpoints, prevEndpoints) {
+		return nil
+	}
+
+	errUpdate := reconciler.actualStateUpdater.UpdateComponentInstance(key, func(obj *resolve.ComponentInstance) {
+		obj.EndpointsUpToDate = true
+		obj.Endpoints = endpoints
+	})
+	if errUpdate != nil {
+		return newCorrection(key, engine.CorrectionUnknown, "endpoints have changed, but they can't be updated in actual state: %s", errUpdate)
+	}
+	return newCorrection(key, engine.CorrectionChanged, "endpoints have changed from %v to %v", prevEndpoints, endpoints)
+}
+
+// validateCluster validates a given cluster via its plugin. Every cluster gets validated only once
+func (reconciler *Reconciler) validateCluster(cluster *lang.Cluster) error {
+	reconciler.clusterMutex.Lock()
+	defer reconciler.clusterMutex.Unlock()
+
+	if err, ok := reconciler.clusterErrors[cluster.Name]; ok {
+		return err
+	}
+
+	p, err := reconciler.plugins.ForCluster(cluster)
+	if err == nil {
+		err = p.Validate()
+	}
+	reconciler.clusterErrors[cluster.Name] = err
+
+	return err
+}
+
+func newCorrection(key string, correctionType string, format string, args ...interface{}) *engine.Correction {
+	return &engine.Correction{
+		ComponentKey: key,
+		Type:         correctionType,
+		Details:      fmt.Sprintf(format, args...),
+	}
+}
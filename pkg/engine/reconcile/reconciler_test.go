@@ -0,0 +1,194 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/config"
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/actual"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+	"github.com/Aptomi/aptomi/pkg/plugin/fake"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconcilerCorrectsActualState(t *testing.T) {
+	b, actualState, keys := makeActualState(t, 4)
+	codePlugin := &clusterStatePlugin{
+		missing:      map[string]bool{actualState.ComponentInstanceMap[keys[1]].GetDeployName(): true},
+		endpoints:    map[string]map[string]string{actualState.ComponentInstanceMap[keys[2]].GetDeployName(): {"http": "http://10.0.0.1:80"}},
+		endpointsErr: map[string]bool{actualState.ComponentInstanceMap[keys[3]].GetDeployName(): true},
+		queried:      make(map[string]int),
+	}
+
+	// first instance has been checked before interruption, so it shouldn't be queried again
+	reconciliation := engine.NewReconciliation(time.Now(), "admin", 2)
+	reconciliation.Checked[keys[0]] = true
+
+	saved := 0
+	err := newReconciler(b, actualState, codePlugin).Reconcile(reconciliation, func(*engine.Reconciliation) error {
+		saved++
+		return nil
+	})
+	assert.NoError(t, err, "Reconciliation should complete without errors")
+	assert.Equal(t, 0, codePlugin.queried[actualState.ComponentInstanceMap[keys[0]].GetDeployName()], "Checked instance should not be queried again")
+	assert.True(t, saved > len(keys), "Progress should be saved after every instance")
+
+	// all corrections should be recorded
+	corrections := make(map[string]string)
+	for _, correction := range reconciliation.Corrections {
+		corrections[correction.ComponentKey] = correction.Type
+	}
+	assert.Equal(t, map[string]string{
+		keys[1]: engine.CorrectionMissing,
+		keys[2]: engine.CorrectionChanged,
+		keys[3]: engine.CorrectionUnknown,
+	}, corrections, "Corrections should be recorded")
+
+	// actual state should be corrected
+	assert.Nil(t, actualState.ComponentInstanceMap[keys[1]], "Missing instance should be removed from actual state")
+	assert.Equal(t, map[string]string{"http": "http://10.0.0.1:80"}, actualState.ComponentInstanceMap[keys[2]].Endpoints, "Changed endpoints should be updated in actual state")
+	assert.NotNil(t, actualState.ComponentInstanceMap[keys[3]], "Unknown instance should stay in actual state")
+
+	// 3 corrections exceed the threshold of 2
+	assert.Equal(t, engine.ReconciliationStatusWaitingForAck, reconciliation.Status, "Reconciliation should wait for acknowledgment")
+	assert.True(t, reconciliation.BlocksEnforcement(), "Enforcement should be blocked")
+}
+
+func TestReconcilerCompletesUnderThreshold(t *testing.T) {
+	b, actualState, keys := makeActualState(t, 2)
+	codePlugin := &clusterStatePlugin{
+		missing: map[string]bool{actualState.ComponentInstanceMap[keys[0]].GetDeployName(): true},
+		queried: make(map[string]int),
+	}
+
+	reconciliation := engine.NewReconciliation(time.Now(), "admin", 1)
+	err := newReconciler(b, actualState, codePlugin).Reconcile(reconciliation, func(*engine.Reconciliation) error { return nil })
+	assert.NoError(t, err, "Reconciliation should complete without errors")
+	assert.Len(t, reconciliation.Corrections, 1, "Single correction should be made")
+	assert.Equal(t, engine.ReconciliationStatusCompleted, reconciliation.Status, "Reconciliation should be completed")
+	assert.False(t, reconciliation.BlocksEnforcement(), "Enforcement should not be blocked")
+}
+
+func TestReconcilerSaveError(t *testing.T) {
+	b, actualState, _ := makeActualState(t, 2)
+	codePlugin := &clusterStatePlugin{queried: make(map[string]int)}
+
+	reconciliation := engine.NewReconciliation(time.Now(), "admin", 1)
+	err := newReconciler(b, actualState, codePlugin).Reconcile(reconciliation, func(*engine.Reconciliation) error { return fmt.Errorf("store is down") })
+	assert.Error(t, err, "Reconciliation should fail if progress can't be saved")
+}
+
+// makeActualState creates policy with a given number of claims, each resolving into its own code instance, and returns
+// its resolution as actual state along with the sorted keys of code instances
+func makeActualState(t *testing.T, claims int) (*builder.PolicyBuilder, *resolve.PolicyResolution, []string) {
+	t.Helper()
+	b := builder.NewPolicyBuilder()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	service.Contexts[0].Allocation.Keys = b.AllocationKeys("{{ .Claim.ID }}")
+	cluster := b.AddCluster()
+	b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+	for i := 0; i < claims; i++ {
+		b.AddClaim(b.AddUser(), service)
+	}
+
+	eventLog := event.NewLog(logrus.WarnLevel, "test-resolve")
+	actualState, resolveErr := resolve.NewPolicyResolver(b.Policy(), b.External(), eventLog).ResolveAllClaims(context.Background())
+	if !assert.NoError(t, resolveErr, "Policy should be resolved without errors") {
+		t.FailNow()
+	}
+	if !assert.NoError(t, actualState.Validate(b.Policy()), "Policy should be resolved without errors") {
+		t.FailNow()
+	}
+
+	keys := []string{}
+	for key, instance := range actualState.ComponentInstanceMap {
+		if instance.IsCode {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	if !assert.Len(t, keys, claims, "Every claim should have its own code instance") {
+		t.FailNow()
+	}
+
+	return b, actualState, keys
+}
+
+func newReconciler(b *builder.PolicyBuilder, actualState *resolve.PolicyResolution, codePlugin plugin.CodePlugin) *Reconciler {
+	plugins := plugin.NewRegistry(
+		config.Plugins{},
+		map[string]plugin.ClusterPluginConstructor{
+			"kubernetes": func(cluster *lang.Cluster, cfg config.Plugins) (plugin.ClusterPlugin, error) {
+				return fake.NewNoOpClusterPlugin(0), nil
+			},
+		},
+		map[string]map[string]plugin.CodePluginConstructor{
+			"kubernetes": {
+				"helm": func(cluster plugin.ClusterPlugin, cfg config.Plugins) (plugin.CodePlugin, error) {
+					return codePlugin, nil
+				},
+			},
+		},
+	)
+	eventLog := event.NewLog(logrus.WarnLevel, "test-reconcile")
+	return NewReconciler(b.Policy(), actualState, actual.NewNoOpActionStateUpdater(actualState), plugins, eventLog, 2)
+}
+
+// clusterStatePlugin is a code plugin, which reports state of instances in the cluster by their deploy names
+type clusterStatePlugin struct {
+	mutex        sync.Mutex
+	missing      map[string]bool
+	endpoints    map[string]map[string]string
+	endpointsErr map[string]bool
+	queried      map[string]int
+}
+
+func (p *clusterStatePlugin) Cleanup() error {
+	return nil
+}
+
+func (p *clusterStatePlugin) Create(invocation *plugin.CodePluginInvocationParams) error {
+	return nil
+}
+
+func (p *clusterStatePlugin) Update(invocation *plugin.CodePluginInvocationParams) error {
+	return nil
+}
+
+func (p *clusterStatePlugin) Destroy(invocation *plugin.CodePluginInvocationParams) error {
+	return nil
+}
+
+func (p *clusterStatePlugin) Endpoints(invocation *plugin.CodePluginInvocationParams) (map[string]string, error) {
+	if p.endpointsErr[invocation.DeployName] {
+		return nil, fmt.Errorf("timeout while getting endpoints")
+	}
+	return p.endpoints[invocation.DeployName], nil
+}
+
+func (p *clusterStatePlugin) Resources(invocation *plugin.CodePluginInvocationParams) (plugin.Resources, error) {
+	return nil, nil
+}
+
+func (p *clusterStatePlugin) Status(invocation *plugin.CodePluginInvocationParams) (bool, error) {
+	p.mutex.Lock()
+	p.queried[invocation.DeployName]++
+	p.mutex.Unlock()
+
+	if p.missing[invocation.DeployName] {
+		return false, fmt.Errorf("release %s not found", invocation.DeployName)
+	}
+	return true, nil
+}
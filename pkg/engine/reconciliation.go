@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+const (
+	// ReconciliationStatusPending represents Reconciliation status when registry has been restored, but actual state hasn't been checked yet
+	ReconciliationStatusPending = "pending"
+	// ReconciliationStatusInProgress represents Reconciliation status when actual state is being checked against clusters
+	ReconciliationStatusInProgress = "inprogress"
+	// ReconciliationStatusWaitingForAck represents Reconciliation status when too many corrections were made and operator has to acknowledge them
+	ReconciliationStatusWaitingForAck = "waitingforack"
+	// ReconciliationStatusCompleted represents Reconciliation status when enforcement is allowed to proceed
+	ReconciliationStatusCompleted = "completed"
+)
+
+const (
+	// CorrectionMissing means that component instance is present in actual state, but it's missing in the cluster
+	CorrectionMissing = "missing"
+	// CorrectionChanged means that component instance in the cluster differs from the one in actual state (e.g. endpoints)
+	CorrectionChanged = "changed"
+	// CorrectionUnknown means that component instance can't be checked (e.g. cluster is unreachable)
+	CorrectionUnknown = "unknown"
+)
+
+// ReconciliationKey is the default key for the Reconciliation object (there is only one Reconciliation)
+var ReconciliationKey = runtime.KeyFromParts(runtime.SystemNS, TypeReconciliation.Kind, runtime.EmptyName)
+
+// TypeReconciliation is TypeInfo for Reconciliation
+var TypeReconciliation = &runtime.TypeInfo{
+	Kind:        "reconciliation",
+	Storable:    true,
+	Versioned:   false,
+	Constructor: func() runtime.Object { return &Reconciliation{} },
+}
+
+// Reconciliation is a marker written after registry gets restored from a backup. Actual state in the registry
+// describes the world as of the backup time, so before the first enforcement all component instances in actual state
+// get checked against clusters and actual state gets corrected. Reconciliation holds the progress of this process,
+// so it could be resumed if interrupted, as well as the report of all corrections made
+type Reconciliation struct {
+	runtime.TypeKind `yaml:",inline"`
+
+	Status     string
+	RestoredAt time.Time
+	RestoredBy string
+
+	// Threshold is the max number of corrections which don't require operator acknowledgment
+	Threshold int
+
+	// Checked is a set of component instance keys, which have been checked already
+	Checked map[string]bool
+
+	// Corrections is a list of corrections made to actual state
+	Corrections []*Correction
+
+	AcknowledgedBy string
+	CompletedAt    time.Time
+
+	// Applied is true once corrected actual state has been handed over to enforcement
+	Applied bool
+}
+
+// Correction is a single correction made to actual state during reconciliation
+type Correction struct {
+	ComponentKey string
+	Type         string
+	Details      string
+}
+
+// NewReconciliation creates a new pending reconciliation
+func NewReconciliation(restoredAt time.Time, restoredBy string, threshold int) *Reconciliation {
+	return &Reconciliation{
+		TypeKind:    TypeReconciliation.GetTypeKind(),
+		Status:      ReconciliationStatusPending,
+		RestoredAt:  restoredAt,
+		RestoredBy:  restoredBy,
+		Threshold:   threshold,
+		Checked:     make(map[string]bool),
+		Corrections: []*Correction{},
+	}
+}
+
+// GetName returns Reconciliation name
+func (reconciliation *Reconciliation) GetName() string {
+	return runtime.EmptyName
+}
+
+// GetNamespace returns Reconciliation namespace
+func (reconciliation *Reconciliation) GetNamespace() string {
+	return runtime.SystemNS
+}
+
+// BlocksEnforcement returns true if enforcement isn't allowed until reconciliation completes
+func (reconciliation *Reconciliation) BlocksEnforcement() bool {
+	return reconciliation.Status != ReconciliationStatusCompleted
+}
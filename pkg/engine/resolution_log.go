@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/sirupsen/logrus"
+)
+
+// TypeResolutionLog is an informational data structure with Kind and Constructor for ResolutionLog
+var TypeResolutionLog = &runtime.TypeInfo{
+	Kind:        "resolution-log",
+	Storable:    true,
+	Versioned:   false,
+	Constructor: func() runtime.Object { return &ResolutionLog{} },
+}
+
+// ResolutionLog represents event log of policy resolution, which produced desired state of specific revision
+type ResolutionLog struct {
+	runtime.TypeKind `yaml:",inline"`
+
+	RevisionGen runtime.Generation
+	Events      []*event.APIEvent
+}
+
+// NewResolutionLog creates new ResolutionLog instance from revision and resolution events
+func NewResolutionLog(revision *Revision, events []*event.APIEvent) *ResolutionLog {
+	return &ResolutionLog{
+		TypeKind:    TypeResolutionLog.GetTypeKind(),
+		RevisionGen: revision.GetGeneration(),
+		Events:      events,
+	}
+}
+
+// GetName returns name of the ResolutionLog
+func (resolutionLog *ResolutionLog) GetName() string {
+	return GetResolutionLogName(resolutionLog.RevisionGen)
+}
+
+// GetNamespace returns namespace of the ResolutionLog
+func (resolutionLog *ResolutionLog) GetNamespace() string {
+	return runtime.SystemNS
+}
+
+// GetDefaultColumns returns default set of columns of resolution log events
+func (resolutionLog *ResolutionLog) GetDefaultColumns() []string {
+	return (&event.APIEvent{}).GetDefaultColumns()
+}
+
+// AsDisplayableList returns resolution log events as a list of displayable objects, so they could be rendered as table
+func (resolutionLog *ResolutionLog) AsDisplayableList() []runtime.Displayable {
+	result := make([]runtime.Displayable, 0, len(resolutionLog.Events))
+	for _, e := range resolutionLog.Events {
+		result = append(result, e)
+	}
+
+	return result
+}
+
+// Filter returns a copy of ResolutionLog with events of a given level (or more severe) and with sequence numbers
+// greater than afterSeq
+func (resolutionLog *ResolutionLog) Filter(level logrus.Level, afterSeq int) *ResolutionLog {
+	result := &ResolutionLog{
+		TypeKind:    resolutionLog.TypeKind,
+		RevisionGen: resolutionLog.RevisionGen,
+		Events:      []*event.APIEvent{},
+	}
+	for _, e := range event.FilterAPIEvents(resolutionLog.Events, level) {
+		if e.Seq > afterSeq {
+			result.Events = append(result.Events, e)
+		}
+	}
+	return result
+}
+
+// GetResolutionLogName returns name of the ResolutionLog for specific Revision generation
+func GetResolutionLogName(revisionGen runtime.Generation) string {
+	return fmt.Sprintf("revision-%s-resolution-log", revisionGen)
+}
@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+const (
+	// ResolutionPriorityInteractive is the priority of resolution work coming from interactive API requests
+	ResolutionPriorityInteractive = "interactive"
+	// ResolutionPriorityGitOps is the priority of resolution work coming from GitOps sync
+	ResolutionPriorityGitOps = "gitops"
+	// ResolutionPriorityDrift is the priority of resolution work triggered by drift of the actual state
+	ResolutionPriorityDrift = "drift"
+)
+
+// ResolutionPriorities is the list of all resolution priorities, from the highest to the lowest
+var ResolutionPriorities = []string{ResolutionPriorityInteractive, ResolutionPriorityGitOps, ResolutionPriorityDrift}
+
+// ResolutionPriorityRank returns rank of a given priority (lower rank goes first) or -1 if priority is unknown
+func ResolutionPriorityRank(priority string) int {
+	for rank, p := range ResolutionPriorities {
+		if p == priority {
+			return rank
+		}
+	}
+	return -1
+}
+
+// ResolutionQueueKey is the default key for the ResolutionQueue object (there is only one ResolutionQueue)
+var ResolutionQueueKey = runtime.KeyFromParts(runtime.SystemNS, TypeResolutionQueue.Kind, runtime.EmptyName)
+
+// TypeResolutionQueue is TypeInfo for ResolutionQueue
+var TypeResolutionQueue = &runtime.TypeInfo{
+	Kind:        "resolution-queue",
+	Storable:    true,
+	Versioned:   false,
+	Constructor: func() runtime.Object { return &ResolutionQueue{} },
+}
+
+// ResolutionQueue is a persisted queue of policy generations, which have been committed, but desired state for
+// them hasn't been calculated yet. Every item has a corresponding revision in resolving status, so the queue
+// survives server restarts
+type ResolutionQueue struct {
+	runtime.TypeKind `yaml:",inline"`
+
+	Items []*ResolutionWork
+}
+
+// ResolutionWork is a single item in the resolution queue
+type ResolutionWork struct {
+	RevisionGen runtime.Generation
+	PolicyGen   runtime.Generation
+	Priority    string
+	EnqueuedAt  time.Time
+	EnqueuedBy  string
+}
+
+// NewResolutionQueue creates a new empty resolution queue
+func NewResolutionQueue() *ResolutionQueue {
+	return &ResolutionQueue{
+		TypeKind: TypeResolutionQueue.GetTypeKind(),
+		Items:    []*ResolutionWork{},
+	}
+}
+
+// GetName returns ResolutionQueue name
+func (queue *ResolutionQueue) GetName() string {
+	return runtime.EmptyName
+}
+
+// GetNamespace returns ResolutionQueue namespace
+func (queue *ResolutionQueue) GetNamespace() string {
+	return runtime.SystemNS
+}
@@ -0,0 +1,35 @@
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/engine/enginetest"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/sirupsen/logrus"
+)
+
+func BenchmarkResolveAllClaimsSmall(b *testing.B) {
+	benchmarkResolveAllClaims(b, enginetest.SyntheticPolicySmall)
+}
+
+func BenchmarkResolveAllClaimsLarge(b *testing.B) {
+	benchmarkResolveAllClaims(b, enginetest.SyntheticPolicyLarge)
+}
+
+func benchmarkResolveAllClaims(b *testing.B, params enginetest.SyntheticPolicyParams) {
+	synthetic := enginetest.NewSyntheticPolicy(params)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		eventLog := event.NewLog(logrus.WarnLevel, "bench-resolve")
+		resolution, resolveErr := NewPolicyResolver(synthetic.Policy, synthetic.External, eventLog).SetContinueOnError(true).ResolveAllClaims(context.Background())
+		if resolveErr != nil {
+			b.Fatalf("policy should be resolved without errors: %s", resolveErr)
+		}
+		if err := resolution.Validate(synthetic.Policy); err != nil {
+			b.Fatalf("synthetic policy should be resolved without errors: %s", err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package resolve
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	mCacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "aptomi_resolution_cache_requests_total",
+			Help:        "Number of policy resolution cache lookups labeled with result (hit or miss).",
+			ConstLabels: prometheus.Labels{"service": "aptomi"},
+		},
+		[]string{"result"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(mCacheRequests)
+}
+
+// CacheKey identifies PolicyResolution of a given policy generation calculated with a given version of external data
+type CacheKey struct {
+	PolicyGen           runtime.Generation
+	ExternalDataVersion uint64
+}
+
+// CachedResolution is PolicyResolution stored in Cache along with events logged while it was calculated, so they
+// could be replayed every time cached resolution is used
+type CachedResolution struct {
+	Resolution *PolicyResolution
+
+	// Events are the events logged while calculating the resolution
+	Events []*event.APIEvent
+
+	// LogLevel is the level of event log, which the events were logged to. Less severe events are not included
+	LogLevel logrus.Level
+}
+
+type cacheEntry struct {
+	key    CacheKey
+	cached *CachedResolution
+}
+
+// Cache is a bounded cache of PolicyResolution keyed by policy generation and version of external data. Once it's
+// full, the least recently used resolution gets evicted. Cached resolutions are shared between all callers, so they
+// must not be modified. Nil Cache is valid and never caches anything
+type Cache struct {
+	mutex   sync.Mutex
+	maxSize int
+	entries map[CacheKey]*list.Element
+	order   *list.List
+}
+
+// NewCache creates a new Cache holding at most maxSize resolutions
+func NewCache(maxSize int) *Cache {
+	return &Cache{
+		maxSize: maxSize,
+		entries: make(map[CacheKey]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Get returns cached PolicyResolution for a given key and true if it's found. Resolution is only returned if its events
+// have been logged with a given log level or a more verbose one, so no events are missing when they are replayed
+func (cache *Cache) Get(key CacheKey, logLevel logrus.Level) (*CachedResolution, bool) {
+	if cache == nil {
+		return nil, false
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	elem, ok := cache.entries[key]
+	if !ok || elem.Value.(*cacheEntry).cached.LogLevel < logLevel {
+		mCacheRequests.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+
+	mCacheRequests.WithLabelValues("hit").Inc()
+	cache.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).cached, true
+}
+
+// Put stores PolicyResolution for a given key, evicting the least recently used one if cache is full
+func (cache *Cache) Put(key CacheKey, cached *CachedResolution) {
+	if cache == nil || cache.maxSize <= 0 {
+		return
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if elem, ok := cache.entries[key]; ok {
+		elem.Value.(*cacheEntry).cached = cached
+		cache.order.MoveToFront(elem)
+		return
+	}
+
+	cache.entries[key] = cache.order.PushFront(&cacheEntry{key: key, cached: cached})
+	for cache.order.Len() > cache.maxSize {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Len returns the number of cached resolutions
+func (cache *Cache) Len() int {
+	if cache == nil {
+		return 0
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	return cache.order.Len()
+}
@@ -0,0 +1,46 @@
+package resolve
+
+import (
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	hits := testutil.ToFloat64(mCacheRequests.WithLabelValues("hit"))
+	misses := testutil.ToFloat64(mCacheRequests.WithLabelValues("miss"))
+
+	cache := NewCache(2)
+	first := &CachedResolution{Resolution: NewPolicyResolution(), Events: []*event.APIEvent{{Message: "first"}}, LogLevel: logrus.InfoLevel}
+	second := &CachedResolution{Resolution: NewPolicyResolution(), LogLevel: logrus.InfoLevel}
+	third := &CachedResolution{Resolution: NewPolicyResolution(), LogLevel: logrus.InfoLevel}
+	cache.Put(CacheKey{PolicyGen: 1}, first)
+	cache.Put(CacheKey{PolicyGen: 2}, second)
+
+	cached, ok := cache.Get(CacheKey{PolicyGen: 1}, logrus.WarnLevel)
+	assert.True(t, ok, "Cached resolution should be found")
+	assert.True(t, first == cached, "Cached resolution should be returned as is along with its events")
+	_, ok = cache.Get(CacheKey{PolicyGen: 1, ExternalDataVersion: 1}, logrus.WarnLevel)
+	assert.False(t, ok, "Resolution calculated with another version of external data shouldn't be found")
+	_, ok = cache.Get(CacheKey{PolicyGen: 1}, logrus.DebugLevel)
+	assert.False(t, ok, "Resolution with events logged at less verbose level shouldn't be found")
+
+	// the least recently used resolution should be evicted
+	cache.Put(CacheKey{PolicyGen: 3}, third)
+	assert.Equal(t, 2, cache.Len(), "Cache size should be bounded")
+	_, ok = cache.Get(CacheKey{PolicyGen: 2}, logrus.InfoLevel)
+	assert.False(t, ok, "The least recently used resolution should be evicted")
+	_, ok = cache.Get(CacheKey{PolicyGen: 1}, logrus.InfoLevel)
+	assert.True(t, ok, "Recently used resolution should be kept")
+
+	assert.Equal(t, hits+2, testutil.ToFloat64(mCacheRequests.WithLabelValues("hit")), "Cache hits should be counted")
+	assert.Equal(t, misses+3, testutil.ToFloat64(mCacheRequests.WithLabelValues("miss")), "Cache misses should be counted")
+
+	var disabled *Cache
+	disabled.Put(CacheKey{PolicyGen: 1}, first)
+	_, ok = disabled.Get(CacheKey{PolicyGen: 1}, logrus.InfoLevel)
+	assert.False(t, ok, "Nil cache shouldn't cache anything")
+}
@@ -0,0 +1,161 @@
+package resolve
+
+import (
+	"sort"
+
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+// CapacityReport shows whether a set of claims fits into the capacity of the clusters, which they are resolved to
+type CapacityReport struct {
+	// Claims is the total number of claims in question
+	Claims int
+
+	// ClaimsFit is the number of claims, which have been resolved and fit into the capacity of the clusters
+	ClaimsFit int
+
+	// ClaimsNotFit is the list of claim keys, which have been resolved, but don't fit into the capacity of the clusters
+	ClaimsNotFit []string
+
+	// ClaimsNotResolved is the list of claim keys, which can't be resolved
+	ClaimsNotResolved []string
+
+	// Clusters is the capacity usage by cluster key
+	Clusters map[string]*ClusterCapacityUsage
+}
+
+// ClusterCapacityUsage shows how much capacity of a single cluster is used and how much extra capacity is needed
+type ClusterCapacityUsage struct {
+	// Capacity is the max number of code component instances in the cluster. Zero means unlimited
+	Capacity int
+
+	// Used is the number of code component instances, which fit into the cluster (including already existing ones)
+	Used int
+
+	// Required is the number of extra code component instances, which don't fit into the cluster
+	Required int
+
+	// Shortfall is the extra capacity needed, so that all code component instances fit into the cluster
+	Shortfall int
+}
+
+// NewCapacityReport calculates capacity report for a given set of claims, which are a part of the given policy and
+// its resolution. Code component instances of other claims are considered to be already using capacity. Claims
+// are processed in the given order, and a claim fits only if all of its new code component instances fit
+func NewCapacityReport(policy *lang.Policy, resolution *PolicyResolution, claims []*lang.Claim) *CapacityReport {
+	report := &CapacityReport{
+		Claims:            len(claims),
+		ClaimsNotFit:      []string{},
+		ClaimsNotResolved: []string{},
+		Clusters:          make(map[string]*ClusterCapacityUsage),
+	}
+
+	inQuestion := make(map[string]bool)
+	for _, claim := range claims {
+		inQuestion[runtime.KeyForStorable(claim)] = true
+	}
+
+	// code component instances of other claims are already using capacity
+	keys := sortedInstanceKeys(resolution)
+	used := make(map[string]bool)
+	for _, key := range keys {
+		instance := resolution.ComponentInstanceMap[key]
+		if !instance.IsCode {
+			continue
+		}
+		for claimKey := range instance.ClaimKeys {
+			if !inQuestion[claimKey] {
+				used[key] = true
+				report.getClusterUsage(policy, instance).Used++
+				break
+			}
+		}
+	}
+
+	// go over claims in question and see if their new instances fit
+	required := make(map[string]bool)
+	for _, claim := range claims {
+		claimKey := runtime.KeyForStorable(claim)
+		if !resolution.GetClaimResolution(claim).Resolved {
+			report.ClaimsNotResolved = append(report.ClaimsNotResolved, claimKey)
+			continue
+		}
+
+		newInstances := make(map[string][]string)
+		for _, key := range keys {
+			instance := resolution.ComponentInstanceMap[key]
+			if _, ok := instance.ClaimKeys[claimKey]; ok && instance.IsCode && !used[key] {
+				clusterKey := getClusterKey(instance)
+				newInstances[clusterKey] = append(newInstances[clusterKey], key)
+			}
+		}
+
+		fit := true
+		for _, instanceKeys := range newInstances {
+			usage := report.getClusterUsage(policy, resolution.ComponentInstanceMap[instanceKeys[0]])
+			if usage.Capacity > 0 && usage.Used+len(instanceKeys) > usage.Capacity {
+				fit = false
+			}
+		}
+
+		if fit {
+			report.ClaimsFit++
+			for clusterKey, instanceKeys := range newInstances {
+				for _, key := range instanceKeys {
+					used[key] = true
+					delete(required, key)
+				}
+				report.Clusters[clusterKey].Used += len(instanceKeys)
+			}
+		} else {
+			report.ClaimsNotFit = append(report.ClaimsNotFit, claimKey)
+			for _, instanceKeys := range newInstances {
+				for _, key := range instanceKeys {
+					required[key] = true
+				}
+			}
+		}
+	}
+
+	// calculate how much extra capacity is needed
+	for key := range required {
+		report.Clusters[getClusterKey(resolution.ComponentInstanceMap[key])].Required++
+	}
+	for _, usage := range report.Clusters {
+		if usage.Capacity > 0 && usage.Used+usage.Required > usage.Capacity {
+			usage.Shortfall = usage.Used + usage.Required - usage.Capacity
+		}
+	}
+
+	return report
+}
+
+// getClusterUsage returns capacity usage for a cluster of a given component instance, creating it if it doesn't exist
+func (report *CapacityReport) getClusterUsage(policy *lang.Policy, instance *ComponentInstance) *ClusterCapacityUsage {
+	clusterKey := getClusterKey(instance)
+	if _, ok := report.Clusters[clusterKey]; !ok {
+		usage := &ClusterCapacityUsage{}
+		clusterObj, err := policy.GetObject(lang.TypeCluster.Kind, instance.Metadata.Key.ClusterName, instance.Metadata.Key.ClusterNameSpace)
+		if err == nil && clusterObj != nil {
+			usage.Capacity = clusterObj.(*lang.Cluster).Capacity
+		}
+		report.Clusters[clusterKey] = usage
+	}
+	return report.Clusters[clusterKey]
+}
+
+// getClusterKey returns key of the cluster, which a given component instance is targeting
+func getClusterKey(instance *ComponentInstance) string {
+	return runtime.KeyFromParts(instance.Metadata.Key.ClusterNameSpace, lang.TypeCluster.Kind, instance.Metadata.Key.ClusterName)
+}
+
+// sortedInstanceKeys returns keys of all component instances in a sorted order, so the report is deterministic
+func sortedInstanceKeys(resolution *PolicyResolution) []string {
+	keys := make([]string, 0, len(resolution.ComponentInstanceMap))
+	for key := range resolution.ComponentInstanceMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,5 +1,10 @@
 package resolve
 
+import (
+	"fmt"
+	"strings"
+)
+
 // ClaimResolution contains resolution status for a given claim
 type ClaimResolution struct {
 	// Resolved indicates whether or not claim has been resolved. If it has been resolved,
@@ -18,3 +23,23 @@ func newClaimResolution(resolved bool, key string) *ClaimResolution {
 		ComponentInstanceKey: key,
 	}
 }
+
+// ClaimsNotResolvedError is returned by ResolveAllClaims in strict mode (see SetContinueOnError), when some claims
+// can't be resolved
+type ClaimsNotResolvedError struct {
+	// Failed is the list of keys of claims (sorted), which can't be resolved
+	Failed []string
+
+	// Total is the number of claims in the policy
+	Total int
+}
+
+func (err *ClaimsNotResolvedError) Error() string {
+	return fmt.Sprintf("%d of %d claims can't be resolved: %s", len(err.Failed), err.Total, strings.Join(err.Failed, ", "))
+}
+
+// IsClaimsNotResolved returns true if a given error is ClaimsNotResolvedError
+func IsClaimsNotResolved(err error) bool {
+	_, ok := err.(*ClaimsNotResolvedError)
+	return ok
+}
@@ -0,0 +1,52 @@
+package resolve
+
+import "sort"
+
+// ComponentInstanceConsumers represents a set of consumers of a given component instance - claims which are keeping it
+// instantiated and component instances which depend on it. Component instance can only be deleted once it has
+// no consumers left
+type ComponentInstanceConsumers struct {
+	// Key is a key of the component instance
+	Key string
+
+	// Found is true if component instance exists in the corresponding state
+	Found bool
+
+	// Claims is a sorted list of claim keys which are keeping this component instance instantiated
+	Claims []string
+
+	// Instances is a sorted list of component instance keys which depend on this component instance
+	Instances []string
+}
+
+// IsEmpty returns true if component instance has no consumers
+func (consumers *ComponentInstanceConsumers) IsEmpty() bool {
+	return len(consumers.Claims) <= 0 && len(consumers.Instances) <= 0
+}
+
+// GetConsumers returns the set of consumers for a component instance with a given key
+func (resolution *PolicyResolution) GetConsumers(key string) *ComponentInstanceConsumers {
+	result := &ComponentInstanceConsumers{
+		Key:       key,
+		Claims:    []string{},
+		Instances: []string{},
+	}
+
+	if instance, ok := resolution.ComponentInstanceMap[key]; ok {
+		result.Found = true
+		for claimKey := range instance.ClaimKeys {
+			result.Claims = append(result.Claims, claimKey)
+		}
+	}
+
+	for instanceKey, instance := range resolution.ComponentInstanceMap {
+		if instance.EdgesOut[key] {
+			result.Instances = append(result.Instances, instanceKey)
+		}
+	}
+
+	sort.Strings(result.Claims)
+	sort.Strings(result.Instances)
+
+	return result
+}
@@ -121,6 +121,22 @@ func (cik ComponentInstanceKey) GetKey() string {
 	return cik.key
 }
 
+// GetCluster returns the cluster, which a component instance is targeting, in form namespace/name
+func (cik ComponentInstanceKey) GetCluster() string {
+	return cik.ClusterNameSpace + "/" + cik.ClusterName
+}
+
+// GetClusterFromKey returns the cluster, which a component instance with a given string key is targeting, in form
+// namespace/name, so clusters with the same name from different namespaces are told apart. It returns an empty
+// string if the key is malformed
+func GetClusterFromKey(key string) string {
+	parts := strings.Split(key, componentInstanceKeySeparator)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[0] + "/" + parts[1]
+}
+
 var (
 	base32LowerCaseHexEncoding = base32.NewEncoding("0123456789abcdefghijklmnopqrstuv")
 )
@@ -53,6 +53,20 @@ func TestComponentKeyUnsafe(t *testing.T) {
 	}
 }
 
+func TestComponentKeyCluster(t *testing.T) {
+	key := makeKey(false)
+	cluster := key.ClusterNameSpace + "/" + key.ClusterName
+	assert.Equal(t, cluster, key.GetCluster(), "Cluster should include its namespace")
+	assert.Equal(t, cluster, GetClusterFromKey(key.GetKey()), "Cluster should be taken from string key along with its namespace")
+
+	// clusters with the same name from different namespaces should be told apart
+	other := key.MakeCopy()
+	other.ClusterNameSpace = "other"
+	assert.NotEqual(t, GetClusterFromKey(key.GetKey()), GetClusterFromKey(other.GetKey()), "Clusters from different namespaces should differ")
+
+	assert.Empty(t, GetClusterFromKey("malformed"), "Cluster shouldn't be taken from malformed key")
+}
+
 func makeKey(root bool) *ComponentInstanceKey {
 	b := builder.NewPolicyBuilder()
 	bundle := b.AddBundle()
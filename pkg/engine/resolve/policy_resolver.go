@@ -1,9 +1,11 @@
 package resolve
 
 import (
+	"context"
 	"fmt"
 	sysruntime "runtime"
 	"runtime/debug"
+	"sort"
 	"sync"
 
 	"github.com/Aptomi/aptomi/pkg/event"
@@ -11,14 +13,38 @@ import (
 	"github.com/Aptomi/aptomi/pkg/lang"
 	"github.com/Aptomi/aptomi/pkg/lang/expression"
 	"github.com/Aptomi/aptomi/pkg/lang/template"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+	"github.com/Aptomi/aptomi/pkg/runtime"
 	"github.com/Aptomi/aptomi/pkg/util"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// MaxConcurrentGoRoutines is the number of concurrently running goroutines for policy evaluation and processing.
+// MaxConcurrentGoRoutines is the default number of concurrently running goroutines for policy evaluation and processing.
 // We don't necessarily want to run a lot of them due to CPU/memory constraints and due to the fact that there is minimal
 // io wait time in policy processing (goroutines are mostly busy doing calculations as opposed to waiting).
 var MaxConcurrentGoRoutines = sysruntime.NumCPU()
 
+// TracerName is the name of the tracer used by policy resolver to report tracing spans
+const TracerName = "github.com/Aptomi/aptomi/pkg/engine/resolve"
+
+const (
+	// SpanResolveAllClaims is the name of the tracing span covering resolution of all claims
+	SpanResolveAllClaims = "resolve.all-claims"
+	// SpanResolveSingleClaim is the name of the tracing span covering resolution of a single requested claim
+	SpanResolveSingleClaim = "resolve.single-claim"
+	// SpanResolveClaim is the name of the tracing span covering resolution of a single claim
+	SpanResolveClaim = "resolve.claim"
+	// SpanRuleEval is the name of the tracing span covering rule evaluation
+	SpanRuleEval = "resolve.rules"
+	// SpanPlacement is the name of the tracing span covering placement (context and cluster selection)
+	SpanPlacement = "resolve.placement"
+	// SpanParameters is the name of the tracing span covering calculation of code and discovery parameters
+	SpanParameters = "resolve.parameters"
+)
+
 // PolicyResolver is a core of Aptomi for policy resolution and translating all claims
 // into a single PolicyResolution object which represents desired state of components running in a cloud.
 type PolicyResolver struct {
@@ -32,6 +58,15 @@ type PolicyResolver struct {
 	// External data
 	externalData *external.Data
 
+	// Plugin registry (optional), used to check generated names against cluster constraints
+	pluginRegistry plugin.Registry
+
+	// Max number of claims (or exclusion groups of claims) resolved concurrently
+	maxConcurrency int
+
+	// Whether to return partial resolution when some claims can't be resolved (otherwise an error is returned)
+	continueOnError bool
+
 	/*
 		Cache
 	*/
@@ -43,16 +78,24 @@ type PolicyResolver struct {
 	templateCache *template.Cache
 
 	/*
-		Calculated objects (aggregated over all claims)
+		Tracing
 	*/
 
-	combineMutex sync.Mutex
+	// Tracer used to report resolution spans
+	tracer trace.Tracer
+
+	/*
+		Calculated objects (aggregated over all claims)
+	*/
 
 	// Reference to the calculated PolicyResolution
 	resolution *PolicyResolution
 
 	// Buffered event log - gets populated during policy resolution
 	eventLog *event.Log
+
+	// Keys of claims, which couldn't be resolved
+	failedClaims []string
 }
 
 // NewPolicyResolver creates a new policy resolver. You must call policy.Validate() before calling this method, to
@@ -67,93 +110,333 @@ func NewPolicyResolver(policy *lang.Policy, externalData *external.Data, eventLo
 	return &PolicyResolver{
 		policy:          policy,
 		externalData:    externalData,
-		expressionCache: expression.NewCache(),
-		templateCache:   template.NewCache(),
+		expressionCache: policy.GetExpressionCache(),
+		templateCache:   policy.GetTemplateCache(),
+		maxConcurrency:  MaxConcurrentGoRoutines,
+		tracer:          otel.Tracer(TracerName),
 		resolution:      NewPolicyResolution(),
 		eventLog:        eventLog,
 	}
 }
 
+// SetPluginRegistry sets plugin registry for the resolver. When it's set, generated instance names, namespaces and
+// label values are checked against constraints of the target cluster, and claims violating them don't get resolved
+func (resolver *PolicyResolver) SetPluginRegistry(pluginRegistry plugin.Registry) *PolicyResolver {
+	resolver.pluginRegistry = pluginRegistry
+	return resolver
+}
+
+// SetMaxConcurrency sets the max number of claims (or exclusion groups of claims) resolved concurrently. By default,
+// it's MaxConcurrentGoRoutines
+func (resolver *PolicyResolver) SetMaxConcurrency(maxConcurrency int) *PolicyResolver {
+	resolver.maxConcurrency = maxConcurrency
+	return resolver
+}
+
+// SetContinueOnError sets whether ResolveAllClaims should return partial PolicyResolution when some claims can't be
+// resolved. By default, resolution is strict and ClaimsNotResolvedError is returned if any claim fails
+func (resolver *PolicyResolver) SetContinueOnError(continueOnError bool) *PolicyResolver {
+	resolver.continueOnError = continueOnError
+	return resolver
+}
+
+// FailedClaims returns keys of claims (sorted), which couldn't be resolved by ResolveAllClaims
+func (resolver *PolicyResolver) FailedClaims() []string {
+	return resolver.failedClaims
+}
+
 // ResolveAllClaims takes policy as input and calculates PolicyResolution (desired state) as output.
 //
 // The method resolves all recorded claims for consuming services ("instantiate <service> with <labels>"), calculating
 // which components have to be allocated and with which parameters. Once PolicyResolution (desired state) is calculated,
 // it can be rendered by the engine diff/apply by deploying and configuring required components in the cloud.
 //
-// As a result, status of every claim will be stored in resolution state.
-func (resolver *PolicyResolver) ResolveAllClaims() *PolicyResolution {
-	// Allocate semaphore, making sure we don't run more than MaxConcurrentGoRoutines go routines at the same time
-	var semaphore = make(chan int, MaxConcurrentGoRoutines)
-	var wg sync.WaitGroup
+// Claims get resolved concurrently by a bounded pool of workers. Every claim is resolved into its own resolution data
+// and event log, so claims sharing components don't interfere with each other while being resolved. Claims from the
+// same exclusion group depend on each other, so they're resolved one by one by a single worker. Once all claims are
+// resolved, their data and event logs are combined in the order of claim keys, so shared components always end up the
+// same and event log is stable for a given policy regardless of the order in which workers finish.
+//
+// If resolution of any claim runs into services and bundles depending on each other in a cycle, no resolution is
+// returned and CycleError naming all objects in the cycle is returned instead. Only dependencies matching criteria of
+// contexts and components get followed, so cycles which can't be reached by any claim are not reported.
+//
+// Failure of a claim never stops resolution of other claims and gets recorded in the event log. By default, resolution
+// is strict: if any claim can't be resolved, ClaimsNotResolvedError listing all failed claims is returned instead of
+// PolicyResolution. If resolver is set to continue on error (see SetContinueOnError), PolicyResolution with all
+// successfully resolved claims is returned and failed claims could be retrieved via FailedClaims.
+//
+// As a result, status of every claim will be stored in resolution state. Provided context is used for tracing, a span
+// gets reported per claim and per resolution phase.
+func (resolver *PolicyResolver) ResolveAllClaims(ctx context.Context) (*PolicyResolution, error) {
+	ctx, span := resolver.tracer.Start(ctx, SpanResolveAllClaims)
+	defer span.End()
+
 	claims := resolver.policy.GetObjectsByKind(lang.TypeClaim.Kind)
+	ungrouped, groups := resolver.groupClaims(claims)
+
+	// Every declared claim without exclusion group gets resolved by a separate task
+	tasks := make([]claimTask, 0, len(ungrouped)+len(groups))
+	for _, claim := range ungrouped {
+		c := claim
+		tasks = append(tasks, func() []*claimResult {
+			node, resolveErr := resolver.resolveClaim(ctx, c, nil)
+			return []*claimResult{{claim: c, node: node, err: resolveErr}}
+		})
+	}
 
-	// Resolve every declared claim
-	for _, claim := range claims {
-		// Start go routine for resolving a given claim
-		wg.Add(1)
-		semaphore <- 1
-		go func(c *lang.Claim) {
-			defer wg.Done()
-			node, resolveErr := resolver.resolveClaim(c)
-			resolver.combineData(node, resolveErr)
-			<-semaphore
-		}(claim.(*lang.Claim))
+	// Claims from every exclusion group get resolved one by one within a single task, so they never share a cluster
+	for _, group := range groups {
+		g := group
+		tasks = append(tasks, func() []*claimResult {
+			results := make([]*claimResult, 0, len(g.claims))
+			for _, c := range g.claims {
+				node, resolveErr := resolver.resolveClaim(ctx, c, g)
+				if resolveErr == nil {
+					g.record(node)
+				}
+				results = append(results, &claimResult{claim: c, node: node, err: resolveErr})
+			}
+			return results
+		})
 	}
 
-	// Wait for all go routines to end
-	wg.Wait()
+	// Combine results in the same order as tasks were created, no matter when they were completed
+	resolver.failedClaims = []string{}
+	var cycleErr error
+	for _, results := range resolver.runClaimTasks(tasks) {
+		for _, result := range results {
+			resolver.combineData(result.node, result.err)
+			if result.err != nil {
+				resolver.failedClaims = append(resolver.failedClaims, runtime.KeyForStorable(result.claim))
+			}
+			if cycleErr == nil && IsCycleError(result.err) {
+				cycleErr = result.err
+			}
+		}
+	}
+
+	// Dependency cycle is a defect of the policy itself rather than of a particular claim
+	if cycleErr != nil {
+		span.SetStatus(codes.Error, cycleErr.Error())
+		return nil, cycleErr
+	}
+	sort.Strings(resolver.failedClaims)
+	span.SetAttributes(attribute.Int("claims", len(claims)), attribute.Int("claims.failed", len(resolver.failedClaims)))
 
 	// Once all components are resolved, print information about them into event log
-	for _, instance := range resolver.resolution.ComponentInstanceMap {
+	resolver.logAllComponentParams()
+
+	if len(resolver.failedClaims) > 0 && !resolver.continueOnError {
+		err := &ClaimsNotResolvedError{Failed: resolver.failedClaims, Total: len(claims)}
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	return resolver.resolution, nil
+}
+
+// ResolveClaim resolves a single claim with a given key, calculating which components of its transitive component tree
+// have to be allocated and with which parameters. It's much faster than ResolveAllClaims when only the outcome of one
+// claim is of interest (e.g. for debugging). Event log of the resolver gets populated with events of this claim only.
+//
+// The returned PolicyResolution is partial: it contains only component instances used by the claim and doesn't
+// include data contributed to the shared components by other claims. If the claim belongs to an exclusion group,
+// claims preceding it in the group are resolved first (without being included into the result), so the claim gets
+// placed the same way as it would be placed by ResolveAllClaims.
+//
+// Whether the claim has been resolved could be checked via GetClaimResolution of the returned PolicyResolution. Error
+// is returned only if the claim doesn't exist or its resolution runs into a dependency cycle (see CycleError).
+func (resolver *PolicyResolver) ResolveClaim(ctx context.Context, claimKey runtime.Key) (*PolicyResolution, error) {
+	ctx, span := resolver.tracer.Start(ctx, SpanResolveSingleClaim, trace.WithAttributes(attribute.String("claim", claimKey)))
+	defer span.End()
+
+	claims := resolver.policy.GetObjectsByKind(lang.TypeClaim.Kind)
+	ungrouped, groups := resolver.groupClaims(claims)
+
+	var node *resolutionNode
+	var resolveErr error
+	found := false
+	for _, claim := range ungrouped {
+		if runtime.KeyForStorable(claim) == claimKey {
+			node, resolveErr = resolver.resolveClaim(ctx, claim, nil)
+			found = true
+			break
+		}
+	}
+
+	for _, group := range groups {
+		if found {
+			break
+		}
+		for _, claim := range group.claims {
+			if runtime.KeyForStorable(claim) == claimKey {
+				node, resolveErr = resolver.resolveClaim(ctx, claim, group)
+				found = true
+				break
+			}
+
+			// claims preceding the requested one only take clusters, their data and logs are not needed
+			precedingNode, precedingErr := resolver.resolveClaim(ctx, claim, group)
+			if precedingErr == nil {
+				group.record(precedingNode)
+			}
+			if precedingNode != nil {
+				precedingNode.closeEventLogs()
+			}
+		}
+	}
+
+	if !found {
+		err := fmt.Errorf("claim %s doesn't exist in the policy", claimKey)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	resolver.combineData(node, resolveErr)
+	if IsCycleError(resolveErr) {
+		span.SetStatus(codes.Error, resolveErr.Error())
+		return nil, resolveErr
+	}
+	resolver.logAllComponentParams()
+
+	return resolver.resolution, nil
+}
+
+// logAllComponentParams prints information about all resolved components into event log, in the order of their keys
+func (resolver *PolicyResolver) logAllComponentParams() {
+	instanceKeys := make([]string, 0, len(resolver.resolution.ComponentInstanceMap))
+	for key := range resolver.resolution.ComponentInstanceMap {
+		instanceKeys = append(instanceKeys, key)
+	}
+	sort.Strings(instanceKeys)
+	for _, key := range instanceKeys {
+		instance := resolver.resolution.ComponentInstanceMap[key]
 		if instance.Metadata.Key.IsComponent() {
 			resolver.logComponentParams(instance)
 		}
 	}
+}
+
+// claimResult is the outcome of resolving a single claim
+type claimResult struct {
+	claim *lang.Claim
+	node  *resolutionNode
+	err   error
+}
+
+// claimTask resolves one or more claims and returns their results in the order claims were resolved
+type claimTask func() []*claimResult
+
+// runClaimTasks runs given tasks using a pool of workers, making sure that no more than maxConcurrency tasks are running
+// at the same time. Results are returned in the same order as tasks
+func (resolver *PolicyResolver) runClaimTasks(tasks []claimTask) [][]*claimResult {
+	workers := resolver.maxConcurrency
+	if workers > len(tasks) {
+		workers = len(tasks)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([][]*claimResult, len(tasks))
+	taskIdx := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range taskIdx {
+				// every task writes only into its own slot, so there is no need for locking
+				results[idx] = tasks[idx]()
+			}
+		}()
+	}
+
+	for idx := range tasks {
+		taskIdx <- idx
+	}
+	close(taskIdx)
+
+	// Wait for all workers to end
+	wg.Wait()
 
-	return resolver.resolution
+	return results
 }
 
-// Resolves a single claim and returns an error if it cannot be resolved
-func (resolver *PolicyResolver) resolveClaim(claim *lang.Claim) (node *resolutionNode, resolveErr error) {
+// Resolves a single claim and returns an error if it cannot be resolved. If exclusion group is given, claim is not
+// allowed to use clusters which are already used by other claims from the group
+func (resolver *PolicyResolver) resolveClaim(ctx context.Context, claim *lang.Claim, group *exclusionGroup) (node *resolutionNode, resolveErr error) {
+	ctx, span := resolver.tracer.Start(ctx, SpanResolveClaim, trace.WithAttributes(attribute.String("claim", runtime.KeyForStorable(claim))))
+
 	// make sure we are converting panics into errors
 	defer func() {
 		if err := recover(); err != nil {
 			resolveErr = fmt.Errorf("panic: %s\n%s", err, string(debug.Stack()))
 			node.eventLog.NewEntry().Error(resolveErr)
 		}
+
+		// report claim resolution status in tracing span
+		if resolveErr != nil {
+			span.RecordError(resolveErr)
+			span.SetStatus(codes.Error, "claim cannot be resolved")
+		}
+		span.End()
 	}()
 
-	// create new resolution node
-	node = resolver.newResolutionNode()
+	// try services from the claim one by one (primary first, then fallbacks), until claim gets resolved
+	var prevService string
+	var prevErr error
+	for _, service := range claim.GetServices() {
+		// logs of the service, which claim couldn't be resolved with, aren't combined
+		if node != nil {
+			node.closeEventLogs()
+		}
 
-	// populate resolution node with data (e.g. construct initial set of labels)
-	resolver.initResolutionNode(node, claim)
+		// create new resolution node
+		node = resolver.newResolutionNode(ctx)
 
-	// resolve it
-	resolveErr = resolver.resolveNode(node)
-	return node, resolveErr
-}
+		// populate resolution node with data (e.g. construct initial set of labels)
+		resolver.initResolutionNode(node, claim, service)
 
-// Combines resolution data into the overall state of the world. If there is a conflict, it will return an error
-func (resolver *PolicyResolver) combineData(node *resolutionNode, resolutionErr error) {
-	// put a lock
-	resolver.combineMutex.Lock()
+		// record the reason why we are falling back to this service
+		if prevErr != nil {
+			node.logFallbackService(prevService, prevErr)
+		}
 
-	// aggregate logs in the end, especially if resolutionErr occurred
-	defer func() {
-		if node != nil {
-			for _, eventLog := range node.eventLogsCombined {
-				resolver.eventLog.Append(eventLog)
+		// resolve it
+		resolveErr = resolver.resolveNode(node)
+		if resolveErr == nil && group != nil {
+			resolveErr = node.checkExclusionGroup(group)
+			if resolveErr != nil {
+				node.eventLog.NewEntry().Error(resolveErr)
 			}
 		}
-		resolver.combineMutex.Unlock()
-	}()
+		// dependency cycle can't be worked around by falling back to another service
+		if resolveErr == nil || IsCycleError(resolveErr) {
+			break
+		}
+		prevService, prevErr = service, resolveErr
+	}
+	return node, resolveErr
+}
 
+// Combines resolution data of a single claim into the overall state of the world. It's called once all claims are
+// resolved, one claim at a time, so there is no need for locking
+func (resolver *PolicyResolver) combineData(node *resolutionNode, resolutionErr error) {
 	// if there was no resolution error, combine component data
 	if resolutionErr == nil {
 		// aggregate component instance data
 		resolver.resolution.AppendData(node.resolution)
 	}
+
+	// aggregate logs in the end, especially if resolutionErr occurred
+	if node != nil {
+		for _, eventLog := range node.eventLogsCombined {
+			resolver.eventLog.Append(eventLog)
+		}
+		node.closeEventLogs()
+	}
 }
 
 // Evaluate evaluates and resolves a single claim, as well as calculates component allocations.
@@ -200,44 +483,45 @@ func (resolver *PolicyResolver) resolveNode(node *resolutionNode) (resolveErr er
 	// Process bundle and transform labels
 	node.transformLabels(node.labels, node.service.ChangeLabels)
 
-	// Match the context
-	node.context, err = node.getMatchedContext()
-	if err != nil {
-		return err
-	}
-
-	// Check that bundle, which current context is implemented with, exists
-	node.bundle, err = node.getMatchedBundle(resolver.policy)
-	if err != nil {
-		return err
-	}
-	node.objectResolved(node.bundle)
-
-	// Process context and transform labels
-	node.transformLabels(node.labels, node.context.ChangeLabels)
-
-	// Resolve allocation keys for the context
-	node.allocationKeysResolved, err = node.resolveAllocationKeys()
+	// Match the context, pick bundle and resolve allocation keys
+	err = node.tracePhase(SpanPlacement, func() error {
+		return node.resolvePlacement(resolver.policy)
+	})
 	if err != nil {
 		return err
 	}
 
 	// Process global rules before processing bundle key and dependent component keys
-	ruleResult, err := node.processRules()
+	var ruleResult *lang.RuleActionResult
+	err = node.tracePhase(SpanRuleEval, func() error {
+		var ruleErr error
+		ruleResult, ruleErr = node.processRules()
+		return ruleErr
+	})
 	if err != nil {
 		return err
 	}
-	// Create bundle key
-	node.bundleKey, err = node.createComponentKey(nil)
+
+	// Pick target cluster according to placement preferences (if it hasn't been set by labels) and create bundle key
+	err = node.tracePhase(SpanPlacement, func() error {
+		prefErr := node.resolvePreferredTarget()
+		if prefErr != nil {
+			return prefErr
+		}
+		var keyErr error
+		node.bundleKey, keyErr = node.createComponentKey(nil)
+		return keyErr
+	})
 	if err != nil {
 		return err
 	}
 
 	// Check if we've been there already and therefore hit a bundle cycle
-	cycle := util.ContainsString(node.path, node.bundleKey.GetKey())
+	cycleStart := node.findOnPath(node.bundleKey.GetKey())
 	node.path = append(node.path, node.bundleKey.GetKey())
-	if cycle {
-		return node.errorBundleCycleDetected()
+	node.objectPath = append(node.objectPath, objectName(node.service), objectName(node.bundle))
+	if cycleStart >= 0 {
+		return node.errorBundleCycleDetected(cycleStart)
 	}
 
 	// Store labels for bundle
@@ -282,7 +566,7 @@ func (resolver *PolicyResolver) resolveNode(node *resolutionNode) (resolveErr er
 		node.discoveryTreeNode[node.component.Name] = util.NestedParameterMap{}
 
 		// Calculate and store discovery params
-		err := node.calculateAndStoreDiscoveryParams()
+		err := node.tracePhase(SpanParameters, node.calculateAndStoreDiscoveryParams)
 		if err != nil {
 			return err
 		}
@@ -292,7 +576,7 @@ func (resolver *PolicyResolver) resolveNode(node *resolutionNode) (resolveErr er
 
 		if node.component.Code != nil {
 			// Evaluate code params
-			err := node.calculateAndStoreCodeParams()
+			err := node.tracePhase(SpanParameters, node.calculateAndStoreCodeParams)
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,30 @@
+package resolve
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Aptomi/aptomi/pkg/lang"
+)
+
+// CycleError is returned by ResolveAllClaims when resolution of a claim runs into services and bundles depending on
+// each other in a cycle (e.g. bundle includes a component pointing to a service, which allocates the same bundle again)
+type CycleError struct {
+	// Path is the list of objects participating in the cycle. The first object gets repeated at the end of the list
+	Path []string
+}
+
+func (err *CycleError) Error() string {
+	return fmt.Sprintf("dependency cycle detected in policy: %s", strings.Join(err.Path, " -> "))
+}
+
+// IsCycleError returns true if a given error is CycleError
+func IsCycleError(err error) bool {
+	_, ok := err.(*CycleError)
+	return ok
+}
+
+// objectName returns the name of a given object, under which it's reported in CycleError
+func objectName(obj lang.Base) string {
+	return fmt.Sprintf("%s '%s/%s'", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+}
@@ -0,0 +1,102 @@
+package resolve
+
+import (
+	"sort"
+
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+// exclusionGroup keeps track of clusters used by claims from the same exclusion group. Claims of a group are
+// resolved one by one in a single go routine, so there is no need for locking
+type exclusionGroup struct {
+	// name of the exclusion group
+	name string
+
+	// claims in the group, sorted by key
+	claims []*lang.Claim
+
+	// total number of clusters in the policy
+	clusters int
+
+	// claim keys by keys of clusters they are using
+	usedBy map[string]string
+}
+
+// groupClaims splits claims into the ones without exclusion group, sorted by key, and exclusion groups, sorted by name
+func (resolver *PolicyResolver) groupClaims(claims []lang.Base) ([]*lang.Claim, []*exclusionGroup) {
+	clusters := len(resolver.policy.GetObjectsByKind(lang.TypeCluster.Kind))
+	ungrouped := []*lang.Claim{}
+	groupMap := make(map[string]*exclusionGroup)
+	for _, obj := range claims {
+		claim := obj.(*lang.Claim) // nolint: errcheck
+		if len(claim.ExclusionGroup) <= 0 {
+			ungrouped = append(ungrouped, claim)
+			continue
+		}
+		if _, ok := groupMap[claim.ExclusionGroup]; !ok {
+			groupMap[claim.ExclusionGroup] = &exclusionGroup{
+				name:     claim.ExclusionGroup,
+				clusters: clusters,
+				usedBy:   make(map[string]string),
+			}
+		}
+		groupMap[claim.ExclusionGroup].claims = append(groupMap[claim.ExclusionGroup].claims, claim)
+	}
+
+	groups := make([]*exclusionGroup, 0, len(groupMap))
+	for _, group := range groupMap {
+		sort.Slice(group.claims, func(i, j int) bool {
+			return runtime.KeyForStorable(group.claims[i]) < runtime.KeyForStorable(group.claims[j])
+		})
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].name < groups[j].name
+	})
+	sort.Slice(ungrouped, func(i, j int) bool {
+		return runtime.KeyForStorable(ungrouped[i]) < runtime.KeyForStorable(ungrouped[j])
+	})
+
+	return ungrouped, groups
+}
+
+// feasible returns true if there are enough clusters for all claims of the group to be placed on distinct clusters
+func (group *exclusionGroup) feasible() bool {
+	return len(group.claims) <= group.clusters
+}
+
+// record marks clusters used by a resolved claim as taken
+func (group *exclusionGroup) record(node *resolutionNode) {
+	claimKey := runtime.KeyForStorable(node.claim)
+	for _, clusterKey := range node.getUsedClusters() {
+		group.usedBy[clusterKey] = claimKey
+	}
+}
+
+// checkExclusionGroup checks that the claim being resolved doesn't use clusters, which are already used by other
+// claims from its exclusion group
+func (node *resolutionNode) checkExclusionGroup(group *exclusionGroup) error {
+	for _, clusterKey := range node.getUsedClusters() {
+		if claimKey, used := group.usedBy[clusterKey]; used {
+			return node.errorExclusionGroupConflict(group, clusterKey, claimKey)
+		}
+	}
+	return nil
+}
+
+// getUsedClusters returns sorted keys of clusters, where code components of the node are placed
+func (node *resolutionNode) getUsedClusters() []string {
+	clusters := make(map[string]bool)
+	for _, instance := range node.resolution.ComponentInstanceMap {
+		if instance.IsCode {
+			clusters[getClusterKey(instance)] = true
+		}
+	}
+	result := make([]string, 0, len(clusters))
+	for clusterKey := range clusters {
+		result = append(result, clusterKey)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -1,6 +1,7 @@
 package resolve
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/Aptomi/aptomi/pkg/event"
@@ -8,6 +9,7 @@ import (
 	"github.com/Aptomi/aptomi/pkg/plugin/k8s"
 	"github.com/Aptomi/aptomi/pkg/runtime"
 	"github.com/Aptomi/aptomi/pkg/util"
+	"go.opentelemetry.io/otel/codes"
 )
 
 // This is a special internal structure that gets used by the engine, while we traverse the policy graph for a given claim
@@ -16,6 +18,9 @@ type resolutionNode struct {
 	// pointer to the policy resolver
 	resolver *PolicyResolver
 
+	// context of the claim being resolved (carries tracing span of the claim)
+	ctx context.Context
+
 	// pointer to event log (local to the node)
 	eventLog *event.Log
 
@@ -31,6 +36,9 @@ type resolutionNode struct {
 	// reference to initial claim
 	claim *lang.Claim
 
+	// service from the claim we are resolving with (either the primary one, or one of the fallback services)
+	claimService string
+
 	// reference to user who requested this claim
 	user *lang.User
 
@@ -39,6 +47,9 @@ type resolutionNode struct {
 	serviceName string
 	service     *lang.Service
 
+	// reference to the cluster where components are being placed
+	cluster *lang.Cluster
+
 	// reference to the current set of labels
 	labels *lang.LabelSet
 
@@ -67,13 +78,17 @@ type resolutionNode struct {
 
 	// path that we traveled so far (to detect cycles)
 	path []string
+
+	// names of the service and the bundle for every entry of the path (to report cycles)
+	objectPath []string
 }
 
 // Creates a new empty resolution node
-func (resolver *PolicyResolver) newResolutionNode() *resolutionNode {
+func (resolver *PolicyResolver) newResolutionNode(ctx context.Context) *resolutionNode {
 	eventLog := event.NewLog(resolver.eventLog.GetLevel(), resolver.eventLog.GetScope())
 	return &resolutionNode{
 		resolver:          resolver,
+		ctx:               ctx,
 		eventLog:          eventLog,
 		eventLogsCombined: []*event.Log{eventLog},
 
@@ -89,17 +104,18 @@ func (resolver *PolicyResolver) newResolutionNode() *resolutionNode {
 	}
 }
 
-// Initialized a newly created resolution node as a starting point for resolving a particular claim.
-// Adds claim and user labels into it.
-func (resolver *PolicyResolver) initResolutionNode(node *resolutionNode, claim *lang.Claim) {
+// Initialized a newly created resolution node as a starting point for resolving a particular claim via a given
+// service (primary or fallback one). Adds claim and user labels into it.
+func (resolver *PolicyResolver) initResolutionNode(node *resolutionNode, claim *lang.Claim, service string) {
 	// populate user, claim
 	node.claim = claim
+	node.claimService = service
 	user := resolver.externalData.UserLoader.LoadUserByName(claim.User)
 	node.user = user
 
 	// start with the namespace & service specified in the claim
 	node.namespace = claim.Namespace
-	node.serviceName = claim.Service
+	node.serviceName = service
 
 	// create a starting set of labels, combining user labels and claim labels
 	node.labels = lang.NewLabelSet(claim.Labels)
@@ -108,19 +124,28 @@ func (resolver *PolicyResolver) initResolutionNode(node *resolutionNode, claim *
 	}
 }
 
+// Releases resources held by event logs of the node and its child nodes (e.g. spill files of bounded event logs)
+func (node *resolutionNode) closeEventLogs() {
+	for _, eventLog := range node.eventLogsCombined {
+		_ = eventLog.Close()
+	}
+}
+
 // Creates a new resolution node (as we are processing claim on another bundle)
 func (node *resolutionNode) createChildNode() *resolutionNode {
 	eventLog := event.NewLog(node.eventLog.GetLevel(), node.eventLog.GetScope())
 	return &resolutionNode{
 		resolver:          node.resolver,
+		ctx:               node.ctx,
 		eventLog:          eventLog,
 		eventLogsCombined: []*event.Log{eventLog},
 
 		resolution: node.resolution,
 
-		depth: node.depth + 1,
-		claim: node.claim,
-		user:  node.user,
+		depth:        node.depth + 1,
+		claim:        node.claim,
+		claimService: node.claimService,
+		user:         node.user,
 
 		// we take the current component we are iterating over, and get its service name
 		namespace:   node.namespace,
@@ -136,7 +161,8 @@ func (node *resolutionNode) createChildNode() *resolutionNode {
 		arrivalKey: node.componentKey,
 
 		// copy path
-		path: util.CopySliceOfStrings(node.path),
+		path:       util.CopySliceOfStrings(node.path),
+		objectPath: util.CopySliceOfStrings(node.objectPath),
 	}
 }
 
@@ -146,6 +172,30 @@ func (node *resolutionNode) objectResolved(object runtime.Storable) {
 	node.eventLog.AddFixedField(object.GetKind()+"Id", runtime.KeyForStorable(object))
 }
 
+// Helper to find a given bundle key on the path, returns -1 if it's not there
+func (node *resolutionNode) findOnPath(key string) int {
+	for idx, pathKey := range node.path {
+		if pathKey == key {
+			return idx
+		}
+	}
+	return -1
+}
+
+// Helper to run a given phase of claim resolution, reporting it as a tracing span
+func (node *resolutionNode) tracePhase(name string, phase func() error) error {
+	_, span := node.resolver.tracer.Start(node.ctx, name)
+	defer span.End()
+
+	err := phase()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
 // Helper to check that user exists
 func (node *resolutionNode) checkUserExists() error {
 	if node.user == nil {
@@ -173,6 +223,31 @@ func (node *resolutionNode) getService(policy *lang.Policy) (*lang.Service, erro
 	return service, nil
 }
 
+// Helper to pick placement for the claim: match the context, get the corresponding bundle and resolve allocation keys
+func (node *resolutionNode) resolvePlacement(policy *lang.Policy) error {
+	var err error
+
+	// Match the context
+	node.context, err = node.getMatchedContext()
+	if err != nil {
+		return err
+	}
+
+	// Check that bundle, which current context is implemented with, exists
+	node.bundle, err = node.getMatchedBundle(policy)
+	if err != nil {
+		return err
+	}
+	node.objectResolved(node.bundle)
+
+	// Process context and transform labels
+	node.transformLabels(node.labels, node.context.ChangeLabels)
+
+	// Resolve allocation keys for the context
+	node.allocationKeysResolved, err = node.resolveAllocationKeys()
+	return err
+}
+
 // Helper to get a matched context
 func (node *resolutionNode) getMatchedContext() (*lang.Context, error) {
 	// Locate the list of contexts for bundle
@@ -259,6 +334,7 @@ func (node *resolutionNode) createComponentKey(component *lang.BundleComponent)
 	if err != nil {
 		return nil, node.errorClusterLookup(target.ClusterName, err)
 	}
+	node.cluster = cluster
 
 	// handle default namespace for kubernetes clusters
 	if len(target.Suffix) <= 0 && cluster.Type == "kubernetes" {
@@ -347,6 +423,11 @@ func (node *resolutionNode) calculateAndStoreCodeParams() error {
 		return node.errorWhenProcessingCodeParams(err)
 	}
 
+	err = node.checkClusterConstraints(componentCodeParams)
+	if err != nil {
+		return err
+	}
+
 	err = node.resolution.RecordCodeParams(node.componentKey, componentCodeParams)
 	if err != nil {
 		return node.errorWhenProcessingCodeParams(err)
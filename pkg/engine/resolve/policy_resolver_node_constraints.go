@@ -0,0 +1,86 @@
+package resolve
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/util"
+)
+
+// labelsParamName is the name of code parameters which are treated as labels (e.g. 'labels' map in Helm values)
+const labelsParamName = "labels"
+
+// checkClusterConstraints checks instance name, namespace and label values generated for the current code component
+// against the constraints of the target cluster. It's a no-op when resolver doesn't have plugin registry
+func (node *resolutionNode) checkClusterConstraints(codeParams util.NestedParameterMap) error {
+	if node.resolver.pluginRegistry == nil || node.cluster == nil {
+		return nil
+	}
+
+	clusterPlugin, err := node.resolver.pluginRegistry.ForCluster(node.cluster)
+	if err != nil {
+		return node.errorClusterLookup(node.cluster.Name, err)
+	}
+	constraints := clusterPlugin.Constraints()
+
+	// instance name is generated from the component key
+	err = node.checkConstraint("instance name", node.componentKey.GetDeployName(), constraints.MaxNameLength,
+		fmt.Sprintf("component key '%s' (cluster, target, service, context, allocation keys, component)", node.componentKey.GetKey()))
+	if err != nil {
+		return err
+	}
+
+	// namespace is coming from the target label or from the default namespace of the cluster
+	err = node.checkConstraint("namespace", node.componentKey.TargetSuffix, constraints.MaxNamespaceLength,
+		fmt.Sprintf("label '%s' = '%s' (claim, user, service, context and rule labels) or default namespace of cluster '%s'", lang.LabelTarget, node.labels.Labels[lang.LabelTarget], node.cluster.Name))
+	if err != nil {
+		return err
+	}
+
+	// label values are coming from the code params
+	return node.checkLabelValues(codeParams, "", false, constraints.MaxLabelValueLength)
+}
+
+// checkLabelValues recursively walks code params and checks all values located under 'labels' keys
+func (node *resolutionNode) checkLabelValues(params map[string]interface{}, path string, isLabels bool, maxLength int) error {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyPath := strings.TrimPrefix(path+"."+key, ".")
+		switch value := params[key].(type) {
+		case util.NestedParameterMap:
+			if err := node.checkLabelValues(value, keyPath, key == labelsParamName, maxLength); err != nil {
+				return err
+			}
+		case map[string]interface{}:
+			if err := node.checkLabelValues(value, keyPath, key == labelsParamName, maxLength); err != nil {
+				return err
+			}
+		case string:
+			if !isLabels {
+				continue
+			}
+			err := node.checkConstraint(fmt.Sprintf("label '%s' value", key), value, maxLength,
+				fmt.Sprintf("code params of component '%s' in bundle '%s' (%s)", node.component.Name, node.bundle.Name, keyPath))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkConstraint checks that a given value doesn't exceed max length. Zero max length means there is no limit
+func (node *resolutionNode) checkConstraint(what string, value string, maxLength int, generatedFrom string) error {
+	if maxLength <= 0 || len(value) <= maxLength {
+		return nil
+	}
+	return node.errorConstraintViolated(what, value, maxLength, generatedFrom)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/Aptomi/aptomi/pkg/event"
 	"github.com/Aptomi/aptomi/pkg/lang"
 	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/util"
 	"github.com/davecgh/go-spew/spew"
 )
 
@@ -20,7 +21,7 @@ func (node *resolutionNode) errorUserDoesNotExist() error {
 }
 
 func (node *resolutionNode) errorClaimNotAllowedByRules() error {
-	return fmt.Errorf("rules do not allow claim '%s/%s' ('%s' -> '%s'): processing '%s', tree depth %d", node.claim.Metadata.Namespace, node.claim.Name, node.claim.User, node.claim.Service, node.serviceName, node.depth)
+	return fmt.Errorf("rules do not allow claim '%s/%s' ('%s' -> '%s'): processing '%s', tree depth %d", node.claim.Metadata.Namespace, node.claim.Name, node.claim.User, node.claimService, node.serviceName, node.depth)
 }
 
 func (node *resolutionNode) userNotAllowedToConsumeService(err error) error {
@@ -31,6 +32,10 @@ func (node *resolutionNode) errorTargetNotSet() error {
 	return fmt.Errorf("not sure where components should be deployed: label 'target' is not set (claim '%s', service '%s', bundle '%s')", node.claim.Name, node.service.Name, node.bundle.Name)
 }
 
+func (node *resolutionNode) errorWhenTestingPreference(cluster *lang.Cluster, cause error) error {
+	return fmt.Errorf("error while testing placement preferences of claim '%s/%s' against cluster '%s': %s", node.claim.Metadata.Namespace, node.claim.Name, runtime.KeyForStorable(cluster), printCauseDetailsOnDebug(cause, node.eventLog))
+}
+
 func (node *resolutionNode) errorClusterLookup(clusterName string, cause error) error {
 	return fmt.Errorf("cluster '%s' lookup error: %s (claim '%s', service '%s', bundle '%s')", clusterName, cause, node.claim.Name, node.service.Name, node.bundle.Name)
 }
@@ -67,8 +72,20 @@ func (node *resolutionNode) errorWhenProcessingDiscoveryParams(cause error) erro
 	return fmt.Errorf("error when processing discovery params for bundle '%s', service '%s', context '%s', component '%s': %s", node.bundle.Name, node.service.Name, node.context.Name, node.component.Name, printCauseDetailsOnDebug(cause, node.eventLog))
 }
 
-func (node *resolutionNode) errorBundleCycleDetected() error {
-	return fmt.Errorf("error when processing policy, bundle cycle detected: %s", node.path)
+func (node *resolutionNode) errorConstraintViolated(what string, value string, maxLength int, generatedFrom string) error {
+	return fmt.Errorf("%s '%s' (length %d) exceeds the limit of %d characters for cluster '%s' (type '%s'), it's generated from: %s", what, value, len(value), maxLength, node.cluster.Name, node.cluster.Type, generatedFrom)
+}
+
+func (node *resolutionNode) errorExclusionGroupConflict(group *exclusionGroup, clusterKey string, claimKey string) error {
+	if !group.feasible() {
+		return fmt.Errorf("claim '%s/%s' can't be placed on cluster '%s' already used by claim '%s': exclusion group '%s' has %d claims, but there are only %d clusters to place them on distinct clusters", node.claim.Metadata.Namespace, node.claim.Name, clusterKey, claimKey, group.name, len(group.claims), group.clusters)
+	}
+	return fmt.Errorf("claim '%s/%s' can't be placed on cluster '%s' already used by claim '%s' from the same exclusion group '%s'", node.claim.Metadata.Namespace, node.claim.Name, clusterKey, claimKey, group.name)
+}
+
+func (node *resolutionNode) errorBundleCycleDetected(cycleStart int) error {
+	// cycle starts with the bundle, which has been arrived to again, and ends with the same bundle
+	return &CycleError{Path: util.CopySliceOfStrings(node.objectPath[2*cycleStart+1:])}
 }
 
 /*
@@ -78,15 +95,19 @@ func (node *resolutionNode) errorBundleCycleDetected() error {
 func (node *resolutionNode) logStartResolvingClaim() {
 	if node.depth == 0 {
 		// at the top of the tree, when we resolve a root-level claim
-		node.eventLog.NewEntry().Infof("Resolving top-level claim '%s/%s' ('%s' -> '%s')", node.claim.Metadata.Namespace, node.claim.Name, node.claim.User, node.claim.Service)
+		node.eventLog.NewEntry().Infof("Resolving top-level claim '%s/%s' ('%s' -> '%s')", node.claim.Metadata.Namespace, node.claim.Name, node.claim.User, node.claimService)
 	} else {
 		// recursively processing the rest of the tree
-		node.eventLog.NewEntry().Infof("Resolving claim '%s/%s' ('%s' -> '%s'): processing '%s', tree depth %d", node.claim.Metadata.Namespace, node.claim.Name, node.claim.User, node.claim.Service, node.serviceName, node.depth)
+		node.eventLog.NewEntry().Infof("Resolving claim '%s/%s' ('%s' -> '%s'): processing '%s', tree depth %d", node.claim.Metadata.Namespace, node.claim.Name, node.claim.User, node.claimService, node.serviceName, node.depth)
 	}
 
 	node.logLabels(node.labels, "initial")
 }
 
+func (node *resolutionNode) logFallbackService(service string, cause error) {
+	node.eventLog.NewEntry().Warningf("Claim '%s/%s' can't be resolved via service '%s' (%s). Falling back to service '%s'", node.claim.Metadata.Namespace, node.claim.Name, service, cause, node.claimService)
+}
+
 func (node *resolutionNode) logLabels(labelSet *lang.LabelSet, scope string) {
 	secretCnt := 0
 	if node.user != nil {
@@ -131,6 +152,22 @@ func (node *resolutionNode) logTestedRuleMatch(rule *lang.Rule, match bool) {
 	node.eventLog.NewEntry().Debugf("Testing if rule '%s' applies in context '%s' within service '%s'. Result: %t", rule.Name, node.context.Name, node.service.Name, match)
 }
 
+func (node *resolutionNode) logPreferencesSkipped() {
+	node.eventLog.NewEntry().Debugf("Placement preferences of claim '%s/%s' skipped, target is set explicitly: %s", node.claim.Metadata.Namespace, node.claim.Name, node.labels.Labels[lang.LabelTarget])
+}
+
+func (node *resolutionNode) logClusterScored(cluster *lang.Cluster, score int) {
+	node.eventLog.NewEntry().Infof("Placement preferences of claim '%s/%s' scored cluster '%s': %d", node.claim.Metadata.Namespace, node.claim.Name, runtime.KeyForStorable(cluster), score)
+}
+
+func (node *resolutionNode) logPreferredClusterPicked(cluster *lang.Cluster, score int) {
+	if score <= 0 {
+		node.eventLog.NewEntry().Warningf("None of placement preferences of claim '%s/%s' can be satisfied, placing it on cluster '%s'", node.claim.Metadata.Namespace, node.claim.Name, runtime.KeyForStorable(cluster))
+	} else {
+		node.eventLog.NewEntry().Infof("Placing claim '%s/%s' on preferred cluster '%s' with score %d", node.claim.Metadata.Namespace, node.claim.Name, runtime.KeyForStorable(cluster), score)
+	}
+}
+
 func (node *resolutionNode) logAllocationKeysSuccessfullyResolved(resolvedKeys []string) {
 	if len(resolvedKeys) > 0 {
 		node.eventLog.NewEntry().Infof("Allocation keys successfully resolved for context '%s' within service '%s': %s", node.context.Name, node.service.Name, resolvedKeys)
@@ -150,7 +187,7 @@ func (node *resolutionNode) logResolvingClaimOnComponent() {
 func (node *resolutionNode) logInstanceSuccessfullyResolved(cik *ComponentInstanceKey) {
 	if node.depth == 0 && cik.IsBundle() {
 		// at the top of the tree, when we resolve a root-level claim
-		node.eventLog.NewEntry().Infof("Successfully resolved claim '%s/%s' ('%s' -> '%s'): %s", node.claim.Metadata.Namespace, node.claim.Name, node.user.Name, node.claim.Service, cik.GetKey())
+		node.eventLog.NewEntry().Infof("Successfully resolved claim '%s/%s' ('%s' -> '%s'): %s", node.claim.Metadata.Namespace, node.claim.Name, node.user.Name, node.claimService, cik.GetKey())
 	} else if cik.IsBundle() {
 		// resolved bundle instance
 		node.eventLog.NewEntry().Infof("Successfully resolved bundle instance '%s' -> '%s': %s", node.user.Name, node.service.Name, cik.GetKey())
@@ -183,7 +220,7 @@ func (resolver *PolicyResolver) logComponentParams(instance *ComponentInstance)
 
 	code := bundleObj.(*lang.Bundle).GetComponentsMap()[instance.Metadata.Key.ComponentName].Code
 	if code != nil {
-		cs := spew.ConfigState{Indent: "\t"}
+		cs := spew.ConfigState{Indent: "\t", SortKeys: true}
 
 		// log code params
 		resolver.eventLog.NewEntry().Debugf("Calculated final code params for component '%s': %s", instance.Metadata.Key.GetKey(), cs.Sdump(instance.CalculatedCodeParams))
@@ -197,7 +234,7 @@ func (resolver *PolicyResolver) logComponentParams(instance *ComponentInstance)
 func printCauseDetailsOnDebug(err error, eventLog *event.Log) error {
 	errWithDetails, isErrorWithDetails := err.(*errors.ErrorWithDetails)
 	if isErrorWithDetails {
-		cs := spew.ConfigState{Indent: "\t"}
+		cs := spew.ConfigState{Indent: "\t", SortKeys: true}
 		eventLog.NewEntry().Debug(cs.Sdump(errWithDetails.Details()))
 	}
 	return err
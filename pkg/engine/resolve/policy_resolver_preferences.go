@@ -0,0 +1,77 @@
+package resolve
+
+import (
+	"sort"
+
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/expression"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+// resolvePreferredTarget picks target cluster for a claim with placement preferences, when target hasn't been set
+// explicitly via labels. Candidate clusters (from the service namespace and system namespace) get scored by the sum of
+// weights of preferences they match, and the one with the highest score wins. Ties are broken by cluster key, so
+// the result is deterministic. Preferences are soft: if none of them match, the first candidate still gets picked
+func (node *resolutionNode) resolvePreferredTarget() error {
+	if len(node.claim.Preferred) <= 0 {
+		return nil
+	}
+
+	// explicit target always wins
+	if len(node.labels.Labels[lang.LabelTarget]) > 0 {
+		node.logPreferencesSkipped()
+		return nil
+	}
+
+	var best *lang.Cluster
+	bestScore := -1
+	for _, cluster := range node.getCandidateClusters() {
+		score, err := node.scoreCluster(cluster)
+		if err != nil {
+			return err
+		}
+		node.logClusterScored(cluster, score)
+		if score > bestScore {
+			best, bestScore = cluster, score
+		}
+	}
+
+	// no clusters at all, resolution will fail later on with target not set
+	if best == nil {
+		return nil
+	}
+
+	node.logPreferredClusterPicked(best, bestScore)
+	node.labels.Labels[lang.LabelTarget] = best.Namespace + "/" + best.Name
+	return nil
+}
+
+// getCandidateClusters returns clusters, which claim can be placed on, sorted by their keys
+func (node *resolutionNode) getCandidateClusters() []*lang.Cluster {
+	result := []*lang.Cluster{}
+	for _, obj := range node.resolver.policy.GetObjectsByKind(lang.TypeCluster.Kind) {
+		if obj.GetNamespace() == node.namespace || obj.GetNamespace() == runtime.SystemNS {
+			result = append(result, obj.(*lang.Cluster)) // nolint: errcheck
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return runtime.KeyForStorable(result[i]) < runtime.KeyForStorable(result[j])
+	})
+	return result
+}
+
+// scoreCluster returns the sum of weights of claim preferences, which a given cluster matches
+func (node *resolutionNode) scoreCluster(cluster *lang.Cluster) (int, error) {
+	params := expression.NewParams(cluster.Labels, map[string]interface{}{})
+	score := 0
+	for _, preference := range node.claim.Preferred {
+		matched, err := preference.Matches(params, node.resolver.expressionCache)
+		if err != nil {
+			return 0, node.errorWhenTestingPreference(cluster, err)
+		}
+		if matched {
+			score += preference.Weight
+		}
+	}
+	return score, nil
+}
@@ -2,15 +2,26 @@
 package resolve
 
 import (
+	"context"
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/Aptomi/aptomi/pkg/config"
 	"github.com/Aptomi/aptomi/pkg/event"
 	"github.com/Aptomi/aptomi/pkg/lang"
 	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/plugin"
+	"github.com/Aptomi/aptomi/pkg/plugin/fake"
+	"github.com/Aptomi/aptomi/pkg/runtime"
 	"github.com/Aptomi/aptomi/pkg/util"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestPolicyResolverSimple(t *testing.T) {
@@ -359,11 +370,77 @@ func TestPolicyResolverBundleLoop(t *testing.T) {
 	b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
 
 	// add claim
-	claim := b.AddClaim(b.AddUser(), service1)
+	b.AddClaim(b.AddUser(), service1)
+
+	// policy resolution with bundle cycle should fail with an error, which names all objects in the cycle
+	eventLog := event.NewLog(logrus.DebugLevel, "test-resolve")
+	resolution, err := NewPolicyResolver(b.Policy(), b.External(), eventLog).ResolveAllClaims(context.Background())
+	assert.Nil(t, resolution, "Policy with bundle cycle should not be resolved")
+	if !assert.True(t, IsCycleError(err), "Policy resolution should fail with cycle error, got: %s", err) {
+		t.FailNow()
+	}
+	path := err.(*CycleError).Path
+	if !assert.Len(t, path, 7, "All bundles and services should be in the cycle") {
+		t.FailNow()
+	}
+	assert.Equal(t, path[0], path[len(path)-1], "Cycle should end with the object it starts with")
+	for _, obj := range []lang.Base{bundle1, bundle2, bundle3, service1, service2, service3} {
+		assert.Contains(t, path, obj.GetKind()+" '"+obj.GetNamespace()+"/"+obj.GetName()+"'", "Object should be reported as a part of the cycle")
+	}
+}
 
-	// policy resolution with bundle cycle should should result in an error
+func TestPolicyResolverServiceBundleLoop(t *testing.T) {
+	b := builder.NewPolicyBuilder()
+
+	// create a bundle, which depends on the service allocating the same bundle
+	bundle := b.AddBundle()
+	service := b.AddService(bundle, b.CriteriaTrue())
+	b.AddBundleComponent(bundle, b.ServiceComponent(service))
+
+	// create an unrelated bundle without cycles
+	otherBundle := b.AddBundle()
+	b.AddBundleComponent(otherBundle, b.CodeComponent(nil, nil))
+	otherService := b.AddService(otherBundle, b.CriteriaTrue())
+
+	cluster := b.AddCluster()
+	b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+	otherClaim := b.AddClaim(b.AddUser(), otherService)
+
+	// cycle should not be reported, while there are no claims running into it
 	resolvePolicy(t, b, []verifyClaim{
-		{claim: claim, resolved: false, logMessage: "bundle cycle detected"},
+		{claim: otherClaim, resolved: true},
+	})
+
+	// once there is a claim on the service participating in the cycle, resolution should fail
+	b.AddClaim(b.AddUser(), service)
+	eventLog := event.NewLog(logrus.DebugLevel, "test-resolve")
+	_, err := NewPolicyResolver(b.Policy(), b.External(), eventLog).ResolveAllClaims(context.Background())
+	if !assert.True(t, IsCycleError(err), "Policy resolution should fail with cycle error, got: %s", err) {
+		t.FailNow()
+	}
+	assert.Contains(t, err.Error(), "service '"+service.Namespace+"/"+service.Name+"'", "Service should be named in the error")
+	assert.Contains(t, err.Error(), "bundle '"+bundle.Namespace+"/"+bundle.Name+"'", "Bundle should be named in the error")
+	assert.Len(t, err.(*CycleError).Path, 3, "Cycle should consist of the bundle and the service")
+}
+
+func TestPolicyResolverLoopNotMatchingCriteria(t *testing.T) {
+	b := builder.NewPolicyBuilder()
+
+	// create a bundle, which depends on the service allocating the same bundle, but only for the claims which never
+	// come to the bundle
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	loopComponent := b.AddBundleComponent(bundle, b.ServiceComponent(service))
+	loopComponent.Criteria = &lang.Criteria{RequireAll: []string{"label1 == 'loop'"}}
+
+	cluster := b.AddCluster()
+	b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+	claim := b.AddClaim(b.AddUser(), service)
+
+	// dependency on the service is never followed, so there is no cycle to report
+	resolvePolicy(t, b, []verifyClaim{
+		{claim: claim, resolved: true},
 	})
 }
 
@@ -405,6 +482,267 @@ func TestPolicyResolverPickClusterViaRules(t *testing.T) {
 	assert.Equal(t, cluster2.Name, instance2.CalculatedLabels.Labels[lang.LabelTarget], "Cluster should be set correctly via rules")
 }
 
+func TestPolicyResolverClaimWithFallbackService(t *testing.T) {
+	b := builder.NewPolicyBuilder()
+
+	// create primary service, which requires gpu
+	bundlePrimary := b.AddBundle()
+	b.AddBundleComponent(bundlePrimary, b.CodeComponent(nil, nil))
+	servicePrimary := b.AddService(bundlePrimary, b.CriteriaTrue())
+	servicePrimary.ChangeLabels = lang.NewLabelOperationsSetSingleLabel("gpu", "true")
+
+	// create fallback service, which doesn't require gpu
+	bundleFallback := b.AddBundle()
+	componentFallback := b.AddBundleComponent(bundleFallback, b.CodeComponent(nil, nil))
+	serviceFallback := b.AddService(bundleFallback, b.CriteriaTrue())
+	serviceFallback.ChangeLabels = lang.NewLabelOperationsSetSingleLabel("gpu", "false")
+
+	// add rule to set cluster, but there are no clusters with gpu
+	cluster := b.AddCluster()
+	b.AddRule(b.Criteria("gpu == 'false'", "true", "false"), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+
+	// add claim with a fallback service
+	claim := b.AddClaim(b.AddUser(), servicePrimary)
+	claim.Fallback = []string{serviceFallback.Namespace + "/" + serviceFallback.Name}
+
+	// claim should be resolved via fallback service, and the choice should be recorded in event log
+	resolution := resolvePolicy(t, b, []verifyClaim{
+		{claim: claim, resolved: true, logMessage: fmt.Sprintf("Falling back to service '%s'", claim.Fallback[0])},
+	})
+
+	instance := getInstanceByParams(t, cluster, "k8ns", serviceFallback, serviceFallback.Contexts[0], nil, bundleFallback, componentFallback, resolution)
+	assert.Equal(t, 1, len(instance.ClaimKeys), "Instance should be referenced by one claim")
+	assert.Equal(t, instance.Metadata.Key.GetParentBundleKey().GetKey(), resolution.GetClaimResolution(claim).ComponentInstanceKey, "Claim should be resolved via fallback service")
+}
+
+func TestPolicyResolverExclusionGroupInfeasible(t *testing.T) {
+	b := builder.NewPolicyBuilder()
+
+	// create primary service, which goes to the first cluster
+	bundlePrimary := b.AddBundle()
+	b.AddBundleComponent(bundlePrimary, b.CodeComponent(nil, nil))
+	servicePrimary := b.AddService(bundlePrimary, b.CriteriaTrue())
+	servicePrimary.ChangeLabels = lang.NewLabelOperationsSetSingleLabel("primary", "true")
+
+	// create fallback service, which goes to the second cluster
+	bundleFallback := b.AddBundle()
+	b.AddBundleComponent(bundleFallback, b.CodeComponent(nil, nil))
+	serviceFallback := b.AddService(bundleFallback, b.CriteriaTrue())
+	serviceFallback.ChangeLabels = lang.NewLabelOperationsSetSingleLabel("primary", "false")
+
+	cluster1 := b.AddCluster()
+	cluster2 := b.AddCluster()
+	b.AddRule(b.Criteria("primary == 'true'", "true", "false"), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster1.Name)))
+	b.AddRule(b.Criteria("primary == 'false'", "true", "false"), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster2.Name)))
+
+	// add three mutually exclusive claims, while there are only two clusters
+	claims := []*lang.Claim{}
+	for i := 0; i < 3; i++ {
+		claim := b.AddClaim(b.AddUser(), servicePrimary)
+		claim.Fallback = []string{serviceFallback.Namespace + "/" + serviceFallback.Name}
+		claim.ExclusionGroup = "tenants"
+		claims = append(claims, claim)
+	}
+
+	eventLog := event.NewLog(logrus.DebugLevel, "test-resolve")
+	resolution, resolveErr := NewPolicyResolver(b.Policy(), b.External(), eventLog).SetContinueOnError(true).ResolveAllClaims(context.Background())
+	if !assert.NoError(t, resolveErr, "Policy should be resolved without errors") {
+		t.FailNow()
+	}
+
+	// two claims should be resolved on distinct clusters, the last one can't be resolved
+	clusters := make(map[string]bool)
+	resolved := 0
+	for _, claim := range claims {
+		claimResolution := resolution.GetClaimResolution(claim)
+		if claimResolution.Resolved {
+			resolved++
+			clusters[resolution.ComponentInstanceMap[claimResolution.ComponentInstanceKey].Metadata.Key.ClusterName] = true
+		}
+	}
+	assert.Equal(t, 2, resolved, "Only two claims should be resolved")
+	assert.Equal(t, map[string]bool{cluster1.Name: true, cluster2.Name: true}, clusters, "Resolved claims should be placed on distinct clusters")
+
+	// infeasibility should be reported clearly
+	verifier := event.NewLogVerifier("exclusion group 'tenants' has 3 claims, but there are only 2 clusters", true)
+	eventLog.Save(verifier)
+	assert.True(t, verifier.MatchedErrorsCount() > 0, "Event log should report that there are not enough clusters for exclusion group")
+}
+
+func TestPolicyResolverPreferredClusterSatisfiable(t *testing.T) {
+	b := builder.NewPolicyBuilder()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+
+	clusterEU := b.AddCluster()
+	clusterEU.Labels = map[string]string{"region": "eu-west"}
+	clusterUS := b.AddCluster()
+	clusterUS.Labels = map[string]string{"region": "us-east"}
+
+	// claim doesn't set target, but prefers us-east
+	claim := b.AddClaim(b.AddUser(), service)
+	claim.Preferred = []*lang.PlacementPreference{
+		{Criteria: &lang.Criteria{RequireAll: []string{"region == 'us-east'"}}, Weight: 10},
+	}
+
+	eventLog := event.NewLog(logrus.DebugLevel, "test-resolve")
+	resolution, resolveErr := NewPolicyResolver(b.Policy(), b.External(), eventLog).ResolveAllClaims(context.Background())
+	if !assert.NoError(t, resolveErr, "Policy should be resolved without errors") {
+		t.FailNow()
+	}
+
+	// claim should be placed on the preferred cluster
+	claimResolution := resolution.GetClaimResolution(claim)
+	assert.True(t, claimResolution.Resolved, "Claim should be resolved")
+	assert.Equal(t, clusterUS.Name, resolution.ComponentInstanceMap[claimResolution.ComponentInstanceKey].Metadata.Key.ClusterName, "Claim should be placed on preferred cluster")
+
+	// scores of all candidate clusters should be recorded
+	for cluster, score := range map[*lang.Cluster]int{clusterEU: 0, clusterUS: 10} {
+		verifier := event.NewLogVerifier(fmt.Sprintf("scored cluster '%s': %d", runtime.KeyForStorable(cluster), score), false)
+		eventLog.Save(verifier)
+		assert.True(t, verifier.MatchedErrorsCount() > 0, "Event log should contain score of cluster %s", cluster.Name)
+	}
+}
+
+func TestPolicyResolverPreferredClusterNotSatisfiable(t *testing.T) {
+	b := builder.NewPolicyBuilder()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+
+	cluster1 := b.AddCluster()
+	cluster1.Labels = map[string]string{"region": "eu-west"}
+	cluster2 := b.AddCluster()
+	cluster2.Labels = map[string]string{"region": "us-east"}
+
+	// claim prefers the region, which no cluster is in
+	claim := b.AddClaim(b.AddUser(), service)
+	claim.Preferred = []*lang.PlacementPreference{
+		{Criteria: &lang.Criteria{RequireAll: []string{"region == 'ap-south'"}}, Weight: 10},
+	}
+
+	eventLog := event.NewLog(logrus.DebugLevel, "test-resolve")
+	resolution, resolveErr := NewPolicyResolver(b.Policy(), b.External(), eventLog).ResolveAllClaims(context.Background())
+	if !assert.NoError(t, resolveErr, "Policy should be resolved without errors") {
+		t.FailNow()
+	}
+
+	// claim should still be placed, on the first candidate cluster
+	expected := cluster1
+	if runtime.KeyForStorable(cluster2) < runtime.KeyForStorable(cluster1) {
+		expected = cluster2
+	}
+	claimResolution := resolution.GetClaimResolution(claim)
+	assert.True(t, claimResolution.Resolved, "Claim should be resolved even though preference can't be satisfied")
+	assert.Equal(t, expected.Name, resolution.ComponentInstanceMap[claimResolution.ComponentInstanceKey].Metadata.Key.ClusterName, "Claim should be placed on the first candidate cluster")
+
+	// scores should be recorded
+	for _, cluster := range []*lang.Cluster{cluster1, cluster2} {
+		verifier := event.NewLogVerifier(fmt.Sprintf("scored cluster '%s': 0", runtime.KeyForStorable(cluster)), false)
+		eventLog.Save(verifier)
+		assert.True(t, verifier.MatchedErrorsCount() > 0, "Event log should contain score of cluster %s", cluster.Name)
+	}
+	verifier := event.NewLogVerifier("None of placement preferences", false)
+	eventLog.Save(verifier)
+	assert.True(t, verifier.MatchedErrorsCount() > 0, "Event log should report that preferences can't be satisfied")
+}
+
+func TestPolicyResolverClusterConstraints(t *testing.T) {
+	b := builder.NewPolicyBuilder()
+
+	// create a bundle, which passes user-provided label into code params as label
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(util.NestedParameterMap{"labels": util.NestedParameterMap{"team": "{{ .Labels.team }}"}}, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+
+	// claims specify cluster and namespace via target label
+	cluster := b.AddCluster()
+	longValue := strings.Repeat("x", 64)
+
+	// claim with short names should be resolved
+	c1 := b.AddClaim(b.AddUser(), service)
+	c1.Labels["team"] = "dev"
+	c1.Labels[lang.LabelTarget] = cluster.Name + ".dev"
+
+	// claim with long label value should not be resolved
+	c2 := b.AddClaim(b.AddUser(), service)
+	c2.Labels["team"] = longValue
+	c2.Labels[lang.LabelTarget] = cluster.Name + ".dev"
+
+	// claim with long namespace should not be resolved
+	c3 := b.AddClaim(b.AddUser(), service)
+	c3.Labels["team"] = "dev"
+	c3.Labels[lang.LabelTarget] = cluster.Name + "." + longValue
+
+	clusterTypes := map[string]plugin.ClusterPluginConstructor{
+		"kubernetes": func(cluster *lang.Cluster, cfg config.Plugins) (plugin.ClusterPlugin, error) {
+			return fake.NewNoOpClusterPlugin(0), nil
+		},
+	}
+	pluginRegistry := plugin.NewRegistry(config.Plugins{}, clusterTypes, nil)
+
+	eventLog := event.NewLog(logrus.DebugLevel, "test-resolve")
+	resolution, resolveErr := NewPolicyResolver(b.Policy(), b.External(), eventLog).SetPluginRegistry(pluginRegistry).SetContinueOnError(true).ResolveAllClaims(context.Background())
+	if !assert.NoError(t, resolveErr, "Policy should be resolved without errors") {
+		t.FailNow()
+	}
+
+	assert.True(t, resolution.GetClaimResolution(c1).Resolved, "Claim with short names should be resolved")
+	assert.False(t, resolution.GetClaimResolution(c2).Resolved, "Claim with long label value should not be resolved")
+	assert.False(t, resolution.GetClaimResolution(c3).Resolved, "Claim with long namespace should not be resolved")
+
+	// errors should name the offending value, its length and where it came from
+	for _, message := range []string{
+		fmt.Sprintf("label 'team' value '%s' (length 64) exceeds the limit of 63 characters", longValue),
+		fmt.Sprintf("namespace '%s' (length 64) exceeds the limit of 63 characters", longValue),
+	} {
+		verifier := event.NewLogVerifier(message, true)
+		eventLog.Save(verifier)
+		assert.True(t, verifier.MatchedErrorsCount() > 0, "Event log should have an error message: %s", message)
+	}
+}
+
+func TestPolicyResolverContinueOnError(t *testing.T) {
+	b := builder.NewPolicyBuilder()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	cluster := b.AddCluster()
+	b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+
+	// two claims can be resolved, while the one declared by non-existing user can't
+	c1 := b.AddClaim(b.AddUser(), service)
+	c2 := b.AddClaim(b.AddUser(), service)
+	c3 := b.AddClaim(&lang.User{Name: "non-existing-user-123456789"}, service)
+
+	// by default, resolution should fail and name the claim which can't be resolved
+	_, err := NewPolicyResolver(b.Policy(), b.External(), event.NewLog(logrus.DebugLevel, "test-resolve")).ResolveAllClaims(context.Background())
+	if !assert.True(t, IsClaimsNotResolved(err), "ClaimsNotResolvedError should be returned in strict mode, got: %v", err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []string{runtime.KeyForStorable(c3)}, err.(*ClaimsNotResolvedError).Failed, "Failed claims should be reported")
+	assert.Equal(t, 3, err.(*ClaimsNotResolvedError).Total, "Total number of claims should be reported")
+	assert.Contains(t, err.Error(), "1 of 3 claims can't be resolved", "Error should report the number of failed claims")
+
+	// if resolver continues on error, other claims should be resolved
+	eventLog := event.NewLog(logrus.DebugLevel, "test-resolve")
+	resolver := NewPolicyResolver(b.Policy(), b.External(), eventLog).SetContinueOnError(true)
+	resolution, err := resolver.ResolveAllClaims(context.Background())
+	if !assert.NoError(t, err, "Policy should be resolved without errors") {
+		t.FailNow()
+	}
+	assert.True(t, resolution.GetClaimResolution(c1).Resolved, "Claim should be resolved")
+	assert.True(t, resolution.GetClaimResolution(c2).Resolved, "Claim should be resolved")
+	assert.False(t, resolution.GetClaimResolution(c3).Resolved, "Claim of non-existing user should not be resolved")
+	assert.Equal(t, []string{runtime.KeyForStorable(c3)}, resolver.FailedClaims(), "Failed claims should be reported")
+
+	// failure should be recorded in the event log
+	verifier := event.NewLogVerifier("non-existing user", true)
+	eventLog.Save(verifier)
+	assert.True(t, verifier.MatchedErrorsCount() > 0, "Event log should have an error message for the failed claim")
+}
+
 func TestPolicyResolverInternalPanic(t *testing.T) {
 	b := builder.NewPolicyBuilder()
 	b.PanicWhenLoadingUsers()
@@ -476,21 +814,256 @@ func TestPolicyResolverAllocationKeys(t *testing.T) {
 	}
 }
 
+func TestPolicyResolverCapacityReport(t *testing.T) {
+	b := builder.NewPolicyBuilder()
+
+	// create a bundle and a service, where every claim gets its own instance
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	service.Contexts[0].Allocation.Keys = b.AllocationKeys("{{ .Claim.ID }}")
+
+	// add rule to set cluster, which can only fit 3 instances
+	cluster := b.AddCluster()
+	cluster.Capacity = 3
+	b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+
+	// existing claim is already using capacity
+	existing := b.AddClaim(b.AddUser(), service)
+
+	// add more hypothetical claims than capacity allows
+	claims := []*lang.Claim{}
+	expected := []verifyClaim{{claim: existing, resolved: true}}
+	for i := 0; i < 5; i++ {
+		claim := b.AddClaim(b.AddUser(), service)
+		claims = append(claims, claim)
+		expected = append(expected, verifyClaim{claim: claim, resolved: true})
+	}
+
+	resolution := resolvePolicy(t, b, expected)
+	report := NewCapacityReport(b.Policy(), resolution, claims)
+
+	assert.Equal(t, 5, report.Claims, "All claims in question should be counted")
+	assert.Equal(t, 2, report.ClaimsFit, "Only 2 claims should fit into remaining capacity")
+	assert.Len(t, report.ClaimsNotFit, 3, "3 claims should not fit")
+	assert.Empty(t, report.ClaimsNotResolved, "All claims should be resolved")
+
+	usage := report.Clusters[runtime.KeyForStorable(cluster)]
+	if assert.NotNil(t, usage, "Capacity usage should be reported for cluster") {
+		assert.Equal(t, 3, usage.Capacity, "Cluster capacity should be reported")
+		assert.Equal(t, 3, usage.Used, "Cluster capacity should be fully used")
+		assert.Equal(t, 3, usage.Required, "3 more instances should be required")
+		assert.Equal(t, 3, usage.Shortfall, "Cluster capacity should be short of 3 instances")
+	}
+}
+
+func TestPolicyResolverTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prevProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(prevProvider)
+
+	b := builder.NewPolicyBuilder()
+
+	// create a bundle with a single code component
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(util.NestedParameterMap{"debug": "{{ .Labels.target }}"}, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+
+	// add rule to set cluster
+	cluster := b.AddCluster()
+	b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+
+	// add claims
+	c1 := b.AddClaim(b.AddUser(), service)
+	c2 := b.AddClaim(b.AddUser(), service)
+
+	// policy resolution should be completed successfully
+	resolvePolicy(t, b, []verifyClaim{
+		{claim: c1, resolved: true},
+		{claim: c2, resolved: true},
+	})
+
+	// spans should be emitted for all resolution phases
+	spanCount := make(map[string]int)
+	for _, span := range exporter.GetSpans() {
+		spanCount[span.Name]++
+	}
+
+	assert.Equal(t, 1, spanCount[SpanResolveAllClaims], "Single span should be emitted for resolution of all claims")
+	assert.Equal(t, 2, spanCount[SpanResolveClaim], "Span should be emitted for every claim")
+	assert.True(t, spanCount[SpanRuleEval] >= 2, "Span should be emitted for rule evaluation of every claim")
+	assert.True(t, spanCount[SpanPlacement] >= 2, "Span should be emitted for placement of every claim")
+	assert.True(t, spanCount[SpanParameters] >= 2, "Span should be emitted for parameters calculation of every claim")
+}
+
 /*
 	Helpers
 */
 
+func TestPolicyResolverConcurrencyStableResult(t *testing.T) {
+	b := builder.NewPolicyBuilder()
+
+	// create a bundle, which uses label in its code parameters
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle,
+		b.CodeComponent(
+			util.NestedParameterMap{"address": "{{ .Labels.deplabel }}"},
+			util.NestedParameterMap{"url": "component-{{ .Discovery.Instance }}"},
+		),
+	)
+	service := b.AddServiceMultipleContexts(bundle,
+		b.Criteria("label1 == 'value1'", "true", "false"),
+		b.CriteriaTrue(),
+	)
+	cluster := b.AddCluster()
+	b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+
+	// add claims sharing component instances, some of them feeding conflicting labels into a shared component
+	for i := 0; i < 30; i++ {
+		claim := b.AddClaim(b.AddUser(), service)
+		if i%2 == 0 {
+			claim.Labels["label1"] = "value1"
+			claim.Labels["deplabel"] = strconv.Itoa(i)
+		}
+	}
+
+	// resolve policy a few times with different concurrency and get event log messages and resolved instances. Error
+	// details dumped on debug level contain pointers, which differ between runs, so they get masked
+	pointers := regexp.MustCompile(`0x[0-9a-f]+`)
+	resolveWith := func(maxConcurrency int) ([]string, map[string]bool) {
+		eventLog := event.NewLog(logrus.DebugLevel, "test-resolve")
+		resolution, resolveErr := NewPolicyResolver(b.Policy(), b.External(), eventLog).SetMaxConcurrency(maxConcurrency).SetContinueOnError(true).ResolveAllClaims(context.Background())
+		if !assert.NoError(t, resolveErr, "Policy should be resolved without errors") {
+			t.FailNow()
+		}
+
+		messages := []string{}
+		for _, e := range eventLog.AsAPIEvents() {
+			messages = append(messages, pointers.ReplaceAllString(e.Message, "0x"))
+		}
+		instances := make(map[string]bool)
+		for key, instance := range resolution.ComponentInstanceMap {
+			instances[key] = instance.Error == nil
+		}
+		return messages, instances
+	}
+
+	expectedMessages, expectedInstances := resolveWith(1)
+	assert.NotEmpty(t, expectedMessages, "Event log should not be empty")
+	for _, maxConcurrency := range []int{2, 8, 32} {
+		for i := 0; i < 3; i++ {
+			messages, instances := resolveWith(maxConcurrency)
+			assert.Equal(t, expectedInstances, instances, "Resolved instances should not depend on concurrency (%d)", maxConcurrency)
+			assert.Equal(t, expectedMessages, messages, "Event log should not depend on concurrency (%d)", maxConcurrency)
+		}
+	}
+}
+
 type verifyClaim struct {
 	claim      *lang.Claim
 	resolved   bool
 	logMessage string
 }
 
+func TestPolicyResolverResolveClaim(t *testing.T) {
+	b := builder.NewPolicyBuilder()
+
+	// create two independent services
+	bundle1 := b.AddBundle()
+	b.AddBundleComponent(bundle1, b.CodeComponent(util.NestedParameterMap{"name": "{{ .Labels.name }}"}, nil))
+	service1 := b.AddService(bundle1, b.CriteriaTrue())
+	bundle2 := b.AddBundle()
+	b.AddBundleComponent(bundle2, b.CodeComponent(nil, nil))
+	service2 := b.AddService(bundle2, b.CriteriaTrue())
+
+	cluster := b.AddCluster()
+	b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+
+	c1 := b.AddClaim(b.AddUser(), service1)
+	c1.Labels["name"] = "first"
+	c2 := b.AddClaim(b.AddUser(), service2)
+
+	full := resolvePolicy(t, b, []verifyClaim{
+		{claim: c1, resolved: true},
+		{claim: c2, resolved: true},
+	})
+
+	// resolve only the first claim
+	eventLog := event.NewLog(logrus.DebugLevel, "test-resolve")
+	partial, err := NewPolicyResolver(b.Policy(), b.External(), eventLog).ResolveClaim(context.Background(), runtime.KeyForStorable(c1))
+	if !assert.NoError(t, err, "Claim should be resolved without errors") {
+		t.FailNow()
+	}
+	assert.True(t, partial.GetClaimResolution(c1).Resolved, "Claim should be resolved")
+	assert.False(t, partial.GetClaimResolution(c2).Resolved, "Other claim should not be resolved")
+
+	// partial resolution should contain the same instances as the full one, but only for the requested claim
+	assert.NotEmpty(t, partial.ComponentInstanceMap, "Instances of the claim should be resolved")
+	for key, instance := range partial.ComponentInstanceMap {
+		fullInstance, found := full.ComponentInstanceMap[key]
+		if !assert.True(t, found, "Instance %s should be present in full resolution", key) {
+			continue
+		}
+		assert.Contains(t, instance.ClaimKeys, runtime.KeyForStorable(c1), "Instance %s should be used by the requested claim", key)
+		assert.NotContains(t, instance.ClaimKeys, runtime.KeyForStorable(c2), "Instance %s should not be used by other claim", key)
+		assert.Equal(t, fullInstance.CalculatedCodeParams, instance.CalculatedCodeParams, "Instance %s should have the same code params", key)
+	}
+	assert.True(t, len(partial.ComponentInstanceMap) < len(full.ComponentInstanceMap), "Instances of other claim should not be resolved")
+
+	// claim, which doesn't exist, can't be resolved
+	_, err = NewPolicyResolver(b.Policy(), b.External(), event.NewLog(logrus.DebugLevel, "test-resolve")).ResolveClaim(context.Background(), runtime.KeyFromParts(c1.Namespace, lang.TypeClaim.Kind, "missing"))
+	assert.Error(t, err, "Missing claim should not be resolved")
+}
+
+func TestPolicyResolverResolveClaimExclusionGroup(t *testing.T) {
+	b := builder.NewPolicyBuilder()
+
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	b.AddCluster()
+	cluster := b.AddCluster()
+	b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+
+	// add mutually exclusive claims, while all of them go to the same cluster
+	claims := []*lang.Claim{}
+	for i := 0; i < 2; i++ {
+		claim := b.AddClaim(b.AddUser(), service)
+		claim.ExclusionGroup = "tenants"
+		claims = append(claims, claim)
+	}
+
+	eventLog := event.NewLog(logrus.DebugLevel, "test-resolve")
+	full, err := NewPolicyResolver(b.Policy(), b.External(), eventLog).SetContinueOnError(true).ResolveAllClaims(context.Background())
+	if !assert.NoError(t, err, "Policy should be resolved without errors") {
+		t.FailNow()
+	}
+
+	// every claim should get the same outcome, when resolved on its own
+	resolved := 0
+	for _, claim := range claims {
+		partial, resolveErr := NewPolicyResolver(b.Policy(), b.External(), event.NewLog(logrus.DebugLevel, "test-resolve")).ResolveClaim(context.Background(), runtime.KeyForStorable(claim))
+		if !assert.NoError(t, resolveErr, "Claim should be resolved without errors") {
+			continue
+		}
+		assert.Equal(t, full.GetClaimResolution(claim), partial.GetClaimResolution(claim), "Claim should be resolved the same way as with all claims")
+		if partial.GetClaimResolution(claim).Resolved {
+			resolved++
+		}
+	}
+	assert.Equal(t, 1, resolved, "Only one claim from exclusion group should be resolved")
+}
+
 func resolvePolicy(t *testing.T, builder *builder.PolicyBuilder, expected []verifyClaim) *PolicyResolution {
 	t.Helper()
 	eventLog := event.NewLog(logrus.DebugLevel, "test-resolve")
-	resolver := NewPolicyResolver(builder.Policy(), builder.External(), eventLog)
-	result := resolver.ResolveAllClaims()
+	resolver := NewPolicyResolver(builder.Policy(), builder.External(), eventLog).SetContinueOnError(true)
+	result, resolveErr := resolver.ResolveAllClaims(context.Background())
+	if !assert.NoError(t, resolveErr, "Policy should be resolved without errors") {
+		t.FailNow()
+	}
 
 	// check status of all claims
 	for _, check := range expected {
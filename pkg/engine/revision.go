@@ -1,6 +1,8 @@
 package engine
 
 import (
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/Aptomi/aptomi/pkg/engine/apply/action"
@@ -9,6 +11,8 @@ import (
 )
 
 const (
+	// RevisionStatusResolving represents Revision status when it has been created, but its desired state is still being calculated in the background
+	RevisionStatusResolving = "resolving"
 	// RevisionStatusWaiting represents Revision status when it has been created, but apply haven't started yet
 	RevisionStatusWaiting = "waiting"
 	// RevisionStatusInProgress represents Revision status with apply in progress
@@ -17,6 +21,8 @@ const (
 	RevisionStatusCompleted = "completed"
 	// RevisionStatusError represents Revision status when a critical error happened (we should rarely see those)
 	RevisionStatusError = "error"
+	// RevisionStatusSuperseded represents Revision status when it has been skipped, because a newer revision got resolved before apply started
+	RevisionStatusSuperseded = "superseded"
 )
 
 // RevisionKey is the default key for the Revision object (there is only one Revision exists but with multiple generations)
@@ -30,7 +36,7 @@ var TypeRevision = &runtime.TypeInfo{
 	Constructor: func() runtime.Object { return &Revision{} },
 	IndexValueTransforms: map[string]runtime.ValueTransform{
 		"Status": func(val interface{}) interface{} {
-			if val.(string) == RevisionStatusCompleted {
+			if val.(string) == RevisionStatusCompleted || val.(string) == RevisionStatusSuperseded {
 				return nil
 			}
 			return val
@@ -53,6 +59,14 @@ type Revision struct {
 	Result    *action.ApplyResult
 	AppliedAt time.Time
 
+	// ResolutionError is set when desired state couldn't be calculated in the background, so revision can't be applied
+	ResolutionError string `yaml:",omitempty"`
+
+	// TraceID and SpanID identify the tracing span of the API request, which created the revision, so it could be
+	// found in the tracing backend and enforcement spans could be linked to it. They are only set when tracing is on
+	TraceID string `yaml:",omitempty"`
+	SpanID  string `yaml:",omitempty"`
+
 	// TODO: do not store apply log in revision
 	ApplyLog []*event.APIEvent
 }
@@ -72,6 +86,11 @@ func NewRevision(gen runtime.Generation, policyGen runtime.Generation, recalcula
 	}
 }
 
+// IsFinished returns true if revision will not be processed any further
+func (revision *Revision) IsFinished() bool {
+	return revision.Status == RevisionStatusCompleted || revision.Status == RevisionStatusError || revision.Status == RevisionStatusSuperseded
+}
+
 // GetName returns Revision name
 func (revision *Revision) GetName() string {
 	return runtime.EmptyName
@@ -91,3 +110,38 @@ func (revision *Revision) GetGeneration() runtime.Generation {
 func (revision *Revision) SetGeneration(gen runtime.Generation) {
 	revision.Metadata.Generation = gen
 }
+
+// GetDefaultColumns returns default set of columns to be displayed
+func (revision *Revision) GetDefaultColumns() []string {
+	return []string{"Revision", "Policy", "Status", "Created", "Applied", "Progress"}
+}
+
+// AsColumns returns Revision representation as columns
+func (revision *Revision) AsColumns() map[string]string {
+	result := make(map[string]string)
+
+	result["Revision"] = revision.GetGeneration().String()
+	result["Policy"] = revision.PolicyGen.String()
+	result["Status"] = revision.Status
+	result["Created"] = formatTime(revision.CreatedAt)
+	result["Applied"] = formatTime(revision.AppliedAt)
+	result["Recalculate All"] = strconv.FormatBool(revision.RecalculateAll)
+
+	if revision.Result != nil {
+		result["Progress"] = fmt.Sprintf("%d/%d", revision.Result.Processed(), revision.Result.Total)
+		result["Success"] = strconv.FormatUint(uint64(revision.Result.Success), 10)
+		result["Failed"] = strconv.FormatUint(uint64(revision.Result.Failed), 10)
+		result["Skipped"] = strconv.FormatUint(uint64(revision.Result.Skipped), 10)
+		result["Previously Completed"] = strconv.FormatUint(uint64(revision.Result.PreviouslyCompleted), 10)
+		result["Total"] = strconv.FormatUint(uint64(revision.Result.Total), 10)
+	}
+
+	return result
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
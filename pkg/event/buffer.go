@@ -0,0 +1,151 @@
+package event
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OverflowMode defines what happens with debug/info events when event log buffer exceeds its limit
+type OverflowMode string
+
+const (
+	// OverflowSpill means that older debug/info events will be spilled into a temporary file on disk
+	OverflowSpill OverflowMode = "spill"
+
+	// OverflowDrop means that older debug/info events will be dropped (and counted)
+	OverflowDrop OverflowMode = "drop"
+)
+
+// BufferConfig represents configuration of the in-memory buffer of the event log
+type BufferConfig struct {
+	// MaxEntries is the max number of events kept in memory per event log, 0 means unlimited
+	MaxEntries int
+
+	// Overflow defines what to do with older debug/info events when MaxEntries exceeded (spill or drop), warnings
+	// and errors are always retained in memory
+	Overflow OverflowMode
+
+	// SpillDir is a directory to create temporary spill files in (default system temp dir will be used if empty)
+	SpillDir string
+}
+
+var (
+	defaultBufferConfigMu = &sync.RWMutex{}
+	defaultBufferConfig   = BufferConfig{}
+)
+
+// SetDefaultBufferConfig sets buffer config to be used by all event logs created after this call
+func SetDefaultBufferConfig(cfg BufferConfig) {
+	defaultBufferConfigMu.Lock()
+	defer defaultBufferConfigMu.Unlock()
+
+	defaultBufferConfig = cfg
+}
+
+// GetDefaultBufferConfig returns buffer config used for the newly created event logs
+func GetDefaultBufferConfig() BufferConfig {
+	defaultBufferConfigMu.RLock()
+	defer defaultBufferConfigMu.RUnlock()
+
+	return defaultBufferConfig
+}
+
+// bufferedEntry is a log entry together with its sequence number in the event log
+type bufferedEntry struct {
+	seq   uint64
+	entry *logrus.Entry
+}
+
+// spilledEntry is a representation of the log entry stored in the spill file
+type spilledEntry struct {
+	Seq     uint64
+	Time    time.Time
+	Level   logrus.Level
+	Message string
+	Data    map[string]interface{}
+}
+
+// spillFile stores entries evicted from memory in a temporary file. File gets unlinked right after creation, so it
+// will be removed automatically once closed (or garbage collected)
+type spillFile struct {
+	file   *os.File
+	writer *bufio.Writer
+	count  int
+}
+
+func newSpillFile(dir string) (*spillFile, error) {
+	file, err := ioutil.TempFile(dir, "aptomi-event-log-")
+	if err != nil {
+		return nil, fmt.Errorf("error while creating event log spill file: %s", err)
+	}
+
+	// unlink file right away, it'll stay accessible through the open file descriptor
+	_ = os.Remove(file.Name()) // nolint: gas
+
+	return &spillFile{file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+func (spill *spillFile) write(e *bufferedEntry) error {
+	data := make(map[string]interface{}, len(e.entry.Data))
+	for key, value := range e.entry.Data {
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		data[key] = value
+	}
+
+	spilled := &spilledEntry{
+		Seq:     e.seq,
+		Time:    e.entry.Time,
+		Level:   e.entry.Level,
+		Message: e.entry.Message,
+		Data:    data,
+	}
+	bytes, err := json.Marshal(spilled)
+	if err != nil {
+		// some of the attached details can't be marshaled, so let's store them as strings
+		for key, value := range data {
+			data[key] = fmt.Sprintf("%v", value)
+		}
+		bytes, err = json.Marshal(spilled)
+		if err != nil {
+			return fmt.Errorf("error while marshaling event log entry for spilling: %s", err)
+		}
+	}
+
+	_, err = spill.writer.Write(append(bytes, '\n'))
+	if err != nil {
+		return fmt.Errorf("error while writing event log entry into spill file: %s", err)
+	}
+	spill.count++
+
+	return nil
+}
+
+// reader returns a decoder to read back spilled entries from the beginning of the file
+func (spill *spillFile) reader() (*json.Decoder, error) {
+	err := spill.writer.Flush()
+	if err != nil {
+		return nil, fmt.Errorf("error while flushing event log spill file: %s", err)
+	}
+
+	// use section reader, so that reading doesn't affect the write position of the file
+	info, err := spill.file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error while reading event log spill file info: %s", err)
+	}
+
+	return json.NewDecoder(io.NewSectionReader(spill.file, 0, info.Size())), nil
+}
+
+func (spill *spillFile) close() error {
+	return spill.file.Close()
+}
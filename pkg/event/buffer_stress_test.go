@@ -0,0 +1,92 @@
+package event_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/engine/enginetest"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventLogBufferStress(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping event log stress test in short mode")
+	}
+
+	spillDir, err := ioutil.TempDir("", "aptomi-event-log-stress-")
+	if !assert.NoError(t, err, "Spill dir should be created") {
+		t.FailNow()
+	}
+	defer os.RemoveAll(spillDir) // nolint: errcheck
+
+	prevConfig := event.GetDefaultBufferConfig()
+	defer event.SetDefaultBufferConfig(prevConfig)
+
+	// with debug logging, every claim of the synthetic policy produces events for all services it depends on, so even
+	// the small policy results in hundreds of thousands of events, taking hundreds of megabytes when kept in memory
+	synthetic := enginetest.NewSyntheticPolicy(enginetest.SyntheticPolicySmall)
+	unbounded, unboundedEvents := eventLogHeapSize(t, synthetic, event.BufferConfig{})
+	fdsBefore := openFiles()
+	bounded, boundedEvents := eventLogHeapSize(t, synthetic, event.BufferConfig{MaxEntries: 1000, Overflow: event.OverflowSpill, SpillDir: spillDir})
+	t.Logf("Event log heap size: unbounded = %d bytes (%d events), bounded = %d bytes (%d events)", unbounded, unboundedEvents, bounded, boundedEvents)
+
+	assert.True(t, unboundedEvents > 100000, "Resolution should produce hundreds of thousands of events")
+	assert.Equal(t, unboundedEvents, boundedEvents, "Spilled events should be read back")
+	assert.True(t, bounded*10 < unbounded, "Bounded event log should use significantly less memory than unbounded one")
+
+	// spill files are unlinked right after creation, so they only hold file descriptors until event logs are closed
+	if fdsBefore >= 0 {
+		assert.Equal(t, fdsBefore, openFiles(), "Spill files should be closed")
+	}
+	spilled, err := ioutil.ReadDir(spillDir)
+	assert.NoError(t, err, "Spill dir should be read")
+	assert.Empty(t, spilled, "Spill files should be removed")
+}
+
+// eventLogHeapSize resolves a given policy with debug logging and returns the heap size taken by its event log along
+// with the number of events in it. Event logs of all resolution nodes use a given buffer config
+func eventLogHeapSize(t *testing.T, synthetic *enginetest.SyntheticPolicy, config event.BufferConfig) (int64, int) {
+	t.Helper()
+	event.SetDefaultBufferConfig(config)
+
+	eventLog := event.NewLog(logrus.DebugLevel, "stress")
+	resolution, err := resolve.NewPolicyResolver(synthetic.Policy, synthetic.External, eventLog).SetContinueOnError(true).ResolveAllClaims(context.Background())
+	if !assert.NoError(t, err, "Policy should be resolved without errors") {
+		t.FailNow()
+	}
+	if config.MaxEntries > 0 {
+		inMemory, _ := eventLog.GetBufferStats()
+		assert.True(t, inMemory <= config.MaxEntries, "In-memory buffer should be bounded")
+	}
+
+	// heap size of the event log is the difference between heap in use with and without it, while resolution is kept
+	withLog := heapInUse()
+	events := len(eventLog.AsAPIEvents())
+	assert.NoError(t, eventLog.Close(), "Event log should be closed")
+	withoutLog := heapInUse()
+	runtime.KeepAlive(resolution)
+
+	return withLog - withoutLog, events
+}
+
+func heapInUse() int64 {
+	runtime.GC()
+	stats := &runtime.MemStats{}
+	runtime.ReadMemStats(stats)
+	return int64(stats.HeapInuse)
+}
+
+// openFiles returns the number of file descriptors open by the process or -1 if it can't be determined
+func openFiles() int {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	return len(fds)
+}
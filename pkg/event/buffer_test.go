@@ -0,0 +1,60 @@
+package event
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventLogBufferSpill(t *testing.T) {
+	eventLog := NewLogWithBuffer(logrus.DebugLevel, "test", BufferConfig{MaxEntries: 10, Overflow: OverflowSpill})
+	defer eventLog.Close() // nolint: errcheck
+
+	for i := 0; i < 100; i++ {
+		if i%10 == 0 {
+			eventLog.NewEntry().Warningf("warning %d", i)
+		} else {
+			eventLog.NewEntry().Debugf("debug %d", i)
+		}
+	}
+
+	inMemory, dropped := eventLog.GetBufferStats()
+	assert.True(t, inMemory <= 20, "In-memory buffer should be bounded (warnings are always retained)")
+	assert.EqualValues(t, 0, dropped, "Nothing should be dropped in spill mode")
+
+	events := eventLog.AsAPIEvents()
+	if assert.Len(t, events, 100, "All events should be read back, including spilled ones") {
+		for i, e := range events {
+			if i%10 == 0 {
+				assert.Equal(t, fmt.Sprintf("warning %d", i), e.Message)
+				assert.Equal(t, "warning", e.LogLevel)
+			} else {
+				assert.Equal(t, fmt.Sprintf("debug %d", i), e.Message)
+				assert.Equal(t, "debug", e.LogLevel)
+			}
+		}
+	}
+}
+
+func TestEventLogBufferDrop(t *testing.T) {
+	eventLog := NewLogWithBuffer(logrus.DebugLevel, "test", BufferConfig{MaxEntries: 10, Overflow: OverflowDrop})
+	defer eventLog.Close() // nolint: errcheck
+
+	for i := 0; i < 100; i++ {
+		eventLog.NewEntry().Infof("info %d", i)
+	}
+	eventLog.NewEntry().Errorf("error")
+
+	inMemory, dropped := eventLog.GetBufferStats()
+	assert.True(t, inMemory <= 10, "In-memory buffer should be bounded")
+	assert.EqualValues(t, 101-inMemory, dropped, "Evicted events should be counted as dropped")
+
+	events := eventLog.AsAPIEvents()
+	assert.Len(t, events, inMemory+1, "Retained events and a warning about dropped events should be returned")
+	assert.Equal(t, "info 99", events[len(events)-3].Message)
+	assert.Equal(t, "error", events[len(events)-2].Message)
+	assert.Equal(t, "warning", events[len(events)-1].LogLevel)
+	assert.Contains(t, events[len(events)-1].Message, "dropped")
+}
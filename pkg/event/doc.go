@@ -3,5 +3,7 @@
 // Event logs are user-friendly logs (e.g. policy resolution log, policy apply log), which eventually get
 // shown to the end-users through UI. Unlike standard logs, event logs are fully stored in memory before
 // they get persisted. This is required in order for the engine to attach "details" to every log record.
+// In-memory buffer could be bounded (see BufferConfig), in which case older debug/info entries are spilled to disk
+// or dropped, while warnings and errors are always retained.
 // This package also provides a mock logger, which can be useful in unit tests.
 package event
@@ -1,7 +1,9 @@
 package event
 
 import (
+	"fmt"
 	"io/ioutil"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -22,8 +24,14 @@ type Log struct {
 // NewLog creates a new instance of event log.
 // Initially it just buffers all entries and doesn't write them.
 // It needs to buffer all entries, so that the context can be later attached to them
-// before they get serialized and written to an external source
+// before they get serialized and written to an external source.
+// Default buffer config (see SetDefaultBufferConfig) is used to limit the number of entries kept in memory
 func NewLog(level logrus.Level, scope string) *Log {
+	return NewLogWithBuffer(level, scope, GetDefaultBufferConfig())
+}
+
+// NewLogWithBuffer creates a new instance of event log with the specified buffer config
+func NewLogWithBuffer(level logrus.Level, scope string, bufferConfig BufferConfig) *Log {
 	logger := &logrus.Logger{
 		Out:       ioutil.Discard,
 		Formatter: new(logrus.TextFormatter),
@@ -31,7 +39,7 @@ func NewLog(level logrus.Level, scope string) *Log {
 		Level:     level,
 	}
 
-	hookMemory := &HookMemory{}
+	hookMemory := NewHookMemory(bufferConfig)
 	logger.Hooks.Add(hookMemory)
 
 	return &Log{
@@ -81,28 +89,59 @@ func (eventLog *Log) NewEntry() *logrus.Entry {
 
 // Append adds entries to the event logs
 func (eventLog *Log) Append(that *Log) {
-	for _, thatEntry := range that.hookMemory.entries {
-		entry := &logrus.Entry{
+	err := that.hookMemory.forEach(that.logger, func(thatEntry *logrus.Entry) error {
+		eventLog.logEntry(&logrus.Entry{
 			Logger:  eventLog.logger,
 			Data:    thatEntry.Data,
 			Time:    thatEntry.Time,
 			Level:   thatEntry.Level,
 			Message: thatEntry.Message,
+		})
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// AppendAPIEvents adds entries to the event log from given APIEvents (e.g. saved earlier from another event log),
+// keeping their time, level and message. Events with unknown level are added as info
+func (eventLog *Log) AppendAPIEvents(events []*APIEvent) {
+	for _, e := range events {
+		level, err := logrus.ParseLevel(e.LogLevel)
+		if err != nil {
+			level = logrus.InfoLevel
 		}
-		switch entry.Level {
-		case logrus.PanicLevel:
-			entry.Panic(entry.Message)
-		case logrus.FatalLevel:
-			entry.Fatal(entry.Message)
-		case logrus.ErrorLevel:
-			entry.Error(entry.Message)
-		case logrus.WarnLevel:
-			entry.Warn(entry.Message)
-		case logrus.InfoLevel:
-			entry.Info(entry.Message)
-		case logrus.DebugLevel:
-			entry.Debug(entry.Message)
+
+		data := logrus.Fields{}
+		for key, value := range eventLog.fixedFields {
+			data[key] = value
 		}
+		eventLog.logEntry(&logrus.Entry{
+			Logger:  eventLog.logger,
+			Data:    data,
+			Time:    e.Time,
+			Level:   level,
+			Message: e.Message,
+		})
+	}
+}
+
+// logEntry logs a given entry with its level
+func (eventLog *Log) logEntry(entry *logrus.Entry) {
+	switch entry.Level {
+	case logrus.PanicLevel:
+		entry.Panic(entry.Message)
+	case logrus.FatalLevel:
+		entry.Fatal(entry.Message)
+	case logrus.ErrorLevel:
+		entry.Error(entry.Message)
+	case logrus.WarnLevel:
+		entry.Warn(entry.Message)
+	case logrus.InfoLevel:
+		entry.Info(entry.Message)
+	case logrus.DebugLevel:
+		entry.Debug(entry.Message)
 	}
 }
 
@@ -111,12 +150,34 @@ func (eventLog *Log) AddFixedField(name string, value string) {
 	eventLog.fixedFields[name] = value
 }
 
-// Save takes all buffered event log entries and saves them
+// Save takes all buffered event log entries (including the ones spilled to disk) and saves them. If some entries were
+// dropped because of the buffer overflow, an additional warning entry will be saved in the end
 func (eventLog *Log) Save(hook logrus.Hook) {
-	for _, e := range eventLog.hookMemory.entries {
-		err := hook.Fire(e)
+	err := eventLog.hookMemory.forEach(eventLog.logger, hook.Fire)
+	if err != nil {
+		panic(err)
+	}
+
+	if dropped := eventLog.hookMemory.Dropped(); dropped > 0 {
+		err = hook.Fire(&logrus.Entry{
+			Logger:  eventLog.logger,
+			Data:    logrus.Fields{"scope": eventLog.scope},
+			Time:    time.Now(),
+			Level:   logrus.WarnLevel,
+			Message: fmt.Sprintf("%d debug/info events were dropped, event log exceeded its buffer limit of %d events", dropped, eventLog.hookMemory.config.MaxEntries),
+		})
 		if err != nil {
 			panic(err)
 		}
 	}
 }
+
+// GetBufferStats returns the number of entries kept in memory and the number of dropped entries
+func (eventLog *Log) GetBufferStats() (inMemory int, dropped uint64) {
+	return eventLog.hookMemory.InMemory(), eventLog.hookMemory.Dropped()
+}
+
+// Close releases resources held by event log (e.g. spill file), event log shouldn't be used after it's closed
+func (eventLog *Log) Close() error {
+	return eventLog.hookMemory.close()
+}
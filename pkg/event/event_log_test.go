@@ -0,0 +1,36 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventLogAppendAPIEvents(t *testing.T) {
+	eventLog := NewLog(logrus.DebugLevel, "test-source")
+	eventLog.NewEntry().Debug("debug message")
+	eventLog.NewEntry().Info("info message")
+	eventLog.NewEntry().Warn("warning message")
+	events := eventLog.AsAPIEvents()
+
+	// events get replayed as they were logged
+	replayed := NewLog(logrus.DebugLevel, "test-target")
+	replayed.AppendAPIEvents(events)
+	replayedEvents := replayed.AsAPIEvents()
+	if assert.Len(t, replayedEvents, len(events), "All events should be replayed") {
+		for i, e := range events {
+			assert.Equal(t, e.LogLevel, replayedEvents[i].LogLevel, "Event level should be kept")
+			assert.Equal(t, e.Message, replayedEvents[i].Message, "Event message should be kept")
+		}
+	}
+
+	// events below the level of event log are skipped
+	filtered := NewLog(logrus.InfoLevel, "test-target")
+	filtered.AppendAPIEvents(events)
+	filteredEvents := filtered.AsAPIEvents()
+	if assert.Len(t, filteredEvents, 2, "Only events of event log level should be replayed") {
+		assert.Equal(t, "info message", filteredEvents[0].Message)
+		assert.Equal(t, "warning message", filteredEvents[1].Message)
+	}
+}
@@ -8,6 +8,8 @@ import (
 
 // APIEvent represents simplified Event object to be returned from the API
 type APIEvent struct {
+	// Seq is a sequence number of the event in the event log, starting from 1
+	Seq      int `yaml:",omitempty"`
 	Time     time.Time
 	LogLevel string `yaml:"level"`
 	Message  string
@@ -20,6 +22,18 @@ func (eventLog *Log) AsAPIEvents() []*APIEvent {
 	return saver.events
 }
 
+// FilterAPIEvents returns APIEvents of a given level or more severe ones. Events with unknown level are always returned
+func FilterAPIEvents(events []*APIEvent, level logrus.Level) []*APIEvent {
+	result := make([]*APIEvent, 0, len(events))
+	for _, e := range events {
+		eventLevel, err := logrus.ParseLevel(e.LogLevel)
+		if err != nil || eventLevel <= level {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
 // HookAPIEvents saves all events as APIEvents that holds only time, level and message
 type HookAPIEvents struct {
 	events []*APIEvent
@@ -32,7 +46,21 @@ func (hook *HookAPIEvents) Levels() []logrus.Level {
 
 // Fire processes a single log entry
 func (hook *HookAPIEvents) Fire(e *logrus.Entry) error {
-	apiEvent := &APIEvent{Time: e.Time, LogLevel: e.Level.String(), Message: e.Message}
+	apiEvent := &APIEvent{Seq: len(hook.events) + 1, Time: e.Time, LogLevel: e.Level.String(), Message: e.Message}
 	hook.events = append(hook.events, apiEvent)
 	return nil
 }
+
+// GetDefaultColumns returns default set of columns to be displayed
+func (event *APIEvent) GetDefaultColumns() []string {
+	return []string{"Time", "Level", "Message"}
+}
+
+// AsColumns returns APIEvent representation as columns
+func (event *APIEvent) AsColumns() map[string]string {
+	return map[string]string{
+		"Time":    event.Time.Format(time.RFC3339Nano),
+		"Level":   event.LogLevel,
+		"Message": event.Message,
+	}
+}
@@ -1,12 +1,27 @@
 package event
 
 import (
+	"fmt"
+	"sync"
+
 	"github.com/sirupsen/logrus"
 )
 
-// HookMemory implements event log hook, which buffers all event log entries in hookMemory
+// HookMemory implements event log hook, which buffers all event log entries in hookMemory. If buffer is bounded
+// (see BufferConfig), older debug/info entries get spilled to disk or dropped once the limit is exceeded, while
+// warnings and errors are always retained in memory
 type HookMemory struct {
-	entries []*logrus.Entry
+	mutex   sync.Mutex
+	config  BufferConfig
+	seq     uint64
+	entries []*bufferedEntry
+	spill   *spillFile
+	dropped uint64
+}
+
+// NewHookMemory creates a new HookMemory with the given buffer config
+func NewHookMemory(config BufferConfig) *HookMemory {
+	return &HookMemory{config: config}
 }
 
 // Levels defines on which log levels this hook should be fired
@@ -16,6 +31,147 @@ func (buf *HookMemory) Levels() []logrus.Level {
 
 // Fire processes a single log entry
 func (buf *HookMemory) Fire(e *logrus.Entry) error {
-	buf.entries = append(buf.entries, e)
+	buf.mutex.Lock()
+	defer buf.mutex.Unlock()
+
+	buf.seq++
+	buf.entries = append(buf.entries, &bufferedEntry{seq: buf.seq, entry: e})
+
+	if buf.config.MaxEntries > 0 && len(buf.entries) > buf.config.MaxEntries {
+		return buf.evict()
+	}
+
+	return nil
+}
+
+// InMemory returns the number of entries currently kept in memory
+func (buf *HookMemory) InMemory() int {
+	buf.mutex.Lock()
+	defer buf.mutex.Unlock()
+
+	return len(buf.entries)
+}
+
+// Dropped returns the number of entries dropped because of the buffer overflow
+func (buf *HookMemory) Dropped() uint64 {
+	buf.mutex.Lock()
+	defer buf.mutex.Unlock()
+
+	return buf.dropped
+}
+
+// evict removes the oldest half of debug/info entries from memory, spilling them to disk or dropping them depending
+// on the buffer config. Removing entries in batches keeps the cost of eviction amortized
+func (buf *HookMemory) evict() error {
+	toEvict := len(buf.entries) - buf.config.MaxEntries/2
+	retained := buf.entries[:0]
+	for idx, e := range buf.entries {
+		if toEvict <= 0 || e.entry.Level <= logrus.WarnLevel {
+			retained = append(retained, e)
+			continue
+		}
+
+		if buf.config.Overflow == OverflowDrop {
+			buf.dropped++
+		} else {
+			err := buf.spillEntry(e)
+			if err != nil {
+				// keep the rest of the entries in memory, we'll try again on the next overflow
+				retained = append(retained, buf.entries[idx:]...)
+				buf.entries = retained
+				return err
+			}
+		}
+		toEvict--
+	}
+
+	// clear the tail, so that evicted entries could be garbage collected
+	for idx := len(retained); idx < len(buf.entries); idx++ {
+		buf.entries[idx] = nil
+	}
+	buf.entries = retained
+
 	return nil
 }
+
+func (buf *HookMemory) spillEntry(e *bufferedEntry) error {
+	if buf.spill == nil {
+		spill, err := newSpillFile(buf.config.SpillDir)
+		if err != nil {
+			return err
+		}
+		buf.spill = spill
+	}
+
+	return buf.spill.write(e)
+}
+
+// forEach calls provided function for every entry (including spilled ones) in the order they were added
+func (buf *HookMemory) forEach(logger *logrus.Logger, fn func(e *logrus.Entry) error) error {
+	buf.mutex.Lock()
+	defer buf.mutex.Unlock()
+
+	if buf.spill == nil || buf.spill.count == 0 {
+		for _, e := range buf.entries {
+			if err := fn(e.entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	decoder, err := buf.spill.reader()
+	if err != nil {
+		return err
+	}
+
+	// merge spilled entries with the ones retained in memory by sequence number
+	memIdx := 0
+	for spilledIdx := 0; spilledIdx < buf.spill.count; spilledIdx++ {
+		spilled := &spilledEntry{}
+		if err = decoder.Decode(spilled); err != nil {
+			return fmt.Errorf("error while reading event log entry from spill file: %s", err)
+		}
+
+		for ; memIdx < len(buf.entries) && buf.entries[memIdx].seq < spilled.Seq; memIdx++ {
+			if err = fn(buf.entries[memIdx].entry); err != nil {
+				return err
+			}
+		}
+
+		err = fn(&logrus.Entry{
+			Logger:  logger,
+			Data:    spilled.Data,
+			Time:    spilled.Time,
+			Level:   spilled.Level,
+			Message: spilled.Message,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for ; memIdx < len(buf.entries); memIdx++ {
+		if err = fn(buf.entries[memIdx].entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// close releases spill file, if it was created
+func (buf *HookMemory) close() error {
+	buf.mutex.Lock()
+	defer buf.mutex.Unlock()
+
+	if buf.spill == nil {
+		return nil
+	}
+
+	err := buf.spill.close()
+	buf.spill = nil
+	buf.entries = nil
+
+	return err
+}
@@ -0,0 +1,51 @@
+package event
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// HookStream implements event log hook, which passes every event log entry as APIEvent to a given function as soon as
+// it's logged (e.g. to stream events to the client in real time instead of returning the whole log in the end)
+type HookStream struct {
+	mutex sync.Mutex
+	level logrus.Level
+	seq   int
+	fn    func(*APIEvent)
+}
+
+// NewHookStream creates a new HookStream, which passes entries of a given level or more severe ones to a given function
+func NewHookStream(level logrus.Level, fn func(*APIEvent)) *HookStream {
+	return &HookStream{level: level, fn: fn}
+}
+
+// Levels defines on which log levels this hook should be fired
+func (hook *HookStream) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire processes a single log entry. Entries of all levels are counted, so events get the same sequence numbers as
+// the ones returned by AsAPIEvents
+func (hook *HookStream) Fire(e *logrus.Entry) error {
+	hook.mutex.Lock()
+	defer hook.mutex.Unlock()
+
+	hook.seq++
+	if e.Level <= hook.level {
+		hook.fn(&APIEvent{Seq: hook.seq, Time: e.Time, LogLevel: e.Level.String(), Message: e.Message})
+	}
+	return nil
+}
+
+// AddStreamHook puts an additional hook to an existing event log, to pass its entries of a given level or more severe
+// ones to a given function as APIEvents. Entries logged before the hook was added are passed first, so the function
+// gets all events of the log in order
+func (eventLog *Log) AddStreamHook(level logrus.Level, fn func(*APIEvent)) *Log {
+	hook := NewHookStream(level, fn)
+	err := eventLog.hookMemory.forEach(eventLog.logger, hook.Fire)
+	if err != nil {
+		panic(err)
+	}
+	return eventLog.AddHook(hook)
+}
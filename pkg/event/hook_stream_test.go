@@ -0,0 +1,38 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventLogStreamHook(t *testing.T) {
+	eventLog := NewLog(logrus.DebugLevel, "test")
+	eventLog.NewEntry().Debug("before debug")
+	eventLog.NewEntry().Warning("before warning")
+
+	streamed := make([]*APIEvent, 0)
+	eventLog.AddStreamHook(logrus.InfoLevel, func(e *APIEvent) {
+		streamed = append(streamed, e)
+	})
+	assert.Len(t, streamed, 1, "Matching entries logged before the hook was added should be streamed right away")
+
+	eventLog.NewEntry().Info("after info")
+	eventLog.NewEntry().Debug("after debug")
+	child := NewLog(logrus.DebugLevel, "child")
+	child.NewEntry().Error("child error")
+	eventLog.Append(child)
+
+	if assert.Len(t, streamed, 3, "Entries of the requested level or more severe ones should be streamed") {
+		assert.Equal(t, "before warning", streamed[0].Message)
+		assert.Equal(t, "after info", streamed[1].Message)
+		assert.Equal(t, "child error", streamed[2].Message)
+	}
+
+	// streamed events should be numbered the same way as the whole log
+	all := eventLog.AsAPIEvents()
+	for _, e := range streamed {
+		assert.Equal(t, all[e.Seq-1].Message, e.Message, "Streamed event should have the same sequence number")
+	}
+}
@@ -1,6 +1,8 @@
 package external
 
 import (
+	"sync/atomic"
+
 	"github.com/Aptomi/aptomi/pkg/external/secrets"
 	"github.com/Aptomi/aptomi/pkg/external/users"
 )
@@ -9,12 +11,40 @@ import (
 type Data struct {
 	UserLoader   users.UserLoader
 	SecretLoader secrets.SecretLoader
+
+	// version gets incremented every time external data changes
+	version uint64
 }
 
-// NewData creates a new instance of external Data
+// changeNotifier is implemented by user and secret loaders, which reload data periodically and notify when it changes
+type changeNotifier interface {
+	OnChange(handler func())
+}
+
+// NewData creates a new instance of external Data. If user or secret loader reloads data periodically, version of
+// external data gets incremented every time it changes
 func NewData(userLoader users.UserLoader, secretLoader secrets.SecretLoader) *Data {
-	return &Data{
+	data := &Data{
 		UserLoader:   userLoader,
 		SecretLoader: secretLoader,
 	}
+	for _, loader := range []interface{}{userLoader, secretLoader} {
+		if notifier, ok := loader.(changeNotifier); ok {
+			notifier.OnChange(data.Changed)
+		}
+	}
+
+	return data
+}
+
+// Version returns version of external data, so results calculated from it (e.g. cached policy resolutions) could be
+// told apart once it changes
+func (data *Data) Version() uint64 {
+	return atomic.LoadUint64(&data.version)
+}
+
+// Changed gets called every time users or secrets change (e.g. get reloaded with different content), so the version of
+// external data gets incremented
+func (data *Data) Changed() {
+	atomic.AddUint64(&data.version, 1)
 }
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/Aptomi/aptomi/pkg/lang/yaml"
+	utilsync "github.com/Aptomi/aptomi/pkg/util/sync"
 	"github.com/mattn/go-zglob"
 	"github.com/patrickmn/go-cache"
 	log "github.com/sirupsen/logrus"
@@ -18,6 +19,7 @@ import (
 type SecretLoaderFromDir struct {
 	baseDir string
 	cache   *cache.Cache
+	changes utilsync.ChangeNotifier
 }
 
 // UserSecrets represents a single user secret (user name and a map of secrets)
@@ -70,9 +72,16 @@ func (loader *SecretLoaderFromDir) LoadSecretsAll() map[string]map[string]string
 	}
 
 	loader.cache.Set("secrets", result, cache.DefaultExpiration)
+	loader.changes.Loaded(result)
 	return result
 }
 
+// OnChange registers a handler, which gets called every time secrets reloaded from the directory differ from the
+// previously loaded ones
+func (loader *SecretLoaderFromDir) OnChange(handler func()) {
+	loader.changes.OnChange(handler)
+}
+
 // LoadSecretsByUserName loads secrets for a single user
 func (loader *SecretLoaderFromDir) LoadSecretsByUserName(user string) map[string]string {
 	return loader.LoadSecretsAll()[strings.ToLower(user)]
@@ -10,6 +10,7 @@ import (
 	"github.com/Aptomi/aptomi/pkg/lang"
 	"github.com/Aptomi/aptomi/pkg/lang/yaml"
 	"github.com/Aptomi/aptomi/pkg/util"
+	utilsync "github.com/Aptomi/aptomi/pkg/util/sync"
 	"github.com/patrickmn/go-cache"
 )
 
@@ -18,6 +19,7 @@ type UserLoaderFromFile struct {
 	fileName             string
 	cache                *cache.Cache
 	domainAdminOverrides map[string]bool
+	changes              utilsync.ChangeNotifier
 }
 
 // NewUserLoaderFromFile returns new UserLoaderFromFile
@@ -51,9 +53,16 @@ func (loader *UserLoaderFromFile) LoadUsersAll() *lang.GlobalUsers {
 		}
 	}
 	loader.cache.Set("users", result, cache.DefaultExpiration)
+	loader.changes.Loaded(result)
 	return result
 }
 
+// OnChange registers a handler, which gets called every time users reloaded from the file differ from the previously
+// loaded ones
+func (loader *UserLoaderFromFile) OnChange(handler func()) {
+	loader.changes.OnChange(handler)
+}
+
 // LoadUserByName loads a single user by name
 func (loader *UserLoaderFromFile) LoadUserByName(name string) *lang.User {
 	return loader.LoadUsersAll().Users[strings.ToLower(name)]
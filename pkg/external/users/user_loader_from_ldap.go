@@ -10,6 +10,7 @@ import (
 
 	"github.com/Aptomi/aptomi/pkg/config"
 	"github.com/Aptomi/aptomi/pkg/lang"
+	utilsync "github.com/Aptomi/aptomi/pkg/util/sync"
 	"github.com/patrickmn/go-cache"
 	"gopkg.in/ldap.v2"
 )
@@ -19,6 +20,7 @@ type UserLoaderFromLDAP struct {
 	cfg                  config.LDAP
 	cache                *cache.Cache
 	domainAdminOverrides map[string]bool
+	changes              utilsync.ChangeNotifier
 }
 
 // NewUserLoaderFromLDAP returns new UserLoaderFromLDAP, given location with LDAP configuration file (with host/port and mapping)
@@ -56,9 +58,16 @@ func (loader *UserLoaderFromLDAP) LoadUsersAll() *lang.GlobalUsers {
 		}
 	}
 	loader.cache.Set("ldapUsers", result, cache.DefaultExpiration)
+	loader.changes.Loaded(result)
 	return result
 }
 
+// OnChange registers a handler, which gets called every time users reloaded from LDAP differ from the previously
+// loaded ones
+func (loader *UserLoaderFromLDAP) OnChange(handler func()) {
+	loader.changes.OnChange(handler)
+}
+
 // LoadUserByName loads a single user by name
 func (loader *UserLoaderFromLDAP) LoadUserByName(name string) *lang.User {
 	return loader.LoadUsersAll().Users[strings.ToLower(name)]
@@ -29,6 +29,15 @@ func (loader *UserLoaderMultipleSources) LoadUsersAll() *lang.GlobalUsers {
 	return result
 }
 
+// OnChange registers a handler, which gets called every time users reloaded by any of the combined loaders change
+func (loader *UserLoaderMultipleSources) OnChange(handler func()) {
+	for _, l := range loader.loaders {
+		if notifier, ok := l.(interface{ OnChange(func()) }); ok {
+			notifier.OnChange(handler)
+		}
+	}
+}
+
 // LoadUserByName loads a single user by name
 func (loader *UserLoaderMultipleSources) LoadUserByName(name string) *lang.User {
 	for _, l := range loader.loaders {
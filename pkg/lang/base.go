@@ -6,3 +6,8 @@ import "github.com/Aptomi/aptomi/pkg/runtime"
 type Base interface {
 	runtime.Deletable
 }
+
+// Labeled interface represents policy object with a set of labels attached to it
+type Labeled interface {
+	GetLabels() map[string]string
+}
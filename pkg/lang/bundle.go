@@ -88,6 +88,11 @@ func (component *BundleComponent) Matches(params *expression.Parameters, cache *
 	return component.Criteria.allows(params, cache)
 }
 
+// GetLabels returns labels attached to the bundle
+func (bundle *Bundle) GetLabels() map[string]string {
+	return bundle.Labels
+}
+
 // GetComponentsMap lazily initializes and returns a map of name -> component, while being thread-safe
 func (bundle *Bundle) GetComponentsMap() map[string]*BundleComponent {
 	bundle.componentsMapOnce.Do(func() {
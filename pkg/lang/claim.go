@@ -1,6 +1,7 @@
 package lang
 
 import (
+	"github.com/Aptomi/aptomi/pkg/lang/expression"
 	"github.com/Aptomi/aptomi/pkg/runtime"
 )
 
@@ -27,6 +28,46 @@ type Claim struct {
 	// namespace.
 	Service string `validate:"required"`
 
+	// Fallback is an ordered list of alternate services, which will be tried one by one if claim can't be resolved
+	// via the primary service (e.g. there is no eligible cluster for it). Services are referred to in the same
+	// form as the primary one.
+	Fallback []string `yaml:"fallback,omitempty" validate:"dive,required"`
+
+	// ExclusionGroup, if set, guarantees that claims in the same exclusion group never share a cluster (e.g.
+	// competing tenants). If a claim would end up in a cluster which is already used by another claim from the
+	// group, its fallback services will be tried. Claim can't be resolved if none of them leads to a distinct cluster
+	ExclusionGroup string `yaml:"exclusion-group,omitempty" validate:"omitempty,identifier"`
+
 	// Labels which are provided by the user.
 	Labels map[string]string `yaml:"labels,omitempty" validate:"omitempty,labels"`
+
+	// Preferred is a list of soft placement hints. If target cluster hasn't been set explicitly via 'target' label,
+	// candidate clusters get scored by preferences they match and the one with the highest score gets picked. Unlike
+	// 'target' label, preferences never cause claim resolution to fail when none of them can be satisfied
+	Preferred []*PlacementPreference `yaml:"preferred,omitempty" validate:"dive"`
+}
+
+// PlacementPreference is a soft placement hint, which biases selection of the target cluster for a claim
+type PlacementPreference struct {
+	// Criteria gets evaluated against labels of the candidate cluster
+	Criteria *Criteria `validate:"required"`
+
+	// Weight gets added to the score of every candidate cluster, which matches the criteria
+	Weight int `validate:"min=1"`
+}
+
+// Matches returns true if cluster with a given set of parameters satisfies the preference
+func (preference *PlacementPreference) Matches(params *expression.Parameters, cache *expression.Cache) (bool, error) {
+	return preference.Criteria.allows(params, cache)
+}
+
+// GetLabels returns labels provided by the user
+func (claim *Claim) GetLabels() map[string]string {
+	return claim.Labels
+}
+
+// GetServices returns an ordered list of services, which claim can be resolved with. The primary service always
+// goes first, followed by fallback services
+func (claim *Claim) GetServices() []string {
+	return append([]string{claim.Service}, claim.Fallback...)
 }
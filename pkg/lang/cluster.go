@@ -29,6 +29,14 @@ type Cluster struct {
 
 	// Config for a given cluster type
 	Config interface{} `validate:"required"`
+
+	// Capacity is the max number of code component instances which can run in the cluster. Zero means unlimited
+	Capacity int `yaml:"capacity,omitempty" validate:"min=0"`
+}
+
+// GetLabels returns labels attached to the cluster
+func (cluster *Cluster) GetLabels() map[string]string {
+	return cluster.Labels
 }
 
 // ParseConfigInto parses cluster config into provided object
@@ -54,5 +62,6 @@ func (cluster *Cluster) MakeCopy() *Cluster {
 		Type:     cluster.Type,
 		Labels:   cluster.Labels,
 		Config:   cluster.Config,
+		Capacity: cluster.Capacity,
 	}
 }
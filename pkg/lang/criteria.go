@@ -74,6 +74,12 @@ func (criteria *Criteria) allows(params *expression.Parameters, cache *expressio
 	return true, nil
 }
 
+// MatchesLabels returns whether criteria evaluates to "true" for a given set of labels, which expressions refer to
+// through variables the same way as in rule criteria
+func (criteria *Criteria) MatchesLabels(labels map[string]string, cache *expression.Cache) (bool, error) {
+	return criteria.allows(expression.NewParams(labels, nil), cache)
+}
+
 // Evaluates bool expression, given a set of parameters and a cache. If cache is nil, it will still be evaluated
 // successfully, but without a cache
 func (criteria *Criteria) evaluateBool(expressionStr string, params *expression.Parameters, cache *expression.Cache) (bool, error) {
@@ -0,0 +1,282 @@
+package lang
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"gopkg.in/yaml.v2"
+)
+
+// TypeDefaults is an informational data structure with Kind and Constructor for Defaults
+var TypeDefaults = &runtime.TypeInfo{
+	Kind:        "defaults",
+	Storable:    true,
+	Versioned:   true,
+	Constructor: func() runtime.Object { return &Defaults{} },
+}
+
+// Defaults defines default values for fields of policy objects within a namespace, so namespace admins don't have
+// to repeat the same cluster references, labels and parameters in every object. There can be only one Defaults
+// object per namespace.
+//
+// Defaults get applied when objects are added or updated, only to the fields which are not set (missing or having
+// zero value). Explicitly set values always win. Effective values get recorded in the object itself, along with the
+// list of defaulted fields in its metadata, so exported objects show them. When Defaults object gets changed, all
+// defaulted fields of objects in its namespace get re-evaluated. Deleting Defaults object keeps effective values
+// already recorded in objects
+type Defaults struct {
+	runtime.TypeKind `yaml:",inline"`
+	Metadata         `validate:"required"`
+
+	// Values maps object kind into default values of its fields. Fields are addressed by dot-separated paths of their
+	// names as they appear in yaml (e.g. 'labels.team' for claim or 'capacity' for cluster)
+	Values map[string]map[string]interface{} `validate:"required"`
+}
+
+// GetPaths returns sorted list of field paths with default values for a given object kind
+func (defaults *Defaults) GetPaths(kind string) []string {
+	if defaults == nil {
+		return nil
+	}
+	result := make([]string, 0, len(defaults.Values[kind]))
+	for path := range defaults.Values[kind] {
+		result = append(result, path)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// getValue returns default value of a field for a given object kind
+func (defaults *Defaults) getValue(kind string, path string) (interface{}, bool) {
+	if defaults == nil {
+		return nil, false
+	}
+	value, ok := defaults.Values[kind][path]
+	return value, ok
+}
+
+// GetDefaults returns Defaults object of a given namespace or nil if there is none
+func (policy *Policy) GetDefaults(ns string) *Defaults {
+	policyNamespace, ok := policy.Namespace[ns]
+	if !ok {
+		return nil
+	}
+
+	// only one Defaults object is allowed per namespace, but let's stay deterministic until policy gets validated
+	var result *Defaults
+	for _, defaults := range policyNamespace.Defaults {
+		if result == nil || defaults.Name < result.Name {
+			result = defaults
+		}
+	}
+	return result
+}
+
+// ApplyDefaults returns a copy of a given object with unset fields filled from Defaults, along with the list of filled
+// field paths. Fields which have been filled from previous Defaults get re-evaluated: their values get replaced with
+// the new defaults, unless they have been changed explicitly since then. Both previous and new Defaults could be nil
+func ApplyDefaults(obj Base, prev *Defaults, next *Defaults) (Base, []string, error) {
+	kind := obj.GetKind()
+	typeInfo := policyObjectsMap[kind]
+	if typeInfo == nil || kind == TypeDefaults.Kind {
+		return obj, nil, nil
+	}
+
+	fields, err := toFieldMap(obj)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error while applying defaults to %s: %s", runtime.KeyForStorable(obj), err)
+	}
+	meta, _ := fields["metadata"].(map[interface{}]interface{}) // nolint: errcheck
+
+	// forget values filled from previous defaults, if they weren't changed explicitly
+	if defaulted, ok := meta["defaulted"].([]interface{}); ok {
+		for _, pathObj := range defaulted {
+			path := fmt.Sprintf("%v", pathObj)
+			prevValue, found := prev.getValue(kind, path)
+			if found && fieldValueEquals(getField(fields, path), prevValue) {
+				unsetField(fields, path)
+			}
+		}
+	}
+	delete(meta, "defaulted")
+
+	// fill unset fields from new defaults
+	filled := []string{}
+	for _, path := range next.GetPaths(kind) {
+		if isUnsetValue(getField(fields, path)) {
+			value, _ := next.getValue(kind, path)
+			setField(fields, path, value)
+			filled = append(filled, path)
+		}
+	}
+	if len(filled) > 0 {
+		if meta == nil {
+			meta = make(map[interface{}]interface{})
+			fields["metadata"] = meta
+		}
+		meta["defaulted"] = filled
+	}
+
+	data, err := yaml.Marshal(fields)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error while applying defaults to %s: %s", runtime.KeyForStorable(obj), err)
+	}
+	result := typeInfo.New().(Base) // nolint: errcheck
+	err = yaml.Unmarshal(data, result)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error while applying defaults to %s (default value doesn't fit the field): %s", runtime.KeyForStorable(obj), err)
+	}
+
+	return result, filled, nil
+}
+
+// validateDefaultsPath checks that a field with a given path exists on objects of a given kind and can be defaulted
+func validateDefaultsPath(kind string, path string) error {
+	typeInfo := policyObjectsMap[kind]
+	if typeInfo == nil || kind == TypeDefaults.Kind {
+		return fmt.Errorf("kind '%s' can't have defaults", kind)
+	}
+
+	parts := strings.Split(path, ".")
+	if parts[0] == "kind" || (parts[0] == "metadata" && (len(parts) < 3 || parts[1] != "annotations")) {
+		return fmt.Errorf("field '%s' can't have defaults", path)
+	}
+
+	t := reflect.TypeOf(typeInfo.New())
+	for idx, part := range parts {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			field, ok := findYAMLField(t, part)
+			if !ok {
+				return fmt.Errorf("field '%s' doesn't exist", strings.Join(parts[:idx+1], "."))
+			}
+			t = field.Type
+		case reflect.Map:
+			if t.Key().Kind() != reflect.String {
+				return fmt.Errorf("field '%s' can't have nested fields", strings.Join(parts[:idx], "."))
+			}
+			t = t.Elem()
+		case reflect.Interface:
+			// free-form field, anything could be nested
+			return nil
+		default:
+			return fmt.Errorf("field '%s' can't have nested fields", strings.Join(parts[:idx], "."))
+		}
+	}
+	return nil
+}
+
+// validateDefaultsValue checks that default value fits the field with a given path on objects of a given kind
+func validateDefaultsValue(kind string, path string, value interface{}) error {
+	fields := map[interface{}]interface{}{"kind": kind}
+	setField(fields, path, value)
+	data, err := yaml.Marshal(fields)
+	if err == nil {
+		err = yaml.Unmarshal(data, policyObjectsMap[kind].New())
+	}
+	if err != nil {
+		return fmt.Errorf("value doesn't fit the field: %s", err)
+	}
+	return nil
+}
+
+// findYAMLField looks up struct field by its name in yaml, including fields of inlined structs
+func findYAMLField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if len(field.PkgPath) > 0 {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("yaml"), ",")
+		if len(tag) > 1 && tag[1] == "inline" {
+			if result, ok := findYAMLField(field.Type, name); ok {
+				return result, true
+			}
+			continue
+		}
+		fieldName := tag[0]
+		if len(fieldName) == 0 {
+			fieldName = strings.ToLower(field.Name)
+		}
+		if fieldName == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// toFieldMap converts object into a generic map of its fields, as they appear in yaml
+func toFieldMap(obj interface{}) (map[interface{}]interface{}, error) {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[interface{}]interface{})
+	err = yaml.Unmarshal(data, &result)
+	return result, err
+}
+
+func getField(fields map[interface{}]interface{}, path string) interface{} {
+	var current interface{} = fields
+	for _, part := range strings.Split(path, ".") {
+		currentMap, ok := current.(map[interface{}]interface{})
+		if !ok {
+			return nil
+		}
+		current = currentMap[part]
+	}
+	return current
+}
+
+func setField(fields map[interface{}]interface{}, path string, value interface{}) {
+	parts := strings.Split(path, ".")
+	current := fields
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[interface{}]interface{})
+		if !ok {
+			next = make(map[interface{}]interface{})
+			current[part] = next
+		}
+		current = next
+	}
+	current[parts[len(parts)-1]] = value
+}
+
+func unsetField(fields map[interface{}]interface{}, path string) {
+	parts := strings.Split(path, ".")
+	current := fields
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[interface{}]interface{})
+		if !ok {
+			return
+		}
+		current = next
+	}
+	delete(current, parts[len(parts)-1])
+}
+
+// isUnsetValue returns true if field is missing or has zero value
+func isUnsetValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	}
+	return reflect.DeepEqual(value, reflect.Zero(v.Type()).Interface())
+}
+
+// fieldValueEquals compares values after passing both of them through yaml, so they have the same representation
+func fieldValueEquals(a interface{}, b interface{}) bool {
+	dataA, errA := yaml.Marshal(a)
+	dataB, errB := yaml.Marshal(b)
+	return errA == nil && errB == nil && string(dataA) == string(dataB)
+}
@@ -0,0 +1,111 @@
+package lang
+
+import (
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeDefaults(name string, values map[string]map[string]interface{}) *Defaults {
+	return &Defaults{
+		TypeKind: TypeDefaults.GetTypeKind(),
+		Metadata: Metadata{
+			Namespace: "main",
+			Name:      name,
+		},
+		Values: values,
+	}
+}
+
+func makeDefaultsClaim(labels map[string]string) *Claim {
+	return &Claim{
+		TypeKind: TypeClaim.GetTypeKind(),
+		Metadata: Metadata{
+			Namespace: "main",
+			Name:      "claim",
+		},
+		User:    "alice",
+		Service: "service",
+		Labels:  labels,
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	defaults := makeDefaults("defaults", map[string]map[string]interface{}{
+		TypeClaim.Kind: {
+			"labels.team":     "platform",
+			"labels.env":      "dev",
+			"exclusion-group": "tenants",
+		},
+	})
+
+	// unset fields get filled, explicit values win
+	obj, filled, err := ApplyDefaults(makeDefaultsClaim(map[string]string{"env": "prod"}), nil, defaults)
+	assert.NoError(t, err, "Defaults should be applied")
+	claim := obj.(*Claim)
+	assert.Equal(t, []string{"exclusion-group", "labels.team"}, filled, "Only unset fields should be filled")
+	assert.Equal(t, []string{"exclusion-group", "labels.team"}, claim.Defaulted, "Filled fields should be recorded")
+	assert.Equal(t, map[string]string{"env": "prod", "team": "platform"}, claim.Labels, "Explicit label should win")
+	assert.Equal(t, "tenants", claim.ExclusionGroup, "Exclusion group should be filled")
+
+	// changed defaults replace previously defaulted values
+	changed := makeDefaults("defaults", map[string]map[string]interface{}{
+		TypeClaim.Kind: {
+			"labels.team": "core",
+		},
+	})
+	obj, filled, err = ApplyDefaults(claim, defaults, changed)
+	assert.NoError(t, err, "Defaults should be re-applied")
+	claim = obj.(*Claim)
+	assert.Equal(t, []string{"labels.team"}, filled, "Only fields from new defaults should be filled")
+	assert.Equal(t, map[string]string{"env": "prod", "team": "core"}, claim.Labels, "Defaulted label should be replaced")
+	assert.Empty(t, claim.ExclusionGroup, "Field without default should be unset")
+
+	// explicitly changed defaulted value is kept
+	claim.Labels["team"] = "custom"
+	obj, filled, err = ApplyDefaults(claim, changed, defaults)
+	assert.NoError(t, err, "Defaults should be re-applied")
+	claim = obj.(*Claim)
+	assert.Equal(t, []string{"exclusion-group"}, filled, "Explicitly changed field should not be filled")
+	assert.Equal(t, "custom", claim.Labels["team"], "Explicitly changed label should be kept")
+
+	// defaults don't apply to other kinds
+	obj, filled, err = ApplyDefaults(defaults, nil, defaults)
+	assert.NoError(t, err, "Defaults should not be applied to defaults")
+	assert.Equal(t, defaults, obj, "Defaults object should stay the same")
+	assert.Empty(t, filled, "No fields should be filled")
+}
+
+func TestPolicyValidationDefaults(t *testing.T) {
+	runValidationTests(t, ResSuccess, true, []Base{
+		makeDefaults("defaults", map[string]map[string]interface{}{
+			TypeClaim.Kind:   {"labels.team": "platform", "metadata.annotations.owner": "alice"},
+			TypeCluster.Kind: {"capacity": 10, "config.namespace": "apps"},
+		}),
+	})
+	runValidationTests(t, ResFailure, true, []Base{
+		makeDefaults("unknown-field", map[string]map[string]interface{}{TypeClaim.Kind: {"unknown": "value"}}),
+		makeDefaults("unknown-kind", map[string]map[string]interface{}{"unknown": {"labels.team": "platform"}}),
+		makeDefaults("defaults-kind", map[string]map[string]interface{}{TypeDefaults.Kind: {"values": "value"}}),
+		makeDefaults("metadata", map[string]map[string]interface{}{TypeClaim.Kind: {"metadata.name": "value"}}),
+		makeDefaults("scalar-nested", map[string]map[string]interface{}{TypeClaim.Kind: {"user.name": "value"}}),
+		makeDefaults("wrong-type", map[string]map[string]interface{}{TypeCluster.Kind: {"capacity": "lots"}}),
+	})
+
+	// only one defaults object per namespace is allowed
+	runValidationTests(t, ResFailure, false, []Base{
+		makeDefaults("first", map[string]map[string]interface{}{TypeClaim.Kind: {"labels.team": "platform"}}),
+		makeDefaults("second", map[string]map[string]interface{}{TypeClaim.Kind: {"labels.env": "dev"}}),
+	})
+}
+
+func TestPolicyGetDefaults(t *testing.T) {
+	policy := NewPolicy()
+	assert.Nil(t, policy.GetDefaults("main"), "There should be no defaults in empty policy")
+
+	defaults := makeDefaults("defaults", map[string]map[string]interface{}{TypeClaim.Kind: {"labels.team": "platform"}})
+	assert.NoError(t, policy.AddObject(defaults), "Defaults should be added to policy")
+	assert.Equal(t, defaults, policy.GetDefaults("main"), "Defaults should be found")
+	assert.Nil(t, policy.GetDefaults(runtime.SystemNS), "There should be no defaults in other namespace")
+}
@@ -12,25 +12,34 @@ func NewCache() *Cache {
 	return &Cache{eCache: sync.Map{}}
 }
 
+// Compile returns compiled expression. If an compiled expression already exists in cache, it will be returned.
+// Otherwise it will get compiled and added to the cache.
+// This method is thread-safe and can be called concurrently from multiple goroutines.
+func (cache *Cache) Compile(expressionStr string) (*Expression, error) {
+	// Look up expression from the cache
+	expressionCached, ok := cache.eCache.Load(expressionStr)
+	if ok {
+		return expressionCached.(*Expression), nil // nolint: errcheck
+	}
+
+	// Compile expression, if not found
+	// This might happen a several times in parallel, that's okay
+	expression, err := NewExpression(expressionStr)
+	if err != nil {
+		return nil, err
+	}
+	cache.eCache.Store(expressionStr, expression)
+	return expression, nil
+}
+
 // EvaluateAsBool evaluates boolean expression given a set of parameters.
 // If an compiled expression already exists in cache, it will be used.
 // Otherwise it will get compiled and added to the cache before evaluating the expression.
 // This method is thread-safe and can be called concurrently from multiple goroutines.
 func (cache *Cache) EvaluateAsBool(expressionStr string, params *Parameters) (bool, error) {
-	// Look up expression from the cache
-	var expression *Expression
-	expressionCached, ok := cache.eCache.Load(expressionStr)
-	if ok {
-		expression = expressionCached.(*Expression) // nolint: errcheck
-	} else {
-		// Compile expression, if not found
-		// This might happen a several times in parallel, that's okay
-		var err error
-		expression, err = NewExpression(expressionStr)
-		if err != nil {
-			return false, err
-		}
-		cache.eCache.Store(expressionStr, expression)
+	expression, err := cache.Compile(expressionStr)
+	if err != nil {
+		return false, err
 	}
 
 	// Evaluate expression
@@ -2,6 +2,7 @@ package expression
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/Aptomi/aptomi/pkg/errors"
 	"github.com/ralekseenkov/govaluate"
@@ -41,6 +42,27 @@ func NewExpression(expressionStr string) (*Expression, error) {
 	}, nil
 }
 
+// ReferencedVariables returns a sorted list of plain variables referenced from the expression (e.g. 'team' in
+// 'team == "dev"'). Accessors to struct parameters (e.g. 'Claim.Name') are not included
+func (expression *Expression) ReferencedVariables() []string {
+	referenced := make(map[string]bool)
+	for _, token := range expression.expressionCompiled.Tokens() {
+		if token.Kind != govaluate.VARIABLE {
+			continue
+		}
+		if name, ok := token.Value.(string); ok {
+			referenced[name] = true
+		}
+	}
+
+	result := make([]string, 0, len(referenced))
+	for name := range referenced {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
 // EvaluateAsBool evaluates a compiled boolean expression given a set of named parameters
 func (expression *Expression) EvaluateAsBool(params *Parameters) (bool, error) {
 	// Evaluate
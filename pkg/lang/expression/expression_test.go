@@ -127,3 +127,21 @@ func TestExpressions(t *testing.T) {
 		evaluateWithCache(t, test.expression, params, test.result, cache)
 	}
 }
+
+func TestExpressionReferencedVariables(t *testing.T) {
+	tests := []struct {
+		expression string
+		referenced []string
+	}{
+		{"team == 'dev' && (deptCode > 10 || team == 'ops')", []string{"deptCode", "team"}},
+		{"in(org, 'a', 'b') && Claim.Name == 'x'", []string{"org"}},
+		{"true", []string{}},
+	}
+
+	for _, test := range tests {
+		expr, err := NewExpression(test.expression)
+		if assert.NoError(t, err, "Expression should be compiled: %s", test.expression) {
+			assert.Equal(t, test.referenced, expr.ReferencedVariables(), "Referenced variables: %s", test.expression)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package lang
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Aptomi/aptomi/pkg/util"
+)
+
+// Prefixes of template variables, which refer to labels
+const (
+	labelsTemplatePrefix     = ".Labels."
+	userLabelsTemplatePrefix = ".User.Labels."
+)
+
+// Names of struct parameters exposed to rule expressions, which are not labels
+var expressionStructParams = map[string]bool{
+	"Bundle": true,
+	"Claim":  true,
+}
+
+// LabelUsage is a report of which labels are referenced from criteria expressions and text templates in the policy,
+// cross-referenced against labels users currently have in external data. Label referenced from the policy, which
+// nobody has, makes criteria silently never match. User label, which nobody references, is loaded for nothing
+type LabelUsage struct {
+	// Labels is a map from label name to a sorted list of policy objects referencing it
+	Labels map[string][]string
+
+	// Undefined is a sorted list of labels, which are referenced from the policy, but none of the users has them
+	// and they don't get set by any of policy objects either
+	Undefined []string
+
+	// Unused is a sorted list of user labels, which are present in external data, but not referenced from the policy
+	Unused []string
+
+	// userOnly is a set of labels, which can only come from users (e.g. .User.Labels.team or ACL rule criteria)
+	userOnly map[string]bool
+}
+
+// NewLabelUsage analyzes all criteria expressions and text templates in the policy, extracts referenced labels and
+// cross-references them against labels of a given set of users
+func NewLabelUsage(policy *Policy, users *GlobalUsers) *LabelUsage {
+	usage := &LabelUsage{
+		Labels:   make(map[string][]string),
+		userOnly: make(map[string]bool),
+	}
+
+	// labels which are set by the policy itself, so they don't have to come from users
+	defined := make(map[string]bool)
+
+	for _, obj := range policy.GetObjectsByKind(TypeRule.Kind) {
+		rule := obj.(*Rule) // nolint: errcheck
+		object := fmt.Sprintf("rule %s/%s", rule.Namespace, rule.Name)
+		usage.addCriteria(policy, object, rule.Criteria, false)
+		if rule.Actions != nil {
+			addLabelsSet(defined, rule.Actions.ChangeLabels)
+		}
+	}
+
+	// ACL rules are evaluated against user labels only
+	for _, obj := range policy.GetObjectsByKind(TypeACLRule.Kind) {
+		rule := obj.(*ACLRule) // nolint: errcheck
+		usage.addCriteria(policy, fmt.Sprintf("aclrule %s/%s", rule.Namespace, rule.Name), rule.Criteria, true)
+	}
+
+	for _, obj := range policy.GetObjectsByKind(TypeService.Kind) {
+		service := obj.(*Service) // nolint: errcheck
+		object := fmt.Sprintf("service %s/%s", service.Namespace, service.Name)
+		addLabelsSet(defined, service.ChangeLabels)
+		for _, serviceCtx := range service.Contexts {
+			usage.addCriteria(policy, object, serviceCtx.Criteria, false)
+			addLabelsSet(defined, serviceCtx.ChangeLabels)
+			if serviceCtx.Allocation != nil {
+				for _, key := range serviceCtx.Allocation.Keys {
+					usage.addTemplate(policy, object, key)
+				}
+			}
+		}
+	}
+
+	for _, obj := range policy.GetObjectsByKind(TypeBundle.Kind) {
+		bundle := obj.(*Bundle) // nolint: errcheck
+		object := fmt.Sprintf("bundle %s/%s", bundle.Namespace, bundle.Name)
+		for _, component := range bundle.Components {
+			usage.addCriteria(policy, object, component.Criteria, false)
+			usage.addTemplateTree(policy, object, component.Discovery)
+			if component.Code != nil {
+				usage.addTemplateTree(policy, object, component.Code.Params)
+			}
+		}
+	}
+
+	for _, obj := range policy.GetObjectsByKind(TypeClaim.Kind) {
+		for name := range obj.(*Claim).Labels {
+			defined[name] = true
+		}
+	}
+
+	// labels users currently have
+	userLabels := make(map[string]bool)
+	if users != nil {
+		for _, user := range users.Users {
+			for name := range user.Labels {
+				userLabels[name] = true
+			}
+		}
+	}
+
+	usage.Undefined = []string{}
+	for name, objects := range usage.Labels {
+		sort.Strings(objects)
+		if !userLabels[name] && (usage.userOnly[name] || !defined[name]) {
+			usage.Undefined = append(usage.Undefined, name)
+		}
+	}
+	sort.Strings(usage.Undefined)
+
+	usage.Unused = []string{}
+	for name := range userLabels {
+		if _, referenced := usage.Labels[name]; !referenced {
+			usage.Unused = append(usage.Unused, name)
+		}
+	}
+	sort.Strings(usage.Unused)
+
+	return usage
+}
+
+// Referenced returns a sorted list of all labels referenced from the policy. It's exactly the set of user attributes,
+// which policy resolution consults
+func (usage *LabelUsage) Referenced() []string {
+	result := make([]string, 0, len(usage.Labels))
+	for name := range usage.Labels {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Warnings returns human-readable warnings for labels, which are referenced from the policy, but nobody has them
+func (usage *LabelUsage) Warnings() []string {
+	result := []string{}
+	for _, name := range usage.Undefined {
+		result = append(result, fmt.Sprintf("label '%s' is referenced from %s, but none of the users has it", name, strings.Join(usage.Labels[name], ", ")))
+	}
+	return result
+}
+
+// addCriteria extracts labels referenced from all expressions of a given criteria.
+// expressions which don't compile are skipped, as they get reported as validation errors
+func (usage *LabelUsage) addCriteria(policy *Policy, object string, criteria *Criteria, userOnly bool) {
+	if criteria == nil {
+		return
+	}
+	for _, clause := range [][]string{criteria.RequireAll, criteria.RequireAny, criteria.RequireNone} {
+		for _, expressionStr := range clause {
+			expr, err := policy.GetExpressionCache().Compile(expressionStr)
+			if err != nil {
+				continue
+			}
+			for _, name := range expr.ReferencedVariables() {
+				if expressionStructParams[name] {
+					continue
+				}
+				usage.add(object, name, userOnly)
+			}
+		}
+	}
+}
+
+// addTemplateTree extracts labels referenced from all text templates in the parameter tree
+func (usage *LabelUsage) addTemplateTree(policy *Policy, object string, node interface{}) {
+	switch value := node.(type) {
+	case string:
+		usage.addTemplate(policy, object, value)
+	case util.NestedParameterMap:
+		for _, child := range value {
+			usage.addTemplateTree(policy, object, child)
+		}
+	}
+}
+
+// addTemplate extracts labels referenced from a text template (e.g. .Labels.team or .User.Labels.team).
+// templates which don't compile are skipped, as they get reported as validation errors
+func (usage *LabelUsage) addTemplate(policy *Policy, object string, templateStr string) {
+	tmpl, err := policy.GetTemplateCache().Compile(templateStr)
+	if err != nil {
+		return
+	}
+	for _, variable := range tmpl.ReferencedVariables() {
+		if strings.HasPrefix(variable, userLabelsTemplatePrefix) {
+			usage.add(object, labelName(variable, userLabelsTemplatePrefix), true)
+		} else if strings.HasPrefix(variable, labelsTemplatePrefix) {
+			usage.add(object, labelName(variable, labelsTemplatePrefix), false)
+		}
+	}
+}
+
+func (usage *LabelUsage) add(object string, name string, userOnly bool) {
+	// references from the same object come one after another, so it's enough to check the last one
+	objects := usage.Labels[name]
+	if len(objects) == 0 || objects[len(objects)-1] != object {
+		usage.Labels[name] = append(objects, object)
+	}
+	if userOnly {
+		usage.userOnly[name] = true
+	}
+}
+
+// labelName returns label name from a template variable (e.g. 'team' from '.Labels.team.nested')
+func labelName(variable string, prefix string) string {
+	return strings.SplitN(strings.TrimPrefix(variable, prefix), ".", 2)[0]
+}
+
+// addLabelsSet adds names of all labels, which get set by given label operations
+func addLabelsSet(defined map[string]bool, ops LabelOperations) {
+	for name := range ops["set"] {
+		defined[name] = true
+	}
+}
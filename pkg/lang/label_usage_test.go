@@ -0,0 +1,45 @@
+package lang
+
+import (
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabelUsage(t *testing.T) {
+	rule := makeRule(10, "team == 'dev' || deptCode > 10 || (cluster == 'x' && Claim.Name == 'y')", 0, "cluster")
+	bundle := makeBundle("bundle", 0)
+	bundle.Components = makeBundleComponents(1, "", 0, Nil)
+	bundle.Components[0].Code.Params = util.NestedParameterMap{
+		"name": util.NestedParameterMap{"org": "{{ .User.Labels.org }}-{{ .Labels.cluster }}"},
+	}
+
+	policy := NewPolicy()
+	for _, obj := range []Base{rule, bundle} {
+		assert.NoError(t, policy.AddObject(obj), "Unable to add object to policy: %s", obj)
+	}
+
+	users := &GlobalUsers{Users: map[string]*User{
+		"alice": {Name: "alice", Labels: map[string]string{"team": "dev", "unused": "value"}},
+		"bob":   {Name: "bob", Labels: map[string]string{"org": "it"}},
+	}}
+
+	usage := NewLabelUsage(policy, users)
+	assert.Equal(t, map[string][]string{
+		"team":     {"rule main/rule"},
+		"deptCode": {"rule main/rule"},
+		"cluster":  {"bundle main/bundle", "rule main/rule"},
+		"org":      {"bundle main/bundle"},
+	}, usage.Labels, "Referenced labels should be reported along with objects referencing them")
+	assert.Equal(t, []string{"cluster", "deptCode", "org", "team"}, usage.Referenced(), "All referenced labels should be returned")
+
+	// 'cluster' is set by the rule, so it's not expected from users
+	assert.Equal(t, []string{"deptCode"}, usage.Undefined, "Labels nobody has should be reported")
+	assert.Equal(t, []string{"unused"}, usage.Unused, "User labels nobody references should be reported")
+	assert.Equal(t, []string{"label 'deptCode' is referenced from rule main/rule, but none of the users has it"}, usage.Warnings(), "Warnings should be generated for undefined labels")
+
+	// once the only user with 'org' is gone, it should be reported as well
+	delete(users.Users, "bob")
+	assert.Equal(t, []string{"deptCode", "org"}, NewLabelUsage(policy, users).Undefined, "Labels nobody has should be reported")
+}
@@ -9,11 +9,16 @@ import (
 // Kind describes type of the object (e.g. Bundle, Service, Cluster, etc)
 // Name is a user-provided string identifier of an object. Names are usually human readable and must be unique across
 // objects within the same namespace and the same object kind.
+// Annotations is a free-form map (e.g. ticket links, notes), which is stored and returned along with the object, but
+// completely ignored during policy resolution.
+// Defaulted is a list of field paths, which haven't been set explicitly and got filled from Defaults of the namespace.
 type Metadata struct {
-	Namespace  string             `yaml:",omitempty" validate:"identifier"`
-	Name       string             `yaml:",omitempty" validate:"identifier"`
-	Generation runtime.Generation `yaml:",omitempty"`
-	Deleted    bool               `yaml:",omitempty"`
+	Namespace   string             `yaml:",omitempty" validate:"identifier"`
+	Name        string             `yaml:",omitempty" validate:"identifier"`
+	Generation  runtime.Generation `yaml:",omitempty"`
+	Deleted     bool               `yaml:",omitempty"`
+	Annotations map[string]string  `yaml:",omitempty"`
+	Defaulted   []string           `yaml:",omitempty"`
 }
 
 // GetNamespace returns object namespace
@@ -36,6 +41,11 @@ func (meta *Metadata) SetGeneration(generation runtime.Generation) {
 	meta.Generation = generation
 }
 
+// GetAnnotations returns object annotations
+func (meta *Metadata) GetAnnotations() map[string]string {
+	return meta.Annotations
+}
+
 // IsDeleted returns if object deleted or not
 func (meta *Metadata) IsDeleted() bool {
 	return meta.Deleted
@@ -11,18 +11,19 @@ var (
 		TypeCluster,
 		TypeRule,
 		TypeACLRule,
+		TypeDefaults,
 	}
 
-	policyObjectsMap = make(map[runtime.Kind]bool)
+	policyObjectsMap = make(map[runtime.Kind]*runtime.TypeInfo)
 )
 
 func init() {
 	for _, obj := range PolicyTypes {
-		policyObjectsMap[obj.Kind] = true
+		policyObjectsMap[obj.Kind] = obj
 	}
 }
 
 // IsPolicyObject returns true if provided object is part of the policy objects list
 func IsPolicyObject(obj runtime.Object) bool {
-	return policyObjectsMap[obj.GetKind()]
+	return policyObjectsMap[obj.GetKind()] != nil
 }
@@ -5,6 +5,8 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/Aptomi/aptomi/pkg/lang/expression"
+	"github.com/Aptomi/aptomi/pkg/lang/template"
 	"github.com/Aptomi/aptomi/pkg/runtime"
 )
 
@@ -27,6 +29,11 @@ type Policy struct {
 	// Access control rules (who can access which objects in which policy namespaces)
 	aclMutex    sync.Mutex
 	aclResolver *ACLResolver
+
+	// Compiled expressions and text templates, shared between policy validation and policy resolution
+	cacheMutex      sync.Mutex
+	expressionCache *expression.Cache
+	templateCache   *template.Cache
 }
 
 // NewPolicy creates a new Policy
@@ -56,6 +63,28 @@ func (policy *Policy) getCachedACLResolver() *ACLResolver {
 	return policy.aclResolver
 }
 
+// GetExpressionCache returns a cache of compiled expressions for this policy, so that expressions compiled during
+// policy validation don't have to be compiled again during policy resolution
+func (policy *Policy) GetExpressionCache() *expression.Cache {
+	policy.cacheMutex.Lock()
+	defer policy.cacheMutex.Unlock()
+	if policy.expressionCache == nil {
+		policy.expressionCache = expression.NewCache()
+	}
+	return policy.expressionCache
+}
+
+// GetTemplateCache returns a cache of compiled text templates for this policy, so that templates compiled during
+// policy validation don't have to be compiled again during policy resolution
+func (policy *Policy) GetTemplateCache() *template.Cache {
+	policy.cacheMutex.Lock()
+	defer policy.cacheMutex.Unlock()
+	if policy.templateCache == nil {
+		policy.templateCache = template.NewCache()
+	}
+	return policy.templateCache
+}
+
 // View returns a policy view object, which allows to make all policy operations on behalf of a certain user
 // Policy view object will enforce all ACLs, allowing the user to only perform actions which he is allowed to perform
 // All ACL rules should be loaded and added to the policy before this method gets called
@@ -129,6 +158,7 @@ func (policy *Policy) GetObject(kind string, locator string, currentNs string) (
 // It also checks that all cross-object references are valid. If policy is malformed, then a list of errors is returned.
 // Otherwise, if policy is correctly formed, then nil is returned.
 // The resulting error can be caster to (validator.ValidationErrors) and iterated over, to get the full list of errors.
+// If all objects are well-formed, but some of the uniqueness constraints are violated, then *UniquenessError is returned.
 func (policy *Policy) Validate() error {
 	return NewPolicyValidator(policy).Validate()
 }
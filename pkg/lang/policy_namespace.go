@@ -10,13 +10,14 @@ import (
 // PolicyNamespace describes a specific namespace within Aptomi policy.
 // All policy objects get placed in the appropriate maps and structs within PolicyNamespace.
 type PolicyNamespace struct {
-	Name     string              `validate:"identifier"`
-	Bundles  map[string]*Bundle  `validate:"dive"`
-	Services map[string]*Service `validate:"dive"`
-	Clusters map[string]*Cluster `validate:"dive"`
-	Rules    map[string]*Rule    `validate:"dive"`
-	ACLRules map[string]*ACLRule `validate:"dive"`
-	Claims   map[string]*Claim   `validate:"dive"`
+	Name     string               `validate:"identifier"`
+	Bundles  map[string]*Bundle   `validate:"dive"`
+	Services map[string]*Service  `validate:"dive"`
+	Clusters map[string]*Cluster  `validate:"dive"`
+	Rules    map[string]*Rule     `validate:"dive"`
+	ACLRules map[string]*ACLRule  `validate:"dive"`
+	Claims   map[string]*Claim    `validate:"dive"`
+	Defaults map[string]*Defaults `validate:"dive"`
 }
 
 // NewPolicyNamespace creates a new PolicyNamespace
@@ -29,6 +30,7 @@ func NewPolicyNamespace(name string) *PolicyNamespace {
 		Rules:    make(map[string]*Rule),
 		ACLRules: make(map[string]*ACLRule),
 		Claims:   make(map[string]*Claim),
+		Defaults: make(map[string]*Defaults),
 	}
 }
 
@@ -65,6 +67,8 @@ func (policyNamespace *PolicyNamespace) addObject(obj Base) error {
 		policyNamespace.ACLRules[obj.GetName()] = obj.(*ACLRule) // nolint: errcheck
 	case TypeClaim.Kind:
 		policyNamespace.Claims[obj.GetName()] = obj.(*Claim) // nolint: errcheck
+	case TypeDefaults.Kind:
+		policyNamespace.Defaults[obj.GetName()] = obj.(*Defaults) // nolint: errcheck
 	default:
 		return fmt.Errorf("not supported by PolicyNamespace.addObject(): unknown kind %s", kind)
 	}
@@ -103,6 +107,11 @@ func (policyNamespace *PolicyNamespace) removeObject(obj Base) bool {
 			delete(policyNamespace.Claims, obj.GetName())
 			return true
 		}
+	case TypeDefaults.Kind:
+		if _, exist := policyNamespace.Defaults[obj.GetName()]; exist {
+			delete(policyNamespace.Defaults, obj.GetName())
+			return true
+		}
 	}
 
 	return false
@@ -135,6 +144,10 @@ func (policyNamespace *PolicyNamespace) getObjectsByKind(kind string) []Base {
 		for _, claim := range policyNamespace.Claims {
 			result = append(result, claim)
 		}
+	case TypeDefaults.Kind:
+		for _, defaults := range policyNamespace.Defaults {
+			result = append(result, defaults)
+		}
 	default:
 		panic(fmt.Sprintf("not supported by PolicyNamespace.getObjectsByKind(): unknown kind %s", kind))
 	}
@@ -169,6 +182,10 @@ func (policyNamespace *PolicyNamespace) getObject(kind string, name string) (run
 		if result, ok = policyNamespace.Claims[name]; !ok {
 			return nil, nil
 		}
+	case TypeDefaults.Kind:
+		if result, ok = policyNamespace.Defaults[name]; !ok {
+			return nil, nil
+		}
 	default:
 		return nil, fmt.Errorf("not supported by PolicyNamespace.getObject(): unknown kind %s, %s", kind, name)
 	}
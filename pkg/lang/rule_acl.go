@@ -140,15 +140,17 @@ var DomainAdmin = &ACLRole{
 	Privileges: &Privileges{
 		AllNamespaces: true,
 		NamespaceObjects: map[string]*Privilege{
-			TypeBundle.Kind:  fullAccess,
-			TypeService.Kind: fullAccess,
-			TypeClaim.Kind:   fullAccess,
-			TypeRule.Kind:    fullAccess,
+			TypeBundle.Kind:   fullAccess,
+			TypeService.Kind:  fullAccess,
+			TypeClaim.Kind:    fullAccess,
+			TypeRule.Kind:     fullAccess,
+			TypeDefaults.Kind: fullAccess,
 		},
 		GlobalObjects: map[string]*Privilege{
-			TypeCluster.Kind: fullAccess,
-			TypeRule.Kind:    fullAccess,
-			TypeACLRule.Kind: fullAccess,
+			TypeCluster.Kind:  fullAccess,
+			TypeRule.Kind:     fullAccess,
+			TypeACLRule.Kind:  fullAccess,
+			TypeDefaults.Kind: fullAccess,
 		},
 	},
 }
@@ -159,15 +161,17 @@ var NamespaceAdmin = &ACLRole{
 	Name: "Namespace Admin",
 	Privileges: &Privileges{
 		NamespaceObjects: map[string]*Privilege{
-			TypeBundle.Kind:  fullAccess,
-			TypeService.Kind: fullAccess,
-			TypeClaim.Kind:   fullAccess,
-			TypeRule.Kind:    fullAccess,
+			TypeBundle.Kind:   fullAccess,
+			TypeService.Kind:  fullAccess,
+			TypeClaim.Kind:    fullAccess,
+			TypeRule.Kind:     fullAccess,
+			TypeDefaults.Kind: fullAccess,
 		},
 		GlobalObjects: map[string]*Privilege{
-			TypeCluster.Kind: viewAccess,
-			TypeRule.Kind:    viewAccess,
-			TypeACLRule.Kind: viewAccess,
+			TypeCluster.Kind:  viewAccess,
+			TypeRule.Kind:     viewAccess,
+			TypeACLRule.Kind:  viewAccess,
+			TypeDefaults.Kind: viewAccess,
 		},
 	},
 }
@@ -178,15 +182,17 @@ var ServiceConsumer = &ACLRole{
 	Name: "Service Consumer",
 	Privileges: &Privileges{
 		NamespaceObjects: map[string]*Privilege{
-			TypeBundle.Kind:  viewAccess,
-			TypeService.Kind: viewAccess,
-			TypeClaim.Kind:   fullAccess,
-			TypeRule.Kind:    viewAccess,
+			TypeBundle.Kind:   viewAccess,
+			TypeService.Kind:  viewAccess,
+			TypeClaim.Kind:    fullAccess,
+			TypeRule.Kind:     viewAccess,
+			TypeDefaults.Kind: viewAccess,
 		},
 		GlobalObjects: map[string]*Privilege{
-			TypeCluster.Kind: viewAccess,
-			TypeRule.Kind:    viewAccess,
-			TypeACLRule.Kind: viewAccess,
+			TypeCluster.Kind:  viewAccess,
+			TypeRule.Kind:     viewAccess,
+			TypeACLRule.Kind:  viewAccess,
+			TypeDefaults.Kind: viewAccess,
 		},
 	},
 }
@@ -197,15 +203,17 @@ var nobody = &ACLRole{
 	Name: "Nobody",
 	Privileges: &Privileges{
 		NamespaceObjects: map[string]*Privilege{
-			TypeBundle.Kind:  viewAccess,
-			TypeService.Kind: viewAccess,
-			TypeClaim.Kind:   viewAccess,
-			TypeRule.Kind:    viewAccess,
+			TypeBundle.Kind:   viewAccess,
+			TypeService.Kind:  viewAccess,
+			TypeClaim.Kind:    viewAccess,
+			TypeRule.Kind:     viewAccess,
+			TypeDefaults.Kind: viewAccess,
 		},
 		GlobalObjects: map[string]*Privilege{
-			TypeCluster.Kind: viewAccess,
-			TypeRule.Kind:    viewAccess,
-			TypeACLRule.Kind: viewAccess,
+			TypeCluster.Kind:  viewAccess,
+			TypeRule.Kind:     viewAccess,
+			TypeACLRule.Kind:  viewAccess,
+			TypeDefaults.Kind: viewAccess,
 		},
 	},
 }
@@ -27,6 +27,10 @@ type Service struct {
 	// the service gets matched
 	ChangeLabels LabelOperations `yaml:"change-labels,omitempty" validate:"labelOperations"`
 
+	// ExternalPort is an optional port on which the service is exposed to the outside world. It must be unique
+	// across all services in the policy
+	ExternalPort int `yaml:"external-port,omitempty" validate:"omitempty,min=1,max=65535" unique:"external-port"`
+
 	// Contexts contains an ordered list of contexts within a service. When allocating an instance, Aptomi will pick
 	// and instantiate the first context which matches the criteria
 	Contexts []*Context `validate:"dive"`
@@ -12,25 +12,34 @@ func NewCache() *Cache {
 	return &Cache{tCache: sync.Map{}}
 }
 
+// Compile returns compiled text template. If an compiled text template already exists in cache, it will be returned.
+// Otherwise it will get compiled and added to the cache.
+// This method is thread-safe and can be called concurrently from multiple goroutines.
+func (cache *Cache) Compile(templateStr string) (*Template, error) {
+	// Look up template from the cache
+	templateCached, ok := cache.tCache.Load(templateStr)
+	if ok {
+		return templateCached.(*Template), nil // nolint: errcheck
+	}
+
+	// Compile template, if not found
+	// This might happen a several times in parallel, that's okay
+	template, err := NewTemplate(templateStr)
+	if err != nil {
+		return nil, err
+	}
+	cache.tCache.Store(templateStr, template)
+	return template, nil
+}
+
 // Evaluate evaluates text template given a set of parameters.
 // If an compiled text template already exists in cache, it will be used.
 // Otherwise it will get compiled and added to the cache before evaluating the text template.
 // This method is thread-safe and can be called concurrently from multiple goroutines.
 func (cache *Cache) Evaluate(templateStr string, params *Parameters) (string, error) {
-	// Look up template from the cache
-	var template *Template
-	templateCached, ok := cache.tCache.Load(templateStr)
-	if ok {
-		template = templateCached.(*Template) // nolint: errcheck
-	} else {
-		// Compile template, if not found
-		// This might happen a several times in parallel, that's okay
-		var err error
-		template, err = NewTemplate(templateStr)
-		if err != nil {
-			return "", err
-		}
-		cache.tCache.Store(templateStr, template)
+	template, err := cache.Compile(templateStr)
+	if err != nil {
+		return "", err
 	}
 
 	// Evaluate template
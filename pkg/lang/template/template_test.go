@@ -94,3 +94,50 @@ func TestTemplateEvaluation(t *testing.T) {
 	}
 
 }
+
+func TestTemplateUnknownVariables(t *testing.T) {
+	known := Variables{
+		"User": {
+			"Name":   {},
+			"Labels": nil,
+		},
+		"Labels": nil,
+	}
+
+	tests := []struct {
+		template string
+		unknown  []string
+	}{
+		{"test-{{.User.Labels.team}}-{{.Labels.tagname}}-{{ .User.Name }}", []string{}},
+		{"{{ if .User.Labels.team }}{{ .User.Lables.team }}{{ else }}{{ .Missing }}{{ end }}", []string{".Missing", ".User.Lables"}},
+		{"{{ default \"abc\" .User.Name.Nested }}-{{ $.Claim.ID }}", []string{".Claim", ".User.Name.Nested"}},
+		{"{{ with .User.Labels }}{{ .team }}{{ end }}", []string{}},
+		{"{{ range .Labels }}{{ .anything }}{{ end }}", []string{}},
+	}
+
+	for _, test := range tests {
+		tmpl, err := NewTemplate(test.template)
+		if assert.NoError(t, err, "Template should be compiled: %s", test.template) {
+			assert.Equal(t, test.unknown, tmpl.UnknownVariables(known), "Unknown variables: %s", test.template)
+		}
+	}
+}
+
+func TestTemplateReferencedVariables(t *testing.T) {
+	tests := []struct {
+		template   string
+		referenced []string
+	}{
+		{"test-{{.User.Labels.team}}-{{.Labels.tagname}}-{{ .User.Name }}", []string{".Labels.tagname", ".User.Labels.team", ".User.Name"}},
+		{"{{ if .Labels.a }}{{ .Labels.b }}{{ else }}{{ $.Labels.c }}{{ end }}", []string{".Labels.a", ".Labels.b", ".Labels.c"}},
+		{"{{ with .User.Labels }}{{ .team }}{{ end }}", []string{".User.Labels"}},
+		{"plain text", []string{}},
+	}
+
+	for _, test := range tests {
+		tmpl, err := NewTemplate(test.template)
+		if assert.NoError(t, err, "Template should be compiled: %s", test.template) {
+			assert.Equal(t, test.referenced, tmpl.ReferencedVariables(), "Referenced variables: %s", test.template)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package template
+
+import (
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// Variables is a tree of variables which can be referenced from text templates (e.g. .User.Labels). A nil subtree
+// means that any nested variables are allowed (e.g. any label name under .Labels), while an empty subtree means that
+// the variable has no nested variables
+type Variables map[string]Variables
+
+// UnknownVariables returns a sorted list of variables referenced from the template, which are not in the list of
+// known variables (e.g. misspelled '.User.Lables'). Only references to the top-level data are checked, while
+// references inside 'with' and 'range' blocks are skipped, because data gets rebound there
+func (template *Template) UnknownVariables(known Variables) []string {
+	unknown := make(map[string]bool)
+	template.walk(func(ident []string) {
+		checkIdent(ident, known, unknown)
+	})
+	return sortedKeys(unknown)
+}
+
+// ReferencedVariables returns a sorted list of all variables referenced from the template (e.g. '.User.Labels.team').
+// Same as for UnknownVariables, only references to the top-level data are returned
+func (template *Template) ReferencedVariables() []string {
+	referenced := make(map[string]bool)
+	template.walk(func(ident []string) {
+		referenced["."+strings.Join(ident, ".")] = true
+	})
+	return sortedKeys(referenced)
+}
+
+// walk calls visit for every reference to the top-level data in the template
+func (template *Template) walk(visit func(ident []string)) {
+	for _, tmpl := range template.templateCompiled.Templates() {
+		if tmpl.Tree != nil {
+			walkNode(tmpl.Tree.Root, visit)
+		}
+	}
+}
+
+func walkNode(node parse.Node, visit func(ident []string)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkNode(child, visit)
+		}
+	case *parse.ActionNode:
+		walkNode(n.Pipe, visit)
+	case *parse.IfNode:
+		walkNode(n.Pipe, visit)
+		walkNode(n.List, visit)
+		walkNode(n.ElseList, visit)
+	case *parse.WithNode:
+		walkNode(n.Pipe, visit)
+		walkNode(n.ElseList, visit)
+	case *parse.RangeNode:
+		walkNode(n.Pipe, visit)
+		walkNode(n.ElseList, visit)
+	case *parse.TemplateNode:
+		walkNode(n.Pipe, visit)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			walkNode(cmd, visit)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walkNode(arg, visit)
+		}
+	case *parse.FieldNode:
+		visit(n.Ident)
+	case *parse.VariableNode:
+		// $ always refers to the top-level data
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			visit(n.Ident[1:])
+		}
+	}
+}
+
+func checkIdent(ident []string, known Variables, unknown map[string]bool) {
+	vars := known
+	for idx, name := range ident {
+		if vars == nil {
+			return
+		}
+		child, ok := vars[name]
+		if !ok {
+			unknown["."+strings.Join(ident[:idx+1], ".")] = true
+			return
+		}
+		vars = child
+	}
+}
+
+func sortedKeys(set map[string]bool) []string {
+	result := make([]string, 0, len(set))
+	for key := range set {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
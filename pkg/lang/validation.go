@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 
-	"github.com/Aptomi/aptomi/pkg/lang/expression"
 	"github.com/Aptomi/aptomi/pkg/lang/template"
 	"github.com/Aptomi/aptomi/pkg/runtime"
 	"github.com/Aptomi/aptomi/pkg/util"
@@ -30,7 +30,8 @@ var (
 type contextKey string
 
 var policyKey = contextKey("policy")
-var errorsKey = contextKey("errors")
+var detailsKey = contextKey("details")
+var warningsKey = contextKey("warnings")
 
 func (c contextKey) String() string {
 	return "lang context key " + string(c)
@@ -39,14 +40,24 @@ func (c contextKey) String() string {
 // Custom error for policy validation
 type policyValidationError struct {
 	errList []string
+	errors  []*ValidationError
 }
 
 func (err policyValidationError) Error() string {
 	return strings.Join(err.errList, "\n")
 }
 
-func (err *policyValidationError) addError(errStr string) {
+func (err *policyValidationError) addError(errStr string, structured *ValidationError) {
 	err.errList = append(err.errList, errStr)
+	err.errors = append(err.errors, structured)
+}
+
+// Additional details for field validation errors (e.g. expression compilation errors), keyed by field value
+type fieldErrorDetails map[string]string
+
+// Warnings found during policy validation, which don't make policy invalid (e.g. references to unknown variables)
+type policyValidationWarnings struct {
+	warnList []string
 }
 
 // PolicyValidator is a custom validator for the policy
@@ -80,10 +91,12 @@ func NewPolicyValidator(policy *Policy) *PolicyValidator {
 	result.RegisterStructValidationCtx(validateBundle, Bundle{})
 	result.RegisterStructValidationCtx(validateClaim, Claim{})
 	result.RegisterStructValidationCtx(validateService, Service{})
+	result.RegisterStructValidationCtx(validateDefaults, Defaults{})
 
 	// context
 	ctx := context.WithValue(context.Background(), policyKey, policy)
-	ctx = context.WithValue(ctx, errorsKey, &policyValidationError{})
+	ctx = context.WithValue(ctx, detailsKey, fieldErrorDetails{})
+	ctx = context.WithValue(ctx, warningsKey, &policyValidationWarnings{})
 
 	// default translations
 	eng := english.New()
@@ -167,6 +180,14 @@ func NewPolicyValidator(policy *Policy) *PolicyValidator {
 			tag:         "aclRuleActions",
 			translation: fmt.Sprintf("is a required field (role assignment map must be specified)"),
 		},
+		{
+			tag:         "defaultsPath",
+			translation: fmt.Sprintf("'{0}' can't have a default value ({1})"),
+		},
+		{
+			tag:         "defaultsSingle",
+			translation: fmt.Sprintf("'{0}' is not allowed, namespace should have only one defaults object"),
+		},
 	}
 	for _, t := range translations {
 		err = result.RegisterTranslation(t.tag, trans, registrationFunc(t.tag, t.translation), translateFunc)
@@ -202,34 +223,50 @@ func translateFunc(ut ut.Translator, fe validator.FieldError) string {
 
 // Validate validates the entire policy for errors and returns an error (it can be casted to
 // policyValidationError, containing a list of errors inside). When error is printed as string, it will
-// automatically contains the full list of validation errors.
+// automatically contains the full list of validation errors. Every error contains a path to the invalid field
+// (including the object it belongs to), the offending value and, if available, additional details (e.g. why
+// expression failed to compile). If all objects are well-formed, uniqueness constraints get checked as well.
+//
+// All expressions and text templates get compiled during validation and put into the policy cache, so they don't
+// have to be compiled again during policy resolution.
 func (v *PolicyValidator) Validate() error {
 	// validate policy
 	err := v.val.StructCtx(v.ctx, v.policy)
 	if err == nil {
-		return nil
+		return v.policy.validateUniqueness()
 	}
 
 	// collect human-readable errors
 	result := policyValidationError{}
-	vErrors := err.(validator.ValidationErrors) // nolint: errcheck
+	details := v.ctx.Value(detailsKey).(fieldErrorDetails) // nolint: errcheck
+	vErrors := err.(validator.ValidationErrors)            // nolint: errcheck
 	for _, vErr := range vErrors {
-		errStr := fmt.Sprintf("%s: %s", vErr.Namespace(), vErr.Translate(v.trans))
-		result.addError(errStr)
-	}
-
-	// collect additional errors stored in context
-	for _, errStr := range v.ctx.Value(errorsKey).(*policyValidationError).errList { // nolint: errcheck
-		result.addError(errStr)
+		message := vErr.Translate(v.trans)
+		if detail, ok := details[fmt.Sprintf("%v", vErr.Value())]; ok {
+			message = fmt.Sprintf("%s (%s)", message, detail)
+		}
+		result.addError(fmt.Sprintf("%s: %s", vErr.Namespace(), message), newFieldValidationError(vErr.Namespace(), message))
 	}
 
 	return result
 }
 
-// adds validation error to the context
-func attachErrorToContext(ctx context.Context, _ validator.FieldLevel, errMsg string) {
-	pve := ctx.Value(errorsKey).(*policyValidationError) // nolint: errcheck
-	pve.errList = append(pve.errList, errMsg)
+// Warnings returns the list of warnings found during policy validation. They don't make policy invalid, but
+// likely indicate a problem (e.g. template refers to a misspelled variable, which will never be bound)
+func (v *PolicyValidator) Warnings() []string {
+	return v.ctx.Value(warningsKey).(*policyValidationWarnings).warnList // nolint: errcheck
+}
+
+// adds details for the field validation error to the context
+func attachErrorToContext(ctx context.Context, fl validator.FieldLevel, errMsg string) {
+	details := ctx.Value(detailsKey).(fieldErrorDetails) // nolint: errcheck
+	details[fmt.Sprintf("%v", fl.Field().Interface())] = errMsg
+}
+
+// adds validation warning to the context
+func attachWarningToContext(ctx context.Context, warnMsg string) {
+	warnings := ctx.Value(warningsKey).(*policyValidationWarnings) // nolint: errcheck
+	warnings.warnList = append(warnings.warnList, warnMsg)
 }
 
 // checks in a given field is a string, and it has a valid value (one of the values from a given string array)
@@ -259,8 +296,8 @@ func validateIdentifier(ctx context.Context, fl validator.FieldLevel) bool {
 
 // checks if a given string is valid expression
 func validateExpression(ctx context.Context, fl validator.FieldLevel) bool {
-	value := fl.Field().String()
-	_, err := expression.NewExpression(value)
+	policy := ctx.Value(policyKey).(*Policy) // nolint: errcheck
+	_, err := policy.GetExpressionCache().Compile(fl.Field().String())
 	if err != nil {
 		attachErrorToContext(ctx, fl, err.Error())
 	}
@@ -269,7 +306,8 @@ func validateExpression(ctx context.Context, fl validator.FieldLevel) bool {
 
 // checks if a given string is valid template
 func validateTemplate(ctx context.Context, fl validator.FieldLevel) bool {
-	_, err := template.NewTemplate(fl.Field().String())
+	policy := ctx.Value(policyKey).(*Policy) // nolint: errcheck
+	_, err := policy.GetTemplateCache().Compile(fl.Field().String())
 	if err != nil {
 		attachErrorToContext(ctx, fl, err.Error())
 	}
@@ -278,8 +316,9 @@ func validateTemplate(ctx context.Context, fl validator.FieldLevel) bool {
 
 // checks if a given nested map is a valid map of text templates (e.g. code parameters, discovery parameters, etc)
 func validateTemplateNestedMap(ctx context.Context, fl validator.FieldLevel) bool {
+	policy := ctx.Value(policyKey).(*Policy)                 // nolint: errcheck
 	pMap := fl.Field().Interface().(util.NestedParameterMap) // nolint: errcheck
-	_, err := util.ProcessParameterTree(pMap, nil, nil, util.ModeCompile)
+	_, err := util.ProcessParameterTree(pMap, nil, policy.GetTemplateCache(), util.ModeCompile)
 	if err != nil {
 		attachErrorToContext(ctx, fl, err.Error())
 	}
@@ -387,6 +426,15 @@ func validateBundle(ctx context.Context, sl validator.StructLevel) {
 			}
 		}
 	}
+
+	// templates in code params and discovery should only refer to known variables
+	object := fmt.Sprintf("bundle %s/%s", bundle.Namespace, bundle.Name)
+	for _, component := range bundle.Components {
+		if component.Code != nil {
+			checkTemplateTreeVariables(ctx, object, fmt.Sprintf("Component[%s].Code.Params", component.Name), component.Code.Params, codeTemplateVariables)
+		}
+		checkTemplateTreeVariables(ctx, object, fmt.Sprintf("Component[%s].Discovery", component.Name), component.Discovery, codeTemplateVariables)
+	}
 }
 
 // checks if claim is valid
@@ -394,11 +442,13 @@ func validateClaim(ctx context.Context, sl validator.StructLevel) {
 	claim := sl.Current().Addr().Interface().(*Claim) // nolint: errcheck
 	policy := ctx.Value(policyKey).(*Policy)          // nolint: errcheck
 
-	// claim should point to an existing service
-	obj, err := policy.GetObject(TypeService.Kind, claim.Service, claim.Namespace)
-	if obj == nil || err != nil {
-		sl.ReportError(claim.Service, fmt.Sprintf("Service[%s/%s]", claim.Namespace, claim.Service), "", "exists", "")
-		return
+	// claim should point to an existing service, as well as all fallback services should exist
+	for _, service := range claim.GetServices() {
+		obj, err := policy.GetObject(TypeService.Kind, service, claim.Namespace)
+		if obj == nil || err != nil {
+			sl.ReportError(service, fmt.Sprintf("Service[%s/%s]", claim.Namespace, service), "", "exists", "")
+			return
+		}
 	}
 }
 
@@ -419,6 +469,103 @@ func validateService(ctx context.Context, sl validator.StructLevel) {
 			return
 		}
 	}
+
+	// allocation keys should only refer to known variables
+	object := fmt.Sprintf("service %s/%s", service.Namespace, service.Name)
+	for _, serviceCtx := range service.Contexts {
+		if serviceCtx.Allocation == nil {
+			continue
+		}
+		for idx, key := range serviceCtx.Allocation.Keys {
+			checkTemplateVariables(ctx, object, fmt.Sprintf("Contexts[%s].Allocation.Keys[%d]", serviceCtx.Name, idx), key, allocationTemplateVariables)
+		}
+	}
+}
+
+// Variables available to code params and discovery templates. Must be kept in sync with the contextual data
+// exposed by the policy resolver (see getContextualDataForCodeDiscoveryTemplate)
+var codeTemplateVariables = template.Variables{
+	"User": {
+		"Name":    {},
+		"Labels":  nil,
+		"Secrets": nil,
+	},
+	"Labels":    nil,
+	"Discovery": nil,
+	"Target": {
+		"Namespace": {},
+	},
+}
+
+// Variables available to allocation key templates. Must be kept in sync with the contextual data exposed by the
+// policy resolver (see getContextualDataForContextAllocationTemplate)
+var allocationTemplateVariables = template.Variables{
+	"User": codeTemplateVariables["User"],
+	"Claim": {
+		"Namespace":   {},
+		"Name":        {},
+		"Generation":  {},
+		"Deleted":     {},
+		"Annotations": nil,
+		"ID":          {},
+	},
+	"Labels": nil,
+}
+
+// checks that all text templates in the parameter tree only refer to known variables
+func checkTemplateTreeVariables(ctx context.Context, object string, path string, node interface{}, known template.Variables) {
+	switch value := node.(type) {
+	case string:
+		checkTemplateVariables(ctx, object, path, value, known)
+	case util.NestedParameterMap:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			checkTemplateTreeVariables(ctx, object, path+"."+key, value[key], known)
+		}
+	}
+}
+
+// checks that text template only refers to known variables and attaches warnings to the context otherwise.
+// templates which don't compile are skipped, as they get reported as validation errors
+func checkTemplateVariables(ctx context.Context, object string, path string, templateStr string, known template.Variables) {
+	policy := ctx.Value(policyKey).(*Policy) // nolint: errcheck
+	tmpl, err := policy.GetTemplateCache().Compile(templateStr)
+	if err != nil {
+		return
+	}
+	for _, variable := range tmpl.UnknownVariables(known) {
+		attachWarningToContext(ctx, fmt.Sprintf("%s: %s: template '%s' refers to unknown variable '%s'", object, path, templateStr, variable))
+	}
+}
+
+// checks if defaults are valid
+func validateDefaults(ctx context.Context, sl validator.StructLevel) {
+	defaults := sl.Current().Addr().Interface().(*Defaults) // nolint: errcheck
+	policy := ctx.Value(policyKey).(*Policy)                // nolint: errcheck
+
+	// there should be only one defaults object per namespace
+	if nsDefaults := policy.GetDefaults(defaults.Namespace); nsDefaults != nil && nsDefaults.Name != defaults.Name {
+		sl.ReportError(defaults.Name, "Name", "", "defaultsSingle", "")
+		return
+	}
+
+	// every path should point to an existing field, which can hold a default value
+	for _, kind := range util.GetSortedStringKeys(defaults.Values) {
+		for _, path := range defaults.GetPaths(kind) {
+			err := validateDefaultsPath(kind, path)
+			if err == nil {
+				err = validateDefaultsValue(kind, path, defaults.Values[kind][path])
+			}
+			if err != nil {
+				sl.ReportError(path, fmt.Sprintf("Values[%s][%s]", kind, path), "", "defaultsPath", err.Error())
+				return
+			}
+		}
+	}
 }
 
 // checks if rule is valid
@@ -0,0 +1,94 @@
+package lang
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+// ValidationError represents a single policy validation error in structured form. Namespace, Kind and Name point to
+// the invalid object and Field points to the invalid field of it, all of them are empty if error isn't specific
+type ValidationError struct {
+	Namespace string `yaml:",omitempty"`
+	Kind      string `yaml:",omitempty"`
+	Name      string `yaml:",omitempty"`
+	Field     string `yaml:",omitempty"`
+	Message   string
+}
+
+func (err *ValidationError) String() string {
+	result := err.Message
+	if len(err.Field) > 0 {
+		result = err.Field + ": " + result
+	}
+	if len(err.Kind) > 0 {
+		result = fmt.Sprintf("%s %s/%s: %s", err.Kind, err.Namespace, err.Name, result)
+	}
+	return result
+}
+
+// fieldPathRegex matches path to the invalid field reported by validator, e.g. Policy.Namespace[main].Claims[name].Field
+var fieldPathRegex = regexp.MustCompile(`^Policy\.Namespace\[([^\]]*)\]\.(\w+)\[([^\]]*)\]\.?(.*)$`)
+
+// kindByNamespaceField maps fields of PolicyNamespace to kinds of objects they hold
+var kindByNamespaceField = map[string]string{
+	"Bundles":  TypeBundle.Kind,
+	"Services": TypeService.Kind,
+	"Clusters": TypeCluster.Kind,
+	"Rules":    TypeRule.Kind,
+	"ACLRules": TypeACLRule.Kind,
+	"Claims":   TypeClaim.Kind,
+	"Defaults": TypeDefaults.Kind,
+}
+
+// newFieldValidationError returns validation error for the field with a given path reported by validator
+func newFieldValidationError(path string, message string) *ValidationError {
+	match := fieldPathRegex.FindStringSubmatch(path)
+	if match == nil {
+		return &ValidationError{Field: path, Message: message}
+	}
+
+	kind, ok := kindByNamespaceField[match[2]]
+	if !ok {
+		return &ValidationError{Field: path, Message: message}
+	}
+
+	return &ValidationError{
+		Namespace: match[1],
+		Kind:      kind,
+		Name:      match[3],
+		Field:     match[4],
+		Message:   message,
+	}
+}
+
+// GetValidationErrors returns the list of validation errors in structured form from the error returned by policy
+// validation. Every object sharing non unique value gets its own error. Errors of any other type are returned as a
+// single error, which doesn't point to any object
+func GetValidationErrors(err error) []*ValidationError {
+	switch vErr := err.(type) {
+	case nil:
+		return nil
+	case policyValidationError:
+		return vErr.errors
+	case *UniquenessError:
+		result := make([]*ValidationError, 0)
+		for _, conflict := range vErr.Conflicts {
+			for _, key := range conflict.Objects {
+				objErr := &ValidationError{
+					Field:   conflict.Constraint,
+					Message: fmt.Sprintf("'%s' is not unique, shared by %s", conflict.Value, strings.Join(conflict.Objects, ", ")),
+				}
+				if parts := strings.Split(key, runtime.KeySeparator); len(parts) == 3 {
+					objErr.Namespace, objErr.Kind, objErr.Name = parts[0], parts[1], parts[2]
+				}
+				result = append(result, objErr)
+			}
+		}
+		return result
+	}
+
+	return []*ValidationError{{Message: err.Error()}}
+}
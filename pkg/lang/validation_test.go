@@ -154,6 +154,83 @@ func TestPolicyValidationCluster(t *testing.T) {
 	})
 }
 
+func TestPolicyValidationUniqueness(t *testing.T) {
+	bundle := makeBundle("bundle", 0)
+	service1 := makeService("service1", 0, bundle.Name)
+	service1.ExternalPort = 8080
+	service2 := makeService("service2", 0, bundle.Name)
+	service2.ExternalPort = 8081
+	runValidationTests(t, ResSuccess, false, []Base{bundle, service1, service2})
+
+	// two services sharing the same external port
+	service2.ExternalPort = 8080
+	runValidationTests(t, ResFailure, false, []Base{bundle, service1, service2})
+
+	policy := NewPolicy()
+	for _, obj := range []Base{bundle, service1, service2} {
+		assert.NoError(t, policy.AddObject(obj), "Unable to add object to policy: %s", obj)
+	}
+	err := policy.Validate()
+	if assert.IsType(t, &UniquenessError{}, err, "Uniqueness error should be returned") {
+		conflicts := err.(*UniquenessError).Conflicts
+		if assert.Len(t, conflicts, 1, "Exactly one conflict should be reported") {
+			assert.Equal(t, "external-port", conflicts[0].Constraint)
+			assert.Equal(t, "8080", conflicts[0].Value)
+			assert.Equal(t, []runtime.Key{runtime.KeyForStorable(service1), runtime.KeyForStorable(service2)}, conflicts[0].Objects)
+		}
+	}
+}
+
+func TestPolicyValidationTemplateVariables(t *testing.T) {
+	bundle := makeBundle("bundle", 0)
+	bundle.Components = makeBundleComponents(1, "", 0, Nil)
+	bundle.Components[0].Code.Params = util.NestedParameterMap{
+		"team":    "{{ .User.Labels.team }}",
+		"nested":  util.NestedParameterMap{"team": "{{ .User.Lables.team }}"},
+		"cluster": "{{ .Target.Namespace }}-{{ .Discovery.Instance }}",
+	}
+	service := makeService("service", 0, bundle.Name)
+	service.Contexts[0].Allocation.Keys = []string{"{{ .Claim.ID }}", "{{ .Claim.Identifier }}"}
+
+	policy := NewPolicy()
+	for _, obj := range []Base{bundle, service} {
+		assert.NoError(t, policy.AddObject(obj), "Unable to add object to policy: %s", obj)
+	}
+
+	// misspelled variables should not make policy invalid, but should be reported as warnings
+	validator := NewPolicyValidator(policy)
+	assert.NoError(t, validator.Validate(), "Policy with unknown template variables should be valid")
+	assert.Equal(t, []string{
+		"bundle main/bundle: Component[component-0].Code.Params.nested.team: template '{{ .User.Lables.team }}' refers to unknown variable '.User.Lables'",
+		"service main/service: Contexts[context].Allocation.Keys[1]: template '{{ .Claim.Identifier }}' refers to unknown variable '.Claim.Identifier'",
+	}, validator.Warnings(), "Unknown template variables should be reported as warnings")
+}
+
+func TestPolicyValidationErrorDetails(t *testing.T) {
+	rule := makeRule(10, "true", 0, "name")
+	rule.Criteria.RequireAll = []string{"specialname + 'a' +"}
+
+	policy := NewPolicy()
+	assert.NoError(t, policy.AddObject(rule), "Unable to add object to policy: %s", rule)
+
+	// error should point to the invalid field, contain the expression and the reason why it's invalid
+	err := policy.Validate()
+	if assert.Error(t, err, "Policy with invalid expression should not be valid") {
+		assert.Contains(t, err.Error(), "Criteria.RequireAll[0]", "Error should contain path to the invalid field")
+		assert.Contains(t, err.Error(), "'specialname + 'a' +' is not a valid expression (", "Error should contain the expression and details")
+
+		// the same error should be available in structured form
+		errors := GetValidationErrors(err)
+		if assert.Len(t, errors, 1, "Single structured error should be returned") {
+			assert.Equal(t, rule.Namespace, errors[0].Namespace)
+			assert.Equal(t, TypeRule.Kind, errors[0].Kind)
+			assert.Equal(t, rule.Name, errors[0].Name)
+			assert.Equal(t, "Criteria.RequireAll[0]", errors[0].Field)
+			assert.Contains(t, errors[0].Message, "'specialname + 'a' +' is not a valid expression (")
+		}
+	}
+}
+
 func runValidationTests(t *testing.T, result int, every bool, objects []Base) {
 	t.Helper()
 
@@ -0,0 +1,119 @@
+package lang
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+// UniqueTag is a struct tag used to declare that a field of the policy object must have a unique value policy-wide.
+// Tag value is a name of the uniqueness constraint, so fields of different object kinds could share the same
+// constraint (e.g. `unique:"external-port"`). Zero values are not checked
+const UniqueTag = "unique"
+
+// UniquenessConflict represents a single value of the uniqueness constraint shared by several objects
+type UniquenessConflict struct {
+	Constraint string
+	Value      string
+	Objects    []runtime.Key
+}
+
+// UniquenessError is returned by policy validation when some of the uniqueness constraints are violated
+type UniquenessError struct {
+	Conflicts []*UniquenessConflict
+}
+
+func (err *UniquenessError) Error() string {
+	errList := []string{}
+	for _, conflict := range err.Conflicts {
+		errList = append(errList, fmt.Sprintf("%s: '%s' is not unique, shared by %s", conflict.Constraint, conflict.Value, strings.Join(conflict.Objects, ", ")))
+	}
+	return strings.Join(errList, "\n")
+}
+
+// uniquenessIndex maps constraint name -> value -> list of object keys with that value
+type uniquenessIndex map[string]map[string][]runtime.Key
+
+// add puts values of all unique fields of a given object into the index
+func (index uniquenessIndex) add(obj Base) {
+	index.addStruct(runtime.KeyForStorable(obj), reflect.ValueOf(obj))
+}
+
+func (index uniquenessIndex) addStruct(key runtime.Key, value reflect.Value) {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if len(field.PkgPath) > 0 {
+			// skip unexported fields
+			continue
+		}
+
+		constraint, ok := field.Tag.Lookup(UniqueTag)
+		if !ok {
+			if field.Anonymous {
+				index.addStruct(key, value.Field(i))
+			}
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		if reflect.DeepEqual(fieldValue.Interface(), reflect.Zero(fieldValue.Type()).Interface()) {
+			continue
+		}
+
+		if index[constraint] == nil {
+			index[constraint] = make(map[string][]runtime.Key)
+		}
+		valueStr := fmt.Sprintf("%v", fieldValue.Interface())
+		index[constraint][valueStr] = append(index[constraint][valueStr], key)
+	}
+}
+
+// conflicts returns all values shared by more than one object, sorted by constraint name and value
+func (index uniquenessIndex) conflicts() []*UniquenessConflict {
+	result := []*UniquenessConflict{}
+	for constraint, values := range index {
+		for value, keys := range values {
+			if len(keys) > 1 {
+				sort.Strings(keys)
+				result = append(result, &UniquenessConflict{Constraint: constraint, Value: value, Objects: keys})
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Constraint != result[j].Constraint {
+			return result[i].Constraint < result[j].Constraint
+		}
+		return result[i].Value < result[j].Value
+	})
+	return result
+}
+
+// validateUniqueness checks that all fields tagged as unique have distinct values across all policy objects
+func (policy *Policy) validateUniqueness() error {
+	index := uniquenessIndex{}
+	for _, typeInfo := range PolicyTypes {
+		for _, obj := range policy.GetObjectsByKind(typeInfo.Kind) {
+			index.add(obj)
+		}
+	}
+
+	conflicts := index.conflicts()
+	if len(conflicts) > 0 {
+		return &UniquenessError{Conflicts: conflicts}
+	}
+
+	return nil
+}
@@ -31,6 +31,17 @@ func (plugin *noOpPlugin) Validate() error {
 	return nil
 }
 
+// Constraints returns the same limits as Kubernetes has, so that noop mode can surface them before anything is
+// deployed to the real clusters
+func (*noOpPlugin) Constraints() plugin.ClusterConstraints {
+	return plugin.KubernetesConstraints
+}
+
+// Capabilities returns all capabilities, as noop plugin doesn't touch clusters and could pretend to support anything
+func (*noOpPlugin) Capabilities() plugin.ClusterCapabilities {
+	return plugin.NewClusterCapabilities(plugin.CapabilityPreview, plugin.CapabilityDryRun, plugin.CapabilityFinalizers)
+}
+
 func (plugin *noOpPlugin) Cleanup() error {
 	return nil
 }
@@ -1,6 +1,9 @@
 package plugin
 
 import (
+	"sort"
+	"strings"
+
 	"github.com/Aptomi/aptomi/pkg/config"
 	"github.com/Aptomi/aptomi/pkg/event"
 	"github.com/Aptomi/aptomi/pkg/lang"
@@ -27,6 +30,77 @@ type ClusterPlugin interface {
 	Base
 
 	Validate() error
+	Constraints() ClusterConstraints
+	Capabilities() ClusterCapabilities
+}
+
+// Capability is an optional feature, which cluster plugin may or may not support. API checks that plugins of all
+// affected clusters support a feature before invoking it
+type Capability string
+
+const (
+	// CapabilityPreview means that code plugins are able to render resources of component instances, which haven't
+	// been deployed yet (see ParamPreview)
+	CapabilityPreview Capability = "preview"
+
+	// CapabilityDryRun means that cluster is able to validate changes without applying them
+	CapabilityDryRun Capability = "dry-run"
+
+	// CapabilityFinalizers means that cluster blocks deletion of resources until their cleanup is done
+	CapabilityFinalizers Capability = "finalizers"
+)
+
+// ClusterCapabilities is a set of optional features supported by cluster plugin
+type ClusterCapabilities map[Capability]bool
+
+// NewClusterCapabilities creates a set of given capabilities
+func NewClusterCapabilities(capabilities ...Capability) ClusterCapabilities {
+	result := make(ClusterCapabilities)
+	for _, capability := range capabilities {
+		result[capability] = true
+	}
+	return result
+}
+
+// Has returns true if a given capability is supported
+func (capabilities ClusterCapabilities) Has(capability Capability) bool {
+	return capabilities[capability]
+}
+
+// String returns a sorted, comma-separated list of supported capabilities
+func (capabilities ClusterCapabilities) String() string {
+	result := []string{}
+	for capability, supported := range capabilities {
+		if supported {
+			result = append(result, string(capability))
+		}
+	}
+	if len(result) == 0 {
+		return "none"
+	}
+	sort.Strings(result)
+	return strings.Join(result, ", ")
+}
+
+// ClusterConstraints defines limits which names and values generated during policy resolution must satisfy in order
+// to be accepted by the cluster. Zero value means that there is no limit
+type ClusterConstraints struct {
+	// MaxNameLength is the max length of component instance name (e.g. Helm release name)
+	MaxNameLength int
+
+	// MaxNamespaceLength is the max length of the namespace where component instance gets deployed
+	MaxNamespaceLength int
+
+	// MaxLabelValueLength is the max length of label values passed to the code
+	MaxLabelValueLength int
+}
+
+// KubernetesConstraints are the limits imposed by Kubernetes. Instance names end up in names of services (DNS-1035
+// labels), namespaces are DNS-1123 labels, and label values are limited to 63 characters as well
+var KubernetesConstraints = ClusterConstraints{
+	MaxNameLength:       63,
+	MaxNamespaceLength:  63,
+	MaxLabelValueLength: 63,
 }
 
 // ClusterPluginConstructor represents constructor for the cluster plugin
@@ -45,6 +119,10 @@ type CodePlugin interface {
 	Status(*CodePluginInvocationParams) (bool, error)
 }
 
+// ParamPreview is a plugin-specific parameter, which is set to "true" when code plugin is asked to render resources of
+// a component instance, which may not be deployed yet. It's only passed to plugins for clusters with CapabilityPreview
+const ParamPreview = "preview"
+
 // ParamTargetSuffix it's a plugin-specific parameter, which is additionally specifies where the code should reside (in case of k8s and Helm, it's a string consisting of k8s namespace)
 const ParamTargetSuffix = "target-suffix"
 
@@ -40,6 +40,17 @@ func (p *Plugin) Validate() error {
 	return err
 }
 
+// Constraints returns limits imposed by Kubernetes on names and label values
+func (p *Plugin) Constraints() plugin.ClusterConstraints {
+	return plugin.KubernetesConstraints
+}
+
+// Capabilities returns optional features supported by Kubernetes. Resources get deleted by Kubernetes only after
+// their finalizers are done, while preview and dry-run aren't supported by code plugins yet
+func (p *Plugin) Capabilities() plugin.ClusterCapabilities {
+	return plugin.NewClusterCapabilities(plugin.CapabilityFinalizers)
+}
+
 // Init parses Kubernetes cluster config and retrieves external address for Kubernetes cluster
 func (p *Plugin) Init() error {
 	return p.once.Do(func() error {
@@ -5,3 +5,12 @@ type Displayable interface {
 	GetDefaultColumns() []string
 	AsColumns() map[string]string
 }
+
+// DisplayableList represents object that holds a list of Displayable objects (e.g. API list wrappers), so that it
+// could be rendered as a table
+type DisplayableList interface {
+	// GetDefaultColumns returns default set of columns of objects in the list, so the header could be rendered even
+	// if the list is empty
+	GetDefaultColumns() []string
+	AsDisplayableList() []Displayable
+}
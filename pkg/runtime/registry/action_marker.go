@@ -0,0 +1,94 @@
+package registry
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/apply/action"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+)
+
+// GetActionMarkers returns markers of all actions completed while applying revision with a given generation
+func (reg *defaultRegistry) GetActionMarkers(revisionGen runtime.Generation) ([]*engine.ActionMarker, error) {
+	var markers []*engine.ActionMarker
+	prefix := runtime.KeyFromParts(runtime.SystemNS, engine.TypeActionMarker.Kind, engine.GetActionMarkersPrefix(revisionGen))
+	err := reg.store.Find(engine.TypeActionMarker.Kind, &markers, store.WithKeyPrefix(prefix))
+	if err != nil {
+		return nil, fmt.Errorf("error while getting action markers of revision %s: %s", revisionGen, err)
+	}
+
+	return markers, nil
+}
+
+// NewCompletionMarkers creates completion markers for applying a given revision. Only actions completed by the
+// revision itself are considered completed, as actual state could have changed since other revisions were applied
+func (reg *defaultRegistry) NewCompletionMarkers(revision *engine.Revision) (action.CompletionMarkers, error) {
+	markers := &RevisionCompletionMarkers{
+		registry:    reg,
+		revisionGen: revision.GetGeneration(),
+		completed:   make(map[string]*engine.ActionMarker),
+	}
+
+	revisionMarkers, err := reg.GetActionMarkers(revision.GetGeneration())
+	if err != nil {
+		return nil, err
+	}
+	for _, marker := range revisionMarkers {
+		markers.completed[marker.ActionKey] = marker
+	}
+
+	return markers, nil
+}
+
+// DeleteActionMarkers removes markers of all actions completed while applying revision with a given generation. Once
+// revision gets completed, all changes made by its actions are recorded in actual state and markers are not needed
+func (reg *defaultRegistry) DeleteActionMarkers(revisionGen runtime.Generation) error {
+	markers, err := reg.GetActionMarkers(revisionGen)
+	if err != nil {
+		return err
+	}
+
+	for _, marker := range markers {
+		err = reg.store.Delete(engine.TypeActionMarker.Kind, runtime.KeyForStorable(marker))
+		if err != nil {
+			return fmt.Errorf("error while deleting action marker %s: %s", marker.GetName(), err)
+		}
+	}
+
+	return nil
+}
+
+// RevisionCompletionMarkers is a thread-safe implementation of CompletionMarkers, which records completed actions
+// in the registry as markers of a specific revision
+type RevisionCompletionMarkers struct {
+	registry    *defaultRegistry
+	revisionGen runtime.Generation
+	completed   map[string]*engine.ActionMarker
+	mutex       sync.RWMutex
+}
+
+// IsCompleted returns true if action has been completed with the same inputs
+func (markers *RevisionCompletionMarkers) IsCompleted(act action.Interface, inputHash string) bool {
+	markers.mutex.RLock()
+	defer markers.mutex.RUnlock()
+
+	marker, exist := markers.completed[act.GetName()]
+	return exist && marker.Result == engine.ActionMarkerResultSuccess && marker.InputHash == inputHash
+}
+
+// MarkCompleted saves the marker of completed action in the registry
+func (markers *RevisionCompletionMarkers) MarkCompleted(act action.Interface, inputHash string) error {
+	marker := engine.NewActionMarker(markers.revisionGen, act.GetName(), inputHash)
+	_, err := markers.registry.store.Save(marker)
+	if err != nil {
+		return fmt.Errorf("error while saving action marker %s: %s", marker.GetName(), err)
+	}
+
+	markers.mutex.Lock()
+	markers.completed[marker.ActionKey] = marker
+	markers.mutex.Unlock()
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/engine/apply/action"
+	"github.com/Aptomi/aptomi/pkg/engine/apply/action/component"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompletionMarkers(t *testing.T) {
+	reg := New(memory.New(runtime.NewTypes().Append(Types...), store.NewYAMLCodec()))
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	first, err := reg.NewRevision(1, resolve.NewPolicyResolution(), false)
+	if !assert.NoError(t, err, "Revision should be created") {
+		t.FailNow()
+	}
+	second, err := reg.NewRevision(1, resolve.NewPolicyResolution(), false)
+	if !assert.NoError(t, err, "Revision should be created") {
+		t.FailNow()
+	}
+
+	act := component.NewAttachClaimAction("component", "claim", 0)
+	inputHash := action.InputHash(act)
+
+	markers, err := reg.NewCompletionMarkers(first)
+	if !assert.NoError(t, err, "Completion markers should be loaded") {
+		t.FailNow()
+	}
+	assert.NoError(t, markers.MarkCompleted(act, inputHash), "Action should be marked as completed")
+
+	// markers are loaded back for the same revision only
+	markers, err = reg.NewCompletionMarkers(first)
+	if assert.NoError(t, err, "Completion markers should be loaded") {
+		assert.True(t, markers.IsCompleted(act, inputHash), "Action should be completed by the same revision")
+		assert.False(t, markers.IsCompleted(act, "changed"), "Action with changed inputs should not be completed")
+	}
+	markers, err = reg.NewCompletionMarkers(second)
+	if assert.NoError(t, err, "Completion markers should be loaded") {
+		assert.False(t, markers.IsCompleted(act, inputHash), "Action completed by another revision should not be completed")
+	}
+
+	// markers get deleted once revision is completed
+	assert.NoError(t, reg.DeleteActionMarkers(first.GetGeneration()), "Action markers should be deleted")
+	remaining, err := reg.GetActionMarkers(first.GetGeneration())
+	assert.NoError(t, err, "Action markers should be loaded")
+	assert.Empty(t, remaining, "Action markers should be deleted")
+}
@@ -0,0 +1,27 @@
+package registry
+
+import (
+	"io"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+)
+
+// Export writes all objects from the registry (including all generations of policy objects, policies and revisions)
+// to w using a given codec
+func (reg *defaultRegistry) Export(w io.Writer, codec store.Codec) (*store.BackupResult, error) {
+	// policy shouldn't change while exporting, so exported policy objects match exported policies
+	reg.policyChangeLock.Lock()
+	defer reg.policyChangeLock.Unlock()
+
+	return store.Export(reg.store, runtime.NewTypes().Append(Types...), codec, w)
+}
+
+// Import reads objects written by Export using a given codec and saves them into the registry with the same
+// generations. Import into non-empty registry fails unless force is set
+func (reg *defaultRegistry) Import(r io.Reader, codec store.Codec, force bool) (*store.BackupResult, error) {
+	reg.policyChangeLock.Lock()
+	defer reg.policyChangeLock.Unlock()
+
+	return store.Import(reg.store, runtime.NewTypes().Append(Types...), codec, r, force)
+}
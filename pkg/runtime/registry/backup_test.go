@@ -0,0 +1,75 @@
+package registry
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportImport(t *testing.T) {
+	reg := New(memory.New(runtime.NewTypes().Append(Types...), store.NewYAMLCodec()))
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	claim := b.AddClaim(b.AddUser(), service)
+	_, _, err := reg.UpdatePolicy([]lang.Base{cluster, bundle, service, claim}, "admin")
+	if !assert.NoError(t, err, "Policy should be updated") {
+		t.FailNow()
+	}
+	claim.Labels["updated"] = "true"
+	_, latest, err := reg.UpdatePolicy([]lang.Base{claim}, "admin")
+	if !assert.NoError(t, err, "Policy should be updated") {
+		t.FailNow()
+	}
+
+	codec := store.NewGobCodec(runtime.NewTypes().Append(Types...))
+	backup := &bytes.Buffer{}
+	exported, err := reg.Export(backup, codec)
+	if !assert.NoError(t, err, "Registry should be exported") {
+		t.FailNow()
+	}
+
+	restored := New(memory.New(runtime.NewTypes().Append(Types...), store.NewYAMLCodec()))
+	imported, err := restored.Import(bytes.NewReader(backup.Bytes()), codec, false)
+	if !assert.NoError(t, err, "Registry should be imported") {
+		t.FailNow()
+	}
+	assert.Equal(t, exported.Objects, imported.Objects, "All exported objects should be imported")
+
+	for gen := runtime.FirstGen; gen <= latest.GetGeneration(); gen++ {
+		expected, errExpected := reg.GetPolicyData(gen)
+		actual, errActual := restored.GetPolicyData(gen)
+		if assert.NoError(t, errExpected) && assert.NoError(t, errActual) && assert.NotNil(t, actual, "Policy gen %d should be restored", gen) {
+			assert.Equal(t, expected.Objects, actual.Objects, "Policy gen %d should reference the same object generations", gen)
+		}
+	}
+
+	policy, _, err := restored.GetPolicy(runtime.LastOrEmptyGen)
+	if assert.NoError(t, err, "Restored policy should be loaded") {
+		claims := policy.GetObjectsByKind(lang.TypeClaim.Kind)
+		if assert.Len(t, claims, 1) {
+			assert.Equal(t, "true", claims[0].(*lang.Claim).Labels["updated"], "The last claim generation should be restored")
+		}
+	}
+
+	revision, err := restored.GetRevision(runtime.LastOrEmptyGen)
+	if assert.NoError(t, err) && assert.NotNil(t, revision, "Revision should be restored") {
+		_, err = restored.GetDesiredState(revision)
+		assert.NoError(t, err, "Desired state of restored revision should be loaded")
+	}
+
+	_, err = reg.Import(bytes.NewReader(backup.Bytes()), codec, false)
+	assert.True(t, store.IsNotEmpty(err), "Import into non-empty registry should fail unless forced")
+}
@@ -0,0 +1,74 @@
+package registry
+
+import (
+	"fmt"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+// CompactPolicyObject removes old generations of a policy object, keeping the last keepLast generations and all
+// generations referenced by policies for which revisions exist. It returns the list of removed generations
+func (reg *defaultRegistry) CompactPolicyObject(ns string, kind runtime.Kind, name string, keepLast int) ([]runtime.Generation, error) {
+	// policy shouldn't change while compacting, so we don't remove generation referenced by a new policy
+	reg.policyChangeLock.Lock()
+	defer reg.policyChangeLock.Unlock()
+
+	referenced, err := reg.getReferencedGens(ns, kind, name)
+	if err != nil {
+		return nil, err
+	}
+
+	key := runtime.KeyFromParts(ns, kind, name)
+	removed, err := reg.store.Compact(kind, key, keepLast, func(gen runtime.Generation) bool {
+		return referenced[gen]
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error while compacting %s: %s", key, err)
+	}
+
+	return removed, nil
+}
+
+// getReferencedGens returns generations of a policy object, which are referenced by the latest policy or by policies
+// for which revisions exist
+func (reg *defaultRegistry) getReferencedGens(ns string, kind runtime.Kind, name string) (map[runtime.Generation]bool, error) {
+	lastRevision, err := reg.GetRevision(runtime.LastOrEmptyGen)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting last revision: %s", err)
+	}
+
+	policyGens := map[runtime.Generation]bool{runtime.LastOrEmptyGen: true}
+	if lastRevision != nil {
+		for gen := runtime.FirstGen; gen <= lastRevision.GetGeneration(); gen++ {
+			revision, errRevision := reg.GetRevision(gen)
+			if errRevision != nil {
+				return nil, fmt.Errorf("error while getting revision %s: %s", gen, errRevision)
+			}
+			if revision != nil {
+				policyGens[revision.PolicyGen] = true
+			}
+		}
+	}
+
+	result := make(map[runtime.Generation]bool)
+	for policyGen := range policyGens {
+		policyData, errPolicy := reg.GetPolicyData(policyGen)
+		if errPolicy != nil {
+			return nil, fmt.Errorf("error while getting policy %s: %s", policyGen, errPolicy)
+		}
+		if gen, ok := getObjectGen(policyData, ns, kind, name); ok {
+			result[gen] = true
+		}
+	}
+
+	return result, nil
+}
+
+func getObjectGen(policyData *engine.PolicyData, ns string, kind runtime.Kind, name string) (runtime.Generation, bool) {
+	if policyData == nil || policyData.Objects == nil {
+		return runtime.LastOrEmptyGen, false
+	}
+	gen, ok := policyData.Objects[ns][kind][name]
+	return gen, ok
+}
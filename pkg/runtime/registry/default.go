@@ -1,6 +1,7 @@
 package registry
 
 import (
+	"context"
 	"sync"
 
 	"github.com/Aptomi/aptomi/pkg/runtime/store"
@@ -9,13 +10,23 @@ import (
 // defaultRegistry is the generic registry implementation that is the glue layer for saving
 // different engine objects into the object registry
 type defaultRegistry struct {
-	policyChangeLock sync.Mutex
+	policyChangeLock *sync.Mutex
 	store            store.Interface
 }
 
 // New returns default implementation of generic registry
 func New(store store.Interface) Interface {
 	return &defaultRegistry{
-		store: store,
+		policyChangeLock: &sync.Mutex{},
+		store:            store,
+	}
+}
+
+// WithContext returns registry sharing the same store, which reports tracing spans for all store operations as
+// children of the span from a given context
+func (reg *defaultRegistry) WithContext(ctx context.Context) Interface {
+	return &defaultRegistry{
+		policyChangeLock: reg.policyChangeLock,
+		store:            store.WithTracing(ctx, reg.store),
 	}
 }
@@ -0,0 +1,120 @@
+package registry
+
+import (
+	"fmt"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+)
+
+// DesiredStateNotFoundError is returned when there is no desired state stored for the revision and all preceding
+// ones (e.g. it was never saved or it was removed by garbage collection)
+type DesiredStateNotFoundError struct {
+	RevisionGen runtime.Generation
+}
+
+func (err *DesiredStateNotFoundError) Error() string {
+	return fmt.Sprintf("desired state for revision %s not found", err.RevisionGen)
+}
+
+// IsDesiredStateNotFound returns true if a given error is DesiredStateNotFoundError
+func IsDesiredStateNotFound(err error) bool {
+	_, ok := err.(*DesiredStateNotFoundError)
+	return ok
+}
+
+// GetDesiredStateInstanceKeys returns sorted keys of all component instances in desired state associated with the
+// revision. The same as GetDesiredState, revisions without desired state of their own get it from the closest
+// preceding revision and DesiredStateNotFoundError is returned if there is none
+func (reg *defaultRegistry) GetDesiredStateInstanceKeys(revision *engine.Revision) ([]string, error) {
+	index, desiredState, err := reg.findDesiredStateIndex(revision)
+	if err != nil {
+		return nil, err
+	}
+	if index != nil {
+		return index.Keys, nil
+	}
+
+	// desired state saved before instances became addressable, so it has to be loaded completely
+	if desiredState != nil {
+		return engine.NewDesiredStateIndex(revision, &desiredState.Resolution).Keys, nil
+	}
+
+	return nil, &DesiredStateNotFoundError{RevisionGen: revision.GetGeneration()}
+}
+
+// GetDesiredStateInstance returns a single component instance from desired state associated with the revision or nil
+// if there is no such instance. DesiredStateNotFoundError is returned if there is no desired state for the revision
+func (reg *defaultRegistry) GetDesiredStateInstance(revision *engine.Revision, key string) (*resolve.ComponentInstance, error) {
+	index, desiredState, err := reg.findDesiredStateIndex(revision)
+	if err != nil {
+		return nil, err
+	}
+	if index != nil {
+		var dsInstance *engine.DesiredStateInstance
+		err = reg.store.Find(engine.TypeDesiredStateInstance.Kind, &dsInstance, store.WithKey(runtime.KeyFromParts(runtime.SystemNS, engine.TypeDesiredStateInstance.Kind, engine.GetDesiredStateInstanceName(index.RevisionGen, key))))
+		if err != nil {
+			return nil, fmt.Errorf("error while getting desired state instance %s for revision %d: %s", key, index.RevisionGen, err)
+		}
+		if dsInstance == nil {
+			return nil, nil
+		}
+		return dsInstance.Instance, nil
+	}
+
+	// desired state saved before instances became addressable, so it has to be loaded completely
+	if desiredState != nil {
+		return desiredState.Resolution.ComponentInstanceMap[key], nil
+	}
+
+	return nil, &DesiredStateNotFoundError{RevisionGen: revision.GetGeneration()}
+}
+
+// loadDesiredState loads all component instances listed in a given desired state index
+func (reg *defaultRegistry) loadDesiredState(index *engine.DesiredStateIndex) (*resolve.PolicyResolution, error) {
+	var dsInstances []*engine.DesiredStateInstance
+	err := reg.store.Find(engine.TypeDesiredStateInstance.Kind, &dsInstances, store.WithKeyPrefix(runtime.KeyFromParts(runtime.SystemNS, engine.TypeDesiredStateInstance.Kind, engine.GetDesiredStateInstanceName(index.RevisionGen, ""))))
+	if err != nil {
+		return nil, fmt.Errorf("error while getting desired state instances for revision %d: %s", index.RevisionGen, err)
+	}
+
+	resolution := resolve.NewPolicyResolution()
+	for _, dsInstance := range dsInstances {
+		resolution.ComponentInstanceMap[dsInstance.Instance.GetKey()] = dsInstance.Instance
+	}
+	for _, key := range index.Keys {
+		if _, exist := resolution.ComponentInstanceMap[key]; !exist {
+			return nil, fmt.Errorf("desired state instance %s for revision %d not found", key, index.RevisionGen)
+		}
+	}
+
+	return resolution, nil
+}
+
+// findDesiredStateIndex looks for the index of desired state associated with the revision, going back to preceding
+// revisions if needed. If desired state has been saved without index, desired state itself gets returned instead
+func (reg *defaultRegistry) findDesiredStateIndex(revision *engine.Revision) (*engine.DesiredStateIndex, *engine.DesiredState, error) {
+	for gen := revision.GetGeneration(); gen >= runtime.FirstGen; gen-- {
+		var index *engine.DesiredStateIndex
+		err := reg.store.Find(engine.TypeDesiredStateIndex.Kind, &index, store.WithKey(runtime.KeyFromParts(runtime.SystemNS, engine.TypeDesiredStateIndex.Kind, engine.GetDesiredStateIndexName(gen))))
+		if err != nil {
+			return nil, nil, fmt.Errorf("error while getting desired state index for revision %d: %s", gen, err)
+		}
+		if index != nil {
+			return index, nil, nil
+		}
+
+		var desiredState *engine.DesiredState
+		err = reg.store.Find(engine.TypeDesiredState.Kind, &desiredState, store.WithKey(runtime.KeyFromParts(runtime.SystemNS, engine.TypeDesiredState.Kind, engine.GetDesiredStateName(gen))))
+		if err != nil {
+			return nil, nil, fmt.Errorf("error while getting desired state for revision %d: %s", gen, err)
+		}
+		if desiredState != nil {
+			return nil, desiredState, nil
+		}
+	}
+
+	return nil, nil, nil
+}
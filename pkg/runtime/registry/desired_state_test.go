@@ -0,0 +1,89 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDesiredStateNotFound(t *testing.T) {
+	reg := New(memory.New(runtime.NewTypes().Append(Types...), store.NewYAMLCodec()))
+
+	// revision still being resolved without any preceding revisions has no desired state at all
+	resolving, err := reg.NewResolvingRevision(runtime.FirstGen)
+	if !assert.NoError(t, err, "Revision should be created") {
+		t.FailNow()
+	}
+
+	_, err = reg.GetDesiredState(resolving)
+	assert.True(t, IsDesiredStateNotFound(err), "Missing desired state should be reported: %v", err)
+	_, err = reg.GetDesiredStateInstanceKeys(resolving)
+	assert.True(t, IsDesiredStateNotFound(err), "Missing desired state should be reported: %v", err)
+	_, err = reg.GetDesiredStateInstance(resolving, "key")
+	assert.True(t, IsDesiredStateNotFound(err), "Missing desired state should be reported: %v", err)
+
+	// once desired state is saved, it's used by the following revision as well
+	assert.NoError(t, reg.SaveDesiredState(resolving, resolve.NewPolicyResolution()), "Desired state should be saved")
+	next, err := reg.NewResolvingRevision(runtime.FirstGen)
+	if !assert.NoError(t, err, "Revision should be created") {
+		t.FailNow()
+	}
+	desiredState, err := reg.GetDesiredState(next)
+	assert.NoError(t, err, "Desired state of the preceding revision should be returned")
+	assert.NotNil(t, desiredState, "Desired state of the preceding revision should be returned")
+}
+
+func TestSaveDesiredState(t *testing.T) {
+	db := memory.New(runtime.NewTypes().Append(Types...), store.NewYAMLCodec())
+	reg := New(db)
+
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	b.AddRule(b.CriteriaTrue(), b.RuleActions(lang.NewLabelOperationsSetSingleLabel(lang.LabelTarget, cluster.Name)))
+	b.AddClaim(b.AddUser(), service)
+	resolution, err := resolve.NewPolicyResolver(b.Policy(), b.External(), event.NewLog(logrus.WarnLevel, "test")).ResolveAllClaims(context.Background())
+	if !assert.NoError(t, err, "Policy should be resolved without errors") || !assert.NotEmpty(t, resolution.ComponentInstanceMap, "Desired state should have instances") {
+		t.FailNow()
+	}
+
+	revision, err := reg.NewRevision(runtime.FirstGen, resolution, false)
+	if !assert.NoError(t, err, "Revision should be created") {
+		t.FailNow()
+	}
+
+	// desired state is only stored as separate instances along with the index
+	desiredStateKeys, err := db.Keys(engine.TypeDesiredState.Kind)
+	assert.NoError(t, err)
+	assert.Empty(t, desiredStateKeys, "Desired state shouldn't be saved as a single object")
+	instanceKeys, err := db.Keys(engine.TypeDesiredStateInstance.Kind)
+	assert.NoError(t, err)
+	assert.Len(t, instanceKeys, len(resolution.ComponentInstanceMap), "Every instance should be saved separately")
+
+	desiredState, err := reg.GetDesiredState(revision)
+	if assert.NoError(t, err, "Desired state should be loaded") {
+		assert.Len(t, desiredState.ComponentInstanceMap, len(resolution.ComponentInstanceMap), "Desired state should be assembled from instances")
+		for key, instance := range resolution.ComponentInstanceMap {
+			if assert.Contains(t, desiredState.ComponentInstanceMap, key, "Instance should be loaded") {
+				assert.Equal(t, instance.Metadata.Key, desiredState.ComponentInstanceMap[key].Metadata.Key, "Instance %s should be loaded", key)
+			}
+		}
+	}
+
+	// desired state with missing instance is reported instead of being returned partially
+	assert.NoError(t, db.Delete(engine.TypeDesiredStateInstance.Kind, instanceKeys[0]), "Instance should be deleted")
+	_, err = reg.GetDesiredState(revision)
+	assert.Error(t, err, "Incomplete desired state should be reported")
+}
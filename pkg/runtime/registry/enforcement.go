@@ -0,0 +1,69 @@
+package registry
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+)
+
+// NewEnforcement creates a new pending Enforcement for a given revision and saves it to the database
+func (reg *defaultRegistry) NewEnforcement(revisionGen runtime.Generation, createdAt time.Time) (*engine.Enforcement, error) {
+	currEnforcement, err := reg.GetEnforcement(runtime.LastOrEmptyGen)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting last enforcement: %s", err)
+	}
+
+	var gen runtime.Generation
+	if currEnforcement == nil {
+		gen = runtime.FirstGen
+	} else {
+		gen = currEnforcement.GetGeneration().Next()
+	}
+
+	enforcement := engine.NewEnforcement(gen, revisionGen, createdAt)
+	_, err = reg.store.Save(enforcement)
+	if err != nil {
+		return nil, fmt.Errorf("error while saving new enforcement: %s", err)
+	}
+
+	return enforcement, nil
+}
+
+// GetEnforcement returns Enforcement for specified generation
+func (reg *defaultRegistry) GetEnforcement(gen runtime.Generation) (*engine.Enforcement, error) {
+	var enforcement *engine.Enforcement
+	err := reg.store.Find(engine.TypeEnforcement.Kind, &enforcement, store.WithKey(engine.EnforcementKey), store.WithGen(gen))
+	if store.IsGenNotFound(err) {
+		// requested generation doesn't exist or was compacted
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return enforcement, nil
+}
+
+// UpdateEnforcement updates specified Enforcement in the registry without creating new generation
+func (reg *defaultRegistry) UpdateEnforcement(enforcement *engine.Enforcement) error {
+	_, err := reg.store.Save(enforcement, store.WithReplaceOrForceGen())
+	if err != nil {
+		return fmt.Errorf("error while updating enforcement: %s", err)
+	}
+
+	return nil
+}
+
+// GetUnfinishedEnforcements returns all enforcements, which are still pending or running, in chronological order
+func (reg *defaultRegistry) GetUnfinishedEnforcements() ([]*engine.Enforcement, error) {
+	var enforcements []*engine.Enforcement
+	err := reg.store.Find(engine.TypeEnforcement.Kind, &enforcements, store.WithKey(engine.EnforcementKey), store.WithWhereEq("Status", engine.EnforcementStatusPending, engine.EnforcementStatusRunning))
+	if err != nil {
+		return nil, err
+	}
+
+	return enforcements, nil
+}
@@ -0,0 +1,50 @@
+package registry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnforcements(t *testing.T) {
+	reg := New(memory.New(runtime.NewTypes().Append(Types...), store.NewYAMLCodec()))
+
+	createdAt := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	first, err := reg.NewEnforcement(3, createdAt)
+	if !assert.NoError(t, err, "Enforcement should be created") {
+		t.FailNow()
+	}
+	second, err := reg.NewEnforcement(4, createdAt)
+	if !assert.NoError(t, err, "Enforcement should be created") {
+		t.FailNow()
+	}
+	assert.EqualValues(t, runtime.FirstGen, first.GetGeneration(), "First enforcement should get the first generation")
+	assert.Equal(t, first.GetGeneration().Next(), second.GetGeneration(), "Every enforcement should get a new generation")
+	assert.Equal(t, engine.EnforcementStatusPending, second.Status, "New enforcement should be pending")
+
+	// finished enforcements shouldn't be returned as unfinished
+	first.Status = engine.EnforcementStatusSucceeded
+	if !assert.NoError(t, reg.UpdateEnforcement(first), "Enforcement should be updated") {
+		t.FailNow()
+	}
+	unfinished, err := reg.GetUnfinishedEnforcements()
+	if assert.NoError(t, err, "Unfinished enforcements should be loaded") && assert.Len(t, unfinished, 1, "Only one enforcement should be unfinished") {
+		assert.Equal(t, second.GetGeneration(), unfinished[0].GetGeneration(), "Pending enforcement should be unfinished")
+	}
+
+	loaded, err := reg.GetEnforcement(first.GetGeneration())
+	if assert.NoError(t, err, "Enforcement should be loaded") && assert.NotNil(t, loaded, "Enforcement should exist") {
+		assert.Equal(t, engine.EnforcementStatusSucceeded, loaded.Status, "Enforcement should be updated in place")
+		assert.EqualValues(t, 3, loaded.RevisionGen, "Enforcement should keep its revision")
+		assert.True(t, createdAt.Equal(loaded.CreatedAt), "Enforcement should keep its creation time")
+	}
+
+	missing, err := reg.GetEnforcement(42)
+	assert.NoError(t, err, "Missing enforcement shouldn't be an error")
+	assert.Nil(t, missing, "Missing enforcement should not be found")
+}
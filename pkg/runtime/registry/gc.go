@@ -0,0 +1,413 @@
+package registry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+)
+
+const (
+	// DefaultGCKeepLast is the default number of last generations garbage collection keeps for every policy object
+	DefaultGCKeepLast = 10
+
+	// DefaultGCKeepLastHistory is the default number of last generations garbage collection keeps for policy, revision
+	// and enforcement, as they represent the history of policy changes and enforcement runs
+	DefaultGCKeepLastHistory = 100
+)
+
+// GCRetention defines how many last generations of versioned objects garbage collection keeps
+type GCRetention struct {
+	// KeepLast is the number of last generations kept for every policy object. If not set, DefaultGCKeepLast is used
+	KeepLast int
+
+	// KeepLastHistory is the number of last generations kept for policy, revision and enforcement. If not set,
+	// DefaultGCKeepLastHistory is used
+	KeepLastHistory int
+
+	// KeepLastByKind overrides the number of last generations kept for objects of specific kinds
+	KeepLastByKind map[runtime.Kind]int
+}
+
+// GetKeepLast returns the number of last generations kept for objects of a given kind. At least the last generation
+// is always kept
+func (retention *GCRetention) GetKeepLast(kind runtime.Kind) int {
+	keepLast := 0
+	if retention != nil {
+		if value, ok := retention.KeepLastByKind[kind]; ok {
+			keepLast = value
+		} else if isHistoryKind(kind) {
+			keepLast = retention.KeepLastHistory
+		} else {
+			keepLast = retention.KeepLast
+		}
+	}
+
+	if keepLast <= 0 {
+		if isHistoryKind(kind) {
+			return DefaultGCKeepLastHistory
+		}
+		return DefaultGCKeepLast
+	}
+
+	return keepLast
+}
+
+// isHistoryKind returns true if objects of a given kind represent the history of policy changes and enforcement runs
+func isHistoryKind(kind runtime.Kind) bool {
+	return kind == engine.TypePolicyData.Kind || kind == engine.TypeRevision.Kind || kind == engine.TypeEnforcement.Kind
+}
+
+// GCResult represents result of garbage collection
+type GCResult struct {
+	// Removed is the number of removed object keys (generations) by kind
+	Removed map[runtime.Kind]int
+}
+
+// Total returns the total number of removed object keys
+func (result *GCResult) Total() int {
+	total := 0
+	for _, removed := range result.Removed {
+		total += removed
+	}
+	return total
+}
+
+// CollectGarbage removes old generations of revisions, policies and policy objects along with their index entries
+// according to a given retention. The last generation of every object is never removed, as well as revisions, which
+// haven't been processed yet or hold the desired state in effect, and generations of policies and policy objects
+// referenced by the remaining revisions. Objects belonging to removed revisions (desired state, resolution log,
+// action markers and finished enforcements) are removed along with them. Policy apply jobs, which have expired by a
+// given time, are removed as well
+func (reg *defaultRegistry) CollectGarbage(retention *GCRetention, now time.Time) (*GCResult, error) {
+	// policy shouldn't change while collecting garbage, so we don't remove generations referenced by a new policy
+	reg.policyChangeLock.Lock()
+	defer reg.policyChangeLock.Unlock()
+
+	result := &GCResult{Removed: make(map[runtime.Kind]int)}
+
+	policyGens, err := reg.collectRevisions(retention.GetKeepLast(engine.TypeRevision.Kind), retention.GetKeepLast(engine.TypeEnforcement.Kind), result)
+	if err != nil {
+		return result, err
+	}
+
+	referenced, err := reg.collectPolicies(retention.GetKeepLast(engine.TypePolicyData.Kind), policyGens, result)
+	if err != nil {
+		return result, err
+	}
+
+	for _, info := range lang.PolicyTypes {
+		if !info.Versioned {
+			continue
+		}
+		keepLast := retention.GetKeepLast(info.Kind)
+		for key, gens := range referenced[info.Kind] {
+			removed, errCompact := reg.store.Compact(info.Kind, key, keepLast, func(gen runtime.Generation) bool {
+				return gens[gen]
+			})
+			if errCompact != nil {
+				return result, fmt.Errorf("error while compacting %s: %s", key, errCompact)
+			}
+			result.Removed[info.Kind] += len(removed)
+		}
+	}
+
+	removedJobs, err := reg.DeleteExpiredPolicyApplyJobs(now)
+	if err != nil {
+		return result, fmt.Errorf("error while deleting expired policy apply jobs: %s", err)
+	}
+	result.Removed[engine.TypePolicyApplyJob.Kind] += removedJobs
+
+	return result, nil
+}
+
+// collectRevisions removes old revisions along with the objects belonging to them and returns generations of policies
+// referenced by the remaining ones
+func (reg *defaultRegistry) collectRevisions(keepLast int, keepLastEnforcements int, result *GCResult) (map[runtime.Generation]bool, error) {
+	policyGens := map[runtime.Generation]bool{runtime.LastOrEmptyGen: true}
+
+	lastRevision, err := reg.GetRevision(runtime.LastOrEmptyGen)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting last revision: %s", err)
+	}
+	if lastRevision == nil {
+		return policyGens, nil
+	}
+
+	var revisions []*engine.Revision
+	err = reg.store.Find(engine.TypeRevision.Kind, &revisions, store.WithKey(engine.RevisionKey), store.WithGenRange(runtime.FirstGen, lastRevision.GetGeneration()))
+	if err != nil {
+		return nil, fmt.Errorf("error while getting revisions: %s", err)
+	}
+
+	// revisions, which haven't been processed yet, and the last completed one (holding desired state in effect) are
+	// always kept along with the last revision for the latest policy, which enforcer may retry
+	_, policyGen, err := reg.GetPolicy(runtime.LastOrEmptyGen)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting latest policy: %s", err)
+	}
+	active := make(map[runtime.Generation]bool)
+	lastCompleted, lastForPolicy := runtime.LastOrEmptyGen, runtime.LastOrEmptyGen
+	for _, revision := range revisions {
+		if !revision.IsFinished() {
+			active[revision.GetGeneration()] = true
+		}
+		if revision.Status == engine.RevisionStatusCompleted {
+			lastCompleted = revision.GetGeneration()
+		}
+		if revision.PolicyGen == policyGen {
+			lastForPolicy = revision.GetGeneration()
+		}
+	}
+	active[lastCompleted] = true
+	active[lastForPolicy] = true
+
+	removed, err := reg.store.Compact(engine.TypeRevision.Kind, engine.RevisionKey, keepLast, func(gen runtime.Generation) bool {
+		return active[gen]
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error while compacting revisions: %s", err)
+	}
+	result.Removed[engine.TypeRevision.Kind] += len(removed)
+
+	removedGens := make(map[runtime.Generation]bool)
+	for _, gen := range removed {
+		removedGens[gen] = true
+	}
+	remainingGens := make(map[runtime.Generation]bool)
+	for _, revision := range revisions {
+		if !removedGens[revision.GetGeneration()] {
+			policyGens[revision.PolicyGen] = true
+			remainingGens[revision.GetGeneration()] = true
+		}
+	}
+
+	err = reg.collectRevisionObjects(revisions, removedGens, result)
+	if err != nil {
+		return nil, err
+	}
+
+	err = reg.collectEnforcements(keepLastEnforcements, lastRevision.GetGeneration(), remainingGens, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return policyGens, nil
+}
+
+// collectRevisionObjects removes desired state, resolution log and action markers of removed revisions. Revisions
+// without desired state of their own use desired state of the closest preceding revision, so it's kept as long as
+// such revisions remain
+func (reg *defaultRegistry) collectRevisionObjects(revisions []*engine.Revision, removedGens map[runtime.Generation]bool, result *GCResult) error {
+	if len(removedGens) == 0 {
+		return nil
+	}
+
+	desiredStateKeys, err := reg.store.Keys(engine.TypeDesiredState.Kind)
+	if err != nil {
+		return fmt.Errorf("error while getting desired state keys: %s", err)
+	}
+	indexKeys, err := reg.store.Keys(engine.TypeDesiredStateIndex.Kind)
+	if err != nil {
+		return fmt.Errorf("error while getting desired state index keys: %s", err)
+	}
+	instanceKeys, err := reg.store.Keys(engine.TypeDesiredStateInstance.Kind)
+	if err != nil {
+		return fmt.Errorf("error while getting desired state instance keys: %s", err)
+	}
+
+	hasDesiredState := make(map[runtime.Generation]bool)
+	for _, revision := range revisions {
+		gen := revision.GetGeneration()
+		hasDesiredState[gen] = containsKey(indexKeys, runtime.KeyFromParts(runtime.SystemNS, engine.TypeDesiredStateIndex.Kind, engine.GetDesiredStateIndexName(gen))) ||
+			containsKey(desiredStateKeys, runtime.KeyFromParts(runtime.SystemNS, engine.TypeDesiredState.Kind, engine.GetDesiredStateName(gen)))
+	}
+
+	neededDesiredState := make(map[runtime.Generation]bool)
+	withDesiredState := runtime.LastOrEmptyGen
+	for _, revision := range revisions {
+		gen := revision.GetGeneration()
+		if hasDesiredState[gen] {
+			withDesiredState = gen
+		}
+		if !removedGens[gen] && withDesiredState != runtime.LastOrEmptyGen {
+			neededDesiredState[withDesiredState] = true
+		}
+	}
+
+	for _, revision := range revisions {
+		gen := revision.GetGeneration()
+		if !removedGens[gen] {
+			continue
+		}
+
+		if !neededDesiredState[gen] {
+			var keys []runtime.Key
+			instancePrefix := runtime.KeyFromParts(runtime.SystemNS, engine.TypeDesiredStateInstance.Kind, engine.GetDesiredStateInstanceName(gen, ""))
+			for _, key := range instanceKeys {
+				if strings.HasPrefix(key, instancePrefix) {
+					keys = append(keys, key)
+				}
+			}
+			err = reg.deleteRevisionObjects(engine.TypeDesiredStateInstance.Kind, keys, result)
+			if err != nil {
+				return err
+			}
+
+			indexKey := runtime.KeyFromParts(runtime.SystemNS, engine.TypeDesiredStateIndex.Kind, engine.GetDesiredStateIndexName(gen))
+			if containsKey(indexKeys, indexKey) {
+				err = reg.deleteRevisionObjects(engine.TypeDesiredStateIndex.Kind, []runtime.Key{indexKey}, result)
+				if err != nil {
+					return err
+				}
+			}
+
+			desiredStateKey := runtime.KeyFromParts(runtime.SystemNS, engine.TypeDesiredState.Kind, engine.GetDesiredStateName(gen))
+			if containsKey(desiredStateKeys, desiredStateKey) {
+				err = reg.deleteRevisionObjects(engine.TypeDesiredState.Kind, []runtime.Key{desiredStateKey}, result)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		resolutionLog, err := reg.GetResolutionLog(gen)
+		if err != nil {
+			return err
+		}
+		if resolutionLog != nil {
+			err = reg.deleteRevisionObjects(engine.TypeResolutionLog.Kind, []runtime.Key{runtime.KeyForStorable(resolutionLog)}, result)
+			if err != nil {
+				return err
+			}
+		}
+
+		markers, err := reg.GetActionMarkers(gen)
+		if err != nil {
+			return err
+		}
+		var markerKeys []runtime.Key
+		for _, marker := range markers {
+			markerKeys = append(markerKeys, runtime.KeyForStorable(marker))
+		}
+		err = reg.deleteRevisionObjects(engine.TypeActionMarker.Kind, markerKeys, result)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteRevisionObjects removes objects of a given kind with given keys, which belong to the removed revision
+func (reg *defaultRegistry) deleteRevisionObjects(kind runtime.Kind, keys []runtime.Key, result *GCResult) error {
+	for _, key := range keys {
+		err := reg.store.Delete(kind, key)
+		if err != nil {
+			return fmt.Errorf("error while deleting %s: %s", key, err)
+		}
+		result.Removed[kind]++
+	}
+
+	return nil
+}
+
+// containsKey returns true if a given sorted list of keys contains a given key
+func containsKey(keys []runtime.Key, key runtime.Key) bool {
+	idx := sort.SearchStrings(keys, key)
+	return idx < len(keys) && keys[idx] == key
+}
+
+// collectEnforcements removes old finished enforcements of revisions, which don't exist anymore. Enforcements of the
+// remaining revisions and of revisions created after the last given one are always kept
+func (reg *defaultRegistry) collectEnforcements(keepLast int, lastRevisionGen runtime.Generation, remainingGens map[runtime.Generation]bool, result *GCResult) error {
+	lastEnforcement, err := reg.GetEnforcement(runtime.LastOrEmptyGen)
+	if err != nil {
+		return fmt.Errorf("error while getting last enforcement: %s", err)
+	}
+	if lastEnforcement == nil {
+		return nil
+	}
+
+	var enforcements []*engine.Enforcement
+	err = reg.store.Find(engine.TypeEnforcement.Kind, &enforcements, store.WithKey(engine.EnforcementKey), store.WithGenRange(runtime.FirstGen, lastEnforcement.GetGeneration()))
+	if err != nil {
+		return fmt.Errorf("error while getting enforcements: %s", err)
+	}
+
+	active := make(map[runtime.Generation]bool)
+	for _, enforcement := range enforcements {
+		if !enforcement.IsFinished() || enforcement.RevisionGen > lastRevisionGen || remainingGens[enforcement.RevisionGen] {
+			active[enforcement.GetGeneration()] = true
+		}
+	}
+
+	removed, err := reg.store.Compact(engine.TypeEnforcement.Kind, engine.EnforcementKey, keepLast, func(gen runtime.Generation) bool {
+		return active[gen]
+	})
+	if err != nil {
+		return fmt.Errorf("error while compacting enforcements: %s", err)
+	}
+	result.Removed[engine.TypeEnforcement.Kind] += len(removed)
+
+	return nil
+}
+
+// collectPolicies removes old policies, except the ones with given generations, and returns generations of policy
+// objects referenced by the remaining ones by object kind and key. All objects referenced by any policy are listed, so
+// objects referenced by removed policies only get collected as well
+func (reg *defaultRegistry) collectPolicies(keepLast int, keep map[runtime.Generation]bool, result *GCResult) (map[runtime.Kind]map[runtime.Key]map[runtime.Generation]bool, error) {
+	lastPolicy, err := reg.GetPolicyData(runtime.LastOrEmptyGen)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting latest policy: %s", err)
+	}
+	if lastPolicy == nil {
+		return nil, nil
+	}
+
+	var policies []*engine.PolicyData
+	err = reg.store.Find(engine.TypePolicyData.Kind, &policies, store.WithKey(engine.PolicyDataKey), store.WithGenRange(runtime.FirstGen, lastPolicy.GetGeneration()))
+	if err != nil {
+		return nil, fmt.Errorf("error while getting policies: %s", err)
+	}
+
+	removed, err := reg.store.Compact(engine.TypePolicyData.Kind, engine.PolicyDataKey, keepLast, func(gen runtime.Generation) bool {
+		return keep[gen]
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error while compacting policies: %s", err)
+	}
+	result.Removed[engine.TypePolicyData.Kind] += len(removed)
+
+	removedGens := make(map[runtime.Generation]bool)
+	for _, gen := range removed {
+		removedGens[gen] = true
+	}
+
+	referenced := make(map[runtime.Kind]map[runtime.Key]map[runtime.Generation]bool)
+	for _, policyData := range policies {
+		for ns, kindMap := range policyData.Objects {
+			for kind, nameMap := range kindMap {
+				if referenced[kind] == nil {
+					referenced[kind] = make(map[runtime.Key]map[runtime.Generation]bool)
+				}
+				for name, gen := range nameMap {
+					key := runtime.KeyFromParts(ns, kind, name)
+					if referenced[kind][key] == nil {
+						referenced[kind][key] = make(map[runtime.Generation]bool)
+					}
+					if !removedGens[policyData.GetGeneration()] {
+						referenced[kind][key][gen] = true
+					}
+				}
+			}
+		}
+	}
+
+	return referenced, nil
+}
@@ -0,0 +1,263 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/apply/action/component"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectGarbage(t *testing.T) {
+	reg := New(memory.New(runtime.NewTypes().Append(Types...), store.NewYAMLCodec()))
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	claim := b.AddClaim(b.AddUser(), service)
+
+	// policy gens 2-6 with claim gens 1-5
+	_, _, err := reg.UpdatePolicy([]lang.Base{cluster, bundle, service, claim}, "admin")
+	if !assert.NoError(t, err, "Policy should be updated") {
+		t.FailNow()
+	}
+	for i := 1; i <= 4; i++ {
+		claim.Labels["updated"] = fmt.Sprintf("%d", i)
+		_, _, err = reg.UpdatePolicy([]lang.Base{claim}, "admin")
+		if !assert.NoError(t, err, "Policy should be updated") {
+			t.FailNow()
+		}
+	}
+
+	// revisions waiting to be processed keep policy gen 1 (initial revision) and policy gen 3 along with claim gen 2
+	// referenced by it
+	revision, err := reg.NewRevision(3, resolve.NewPolicyResolution(), false)
+	if !assert.NoError(t, err, "Revision should be created") {
+		t.FailNow()
+	}
+
+	result, err := reg.CollectGarbage(&GCRetention{KeepLast: 2, KeepLastHistory: 2}, time.Now())
+	if !assert.NoError(t, err, "Garbage should be collected") {
+		t.FailNow()
+	}
+	assert.Equal(t, map[runtime.Kind]int{engine.TypePolicyData.Kind: 2, lang.TypeClaim.Kind: 2}, removedOnly(result.Removed), "Old generations should be removed")
+	assert.Equal(t, 4, result.Total(), "Total number of removed keys should be reported")
+
+	for gen, exists := range map[runtime.Generation]bool{1: true, 2: false, 3: true, 4: false, 5: true, 6: true} {
+		policyData, errPolicy := reg.GetPolicyData(gen)
+		assert.NoError(t, errPolicy)
+		assert.Equal(t, exists, policyData != nil, "Policy gen %d existence after garbage collection", gen)
+	}
+
+	policy, _, err := reg.GetPolicy(3)
+	if assert.NoError(t, err, "Policy referenced by revision should be loaded") {
+		assert.Len(t, policy.GetObjectsByKind(lang.TypeClaim.Kind), 1, "Claim referenced by retained policy should be kept")
+	}
+
+	kept, err := reg.GetRevision(revision.GetGeneration())
+	assert.NoError(t, err)
+	assert.NotNil(t, kept, "Revision waiting to be processed should be kept")
+
+	result, err = reg.CollectGarbage(&GCRetention{KeepLast: 2, KeepLastHistory: 2}, time.Now())
+	assert.NoError(t, err, "Garbage should be collected again")
+	assert.Equal(t, 0, result.Total(), "Nothing should be removed on the second run")
+}
+
+func TestCollectGarbageRevisionObjects(t *testing.T) {
+	db := memory.New(runtime.NewTypes().Append(Types...), store.NewYAMLCodec())
+	reg := New(db)
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	claim := b.AddClaim(b.AddUser(), service)
+	claim.Labels[lang.LabelTarget] = cluster.Name
+	_, policyData, err := reg.UpdatePolicy([]lang.Base{cluster, bundle, service, claim}, "admin")
+	if !assert.NoError(t, err, "Policy should be updated") {
+		t.FailNow()
+	}
+	desiredState, err := resolve.NewPolicyResolver(b.Policy(), b.External(), event.NewLog(logrus.WarnLevel, "test-resolve")).ResolveAllClaims(context.Background())
+	if !assert.NoError(t, err, "Policy should be resolved without errors") || !assert.NotEmpty(t, desiredState.ComponentInstanceMap, "Desired state should have instances") {
+		t.FailNow()
+	}
+
+	// revision 1 (initial) and revision 2 are completed, revision 3 gets superseded by revision 4, which is still
+	// being resolved and uses desired state of revision 3
+	initial, err := reg.GetRevision(runtime.FirstGen)
+	if !assert.NoError(t, err, "Initial revision should be loaded") {
+		t.FailNow()
+	}
+	revisions := []*engine.Revision{initial}
+	for i := 0; i < 2; i++ {
+		revision, errRevision := reg.NewRevision(policyData.GetGeneration(), desiredState, false)
+		if !assert.NoError(t, errRevision, "Revision should be created") {
+			t.FailNow()
+		}
+		revisions = append(revisions, revision)
+	}
+
+	act := component.NewAttachClaimAction("component", "claim", 0)
+	for i, status := range []string{engine.RevisionStatusCompleted, engine.RevisionStatusCompleted, engine.RevisionStatusSuperseded} {
+		revisions[i].Status = status
+		assert.NoError(t, reg.UpdateRevision(revisions[i]), "Revision should be updated")
+		assert.NoError(t, reg.SaveResolutionLog(revisions[i], nil), "Resolution log should be saved")
+		markers, errMarkers := reg.NewCompletionMarkers(revisions[i])
+		if assert.NoError(t, errMarkers, "Completion markers should be loaded") {
+			assert.NoError(t, markers.MarkCompleted(act, "hash"), "Action should be marked as completed")
+		}
+	}
+
+	// revision gets created after the preceding ones are updated, as updated generation becomes the last one
+	resolving, err := reg.NewResolvingRevision(policyData.GetGeneration())
+	if !assert.NoError(t, err, "Resolving revision should be created") {
+		t.FailNow()
+	}
+
+	// enforcements of revisions 1 and 3 are finished, while enforcement of revision 4 is pending
+	for _, revision := range []*engine.Revision{revisions[0], revisions[2], resolving} {
+		enforcement, errEnforcement := reg.NewEnforcement(revision.GetGeneration(), time.Now())
+		if !assert.NoError(t, errEnforcement, "Enforcement should be created") {
+			t.FailNow()
+		}
+		if revision != resolving {
+			enforcement.Status = engine.EnforcementStatusSucceeded
+			assert.NoError(t, reg.UpdateEnforcement(enforcement), "Enforcement should be updated")
+		}
+	}
+
+	result, err := reg.CollectGarbage(&GCRetention{KeepLast: 1, KeepLastHistory: 1}, time.Now())
+	if !assert.NoError(t, err, "Garbage should be collected") {
+		t.FailNow()
+	}
+	assert.Equal(t, map[runtime.Kind]int{
+		engine.TypePolicyData.Kind:        1,
+		engine.TypeRevision.Kind:          2,
+		engine.TypeDesiredStateIndex.Kind: 1,
+		engine.TypeResolutionLog.Kind:     2,
+		engine.TypeActionMarker.Kind:      2,
+		engine.TypeEnforcement.Kind:       2,
+	}, removedOnly(result.Removed), "Objects of removed revisions should be removed")
+
+	for _, revision := range []*engine.Revision{revisions[0], revisions[2]} {
+		gen := revision.GetGeneration()
+		kept, errRevision := reg.GetRevision(gen)
+		assert.NoError(t, errRevision)
+		assert.Nil(t, kept, "Revision %s should be removed", gen)
+
+		resolutionLog, errLog := reg.GetResolutionLog(gen)
+		assert.NoError(t, errLog)
+		assert.Nil(t, resolutionLog, "Resolution log of revision %s should be removed", gen)
+
+		markers, errMarkers := reg.GetActionMarkers(gen)
+		assert.NoError(t, errMarkers)
+		assert.Empty(t, markers, "Action markers of revision %s should be removed", gen)
+	}
+
+	indexKeys, err := db.Keys(engine.TypeDesiredStateIndex.Kind)
+	assert.NoError(t, err)
+	assert.NotContains(t, indexKeys, runtime.KeyFromParts(runtime.SystemNS, engine.TypeDesiredStateIndex.Kind, engine.GetDesiredStateIndexName(revisions[0].GetGeneration())), "Desired state of removed revision should be removed")
+
+	// desired state of removed revision 3 is still used by revision 4
+	instanceKeys, err := db.Keys(engine.TypeDesiredStateInstance.Kind)
+	assert.NoError(t, err)
+	assert.Len(t, instanceKeys, 2*len(desiredState.ComponentInstanceMap), "Desired state instances of revisions 2 and 3 should be kept")
+	resolvingDesiredState, err := reg.GetDesiredState(resolving)
+	if assert.NoError(t, err, "Desired state should be loaded") {
+		assert.Equal(t, len(desiredState.ComponentInstanceMap), len(resolvingDesiredState.ComponentInstanceMap), "Desired state of the preceding revision should be kept")
+	}
+
+	markers, err := reg.GetActionMarkers(revisions[1].GetGeneration())
+	assert.NoError(t, err)
+	assert.Len(t, markers, 1, "Action markers of the remaining revision should be kept")
+
+	for gen, exists := range map[runtime.Generation]bool{1: false, 2: false, 3: true} {
+		enforcement, errEnforcement := reg.GetEnforcement(gen)
+		assert.NoError(t, errEnforcement)
+		assert.Equal(t, exists, enforcement != nil, "Enforcement %d existence after garbage collection", gen)
+	}
+
+	result, err = reg.CollectGarbage(&GCRetention{KeepLast: 1, KeepLastHistory: 1}, time.Now())
+	assert.NoError(t, err, "Garbage should be collected again")
+	assert.Equal(t, 0, result.Total(), "Nothing should be removed on the second run")
+}
+
+func TestCollectGarbagePolicyApplyJobs(t *testing.T) {
+	reg := New(memory.New(runtime.NewTypes().Append(Types...), store.NewYAMLCodec()))
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	finished, err := reg.NewPolicyApplyJob("alice", now)
+	if !assert.NoError(t, err, "Job should be created") {
+		t.FailNow()
+	}
+	finished.State = engine.PolicyApplyJobStateSucceeded
+	finished.FinishedAt = now
+	finished.ExpiresAt = now.Add(time.Hour)
+	if !assert.NoError(t, reg.UpdatePolicyApplyJob(finished), "Job should be updated") {
+		t.FailNow()
+	}
+	running, err := reg.NewPolicyApplyJob("bob", now)
+	if !assert.NoError(t, err, "Job should be created") {
+		t.FailNow()
+	}
+
+	result, err := reg.CollectGarbage(nil, now.Add(time.Minute))
+	assert.NoError(t, err, "Garbage should be collected")
+	assert.Zero(t, result.Removed[engine.TypePolicyApplyJob.Kind], "Jobs should not be removed before they expire")
+
+	result, err = reg.CollectGarbage(nil, now.Add(2*time.Hour))
+	assert.NoError(t, err, "Garbage should be collected")
+	assert.Equal(t, 1, result.Removed[engine.TypePolicyApplyJob.Kind], "Expired job should be removed")
+
+	job, err := reg.GetPolicyApplyJob(finished.ID)
+	assert.NoError(t, err, "Missing job shouldn't be an error")
+	assert.Nil(t, job, "Expired job should be removed")
+	job, err = reg.GetPolicyApplyJob(running.ID)
+	assert.NoError(t, err, "Job should be loaded")
+	assert.NotNil(t, job, "Unfinished job should be kept")
+}
+
+func TestGCRetentionGetKeepLast(t *testing.T) {
+	var retention *GCRetention
+	assert.Equal(t, DefaultGCKeepLast, retention.GetKeepLast(lang.TypeClaim.Kind))
+	assert.Equal(t, DefaultGCKeepLastHistory, retention.GetKeepLast(engine.TypeRevision.Kind))
+
+	retention = &GCRetention{KeepLast: 3, KeepLastHistory: 7, KeepLastByKind: map[runtime.Kind]int{lang.TypeService.Kind: 1}}
+	assert.Equal(t, 3, retention.GetKeepLast(lang.TypeClaim.Kind))
+	assert.Equal(t, 7, retention.GetKeepLast(engine.TypePolicyData.Kind))
+	assert.Equal(t, 1, retention.GetKeepLast(lang.TypeService.Kind), "Retention should be overridden for a kind")
+}
+
+// removedOnly drops kinds for which nothing was removed
+func removedOnly(removed map[runtime.Kind]int) map[runtime.Kind]int {
+	result := make(map[runtime.Kind]int)
+	for kind, count := range removed {
+		if count > 0 {
+			result[kind] = count
+		}
+	}
+	return result
+}
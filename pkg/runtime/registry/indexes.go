@@ -0,0 +1,30 @@
+package registry
+
+import (
+	"fmt"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+)
+
+// RebuildIndexes checks indexes of all versioned objects in the registry against the stored objects and repairs them.
+// If dryRun is set, nothing is changed and only inconsistencies are reported
+func (reg *defaultRegistry) RebuildIndexes(dryRun bool) (map[runtime.Kind]*store.IndexRebuildResult, error) {
+	// policy shouldn't change while rebuilding, so policy object indexes aren't changed under the rebuild
+	reg.policyChangeLock.Lock()
+	defer reg.policyChangeLock.Unlock()
+
+	result := map[runtime.Kind]*store.IndexRebuildResult{}
+	for _, info := range Types {
+		if !info.Storable || !info.Versioned {
+			continue
+		}
+		kindResult, err := reg.store.RebuildIndexes(info.Kind, dryRun)
+		if err != nil {
+			return nil, fmt.Errorf("error while rebuilding indexes of kind %s: %s", info.Kind, err)
+		}
+		result[info.Kind] = kindResult
+	}
+
+	return result, nil
+}
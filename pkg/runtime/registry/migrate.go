@@ -0,0 +1,187 @@
+package registry
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+)
+
+// MigrationResult contains the number of copied objects for every kind (every generation of versioned objects is
+// counted separately)
+type MigrationResult map[runtime.Kind]int
+
+// Migrate copies all objects of all registered types from one store to another (e.g. from bolt to etcd). Versioned
+// objects are copied with all their existing generations, which are saved with exactly the same generations, so the
+// history (e.g. policy generations referenced by revisions) is preserved. Last generation indexes are verified in the
+// destination store once every object is copied. Migration could be safely re-run, as unchanged objects aren't written
+//
+// Policy objects could be in any namespace, so namespaces are collected from all generations of the policy
+func Migrate(from store.Interface, to store.Interface) (MigrationResult, error) {
+	namespaces, err := policyNamespaces(from)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(MigrationResult)
+	for _, info := range Types {
+		for _, ns := range namespaces {
+			var count int
+			if info.Versioned {
+				count, err = migrateVersioned(from, to, info, ns)
+			} else {
+				count, err = migrateNonVersioned(from, to, info, ns)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error while migrating objects of kind %s in namespace %s: %s", info.Kind, ns, err)
+			}
+			result[info.Kind] += count
+		}
+	}
+
+	return result, nil
+}
+
+// policyNamespaces returns sorted list of namespaces used by all existing generations of the policy along with the
+// system namespace
+func policyNamespaces(from store.Interface) ([]string, error) {
+	nsSet := map[string]bool{runtime.SystemNS: true}
+
+	var lastPolicyData *engine.PolicyData
+	err := from.Find(engine.TypePolicyData.Kind, &lastPolicyData, store.WithKey(engine.PolicyDataKey))
+	if err != nil {
+		return nil, fmt.Errorf("error while getting last policy: %s", err)
+	}
+
+	if lastPolicyData != nil {
+		for gen := runtime.FirstGen; gen <= lastPolicyData.GetGeneration(); gen = gen.Next() {
+			var policyData *engine.PolicyData
+			err = from.Find(engine.TypePolicyData.Kind, &policyData, store.WithKey(engine.PolicyDataKey), store.WithGen(gen))
+			if store.IsGenNotFound(err) {
+				// policy generation could be already compacted
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error while getting policy generation %s: %s", gen, err)
+			}
+			for ns := range policyData.Objects {
+				nsSet[ns] = true
+			}
+		}
+	}
+
+	namespaces := make([]string, 0, len(nsSet))
+	for ns := range nsSet {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	return namespaces, nil
+}
+
+// objectKeys returns keys of all objects of a given kind in a given namespace. Objects without name (e.g. policy)
+// have the key without trailing separator, while separator is needed for the rest of them, so kinds sharing the same
+// prefix (e.g. desired-state and desired-state-index) don't mix
+func objectKeys(from store.Interface, info *runtime.TypeInfo, ns string) ([]runtime.Key, error) {
+	base := runtime.KeyFromParts(ns, info.Kind, runtime.EmptyName)
+	keys := make([]runtime.Key, 0)
+
+	unnamed := reflect.New(reflect.TypeOf(info.New()))
+	if err := from.Find(info.Kind, unnamed.Interface(), store.WithKey(base)); err != nil {
+		return nil, err
+	}
+	if !unnamed.Elem().IsNil() {
+		keys = append(keys, base)
+	}
+
+	objs := reflect.New(reflect.SliceOf(reflect.TypeOf(info.New())))
+	if err := from.Find(info.Kind, objs.Interface(), store.WithKeyPrefix(base+runtime.KeySeparator)); err != nil {
+		return nil, err
+	}
+	for idx := 0; idx < objs.Elem().Len(); idx++ {
+		keys = append(keys, runtime.KeyForStorable(objs.Elem().Index(idx).Interface().(runtime.Storable))) // nolint: errcheck
+	}
+
+	return keys, nil
+}
+
+func migrateVersioned(from store.Interface, to store.Interface, info *runtime.TypeInfo, ns string) (int, error) {
+	keys, err := objectKeys(from, info, ns)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, key := range keys {
+		lastObj := reflect.New(reflect.TypeOf(info.New()))
+		if err = from.Find(info.Kind, lastObj.Interface(), store.WithKey(key)); err != nil {
+			return count, err
+		}
+		if lastObj.Elem().IsNil() {
+			// object could be deleted while migrating
+			continue
+		}
+
+		// generations are copied in order, so the last one is the last generation in the destination store as well
+		lastGen := lastObj.Elem().Interface().(runtime.Versioned).GetGeneration() // nolint: errcheck
+		for gen := runtime.FirstGen; gen <= lastGen; gen = gen.Next() {
+			obj := reflect.New(reflect.TypeOf(info.New()))
+			err = from.Find(info.Kind, obj.Interface(), store.WithKey(key), store.WithGen(gen))
+			if store.IsGenNotFound(err) {
+				// generation could be already compacted
+				continue
+			}
+			if err != nil {
+				return count, err
+			}
+
+			_, err = to.Save(obj.Elem().Interface().(runtime.Storable), store.WithReplaceOrForceGen()) // nolint: errcheck
+			if err != nil {
+				return count, fmt.Errorf("error while saving %s generation %s: %s", key, gen, err)
+			}
+			count++
+		}
+
+		saved := reflect.New(reflect.TypeOf(info.New()))
+		err = to.Find(info.Kind, saved.Interface(), store.WithKey(key))
+		if err != nil {
+			return count, fmt.Errorf("error while verifying last generation of %s: %s", key, err)
+		}
+		if saved.Elem().IsNil() || saved.Elem().Interface().(runtime.Versioned).GetGeneration() != lastGen { // nolint: errcheck
+			return count, fmt.Errorf("last generation of %s in the destination store doesn't match the last generation %s in the source store", key, lastGen)
+		}
+	}
+
+	return count, nil
+}
+
+func migrateNonVersioned(from store.Interface, to store.Interface, info *runtime.TypeInfo, ns string) (int, error) {
+	base := runtime.KeyFromParts(ns, info.Kind, runtime.EmptyName)
+	count := 0
+
+	unnamed := reflect.New(reflect.TypeOf(info.New()))
+	if err := from.Find(info.Kind, unnamed.Interface(), store.WithKey(base)); err != nil {
+		return 0, err
+	}
+	if !unnamed.Elem().IsNil() {
+		if _, err := to.Save(unnamed.Elem().Interface().(runtime.Storable)); err != nil { // nolint: errcheck
+			return 0, fmt.Errorf("error while saving %s: %s", base, err)
+		}
+		count++
+	}
+
+	// objects are scanned one by one, as there could be lots of them (e.g. component instances of desired states)
+	err := from.FindIter(info.Kind, func(obj runtime.Object) error {
+		storable := obj.(runtime.Storable) // nolint: errcheck
+		if _, err := to.Save(storable); err != nil {
+			return fmt.Errorf("error while saving %s: %s", runtime.KeyForStorable(storable), err)
+		}
+		count++
+		return nil
+	}, store.WithKeyPrefix(base+runtime.KeySeparator))
+
+	return count, err
+}
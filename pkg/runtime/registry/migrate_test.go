@@ -0,0 +1,89 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate(t *testing.T) {
+	from := memory.New(runtime.NewTypes().Append(Types...), store.NewYAMLCodec())
+	reg := New(from)
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	claim := b.AddClaim(b.AddUser(), service)
+	claim.Labels[lang.LabelTarget] = cluster.Name
+	_, _, err := reg.UpdatePolicy([]lang.Base{cluster, bundle, service, claim}, "admin")
+	if !assert.NoError(t, err, "Policy should be updated") {
+		t.FailNow()
+	}
+
+	// second generation of the claim
+	claim.Labels["updated"] = "true"
+	_, policyData, err := reg.UpdatePolicy([]lang.Base{claim}, "admin")
+	if !assert.NoError(t, err, "Policy should be updated") {
+		t.FailNow()
+	}
+
+	desiredState, resolveErr := resolve.NewPolicyResolver(b.Policy(), b.External(), event.NewLog(logrus.WarnLevel, "test-resolve")).ResolveAllClaims(context.Background())
+	if !assert.NoError(t, resolveErr, "Policy should be resolved without errors") {
+		t.FailNow()
+	}
+	revision, err := reg.NewRevision(policyData.GetGeneration(), desiredState, false)
+	if !assert.NoError(t, err, "Revision should be created") {
+		t.FailNow()
+	}
+	if !assert.NoError(t, reg.SaveDesiredState(revision, desiredState), "Desired state should be saved") {
+		t.FailNow()
+	}
+
+	to := memory.New(runtime.NewTypes().Append(Types...), store.NewYAMLCodec())
+	result, err := Migrate(from, to)
+	if !assert.NoError(t, err, "Migration should succeed") {
+		t.FailNow()
+	}
+	assert.EqualValues(t, policyData.GetGeneration(), result[engine.TypePolicyData.Kind], "All policy generations should be copied")
+	assert.Equal(t, 2, result[lang.TypeClaim.Kind], "All claim generations should be copied")
+	assert.Equal(t, 2, result[engine.TypeRevision.Kind], "All revisions should be copied")
+	assert.Equal(t, len(desiredState.ComponentInstanceMap), result[engine.TypeDesiredStateInstance.Kind], "Non-versioned objects should be copied")
+
+	migrated := New(to)
+	for gen := runtime.FirstGen; gen <= policyData.GetGeneration(); gen = gen.Next() {
+		expected, expectedErr := reg.GetPolicyData(gen)
+		actual, actualErr := migrated.GetPolicyData(gen)
+		if assert.NoError(t, expectedErr) && assert.NoError(t, actualErr) {
+			assert.Equal(t, expected, actual, "Policy generation %s should be copied as is", gen)
+		}
+	}
+
+	policy, policyGen, err := migrated.GetPolicy(runtime.LastOrEmptyGen)
+	if assert.NoError(t, err, "Migrated policy should be loaded") {
+		assert.Equal(t, policyData.GetGeneration(), policyGen, "Last policy generation should be preserved")
+		assert.Equal(t, "true", policy.GetObjectsByKind(lang.TypeClaim.Kind)[0].(*lang.Claim).Labels["updated"], "Last claim generation should be used")
+	}
+
+	// migration could be safely re-run
+	_, err = Migrate(from, to)
+	assert.NoError(t, err, "Repeated migration should succeed")
+	_, policyGen, err = migrated.GetPolicy(runtime.LastOrEmptyGen)
+	if assert.NoError(t, err) {
+		assert.Equal(t, policyData.GetGeneration(), policyGen, "Repeated migration shouldn't create new generations")
+	}
+}
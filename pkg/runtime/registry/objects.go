@@ -1,12 +1,38 @@
 package registry
 
 import (
+	"fmt"
+
 	"github.com/Aptomi/aptomi/pkg/engine"
 	"github.com/Aptomi/aptomi/pkg/lang"
 	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
 )
 
 var (
 	// Types represents list of all storable objects
 	Types = runtime.AppendAllTypes(engine.Types, lang.PolicyTypes)
 )
+
+// GetObjectType returns type info for a storable object kind or nil if there is no such kind
+func GetObjectType(kind runtime.Kind) *runtime.TypeInfo {
+	for _, info := range Types {
+		if info.Kind == kind {
+			return info
+		}
+	}
+	return nil
+}
+
+// ScanObjects calls fn for every non-versioned object of a given kind one by one, without loading all of them in
+// memory at once
+func (reg *defaultRegistry) ScanObjects(kind runtime.Kind, fn func(obj runtime.Object) error) error {
+	// separator is needed, so kinds sharing the same prefix (e.g. desired-state and desired-state-index) don't mix
+	prefix := runtime.KeyFromParts(runtime.SystemNS, kind, "") + runtime.KeySeparator
+	err := reg.store.FindIter(kind, fn, store.WithKeyPrefix(prefix))
+	if err != nil {
+		return fmt.Errorf("error while scanning objects of kind %s: %s", kind, err)
+	}
+
+	return nil
+}
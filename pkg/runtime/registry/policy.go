@@ -2,6 +2,7 @@ package registry
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/Aptomi/aptomi/pkg/engine"
@@ -16,6 +17,10 @@ func (reg *defaultRegistry) GetPolicyData(gen runtime.Generation) (*engine.Polic
 	// todo thing about replacing hardcoded key with some flag in Info that will show that there is a single object of that kind
 	var policyData *engine.PolicyData
 	err := reg.store.Find(engine.TypePolicyData.Kind, &policyData, store.WithKey(engine.PolicyDataKey), store.WithGen(gen))
+	if store.IsGenNotFound(err) {
+		// requested generation doesn't exist or was compacted
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -26,6 +31,56 @@ func (reg *defaultRegistry) GetPolicyData(gen runtime.Generation) (*engine.Polic
 	return policyData, nil
 }
 
+// GetPolicyHistoryPage returns a page of at most limit policy generations (all of them if limit isn't positive) going
+// back in time from the latest one, starting before the generation defined by a given continue token (from the latest
+// generation if it's empty), along with the token for the next page (empty if there are no more pages). Compacted
+// generations are skipped. Empty list is returned if there is no policy yet
+func (reg *defaultRegistry) GetPolicyHistoryPage(limit int, token string) ([]*engine.PolicyData, string, error) {
+	to := runtime.LastOrEmptyGen
+	if len(token) > 0 {
+		before, err := strconv.ParseUint(token, 10, 64)
+		if err != nil || before <= uint64(runtime.FirstGen) {
+			return nil, "", &store.InvalidContinueTokenError{Token: token, Reason: "it should be a generation to continue before"}
+		}
+		to = runtime.Generation(before - 1)
+	} else {
+		lastData, err := reg.GetPolicyData(runtime.LastOrEmptyGen)
+		if err != nil {
+			return nil, "", err
+		}
+		if lastData == nil {
+			return []*engine.PolicyData{}, "", nil
+		}
+		to = lastData.GetGeneration()
+	}
+
+	result := []*engine.PolicyData{}
+	for to >= runtime.FirstGen && (limit <= 0 || len(result) < limit) {
+		// find as many generations as it's needed to fill the page, there could be less of them if some were compacted
+		from := runtime.FirstGen
+		if limit > 0 && to-runtime.FirstGen >= runtime.Generation(limit-len(result)) {
+			from = to - runtime.Generation(limit-len(result)) + 1
+		}
+
+		var policies []*engine.PolicyData
+		err := reg.store.Find(engine.TypePolicyData.Kind, &policies, store.WithKey(engine.PolicyDataKey), store.WithGenRange(from, to))
+		if err != nil {
+			return nil, "", err
+		}
+		for idx := len(policies) - 1; idx >= 0; idx-- {
+			result = append(result, policies[idx])
+		}
+
+		to = from - 1
+	}
+
+	if to < runtime.FirstGen {
+		return result, "", nil
+	}
+
+	return result, result[len(result)-1].GetGeneration().String(), nil
+}
+
 // getPolicyFromData() returns Policy converted from PolicyData.
 // if PolicyData is nil, it will return nil
 func (reg *defaultRegistry) getPolicyFromData(policyData *engine.PolicyData) (*lang.Policy, runtime.Generation, error) {
@@ -81,18 +136,23 @@ func (reg *defaultRegistry) UpdatePolicy(updatedObjects []lang.Base, performedBy
 		panic(fmt.Sprintf("cannot retrieve last policy from the registry, policyData is nil"))
 	}
 
-	changed := false
+	storables := make([]runtime.Storable, 0, len(updatedObjects))
 	for _, updatedObj := range updatedObjects {
 		if updatedObj.IsDeleted() {
 			return false, nil, fmt.Errorf("objects with deleted=true not supported while updating policy: %s", runtime.KeyForStorable(updatedObj))
 		}
+		storables = append(storables, updatedObj)
+	}
 
-		var changedObj bool
-		changedObj, err = reg.store.Save(updatedObj)
-		if err != nil {
-			return false, nil, err
-		}
-		if changedObj {
+	// all objects are saved at once, so policy couldn't be left partially updated
+	changedObjs, err := reg.store.SaveMany(storables)
+	if err != nil {
+		return false, nil, err
+	}
+
+	changed := false
+	for idx, updatedObj := range updatedObjects {
+		if changedObjs[idx] {
 			policyData.Add(updatedObj)
 			changed = true
 		}
@@ -176,3 +236,117 @@ func (reg *defaultRegistry) DeleteFromPolicy(deleted []lang.Base, performedBy st
 
 	return policyChanged, policyData, nil
 }
+
+// RollbackPolicy makes a new policy generation with the same objects as in policy with a given generation. Objects,
+// which have been changed since then, get saved with their old content as new generations and objects, which have been
+// added since then, get deleted. History is kept intact, as all changes are made as new generations
+func (reg *defaultRegistry) RollbackPolicy(gen runtime.Generation, performedBy string) (bool, *engine.PolicyData, error) {
+	// we should process only a single policy update request at once
+	reg.policyChangeLock.Lock()
+	defer reg.policyChangeLock.Unlock()
+
+	targetData, err := reg.GetPolicyData(gen)
+	if err != nil {
+		return false, nil, err
+	}
+	if targetData == nil {
+		return false, nil, fmt.Errorf("policy generation %s doesn't exist or was compacted", gen)
+	}
+
+	policyData, err := reg.GetPolicyData(runtime.LastOrEmptyGen)
+	if err != nil {
+		return false, nil, err
+	}
+	if policyData == nil {
+		panic(fmt.Sprintf("cannot retrieve last policy from the registry, policyData is nil"))
+	}
+
+	// load objects with their generations from the target policy
+	restored := make([]lang.Base, 0)
+	storables := make([]runtime.Storable, 0)
+	for ns, kindNameGen := range targetData.Objects {
+		for kind, nameGen := range kindNameGen {
+			for name, objGen := range nameGen {
+				var langObj lang.Base
+				err = reg.store.Find(kind, &langObj, store.WithKey(runtime.KeyFromParts(ns, kind, name)), store.WithGen(objGen))
+				if err != nil {
+					return false, nil, fmt.Errorf("error while loading object %s of policy generation %s: %s", runtime.KeyFromParts(ns, kind, name), gen, err)
+				}
+				restored = append(restored, langObj)
+				storables = append(storables, langObj)
+			}
+		}
+	}
+
+	// objects are saved with their old content, which creates new generations only for the changed ones
+	_, err = reg.store.SaveMany(storables)
+	if err != nil {
+		return false, nil, err
+	}
+
+	rolledBack := &engine.PolicyData{
+		TypeKind: policyData.TypeKind,
+		Metadata: policyData.Metadata,
+		Objects:  make(map[string]map[string]map[string]runtime.Generation),
+	}
+	for _, obj := range restored {
+		rolledBack.Add(obj)
+	}
+
+	// objects added after the target generation get deleted
+	for ns, kindNameGen := range policyData.Objects {
+		for kind, nameGen := range kindNameGen {
+			for name, objGen := range nameGen {
+				if _, exist := rolledBack.Objects[ns][kind][name]; exist {
+					continue
+				}
+				var langObj lang.Base
+				err = reg.store.Find(kind, &langObj, store.WithKey(runtime.KeyFromParts(ns, kind, name)), store.WithGen(objGen))
+				if err != nil {
+					return false, nil, fmt.Errorf("error while loading object %s of the current policy: %s", runtime.KeyFromParts(ns, kind, name), err)
+				}
+				langObj.SetDeleted(true)
+				_, err = reg.store.Save(langObj)
+				if err != nil {
+					return false, nil, fmt.Errorf("error while setting deleted=true for %s: %s", runtime.KeyForStorable(langObj), err)
+				}
+			}
+		}
+	}
+
+	if samePolicyObjects(rolledBack, policyData) {
+		return false, policyData, nil
+	}
+
+	// update metadata before saving policy data (to capture who and when rolled back the policy)
+	rolledBack.Metadata.UpdatedAt = time.Now()
+	rolledBack.Metadata.UpdatedBy = performedBy
+
+	_, err = reg.store.Save(rolledBack)
+	if err != nil {
+		return false, nil, err
+	}
+
+	return true, rolledBack, nil
+}
+
+// samePolicyObjects returns true if both policies consist of the same generations of the same objects
+func samePolicyObjects(policyData *engine.PolicyData, other *engine.PolicyData) bool {
+	count := 0
+	for ns, kindNameGen := range policyData.Objects {
+		for kind, nameGen := range kindNameGen {
+			for name, objGen := range nameGen {
+				if otherGen, exist := other.Objects[ns][kind][name]; !exist || otherGen != objGen {
+					return false
+				}
+				count++
+			}
+		}
+	}
+	for _, kindNameGen := range other.Objects {
+		for _, nameGen := range kindNameGen {
+			count -= len(nameGen)
+		}
+	}
+	return count == 0
+}
@@ -0,0 +1,90 @@
+package registry
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+)
+
+// NewPolicyApplyJob creates a new pending PolicyApplyJob with a generated ID and saves it to the database
+func (reg *defaultRegistry) NewPolicyApplyJob(createdBy string, createdAt time.Time) (*engine.PolicyApplyJob, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("error while generating policy apply job id: %s", err)
+	}
+
+	job := engine.NewPolicyApplyJob(hex.EncodeToString(id), createdBy, createdAt)
+	_, err := reg.store.Save(job)
+	if err != nil {
+		return nil, fmt.Errorf("error while saving new policy apply job: %s", err)
+	}
+
+	return job, nil
+}
+
+// GetPolicyApplyJob returns PolicyApplyJob with a given ID or nil if it doesn't exist
+func (reg *defaultRegistry) GetPolicyApplyJob(id string) (*engine.PolicyApplyJob, error) {
+	var job *engine.PolicyApplyJob
+	err := reg.store.Find(engine.TypePolicyApplyJob.Kind, &job, store.WithKey(runtime.KeyFromParts(runtime.SystemNS, engine.TypePolicyApplyJob.Kind, id)))
+	if err != nil {
+		return nil, fmt.Errorf("error while getting policy apply job %s: %s", id, err)
+	}
+
+	return job, nil
+}
+
+// UpdatePolicyApplyJob saves specified PolicyApplyJob in the registry
+func (reg *defaultRegistry) UpdatePolicyApplyJob(job *engine.PolicyApplyJob) error {
+	_, err := reg.store.Save(job)
+	if err != nil {
+		return fmt.Errorf("error while updating policy apply job %s: %s", job.ID, err)
+	}
+
+	return nil
+}
+
+// GetUnfinishedPolicyApplyJobs returns all PolicyApplyJobs, which are still pending or running
+func (reg *defaultRegistry) GetUnfinishedPolicyApplyJobs() ([]*engine.PolicyApplyJob, error) {
+	unfinished := []*engine.PolicyApplyJob{}
+	err := reg.ScanObjects(engine.TypePolicyApplyJob.Kind, func(obj runtime.Object) error {
+		job := obj.(*engine.PolicyApplyJob) // nolint: errcheck
+		if !job.IsFinished() {
+			unfinished = append(unfinished, job)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error while getting unfinished policy apply jobs: %s", err)
+	}
+
+	return unfinished, nil
+}
+
+// DeleteExpiredPolicyApplyJobs removes all finished PolicyApplyJobs, which have expired by a given time, and returns
+// the number of removed jobs
+func (reg *defaultRegistry) DeleteExpiredPolicyApplyJobs(now time.Time) (int, error) {
+	expired := []runtime.Key{}
+	err := reg.ScanObjects(engine.TypePolicyApplyJob.Kind, func(obj runtime.Object) error {
+		job := obj.(*engine.PolicyApplyJob) // nolint: errcheck
+		if job.IsExpired(now) {
+			expired = append(expired, runtime.KeyForStorable(job))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, key := range expired {
+		if err := reg.store.Delete(engine.TypePolicyApplyJob.Kind, key); err != nil {
+			return 0, fmt.Errorf("error while deleting policy apply job %s: %s", key, err)
+		}
+	}
+
+	return len(expired), nil
+}
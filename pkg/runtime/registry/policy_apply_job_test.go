@@ -0,0 +1,62 @@
+package registry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyApplyJobs(t *testing.T) {
+	reg := New(memory.New(runtime.NewTypes().Append(Types...), store.NewYAMLCodec()))
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	first, err := reg.NewPolicyApplyJob("alice", now)
+	if !assert.NoError(t, err, "Job should be created") {
+		t.FailNow()
+	}
+	second, err := reg.NewPolicyApplyJob("bob", now)
+	if !assert.NoError(t, err, "Job should be created") {
+		t.FailNow()
+	}
+	assert.NotEmpty(t, first.ID, "Job should get an ID")
+	assert.NotEqual(t, first.ID, second.ID, "Every job should get a unique ID")
+	assert.Equal(t, engine.PolicyApplyJobStatePending, first.State, "New job should be pending")
+
+	// finish the first job
+	first.State = engine.PolicyApplyJobStateSucceeded
+	first.FinishedAt = now
+	first.ExpiresAt = now.Add(time.Hour)
+	if !assert.NoError(t, reg.UpdatePolicyApplyJob(first), "Job should be updated") {
+		t.FailNow()
+	}
+	loaded, err := reg.GetPolicyApplyJob(first.ID)
+	if assert.NoError(t, err, "Job should be loaded") && assert.NotNil(t, loaded, "Job should exist") {
+		assert.Equal(t, engine.PolicyApplyJobStateSucceeded, loaded.State, "Job should be updated")
+		assert.Equal(t, "alice", loaded.CreatedBy, "Job should keep its creator")
+	}
+
+	// job should be removed only after it expires, while unfinished jobs should never be removed
+	unfinished, err := reg.GetUnfinishedPolicyApplyJobs()
+	if assert.NoError(t, err, "Unfinished jobs should be loaded") && assert.Len(t, unfinished, 1, "Only unfinished job should be returned") {
+		assert.Equal(t, second.ID, unfinished[0].ID, "Only unfinished job should be returned")
+	}
+
+	removed, err := reg.DeleteExpiredPolicyApplyJobs(now.Add(time.Minute))
+	assert.NoError(t, err, "Expired jobs should be removed")
+	assert.Equal(t, 0, removed, "Jobs should not be removed before they expire")
+	removed, err = reg.DeleteExpiredPolicyApplyJobs(now.Add(2 * time.Hour))
+	assert.NoError(t, err, "Expired jobs should be removed")
+	assert.Equal(t, 1, removed, "Expired job should be removed")
+
+	loaded, err = reg.GetPolicyApplyJob(first.ID)
+	assert.NoError(t, err, "Missing job shouldn't be an error")
+	assert.Nil(t, loaded, "Expired job should not be found")
+	loaded, err = reg.GetPolicyApplyJob(second.ID)
+	assert.NoError(t, err, "Job should be loaded")
+	assert.NotNil(t, loaded, "Unfinished job should not be removed")
+}
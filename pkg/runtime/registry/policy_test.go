@@ -0,0 +1,115 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/lang/builder"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRollbackPolicy(t *testing.T) {
+	reg := New(memory.New(runtime.NewTypes().Append(Types...), store.NewYAMLCodec()))
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+
+	b := builder.NewPolicyBuilder()
+	cluster := b.AddCluster()
+	bundle := b.AddBundle()
+	b.AddBundleComponent(bundle, b.CodeComponent(nil, nil))
+	service := b.AddService(bundle, b.CriteriaTrue())
+	claim := b.AddClaim(b.AddUser(), service)
+	_, target, err := reg.UpdatePolicy([]lang.Base{cluster, bundle, service, claim}, "admin")
+	if !assert.NoError(t, err, "Policy should be updated") {
+		t.FailNow()
+	}
+
+	// change claim and add another one after the target generation
+	claim.Labels["updated"] = "true"
+	added := b.AddClaim(b.AddUser(), service)
+	_, latest, err := reg.UpdatePolicy([]lang.Base{claim, added}, "admin")
+	if !assert.NoError(t, err, "Policy should be updated") {
+		t.FailNow()
+	}
+
+	changed, rolledBack, err := reg.RollbackPolicy(target.GetGeneration(), "operator")
+	if !assert.NoError(t, err, "Policy should be rolled back") {
+		t.FailNow()
+	}
+	assert.True(t, changed, "Policy should be changed by rollback")
+	assert.Equal(t, latest.GetGeneration().Next(), rolledBack.GetGeneration(), "Rollback should create a new policy generation")
+
+	policy, _, err := reg.GetPolicy(runtime.LastOrEmptyGen)
+	if !assert.NoError(t, err, "Policy should be loaded") {
+		t.FailNow()
+	}
+	claims := policy.GetObjectsByKind(lang.TypeClaim.Kind)
+	if assert.Len(t, claims, 1, "Claim added after the target generation should be removed") {
+		assert.NotContains(t, claims[0].(*lang.Claim).Labels, "updated", "Claim should have content from the target generation")
+	}
+
+	old, _, err := reg.GetPolicy(latest.GetGeneration())
+	if assert.NoError(t, err, "Previous policy generation should be loaded") {
+		assert.Len(t, old.GetObjectsByKind(lang.TypeClaim.Kind), 2, "History shouldn't be rewritten")
+	}
+
+	changed, _, err = reg.RollbackPolicy(target.GetGeneration(), "operator")
+	assert.NoError(t, err, "Policy should be rolled back again")
+	assert.False(t, changed, "Rolling back to the same content shouldn't change policy")
+
+	_, _, err = reg.RollbackPolicy(100, "operator")
+	assert.Error(t, err, "Rolling back to non-existing generation should fail")
+}
+
+func TestGetPolicyHistoryPage(t *testing.T) {
+	reg := New(memory.New(runtime.NewTypes().Append(Types...), store.NewYAMLCodec()))
+
+	policies, next, err := reg.GetPolicyHistoryPage(2, "")
+	if assert.NoError(t, err, "Policy history should be returned for empty registry") {
+		assert.Empty(t, policies, "There should be no policy generations in empty registry")
+		assert.Empty(t, next, "There should be no more pages in empty registry")
+	}
+
+	if !assert.NoError(t, reg.InitPolicy(), "Policy should be initialized") {
+		t.FailNow()
+	}
+	b := builder.NewPolicyBuilder()
+	for i := 0; i < 4; i++ {
+		_, _, err = reg.UpdatePolicy([]lang.Base{b.AddCluster()}, "admin")
+		if !assert.NoError(t, err, "Policy should be updated") {
+			t.FailNow()
+		}
+	}
+
+	// page through all 5 generations going back in time
+	gens := []runtime.Generation{}
+	pages := 0
+	for {
+		policies, next, err = reg.GetPolicyHistoryPage(2, next)
+		if !assert.NoError(t, err, "Policy history page should be returned") {
+			t.FailNow()
+		}
+		pages++
+		for _, policyData := range policies {
+			gens = append(gens, policyData.GetGeneration())
+		}
+		if len(next) == 0 {
+			break
+		}
+	}
+	assert.Equal(t, []runtime.Generation{5, 4, 3, 2, 1}, gens, "All policy generations should be returned starting from the latest one")
+	assert.Equal(t, 3, pages, "Policy generations should be returned in pages")
+
+	policies, next, err = reg.GetPolicyHistoryPage(0, "")
+	if assert.NoError(t, err, "Policy history should be returned without limit") {
+		assert.Len(t, policies, 5, "All policy generations should be returned without limit")
+		assert.Empty(t, next, "There should be no more pages without limit")
+	}
+
+	_, _, err = reg.GetPolicyHistoryPage(2, "abc")
+	assert.True(t, store.IsInvalidContinueToken(err), "Invalid continue token should be reported")
+}
@@ -0,0 +1,29 @@
+package registry
+
+import (
+	"fmt"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+)
+
+// GetReconciliation returns Reconciliation or nil if registry has never been restored
+func (reg *defaultRegistry) GetReconciliation() (*engine.Reconciliation, error) {
+	var reconciliation *engine.Reconciliation
+	err := reg.store.Find(engine.TypeReconciliation.Kind, &reconciliation, store.WithKey(engine.ReconciliationKey))
+	if err != nil {
+		return nil, fmt.Errorf("error while getting reconciliation: %s", err)
+	}
+
+	return reconciliation, nil
+}
+
+// UpdateReconciliation saves specified Reconciliation in the registry
+func (reg *defaultRegistry) UpdateReconciliation(reconciliation *engine.Reconciliation) error {
+	_, err := reg.store.Save(reconciliation)
+	if err != nil {
+		return fmt.Errorf("error while updating reconciliation: %s", err)
+	}
+
+	return nil
+}
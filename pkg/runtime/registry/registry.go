@@ -1,40 +1,79 @@
 package registry
 
 import (
+	"context"
+	"io"
+	"time"
+
 	"github.com/Aptomi/aptomi/pkg/engine"
 	"github.com/Aptomi/aptomi/pkg/engine/actual"
 	"github.com/Aptomi/aptomi/pkg/engine/apply/action"
 	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
 	"github.com/Aptomi/aptomi/pkg/lang"
 	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
 )
 
 // Interface represents main object registry interface that covers database operations for all objects
 type Interface interface {
 	PolicyRegistry
 	RevisionRegistry
+	EnforcementRegistry
 	ActualStateRegistry
+	ReconciliationRegistry
+	ResolutionQueueRegistry
+	PolicyApplyJobRegistry
+	ObjectRegistry
+
+	// WithContext returns registry, which reports tracing spans for store operations as children of the span from a
+	// given context
+	WithContext(ctx context.Context) Interface
 }
 
 // PolicyRegistry represents database operations for Policy object
 type PolicyRegistry interface {
 	GetPolicy(runtime.Generation) (*lang.Policy, runtime.Generation, error)
 	GetPolicyData(runtime.Generation) (*engine.PolicyData, error)
+	GetPolicyHistoryPage(limit int, token string) ([]*engine.PolicyData, string, error)
 	InitPolicy() error
 	UpdatePolicy(updated []lang.Base, performedBy string) (changed bool, data *engine.PolicyData, err error)
 	DeleteFromPolicy(deleted []lang.Base, performedBy string) (changed bool, data *engine.PolicyData, err error)
+	RollbackPolicy(gen runtime.Generation, performedBy string) (changed bool, data *engine.PolicyData, err error)
+	CompactPolicyObject(ns string, kind runtime.Kind, name string, keepLast int) (removed []runtime.Generation, err error)
+	CollectGarbage(retention *GCRetention, now time.Time) (*GCResult, error)
 }
 
 // RevisionRegistry represents database operations for Revision object
 type RevisionRegistry interface {
 	NewRevision(policyGen runtime.Generation, desiredState *resolve.PolicyResolution, recalculateAll bool) (*engine.Revision, error)
+	NewResolvingRevision(policyGen runtime.Generation) (*engine.Revision, error)
+	SaveDesiredState(revision *engine.Revision, desiredState *resolve.PolicyResolution) error
 	GetDesiredState(*engine.Revision) (*resolve.PolicyResolution, error)
+	GetDesiredStateInstanceKeys(revision *engine.Revision) ([]string, error)
+	GetDesiredStateInstance(revision *engine.Revision, key string) (*resolve.ComponentInstance, error)
+	SaveResolutionLog(revision *engine.Revision, events []*event.APIEvent) error
+	GetResolutionLog(revisionGen runtime.Generation) (*engine.ResolutionLog, error)
 	GetRevision(gen runtime.Generation) (*engine.Revision, error)
 	UpdateRevision(revision *engine.Revision) error
 	NewRevisionResultUpdater(revision *engine.Revision) action.ApplyResultUpdater
+	NewCompletionMarkers(revision *engine.Revision) (action.CompletionMarkers, error)
+	GetActionMarkers(revisionGen runtime.Generation) ([]*engine.ActionMarker, error)
+	DeleteActionMarkers(revisionGen runtime.Generation) error
 	GetFirstUnprocessedRevision() (*engine.Revision, error)
+	GetUnprocessedRevisions() ([]*engine.Revision, error)
 	GetLastRevisionForPolicy(policyGen runtime.Generation) (*engine.Revision, error)
 	GetAllRevisionsForPolicy(policyGen runtime.Generation) ([]*engine.Revision, error)
+	GetRevisionsForPolicyPage(policyGen runtime.Generation, limit int, token string) ([]*engine.Revision, string, error)
+}
+
+// EnforcementRegistry represents database operations for Enforcement object, which tracks enforcement of a single
+// policy change
+type EnforcementRegistry interface {
+	NewEnforcement(revisionGen runtime.Generation, createdAt time.Time) (*engine.Enforcement, error)
+	GetEnforcement(gen runtime.Generation) (*engine.Enforcement, error)
+	UpdateEnforcement(enforcement *engine.Enforcement) error
+	GetUnfinishedEnforcements() ([]*engine.Enforcement, error)
 }
 
 // ActualStateRegistry represents database operations for the actual state handling
@@ -42,3 +81,35 @@ type ActualStateRegistry interface {
 	GetActualState() (*resolve.PolicyResolution, error)
 	NewActualStateUpdater(*resolve.PolicyResolution) actual.StateUpdater
 }
+
+// ReconciliationRegistry represents database operations for the Reconciliation object, which gets written after
+// registry is restored from a backup
+type ReconciliationRegistry interface {
+	GetReconciliation() (*engine.Reconciliation, error)
+	UpdateReconciliation(reconciliation *engine.Reconciliation) error
+}
+
+// ResolutionQueueRegistry represents database operations for the ResolutionQueue object, which holds policy
+// generations waiting for desired state to be calculated in the background
+type ResolutionQueueRegistry interface {
+	GetResolutionQueue() (*engine.ResolutionQueue, error)
+	UpdateResolutionQueue(queue *engine.ResolutionQueue) error
+}
+
+// PolicyApplyJobRegistry represents database operations for PolicyApplyJob objects, which track asynchronous policy
+// changes
+type PolicyApplyJobRegistry interface {
+	NewPolicyApplyJob(createdBy string, createdAt time.Time) (*engine.PolicyApplyJob, error)
+	GetPolicyApplyJob(id string) (*engine.PolicyApplyJob, error)
+	UpdatePolicyApplyJob(job *engine.PolicyApplyJob) error
+	GetUnfinishedPolicyApplyJobs() ([]*engine.PolicyApplyJob, error)
+	DeleteExpiredPolicyApplyJobs(now time.Time) (int, error)
+}
+
+// ObjectRegistry represents low-level database operations for scanning over all objects of a given kind
+type ObjectRegistry interface {
+	ScanObjects(kind runtime.Kind, fn func(obj runtime.Object) error) error
+	Export(w io.Writer, codec store.Codec) (*store.BackupResult, error)
+	Import(r io.Reader, codec store.Codec, force bool) (*store.BackupResult, error)
+	RebuildIndexes(dryRun bool) (map[runtime.Kind]*store.IndexRebuildResult, error)
+}
@@ -0,0 +1,32 @@
+package registry
+
+import (
+	"fmt"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+)
+
+// GetResolutionQueue returns ResolutionQueue or an empty one if nothing has ever been queued
+func (reg *defaultRegistry) GetResolutionQueue() (*engine.ResolutionQueue, error) {
+	var queue *engine.ResolutionQueue
+	err := reg.store.Find(engine.TypeResolutionQueue.Kind, &queue, store.WithKey(engine.ResolutionQueueKey))
+	if err != nil {
+		return nil, fmt.Errorf("error while getting resolution queue: %s", err)
+	}
+	if queue == nil {
+		queue = engine.NewResolutionQueue()
+	}
+
+	return queue, nil
+}
+
+// UpdateResolutionQueue saves specified ResolutionQueue in the registry
+func (reg *defaultRegistry) UpdateResolutionQueue(queue *engine.ResolutionQueue) error {
+	_, err := reg.store.Save(queue)
+	if err != nil {
+		return fmt.Errorf("error while updating resolution queue: %s", err)
+	}
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/Aptomi/aptomi/pkg/engine"
 	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
 	"github.com/Aptomi/aptomi/pkg/runtime"
 	"github.com/Aptomi/aptomi/pkg/runtime/store"
 )
@@ -14,6 +15,10 @@ func (reg *defaultRegistry) GetRevision(gen runtime.Generation) (*engine.Revisio
 	// todo thing about replacing hardcoded key with some flag in Info that will show that there is a single object of that kind
 	var revision *engine.Revision
 	err := reg.store.Find(engine.TypeRevision.Kind, &revision, store.WithKey(engine.RevisionKey), store.WithGen(gen))
+	if store.IsGenNotFound(err) {
+		// requested generation doesn't exist or was compacted
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -26,6 +31,27 @@ func (reg *defaultRegistry) GetRevision(gen runtime.Generation) (*engine.Revisio
 
 // NewRevision creates a new revision and saves it to the database
 func (reg *defaultRegistry) NewRevision(policyGen runtime.Generation, resolution *resolve.PolicyResolution, recalculateAll bool) (*engine.Revision, error) {
+	revision, err := reg.newRevision(policyGen, recalculateAll, engine.RevisionStatusWaiting)
+	if err != nil {
+		return nil, err
+	}
+
+	// save desired state
+	err = reg.SaveDesiredState(revision, resolution)
+	if err != nil {
+		return nil, err
+	}
+
+	return revision, nil
+}
+
+// NewResolvingRevision creates a new revision in resolving status and saves it to the database. Desired state for it
+// has to be saved later via SaveDesiredState
+func (reg *defaultRegistry) NewResolvingRevision(policyGen runtime.Generation) (*engine.Revision, error) {
+	return reg.newRevision(policyGen, false, engine.RevisionStatusResolving)
+}
+
+func (reg *defaultRegistry) newRevision(policyGen runtime.Generation, recalculateAll bool, status string) (*engine.Revision, error) {
 	currRevision, err := reg.GetRevision(runtime.LastOrEmptyGen)
 	if err != nil {
 		return nil, fmt.Errorf("error while getting last revision: %s", err)
@@ -40,6 +66,7 @@ func (reg *defaultRegistry) NewRevision(policyGen runtime.Generation, resolution
 
 	// create revision
 	revision := engine.NewRevision(gen, policyGen, recalculateAll)
+	revision.Status = status
 
 	// save revision
 	_, err = reg.store.Save(revision)
@@ -47,14 +74,27 @@ func (reg *defaultRegistry) NewRevision(policyGen runtime.Generation, resolution
 		return nil, fmt.Errorf("error while saving new revision: %s", err)
 	}
 
-	// save desired state
-	desiredState := engine.NewDesiredState(revision, resolution)
-	_, err = reg.store.Save(desiredState)
+	return revision, nil
+}
+
+// SaveDesiredState saves desired state for the revision. Every component instance gets saved as a separate object
+// along with the index of instance keys, so instances could be addressed individually without loading the whole
+// desired state. All of them are saved at once with the index going last, so desired state is only found by its index
+// once all instances have been saved
+func (reg *defaultRegistry) SaveDesiredState(revision *engine.Revision, resolution *resolve.PolicyResolution) error {
+	index := engine.NewDesiredStateIndex(revision, resolution)
+	storables := make([]runtime.Storable, 0, len(index.Keys)+1)
+	for _, key := range index.Keys {
+		storables = append(storables, engine.NewDesiredStateInstance(revision, resolution.ComponentInstanceMap[key]))
+	}
+	storables = append(storables, index)
+
+	_, err := reg.store.SaveMany(storables)
 	if err != nil {
-		return nil, fmt.Errorf("error while saving desired state for new revision: %s", err)
+		return fmt.Errorf("error while saving desired state for revision %d: %s", revision.GetGeneration(), err)
 	}
 
-	return revision, nil
+	return nil
 }
 
 // UpdateRevision updates specified Revision in the registry without creating new generation
@@ -91,6 +131,20 @@ func (reg *defaultRegistry) GetAllRevisionsForPolicy(policyGen runtime.Generatio
 	return revisions, nil
 }
 
+// GetRevisionsForPolicyPage returns a page of at most limit revisions for the specified policy generation starting
+// after the position defined by a given continue token (from the beginning if it's empty) and the token for the next
+// page (empty if there are no more pages)
+func (reg *defaultRegistry) GetRevisionsForPolicyPage(policyGen runtime.Generation, limit int, token string) ([]*engine.Revision, string, error) {
+	var revisions []*engine.Revision
+	var next string
+	err := reg.store.Find(engine.TypeRevision.Kind, &revisions, store.WithKey(engine.RevisionKey), store.WithWhereEq("PolicyGen", policyGen), store.WithLimit(limit), store.WithContinue(token), store.WithNextToken(&next))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return revisions, next, nil
+}
+
 // GetFirstUnprocessedRevision returns the last revision which has not beed processed by the engine yet
 func (reg *defaultRegistry) GetFirstUnprocessedRevision() (*engine.Revision, error) {
 	// TODO: this method is slow, needs indexes
@@ -103,16 +157,57 @@ func (reg *defaultRegistry) GetFirstUnprocessedRevision() (*engine.Revision, err
 	return revision, nil
 }
 
-// GetDesiredState returns desired state associated with the revision
+// GetUnprocessedRevisions returns all revisions, which have not been processed by the engine yet (including the ones
+// still being resolved), in chronological order
+func (reg *defaultRegistry) GetUnprocessedRevisions() ([]*engine.Revision, error) {
+	var revisions []*engine.Revision
+	err := reg.store.Find(engine.TypeRevision.Kind, &revisions, store.WithKey(engine.RevisionKey), store.WithWhereEq("Status", engine.RevisionStatusResolving, engine.RevisionStatusWaiting, engine.RevisionStatusInProgress))
+	if err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// GetDesiredState returns desired state associated with the revision. Revisions, which are still being resolved in the
+// background or failed to resolve, don't have desired state of their own, so desired state of the closest preceding
+// revision is returned for them. If there is no desired state stored for the revision and all preceding ones,
+// DesiredStateNotFoundError is returned
 func (reg *defaultRegistry) GetDesiredState(revision *engine.Revision) (*resolve.PolicyResolution, error) {
-	// todo make desired state versioned same as revision (forceSpecificVersion on save)
-	// todo thing about replacing hardcoded key with some flag in Info that will show that there is a single object of that kind
-	var desiredState *engine.DesiredState
-	// todo switch desired state from name including revision gen to just static name with forced generation equal to revision gen
-	err := reg.store.Find(engine.TypeDesiredState.Kind, &desiredState, store.WithKey(runtime.KeyFromParts(runtime.SystemNS, engine.TypeDesiredState.Kind, engine.GetDesiredStateName(revision.GetGeneration()))))
+	index, desiredState, err := reg.findDesiredStateIndex(revision)
 	if err != nil {
 		return nil, err
 	}
+	if index != nil {
+		return reg.loadDesiredState(index)
+	}
+
+	// desired state saved before instances became addressable
+	if desiredState != nil {
+		return &desiredState.Resolution, nil
+	}
+
+	return nil, &DesiredStateNotFoundError{RevisionGen: revision.GetGeneration()}
+}
+
+// SaveResolutionLog saves event log of policy resolution, which produced desired state of the revision
+func (reg *defaultRegistry) SaveResolutionLog(revision *engine.Revision, events []*event.APIEvent) error {
+	resolutionLog := engine.NewResolutionLog(revision, events)
+	_, err := reg.store.Save(resolutionLog)
+	if err != nil {
+		return fmt.Errorf("error while saving resolution log for revision %d: %s", revision.GetGeneration(), err)
+	}
+
+	return nil
+}
+
+// GetResolutionLog returns event log of policy resolution for the revision or nil if it hasn't been saved
+func (reg *defaultRegistry) GetResolutionLog(revisionGen runtime.Generation) (*engine.ResolutionLog, error) {
+	var resolutionLog *engine.ResolutionLog
+	err := reg.store.Find(engine.TypeResolutionLog.Kind, &resolutionLog, store.WithKey(runtime.KeyFromParts(runtime.SystemNS, engine.TypeResolutionLog.Kind, engine.GetResolutionLogName(revisionGen))))
+	if err != nil {
+		return nil, fmt.Errorf("error while getting resolution log for revision %d: %s", revisionGen, err)
+	}
 
-	return &desiredState.Resolution, nil
+	return resolutionLog, nil
 }
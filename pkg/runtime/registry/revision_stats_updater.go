@@ -51,10 +51,17 @@ func (updater *RevisionResultUpdaterImpl) AddSkipped() {
 	updater.save()
 }
 
+// AddPreviouslyCompleted safely increments the number of actions, which have been completed previously
+func (updater *RevisionResultUpdaterImpl) AddPreviouslyCompleted() {
+	atomic.AddUint32(&updater.revision.Result.PreviouslyCompleted, 1)
+	updater.save()
+}
+
 // Done saves the revision when all actions have been processed
 func (updater *RevisionResultUpdaterImpl) Done() *action.ApplyResult {
-	if updater.revision.Result.Success+updater.revision.Result.Failed+updater.revision.Result.Skipped != updater.revision.Result.Total {
-		panic(fmt.Sprintf("error while applying actions: %d (success) + %d (failed) + %d (skipped) != %d (total)", updater.revision.Result.Success, updater.revision.Result.Failed, updater.revision.Result.Skipped, updater.revision.Result.Total))
+	result := updater.revision.Result
+	if result.Processed() != result.Total {
+		panic(fmt.Sprintf("error while applying actions: %d (success) + %d (failed) + %d (skipped) + %d (previously completed) != %d (total)", result.Success, result.Failed, result.Skipped, result.PreviouslyCompleted, result.Total))
 	}
 	updater.revision.Status = engine.RevisionStatusCompleted
 	updater.revision.AppliedAt = time.Now()
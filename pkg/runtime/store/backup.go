@@ -0,0 +1,213 @@
+package store
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+// backupHeader is written at the beginning of every backup, so files of other formats get rejected on import
+var backupHeader = []byte("aptomi-backup/1\n")
+
+// maxBackupRecordSize limits the size of a single record read from a backup, so corrupted length doesn't make import
+// allocate huge amount of memory
+const maxBackupRecordSize = 1 << 30
+
+// BackupResult represents number of objects exported to or imported from a backup by kind
+type BackupResult struct {
+	Objects map[runtime.Kind]int
+}
+
+// Total returns the total number of exported or imported objects
+func (result *BackupResult) Total() int {
+	total := 0
+	for _, count := range result.Objects {
+		total += count
+	}
+	return total
+}
+
+// NotEmptyError is returned when backup is imported into a store, which already has objects, without force
+type NotEmptyError struct {
+	Kind runtime.Kind
+}
+
+func (err *NotEmptyError) Error() string {
+	return fmt.Sprintf("store isn't empty (it has objects of kind %s), import should be forced to replace them", err.Kind)
+}
+
+// IsNotEmpty returns true if a given error is NotEmptyError
+func IsNotEmpty(err error) bool {
+	_, ok := err.(*NotEmptyError)
+	return ok
+}
+
+// Export writes all objects of all storable kinds from a given store to w, including all generations of versioned
+// objects. Every object is written as its kind followed by the object encoded with a given codec, both prefixed with
+// their length. Objects aren't read in a single transaction, so writes to the store should be stopped while exporting
+// to get a consistent snapshot
+func Export(s Interface, types *runtime.Types, codec Codec, w io.Writer) (*BackupResult, error) {
+	writer := bufio.NewWriter(w)
+	if _, err := writer.Write(backupHeader); err != nil {
+		return nil, err
+	}
+
+	result := &BackupResult{Objects: make(map[runtime.Kind]int)}
+	for _, info := range storableTypes(types) {
+		keys, err := s.Keys(info.Kind)
+		if err != nil {
+			return nil, fmt.Errorf("error while listing objects of kind %s: %s", info.Kind, err)
+		}
+
+		for _, key := range keys {
+			objects, errFind := findAllGens(s, info, key)
+			if errFind != nil {
+				return nil, fmt.Errorf("error while reading %s: %s", key, errFind)
+			}
+			for _, obj := range objects {
+				data, errMarshal := codec.Marshal(obj)
+				if errMarshal != nil {
+					return nil, fmt.Errorf("error while encoding %s: %s", key, errMarshal)
+				}
+				if err = writeBackupRecord(writer, []byte(info.Kind)); err != nil {
+					return nil, err
+				}
+				if err = writeBackupRecord(writer, data); err != nil {
+					return nil, err
+				}
+				result.Objects[info.Kind]++
+			}
+		}
+	}
+
+	return result, writer.Flush()
+}
+
+// Import reads objects written by Export from r and saves them into a given store, so generations of versioned objects
+// match the exported ones exactly. Import into a store, which already has objects, fails unless force is set. In that
+// case objects from the backup replace the existing ones with the same keys and generations, while other objects are
+// kept intact
+func Import(s Interface, types *runtime.Types, codec Codec, r io.Reader, force bool) (*BackupResult, error) {
+	if !force {
+		for _, info := range storableTypes(types) {
+			keys, err := s.Keys(info.Kind)
+			if err != nil {
+				return nil, fmt.Errorf("error while listing objects of kind %s: %s", info.Kind, err)
+			}
+			if len(keys) > 0 {
+				return nil, &NotEmptyError{Kind: info.Kind}
+			}
+		}
+	}
+
+	reader := bufio.NewReader(r)
+	header := make([]byte, len(backupHeader))
+	if _, err := io.ReadFull(reader, header); err != nil || string(header) != string(backupHeader) {
+		return nil, fmt.Errorf("not an aptomi backup")
+	}
+
+	result := &BackupResult{Objects: make(map[runtime.Kind]int)}
+	for {
+		kind, err := readBackupRecord(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, fmt.Errorf("error while reading backup: %s", err)
+		}
+		data, err := readBackupRecord(reader)
+		if err != nil {
+			return result, fmt.Errorf("error while reading backup: object of kind %s is truncated: %s", kind, err)
+		}
+
+		info, exist := types.Kinds[string(kind)]
+		if !exist || !info.Storable {
+			return result, fmt.Errorf("backup contains objects of unknown kind %s", kind)
+		}
+		obj, ok := info.New().(runtime.Storable)
+		if !ok {
+			return result, fmt.Errorf("backup contains objects of non storable kind %s", kind)
+		}
+		if err = codec.Unmarshal(data, obj); err != nil {
+			return result, fmt.Errorf("error while decoding object of kind %s: %s", kind, err)
+		}
+		if _, err = s.Save(obj, WithReplaceOrForceGen()); err != nil {
+			return result, fmt.Errorf("error while saving %s: %s", runtime.KeyForStorable(obj), err)
+		}
+		result.Objects[info.Kind]++
+	}
+
+	return result, nil
+}
+
+// storableTypes returns storable types sorted by kind, so objects are always exported in the same order
+func storableTypes(types *runtime.Types) []*runtime.TypeInfo {
+	result := make([]*runtime.TypeInfo, 0, len(types.Kinds))
+	for _, info := range types.Kinds {
+		if info.Storable {
+			result = append(result, info)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Kind < result[j].Kind
+	})
+	return result
+}
+
+// findAllGens returns all generations of a versioned object ordered by generation or a non-versioned object
+func findAllGens(s Interface, info *runtime.TypeInfo, key runtime.Key) ([]runtime.Object, error) {
+	// store requires result to be typed the same way as objects of the kind
+	objType := reflect.TypeOf(info.New())
+	last := reflect.New(objType)
+	if err := s.Find(info.Kind, last.Interface(), WithKey(key)); err != nil {
+		return nil, err
+	}
+	if last.Elem().IsNil() {
+		// object has been deleted after it was listed
+		return nil, nil
+	}
+	lastObj := last.Elem().Interface().(runtime.Object) // nolint: errcheck
+	if !info.Versioned {
+		return []runtime.Object{lastObj}, nil
+	}
+
+	list := reflect.New(reflect.SliceOf(objType))
+	err := s.Find(info.Kind, list.Interface(), WithKey(key), WithGenRange(runtime.FirstGen, lastObj.(runtime.Versioned).GetGeneration()))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]runtime.Object, 0, list.Elem().Len())
+	for idx := 0; idx < list.Elem().Len(); idx++ {
+		result = append(result, list.Elem().Index(idx).Interface().(runtime.Object))
+	}
+
+	return result, nil
+}
+
+func writeBackupRecord(writer io.Writer, data []byte) error {
+	if err := binary.Write(writer, binary.BigEndian, uint32(len(data))); err != nil {
+		return err
+	}
+	_, err := writer.Write(data)
+	return err
+}
+
+func readBackupRecord(reader io.Reader) ([]byte, error) {
+	var size uint32
+	if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if size > maxBackupRecordSize {
+		return nil, fmt.Errorf("record size %d exceeds the limit", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
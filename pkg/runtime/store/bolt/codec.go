@@ -0,0 +1,34 @@
+package bolt
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+func (s *boltStore) marshal(value interface{}) []byte {
+	data, err := s.codec.Marshal(value)
+	if err != nil {
+		panic(fmt.Sprintf("error while marshaling value %v with error: %s", value, err))
+	}
+
+	return data
+}
+
+func (s *boltStore) unmarshal(data []byte, value interface{}) {
+	if err := s.codec.Unmarshal(data, value); err != nil {
+		panic(fmt.Sprintf("error while unmarshaling data: %s", err))
+	}
+}
+
+func (s *boltStore) marshalGen(generation runtime.Generation) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, uint64(generation))
+
+	return data
+}
+
+func (s *boltStore) unmarshalGen(data []byte) runtime.Generation {
+	return runtime.Generation(binary.BigEndian.Uint64(data))
+}
@@ -0,0 +1,17 @@
+package bolt
+
+import (
+	"time"
+)
+
+// defaultTimeout is how long to wait for database file lock if it isn't set in config
+var defaultTimeout = 5 * time.Second
+
+// Config represents bolt store configuration
+type Config struct {
+	// Path is the path to the database file, it's created if it doesn't exist
+	Path string
+
+	// Timeout is how long to wait for the database file lock, if it's opened by another process. If not set, 5s is used
+	Timeout time.Duration
+}
@@ -0,0 +1,7 @@
+// Package bolt implements file-backed store backend on top of bbolt, which follows the same semantics as etcd store
+// (verified by the shared storetest conformance suite). It's intended for single-node installs, where running etcd is
+// too heavy, as the database file is locked and could only be opened by a single process until the store is closed.
+//
+// Key layout matches etcd store, while "/object/" and "/index/" key prefixes are replaced with "objects" and "indexes"
+// buckets: objects are kept by "<key>@<gen>" and indexes by the same names as in etcd.
+package bolt
@@ -0,0 +1,899 @@
+package bolt
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	bbolt "github.com/coreos/bbolt"
+)
+
+var (
+	// objectsBucket keeps objects by key and generation (e.g. "ns/kind/name@gen")
+	objectsBucket = []byte("objects")
+	// indexesBucket keeps last gen, list gen and unique gen indexes by their names
+	indexesBucket = []byte("indexes")
+)
+
+type boltStore struct {
+	// mu serializes writes, so watchers get changes in the same order they are committed
+	mu       sync.Mutex
+	db       *bbolt.DB
+	types    *runtime.Types
+	codec    store.Codec
+	watchers *store.Watchers
+}
+
+// change is a committed change of an object, which watchers should be notified about
+type change struct {
+	info      *runtime.TypeInfo
+	eventType store.EventType
+	key       runtime.Key
+	gen       runtime.Generation
+	data      []byte
+}
+
+// New creates bolt store backend from provided config, types registry and codec. Database file is created if it
+// doesn't exist
+func New(cfg Config, types *runtime.Types, codec store.Codec) (store.Interface, error) {
+	if len(cfg.Path) == 0 {
+		return nil, fmt.Errorf("path to bolt database file should be set")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+
+	db, err := bbolt.Open(cfg.Path, 0600, &bbolt.Options{Timeout: cfg.Timeout})
+	if err != nil {
+		return nil, fmt.Errorf("error while opening bolt database %s: %s", cfg.Path, err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, bucket := range [][]byte{objectsBucket, indexesBucket} {
+			if _, bucketErr := tx.CreateBucketIfNotExists(bucket); bucketErr != nil {
+				return bucketErr
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close() // nolint: errcheck
+		return nil, fmt.Errorf("error while creating buckets in bolt database %s: %s", cfg.Path, err)
+	}
+
+	return &boltStore{
+		db:       db,
+		types:    types,
+		codec:    codec,
+		watchers: store.NewWatchers(codec),
+	}, nil
+}
+
+// Close closes database file
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
+
+func objectKey(key runtime.Key, gen runtime.Generation) []byte {
+	return []byte(key + "@" + gen.String())
+}
+
+// update runs fn in a write transaction and notifies watchers about the change once it's committed
+func (s *boltStore) update(fn func(tx *bbolt.Tx) (*change, error)) error {
+	return s.updateMany(func(tx *bbolt.Tx) ([]*change, error) {
+		committed, err := fn(tx)
+		if committed == nil {
+			return nil, err
+		}
+		return []*change{committed}, err
+	})
+}
+
+// updateMany runs fn in a write transaction and notifies watchers about all changes in order once it's committed
+func (s *boltStore) updateMany(fn func(tx *bbolt.Tx) ([]*change, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var committed []*change
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		committed, err = fn(tx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, c := range committed {
+		s.watchers.Notify(c.info, c.eventType, c.key, c.gen, c.data)
+	}
+
+	return nil
+}
+
+// Save saves Storable object with specified options into bolt and updates indexes when appropriate. It follows the
+// same workflow as etcd store, while all changes for a single object are made in a single bolt transaction
+func (s *boltStore) Save(newStorable runtime.Storable, opts ...store.SaveOpt) (bool, error) {
+	if newStorable == nil {
+		return false, fmt.Errorf("can't save nil")
+	}
+
+	saveOpts := store.NewSaveOpts(opts)
+	info := s.types.Get(newStorable.GetKind())
+	key := runtime.KeyForStorable(newStorable)
+
+	if !info.Versioned {
+		err := s.update(func(tx *bbolt.Tx) (*change, error) {
+			return s.saveNonVersioned(tx, info, key, newStorable)
+		})
+		return false, err
+	}
+
+	var newVersion bool
+	err := s.update(func(tx *bbolt.Tx) (*change, error) {
+		saved, err := s.saveVersioned(tx, info, key, newStorable.(runtime.Versioned), saveOpts) // nolint: errcheck
+		newVersion = saved != nil
+		return saved, err
+	})
+
+	return newVersion, err
+}
+
+// SaveMany saves all objects with the same options in a single bolt transaction, so either all of them are saved or
+// none. Watchers are notified about all changes once the transaction is committed
+func (s *boltStore) SaveMany(newStorables []runtime.Storable, opts ...store.SaveOpt) ([]bool, error) {
+	for _, newStorable := range newStorables {
+		if newStorable == nil {
+			return nil, fmt.Errorf("can't save nil")
+		}
+	}
+
+	saveOpts := store.NewSaveOpts(opts)
+	newVersions := make([]bool, len(newStorables))
+	err := s.updateMany(func(tx *bbolt.Tx) ([]*change, error) {
+		changes := make([]*change, 0, len(newStorables))
+		for idx, newStorable := range newStorables {
+			info := s.types.Get(newStorable.GetKind())
+			key := runtime.KeyForStorable(newStorable)
+
+			var saved *change
+			var err error
+			if info.Versioned {
+				saved, err = s.saveVersioned(tx, info, key, newStorable.(runtime.Versioned), saveOpts) // nolint: errcheck
+				newVersions[idx] = saved != nil
+			} else {
+				saved, err = s.saveNonVersioned(tx, info, key, newStorable)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if saved != nil {
+				changes = append(changes, saved)
+			}
+		}
+		return changes, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newVersions, nil
+}
+
+// saveNonVersioned saves non-versioned object within a given transaction
+func (s *boltStore) saveNonVersioned(tx *bbolt.Tx, info *runtime.TypeInfo, key runtime.Key, newStorable runtime.Storable) (*change, error) {
+	objects := tx.Bucket(objectsBucket)
+	objKey := objectKey(key, runtime.LastOrEmptyGen)
+	eventType := store.EventUpdated
+	if objects.Get(objKey) == nil {
+		eventType = store.EventCreated
+	}
+	data := s.marshal(newStorable)
+	return &change{info, eventType, key, runtime.LastOrEmptyGen, data}, objects.Put(objKey, data)
+}
+
+// saveVersioned saves versioned object within a given transaction. It returns nil change if object isn't changed
+func (s *boltStore) saveVersioned(tx *bbolt.Tx, info *runtime.TypeInfo, key runtime.Key, newObj runtime.Versioned, saveOpts *store.SaveOpts) (*change, error) {
+	objects := tx.Bucket(objectsBucket)
+	indexes := tx.Bucket(indexesBucket)
+	indexesInfo := store.IndexesFor(info)
+
+	// need to remove this obj from indexes
+	var prevObj runtime.Storable
+
+	if saveOpts.IsReplaceOrForceGen() {
+		newGen := newObj.GetGeneration()
+		if newGen == runtime.LastOrEmptyGen {
+			return nil, fmt.Errorf("error while saving object %s with replaceOrForceGen option but with empty generation", key)
+		}
+		// need to check if there is an object already exists with gen from the object, if yes - remove it from indexes
+		if oldObjRaw := objects.Get(objectKey(key, newGen)); oldObjRaw != nil {
+			prevObj = info.New().(runtime.Storable) // nolint: errcheck
+			s.unmarshal(oldObjRaw, prevObj)
+
+			if reflect.DeepEqual(prevObj, newObj) {
+				return nil, nil
+			}
+		}
+	} else {
+		// need to get last gen using index, if exists - compare with, if different - increment revision and delete old from indexes
+		lastGenRaw := indexes.Get([]byte(indexesInfo.NameForStorable(store.LastGenIndex, newObj, s.codec)))
+		if lastGenRaw == nil {
+			newObj.SetGeneration(runtime.FirstGen)
+		} else {
+			lastGen := s.unmarshalGen(lastGenRaw)
+			oldObjRaw := objects.Get(objectKey(key, lastGen))
+			if oldObjRaw == nil {
+				return nil, fmt.Errorf("last gen index for %s seems to be corrupted: generation doesn't exist", key)
+			}
+			prevObj = info.New().(runtime.Storable) // nolint: errcheck
+			s.unmarshal(oldObjRaw, prevObj)
+			newObj.SetGeneration(lastGen)
+
+			if reflect.DeepEqual(prevObj, newObj) {
+				return nil, nil
+			}
+
+			// objects are different
+			newObj.SetGeneration(lastGen.Next())
+		}
+	}
+
+	newGen := newObj.GetGeneration()
+
+	// values of unique indexes shouldn't be used by other generations, while values of namespace-wide unique indexes
+	// shouldn't be used by other objects
+	for _, index := range indexesInfo.List {
+		indexName := index.NameForStorable(newObj, s.codec)
+		if indexName == "" {
+			continue
+		}
+		if index.Type == store.IndexTypeUniqueGen {
+			if genRaw := indexes.Get([]byte(indexName)); genRaw != nil {
+				if gen := s.unmarshalGen(genRaw); gen != newGen {
+					return nil, &store.ErrDuplicateValue{Kind: info.Kind, Key: key, Field: index.Field, HolderGen: gen}
+				}
+			}
+		} else if index.Type == store.IndexTypeUniqueKey {
+			if holder := indexes.Get([]byte(indexName)); holder != nil && string(holder) != key {
+				return nil, &store.ErrDuplicateValue{Kind: info.Kind, Key: key, Field: index.Field, HolderKey: string(holder)}
+			}
+		}
+	}
+
+	// saved generation becomes the last one, so values of namespace-wide unique indexes held by the previous last
+	// generation should be released and it should be removed from indexes holding the last generations only
+	lastObj := prevObj
+	if saveOpts.IsReplaceOrForceGen() && (indexesInfo.HasType(store.IndexTypeUniqueKey) || indexesInfo.HasLastOnly()) {
+		lastObj = nil
+		if lastGenRaw := indexes.Get([]byte(indexesInfo.NameForStorable(store.LastGenIndex, newObj, s.codec))); lastGenRaw != nil {
+			if lastObjRaw := objects.Get(objectKey(key, s.unmarshalGen(lastGenRaw))); lastObjRaw != nil {
+				lastObj = info.New().(runtime.Storable) // nolint: errcheck
+				s.unmarshal(lastObjRaw, lastObj)
+			}
+		}
+	}
+
+	data := s.marshal(newObj)
+	if err := objects.Put(objectKey(key, newGen), data); err != nil {
+		return nil, err
+	}
+
+	if prevObj != nil && prevObj.(runtime.Versioned).GetGeneration() == newGen {
+		for _, index := range indexesInfo.List {
+			indexName := index.NameForStorable(prevObj, s.codec)
+			if indexName == "" {
+				continue
+			}
+			var err error
+			if index.Type == store.IndexTypeListGen {
+				err = s.updateIndex(indexes, []byte(indexName), newGen, true)
+			} else if index.Type == store.IndexTypeUniqueGen {
+				err = indexes.Delete([]byte(indexName))
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if lastObj != nil {
+		lastGen := lastObj.(runtime.Versioned).GetGeneration() // nolint: errcheck
+		for _, index := range indexesInfo.List {
+			indexName := index.NameForStorable(lastObj, s.codec)
+			if indexName == "" {
+				continue
+			}
+			var err error
+			if index.Type == store.IndexTypeUniqueKey && string(indexes.Get([]byte(indexName))) == key {
+				err = indexes.Delete([]byte(indexName))
+			} else if index.LastOnly && lastGen != newGen {
+				err = s.updateIndex(indexes, []byte(indexName), lastGen, true)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, index := range indexesInfo.List {
+		indexName := index.NameForStorable(newObj, s.codec)
+		if indexName == "" {
+			continue
+		}
+		var err error
+		if index.Type == store.IndexTypeLastGen {
+			err = indexes.Put([]byte(indexName), s.marshalGen(newGen))
+		} else if index.Type == store.IndexTypeListGen {
+			err = s.updateIndex(indexes, []byte(indexName), newGen, false)
+		} else if index.Type == store.IndexTypeUniqueGen {
+			err = indexes.Put([]byte(indexName), s.marshalGen(newGen))
+		} else if index.Type == store.IndexTypeUniqueKey {
+			err = indexes.Put([]byte(indexName), []byte(key))
+		} else {
+			panic(fmt.Sprintf("index type %s is not supported by bolt store", index.Type))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	eventType := store.EventUpdated
+	if prevObj == nil && newGen == runtime.FirstGen {
+		eventType = store.EventCreated
+	}
+
+	return &change{info, eventType, key, newGen, data}, nil
+}
+
+func (s *boltStore) updateIndex(indexes *bbolt.Bucket, indexKey []byte, gen runtime.Generation, remove bool) error {
+	valueList := &store.IndexValueList{}
+	if valueListRaw := indexes.Get(indexKey); valueListRaw != nil {
+		s.unmarshal(valueListRaw, valueList)
+	}
+	value := s.marshalGen(gen)
+	if remove {
+		valueList.Remove(value)
+	} else {
+		valueList.Add(value)
+	}
+	if len(*valueList) == 0 {
+		return indexes.Delete(indexKey)
+	}
+	return indexes.Put(indexKey, s.marshal(valueList))
+}
+
+// Find supports the same use cases as etcd store: keyPrefix OR key+gen OR key + whereEq+list/first/last
+func (s *boltStore) Find(kind runtime.Kind, result interface{}, opts ...store.FindOpt) error {
+	findOpts := store.NewFindOpts(opts)
+	info := s.types.Get(kind)
+	if err := findOpts.Validate(info); err != nil {
+		if store.IsInvalidContinueToken(err) {
+			return err
+		}
+		return fmt.Errorf("invalid find options: %s", err)
+	}
+
+	resultTypeList := reflect.PtrTo(reflect.SliceOf(reflect.TypeOf(info.New())))
+	resultList := reflect.TypeOf(result) == resultTypeList
+	if err := findOpts.ValidateResult(info, resultList); err != nil {
+		return fmt.Errorf("invalid find options: %s", err)
+	}
+
+	v := reflect.ValueOf(result).Elem()
+	addToResult := func(elem interface{}) {
+		if resultList {
+			if elem != nil {
+				v.Set(reflect.Append(v, reflect.ValueOf(elem)))
+			}
+		} else if elem == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(elem))
+		}
+	}
+
+	return s.db.View(func(tx *bbolt.Tx) error {
+		if findOpts.GetKeyPrefix() != "" {
+			return s.findByKeyPrefix(tx, findOpts, info, addToResult)
+		} else if findOpts.GetKey() != "" && findOpts.GetFieldEqName() == "" {
+			return s.findByKey(tx, findOpts, info, addToResult)
+		}
+		return s.findByFieldEq(tx, findOpts, info, addToResult)
+	})
+}
+
+// scanPrefix calls fn for all keys in a bucket prefixed by a given prefix in the order of keys. Values are only valid
+// within the transaction
+func scanPrefix(bucket *bbolt.Bucket, prefix []byte, fn func(key []byte, value []byte) error) error {
+	cursor := bucket.Cursor()
+	for key, value := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, value = cursor.Next() {
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanPage is the same as scanPrefix, but it starts right after a given key (if set) and stops after a given number of
+// keys (if set), so it allows to page through keys with a given prefix
+func scanPage(bucket *bbolt.Bucket, prefix []byte, after []byte, limit int, fn func(key []byte, value []byte) error) error {
+	start := prefix
+	if len(after) > 0 {
+		start = append(append([]byte(nil), after...), 0)
+	}
+	cursor := bucket.Cursor()
+	count := 0
+	for key, value := cursor.Seek(start); key != nil && bytes.HasPrefix(key, prefix); key, value = cursor.Next() {
+		if limit > 0 && count >= limit {
+			return nil
+		}
+		if err := fn(key, value); err != nil {
+			return err
+		}
+		count++
+	}
+	return nil
+}
+
+func (s *boltStore) findByKeyPrefix(tx *bbolt.Tx, findOpts *store.FindOpts, info *runtime.TypeInfo, addToResult func(interface{})) error {
+	if info.Versioned {
+		return s.findLastGensByKeyPrefix(tx, findOpts, info, addToResult)
+	}
+
+	var after []byte
+	if findOpts.GetContinueAfter() != "" {
+		after = objectKey(findOpts.GetContinueAfter(), runtime.LastOrEmptyGen)
+	}
+	scanned := 0
+	return scanPage(tx.Bucket(objectsBucket), []byte(findOpts.GetKeyPrefix()), after, findOpts.GetLimit(), func(key []byte, value []byte) error {
+		objKey := string(bytes.TrimSuffix(key, []byte("@"+runtime.LastOrEmptyGen.String())))
+		if scanned++; scanned == findOpts.GetLimit() {
+			findOpts.SetNextKey(objKey)
+		}
+
+		elem := info.New()
+		if err := s.codec.Unmarshal(value, elem); err != nil {
+			return findOpts.HandleDecodeError(objKey, err)
+		}
+		addToResult(elem)
+		return nil
+	})
+}
+
+// findLastGensByKeyPrefix lists last generations of versioned objects with keys prefixed by a given key prefix using
+// last generation index
+func (s *boltStore) findLastGensByKeyPrefix(tx *bbolt.Tx, findOpts *store.FindOpts, info *runtime.TypeInfo, addToResult func(interface{})) error {
+	indexes := store.IndexesFor(info)
+	indexPrefix := indexes.NameForValue(store.LastGenIndex, findOpts.GetKeyPrefix(), nil, s.codec)
+	indexKeyPrefix := []byte(indexes.NameForValue(store.LastGenIndex, "", nil, s.codec))
+	objects := tx.Bucket(objectsBucket)
+
+	var after []byte
+	if findOpts.GetContinueAfter() != "" {
+		after = append(append([]byte(nil), indexKeyPrefix...), findOpts.GetContinueAfter()...)
+	}
+	scanned := 0
+	return scanPage(tx.Bucket(indexesBucket), []byte(indexPrefix), after, findOpts.GetLimit(), func(indexKey []byte, value []byte) error {
+		key := string(bytes.TrimPrefix(indexKey, indexKeyPrefix))
+		if scanned++; scanned == findOpts.GetLimit() {
+			findOpts.SetNextKey(key)
+		}
+		gen := s.unmarshalGen(value)
+		data := objects.Get(objectKey(key, gen))
+		if data == nil {
+			return fmt.Errorf("last gen index for %s seems to be corrupted: generation %s doesn't exist", key, gen)
+		}
+
+		elem := info.New()
+		if err := s.codec.Unmarshal(data, elem); err != nil {
+			return findOpts.HandleDecodeError(key, err)
+		}
+		addToResult(elem)
+		return nil
+	})
+}
+
+// FindIter scans over objects with a given key prefix. Keys are collected upfront, while every object is read in its
+// own transaction and passed to fn outside of it, so fn could safely use the store
+func (s *boltStore) FindIter(kind runtime.Kind, fn func(obj runtime.Object) error, opts ...store.FindOpt) error {
+	findOpts := store.NewFindOpts(opts)
+	info := s.types.Get(kind)
+	if err := findOpts.ValidateIter(info); err != nil {
+		return fmt.Errorf("invalid find options: %s", err)
+	}
+
+	keys := make([][]byte, 0)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return scanPrefix(tx.Bucket(objectsBucket), []byte(findOpts.GetKeyPrefix()), func(key []byte, value []byte) error {
+			keys = append(keys, append([]byte(nil), key...))
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		var data []byte
+		err = s.db.View(func(tx *bbolt.Tx) error {
+			if value := tx.Bucket(objectsBucket).Get(key); value != nil {
+				data = append([]byte(nil), value...)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// object could be deleted while we are iterating
+		if data == nil {
+			continue
+		}
+
+		elem := info.New()
+		if err = s.codec.Unmarshal(data, elem); err != nil {
+			objKey := string(bytes.TrimSuffix(key, []byte("@"+runtime.LastOrEmptyGen.String())))
+			if err = findOpts.HandleDecodeError(objKey, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = fn(elem); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *boltStore) findByKey(tx *bbolt.Tx, findOpts *store.FindOpts, info *runtime.TypeInfo, addToResult func(interface{})) error {
+	if findOpts.IsGenRange() {
+		return s.findByGenRange(tx, findOpts, info, addToResult)
+	}
+	if !info.Versioned && findOpts.GetGen() != runtime.LastOrEmptyGen {
+		return fmt.Errorf("requested specific version for non versioned object")
+	}
+
+	gen := findOpts.GetGen()
+	if info.Versioned && gen == runtime.LastOrEmptyGen {
+		indexes := store.IndexesFor(info)
+		lastGenRaw := tx.Bucket(indexesBucket).Get([]byte(indexes.NameForValue(store.LastGenIndex, findOpts.GetKey(), nil, s.codec)))
+		if lastGenRaw == nil {
+			addToResult(nil)
+			return nil
+		}
+		gen = s.unmarshalGen(lastGenRaw)
+	}
+
+	data := tx.Bucket(objectsBucket).Get(objectKey(findOpts.GetKey(), gen))
+	if data == nil {
+		if findOpts.GetGen() != runtime.LastOrEmptyGen {
+			return &store.GenNotFoundError{Kind: info.Kind, Key: findOpts.GetKey(), Gen: gen}
+		}
+		if info.Versioned {
+			return fmt.Errorf("last generation index of object %s points to generation %s, which doesn't exist", findOpts.GetKey(), gen)
+		}
+		addToResult(nil)
+		return nil
+	}
+
+	result := info.New()
+	s.unmarshal(data, result)
+	addToResult(result)
+
+	return nil
+}
+
+func (s *boltStore) findByFieldEq(tx *bbolt.Tx, findOpts *store.FindOpts, info *runtime.TypeInfo, addToResult func(interface{})) error {
+	indexes := store.IndexesFor(info)
+	index, exist := indexes.List[findOpts.GetFieldEqName()]
+	if !exist {
+		return fmt.Errorf("can't find objects of kind %s by field %s, which isn't indexed (field should be tagged with `store:\"index\"`)", info.Kind, findOpts.GetFieldEqName())
+	}
+	resultGens := make([]runtime.Generation, 0)
+
+	for _, fieldValue := range findOpts.GetFieldEqValues() {
+		indexName := indexes.NameForValue(findOpts.GetFieldEqName(), findOpts.GetKey(), fieldValue, s.codec)
+		if indexName == "" {
+			panic(fmt.Sprintf("can't find using index for which empty index name generated"))
+		}
+		indexValue := tx.Bucket(indexesBucket).Get([]byte(indexName))
+		if indexValue != nil && index.Type == store.IndexTypeUniqueGen {
+			resultGens = append(resultGens, s.unmarshalGen(indexValue))
+		} else if indexValue != nil {
+			valueList := &store.IndexValueList{}
+			s.unmarshal(indexValue, valueList)
+			for _, val := range *valueList {
+				resultGens = append(resultGens, s.unmarshalGen(val))
+			}
+		}
+	}
+
+	resultGens = store.SortedUniqueGens(resultGens)
+
+	if len(resultGens) > 0 {
+		if findOpts.IsGetFirst() {
+			resultGens = []runtime.Generation{resultGens[0]}
+		} else if findOpts.IsGetLast() {
+			resultGens = []runtime.Generation{resultGens[len(resultGens)-1]}
+		}
+	}
+	resultGens = findOpts.PageGens(resultGens)
+	if len(resultGens) == 0 {
+		// single result is reset if nothing matches
+		addToResult(nil)
+	}
+
+	for _, gen := range resultGens {
+		data := tx.Bucket(objectsBucket).Get(objectKey(findOpts.GetKey(), gen))
+		if data == nil {
+			return fmt.Errorf("index is invalid, generation %s doesn't exist for key %s", gen, findOpts.GetKey())
+		}
+		result := info.New()
+		s.unmarshal(data, result)
+		addToResult(result)
+	}
+
+	return nil
+}
+
+// Delete removes object with a given key. For versioned object all generations get removed along with all index
+// entries referencing them
+func (s *boltStore) Delete(kind runtime.Kind, key runtime.Key) error {
+	info := s.types.Get(kind)
+
+	return s.update(func(tx *bbolt.Tx) (*change, error) {
+		objects := tx.Bucket(objectsBucket)
+		if !info.Versioned {
+			objKey := objectKey(key, runtime.LastOrEmptyGen)
+			data := objects.Get(objKey)
+			if data == nil {
+				return nil, nil
+			}
+			deleted := &change{info, store.EventDeleted, key, runtime.LastOrEmptyGen, append([]byte(nil), data...)}
+			return deleted, objects.Delete(objKey)
+		}
+
+		indexes := tx.Bucket(indexesBucket)
+		lastGenKey := []byte(store.IndexesFor(info).NameForValue(store.LastGenIndex, key, nil, s.codec))
+		lastGenRaw := indexes.Get(lastGenKey)
+		if lastGenRaw == nil {
+			return nil, nil
+		}
+		lastGen := s.unmarshalGen(lastGenRaw)
+		deleted := &change{info, store.EventDeleted, key, lastGen, append([]byte(nil), objects.Get(objectKey(key, lastGen))...)}
+
+		gens, err := s.listGens(objects, key)
+		if err != nil {
+			return nil, err
+		}
+		for _, gen := range gens {
+			if err = s.removeGen(tx, info, key, gen); err != nil {
+				return nil, err
+			}
+		}
+
+		return deleted, indexes.Delete(lastGenKey)
+	})
+}
+
+// listGens returns all existing generations of a versioned object
+// findByGenRange finds existing generations of the object within the range defined by WithGenRange in ascending order
+func (s *boltStore) findByGenRange(tx *bbolt.Tx, findOpts *store.FindOpts, info *runtime.TypeInfo, addToResult func(interface{})) error {
+	objects := tx.Bucket(objectsBucket)
+	gens, err := s.listGens(objects, findOpts.GetKey())
+	if err != nil {
+		return err
+	}
+
+	for _, gen := range findOpts.GensInRange(gens) {
+		result := info.New()
+		s.unmarshal(objects.Get(objectKey(findOpts.GetKey(), gen)), result)
+		addToResult(result)
+	}
+
+	return nil
+}
+
+func (s *boltStore) listGens(objects *bbolt.Bucket, key runtime.Key) ([]runtime.Generation, error) {
+	prefix := []byte(key + "@")
+	gens := make([]runtime.Generation, 0)
+	err := scanPrefix(objects, prefix, func(objKey []byte, value []byte) error {
+		gens = append(gens, runtime.ParseGeneration(string(bytes.TrimPrefix(objKey, prefix))))
+		return nil
+	})
+
+	return gens, err
+}
+
+// removeGen removes a single generation of a versioned object along with its entries in list and unique indexes.
+// Values of namespace-wide unique indexes are held by the last generation only, so they're released only when the
+// last generation is removed
+func (s *boltStore) removeGen(tx *bbolt.Tx, info *runtime.TypeInfo, key runtime.Key, gen runtime.Generation) error {
+	objects := tx.Bucket(objectsBucket)
+	indexes := tx.Bucket(indexesBucket)
+
+	objKey := objectKey(key, gen)
+	objRaw := objects.Get(objKey)
+	if objRaw == nil {
+		return nil
+	}
+	obj := info.New().(runtime.Storable) // nolint: errcheck
+	s.unmarshal(objRaw, obj)
+
+	indexesInfo := store.IndexesFor(info)
+	lastGenRaw := indexes.Get([]byte(indexesInfo.NameForValue(store.LastGenIndex, key, nil, s.codec)))
+	isLast := lastGenRaw != nil && s.unmarshalGen(lastGenRaw) == gen
+
+	for _, index := range indexesInfo.List {
+		indexName := index.NameForStorable(obj, s.codec)
+		if indexName == "" {
+			continue
+		}
+		var err error
+		if index.Type == store.IndexTypeListGen {
+			err = s.updateIndex(indexes, []byte(indexName), gen, true)
+		} else if index.Type == store.IndexTypeUniqueGen {
+			if genRaw := indexes.Get([]byte(indexName)); genRaw != nil && s.unmarshalGen(genRaw) == gen {
+				err = indexes.Delete([]byte(indexName))
+			}
+		} else if index.Type == store.IndexTypeUniqueKey && isLast && string(indexes.Get([]byte(indexName))) == key {
+			err = indexes.Delete([]byte(indexName))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return objects.Delete(objKey)
+}
+
+// Compact removes old generations of a versioned object, while keeping the last keepLast generations and the ones
+// which should be retained. Removed generations get removed from all indexes as well
+func (s *boltStore) Compact(kind runtime.Kind, key runtime.Key, keepLast int, retain func(runtime.Generation) bool) ([]runtime.Generation, error) {
+	info := s.types.Get(kind)
+	if !info.Versioned {
+		return nil, fmt.Errorf("non versioned object %s couldn't be compacted, as it has a single generation only", key)
+	}
+	if keepLast < 1 {
+		return nil, fmt.Errorf("at least the last generation of object %s should be kept while compacting", key)
+	}
+
+	var removed []runtime.Generation
+	err := s.update(func(tx *bbolt.Tx) (*change, error) {
+		gens, err := s.listGens(tx.Bucket(objectsBucket), key)
+		if err != nil {
+			return nil, err
+		}
+
+		removed = store.GenerationsToCompact(gens, keepLast, retain)
+		for _, gen := range removed {
+			if err = s.removeGen(tx, info, key, gen); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return removed, nil
+}
+
+// Keys returns sorted keys of all objects of a given kind
+func (s *boltStore) Keys(kind runtime.Kind) ([]runtime.Key, error) {
+	collector := store.NewKeysCollector(kind)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(objectsBucket).ForEach(func(key []byte, value []byte) error {
+			collector.Add(string(key))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return collector.Keys(), nil
+}
+
+// RebuildIndexes recomputes index entries of all objects of a given kind and replaces existing ones in a single write
+// transaction
+func (s *boltStore) RebuildIndexes(kind runtime.Kind, dryRun bool) (*store.IndexRebuildResult, error) {
+	info := s.types.Get(kind)
+	if !info.Versioned {
+		return nil, fmt.Errorf("indexes of non versioned objects of kind %s couldn't be rebuilt, as they aren't indexed", kind)
+	}
+
+	result := &store.IndexRebuildResult{DryRun: dryRun}
+	err := s.update(func(tx *bbolt.Tx) (*change, error) {
+		indexes := tx.Bucket(indexesBucket)
+
+		builder := store.NewIndexBuilder(info, s.codec)
+		err := tx.Bucket(objectsBucket).ForEach(func(key []byte, value []byte) error {
+			if !store.ObjectKeyHasKind(string(key), kind) {
+				return nil
+			}
+			obj := info.New().(runtime.Storable) // nolint: errcheck
+			if err := s.codec.Unmarshal(value, obj); err != nil {
+				return fmt.Errorf("error while decoding object %s: %s", key, err)
+			}
+			builder.Add(obj)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		expected := map[string][]byte{}
+		for indexName, entry := range builder.Entries() {
+			expected[indexName] = s.encodeIndexEntry(entry)
+		}
+
+		// bucket can't be modified while iterating over it, so stale entries are collected first
+		stale := make([][]byte, 0)
+		err = indexes.ForEach(func(indexKey []byte, value []byte) error {
+			if _, exist := expected[string(indexKey)]; !exist && store.IndexNameHasKind(string(indexKey), kind) {
+				stale = append(stale, append([]byte(nil), indexKey...))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, indexKey := range stale {
+			result.AddDangling(string(indexKey))
+			if dryRun {
+				continue
+			}
+			if err = indexes.Delete(indexKey); err != nil {
+				return nil, err
+			}
+		}
+
+		for indexName, value := range expected {
+			current := indexes.Get([]byte(indexName))
+			if current == nil {
+				result.AddMissing(indexName)
+			} else if !bytes.Equal(current, value) {
+				result.AddDivergent(indexName)
+			} else {
+				continue
+			}
+			if dryRun {
+				continue
+			}
+			if err = indexes.Put([]byte(indexName), value); err != nil {
+				return nil, err
+			}
+		}
+		result.Sort()
+
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// encodeIndexEntry returns stored value of the index entry the same way as it's stored while saving objects
+func (s *boltStore) encodeIndexEntry(entry *store.IndexEntry) []byte {
+	switch entry.Type {
+	case store.IndexTypeListGen:
+		valueList := &store.IndexValueList{}
+		for _, gen := range entry.Gens {
+			valueList.Add(s.marshalGen(gen))
+		}
+		return s.marshal(valueList)
+	case store.IndexTypeUniqueKey:
+		return []byte(entry.Key)
+	default:
+		return s.marshalGen(entry.Gens[0])
+	}
+}
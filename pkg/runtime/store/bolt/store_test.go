@@ -0,0 +1,89 @@
+package bolt_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/bolt"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/storetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoltStoreConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolt-store")
+	if !assert.NoError(t, err, "Temp dir should be created") {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	storetest.RunConformanceTests(t, func(t *testing.T, types *runtime.Types) store.Interface {
+		s, err := bolt.New(bolt.Config{Path: filepath.Join(dir, filepath.Base(t.Name())+".db")}, types, store.NewYAMLCodec())
+		if !assert.NoError(t, err, "Bolt store should be created") {
+			t.FailNow()
+		}
+		return s
+	})
+}
+
+func TestBoltStorePersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolt-store")
+	if !assert.NoError(t, err, "Temp dir should be created") {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	cfg := bolt.Config{Path: filepath.Join(dir, "aptomi.db")}
+	s, err := bolt.New(cfg, storetest.Types(), store.NewYAMLCodec())
+	if !assert.NoError(t, err, "Bolt store should be created") {
+		t.FailNow()
+	}
+	for _, status := range []string{"waiting", "done"} {
+		_, err = s.Save(&storetest.Item{TypeKind: storetest.TypeItem.GetTypeKind(), Name: "first", Status: status})
+		assert.NoError(t, err, "Object should be saved")
+	}
+	assert.NoError(t, s.Close(), "Bolt store should be closed")
+
+	s, err = bolt.New(cfg, storetest.Types(), store.NewYAMLCodec())
+	if !assert.NoError(t, err, "Bolt store should be reopened") {
+		t.FailNow()
+	}
+	defer s.Close() // nolint: errcheck
+
+	var item *storetest.Item
+	assert.NoError(t, s.Find(storetest.TypeItem.Kind, &item, store.WithKey(runtime.KeyFromParts(runtime.SystemNS, storetest.TypeItem.Kind, "first"))))
+	if assert.NotNil(t, item, "Object should be persisted") {
+		assert.EqualValues(t, 2, item.GetGeneration(), "Generations should be persisted")
+		assert.Equal(t, "done", item.Status)
+	}
+
+	_, err = bolt.New(bolt.Config{}, storetest.Types(), store.NewYAMLCodec())
+	assert.Error(t, err, "Missing path should be reported")
+}
+
+func TestBoltStoreFileLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolt-store")
+	if !assert.NoError(t, err, "Temp dir should be created") {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	cfg := bolt.Config{Path: filepath.Join(dir, "aptomi.db"), Timeout: 100 * time.Millisecond}
+	s, err := bolt.New(cfg, storetest.Types(), store.NewYAMLCodec())
+	if !assert.NoError(t, err, "Bolt store should be created") {
+		t.FailNow()
+	}
+
+	_, err = bolt.New(cfg, storetest.Types(), store.NewYAMLCodec())
+	assert.Error(t, err, "Database file should be locked while store is open")
+
+	assert.NoError(t, s.Close(), "Bolt store should be closed")
+	s, err = bolt.New(cfg, storetest.Types(), store.NewYAMLCodec())
+	if assert.NoError(t, err, "Database file lock should be released on close") {
+		assert.NoError(t, s.Close())
+	}
+}
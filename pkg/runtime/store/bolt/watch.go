@@ -0,0 +1,14 @@
+package bolt
+
+import (
+	"context"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+)
+
+// Watch delivers changes of objects made through this store, as database file couldn't be changed by other processes
+func (s *boltStore) Watch(ctx context.Context, kind runtime.Kind, keyPrefix runtime.Key) (<-chan *store.WatchEvent, error) {
+	s.types.Get(kind)
+	return s.watchers.Watch(ctx, kind, keyPrefix)
+}
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"encoding/gob"
 	"encoding/json"
+	"sync"
 
+	"github.com/Aptomi/aptomi/pkg/runtime"
 	"gopkg.in/yaml.v2"
 )
 
@@ -46,11 +48,25 @@ func (c *yamlCodec) Unmarshal(data []byte, value interface{}) error {
 	return yaml.Unmarshal(data, value)
 }
 
+// gobRegisterOnce guards registration of generic types shared by all gob codecs
+var gobRegisterOnce sync.Once
+
 type gobCodec struct {
 }
 
-// NewGobCodec returns instance of the store codec that is using gob
-func NewGobCodec() Codec {
+// NewGobCodec returns instance of the store codec that is using gob. All types from a given registry are registered
+// in gob along with generic maps and slices, so objects could be decoded even if they are kept in interface values
+// (e.g. nested parameters). Gob doesn't distinguish nil and empty maps and slices, so they are decoded as nil
+func NewGobCodec(types *runtime.Types) Codec {
+	gobRegisterOnce.Do(func() {
+		gob.Register(map[string]interface{}{})
+		gob.Register(map[interface{}]interface{}{})
+		gob.Register([]interface{}{})
+	})
+	for _, info := range types.Kinds {
+		gob.Register(info.New())
+	}
+
 	return &gobCodec{}
 }
 
@@ -67,13 +83,5 @@ func (c *gobCodec) Marshal(value interface{}) ([]byte, error) {
 }
 
 func (c *gobCodec) Unmarshal(data []byte, value interface{}) error {
-	var buffer bytes.Buffer
-	decoder := gob.NewDecoder(&buffer)
-
-	_, err := buffer.Write(data)
-	if err != nil {
-		return err
-	}
-
-	return decoder.Decode(value)
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(value)
 }
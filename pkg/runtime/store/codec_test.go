@@ -0,0 +1,199 @@
+package store_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/enginetest"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/util"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGobCodec(t *testing.T) {
+	codec := store.NewGobCodec(runtime.NewTypes().Append(resolve.TypeComponentInstance))
+
+	instance := &resolve.ComponentInstance{
+		TypeKind: resolve.TypeComponentInstance.GetTypeKind(),
+		IsCode:   true,
+		CalculatedCodeParams: util.NestedParameterMap{
+			"replicas": 3,
+			"image": util.NestedParameterMap{
+				"name": "nginx",
+				"tag":  "latest",
+			},
+		},
+		DataForPlugins: map[string]string{"key": "value"},
+	}
+	data, err := codec.Marshal(instance)
+	if !assert.NoError(t, err, "Object should be marshaled") {
+		t.FailNow()
+	}
+	decoded := resolve.TypeComponentInstance.New().(*resolve.ComponentInstance)
+	if assert.NoError(t, codec.Unmarshal(data, decoded), "Object should be unmarshaled") {
+		assert.Equal(t, instance.CalculatedCodeParams, decoded.CalculatedCodeParams, "Nested parameters should be decoded")
+		assert.Equal(t, instance.DataForPlugins, decoded.DataForPlugins)
+		assert.True(t, decoded.IsCode)
+	}
+
+	list := &store.IndexValueList{}
+	list.Add([]byte("first"))
+	list.Add([]byte("second"))
+	data, err = codec.Marshal(list)
+	if !assert.NoError(t, err, "Index value list should be marshaled") {
+		t.FailNow()
+	}
+	decodedList := &store.IndexValueList{}
+	if assert.NoError(t, codec.Unmarshal(data, decodedList), "Index value list should be unmarshaled") {
+		assert.Equal(t, list, decodedList)
+	}
+}
+
+func TestGobCodecAllTypes(t *testing.T) {
+	types := runtime.NewTypes().Append(registry.Types...)
+	codec := store.NewGobCodec(types)
+
+	for _, info := range registry.Types {
+		obj := info.New()
+		fillValue(reflect.ValueOf(obj).Elem(), 0)
+		data, err := codec.Marshal(obj)
+		if !assert.NoError(t, err, "Object of kind %s should be marshaled", info.Kind) {
+			continue
+		}
+		decoded := info.New()
+		if assert.NoError(t, codec.Unmarshal(data, decoded), "Object of kind %s should be unmarshaled", info.Kind) {
+			assert.Equal(t, obj, decoded, "Object of kind %s should be the same after round trip", info.Kind)
+		}
+	}
+}
+
+// fillValue sets all exported fields reachable from a given value to non-zero values, so round trip checks fields
+// being encoded instead of zero values. Pointers, slices and maps are left nil below a fixed depth, so recursive types
+// are filled up to that depth only
+func fillValue(value reflect.Value, depth int) {
+	switch value.Kind() {
+	case reflect.Bool:
+		value.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		value.SetFloat(1.5)
+	case reflect.String:
+		value.SetString("value")
+	case reflect.Interface:
+		// only empty interfaces could hold a string, which gob knows how to decode
+		if value.NumMethod() == 0 {
+			value.Set(reflect.ValueOf("value"))
+		}
+	case reflect.Ptr:
+		if depth < 5 {
+			elem := reflect.New(value.Type().Elem())
+			fillValue(elem.Elem(), depth+1)
+			value.Set(elem)
+		}
+	case reflect.Slice:
+		if depth < 5 {
+			slice := reflect.MakeSlice(value.Type(), 1, 1)
+			fillValue(slice.Index(0), depth+1)
+			value.Set(slice)
+		}
+	case reflect.Map:
+		if depth < 5 {
+			key := reflect.New(value.Type().Key()).Elem()
+			fillValue(key, depth+1)
+			elem := reflect.New(value.Type().Elem()).Elem()
+			fillValue(elem, depth+1)
+			m := reflect.MakeMap(value.Type())
+			m.SetMapIndex(key, elem)
+			value.Set(m)
+		}
+	case reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			fillValue(value.Index(i), depth)
+		}
+	case reflect.Struct:
+		if value.Type() == reflect.TypeOf(time.Time{}) {
+			value.Set(reflect.ValueOf(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)))
+			return
+		}
+		for i := 0; i < value.NumField(); i++ {
+			if value.Type().Field(i).PkgPath == "" {
+				fillValue(value.Field(i), depth)
+			}
+		}
+	}
+}
+
+// desiredStateForBenchmark returns desired state of the resolved small synthetic policy
+func desiredStateForBenchmark(b *testing.B) *engine.DesiredState {
+	b.Helper()
+	synthetic := enginetest.NewSyntheticPolicy(enginetest.SyntheticPolicySmall)
+	resolution, resolveErr := resolve.NewPolicyResolver(synthetic.Policy, synthetic.External, event.NewLog(logrus.WarnLevel, "bench-resolve")).SetContinueOnError(true).ResolveAllClaims(context.Background())
+	if resolveErr != nil {
+		b.Fatalf("policy should be resolved without errors: %s", resolveErr)
+	}
+	revision := engine.NewRevision(runtime.FirstGen, runtime.FirstGen, false)
+
+	return engine.NewDesiredState(revision, resolution)
+}
+
+func benchmarkCodecs() map[string]store.Codec {
+	types := runtime.NewTypes().Append(engine.TypeDesiredState)
+	return map[string]store.Codec{
+		"yaml": store.NewYAMLCodec(),
+		"gob":  store.NewGobCodec(types),
+	}
+}
+
+// BenchmarkCodecMarshal compares time and size (encoded-bytes) of the encoded desired state for all codecs
+func BenchmarkCodecMarshal(b *testing.B) {
+	desiredState := desiredStateForBenchmark(b)
+	for name, codec := range benchmarkCodecs() {
+		codec := codec
+		b.Run(name, func(b *testing.B) {
+			var size int
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				data, err := codec.Marshal(desiredState)
+				if err != nil {
+					b.Fatalf("desired state should be marshaled: %s", err)
+				}
+				size = len(data)
+			}
+			b.ReportMetric(float64(size), "encoded-bytes")
+		})
+	}
+}
+
+// BenchmarkCodecUnmarshal compares time of decoding the desired state for all codecs
+func BenchmarkCodecUnmarshal(b *testing.B) {
+	desiredState := desiredStateForBenchmark(b)
+	for name, codec := range benchmarkCodecs() {
+		codec := codec
+		b.Run(name, func(b *testing.B) {
+			data, err := codec.Marshal(desiredState)
+			if err != nil {
+				b.Fatalf("desired state should be marshaled: %s", err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				decoded := engine.TypeDesiredState.New()
+				if err = codec.Unmarshal(data, decoded); err != nil {
+					b.Fatalf("desired state should be unmarshaled: %s", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package store
+
+import (
+	"sort"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+// GenerationsToCompact returns a sorted list of generations, which should be removed while compacting an object with
+// a given list of existing generations. The last keepLast generations are always kept, as well as generations for
+// which retain returns true
+func GenerationsToCompact(gens []runtime.Generation, keepLast int, retain func(runtime.Generation) bool) []runtime.Generation {
+	sorted := make([]runtime.Generation, len(gens))
+	copy(sorted, gens)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	result := make([]runtime.Generation, 0)
+	for idx := 0; idx < len(sorted)-keepLast; idx++ {
+		if retain == nil || !retain(sorted[idx]) {
+			result = append(result, sorted[idx])
+		}
+	}
+
+	return result
+}
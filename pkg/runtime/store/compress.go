@@ -0,0 +1,112 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/golang/snappy"
+)
+
+const (
+	// CompressionGzip is the compression algorithm, which produces smaller values at the cost of speed
+	CompressionGzip = "gzip"
+	// CompressionSnappy is the compression algorithm, which is much faster than gzip, while values are larger
+	CompressionSnappy = "snappy"
+)
+
+// compressedMagic is the header of compressed values followed by a single byte of compression algorithm. Neither YAML
+// nor gob output starts with zero byte, so values stored without compression are read as is
+var compressedMagic = []byte{0x00, 'Z'}
+
+const (
+	compressedGzip byte = iota + 1
+	compressedSnappy
+)
+
+type compressedCodec struct {
+	codec     Codec
+	algorithm byte
+	level     int
+}
+
+// NewCompressedCodec returns store codec, which compresses output of a given codec with a given algorithm (gzip or
+// snappy) and decompresses values on unmarshal. Level is only used by gzip (0 means default compression). Values are
+// prefixed with a small header, so values stored without compression (or with another algorithm) are still readable
+func NewCompressedCodec(codec Codec, algorithm string, level int) (Codec, error) {
+	result := &compressedCodec{codec: codec, level: level}
+	switch algorithm {
+	case CompressionGzip:
+		result.algorithm = compressedGzip
+		if level == 0 {
+			result.level = gzip.DefaultCompression
+		}
+		if result.level < gzip.HuffmanOnly || result.level > gzip.BestCompression {
+			return nil, fmt.Errorf("invalid gzip compression level: %d", level)
+		}
+	case CompressionSnappy:
+		result.algorithm = compressedSnappy
+	default:
+		return nil, fmt.Errorf("unsupported compression algorithm: %s", algorithm)
+	}
+
+	return result, nil
+}
+
+func (c *compressedCodec) Marshal(value interface{}) ([]byte, error) {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := bytes.NewBuffer(make([]byte, 0, len(compressedMagic)+1+len(data)/2))
+	buffer.Write(compressedMagic)
+	buffer.WriteByte(c.algorithm)
+
+	switch c.algorithm {
+	case compressedGzip:
+		writer, gzipErr := gzip.NewWriterLevel(buffer, c.level)
+		if gzipErr != nil {
+			return nil, gzipErr
+		}
+		if _, err = writer.Write(data); err != nil {
+			return nil, err
+		}
+		if err = writer.Close(); err != nil {
+			return nil, err
+		}
+	case compressedSnappy:
+		buffer.Write(snappy.Encode(nil, data))
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func (c *compressedCodec) Unmarshal(data []byte, value interface{}) error {
+	if !bytes.HasPrefix(data, compressedMagic) || len(data) == len(compressedMagic) {
+		return c.codec.Unmarshal(data, value)
+	}
+
+	algorithm := data[len(compressedMagic)]
+	data = data[len(compressedMagic)+1:]
+
+	var err error
+	switch algorithm {
+	case compressedGzip:
+		reader, gzipErr := gzip.NewReader(bytes.NewReader(data))
+		if gzipErr != nil {
+			return fmt.Errorf("error while decompressing gzip value: %s", gzipErr)
+		}
+		data, err = ioutil.ReadAll(reader)
+	case compressedSnappy:
+		data, err = snappy.Decode(nil, data)
+	default:
+		return fmt.Errorf("value is compressed with unknown algorithm: %d", algorithm)
+	}
+	if err != nil {
+		return fmt.Errorf("error while decompressing value: %s", err)
+	}
+
+	return c.codec.Unmarshal(data, value)
+}
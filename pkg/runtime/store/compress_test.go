@@ -0,0 +1,53 @@
+package store_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressedCodec(t *testing.T) {
+	revision := &engine.Revision{
+		TypeKind:  engine.TypeRevision.GetTypeKind(),
+		Metadata:  runtime.GenerationMetadata{Generation: 1},
+		PolicyGen: 42,
+		Status:    strings.Repeat("waiting", 100),
+	}
+	plain, err := store.NewYAMLCodec().Marshal(revision)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	for _, algorithm := range []string{store.CompressionGzip, store.CompressionSnappy} {
+		codec, err := store.NewCompressedCodec(store.NewYAMLCodec(), algorithm, 0)
+		if !assert.NoError(t, err, "Codec with %s compression should be created", algorithm) {
+			continue
+		}
+
+		data, err := codec.Marshal(revision)
+		if !assert.NoError(t, err, "Object should be marshaled with %s compression", algorithm) {
+			continue
+		}
+		assert.True(t, len(data) < len(plain), "Object should be compressed with %s", algorithm)
+
+		decoded := &engine.Revision{}
+		if assert.NoError(t, codec.Unmarshal(data, decoded), "Object should be unmarshaled with %s compression", algorithm) {
+			assert.Equal(t, revision.PolicyGen, decoded.PolicyGen)
+			assert.Equal(t, revision.Status, decoded.Status)
+		}
+
+		decoded = &engine.Revision{}
+		if assert.NoError(t, codec.Unmarshal(plain, decoded), "Uncompressed object should be unmarshaled with %s compression", algorithm) {
+			assert.Equal(t, revision.Status, decoded.Status)
+		}
+	}
+
+	_, err = store.NewCompressedCodec(store.NewYAMLCodec(), "lz4", 0)
+	assert.Error(t, err, "Unsupported algorithm should be reported")
+	_, err = store.NewCompressedCodec(store.NewYAMLCodec(), store.CompressionGzip, 10)
+	assert.Error(t, err, "Invalid gzip compression level should be reported")
+}
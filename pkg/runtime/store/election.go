@@ -0,0 +1,23 @@
+package store
+
+import "context"
+
+// Elector is implemented by store backends, which could be shared by multiple server instances (e.g. etcd), so a
+// single leader could be elected among them to do work, which shouldn't be done concurrently (e.g. enforcement)
+type Elector interface {
+	NewElection(name string, identity string) (Election, error)
+}
+
+// Election elects a single leader among all participants of election with the same name
+type Election interface {
+	// Campaign blocks until this participant becomes the leader or a given context is cancelled. It returns context,
+	// which gets cancelled once leadership is lost (or a given context is cancelled), so the leader should stop its work
+	// right away. Leadership is given up once returned context is cancelled
+	Campaign(ctx context.Context) (context.Context, error)
+
+	// Leader returns identity of the current leader or empty string if there is no leader at the moment
+	Leader(ctx context.Context) (string, error)
+
+	// Identity returns identity of this participant
+	Identity() string
+}
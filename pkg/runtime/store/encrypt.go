@@ -0,0 +1,94 @@
+package store
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+)
+
+// encryptedMagic is the header of encrypted values followed by a single byte of key version. Neither YAML nor gob
+// output starts with zero byte, so values stored without encryption are read as is
+var encryptedMagic = []byte{0x00, 'E'}
+
+// EncryptionKey is an AES key (16, 24 or 32 bytes for AES-128, AES-192 or AES-256) along with its version, which is
+// stored with every encrypted value, so values encrypted with older keys could still be decrypted after key rotation
+type EncryptionKey struct {
+	Version byte
+	Key     []byte
+}
+
+type encryptedCodec struct {
+	codec   Codec
+	version byte
+	ciphers map[byte]cipher.AEAD
+}
+
+// NewEncryptedCodec returns store codec, which encrypts output of a given codec with AES-GCM using a given current key
+// and decrypts values on unmarshal. Values encrypted with previous keys are decrypted with them, so keys could be
+// rotated by making the current key previous and adding a new one. Values stored without encryption are still
+// readable, so encryption could be enabled for an existing installation
+func NewEncryptedCodec(codec Codec, current EncryptionKey, previous ...EncryptionKey) (Codec, error) {
+	result := &encryptedCodec{codec: codec, version: current.Version, ciphers: make(map[byte]cipher.AEAD)}
+	for _, key := range append([]EncryptionKey{current}, previous...) {
+		if _, exist := result.ciphers[key.Version]; exist {
+			return nil, fmt.Errorf("duplicate encryption key version: %d", key.Version)
+		}
+		block, err := aes.NewCipher(key.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key with version %d: %s", key.Version, err)
+		}
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key with version %d: %s", key.Version, err)
+		}
+		result.ciphers[key.Version] = gcm
+	}
+
+	return result, nil
+}
+
+func (c *encryptedCodec) Marshal(value interface{}) ([]byte, error) {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm := c.ciphers[c.version]
+	header := append(append([]byte{}, encryptedMagic...), c.version)
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("error while generating nonce: %s", err)
+	}
+
+	// header is authenticated along with the value, so key version can't be altered
+	result := make([]byte, 0, len(header)+len(nonce)+len(data)+gcm.Overhead())
+	result = append(append(result, header...), nonce...)
+	return gcm.Seal(result, nonce, data, header), nil
+}
+
+func (c *encryptedCodec) Unmarshal(data []byte, value interface{}) error {
+	if !bytes.HasPrefix(data, encryptedMagic) || len(data) == len(encryptedMagic) {
+		return c.codec.Unmarshal(data, value)
+	}
+
+	header := data[:len(encryptedMagic)+1]
+	version := header[len(encryptedMagic)]
+	gcm, exist := c.ciphers[version]
+	if !exist {
+		return fmt.Errorf("value is encrypted with unknown key version: %d", version)
+	}
+
+	data = data[len(header):]
+	if len(data) < gcm.NonceSize() {
+		return fmt.Errorf("encrypted value is truncated")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], header)
+	if err != nil {
+		return fmt.Errorf("error while decrypting value (it's corrupted or has been tampered with): %s", err)
+	}
+
+	return c.codec.Unmarshal(plain, value)
+}
@@ -0,0 +1,76 @@
+package store_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptedCodec(t *testing.T) {
+	revision := &engine.Revision{
+		TypeKind:  engine.TypeRevision.GetTypeKind(),
+		Metadata:  runtime.GenerationMetadata{Generation: 1},
+		PolicyGen: 42,
+		Status:    "secret-status",
+	}
+	oldKey := store.EncryptionKey{Version: 1, Key: bytes.Repeat([]byte{1}, 32)}
+	newKey := store.EncryptionKey{Version: 2, Key: bytes.Repeat([]byte{2}, 16)}
+
+	oldCodec, err := store.NewEncryptedCodec(store.NewYAMLCodec(), oldKey)
+	if !assert.NoError(t, err, "Codec with old key should be created") {
+		t.FailNow()
+	}
+	oldData, err := oldCodec.Marshal(revision)
+	if !assert.NoError(t, err, "Object should be encrypted with old key") {
+		t.FailNow()
+	}
+	assert.False(t, bytes.Contains(oldData, []byte(revision.Status)), "Object should be stored encrypted")
+
+	// rotate key: new key is used for writes, while old one is still used for reads
+	codec, err := store.NewEncryptedCodec(store.NewYAMLCodec(), newKey, oldKey)
+	if !assert.NoError(t, err, "Codec with rotated keys should be created") {
+		t.FailNow()
+	}
+	decoded := &engine.Revision{}
+	if assert.NoError(t, codec.Unmarshal(oldData, decoded), "Object encrypted with old key should be decrypted") {
+		assert.Equal(t, revision.Status, decoded.Status)
+	}
+
+	newData, err := codec.Marshal(revision)
+	if !assert.NoError(t, err, "Object should be encrypted with new key") {
+		t.FailNow()
+	}
+	assert.Error(t, oldCodec.Unmarshal(newData, &engine.Revision{}), "Object encrypted with new key shouldn't be decrypted without it")
+	decoded = &engine.Revision{}
+	if assert.NoError(t, codec.Unmarshal(newData, decoded), "Object encrypted with new key should be decrypted") {
+		assert.Equal(t, revision.PolicyGen, decoded.PolicyGen)
+		assert.Equal(t, revision.Status, decoded.Status)
+	}
+
+	// values stored before encryption was enabled should be readable
+	plain, err := store.NewYAMLCodec().Marshal(revision)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	decoded = &engine.Revision{}
+	if assert.NoError(t, codec.Unmarshal(plain, decoded), "Unencrypted object should be unmarshaled") {
+		assert.Equal(t, revision.Status, decoded.Status)
+	}
+
+	// any change of encrypted value including key version should be detected
+	for _, idx := range []int{2, 3, len(newData) / 2, len(newData) - 1} {
+		tampered := append([]byte{}, newData...)
+		tampered[idx] ^= 0xff
+		assert.Error(t, codec.Unmarshal(tampered, &engine.Revision{}), "Tampered byte %d should be detected", idx)
+	}
+	assert.Error(t, codec.Unmarshal(newData[:10], &engine.Revision{}), "Truncated value should be detected")
+
+	_, err = store.NewEncryptedCodec(store.NewYAMLCodec(), store.EncryptionKey{Version: 1, Key: []byte("short")})
+	assert.Error(t, err, "Invalid key length should be reported")
+	_, err = store.NewEncryptedCodec(store.NewYAMLCodec(), oldKey, oldKey)
+	assert.Error(t, err, "Duplicate key version should be reported")
+}
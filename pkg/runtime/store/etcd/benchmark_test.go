@@ -0,0 +1,146 @@
+package etcd_test
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/engine/enginetest"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/etcd"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/storetest"
+	"github.com/sirupsen/logrus"
+)
+
+func BenchmarkEtcdStoreSave(b *testing.B) {
+	etcdStore, instances := prepareEtcdBenchmark(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		instance := instances[i%len(instances)]
+		// make sure that every save actually writes data
+		instance.EndpointsUpToDate = !instance.EndpointsUpToDate
+		if _, err := etcdStore.Save(instance); err != nil {
+			b.Fatalf("component instance should be saved: %s", err)
+		}
+	}
+}
+
+// BenchmarkEtcdStoreSaveVersioned saves new generations of a few thousand versioned objects, so it shows the number of
+// round trips made by every save transaction (indexes and the object itself are read before writing)
+func BenchmarkEtcdStoreSaveVersioned(b *testing.B) {
+	etcdStore := etcd.NewTestStore(b, etcd.Config{}, storetest.Types(), store.NewYAMLCodec())
+	items := make([]*storetest.Item, 2000)
+	for idx := range items {
+		items[idx] = &storetest.Item{TypeKind: storetest.TypeItem.GetTypeKind(), Name: fmt.Sprintf("item-%d", idx), Status: "waiting"}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		item := items[i%len(items)]
+		// make sure that every save creates a new generation
+		item.Status = fmt.Sprintf("status-%d", i)
+		if _, err := etcdStore.Save(item); err != nil {
+			b.Fatalf("item should be saved: %s", err)
+		}
+	}
+}
+
+func BenchmarkEtcdStoreFind(b *testing.B) {
+	etcdStore, instances := prepareEtcdBenchmark(b)
+	for _, instance := range instances {
+		if _, err := etcdStore.Save(instance); err != nil {
+			b.Fatalf("component instance should be saved: %s", err)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var loaded []*resolve.ComponentInstance
+		err := etcdStore.Find(resolve.TypeComponentInstance.Kind, &loaded, store.WithKeyPrefix(runtime.SystemNS+"/"+resolve.TypeComponentInstance.Kind))
+		if err != nil {
+			b.Fatalf("component instances should be loaded: %s", err)
+		}
+		if len(loaded) != len(instances) {
+			b.Fatalf("%d component instances should be loaded, but found %d", len(instances), len(loaded))
+		}
+	}
+}
+
+// BenchmarkEtcdIndexListGenBytes reports the number of bytes written to list indexes per save of a new generation of
+// an object with several indexed fields, when lists of generations are stored as IndexValueList encoded with the codec
+// (as they used to be) and as IndexGenList, while the object gets 100 generations. It doesn't require etcd, as index
+// values are encoded the same way
+func BenchmarkEtcdIndexListGenBytes(b *testing.B) {
+	indexes := store.IndexesFor(storetest.TypeItem)
+	item := &storetest.Item{TypeKind: storetest.TypeItem.GetTypeKind(), Name: "item", Status: "waiting", Zone: "east", Rack: "1"}
+	codec := store.NewYAMLCodec()
+
+	encoders := []struct {
+		name   string
+		encode func(gens []runtime.Generation) []byte
+	}{
+		{"IndexValueList", func(gens []runtime.Generation) []byte {
+			valueList := &store.IndexValueList{}
+			for _, gen := range gens {
+				data := make([]byte, 8)
+				binary.BigEndian.PutUint64(data, uint64(gen))
+				valueList.Add(data)
+			}
+			data, err := codec.Marshal(valueList)
+			if err != nil {
+				b.Fatalf("index value list should be marshaled: %s", err)
+			}
+			return data
+		}},
+		{"IndexGenList", func(gens []runtime.Generation) []byte {
+			return store.IndexGenList(gens).Encode()
+		}},
+	}
+
+	const saves = 100
+	for _, encoder := range encoders {
+		b.Run(encoder.name, func(b *testing.B) {
+			written := 0
+			for i := 0; i < b.N; i++ {
+				// every save of a new generation with unchanged indexed fields rewrites the whole list in every list index
+				gens := make([]runtime.Generation, 0, saves)
+				for gen := runtime.FirstGen; gen <= saves; gen++ {
+					gens = append(gens, gen)
+					for _, index := range indexes.List {
+						if index.Type != store.IndexTypeListGen || index.NameForStorable(item, codec) == "" {
+							continue
+						}
+						written += len(encoder.encode(gens))
+					}
+				}
+			}
+			b.ReportMetric(float64(written)/float64(b.N*saves), "bytes/save")
+		})
+	}
+}
+
+// prepareEtcdBenchmark connects to etcd and returns component instances of the resolved small synthetic policy
+func prepareEtcdBenchmark(b *testing.B) (store.Interface, []*resolve.ComponentInstance) {
+	b.Helper()
+	etcdStore := etcd.NewTestStore(b, etcd.Config{}, runtime.NewTypes().Append(resolve.TypeComponentInstance), store.NewYAMLCodec())
+
+	synthetic := enginetest.NewSyntheticPolicy(enginetest.SyntheticPolicySmall)
+	resolution, resolveErr := resolve.NewPolicyResolver(synthetic.Policy, synthetic.External, event.NewLog(logrus.WarnLevel, "bench-resolve")).SetContinueOnError(true).ResolveAllClaims(context.Background())
+	if resolveErr != nil {
+		b.Fatalf("policy should be resolved without errors: %s", resolveErr)
+	}
+	instances := make([]*resolve.ComponentInstance, 0, len(resolution.ComponentInstanceMap))
+	for _, instance := range resolution.ComponentInstanceMap {
+		instances = append(instances, instance)
+	}
+
+	return etcdStore, instances
+}
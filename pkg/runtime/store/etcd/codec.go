@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
 )
 
 func (s *etcdStore) marshal(value interface{}) []byte {
@@ -32,3 +33,40 @@ func (s *etcdStore) marshalGen(generation runtime.Generation) string {
 func (s *etcdStore) unmarshalGen(data string) runtime.Generation {
 	return runtime.Generation(binary.BigEndian.Uint64([]byte(data)))
 }
+
+// marshalGenList returns value of IndexTypeListGen index entry for a given list of generations
+func (s *etcdStore) marshalGenList(genList store.IndexGenList) string {
+	return string(genList.Encode())
+}
+
+// unmarshalGenList returns list of generations from the value of IndexTypeListGen index entry. Entries written before
+// IndexGenList was introduced hold IndexValueList of marshaled generations encoded with the codec, so they are still
+// read and get converted to IndexGenList once the entry is updated
+func (s *etcdStore) unmarshalGenList(data string) store.IndexGenList {
+	genList, err := s.decodeGenList(data)
+	if err != nil {
+		panic(fmt.Sprintf("error while unmarshaling list of generations: %s", err))
+	}
+	return genList
+}
+
+// decodeGenList returns list of generations from the value of IndexTypeListGen index entry in either of the encodings
+// or error if the value can't be decoded
+func (s *etcdStore) decodeGenList(data string) (store.IndexGenList, error) {
+	if store.IsEncodedIndexGenList([]byte(data)) {
+		return store.DecodeIndexGenList([]byte(data))
+	}
+
+	valueList := &store.IndexValueList{}
+	if err := s.codec.Unmarshal([]byte(data), valueList); err != nil {
+		return nil, err
+	}
+	genList := make(store.IndexGenList, 0, len(*valueList))
+	for _, value := range *valueList {
+		if len(value) != 8 {
+			return nil, fmt.Errorf("invalid length of generation: %d", len(value))
+		}
+		genList.Add(s.unmarshalGen(string(value)))
+	}
+	return genList, nil
+}
@@ -0,0 +1,48 @@
+package etcd
+
+import (
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/storetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEtcdStoreGenListEncoding(t *testing.T) {
+	for _, codec := range []store.Codec{store.NewYAMLCodec(), store.NewGobCodec(storetest.Types())} {
+		s := &etcdStore{codec: codec}
+
+		genList := store.IndexGenList{1, 2, 42, 1 << 40}
+		assert.Equal(t, genList, s.unmarshalGenList(s.marshalGenList(genList)), "List of generations should be the same after round trip")
+
+		// index entries written before IndexGenList was introduced should still be read
+		valueList := &store.IndexValueList{}
+		for _, gen := range genList {
+			valueList.Add([]byte(s.marshalGen(gen)))
+		}
+		assert.Equal(t, genList, s.unmarshalGenList(string(s.marshal(valueList))), "Legacy list of generations should be read")
+	}
+}
+
+func TestEtcdStoreIndexEntryMatches(t *testing.T) {
+	s := &etcdStore{codec: store.NewYAMLCodec()}
+	entry := &store.IndexEntry{Type: store.IndexTypeListGen, Gens: []runtime.Generation{1, 2, 42}}
+	assert.True(t, s.indexEntryMatches(entry, s.encodeIndexEntry(entry)), "Encoded entry should match")
+
+	// the same list of generations in the legacy encoding should match as well
+	valueList := &store.IndexValueList{}
+	for _, gen := range entry.Gens {
+		valueList.Add([]byte(s.marshalGen(gen)))
+	}
+	assert.True(t, s.indexEntryMatches(entry, string(s.marshal(valueList))), "Legacy entry with the same generations should match")
+
+	valueList.Add([]byte(s.marshalGen(43)))
+	assert.False(t, s.indexEntryMatches(entry, string(s.marshal(valueList))), "Legacy entry with other generations should not match")
+	assert.False(t, s.indexEntryMatches(entry, s.marshalGenList(store.IndexGenList{1, 2})), "Entry with missing generations should not match")
+	assert.False(t, s.indexEntryMatches(entry, "corrupted"), "Corrupted entry should not match")
+
+	lastGen := &store.IndexEntry{Type: store.IndexTypeLastGen, Gens: []runtime.Generation{42}}
+	assert.True(t, s.indexEntryMatches(lastGen, s.marshalGen(42)), "Last generation should match")
+	assert.False(t, s.indexEntryMatches(lastGen, s.marshalGen(1)), "Other generation should not match")
+}
@@ -0,0 +1,170 @@
+package etcd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	etcd "github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/etcdserver/api/v3rpc/rpctypes"
+	log "github.com/sirupsen/logrus"
+)
+
+// compactor periodically removes old generations of versioned objects and compacts etcd history, so the keyspace
+// doesn't grow unbounded
+type compactor struct {
+	store *etcdStore
+	cfg   CompactorConfig
+
+	// etcd revisions observed on every run, oldest first, used to find the revision history could be compacted to
+	observed []observedRevision
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type observedRevision struct {
+	at       time.Time
+	revision int64
+}
+
+func newCompactor(s *etcdStore, cfg CompactorConfig) (*compactor, error) {
+	if cfg.KeepLast < 0 {
+		return nil, fmt.Errorf("number of generations to keep can't be negative: %d", cfg.KeepLast)
+	}
+	for _, kind := range cfg.Kinds {
+		info, exist := s.types.Kinds[kind]
+		if !exist {
+			return nil, fmt.Errorf("can't compact generations of unknown kind %s", kind)
+		}
+		if !info.Versioned {
+			return nil, fmt.Errorf("can't compact generations of non versioned kind %s", kind)
+		}
+	}
+
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultCompactionInterval
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = defaultCompactionRetention
+	}
+
+	return &compactor{
+		store: s,
+		cfg:   cfg,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}, nil
+}
+
+func (c *compactor) start() {
+	go func() {
+		defer close(c.done)
+
+		ticker := time.NewTicker(c.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				if err := c.compact(time.Now()); err != nil {
+					log.Warnf("Error while compacting etcd store: %s", err)
+				}
+			}
+		}
+	}()
+}
+
+// close stops compactor and waits for the current run to finish
+func (c *compactor) close() {
+	close(c.stop)
+	<-c.done
+}
+
+func (c *compactor) compact(now time.Time) error {
+	if c.cfg.KeepLast > 0 {
+		for _, kind := range c.cfg.Kinds {
+			if err := c.compactGenerations(kind); err != nil {
+				return fmt.Errorf("error while compacting generations of kind %s: %s", kind, err)
+			}
+		}
+	}
+
+	if err := c.compactHistory(now); err != nil {
+		return fmt.Errorf("error while compacting history: %s", err)
+	}
+
+	return nil
+}
+
+// compactGenerations removes old generations of all objects of a given kind, which are listed using last gen index
+func (c *compactor) compactGenerations(kind runtime.Kind) error {
+	info := c.store.types.Get(kind)
+	indexPrefix := "/index/" + store.IndexesFor(info).NameForValue(store.LastGenIndex, "", nil, c.store.codec)
+	ctx, cancel := c.store.newContext()
+	defer cancel()
+
+	resp, err := c.store.client.KV.Get(ctx, indexPrefix, etcd.WithPrefix(), etcd.WithKeysOnly())
+	if err != nil {
+		return c.store.wrapError(ctx, err)
+	}
+
+	for _, kv := range resp.Kvs {
+		key := strings.TrimPrefix(string(kv.Key), indexPrefix)
+		// keys start with namespace, so kind should be checked for every key
+		if parts := strings.SplitN(key, runtime.KeySeparator, 3); len(parts) < 2 || parts[1] != kind {
+			continue
+		}
+		removed, errCompact := c.store.Compact(kind, key, c.cfg.KeepLast, nil)
+		if errCompact != nil {
+			return errCompact
+		}
+		if len(removed) > 0 {
+			log.Infof("Compacted %d old generations of %s", len(removed), key)
+		}
+	}
+
+	return nil
+}
+
+// compactHistory compacts etcd history up to the latest revision observed at least Retention ago
+func (c *compactor) compactHistory(now time.Time) error {
+	ctx, cancel := c.store.newContext()
+	defer cancel()
+
+	resp, err := c.store.client.KV.Get(ctx, "/", etcd.WithCountOnly())
+	if err != nil {
+		return c.store.wrapError(ctx, err)
+	}
+	c.observed = append(c.observed, observedRevision{at: now, revision: resp.Header.Revision})
+
+	idx := -1
+	for i, observed := range c.observed {
+		if now.Sub(observed.at) < c.cfg.Retention {
+			break
+		}
+		idx = i
+	}
+	if idx < 0 {
+		return nil
+	}
+
+	revision := c.observed[idx].revision
+	c.observed = c.observed[idx+1:]
+	_, err = c.store.client.KV.Compact(ctx, revision)
+	if err == rpctypes.ErrCompacted {
+		// history was already compacted past this revision by someone else (e.g. other server or etcd itself)
+		log.Debugf("Etcd history is already compacted past revision %d", revision)
+		return nil
+	}
+	if err != nil {
+		return c.store.wrapError(ctx, err)
+	}
+	log.Infof("Compacted etcd history up to revision %d", revision)
+
+	return nil
+}
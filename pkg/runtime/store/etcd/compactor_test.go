@@ -0,0 +1,67 @@
+package etcd
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/storetest"
+	etcd "github.com/coreos/etcd/clientv3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEtcdStoreCompactor(t *testing.T) {
+	cfg := Config{
+		Compactor: CompactorConfig{
+			Interval:  time.Hour,
+			KeepLast:  2,
+			Kinds:     []string{storetest.TypeItem.Kind},
+			Retention: time.Minute,
+		},
+	}
+	types := storetest.Types()
+	s := newTestStore(t, cfg, types, store.NewGobCodec(types))
+	// compactor should be stopped on close
+	defer s.Close() // nolint: errcheck
+
+	impl := s.(*etcdStore) // nolint: errcheck
+	for rack := 1; rack <= 5; rack++ {
+		_, err := s.Save(newTestItem("waiting", strconv.Itoa(rack)))
+		if !assert.NoError(t, err, "Object should be saved") {
+			t.FailNow()
+		}
+	}
+
+	now := time.Now()
+	assert.NoError(t, impl.compactor.compact(now), "Compaction should succeed")
+
+	resp, err := impl.client.KV.Get(context.TODO(), "/object"+"/"+testItemKey+"@", etcd.WithPrefix(), etcd.WithKeysOnly())
+	assert.NoError(t, err, "Generations should be listed")
+	assert.Len(t, resp.Kvs, 2, "Only last generations should be kept")
+
+	var items []*storetest.Item
+	err = s.Find(storetest.TypeItem.Kind, &items, store.WithKey(testItemKey), store.WithWhereEq("Status", "waiting"))
+	assert.NoError(t, err, "Generations should be found by index")
+	if assert.Len(t, items, 2, "Removed generations should be removed from indexes") {
+		assert.EqualValues(t, 4, items[0].GetGeneration())
+		assert.EqualValues(t, 5, items[1].GetGeneration())
+	}
+
+	// history is compacted only when revision observed more than retention ago
+	assert.Len(t, impl.compactor.observed, 1, "Revision should be observed")
+	assert.NoError(t, impl.compactor.compact(now.Add(2*time.Minute)), "Compaction should succeed")
+	assert.Len(t, impl.compactor.observed, 1, "Revision observed before retention should be compacted")
+
+	// history already compacted past the revision (e.g. by other server) is tolerated
+	impl.compactor.observed = []observedRevision{{at: now, revision: 1}}
+	assert.NoError(t, impl.compactor.compact(now.Add(4*time.Minute)), "Already compacted history should be tolerated")
+
+	_, err = newCompactor(impl, CompactorConfig{Interval: time.Hour, Kinds: []string{storetest.TypeItem.Kind, "unknown"}})
+	assert.Error(t, err, "Unknown kind should be rejected")
+
+	disabled := newTestStore(t, Config{Compactor: CompactorConfig{Disabled: true}}, types, store.NewGobCodec(types))
+	assert.Nil(t, disabled.(*etcdStore).compactor, "Compactor should be disabled")
+	assert.NoError(t, disabled.Close(), "Etcd store should be closed")
+}
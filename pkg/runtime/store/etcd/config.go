@@ -1,7 +1,13 @@
 package etcd
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
 	"time"
+
+	log "github.com/sirupsen/logrus"
 )
 
 var (
@@ -9,11 +15,153 @@ var (
 	keepaliveTime    = 30 * time.Second
 	keepaliveTimeout = 10 * time.Second
 	dialTimeout      = 10 * time.Second
+
+	// defaultTimeout is a timeout of a single store operation used if it isn't set in config
+	defaultTimeout = 30 * time.Second
+
+	// defaultCompactionInterval and defaultCompactionRetention are used by compactor if they aren't set in config
+	defaultCompactionInterval  = 5 * time.Minute
+	defaultCompactionRetention = time.Hour
+
+	// defaultElectionTTL is used by leader election if it isn't set in config
+	defaultElectionTTL = 15 * time.Second
+
+	// defaultMaxTxnOps is the default limit of operations in a single transaction of etcd server (--max-txn-ops)
+	defaultMaxTxnOps = 128
 )
 
 // Config represents etcdv3 store configuration
 type Config struct {
 	Prefix    string
 	Endpoints []string
-	// todo add tls config and auth for etcd
+	Compactor CompactorConfig
+	// Debug enables logging of all store queries at debug level
+	Debug bool
+	// Logger is used to log all store queries (along with their duration and number of found objects) at debug level
+	// instead of the standalone logger created if Debug is set. Nothing is logged if none of them is set
+	Logger *log.Entry `yaml:"-" mapstructure:"-"`
+
+	// Timeout of a single store operation (e.g. Save or Find), so they don't block forever if etcd isn't available.
+	// If not set, 30s is used
+	Timeout time.Duration
+
+	// ElectionTTL is how long leader keeps leadership after it stops responding (e.g. crashes or loses connection to
+	// etcd), so another instance could become the leader. If not set, 15s is used
+	ElectionTTL time.Duration
+
+	// MaxTxnOps is the max number of operations etcd server accepts in a single transaction, it should match its
+	// --max-txn-ops flag. Batches saved by SaveMany, which don't fit into it, are split into several transactions.
+	// If not set, 128 is used
+	MaxTxnOps int
+
+	TLS TLSConfig
+
+	// Username and Password are used to authenticate in etcd, if it has authentication enabled
+	Username string
+	Password string
+}
+
+// TLSConfig represents TLS options for connecting to etcd. Certificates and keys could be provided either as paths
+// to files or as PEM encoded data directly (e.g. when they're passed through environment)
+type TLSConfig struct {
+	// CAFile is a file with PEM encoded CA certificates to verify etcd server certificate with. If not set, system
+	// CA certificates are used
+	CAFile string
+	// CA is PEM encoded CA certificates, alternative to CAFile
+	CA string
+
+	// CertFile and KeyFile are files with PEM encoded client certificate and key, if etcd requires client
+	// certificate authentication
+	CertFile string
+	KeyFile  string
+	// Cert and Key are PEM encoded client certificate and key, alternative to CertFile and KeyFile
+	Cert string
+	Key  string
+
+	// InsecureSkipVerify disables verification of etcd server certificate. It should only be used for testing
+	InsecureSkipVerify bool
+}
+
+// IsEnabled returns true if any of TLS options are set, so etcd should be connected over TLS
+func (cfg TLSConfig) IsEnabled() bool {
+	return len(cfg.CAFile) > 0 || len(cfg.CA) > 0 || len(cfg.CertFile) > 0 || len(cfg.KeyFile) > 0 ||
+		len(cfg.Cert) > 0 || len(cfg.Key) > 0 || cfg.InsecureSkipVerify
+}
+
+// Build loads certificates and creates tls.Config. It returns nil if TLS isn't enabled
+func (cfg TLSConfig) Build() (*tls.Config, error) {
+	if !cfg.IsEnabled() {
+		return nil, nil
+	}
+
+	caData, err := loadPEM("CA", cfg.CAFile, cfg.CA)
+	if err != nil {
+		return nil, err
+	}
+	certData, err := loadPEM("client certificate", cfg.CertFile, cfg.Cert)
+	if err != nil {
+		return nil, err
+	}
+	keyData, err := loadPEM("client key", cfg.KeyFile, cfg.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify} // nolint: gas
+	if caData != nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("no certificates found in etcd CA")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if certData != nil || keyData != nil {
+		if certData == nil || keyData == nil {
+			return nil, fmt.Errorf("both etcd client certificate and key should be set")
+		}
+		cert, err := tls.X509KeyPair(certData, keyData)
+		if err != nil {
+			return nil, fmt.Errorf("can't load etcd client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// loadPEM returns PEM data either read from file or provided directly. It returns nil if none of them are set
+func loadPEM(name string, file string, data string) ([]byte, error) {
+	if len(file) > 0 && len(data) > 0 {
+		return nil, fmt.Errorf("only one of etcd %s file and data should be set", name)
+	}
+	if len(data) > 0 {
+		return []byte(data), nil
+	}
+	if len(file) == 0 {
+		return nil, nil
+	}
+
+	result, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("can't read etcd %s file: %s", name, err)
+	}
+
+	return result, nil
+}
+
+// CompactorConfig represents configuration of the background compactor, which bounds growth of the etcd keyspace and
+// its history. It's enabled by default and compacts etcd history only
+type CompactorConfig struct {
+	// Disabled turns compactor off entirely, e.g. for shared etcd clusters, which are compacted by someone else
+	Disabled bool
+	// Interval is how often compaction runs. If not set, 5m is used
+	Interval time.Duration
+	// KeepLast is the number of last generations kept for every versioned object of Kinds
+	KeepLast int
+	// Kinds is the list of versioned kinds, which old generations are removed. Only kinds, which old generations aren't
+	// referenced by other objects, should be listed here (e.g. policy objects are referenced by policy generations, so
+	// they should be compacted by registry instead)
+	Kinds []string
+	// Retention is how long etcd keeps history of changes (previous values of keys). If not set, 1h is used
+	Retention time.Duration
 }
@@ -0,0 +1,158 @@
+package etcd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/coreos/etcd/etcdserver/api/v3rpc/rpctypes"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfigParsing(t *testing.T) {
+	data := `
+endpoints:
+  - etcd-0:2379
+  - etcd-1:2379
+tls:
+  cafile: /etc/etcd/ca.pem
+  certfile: /etc/etcd/client.pem
+  keyfile: /etc/etcd/client-key.pem
+username: aptomi
+password: secret
+`
+	cfg := Config{}
+	if !assert.NoError(t, yaml.Unmarshal([]byte(data), &cfg), "Config should be parsed") {
+		t.FailNow()
+	}
+	assert.Equal(t, []string{"etcd-0:2379", "etcd-1:2379"}, cfg.Endpoints)
+	assert.Equal(t, "/etc/etcd/ca.pem", cfg.TLS.CAFile)
+	assert.Equal(t, "/etc/etcd/client.pem", cfg.TLS.CertFile)
+	assert.Equal(t, "/etc/etcd/client-key.pem", cfg.TLS.KeyFile)
+	assert.True(t, cfg.TLS.IsEnabled(), "TLS should be enabled")
+	assert.Equal(t, "aptomi", cfg.Username)
+	assert.Equal(t, "secret", cfg.Password)
+
+	tlsConfig, err := Config{}.TLS.Build()
+	assert.NoError(t, err, "Empty TLS config should be valid")
+	assert.Nil(t, tlsConfig, "TLS should be disabled by default")
+
+	tlsConfig, err = TLSConfig{InsecureSkipVerify: true}.Build()
+	if assert.NoError(t, err, "TLS config should be built") && assert.NotNil(t, tlsConfig, "TLS should be enabled") {
+		assert.True(t, tlsConfig.InsecureSkipVerify, "Server certificate verification should be skipped")
+	}
+}
+
+func TestConfigInvalidTLS(t *testing.T) {
+	types := runtime.NewTypes()
+
+	_, err := New(Config{TLS: TLSConfig{CAFile: "/non-existing/ca.pem"}}, types, store.NewYAMLCodec())
+	if assert.Error(t, err, "Missing CA file should be reported") {
+		assert.Contains(t, err.Error(), "can't read etcd CA file")
+	}
+
+	dir, err := ioutil.TempDir("", "etcd-tls")
+	if !assert.NoError(t, err, "Temp dir should be created") {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	caFile := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, ioutil.WriteFile(caFile, []byte("not a certificate"), 0600))
+	_, err = New(Config{TLS: TLSConfig{CAFile: caFile}}, types, store.NewYAMLCodec())
+	if assert.Error(t, err, "Invalid CA file should be reported") {
+		assert.Contains(t, err.Error(), "no certificates found")
+	}
+
+	_, err = New(Config{TLS: TLSConfig{CertFile: filepath.Join(dir, "client.pem")}}, types, store.NewYAMLCodec())
+	assert.Error(t, err, "Missing client certificate should be reported")
+
+	_, err = New(Config{Username: "aptomi"}, types, store.NewYAMLCodec())
+	assert.Error(t, err, "Username without password should be reported")
+}
+
+func TestConfigAuthError(t *testing.T) {
+	err := authError("aptomi", rpctypes.ErrGRPCAuthFailed)
+	if assert.Error(t, err, "Failed authentication should be reported") {
+		assert.Contains(t, err.Error(), "authentication in etcd as user 'aptomi' failed")
+	}
+
+	err = authError("aptomi", rpctypes.ErrPermissionDenied)
+	if assert.Error(t, err, "Missing permissions should be reported") {
+		assert.Contains(t, err.Error(), "isn't permitted")
+	}
+
+	assert.NoError(t, authError("aptomi", context.DeadlineExceeded), "Other errors shouldn't be treated as auth errors")
+	assert.NoError(t, authError("aptomi", nil))
+}
+
+// generateCert generates PEM encoded self-signed certificate and its key
+func generateCert(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.NoError(t, err, "Key should be generated") {
+		t.FailNow()
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "etcd"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if !assert.NoError(t, err, "Certificate should be generated") {
+		t.FailNow()
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if !assert.NoError(t, err, "Key should be marshaled") {
+		t.FailNow()
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestConfigTLSData(t *testing.T) {
+	cert, key := generateCert(t)
+
+	tlsConfig, err := TLSConfig{CA: cert, Cert: cert, Key: key}.Build()
+	if assert.NoError(t, err, "TLS config should be built from PEM data") && assert.NotNil(t, tlsConfig, "TLS should be enabled") {
+		assert.NotNil(t, tlsConfig.RootCAs, "CA should be loaded")
+		assert.Len(t, tlsConfig.Certificates, 1, "Client certificate should be loaded")
+	}
+
+	dir, err := ioutil.TempDir("", "etcd-tls")
+	if !assert.NoError(t, err, "Temp dir should be created") {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	certFile := filepath.Join(dir, "client.pem")
+	assert.NoError(t, ioutil.WriteFile(certFile, []byte(cert), 0600))
+	tlsConfig, err = TLSConfig{CAFile: certFile, CertFile: certFile, Key: key}.Build()
+	if assert.NoError(t, err, "TLS config should be built from mix of files and PEM data") {
+		assert.Len(t, tlsConfig.Certificates, 1, "Client certificate should be loaded")
+	}
+
+	_, err = TLSConfig{CAFile: certFile, CA: cert}.Build()
+	assert.Error(t, err, "Both CA file and data should be reported")
+
+	_, err = TLSConfig{Cert: cert}.Build()
+	if assert.Error(t, err, "Client certificate without key should be reported") {
+		assert.Contains(t, err.Error(), "both etcd client certificate and key should be set")
+	}
+}
@@ -0,0 +1,89 @@
+package etcd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	etcd "github.com/coreos/etcd/clientv3"
+	etcdconc "github.com/coreos/etcd/clientv3/concurrency"
+	log "github.com/sirupsen/logrus"
+)
+
+// etcdStore implements store.Elector
+var _ store.Elector = &etcdStore{}
+
+type election struct {
+	store    *etcdStore
+	prefix   string
+	identity string
+}
+
+// NewElection returns leader election with a given name, which this store participates in with a given identity.
+// Election keys are stored under the store prefix, so only servers sharing the same store compete for leadership
+func (s *etcdStore) NewElection(name string, identity string) (store.Election, error) {
+	if len(name) == 0 || len(identity) == 0 {
+		return nil, fmt.Errorf("both election name and identity are required")
+	}
+
+	return &election{
+		store:    s,
+		prefix:   "/election/" + name,
+		identity: identity,
+	}, nil
+}
+
+func (e *election) Identity() string {
+	return e.identity
+}
+
+func (e *election) Campaign(ctx context.Context) (context.Context, error) {
+	// session keeps lease alive while this instance is alive and connected, so leadership is lost once it isn't
+	session, err := etcdconc.NewSession(e.store.client, etcdconc.WithTTL(int(e.store.electionTTL.Seconds())), etcdconc.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("error while creating etcd session: %s", e.store.wrapError(ctx, err))
+	}
+
+	leaderElection := etcdconc.NewElection(session, e.prefix)
+	err = leaderElection.Campaign(ctx, e.identity)
+	if err != nil {
+		session.Close() // nolint: errcheck
+		return nil, fmt.Errorf("error while campaigning in election %s: %s", e.prefix, e.store.wrapError(ctx, err))
+	}
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-session.Done():
+			log.Warnf("Leadership in election %s has been lost by %s", e.prefix, e.identity)
+		case <-leaderCtx.Done():
+		}
+		cancel()
+
+		// resign explicitly, so another instance doesn't have to wait for the lease to expire
+		resignCtx, resignCancel := e.store.newContext()
+		defer resignCancel()
+		if resignErr := leaderElection.Resign(resignCtx); resignErr != nil {
+			log.Warnf("Error while resigning from election %s: %s", e.prefix, resignErr)
+		}
+		session.Close() // nolint: errcheck
+	}()
+
+	return leaderCtx, nil
+}
+
+func (e *election) Leader(ctx context.Context) (string, error) {
+	opCtx, cancel := context.WithTimeout(ctx, e.store.timeout)
+	defer cancel()
+
+	// the leader is the participant with the oldest key, which is how etcd election picks the leader
+	resp, err := e.store.client.KV.Get(opCtx, e.prefix+"/", etcd.WithFirstCreate()...)
+	if err != nil {
+		return "", e.store.wrapError(opCtx, err)
+	}
+	if len(resp.Kvs) == 0 {
+		return "", nil
+	}
+
+	return string(resp.Kvs[0].Value), nil
+}
@@ -1,8 +1,9 @@
 package etcd_test
 
 import (
+	"bytes"
+	"io/ioutil"
 	"os"
-	"strings"
 	"testing"
 
 	"github.com/Aptomi/aptomi/pkg/engine"
@@ -10,23 +11,16 @@ import (
 	"github.com/Aptomi/aptomi/pkg/runtime"
 	"github.com/Aptomi/aptomi/pkg/runtime/store"
 	"github.com/Aptomi/aptomi/pkg/runtime/store/etcd"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/storetest"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestEtcdStoreBaseFunctionality(t *testing.T) {
-	// todo create helper in future that'll allow to run any number of tests in parallel - some random constant generated on start + test name as prefix
-	endpoints := os.Getenv("APTOMI_TEST_DB_ENDPOINTS")
-	if endpoints == "" {
-		endpoints = "127.0.0.1:2379"
-	}
-	cfg := etcd.Config{
-		Prefix:    t.Name(),
-		Endpoints: strings.Split(endpoints, ","),
-	}
 	// todo test with all codecs
-	etcdStore, err := etcd.New(cfg, runtime.NewTypes().Append(engine.TypeRevision, resolve.TypeComponentInstance), store.NewGobCodec())
-	assert.NoError(t, err)
-	assert.NotNil(t, etcdStore)
+	types := runtime.NewTypes().Append(engine.TypeRevision, resolve.TypeComponentInstance)
+	etcdStore := etcd.NewTestStore(t, etcd.Config{}, types, store.NewGobCodec(types))
+	defer etcdStore.Close() // nolint: errcheck
 
 	revision := &engine.Revision{
 		TypeKind: engine.TypeRevision.GetTypeKind(),
@@ -37,8 +31,7 @@ func TestEtcdStoreBaseFunctionality(t *testing.T) {
 		Status:    engine.RevisionStatusWaiting,
 	}
 
-	var changed bool
-	changed, err = etcdStore.Save(revision)
+	changed, err := etcdStore.Save(revision)
 	assert.NoError(t, err)
 	assert.True(t, changed)
 	assert.EqualValues(t, revision.GetGeneration(), 1)
@@ -76,8 +69,7 @@ func TestEtcdStoreBaseFunctionality(t *testing.T) {
 	assert.Equal(t, revision, loadedRevisionBySpecificGen)
 
 	err = etcdStore.Find(engine.TypeRevision.Kind, &loadedRevisionBySpecificGen, store.WithKey(engine.RevisionKey), store.WithGen(42))
-	assert.NoError(t, err)
-	assert.Nil(t, loadedRevisionBySpecificGen)
+	assert.True(t, store.IsGenNotFound(err), "Missing generation should be reported")
 
 	compInstance := &resolve.ComponentInstance{
 		TypeKind: resolve.TypeComponentInstance.GetTypeKind(),
@@ -93,3 +85,50 @@ func TestEtcdStoreBaseFunctionality(t *testing.T) {
 	assert.NoError(t, err)
 	assert.False(t, changed)
 }
+
+func TestEtcdStoreConformance(t *testing.T) {
+	// conformance tests expect empty store, which is guaranteed by the prefix unique for every store
+	storetest.RunConformanceTests(t, func(t *testing.T, types *runtime.Types) store.Interface {
+		return etcd.NewTestStore(t, etcd.Config{}, types, store.NewGobCodec(types))
+	})
+}
+
+func TestEtcdStoreFindLogging(t *testing.T) {
+	var logged bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &logged
+	logger.Level = logrus.DebugLevel
+	types := runtime.NewTypes().Append(engine.TypeRevision)
+	etcdStore := etcd.NewTestStore(t, etcd.Config{Logger: logrus.NewEntry(logger)}, types, store.NewGobCodec(types))
+	defer etcdStore.Close() // nolint: errcheck
+
+	_, err := etcdStore.Save(engine.NewRevision(0, 1, false))
+	assert.NoError(t, err)
+
+	// capture stdout to make sure find doesn't print anything
+	stdout := os.Stdout
+	reader, writer, err := os.Pipe()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	os.Stdout = writer
+
+	var revision *engine.Revision
+	err = etcdStore.Find(engine.TypeRevision.Kind, &revision, store.WithKey(engine.RevisionKey))
+
+	os.Stdout = stdout
+	assert.NoError(t, writer.Close())
+	printed, _ := ioutil.ReadAll(reader)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, revision)
+	assert.Empty(t, string(printed), "Nothing should be written to stdout during find")
+	assert.Contains(t, logged.String(), "found=1", "Find should be logged with number of found objects")
+	assert.Contains(t, logged.String(), "duration=", "Find should be logged with its duration")
+
+	var wrongType *resolve.ComponentInstance
+	err = etcdStore.Find(engine.TypeRevision.Kind, &wrongType, store.WithKey(engine.RevisionKey))
+	if assert.Error(t, err, "Mismatched result type should be reported") {
+		assert.Contains(t, err.Error(), engine.TypeRevision.Kind, "Error should include the kind being queried")
+	}
+}
@@ -0,0 +1,4 @@
+package etcd
+
+// NewTestStore exposes newTestStore to tests of etcd_test package
+var NewTestStore = newTestStore
@@ -0,0 +1,66 @@
+package etcd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/storetest"
+	"github.com/stretchr/testify/assert"
+)
+
+// kindMismatchCodec decodes items as objects of another kind once broken is set
+type kindMismatchCodec struct {
+	store.Codec
+	broken bool
+}
+
+func (c *kindMismatchCodec) Unmarshal(data []byte, value interface{}) error {
+	err := c.Codec.Unmarshal(data, value)
+	if item, ok := value.(*storetest.Item); ok && c.broken {
+		item.Kind = storetest.TypeNote.Kind
+	}
+	return err
+}
+
+func TestValidateFoundElem(t *testing.T) {
+	item := newTestItem("waiting", "")
+	assert.NoError(t, validateFoundElem(storetest.TypeItem.Kind, item, reflect.TypeOf(item)))
+
+	err := validateFoundElem(storetest.TypeItem.Kind, &storetest.Note{TypeKind: storetest.TypeNote.GetTypeKind(), Name: "note"}, reflect.TypeOf(item))
+	if assert.Error(t, err, "Object of wrong type should be reported") {
+		assert.Contains(t, err.Error(), "*storetest.Note")
+		assert.Contains(t, err.Error(), "*storetest.Item")
+	}
+}
+
+func TestEtcdStoreFindValidatesResult(t *testing.T) {
+	types := storetest.Types()
+	codec := &kindMismatchCodec{Codec: store.NewGobCodec(types)}
+	s := newTestStore(t, Config{}, types, codec)
+	defer s.Close() // nolint: errcheck
+
+	for _, rack := range []string{"1", "2"} {
+		_, err := s.Save(newTestItem("waiting", rack))
+		if !assert.NoError(t, err, "Object should be saved") {
+			t.FailNow()
+		}
+	}
+
+	// single result requested, while two generations match
+	var item *storetest.Item
+	err := s.Find(storetest.TypeItem.Kind, &item, store.WithKey(testItemKey), store.WithWhereEq("Status", "waiting"))
+	if assert.Error(t, err, "Multiple objects found for single result should be reported") {
+		assert.Contains(t, err.Error(), "more than one object of kind conformance-item found")
+	}
+
+	codec.broken = true
+	err = s.Find(storetest.TypeItem.Kind, &item, store.WithKey(testItemKey))
+	if assert.Error(t, err, "Object of wrong kind should be reported") {
+		assert.Contains(t, err.Error(), "of kind conformance-item has kind conformance-note")
+	}
+
+	var items []*storetest.Item
+	err = s.Find(storetest.TypeItem.Kind, &items, store.WithKey(testItemKey), store.WithWhereEq("Status", "waiting"))
+	assert.Error(t, err, "Object of wrong kind should be reported for list results")
+}
@@ -0,0 +1,50 @@
+package etcd
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/storetest"
+	"github.com/stretchr/testify/assert"
+)
+
+// testEndpoints returns endpoints of etcd used by integration tests, which could be set by APTOMI_TEST_DB_ENDPOINTS
+func testEndpoints() []string {
+	endpoints := os.Getenv("APTOMI_TEST_DB_ENDPOINTS")
+	if endpoints == "" {
+		endpoints = "127.0.0.1:2379"
+	}
+	return strings.Split(endpoints, ",")
+}
+
+// newTestStore creates etcd store with a given config, types and codec for integration test, which gets skipped in
+// short mode. Config gets test endpoints and a prefix unique for every call, so tests never see objects left by
+// previous runs. Caller is responsible for closing the store
+func newTestStore(tb testing.TB, cfg Config, types *runtime.Types, codec store.Codec) store.Interface {
+	tb.Helper()
+	if testing.Short() {
+		tb.Skip("skipping etcd integration test in short mode")
+	}
+
+	cfg.Endpoints = testEndpoints()
+	cfg.Prefix = tb.Name() + "-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	s, err := New(cfg, types, codec)
+	if !assert.NoError(tb, err, "Etcd store should be created") {
+		tb.FailNow()
+	}
+	return s
+}
+
+// testItemKey is a key of the versioned object saved by integration tests
+var testItemKey = runtime.KeyFromParts(runtime.SystemNS, storetest.TypeItem.Kind, "item")
+
+// newTestItem creates versioned object with a given status and rack, which is saved by integration tests under
+// testItemKey. Objects with different racks get different generations
+func newTestItem(status string, rack string) *storetest.Item {
+	return &storetest.Item{TypeKind: storetest.TypeItem.GetTypeKind(), Name: "item", Status: status, Rack: rack}
+}
@@ -0,0 +1,83 @@
+package etcd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/storetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEtcdStoreRebuildIndexes(t *testing.T) {
+	types := storetest.Types()
+	codec := store.NewGobCodec(types)
+	s := newTestStore(t, Config{}, types, codec)
+	defer s.Close() // nolint: errcheck
+
+	etcdS := s.(*etcdStore) // nolint: errcheck
+	for _, status := range []string{"waiting", "running", "done"} {
+		_, err := s.Save(newTestItem(status, ""))
+		if !assert.NoError(t, err, "Object should be saved") {
+			t.FailNow()
+		}
+	}
+
+	// corrupt indexes: drop list of generations, point last gen to the wrong generation and add a dangling entry
+	indexes := store.IndexesFor(storetest.TypeItem)
+	missing := indexes.NameForValue("Status", testItemKey, "waiting", codec)
+	divergent := indexes.NameForValue(store.LastGenIndex, testItemKey, nil, codec)
+	dangling := indexes.NameForValue("Status", testItemKey, "error", codec)
+	danglingList := &store.IndexValueList{}
+	danglingList.Add([]byte(etcdS.marshalGen(42)))
+	legacy := indexes.NameForValue("Status", testItemKey, "done", codec)
+	legacyList := &store.IndexValueList{}
+	legacyList.Add([]byte(etcdS.marshalGen(3)))
+	_, err := etcdS.client.KV.Delete(context.TODO(), "/index/"+missing)
+	assert.NoError(t, err)
+	_, err = etcdS.client.KV.Put(context.TODO(), "/index/"+divergent, etcdS.marshalGen(1))
+	assert.NoError(t, err)
+	_, err = etcdS.client.KV.Put(context.TODO(), "/index/"+dangling, string(etcdS.marshal(danglingList)))
+	assert.NoError(t, err)
+
+	// list of generations in the legacy encoding is consistent and shouldn't be reported
+	_, err = etcdS.client.KV.Put(context.TODO(), "/index/"+legacy, string(etcdS.marshal(legacyList)))
+	assert.NoError(t, err)
+
+	expected := &store.IndexRebuildResult{
+		Added:     1,
+		Updated:   1,
+		Removed:   1,
+		Missing:   []string{missing},
+		Divergent: []string{divergent},
+		Dangling:  []string{dangling},
+	}
+
+	// dry-run reports inconsistencies without repairing them
+	result, err := s.RebuildIndexes(storetest.TypeItem.Kind, true)
+	assert.NoError(t, err)
+	expected.DryRun = true
+	assert.Equal(t, expected, result, "Inconsistencies should be reported")
+
+	var waiting []*storetest.Item
+	assert.NoError(t, s.Find(storetest.TypeItem.Kind, &waiting, store.WithKey(testItemKey), store.WithWhereEq("Status", "waiting")))
+	assert.Empty(t, waiting, "Dry-run shouldn't repair indexes")
+
+	result, err = s.RebuildIndexes(storetest.TypeItem.Kind, false)
+	assert.NoError(t, err)
+	expected.DryRun = false
+	assert.Equal(t, expected, result, "Inconsistencies should be repaired")
+
+	assert.NoError(t, s.Find(storetest.TypeItem.Kind, &waiting, store.WithKey(testItemKey), store.WithWhereEq("Status", "waiting")))
+	assert.Len(t, waiting, 1, "Object should be found using restored index entry")
+
+	var last *storetest.Item
+	assert.NoError(t, s.Find(storetest.TypeItem.Kind, &last, store.WithKey(testItemKey)))
+	if assert.NotNil(t, last, "Last generation should be found using repaired index entry") {
+		assert.EqualValues(t, 3, last.GetGeneration())
+	}
+
+	result, err = s.RebuildIndexes(storetest.TypeItem.Kind, false)
+	assert.NoError(t, err)
+	assert.Equal(t, &store.IndexRebuildResult{}, result, "Repaired indexes shouldn't be changed again")
+}
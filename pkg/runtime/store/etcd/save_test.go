@@ -0,0 +1,126 @@
+package etcd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/storetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEtcdStoreReplaceUnchanged(t *testing.T) {
+	types := storetest.Types()
+	s := newTestStore(t, Config{}, types, store.NewGobCodec(types))
+	defer s.Close() // nolint: errcheck
+
+	client := s.(*etcdStore).client // nolint: errcheck
+
+	item := newTestItem("waiting", "42")
+	item.SetGeneration(1)
+	_, err := s.Save(item, store.WithReplaceOrForceGen())
+	if !assert.NoError(t, err, "Object should be saved") {
+		t.FailNow()
+	}
+
+	objKey := "/object" + "/" + runtime.KeyForStorable(item) + "@" + item.GetGeneration().String()
+	modRevision := func() int64 {
+		resp, errGet := client.KV.Get(context.TODO(), objKey)
+		if !assert.NoError(t, errGet, "Object should be fetched") || !assert.Len(t, resp.Kvs, 1, "Object should exist") {
+			t.FailNow()
+		}
+		return resp.Kvs[0].ModRevision
+	}
+	before := modRevision()
+
+	changed, err := s.Save(item, store.WithReplaceOrForceGen())
+	assert.NoError(t, err, "Identical object should be saved")
+	assert.False(t, changed, "Identical object should not be reported as changed")
+	assert.Equal(t, before, modRevision(), "Identical object should not be written")
+
+	item.Status = "done"
+	changed, err = s.Save(item, store.WithReplaceOrForceGen())
+	assert.NoError(t, err, "Changed object should be saved")
+	assert.True(t, changed, "Changed object should be reported as changed")
+	assert.True(t, modRevision() > before, "Changed object should be written")
+}
+
+func TestEtcdStoreOperationTimeout(t *testing.T) {
+	s := &etcdStore{timeout: time.Millisecond}
+
+	ctx, cancel := s.newContext()
+	defer cancel()
+	<-ctx.Done()
+
+	err := s.wrapError(ctx, ctx.Err())
+	if assert.Error(t, err, "Timeout should be reported") {
+		assert.Contains(t, err.Error(), "didn't complete in 1ms", "Timeout should be mentioned in error")
+	}
+	assert.NoError(t, s.wrapError(ctx, nil), "No error should be reported if operation succeeded")
+}
+
+func TestEtcdStoreSaveManyChunked(t *testing.T) {
+	types := storetest.Types()
+	cfg := Config{
+		// only two generations fit into a single transaction
+		MaxTxnOps: 2 * (&etcdStore{}).saveOps(storetest.TypeItem),
+	}
+	s := newTestStore(t, cfg, types, store.NewGobCodec(types))
+	defer s.Close() // nolint: errcheck
+
+	items := make([]runtime.Storable, 0)
+	for gen := 1; gen <= 3; gen++ {
+		item := newTestItem("waiting", "")
+		item.SetGeneration(runtime.Generation(gen))
+		items = append(items, item)
+	}
+	changed, err := s.SaveMany(items, store.WithReplaceOrForceGen())
+	if !assert.NoError(t, err, "Objects should be saved") {
+		t.FailNow()
+	}
+	assert.Equal(t, []bool{true, true, true}, changed, "All objects should be reported as changed")
+
+	items[1].(*storetest.Item).Status = "done"
+	created := newTestItem("waiting", "")
+	created.SetGeneration(4)
+	items = append(items, created)
+	changed, err = s.SaveMany(items, store.WithReplaceOrForceGen())
+	if !assert.NoError(t, err, "Objects should be saved in several transactions") {
+		t.FailNow()
+	}
+	assert.Equal(t, []bool{false, true, false, true}, changed, "Only changed and created objects should be reported")
+
+	var waiting []*storetest.Item
+	assert.NoError(t, s.Find(storetest.TypeItem.Kind, &waiting, store.WithKey(testItemKey), store.WithWhereEq("Status", "waiting")))
+	assert.Len(t, waiting, 3, "Unchanged and created objects should be indexed")
+	var done []*storetest.Item
+	assert.NoError(t, s.Find(storetest.TypeItem.Kind, &done, store.WithKey(testItemKey), store.WithWhereEq("Status", "done")))
+	if assert.Len(t, done, 1, "Changed object should be indexed") {
+		assert.EqualValues(t, 2, done[0].GetGeneration(), "Changed object should keep its generation")
+	}
+}
+
+func TestEtcdStoreSaveChunks(t *testing.T) {
+	s := &etcdStore{types: storetest.Types()}
+	s.maxTxnOps = 2 * s.saveOps(storetest.TypeItem)
+
+	items := make([]runtime.Storable, 5)
+	for idx := range items {
+		item := newTestItem("waiting", "")
+		item.SetGeneration(runtime.Generation(idx + 1))
+		items[idx] = item
+	}
+
+	chunks := s.saveChunks(items)
+	if assert.Len(t, chunks, 3, "Objects should be split into chunks fitting into transaction") {
+		assert.Equal(t, items[0:2], chunks[0])
+		assert.Equal(t, items[2:4], chunks[1])
+		assert.Equal(t, items[4:], chunks[2])
+	}
+
+	s.maxTxnOps = 1
+	assert.Len(t, s.saveChunks(items), 5, "Every object exceeding the limit should get its own chunk")
+	assert.Empty(t, s.saveChunks(nil), "No chunks should be created for empty batch")
+}
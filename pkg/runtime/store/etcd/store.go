@@ -4,20 +4,32 @@ import (
 	"context"
 	"fmt"
 	"reflect"
-	"sort"
 	"strings"
+	"time"
 
 	"github.com/Aptomi/aptomi/pkg/runtime"
 	"github.com/Aptomi/aptomi/pkg/runtime/store"
 	etcd "github.com/coreos/etcd/clientv3"
 	etcdconc "github.com/coreos/etcd/clientv3/concurrency"
 	"github.com/coreos/etcd/clientv3/namespace"
+	"github.com/coreos/etcd/etcdserver/api/v3rpc/rpctypes"
+	log "github.com/sirupsen/logrus"
 )
 
 type etcdStore struct {
-	client *etcd.Client
-	types  *runtime.Types
-	codec  store.Codec
+	client    *etcd.Client
+	types     *runtime.Types
+	codec     store.Codec
+	compactor *compactor
+	logger    *log.Entry
+	timeout   time.Duration
+	username  string
+
+	electionTTL time.Duration
+	maxTxnOps   int
+
+	// reportRetry is called for every retry of STM transaction caused by conflicting changes
+	reportRetry func(operation string, kind runtime.Kind)
 }
 
 // New creates etcdv3 store backend from provided config, types registry and codec
@@ -26,13 +38,27 @@ func New(cfg Config, types *runtime.Types, codec store.Codec) (store.Interface,
 		cfg.Endpoints = []string{"localhost:2379"}
 	}
 
+	tlsConfig, err := cfg.TLS.Build()
+	if err != nil {
+		return nil, err
+	}
+	if (len(cfg.Username) > 0) != (len(cfg.Password) > 0) {
+		return nil, fmt.Errorf("both username and password should be set to authenticate in etcd")
+	}
+
 	client, err := etcd.New(etcd.Config{
 		Endpoints:            cfg.Endpoints,
 		DialTimeout:          dialTimeout,
 		DialKeepAliveTime:    keepaliveTime,
 		DialKeepAliveTimeout: keepaliveTimeout,
+		TLS:                  tlsConfig,
+		Username:             cfg.Username,
+		Password:             cfg.Password,
 	})
 	if err != nil {
+		if authErr := authError(cfg.Username, err); authErr != nil {
+			return nil, authErr
+		}
 		return nil, fmt.Errorf("error while connecting to etcd: %s", err)
 	}
 
@@ -44,19 +70,106 @@ func New(cfg Config, types *runtime.Types, codec store.Codec) (store.Interface,
 		client.Watcher = namespace.NewWatcher(client.Watcher, cfg.Prefix)
 	}
 
-	// todo run compactor?
+	s := &etcdStore{
+		client:   client,
+		types:    types,
+		codec:    codec,
+		timeout:  cfg.Timeout,
+		username: cfg.Username,
+	}
+	if s.timeout <= 0 {
+		s.timeout = defaultTimeout
+	}
+	s.electionTTL = cfg.ElectionTTL
+	if s.electionTTL <= 0 {
+		s.electionTTL = defaultElectionTTL
+	}
+	s.maxTxnOps = cfg.MaxTxnOps
+	if s.maxTxnOps <= 0 {
+		s.maxTxnOps = defaultMaxTxnOps
+	}
+	if cfg.Logger != nil {
+		s.logger = cfg.Logger
+	} else if cfg.Debug {
+		logger := log.New()
+		logger.Level = log.DebugLevel
+		s.logger = log.NewEntry(logger)
+	}
+
+	if !cfg.Compactor.Disabled {
+		s.compactor, err = newCompactor(s, cfg.Compactor)
+		if err != nil {
+			client.Close() // nolint: errcheck
+			return nil, fmt.Errorf("error while creating etcd compactor: %s", err)
+		}
+		s.compactor.start()
+	}
+
+	return s, nil
+}
+
+// newContext returns context for a single store operation, which is cancelled once operation timeout passes
+func (s *etcdStore) newContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), s.timeout)
+}
 
-	return &etcdStore{
-		client: client,
-		types:  types,
-		codec:  codec,
-	}, nil
+// wrapError makes it clear that store operation failed because it didn't complete in time or because of failed
+// authentication in etcd
+func (s *etcdStore) wrapError(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("etcd operation didn't complete in %s: %s", s.timeout, err)
+	}
+	if authErr := authError(s.username, err); authErr != nil {
+		return authErr
+	}
+	return err
 }
 
+// authError returns descriptive error if err is caused by failed authentication or missing permissions in etcd, so
+// it could be distinguished from connection errors. It returns nil for all other errors
+func authError(username string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch rpctypes.Error(err) {
+	case rpctypes.ErrUserEmpty:
+		return fmt.Errorf("etcd requires authentication, but no username and password are set: %s", err)
+	case rpctypes.ErrAuthFailed, rpctypes.ErrInvalidAuthToken:
+		return fmt.Errorf("authentication in etcd as user '%s' failed, check username and password: %s", username, err)
+	case rpctypes.ErrPermissionDenied:
+		return fmt.Errorf("etcd user '%s' isn't permitted to access the store, check user roles: %s", username, err)
+	}
+
+	return nil
+}
+
+// Close stops compactor (if it's running) and closes connection to etcd
 func (s *etcdStore) Close() error {
+	if s.compactor != nil {
+		s.compactor.close()
+	}
 	return s.client.Close()
 }
 
+// SetRetriesReporter sets function, which is called for every retry of STM transaction caused by conflicting changes
+func (s *etcdStore) SetRetriesReporter(report func(operation string, kind runtime.Kind)) {
+	s.reportRetry = report
+}
+
+// retrying wraps STM apply function, so every retry of it (STM calls it again if read keys were changed by others
+// before commit) is reported
+func (s *etcdStore) retrying(operation string, kind runtime.Kind, apply func(stm etcdconc.STM) error) func(stm etcdconc.STM) error {
+	attempt := 0
+	return func(stm etcdconc.STM) error {
+		attempt++
+		if attempt > 1 && s.reportRetry != nil {
+			s.reportRetry(operation, kind)
+		}
+		return apply(stm)
+	}
+}
+
 // todo need to rework keys to not include kind or to start with kind at least???
 
 // Save saves Storable object with specified options into Etcd and updates indexes when appropriate.
@@ -67,9 +180,20 @@ func (s *etcdStore) Close() error {
 //    (like index update, getting last existing generation or comparing with existing object), in addition to that
 //    generation set for the object is always ignored if "forceGenOrReplace" option isn't used
 // 3. if "replaceOrForceGen" option used, there should be non-zero generation set in the object, last generation will
-//    not be checked in that case and old object will be removed from indexes, while new one will be added to them
+//    not be checked in that case and old object will be removed from indexes, while new one will be added to them.
+//    If object with the same generation exists and isn't changed, nothing is written and false is returned
 // 4. default option is saving object with new generation if it differs from the last generation object (or first time
 //    created), so, it'll only require adding object to indexes
+//
+// Superseded generation is removed from indexes holding the last generations only (see store.Index.LastOnly) within
+// the same transaction, so it stops matching the value it had. Other list and unique indexes are per generation:
+// superseded generations keep matching their own field values (e.g. revisions are looked up by status and policy
+// generation across all generations), so they are only removed when the generation itself is replaced, compacted or
+// deleted
+//
+// All keys read by the transaction, which are known in advance (see saveKeys), are prefetched by the first request,
+// so saving a versioned object takes at most two reads (indexes first and then the last generation of the object)
+// regardless of the number of indexes, instead of a separate read for every key
 func (s *etcdStore) Save(newStorable runtime.Storable, opts ...store.SaveOpt) (bool, error) {
 	if newStorable == nil {
 		return false, fmt.Errorf("can't save nil")
@@ -77,124 +201,311 @@ func (s *etcdStore) Save(newStorable runtime.Storable, opts ...store.SaveOpt) (b
 
 	saveOpts := store.NewSaveOpts(opts)
 	info := s.types.Get(newStorable.GetKind())
-	indexes := store.IndexesFor(info)
 	key := "/" + runtime.KeyForStorable(newStorable)
 
+	ctx, cancel := s.newContext()
+	defer cancel()
+
 	if !info.Versioned {
 		data := s.marshal(newStorable)
-		_, err := s.client.KV.Put(context.TODO(), "/object"+key+"@"+runtime.LastOrEmptyGen.String(), string(data))
+		_, err := s.client.KV.Put(ctx, "/object"+key+"@"+runtime.LastOrEmptyGen.String(), string(data))
 		// todo should it be true or false always?
-		return false, err
+		return false, s.wrapError(ctx, err)
 	}
 
 	var newVersion bool
-	newObj := newStorable.(runtime.Versioned) // nolint: errcheck
-	// todo prefetch all needed keys for STM to maximize performance (in fact it'll get all data in one first request)
-	// todo consider unmarshal to the info.New() to support gob w/o need to register types?
-	_, err := etcdconc.NewSTM(s.client, func(stm etcdconc.STM) error {
-		// need to remove this obj from indexes
-		var prevObj runtime.Storable
-
-		if saveOpts.IsReplaceOrForceGen() {
-			newGen := newObj.GetGeneration()
-			if newGen == runtime.LastOrEmptyGen {
-				return fmt.Errorf("error while saving object %s with replaceOrForceGen option but with empty generation", key)
+	_, err := etcdconc.NewSTM(s.client, s.retrying(store.OperationSave, info.Kind, func(stm etcdconc.STM) error {
+		var stmErr error
+		newVersion, stmErr = s.saveVersioned(stm, info, newStorable.(runtime.Versioned), saveOpts) // nolint: errcheck
+		return stmErr
+	}), etcdconc.WithAbortContext(ctx), etcdconc.WithPrefetch(s.saveKeys(info, newStorable, saveOpts)...))
+
+	return newVersion, s.wrapError(ctx, err)
+}
+
+// SaveMany saves all objects with the same options in chunks, each of them fits into MaxTxnOps and is saved in a single
+// STM transaction, so either all objects of the chunk are saved along with their index updates or none. Atomicity only
+// holds within a chunk, so the whole batch is saved atomically only if it fits into MaxTxnOps. Objects are saved in
+// order, so the same object could be saved more than once
+func (s *etcdStore) SaveMany(newStorables []runtime.Storable, opts ...store.SaveOpt) ([]bool, error) {
+	for _, newStorable := range newStorables {
+		if newStorable == nil {
+			return nil, fmt.Errorf("can't save nil")
+		}
+	}
+
+	saveOpts := store.NewSaveOpts(opts)
+
+	// batch is saved atomically only if it fits into a single transaction, otherwise it's saved chunk by chunk in
+	// order and objects from the already committed chunks stay saved if one of the next chunks fails
+	newVersions := make([]bool, 0, len(newStorables))
+	for _, chunk := range s.saveChunks(newStorables) {
+		chunkVersions, err := s.saveChunk(chunk, saveOpts)
+		if err != nil {
+			if len(newVersions) > 0 {
+				return nil, fmt.Errorf("error while saving objects (first %d of %d objects were saved): %s", len(newVersions), len(newStorables), err)
 			}
-			// need to check if there is an object already exists with gen from the object, if yes - remove it from indexes
-			oldObjRaw := stm.Get("/object" + key + "@" + newGen.String())
-			if oldObjRaw != "" {
-				// todo avoid
-				prevObj = info.New().(runtime.Storable) // nolint: errcheck
-				/*
-					add field require not nil val for unmarshal field into codec
-					if nil passed => create instance of desired object (w/o casting to storable) and pass to unmarshal
-					if not nil => error if incorrect type
-				*/
-				s.unmarshal([]byte(oldObjRaw), prevObj)
+			return nil, err
+		}
+		newVersions = append(newVersions, chunkVersions...)
+	}
+
+	return newVersions, nil
+}
+
+// saveChunks splits objects into chunks, which could be saved in a single transaction without exceeding the max
+// number of transaction operations. Object, which doesn't fit into the limit by itself, gets its own chunk
+func (s *etcdStore) saveChunks(newStorables []runtime.Storable) [][]runtime.Storable {
+	result := make([][]runtime.Storable, 0, 1)
+	start, ops := 0, 0
+	for idx, newStorable := range newStorables {
+		objOps := s.saveOps(s.types.Get(newStorable.GetKind()))
+		if idx > start && ops+objOps > s.maxTxnOps {
+			result = append(result, newStorables[start:idx])
+			start, ops = idx, 0
+		}
+		ops += objOps
+	}
+	if start < len(newStorables) {
+		result = append(result, newStorables[start:])
+	}
+
+	return result
+}
+
+// saveOps estimates the max number of transaction operations needed to save a single object. Versioned object needs
+// to compare its last gen index, object key and all index keys as well as to put object and update (remove old and add
+// new entry) every index
+func (s *etcdStore) saveOps(info *runtime.TypeInfo) int {
+	if !info.Versioned {
+		return 1
+	}
+
+	return 3*len(store.IndexesFor(info).List) + 3
+}
+
+// saveChunk saves all objects in a single STM transaction
+func (s *etcdStore) saveChunk(newStorables []runtime.Storable, saveOpts *store.SaveOpts) ([]bool, error) {
+	ctx, cancel := s.newContext()
+	defer cancel()
+
+	prefetch := make([]string, 0)
+	for _, newStorable := range newStorables {
+		if info := s.types.Get(newStorable.GetKind()); info.Versioned {
+			prefetch = append(prefetch, s.saveKeys(info, newStorable, saveOpts)...)
+		}
+	}
+
+	newVersions := make([]bool, len(newStorables))
+	_, err := etcdconc.NewSTM(s.client, s.retrying(store.OperationSaveMany, store.KindOfAll(newStorables), func(stm etcdconc.STM) error {
+		for idx, newStorable := range newStorables {
+			info := s.types.Get(newStorable.GetKind())
+			if !info.Versioned {
+				stm.Put("/object/"+runtime.KeyForStorable(newStorable)+"@"+runtime.LastOrEmptyGen.String(), string(s.marshal(newStorable)))
+				continue
+			}
+
+			newVersion, err := s.saveVersioned(stm, info, newStorable.(runtime.Versioned), saveOpts) // nolint: errcheck
+			if err != nil {
+				return err
 			}
+			newVersions[idx] = newVersion
+		}
+		return nil
+	}), etcdconc.WithAbortContext(ctx), etcdconc.WithPrefetch(prefetch...))
+	if err != nil {
+		return nil, s.wrapError(ctx, err)
+	}
+
+	return newVersions, nil
+}
 
-			// todo compare - if not changed - nothing to do
+// saveKeys returns keys, which are read while saving versioned object, so STM could prefetch them in a single request
+// instead of getting them one by one. It includes all index keys for the object and the object key itself if a
+// specific generation is being saved. Key of the last generation isn't known before the last gen index is read, so
+// it's the only key fetched separately when saving a new generation
+func (s *etcdStore) saveKeys(info *runtime.TypeInfo, newStorable runtime.Storable, saveOpts *store.SaveOpts) []string {
+	indexes := store.IndexesFor(info)
+	keys := make([]string, 0, len(indexes.List)+1)
+	if saveOpts.IsReplaceOrForceGen() {
+		gen := newStorable.(runtime.Versioned).GetGeneration() // nolint: errcheck
+		keys = append(keys, "/object/"+runtime.KeyForStorable(newStorable)+"@"+gen.String())
+	}
+	for _, index := range indexes.List {
+		if indexName := index.NameForStorable(newStorable, s.codec); indexName != "" {
+			keys = append(keys, "/index/"+indexName)
+		}
+	}
+
+	return keys
+}
+
+// saveVersioned saves versioned object within a given STM transaction following the workflow described for Save. It
+// returns true if a new generation was created or an existing one was replaced
+func (s *etcdStore) saveVersioned(stm etcdconc.STM, info *runtime.TypeInfo, newObj runtime.Versioned, saveOpts *store.SaveOpts) (bool, error) {
+	newStorable := newObj.(runtime.Storable) // nolint: errcheck
+	indexes := store.IndexesFor(info)
+	key := "/" + runtime.KeyForStorable(newStorable)
+
+	// need to remove this obj from indexes
+	var prevObj runtime.Storable
+
+	if saveOpts.IsReplaceOrForceGen() {
+		newGen := newObj.GetGeneration()
+		if newGen == runtime.LastOrEmptyGen {
+			return false, fmt.Errorf("error while saving object %s with replaceOrForceGen option but with empty generation", key)
+		}
+		// need to check if there is an object already exists with gen from the object, if yes - remove it from indexes
+		oldObjRaw := stm.Get("/object" + key + "@" + newGen.String())
+		if oldObjRaw != "" {
+			// todo avoid
+			prevObj = info.New().(runtime.Storable) // nolint: errcheck
+			/*
+				add field require not nil val for unmarshal field into codec
+				if nil passed => create instance of desired object (w/o casting to storable) and pass to unmarshal
+				if not nil => error if incorrect type
+			*/
+			s.unmarshal([]byte(oldObjRaw), prevObj)
+
+			// nothing to write if object isn't changed, so no watch events are generated as well
+			if reflect.DeepEqual(prevObj, newObj) {
+				return false, nil
+			}
+		}
+	} else {
+		// need to get last gen using index, if exists - compare with, if different - increment revision and delete old from indexes
+		lastGenRaw := stm.Get("/index/" + indexes.NameForStorable(store.LastGenIndex, newStorable, s.codec))
+		if lastGenRaw == "" {
+			newObj.SetGeneration(runtime.FirstGen)
 		} else {
-			// need to get last gen using index, if exists - compare with, if different - increment revision and delete old from indexes
-			lastGenRaw := stm.Get("/index/" + indexes.NameForStorable(store.LastGenIndex, newStorable, s.codec))
-			if lastGenRaw == "" {
-				newObj.SetGeneration(runtime.FirstGen)
-				newVersion = true
-			} else {
-				lastGen := s.unmarshalGen(lastGenRaw)
-				oldObjRaw := stm.Get("/object" + key + "@" + lastGen.String())
-				if oldObjRaw == "" {
-					return fmt.Errorf("last gen index for %s seems to be corrupted: generation doesn't exist", key)
-				}
-				// todo avoid
-				prevObj = info.New().(runtime.Storable) // nolint: errcheck
-				s.unmarshal([]byte(oldObjRaw), prevObj)
-				newObj.SetGeneration(lastGen)
-
-				// todo should we compare marshaled objects for safety?
-				if reflect.DeepEqual(prevObj, newObj) {
-					return nil
-				}
+			lastGen := s.unmarshalGen(lastGenRaw)
+			oldObjRaw := stm.Get("/object" + key + "@" + lastGen.String())
+			if oldObjRaw == "" {
+				return false, fmt.Errorf("last gen index for %s seems to be corrupted: generation doesn't exist", key)
+			}
+			// todo avoid
+			prevObj = info.New().(runtime.Storable) // nolint: errcheck
+			s.unmarshal([]byte(oldObjRaw), prevObj)
+			newObj.SetGeneration(lastGen)
 
-				// objects are different
-				newObj.SetGeneration(lastGen.Next())
-				newVersion = true
+			// todo should we compare marshaled objects for safety?
+			if reflect.DeepEqual(prevObj, newObj) {
+				return false, nil
 			}
+
+			// objects are different
+			newObj.SetGeneration(lastGen.Next())
 		}
+	}
 
-		data := s.marshal(newObj)
-		newGen := newObj.GetGeneration()
-		stm.Put("/object"+key+"@"+newGen.String(), string(data))
+	data := s.marshal(newObj)
+	newGen := newObj.GetGeneration()
+	objKey := strings.TrimPrefix(key, "/")
 
-		if prevObj != nil && prevObj.(runtime.Versioned).GetGeneration() == newGen {
-			for _, index := range indexes.List {
-				indexName := index.NameForStorable(prevObj, s.codec)
-				if indexName == "" {
-					continue
-				}
-				indexKey := "/index/" + indexName
-				if index.Type == store.IndexTypeListGen {
-					s.updateIndex(stm, indexKey, prevObj.(runtime.Versioned).GetGeneration(), true)
+	// values of unique indexes shouldn't be used by other generations, while values of namespace-wide unique indexes
+	// shouldn't be used by other objects. Index entries are read inside the transaction, so concurrent saves of
+	// different objects with the same value can't both succeed
+	for _, index := range indexes.List {
+		indexName := index.NameForStorable(newStorable, s.codec)
+		if indexName == "" {
+			continue
+		}
+		if index.Type == store.IndexTypeUniqueGen {
+			if genRaw := stm.Get("/index/" + indexName); genRaw != "" {
+				if gen := s.unmarshalGen(genRaw); gen != newGen {
+					return false, &store.ErrDuplicateValue{Kind: info.Kind, Key: objKey, Field: index.Field, HolderGen: gen}
 				}
 			}
+		} else if index.Type == store.IndexTypeUniqueKey {
+			if holder := stm.Get("/index/" + indexName); holder != "" && holder != objKey {
+				return false, &store.ErrDuplicateValue{Kind: info.Kind, Key: objKey, Field: index.Field, HolderKey: holder}
+			}
+		}
+	}
+
+	// saved generation becomes the last one, so values of namespace-wide unique indexes held by the previous last
+	// generation should be released and it should be removed from indexes holding the last generations only
+	lastObj := prevObj
+	if saveOpts.IsReplaceOrForceGen() && (indexes.HasType(store.IndexTypeUniqueKey) || indexes.HasLastOnly()) {
+		lastObj = nil
+		if lastGenRaw := stm.Get("/index/" + indexes.NameForStorable(store.LastGenIndex, newStorable, s.codec)); lastGenRaw != "" {
+			if lastObjRaw := stm.Get("/object" + key + "@" + s.unmarshalGen(lastGenRaw).String()); lastObjRaw != "" {
+				lastObj = info.New().(runtime.Storable) // nolint: errcheck
+				s.unmarshal([]byte(lastObjRaw), lastObj)
+			}
 		}
+	}
+
+	stm.Put("/object"+key+"@"+newGen.String(), string(data))
 
+	if prevObj != nil && prevObj.(runtime.Versioned).GetGeneration() == newGen {
 		for _, index := range indexes.List {
-			indexName := index.NameForStorable(newStorable, s.codec)
+			indexName := index.NameForStorable(prevObj, s.codec)
 			if indexName == "" {
 				continue
 			}
 			indexKey := "/index/" + indexName
-			if index.Type == store.IndexTypeLastGen {
-				stm.Put(indexKey, s.marshalGen(newGen))
-			} else if index.Type == store.IndexTypeListGen {
-				s.updateIndex(stm, indexKey, newGen, false)
-			} else {
-				panic("only indexes with types store.IndexTypeLastGen and store.IndexTypeListGen are currently supported by Etcd store")
+			if index.Type == store.IndexTypeListGen {
+				s.updateIndex(stm, indexKey, prevObj.(runtime.Versioned).GetGeneration(), true)
+			} else if index.Type == store.IndexTypeUniqueGen {
+				stm.Del(indexKey)
 			}
 		}
+	}
 
-		return nil
-	})
+	if lastObj != nil {
+		lastGen := lastObj.(runtime.Versioned).GetGeneration() // nolint: errcheck
+		for _, index := range indexes.List {
+			indexName := index.NameForStorable(lastObj, s.codec)
+			if indexName == "" {
+				continue
+			}
+			if index.Type == store.IndexTypeUniqueKey && stm.Get("/index/"+indexName) == objKey {
+				stm.Del("/index/" + indexName)
+			} else if index.LastOnly && lastGen != newGen {
+				s.updateIndex(stm, "/index/"+indexName, lastGen, true)
+			}
+		}
+	}
+
+	for _, index := range indexes.List {
+		indexName := index.NameForStorable(newStorable, s.codec)
+		if indexName == "" {
+			continue
+		}
+		indexKey := "/index/" + indexName
+		if index.Type == store.IndexTypeLastGen {
+			stm.Put(indexKey, s.marshalGen(newGen))
+		} else if index.Type == store.IndexTypeListGen {
+			s.updateIndex(stm, indexKey, newGen, false)
+		} else if index.Type == store.IndexTypeUniqueGen {
+			stm.Put(indexKey, s.marshalGen(newGen))
+		} else if index.Type == store.IndexTypeUniqueKey {
+			stm.Put(indexKey, objKey)
+		} else {
+			panic(fmt.Sprintf("index type %s is not supported by Etcd store", index.Type))
+		}
+	}
 
-	return newVersion, err
+	return true, nil
 }
 
 func (s *etcdStore) updateIndex(stm etcdconc.STM, indexKey string, newGen runtime.Generation, delete bool) {
-	valueList := &store.IndexValueList{}
-	valueListRaw := stm.Get(indexKey)
-	if valueListRaw != "" {
-		s.unmarshal([]byte(valueListRaw), valueList)
+	genList := store.IndexGenList{}
+	genListRaw := stm.Get(indexKey)
+	if genListRaw != "" {
+		genList = s.unmarshalGenList(genListRaw)
 	}
-	// todo avoid marshaling gens for indexes by using special index value list type for gens
-	gen := []byte(s.marshalGen(newGen))
 	if delete {
-		valueList.Remove(gen)
+		genList.Remove(newGen)
 	} else {
-		valueList.Add(gen)
+		genList.Add(newGen)
 	}
-	data := s.marshal(valueList)
-	stm.Put(indexKey, string(data))
+	if len(genList) == 0 {
+		stm.Del(indexKey)
+		return
+	}
+	stm.Put(indexKey, s.marshalGenList(genList))
 }
 
 /*
@@ -218,127 +529,402 @@ Workflow:
 func (s *etcdStore) Find(kind runtime.Kind, result interface{}, opts ...store.FindOpt) error {
 	findOpts := store.NewFindOpts(opts)
 	info := s.types.Get(kind)
+	if err := findOpts.Validate(info); err != nil {
+		if store.IsInvalidContinueToken(err) {
+			return err
+		}
+		return fmt.Errorf("invalid find options: %s", err)
+	}
 
-	resultTypeElem := reflect.TypeOf(info.New())
-	resultTypeSingle := reflect.PtrTo(reflect.TypeOf(info.New()))
-	resultTypeList := reflect.PtrTo(reflect.SliceOf(resultTypeElem))
+	resultList, err := isListResult(kind, result, reflect.TypeOf(info.New()))
+	if err != nil {
+		return err
+	}
+	if err := findOpts.ValidateResult(info, resultList); err != nil {
+		return fmt.Errorf("invalid find options: %s", err)
+	}
 
-	resultList := false
+	ctx, cancel := s.newContext()
+	defer cancel()
 
-	resultType := reflect.TypeOf(result)
-	if resultType == resultTypeSingle {
-		// ok!
-	} else if resultType == resultTypeList {
-		// ok!
-		resultList = true
-	} else {
-		// todo return back verification
-		fmt.Printf("result should be %s or %s, but found: %s\n", resultTypeSingle, resultTypeList, resultType)
-		//return fmt.Errorf("result should be %s or %s, but found: %s", resultTypeSingle, resultTypeList, resultType)
+	if s.logger != nil {
+		defer s.logFind(kind, findOpts, result, resultList, time.Now())
 	}
 
 	v := reflect.ValueOf(result).Elem()
-	if findOpts.GetKeyPrefix() != "" {
-		return s.findByKeyPrefix(findOpts, info, func(elem interface{}) {
-			// todo validate type of the elem
-			// todo if !resultList
-			v.Set(reflect.Append(v, reflect.ValueOf(elem)))
-		})
-	} else if findOpts.GetKey() != "" && findOpts.GetFieldEqName() == "" {
-		return s.findByKey(findOpts, info, func(elem interface{}) {
-			// todo validate type of the elem
-			if elem == nil {
+	elemType := v.Type()
+	if resultList {
+		elemType = elemType.Elem()
+	}
+
+	// helpers keep passing found objects after a failure, only the first error is kept and the rest is ignored
+	found := 0
+	var resultErr error
+	addToResult := func(elem interface{}) {
+		if resultErr != nil {
+			return
+		}
+		if elem == nil {
+			if !resultList {
 				v.Set(reflect.Zero(v.Type()))
-			} else {
-				v.Set(reflect.ValueOf(elem))
 			}
-		})
+			return
+		}
+		if resultErr = validateFoundElem(kind, elem, elemType); resultErr != nil {
+			return
+		}
+
+		found++
+		if resultList {
+			v.Set(reflect.Append(v, reflect.ValueOf(elem)))
+		} else if found > 1 {
+			resultErr = fmt.Errorf("more than one object of kind %s found, while single result requested (key %s)", kind, foundElemKey(elem))
+		} else {
+			v.Set(reflect.ValueOf(elem))
+		}
+	}
+
+	if findOpts.GetKeyPrefix() != "" {
+		err = s.findByKeyPrefix(ctx, findOpts, info, addToResult)
+	} else if findOpts.GetKey() != "" && findOpts.GetFieldEqName() == "" {
+		err = s.findByKey(ctx, findOpts, info, addToResult)
 	} else {
-		return s.findByFieldEq(findOpts, info, func(elem interface{}) {
-			// todo validate type of the elem
-			if !resultList {
-				if elem == nil {
-					v.Set(reflect.Zero(v.Type()))
-				} else {
-					v.Set(reflect.ValueOf(elem))
-				}
-			} else {
-				v.Set(reflect.Append(v, reflect.ValueOf(elem)))
-			}
-		})
+		err = s.findByFieldEq(ctx, findOpts, info, addToResult)
+	}
+	if err == nil {
+		err = resultErr
+	}
+
+	return s.wrapError(ctx, err)
+}
+
+// validateFoundElem checks that the decoded object could be added to the result and that it's of the requested kind,
+// so objects broken by the codec or found using corrupted indexes are reported instead of panicking in reflect
+func validateFoundElem(kind runtime.Kind, elem interface{}, elemType reflect.Type) error {
+	actualType := reflect.TypeOf(elem)
+	if !actualType.AssignableTo(elemType) {
+		return fmt.Errorf("found object %s of kind %s has type %s, which doesn't match requested type %s", foundElemKey(elem), kind, actualType, elemType)
+	}
+	if obj, ok := elem.(runtime.Object); ok && obj.GetKind() != kind {
+		return fmt.Errorf("found object %s of kind %s has kind %s, which doesn't match requested kind (type %s)", foundElemKey(elem), kind, obj.GetKind(), actualType)
+	}
+	return nil
+}
+
+// foundElemKey returns key of the found object for error messages or its type if it isn't storable
+func foundElemKey(elem interface{}) string {
+	if storable, ok := elem.(runtime.Storable); ok {
+		key := runtime.KeyForStorable(storable)
+		if versioned, ok := elem.(runtime.Versioned); ok {
+			key += "@" + versioned.GetGeneration().String()
+		}
+		return key
 	}
+	return fmt.Sprintf("(%T)", elem)
 }
 
-func (s *etcdStore) findByKeyPrefix(findOpts *store.FindOpts, info *runtime.TypeInfo, addToResult func(interface{})) error {
+// isListResult checks that result is a pointer to the object of a given kind (or to the interface it implements) or
+// a pointer to the slice of them. It returns true for slices
+func isListResult(kind runtime.Kind, result interface{}, elemType reflect.Type) (bool, error) {
+	resultType := reflect.TypeOf(result)
+	if resultType != nil && resultType.Kind() == reflect.Ptr {
+		t := resultType.Elem()
+		list := t.Kind() == reflect.Slice
+		if list {
+			t = t.Elem()
+		}
+		if t == elemType || t.Kind() == reflect.Interface && elemType.Implements(t) {
+			return list, nil
+		}
+	}
+
+	return false, fmt.Errorf("result of finding objects of kind %s should be %s or %s, but found: %s", kind, reflect.PtrTo(elemType), reflect.PtrTo(reflect.SliceOf(elemType)), resultType)
+}
+
+// logFind logs find query along with its duration and number of found objects
+func (s *etcdStore) logFind(kind runtime.Kind, findOpts *store.FindOpts, result interface{}, resultList bool, start time.Time) {
+	v := reflect.ValueOf(result).Elem()
+	count := 0
+	if resultList {
+		count = v.Len()
+	} else if !v.IsNil() {
+		count = 1
+	}
+
+	s.logger.WithFields(log.Fields{
+		"kind":      kind,
+		"key":       findOpts.GetKey(),
+		"keyPrefix": findOpts.GetKeyPrefix(),
+		"gen":       findOpts.GetGen(),
+		"whereEq":   fmt.Sprintf("%s%v", findOpts.GetFieldEqName(), findOpts.GetFieldEqValues()),
+		"first":     findOpts.IsGetFirst(),
+		"last":      findOpts.IsGetLast(),
+		"duration":  time.Since(start),
+		"found":     count,
+	}).Debug("(etcd) find")
+}
+
+// findByKeyPrefix lists objects with keys prefixed by a given key prefix. For non-versioned objects it's a single
+// range query over objects, while for versioned objects last generation index is scanned instead, so only the last
+// generation of every object is returned
+func (s *etcdStore) findByKeyPrefix(ctx context.Context, findOpts *store.FindOpts, info *runtime.TypeInfo, addToResult func(interface{})) error {
 	if info.Versioned {
-		return fmt.Errorf("searching with key prefix is only supported for non versioned objects")
+		return s.findLastGensByKeyPrefix(ctx, findOpts, info, addToResult)
 	}
 
-	resp, err := s.client.KV.Get(context.TODO(), "/object"+"/"+findOpts.GetKeyPrefix(), etcd.WithPrefix())
+	prefix := "/object" + "/" + findOpts.GetKeyPrefix()
+	start := prefix
+	if findOpts.GetContinueAfter() != "" {
+		start = "/object" + "/" + findOpts.GetContinueAfter() + "@" + runtime.LastOrEmptyGen.String() + "\x00"
+	}
+	resp, err := s.client.KV.Get(ctx, start, pageOpts(prefix, findOpts)...)
 	if err != nil {
 		return err
 	}
+	if len(resp.Kvs) > 0 && len(resp.Kvs) == findOpts.GetLimit() {
+		findOpts.SetNextKey(strings.TrimSuffix(strings.TrimPrefix(string(resp.Kvs[len(resp.Kvs)-1].Key), "/object/"), "@"+runtime.LastOrEmptyGen.String()))
+	}
 
 	for _, kv := range resp.Kvs {
 		// todo avoid
 		elem := info.New()
-		s.unmarshal(kv.Value, elem)
+		if err = s.codec.Unmarshal(kv.Value, elem); err != nil {
+			key := strings.TrimSuffix(strings.TrimPrefix(string(kv.Key), "/object/"), "@"+runtime.LastOrEmptyGen.String())
+			if err = findOpts.HandleDecodeError(key, err); err != nil {
+				return err
+			}
+			continue
+		}
 		addToResult(elem)
 	}
 
 	return nil
 }
 
-func (s *etcdStore) findByKey(findOpts *store.FindOpts, info *runtime.TypeInfo, addToResult func(interface{})) error {
+// findLastGensByKeyPrefix lists the last generations of versioned objects with keys prefixed by a given key prefix by
+// scanning last gen index
+func (s *etcdStore) findLastGensByKeyPrefix(ctx context.Context, findOpts *store.FindOpts, info *runtime.TypeInfo, addToResult func(interface{})) error {
+	indexPrefix := "/index/" + store.IndexesFor(info).NameForValue(store.LastGenIndex, findOpts.GetKeyPrefix(), nil, s.codec)
+	indexKeyPrefix := "/index/" + store.IndexesFor(info).NameForValue(store.LastGenIndex, "", nil, s.codec)
 
-	if !info.Versioned && findOpts.GetGen() != runtime.LastOrEmptyGen {
-		return fmt.Errorf("requested specific version for non versioned object")
+	// keys of index entries are listed with a plain range request, while every listed entry and the generation it
+	// points to are read through STM, so transaction is retried if any of them gets changed before it's finished and
+	// every returned object is the generation its index entry points to. Objects created concurrently aren't tracked
+	// by STM, so they could be either listed or not
+	values := make(map[runtime.Key]string)
+	keys := make([]runtime.Key, 0)
+	_, err := etcdconc.NewSTM(s.client, s.retrying(store.OperationFind, info.Kind, func(stm etcdconc.STM) error {
+		values = make(map[runtime.Key]string)
+		keys = keys[:0]
+
+		start := indexPrefix
+		if findOpts.GetContinueAfter() != "" {
+			start = indexKeyPrefix + findOpts.GetContinueAfter() + "\x00"
+		}
+		resp, err := s.client.KV.Get(ctx, start, pageOpts(indexPrefix, findOpts)...)
+		if err != nil {
+			return err
+		}
+		if len(resp.Kvs) > 0 && len(resp.Kvs) == findOpts.GetLimit() {
+			findOpts.SetNextKey(strings.TrimPrefix(string(resp.Kvs[len(resp.Kvs)-1].Key), indexKeyPrefix))
+		}
+		for _, kv := range resp.Kvs {
+			key := strings.TrimPrefix(string(kv.Key), indexKeyPrefix)
+			gen := s.unmarshalGen(stm.Get(string(kv.Key)))
+			data := stm.Get("/object" + "/" + key + "@" + gen.String())
+			if data == "" {
+				return fmt.Errorf("last gen index for %s seems to be corrupted: generation %s doesn't exist", key, gen)
+			}
+			keys = append(keys, key)
+			values[key] = data
+		}
+
+		return nil
+	}), etcdconc.WithAbortContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		elem := info.New()
+		if err = s.codec.Unmarshal([]byte(values[key]), elem); err != nil {
+			if err = findOpts.HandleDecodeError(key, err); err != nil {
+				return err
+			}
+			continue
+		}
+		addToResult(elem)
+	}
+
+	return nil
+}
+
+// pageOpts returns options of the range query over keys with a given prefix, which limit the number of returned keys
+// if WithLimit is used, so pages are fetched from etcd without reading the rest of the range
+func pageOpts(prefix string, findOpts *store.FindOpts) []etcd.OpOption {
+	opts := []etcd.OpOption{etcd.WithRange(etcd.GetPrefixRangeEnd(prefix))}
+	if findOpts.GetLimit() > 0 {
+		opts = append(opts, etcd.WithLimit(int64(findOpts.GetLimit())))
 	}
+	return opts
+}
+
+// findIterBatchSize is the number of objects fetched from etcd at once while iterating over objects
+const findIterBatchSize = 100
 
-	var data []byte
+// FindIter scans over objects with a given key prefix, fetching them from etcd in batches, so only a single batch of
+// objects is kept in memory at a time
+func (s *etcdStore) FindIter(kind runtime.Kind, fn func(obj runtime.Object) error, opts ...store.FindOpt) error {
+	findOpts := store.NewFindOpts(opts)
+	info := s.types.Get(kind)
+	if err := findOpts.ValidateIter(info); err != nil {
+		return fmt.Errorf("invalid find options: %s", err)
+	}
 
-	if !info.Versioned || findOpts.GetGen() != runtime.LastOrEmptyGen {
-		resp, respErr := s.client.KV.Get(context.TODO(), "/object"+"/"+findOpts.GetKey()+"@"+findOpts.GetGen().String())
-		if respErr != nil {
-			return respErr
-		} else if resp.Count > 0 {
-			data = resp.Kvs[0].Value
+	prefix := "/object" + "/" + findOpts.GetKeyPrefix()
+	rangeEnd := etcd.GetPrefixRangeEnd(prefix)
+	for start := prefix; ; {
+		// timeout applies to fetching of every batch, as processing of objects could take arbitrary time
+		ctx, cancel := s.newContext()
+		resp, err := s.client.KV.Get(ctx, start, etcd.WithRange(rangeEnd), etcd.WithLimit(findIterBatchSize))
+		cancel()
+		if err != nil {
+			return s.wrapError(ctx, err)
 		}
-	} else {
-		indexes := store.IndexesFor(info)
-		// todo wrap into STM to ensure we're getting really last unchanged element / consider is it important? we can't delete generation, so, probably no need for STM here
-		resp, respErr := s.client.KV.Get(context.TODO(), "/index/"+indexes.NameForValue(store.LastGenIndex, findOpts.GetKey(), nil, s.codec))
-		if respErr != nil {
-			return respErr
-		} else if resp.Count > 0 {
-			lastGen := s.unmarshalGen(string(resp.Kvs[0].Value))
-			resp, respErr = s.client.KV.Get(context.TODO(), "/object"+"/"+findOpts.GetKey()+"@"+lastGen.String())
-			if respErr != nil {
-				return respErr
-			} else if resp.Count > 0 {
-				data = resp.Kvs[0].Value
+
+		for _, kv := range resp.Kvs {
+			elem := info.New()
+			if err = s.codec.Unmarshal(kv.Value, elem); err != nil {
+				key := strings.TrimSuffix(strings.TrimPrefix(string(kv.Key), "/object/"), "@"+runtime.LastOrEmptyGen.String())
+				if err = findOpts.HandleDecodeError(key, err); err != nil {
+					return err
+				}
+				continue
+			}
+			if err = fn(elem); err != nil {
+				return err
 			}
 		}
+
+		if !resp.More || len(resp.Kvs) == 0 {
+			return nil
+		}
+
+		// continue right after the last key of the batch
+		start = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+	}
+}
+
+// findByKey fetches a single object stored under /object/<key>@<gen>. Non-versioned objects always have a single
+// generation (LastOrEmptyGen), while for versioned objects the last generation is looked up in the index if no
+// specific generation requested. If a specific generation requested, but there is no such object, GenNotFoundError
+// is returned, while a missing object, which last generation index points to, means the index is corrupted
+func (s *etcdStore) findByKey(ctx context.Context, findOpts *store.FindOpts, info *runtime.TypeInfo, addToResult func(interface{})) error {
+	if findOpts.IsGenRange() {
+		return s.findByGenRange(ctx, findOpts, info, addToResult)
+	}
+	if !info.Versioned && findOpts.GetGen() != runtime.LastOrEmptyGen {
+		return fmt.Errorf("requested specific version for non versioned object")
 	}
 
-	if data == nil {
+	gen := findOpts.GetGen()
+	if info.Versioned && gen == runtime.LastOrEmptyGen {
+		indexes := store.IndexesFor(info)
+		resp, err := s.client.KV.Get(ctx, "/index/"+indexes.NameForValue(store.LastGenIndex, findOpts.GetKey(), nil, s.codec))
+		if err != nil {
+			return err
+		}
+		if resp.Count == 0 {
+			addToResult(nil)
+			return nil
+		}
+		gen = s.unmarshalGen(string(resp.Kvs[0].Value))
+	}
+
+	resp, err := s.client.KV.Get(ctx, "/object"+"/"+findOpts.GetKey()+"@"+gen.String())
+	if err != nil {
+		return err
+	}
+	if resp.Count == 0 {
+		if findOpts.GetGen() != runtime.LastOrEmptyGen {
+			return &store.GenNotFoundError{Kind: info.Kind, Key: findOpts.GetKey(), Gen: gen}
+		}
+		if info.Versioned {
+			return fmt.Errorf("last generation index of object %s points to generation %s, which doesn't exist", findOpts.GetKey(), gen)
+		}
 		addToResult(nil)
-	} else {
-		// todo avoid
-		result := info.New()
-		s.unmarshal(data, result)
+		return nil
+	}
 
-		addToResult(result)
+	result := info.New()
+	if err = s.codec.Unmarshal(resp.Kvs[0].Value, result); err != nil {
+		return fmt.Errorf("error while decoding object %s: %s", findOpts.GetKey(), err)
+	}
+	addToResult(result)
+
+	return nil
+}
+
+// findByGenRange finds existing generations of the object within the range defined by WithGenRange in ascending order.
+// Generations are encoded in keys as decimal numbers, so they aren't ordered by etcd and range over them can't be
+// requested directly. Instead, keys of all generations are listed first and generations within the range are fetched
+// in batches of transactions
+func (s *etcdStore) findByGenRange(ctx context.Context, findOpts *store.FindOpts, info *runtime.TypeInfo, addToResult func(interface{})) error {
+	prefix := "/object" + "/" + findOpts.GetKey() + "@"
+	resp, err := s.client.KV.Get(ctx, prefix, etcd.WithPrefix(), etcd.WithKeysOnly())
+	if err != nil {
+		return err
+	}
+	gens := make([]runtime.Generation, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		gens = append(gens, runtime.ParseGeneration(strings.TrimPrefix(string(kv.Key), prefix)))
+	}
+
+	gens = findOpts.GensInRange(gens)
+	for len(gens) > 0 {
+		batch := gens
+		if len(batch) > findIterBatchSize {
+			batch = batch[:findIterBatchSize]
+		}
+		gens = gens[len(batch):]
+
+		ops := make([]etcd.Op, 0, len(batch))
+		for _, gen := range batch {
+			ops = append(ops, etcd.OpGet(prefix+gen.String()))
+		}
+		txnResp, txnErr := s.client.KV.Txn(ctx).Then(ops...).Commit()
+		if txnErr != nil {
+			return txnErr
+		}
+		for _, opResp := range txnResp.Responses {
+			// generations could be compacted after they were listed, they're skipped then
+			for _, kv := range opResp.GetResponseRange().Kvs {
+				result := info.New()
+				if err = s.codec.Unmarshal(kv.Value, result); err != nil {
+					return fmt.Errorf("error while decoding object %s: %s", kv.Key, err)
+				}
+				addToResult(result)
+			}
+		}
 	}
 
 	return nil
 }
 
-func (s *etcdStore) findByFieldEq(findOpts *store.FindOpts, info *runtime.TypeInfo, addToResult func(interface{})) error {
+// findByFieldEq finds generations of an object with a given field equal to one of the requested values using index
+// for that field and returns all of them or only the first/last generation if requested. Index and objects are read
+// in a single transaction, while results are only passed to addToResult once the transaction succeeds, as it could be
+// retried
+func (s *etcdStore) findByFieldEq(ctx context.Context, findOpts *store.FindOpts, info *runtime.TypeInfo, addToResult func(interface{})) error {
 	indexes := store.IndexesFor(info)
-	resultGens := make([]runtime.Generation, 0)
+	index, exist := indexes.List[findOpts.GetFieldEqName()]
+	if !exist {
+		return fmt.Errorf("can't find objects of kind %s by field %s, which isn't indexed (field should be tagged with `store:\"index\"`)", info.Kind, findOpts.GetFieldEqName())
+	}
 
-	_, err := etcdconc.NewSTM(s.client, func(stm etcdconc.STM) error {
+	var results [][]byte
+	_, err := etcdconc.NewSTM(s.client, s.retrying(store.OperationFind, info.Kind, func(stm etcdconc.STM) error {
+		resultGens := make([]runtime.Generation, 0)
 		for _, fieldValue := range findOpts.GetFieldEqValues() {
 			indexName := indexes.NameForValue(findOpts.GetFieldEqName(), findOpts.GetKey(), fieldValue, s.codec)
 			if indexName == "" {
@@ -346,18 +932,14 @@ func (s *etcdStore) findByFieldEq(findOpts *store.FindOpts, info *runtime.TypeIn
 			}
 			indexKey := "/index/" + indexName
 			indexValue := stm.Get(indexKey)
-			if indexValue != "" {
-				valueList := &store.IndexValueList{}
-				s.unmarshal([]byte(indexValue), valueList)
-				for _, val := range *valueList {
-					resultGens = append(resultGens, s.unmarshalGen(string(val)))
-				}
+			if indexValue != "" && index.Type == store.IndexTypeUniqueGen {
+				resultGens = append(resultGens, s.unmarshalGen(indexValue))
+			} else if indexValue != "" {
+				resultGens = append(resultGens, s.unmarshalGenList(indexValue)...)
 			}
 		}
 
-		sort.Slice(resultGens, func(i, j int) bool {
-			return resultGens[i] < resultGens[j]
-		})
+		resultGens = store.SortedUniqueGens(resultGens)
 
 		if len(resultGens) > 0 {
 			if findOpts.IsGetFirst() {
@@ -365,34 +947,312 @@ func (s *etcdStore) findByFieldEq(findOpts *store.FindOpts, info *runtime.TypeIn
 			} else if findOpts.IsGetLast() {
 				resultGens = []runtime.Generation{resultGens[len(resultGens)-1]}
 			}
-			for _, gen := range resultGens {
-				data := stm.Get("/object" + "/" + findOpts.GetKey() + "@" + gen.String())
-				if data == "" {
-					return fmt.Errorf("index is invalid :(")
-				}
-				result := info.New()
-				s.unmarshal([]byte(data), result)
-				addToResult(result)
+		}
+		resultGens = findOpts.PageGens(resultGens)
+
+		results = make([][]byte, 0, len(resultGens))
+		for _, gen := range resultGens {
+			data := stm.Get("/object" + "/" + findOpts.GetKey() + "@" + gen.String())
+			if data == "" {
+				return fmt.Errorf("index %s for %s seems to be corrupted: generation %s doesn't exist", findOpts.GetFieldEqName(), findOpts.GetKey(), gen)
 			}
+			results = append(results, []byte(data))
 		}
 
 		return nil
-	})
+	}), etcdconc.WithAbortContext(ctx))
 	if err != nil {
 		return err
 	}
 
+	if len(results) == 0 {
+		// single result is reset if nothing matches
+		addToResult(nil)
+	}
+	for _, data := range results {
+		result := info.New()
+		s.unmarshal(data, result)
+		addToResult(result)
+	}
+
 	return nil
 }
 
+// Delete removes object with a given key. Non-versioned object has a single static key, while for versioned object
+// all generations get removed along with all index entries referencing them inside a single transaction
 func (s *etcdStore) Delete(kind runtime.Kind, key runtime.Key) error {
 	info := s.types.Get(kind)
 
-	if info.Versioned {
-		return fmt.Errorf("versioned object couldn't be deleted using store.Delete, use deleted flag + store.Save instead")
+	ctx, cancel := s.newContext()
+	defer cancel()
+
+	if !info.Versioned {
+		_, err := s.client.KV.Delete(ctx, "/object"+"/"+key+"@"+runtime.LastOrEmptyGen.String())
+		return s.wrapError(ctx, err)
+	}
+
+	prefix := "/object" + "/" + key + "@"
+	indexes := store.IndexesFor(info)
+	_, err := etcdconc.NewSTM(s.client, s.retrying(store.OperationDelete, kind, func(stm etcdconc.STM) error {
+		lastGenKey := "/index/" + indexes.NameForValue(store.LastGenIndex, key, nil, s.codec)
+		if stm.Get(lastGenKey) == "" {
+			// nothing to delete
+			return nil
+		}
+
+		// generations are listed inside the transaction, so they are re-listed if it gets retried
+		resp, err := s.client.KV.Get(ctx, prefix, etcd.WithPrefix(), etcd.WithKeysOnly())
+		if err != nil {
+			return err
+		}
+		for _, kv := range resp.Kvs {
+			s.removeGen(stm, info, key, runtime.ParseGeneration(strings.TrimPrefix(string(kv.Key), prefix)))
+		}
+		stm.Del(lastGenKey)
+
+		return nil
+	}), etcdconc.WithAbortContext(ctx))
+
+	return s.wrapError(ctx, err)
+}
+
+// removeGen removes a single generation of a versioned object along with its entries in list and unique indexes.
+// Values of namespace-wide unique indexes are held by the last generation only, so they're released only when the
+// last generation is removed
+func (s *etcdStore) removeGen(stm etcdconc.STM, info *runtime.TypeInfo, key runtime.Key, gen runtime.Generation) {
+	objKey := "/object" + "/" + key + "@" + gen.String()
+	objRaw := stm.Get(objKey)
+	if objRaw == "" {
+		return
 	}
+	obj := info.New().(runtime.Storable) // nolint: errcheck
+	s.unmarshal([]byte(objRaw), obj)
 
-	_, err := s.client.KV.Delete(context.TODO(), "/object"+"/"+key+"@"+runtime.LastOrEmptyGen.String())
+	indexes := store.IndexesFor(info)
+	lastGenRaw := stm.Get("/index/" + indexes.NameForValue(store.LastGenIndex, key, nil, s.codec))
+	isLast := lastGenRaw != "" && s.unmarshalGen(lastGenRaw) == gen
 
-	return err
+	for _, index := range indexes.List {
+		indexName := index.NameForStorable(obj, s.codec)
+		if indexName == "" {
+			continue
+		}
+		indexKey := "/index/" + indexName
+		if index.Type == store.IndexTypeListGen {
+			s.updateIndex(stm, indexKey, gen, true)
+		} else if index.Type == store.IndexTypeUniqueGen {
+			if genRaw := stm.Get(indexKey); genRaw != "" && s.unmarshalGen(genRaw) == gen {
+				stm.Del(indexKey)
+			}
+		} else if index.Type == store.IndexTypeUniqueKey && isLast && stm.Get(indexKey) == key {
+			stm.Del(indexKey)
+		}
+	}
+
+	stm.Del(objKey)
+}
+
+// Compact removes old generations of a versioned object, while keeping the last keepLast generations and the ones
+// which should be retained. Existing generations are listed and removed along with their index entries inside a
+// single transaction, which reads the last generation index first, so it gets retried with generations re-listed if a
+// new generation is saved concurrently
+func (s *etcdStore) Compact(kind runtime.Kind, key runtime.Key, keepLast int, retain func(runtime.Generation) bool) ([]runtime.Generation, error) {
+	info := s.types.Get(kind)
+	if !info.Versioned {
+		return nil, fmt.Errorf("non versioned object %s couldn't be compacted, as it has a single generation only", key)
+	}
+	if keepLast < 1 {
+		return nil, fmt.Errorf("at least the last generation of object %s should be kept while compacting", key)
+	}
+
+	ctx, cancel := s.newContext()
+	defer cancel()
+
+	prefix := "/object" + "/" + key + "@"
+	indexes := store.IndexesFor(info)
+	var removed []runtime.Generation
+	_, err := etcdconc.NewSTM(s.client, s.retrying(store.OperationCompact, kind, func(stm etcdconc.STM) error {
+		removed = nil
+		if stm.Get("/index/"+indexes.NameForValue(store.LastGenIndex, key, nil, s.codec)) == "" {
+			// nothing to compact
+			return nil
+		}
+
+		// generations are listed inside the transaction, so they are re-listed if it gets retried
+		resp, err := s.client.KV.Get(ctx, prefix, etcd.WithPrefix(), etcd.WithKeysOnly())
+		if err != nil {
+			return err
+		}
+		gens := make([]runtime.Generation, 0, len(resp.Kvs))
+		for _, kv := range resp.Kvs {
+			gens = append(gens, runtime.ParseGeneration(strings.TrimPrefix(string(kv.Key), prefix)))
+		}
+
+		removed = store.GenerationsToCompact(gens, keepLast, retain)
+		for _, gen := range removed {
+			s.removeGen(stm, info, key, gen)
+		}
+
+		return nil
+	}), etcdconc.WithAbortContext(ctx))
+	if err != nil {
+		return nil, s.wrapError(ctx, err)
+	}
+
+	return removed, nil
+}
+
+// Keys returns sorted keys of all objects of a given kind
+func (s *etcdStore) Keys(kind runtime.Kind) ([]runtime.Key, error) {
+	ctx, cancel := s.newContext()
+	defer cancel()
+
+	resp, err := s.client.KV.Get(ctx, "/object/", etcd.WithPrefix(), etcd.WithKeysOnly())
+	if err != nil {
+		return nil, s.wrapError(ctx, err)
+	}
+
+	collector := store.NewKeysCollector(kind)
+	for _, kv := range resp.Kvs {
+		collector.Add(strings.TrimPrefix(string(kv.Key), "/object/"))
+	}
+
+	return collector.Keys(), nil
+}
+
+// rebuildTxnOps is the max number of index entries changed in a single transaction while rebuilding indexes. Every
+// changed entry adds a put/delete operation and a compare, which should fit the max number of operations in a single
+// etcd transaction (--max-txn-ops, 128 by default) together with the compare for objects
+const rebuildTxnOps = 100
+
+// RebuildIndexes recomputes index entries of all objects of a given kind and replaces existing ones. Objects and
+// indexes are read at the same revision and changes are applied in batches of transactions, each of them fails if any
+// object or any of the changed index entries was changed since then, so the rebuild never overwrites concurrent saves
+func (s *etcdStore) RebuildIndexes(kind runtime.Kind, dryRun bool) (*store.IndexRebuildResult, error) {
+	info := s.types.Get(kind)
+	if !info.Versioned {
+		return nil, fmt.Errorf("indexes of non versioned objects of kind %s couldn't be rebuilt, as they aren't indexed", kind)
+	}
+
+	ctx, cancel := s.newContext()
+	defer cancel()
+
+	objects, err := s.client.KV.Get(ctx, "/object/", etcd.WithPrefix())
+	if err != nil {
+		return nil, s.wrapError(ctx, err)
+	}
+	rev := objects.Header.Revision
+	indexes, err := s.client.KV.Get(ctx, "/index/", etcd.WithPrefix(), etcd.WithRev(rev))
+	if err != nil {
+		return nil, s.wrapError(ctx, err)
+	}
+
+	builder := store.NewIndexBuilder(info, s.codec)
+	for _, kv := range objects.Kvs {
+		objKey := strings.TrimPrefix(string(kv.Key), "/object/")
+		if !store.ObjectKeyHasKind(objKey, kind) {
+			continue
+		}
+		obj := info.New().(runtime.Storable) // nolint: errcheck
+		if err = s.codec.Unmarshal(kv.Value, obj); err != nil {
+			return nil, fmt.Errorf("error while decoding object %s: %s", objKey, err)
+		}
+		builder.Add(obj)
+	}
+
+	expected := map[string]*store.IndexEntry{}
+	for indexName, entry := range builder.Entries() {
+		expected["/index/"+indexName] = entry
+	}
+
+	result := &store.IndexRebuildResult{DryRun: dryRun}
+	current := map[string]string{}
+	ops := make([]etcd.Op, 0)
+	cmps := make([]etcd.Cmp, 0)
+	for _, kv := range indexes.Kvs {
+		indexKey := string(kv.Key)
+		if !store.IndexNameHasKind(strings.TrimPrefix(indexKey, "/index/"), kind) {
+			continue
+		}
+		current[indexKey] = string(kv.Value)
+		if _, exist := expected[indexKey]; !exist {
+			result.AddDangling(strings.TrimPrefix(indexKey, "/index/"))
+			ops = append(ops, etcd.OpDelete(indexKey))
+			cmps = append(cmps, etcd.Compare(etcd.ModRevision(indexKey), "=", kv.ModRevision))
+		}
+	}
+	for indexKey, entry := range expected {
+		if currentValue, exist := current[indexKey]; !exist {
+			result.AddMissing(strings.TrimPrefix(indexKey, "/index/"))
+		} else if !s.indexEntryMatches(entry, currentValue) {
+			result.AddDivergent(strings.TrimPrefix(indexKey, "/index/"))
+		} else {
+			continue
+		}
+		ops = append(ops, etcd.OpPut(indexKey, s.encodeIndexEntry(entry)))
+		// missing entry has zero mod revision and shouldn't be created in the meantime
+		cmps = append(cmps, etcd.Compare(etcd.ModRevision(indexKey), "<", rev+1))
+	}
+	result.Sort()
+
+	if dryRun {
+		return result, nil
+	}
+
+	for len(ops) > 0 {
+		batch := len(ops)
+		if batch > rebuildTxnOps {
+			batch = rebuildTxnOps
+		}
+
+		txnCmps := append([]etcd.Cmp{etcd.Compare(etcd.ModRevision("/object/"), "<", rev+1).WithPrefix()}, cmps[:batch]...)
+		resp, txnErr := s.client.KV.Txn(ctx).If(txnCmps...).Then(ops[:batch]...).Commit()
+		if txnErr != nil {
+			return nil, s.wrapError(ctx, txnErr)
+		}
+		if !resp.Succeeded {
+			return nil, fmt.Errorf("indexes of kind %s couldn't be rebuilt, as objects were changed during the rebuild", kind)
+		}
+
+		ops, cmps = ops[batch:], cmps[batch:]
+	}
+
+	return result, nil
+}
+
+// indexEntryMatches returns true if a given stored value of the index entry matches the expected entry. Lists of
+// generations are compared once decoded, as entries written before IndexGenList was introduced hold the same list in
+// the different encoding
+func (s *etcdStore) indexEntryMatches(entry *store.IndexEntry, value string) bool {
+	if entry.Type != store.IndexTypeListGen {
+		return s.encodeIndexEntry(entry) == value
+	}
+
+	genList, err := s.decodeGenList(value)
+	if err != nil || len(genList) != len(entry.Gens) {
+		return false
+	}
+	for _, gen := range entry.Gens {
+		if !genList.Contains(gen) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// encodeIndexEntry returns stored value of the index entry the same way as it's stored while saving objects
+func (s *etcdStore) encodeIndexEntry(entry *store.IndexEntry) string {
+	switch entry.Type {
+	case store.IndexTypeListGen:
+		genList := store.IndexGenList{}
+		for _, gen := range entry.Gens {
+			genList.Add(gen)
+		}
+		return s.marshalGenList(genList)
+	case store.IndexTypeUniqueKey:
+		return entry.Key
+	default:
+		return s.marshalGen(entry.Gens[0])
+	}
 }
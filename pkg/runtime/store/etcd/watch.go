@@ -0,0 +1,189 @@
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	etcd "github.com/coreos/etcd/clientv3"
+	log "github.com/sirupsen/logrus"
+)
+
+// watchRetryInterval is how long to wait before restarting failed watch
+const watchRetryInterval = time.Second
+
+// Watch watches objects of a given kind with keys prefixed by keyPrefix using etcd watcher. If watcher falls behind
+// compacted etcd history or fails, it's transparently restarted
+func (s *etcdStore) Watch(ctx context.Context, kind runtime.Kind, keyPrefix runtime.Key) (<-chan *store.WatchEvent, error) {
+	info := s.types.Get(kind)
+	keyPrefix = strings.TrimPrefix(keyPrefix, "/")
+	if keyPrefix == "" {
+		return nil, fmt.Errorf("key prefix is required to watch objects of kind %s", kind)
+	}
+
+	// only changes made after the current revision are watched
+	opCtx, cancel := s.newContext()
+	resp, err := s.client.KV.Get(opCtx, "/", etcd.WithCountOnly())
+	cancel()
+	if err != nil {
+		return nil, s.wrapError(opCtx, err)
+	}
+
+	events := store.NewWatchChannel()
+	go s.watch(ctx, info, "/object"+"/"+keyPrefix, resp.Header.Revision+1, events)
+
+	return events, nil
+}
+
+func (s *etcdStore) watch(ctx context.Context, info *runtime.TypeInfo, prefix string, rev int64, events chan<- *store.WatchEvent) {
+	defer close(events)
+
+	for ctx.Err() == nil {
+		watchCtx, cancel := context.WithCancel(ctx)
+		watchChan := s.client.Watcher.Watch(etcd.WithRequireLeader(watchCtx), prefix, etcd.WithPrefix(), etcd.WithPrevKV(), etcd.WithRev(rev))
+		var restart bool
+		rev, restart = s.handleWatch(ctx, info, prefix, watchChan, rev, events)
+		cancel()
+
+		if !restart {
+			select {
+			case <-ctx.Done():
+			case <-time.After(watchRetryInterval):
+			}
+		}
+	}
+}
+
+// handleWatch delivers events from a single etcd watch until it's closed or fails. It returns revision to restart
+// watching from and true if watch could be restarted right away
+func (s *etcdStore) handleWatch(ctx context.Context, info *runtime.TypeInfo, prefix string, watchChan etcd.WatchChan, rev int64, events chan<- *store.WatchEvent) (int64, bool) {
+	for resp := range watchChan {
+		if resp.CompactRevision != 0 {
+			log.Warnf("Watch for %s fell behind compacted revision %d, restarting from it", prefix, resp.CompactRevision)
+			return resp.CompactRevision, true
+		}
+		if err := resp.Err(); err != nil {
+			log.Warnf("Watch for %s failed, restarting: %s", prefix, err)
+			return rev, false
+		}
+
+		// all generations of a versioned object are removed in a single transaction, but deletion is reported once
+		deleted := deletedGens(resp.Events)
+		for _, ev := range resp.Events {
+			event := s.toWatchEvent(ctx, info, ev, deleted)
+			if event == nil {
+				continue
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return rev, false
+			}
+		}
+		if len(resp.Events) > 0 {
+			rev = resp.Events[len(resp.Events)-1].Kv.ModRevision + 1
+		}
+	}
+
+	return rev, false
+}
+
+// deletedGens returns the last deleted generation of every object deleted by given events, so the deletion could be
+// reported once with the last generation of the object, while generations are deleted in the order of their keys
+func deletedGens(events []*etcd.Event) map[runtime.Key]runtime.Generation {
+	result := make(map[runtime.Key]runtime.Generation)
+	for _, ev := range events {
+		if ev.Type != etcd.EventTypeDelete {
+			continue
+		}
+		key, gen, ok := parseObjectKey(ev.Kv.Key)
+		if !ok {
+			continue
+		}
+		if lastGen, found := result[key]; !found || gen > lastGen {
+			result[key] = gen
+		}
+	}
+
+	return result
+}
+
+// parseObjectKey returns object key and generation from the etcd key of the object, last returned value is false if
+// it's not a key of the object
+func parseObjectKey(etcdKey []byte) (runtime.Key, runtime.Generation, bool) {
+	objKey := strings.TrimPrefix(string(etcdKey), "/object/")
+	sepIdx := strings.LastIndex(objKey, "@")
+	if sepIdx < 0 {
+		return "", 0, false
+	}
+
+	return objKey[:sepIdx], runtime.ParseGeneration(objKey[sepIdx+1:]), true
+}
+
+func (s *etcdStore) toWatchEvent(ctx context.Context, info *runtime.TypeInfo, ev *etcd.Event, deleted map[runtime.Key]runtime.Generation) *store.WatchEvent {
+	key, gen, ok := parseObjectKey(ev.Kv.Key)
+	if !ok {
+		return nil
+	}
+	event := &store.WatchEvent{
+		Key: key,
+		Gen: gen,
+	}
+	// key prefix could match keys of other kinds sharing the same prefix (e.g. desired-state and desired-state-index)
+	if parts := strings.SplitN(event.Key, runtime.KeySeparator, 3); len(parts) < 2 || parts[1] != info.Kind {
+		return nil
+	}
+
+	if ev.Type == etcd.EventTypeDelete {
+		if deleted[event.Key] != event.Gen {
+			return nil
+		}
+		// removal of old generations of versioned object (e.g. compaction) isn't a deletion of the object
+		if info.Versioned && !s.isDeletedAt(ctx, info, event.Key, ev.Kv.ModRevision) {
+			return nil
+		}
+		event.Type = store.EventDeleted
+		if ev.PrevKv != nil {
+			event.Object = s.decodeWatched(info, event.Key, ev.PrevKv.Value)
+		}
+		return event
+	}
+
+	event.Type = store.EventUpdated
+	if ev.IsCreate() && (!info.Versioned || event.Gen == runtime.FirstGen) {
+		event.Type = store.EventCreated
+	}
+	event.Object = s.decodeWatched(info, event.Key, ev.Kv.Value)
+	if event.Object == nil {
+		return nil
+	}
+
+	return event
+}
+
+// isDeletedAt checks that versioned object doesn't exist anymore (there is no last gen index) at a given revision
+func (s *etcdStore) isDeletedAt(ctx context.Context, info *runtime.TypeInfo, key runtime.Key, rev int64) bool {
+	opCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	indexKey := "/index/" + store.IndexesFor(info).NameForValue(store.LastGenIndex, key, nil, s.codec)
+	resp, err := s.client.KV.Get(opCtx, indexKey, etcd.WithRev(rev), etcd.WithCountOnly())
+	if err != nil {
+		log.Warnf("Can't check whether %s is deleted at revision %d: %s", key, rev, s.wrapError(opCtx, err))
+		return false
+	}
+
+	return resp.Count == 0
+}
+
+func (s *etcdStore) decodeWatched(info *runtime.TypeInfo, key runtime.Key, data []byte) runtime.Object {
+	obj := info.New()
+	if err := s.codec.Unmarshal(data, obj); err != nil {
+		log.Warnf("Can't decode watched object %s: %s", key, err)
+		return nil
+	}
+	return obj
+}
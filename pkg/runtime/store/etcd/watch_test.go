@@ -0,0 +1,79 @@
+package etcd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/storetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEtcdStoreWatch(t *testing.T) {
+	cfg := Config{
+		Compactor: CompactorConfig{Disabled: true},
+	}
+	// kind sharing the same key prefix with items, which changes shouldn't be reported
+	typeItemNote := &runtime.TypeInfo{
+		Kind:        storetest.TypeItem.Kind + "-note",
+		Storable:    true,
+		Constructor: func() runtime.Object { return &storetest.Note{} },
+	}
+	types := runtime.NewTypes().Append(storetest.TypeItem, typeItemNote)
+	s := newTestStore(t, cfg, types, store.NewGobCodec(types))
+	defer s.Close() // nolint: errcheck
+
+	// make sure there is no object left from previous runs
+	if !assert.NoError(t, s.Delete(storetest.TypeItem.Kind, testItemKey), "Object should be deleted") {
+		t.FailNow()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := s.Watch(ctx, storetest.TypeItem.Kind, testItemKey)
+	if !assert.NoError(t, err, "Watch should be started") {
+		t.FailNow()
+	}
+
+	_, err = s.Save(&storetest.Note{TypeKind: typeItemNote.GetTypeKind(), Name: "note"})
+	assert.NoError(t, err, "Object of other kind should be saved")
+
+	item := newTestItem("waiting", "")
+	_, err = s.Save(item)
+	assert.NoError(t, err, "Object should be saved")
+	item.Status = "done"
+	_, err = s.Save(item)
+	assert.NoError(t, err, "Object should be updated")
+	assert.NoError(t, s.Delete(storetest.TypeItem.Kind, testItemKey), "Object should be deleted")
+
+	expected := []struct {
+		eventType store.EventType
+		gen       runtime.Generation
+		status    string
+	}{
+		{store.EventCreated, 1, "waiting"},
+		{store.EventUpdated, 2, "done"},
+		{store.EventDeleted, 2, "done"},
+	}
+	for _, exp := range expected {
+		var event *store.WatchEvent
+		select {
+		case event = <-events:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Watch event wasn't delivered")
+		}
+		assert.Equal(t, exp.eventType, event.Type, "Event type should be correct")
+		assert.Equal(t, testItemKey, event.Key, "Event key should be correct")
+		assert.Equal(t, exp.gen, event.Gen, "Event generation should be correct")
+		if assert.NotNil(t, event.Object, "Event should include object") {
+			assert.Equal(t, exp.status, event.Object.(*storetest.Item).Status, "Event object should be decoded")
+		}
+	}
+
+	cancel()
+	for range events {
+		// drain until closed
+	}
+}
@@ -1,6 +1,12 @@
 package store
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/Aptomi/aptomi/pkg/runtime"
 )
 
@@ -12,10 +18,20 @@ type FindOpts struct {
 	keyPrefix     runtime.Key
 	key           runtime.Key
 	gen           runtime.Generation
+	genRange      bool
+	genFrom       runtime.Generation
+	genTo         runtime.Generation
 	fieldEqName   string
 	fieldEqValues []interface{}
 	getLast       bool
 	getFirst      bool
+	failedKeys    *[]runtime.Key
+	limit         int
+	continueAfter runtime.Key
+	// continueToken is decoded while validating find options into continueAfter or continueAfterGen
+	continueToken    string
+	continueAfterGen runtime.Generation
+	nextToken        *string
 }
 
 // GetKeyPrefix returns key prefix to find objects with keys prefixed by it
@@ -33,6 +49,23 @@ func (opts *FindOpts) GetGen() runtime.Generation {
 	return opts.gen
 }
 
+// IsGenRange returns true if generations of the object within the range defined by WithGenRange should be found
+func (opts *FindOpts) IsGenRange() bool {
+	return opts.genRange
+}
+
+// GensInRange returns generations within the range defined by WithGenRange (both boundaries are inclusive) sorted in
+// ascending order
+func (opts *FindOpts) GensInRange(gens []runtime.Generation) []runtime.Generation {
+	result := make([]runtime.Generation, 0)
+	for _, gen := range gens {
+		if gen >= opts.genFrom && gen <= opts.genTo {
+			result = append(result, gen)
+		}
+	}
+	return SortedUniqueGens(result)
+}
+
 // GetFieldEqName returns name of the field to find object with this field equal to some value
 func (opts *FindOpts) GetFieldEqName() string {
 	return opts.fieldEqName
@@ -43,16 +76,172 @@ func (opts *FindOpts) GetFieldEqValues() []interface{} {
 	return opts.fieldEqValues
 }
 
-// IsGetFirst returns true if first result should be returned
+// IsGetFirst returns true if only the first (with the lowest generation) of the matching generations should be returned
 func (opts *FindOpts) IsGetFirst() bool {
 	return opts.getFirst
 }
 
-// IsGetLast returns true if last result should be returned
+// IsGetLast returns true if only the last (with the highest generation) of the matching generations should be returned
 func (opts *FindOpts) IsGetLast() bool {
 	return opts.getLast
 }
 
+// GetLimit returns max number of objects to find (0 if not limited)
+func (opts *FindOpts) GetLimit() int {
+	return opts.limit
+}
+
+// GetContinueAfter returns key of the object, after which objects should be found (e.g. key of the last object of
+// the previous page)
+func (opts *FindOpts) GetContinueAfter() runtime.Key {
+	return opts.continueAfter
+}
+
+// GetContinueAfterGen returns generation, after which generations found with WithWhereEq should be returned (e.g. last
+// generation of the previous page)
+func (opts *FindOpts) GetContinueAfterGen() runtime.Generation {
+	return opts.continueAfterGen
+}
+
+// SetNextKey is called by store implementations when the page of objects found with WithKeyPrefix and WithLimit is
+// full, so the token to continue after an object with a given key is returned to the caller (if requested)
+func (opts *FindOpts) SetNextKey(key runtime.Key) {
+	if opts.nextToken != nil {
+		*opts.nextToken = opts.encodeContinueToken(&continueToken{Key: key})
+	}
+}
+
+// SortedUniqueGens sorts generations found by index values and removes duplicates, which appear if the same generation
+// is found by more than one value
+func SortedUniqueGens(gens []runtime.Generation) []runtime.Generation {
+	sort.Slice(gens, func(i, j int) bool {
+		return gens[i] < gens[j]
+	})
+
+	result := make([]runtime.Generation, 0, len(gens))
+	for _, gen := range gens {
+		if len(result) == 0 || result[len(result)-1] != gen {
+			result = append(result, gen)
+		}
+	}
+	return result
+}
+
+// PageGens returns a page of generations found with WithWhereEq. Generations should be sorted. Generations up to the
+// one defined by the continue token are skipped and the rest is limited by WithLimit (if used), while the token to
+// continue after the last returned generation is returned to the caller (if requested) when the page is full
+func (opts *FindOpts) PageGens(gens []runtime.Generation) []runtime.Generation {
+	if opts.continueAfterGen != 0 {
+		for len(gens) > 0 && gens[0] <= opts.continueAfterGen {
+			gens = gens[1:]
+		}
+	}
+	if opts.limit > 0 && len(gens) >= opts.limit {
+		gens = gens[:opts.limit]
+		if opts.nextToken != nil {
+			*opts.nextToken = opts.encodeContinueToken(&continueToken{Gen: gens[len(gens)-1]})
+		}
+	}
+	return gens
+}
+
+// IsPartialResults returns true if objects, which can't be decoded, should be skipped instead of failing the whole find
+func (opts *FindOpts) IsPartialResults() bool {
+	return opts.failedKeys != nil
+}
+
+// HandleDecodeError handles an error of decoding a single object while scanning over a range of keys. If partial
+// results are requested, key gets recorded as failed and nil is returned, so the rest of objects could still be
+// returned. Otherwise the error gets returned
+func (opts *FindOpts) HandleDecodeError(key runtime.Key, err error) error {
+	if opts.failedKeys == nil {
+		return fmt.Errorf("error while decoding object %s: %s", key, err)
+	}
+	*opts.failedKeys = append(*opts.failedKeys, key)
+	return nil
+}
+
+// GenNotFoundError is returned when a specific generation of an object is requested, but there is no such generation
+// stored (e.g. it was never saved or it was compacted)
+type GenNotFoundError struct {
+	Kind runtime.Kind
+	Key  runtime.Key
+	Gen  runtime.Generation
+}
+
+func (err *GenNotFoundError) Error() string {
+	return fmt.Sprintf("generation %s of object %s (kind %s) not found", err.Gen, err.Key, err.Kind)
+}
+
+// IsGenNotFound returns true if a given error is GenNotFoundError
+func IsGenNotFound(err error) bool {
+	_, ok := err.(*GenNotFoundError)
+	return ok
+}
+
+// InvalidContinueTokenError is returned when continue token passed with WithContinue can't be decoded or it was
+// issued for another query
+type InvalidContinueTokenError struct {
+	Token  string
+	Reason string
+}
+
+func (err *InvalidContinueTokenError) Error() string {
+	return fmt.Sprintf("invalid continue token %q: %s", err.Token, err.Reason)
+}
+
+// IsInvalidContinueToken returns true if a given error is InvalidContinueTokenError
+func IsInvalidContinueToken(err error) bool {
+	_, ok := err.(*InvalidContinueTokenError)
+	return ok
+}
+
+// continueToken is a position in the list of objects (key) or generations (gen) to continue paging after. It's
+// encoded together with the query it was issued for, so it couldn't be used with another query
+type continueToken struct {
+	Query string             `json:"q"`
+	Key   runtime.Key        `json:"k,omitempty"`
+	Gen   runtime.Generation `json:"g,omitempty"`
+}
+
+// query returns string representation of the query defined by find options, it's the same for the identical queries
+func (opts *FindOpts) query() string {
+	if opts.keyPrefix != "" {
+		return "prefix:" + opts.keyPrefix
+	}
+	return fmt.Sprintf("key:%s/%s=%v", opts.key, opts.fieldEqName, opts.fieldEqValues)
+}
+
+func (opts *FindOpts) encodeContinueToken(token *continueToken) string {
+	token.Query = opts.query()
+	data, err := json.Marshal(token)
+	if err != nil {
+		panic(fmt.Sprintf("error while encoding continue token: %s", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func (opts *FindOpts) decodeContinueToken() error {
+	data, err := base64.RawURLEncoding.DecodeString(opts.continueToken)
+	if err != nil {
+		return &InvalidContinueTokenError{Token: opts.continueToken, Reason: err.Error()}
+	}
+	token := &continueToken{}
+	if err = json.Unmarshal(data, token); err != nil {
+		return &InvalidContinueTokenError{Token: opts.continueToken, Reason: err.Error()}
+	}
+	if token.Query != opts.query() {
+		return &InvalidContinueTokenError{Token: opts.continueToken, Reason: "it was issued for another query"}
+	}
+	if opts.keyPrefix != "" && token.Key == "" || opts.keyPrefix == "" && token.Gen == 0 {
+		return &InvalidContinueTokenError{Token: opts.continueToken, Reason: "it has no position to continue after"}
+	}
+
+	opts.continueAfter = token.Key
+	opts.continueAfterGen = token.Gen
+	return nil
+}
+
 // NewFindOpts creates FindOpts (object find process config) from list of FindOpt (object find process config modifiers)
 func NewFindOpts(opts []FindOpt) *FindOpts {
 	findOpts := &FindOpts{}
@@ -63,6 +252,130 @@ func NewFindOpts(opts []FindOpt) *FindOpts {
 	return findOpts
 }
 
+// ValidateIter checks that find options make sense for iterating over objects of a given type
+func (opts *FindOpts) ValidateIter(info *runtime.TypeInfo) error {
+	if opts.keyPrefix == "" {
+		return fmt.Errorf("WithKeyPrefix should be used to iterate over objects of kind %s", info.Kind)
+	}
+	if info.Versioned {
+		return fmt.Errorf("can't iterate over objects of versioned kind %s (only the latest generations of non-versioned objects could be scanned)", info.Kind)
+	}
+	if opts.limit > 0 || opts.continueAfter != "" || opts.continueToken != "" {
+		return fmt.Errorf("can't use WithLimit, WithContinueAfter or WithContinue to iterate over objects of kind %s (all objects are scanned in batches anyway)", info.Kind)
+	}
+	return opts.Validate(info)
+}
+
+// Validate checks that find options make sense in combination with each other and for objects of a given type
+func (opts *FindOpts) Validate(info *runtime.TypeInfo) error {
+	if opts.key == "" && opts.keyPrefix == "" {
+		return fmt.Errorf("either WithKey or WithKeyPrefix should be used to find objects of kind %s", info.Kind)
+	}
+	if opts.key != "" && opts.keyPrefix != "" {
+		return fmt.Errorf("can't use WithKey and WithKeyPrefix together to find objects of kind %s", info.Kind)
+	}
+
+	// key prefix could be used only to list all objects with their latest generations
+	if opts.keyPrefix != "" {
+		if opts.gen != 0 {
+			return fmt.Errorf("can't use WithGen with WithKeyPrefix to find objects of kind %s (generations could only be searched for a single key)", info.Kind)
+		}
+		if opts.genRange {
+			return fmt.Errorf("can't use WithGenRange with WithKeyPrefix to find objects of kind %s (generations could only be searched for a single key)", info.Kind)
+		}
+		if opts.fieldEqName != "" {
+			return fmt.Errorf("can't use WithWhereEq with WithKeyPrefix to find objects of kind %s (it's only for searching generations of a single key)", info.Kind)
+		}
+		if opts.getFirst || opts.getLast {
+			return fmt.Errorf("can't use WithGetFirst or WithGetLast with WithKeyPrefix to find objects of kind %s", info.Kind)
+		}
+		if opts.continueAfter != "" && !strings.HasPrefix(opts.continueAfter, opts.keyPrefix) {
+			return fmt.Errorf("can't use WithContinueAfter with key %s, which doesn't match WithKeyPrefix %s, to find objects of kind %s", opts.continueAfter, opts.keyPrefix, info.Kind)
+		}
+	} else if opts.failedKeys != nil {
+		return fmt.Errorf("can't use WithPartialResults without WithKeyPrefix to find objects of kind %s (it's only for scanning over a range of keys)", info.Kind)
+	} else if opts.continueAfter != "" {
+		return fmt.Errorf("can't use WithContinueAfter without WithKeyPrefix to find objects of kind %s (use WithContinue to page through generations)", info.Kind)
+	} else if (opts.limit > 0 || opts.continueToken != "") && opts.fieldEqName == "" {
+		return fmt.Errorf("can't use WithLimit or WithContinue without WithKeyPrefix or WithWhereEq to find objects of kind %s (only lists of objects or generations could be paged)", info.Kind)
+	}
+
+	// non-versioned objects have a single generation only
+	if !info.Versioned {
+		if opts.gen != 0 {
+			return fmt.Errorf("can't use WithGen to find objects of non-versioned kind %s", info.Kind)
+		}
+		if opts.genRange {
+			return fmt.Errorf("can't use WithGenRange to find objects of non-versioned kind %s", info.Kind)
+		}
+		if opts.fieldEqName != "" {
+			return fmt.Errorf("can't use WithWhereEq to find objects of non-versioned kind %s (it's only for searching generations)", info.Kind)
+		}
+		if opts.getFirst || opts.getLast {
+			return fmt.Errorf("can't use WithGetFirst or WithGetLast to find objects of non-versioned kind %s", info.Kind)
+		}
+	}
+
+	if opts.gen != 0 && (opts.getFirst || opts.getLast) {
+		return fmt.Errorf("can't use WithGetFirst or WithGetLast with WithGen to find objects of kind %s (only a single generation is returned)", info.Kind)
+	}
+	if opts.gen != 0 && opts.fieldEqName != "" {
+		return fmt.Errorf("can't use WithWhereEq with WithGen to find objects of kind %s", info.Kind)
+	}
+	if opts.genRange && (opts.gen != 0 || opts.fieldEqName != "" || opts.getFirst || opts.getLast) {
+		return fmt.Errorf("can't use WithGenRange with WithGen, WithWhereEq, WithGetFirst or WithGetLast to find objects of kind %s", info.Kind)
+	}
+	if opts.getFirst && opts.getLast {
+		return fmt.Errorf("can't use WithGetFirst and WithGetLast together to find objects of kind %s", info.Kind)
+	}
+	if (opts.getFirst || opts.getLast) && opts.key != "" && opts.fieldEqName == "" {
+		return fmt.Errorf("can't use WithGetFirst or WithGetLast without WithWhereEq to find objects of kind %s (use WithKey only to find the last generation)", info.Kind)
+	}
+	if (opts.getFirst || opts.getLast) && (opts.limit > 0 || opts.continueToken != "") {
+		return fmt.Errorf("can't use WithLimit or WithContinue with WithGetFirst or WithGetLast to find objects of kind %s", info.Kind)
+	}
+	if opts.continueToken != "" && opts.continueAfter != "" {
+		return fmt.Errorf("can't use WithContinue and WithContinueAfter together to find objects of kind %s", info.Kind)
+	}
+	if opts.nextToken != nil && opts.limit == 0 {
+		return fmt.Errorf("can't use WithNextToken without WithLimit to find objects of kind %s (there are no pages without limit)", info.Kind)
+	}
+
+	// only indexed fields could be searched by
+	if opts.fieldEqName != "" {
+		index, exist := IndexesFor(info).List[opts.fieldEqName]
+		if !exist {
+			return fmt.Errorf("can't use WithWhereEq to find objects of kind %s by field %s, which isn't indexed (field should be tagged with `store:\"index\"`)", info.Kind, opts.fieldEqName)
+		}
+		if index.Type == IndexTypeUniqueKey {
+			return fmt.Errorf("can't use WithWhereEq to find objects of kind %s by field %s with namespace-wide unique index (it's only for searching generations of a single key)", info.Kind, opts.fieldEqName)
+		}
+		if index.IsComposite() {
+			for _, value := range opts.fieldEqValues {
+				if _, err := index.GroupValue(value); err != nil {
+					return fmt.Errorf("can't use WithWhereEq to find objects of kind %s by field group %s: %s", info.Kind, opts.fieldEqName, err)
+				}
+			}
+		}
+	}
+
+	if opts.continueToken != "" {
+		if err := opts.decodeContinueToken(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateResult checks that find options could be used with a given result, which is either a pointer to a single
+// object or a pointer to a slice of them. WithGetFirst and WithGetLast always return a single object
+func (opts *FindOpts) ValidateResult(info *runtime.TypeInfo, list bool) error {
+	if list && (opts.getFirst || opts.getLast) {
+		return fmt.Errorf("can't use WithGetFirst or WithGetLast to find a list of objects of kind %s (pointer to a single object should be passed as a result)", info.Kind)
+	}
+	return nil
+}
+
 // WithKey defines key to find objects with it
 func WithKey(key runtime.Key) FindOpt {
 	return func(opts *FindOpts) {
@@ -74,29 +387,21 @@ func WithKey(key runtime.Key) FindOpt {
 	}
 }
 
-// WithKeyPrefix defines key prefix to find objects with keys prefixed with it
+// WithKeyPrefix defines key prefix to find objects with keys prefixed with it. Leading slash is trimmed, as keys are
+// stored without it (e.g. "ns/kind/")
 func WithKeyPrefix(keyPrefix runtime.Key) FindOpt {
 	return func(opts *FindOpts) {
-		if opts.key != "" {
-			panic("can't use WithKeyPrefix with key specified")
-		}
 		if opts.keyPrefix != "" {
 			panic("can't use WithKeyPrefix more then one time")
 		}
 
-		opts.keyPrefix = keyPrefix
+		opts.keyPrefix = strings.TrimPrefix(keyPrefix, "/")
 	}
 }
 
 // WithGen defines generation to find object with it
 func WithGen(gen runtime.Generation) FindOpt {
 	return func(opts *FindOpts) {
-		if opts.key == "" {
-			panic("can't use WithGen without WithKey (key isn't set)")
-		}
-		if opts.keyPrefix != "" {
-			panic("can't use WithGen with key prefix specified")
-		}
 		if opts.gen != 0 {
 			panic("can't use WithGen more then one time")
 		}
@@ -105,42 +410,58 @@ func WithGen(gen runtime.Generation) FindOpt {
 	}
 }
 
-// WithWhereEq defines field name and values to find objects with this field equals to at least one of the specified values
-func WithWhereEq(name string, values ...interface{}) FindOpt {
+// WithGenRange defines range of generations to find for the object with a given key. Both from and to generations
+// are inclusive, so WithGenRange(10, 20) returns generations 10 through 20 (those which exist). Generations are
+// returned sorted in ascending order and if none of them exists (or from is greater than to) the result is empty
+func WithGenRange(from runtime.Generation, to runtime.Generation) FindOpt {
 	return func(opts *FindOpts) {
-		if name == "" {
-			panic("can't use WithWhereEq with empty field name")
+		if opts.genRange {
+			panic("can't use WithGenRange more then one time")
 		}
+
+		opts.genRange = true
+		opts.genFrom = from
+		opts.genTo = to
+	}
+}
+
+// WithWhereEq defines field name and values to find objects with this field equals to at least one of the specified values.
+// Name of the index group (or of the composite index, e.g. "ClusterName+Namespace") could be used as well to find objects
+// by composite index, values should be GroupValue or slices of values in the order of index fields then
+func WithWhereEq(name string, values ...interface{}) FindOpt {
+	return func(opts *FindOpts) {
 		if len(values) == 0 {
 			panic("can't use WithWhereEq without at least single value")
 		}
-		if opts.key == "" {
-			panic("can't use WithWhereEq without specified key (it's only for searching generations now)")
-		}
-		if opts.keyPrefix != "" {
-			panic("can't use WithWhereEq with key prefix specified (it's only for searching generations now)")
-		}
-		if opts.fieldEqName != "" {
-			panic("can't use WithWhereEq more then one time")
-		}
 
-		opts.fieldEqName = name
-		opts.fieldEqValues = values
+		whereIn(opts, "WithWhereEq", name, values)
+	}
+}
+
+// WithWhereIn defines field name and set of values to find objects with this field equals to any of the values. Values
+// could overlap, while every generation is returned only once. Empty set of values matches nothing
+func WithWhereIn(name string, values ...interface{}) FindOpt {
+	return func(opts *FindOpts) {
+		whereIn(opts, "WithWhereIn", name, values)
 	}
 }
 
-// WithGetFirst defines that first result should be returned
+func whereIn(opts *FindOpts, option string, name string, values []interface{}) {
+	if name == "" {
+		panic(fmt.Sprintf("can't use %s with empty field name", option))
+	}
+	if opts.fieldEqName != "" {
+		panic("can't use WithWhereEq or WithWhereIn more then one time")
+	}
+
+	opts.fieldEqName = name
+	opts.fieldEqValues = values
+}
+
+// WithGetFirst defines that only the matching generation with the lowest number should be returned. It requires
+// WithWhereEq and a pointer to a single object as a result
 func WithGetFirst() FindOpt {
 	return func(opts *FindOpts) {
-		if opts.key == "" {
-			panic("can't use WithGetFirst without WithKey (key isn't set)")
-		}
-		if opts.gen != 0 {
-			panic("can't use WithGetFirst when WithGen already used")
-		}
-		if opts.getLast {
-			panic("can't use WithGetFirst when WithGetLast already used")
-		}
 		if opts.getFirst {
 			panic("can't use WithGetFirst more then one time")
 		}
@@ -149,18 +470,10 @@ func WithGetFirst() FindOpt {
 	}
 }
 
-// WithGetLast defines that last result should be returned
+// WithGetLast defines that only the matching generation with the highest number should be returned. It requires
+// WithWhereEq and a pointer to a single object as a result
 func WithGetLast() FindOpt {
 	return func(opts *FindOpts) {
-		if opts.key == "" {
-			panic("can't use WithGetLast without WithKey (key isn't set)")
-		}
-		if opts.gen != 0 {
-			panic("can't use WithGetLast when WithGen already used")
-		}
-		if opts.getFirst {
-			panic("can't use WithGetLast when WithGetFirst already used")
-		}
 		if opts.getLast {
 			panic("can't use WithGetLast more then one time")
 		}
@@ -168,3 +481,78 @@ func WithGetLast() FindOpt {
 		opts.getLast = true
 	}
 }
+
+// WithPartialResults defines that objects, which can't be decoded while scanning over a range of keys, should be
+// skipped and their keys should be added to a given list, instead of failing the whole find
+func WithPartialResults(failedKeys *[]runtime.Key) FindOpt {
+	return func(opts *FindOpts) {
+		if failedKeys == nil {
+			panic("can't use WithPartialResults with nil list of failed keys")
+		}
+		if opts.failedKeys != nil {
+			panic("can't use WithPartialResults more then one time")
+		}
+
+		opts.failedKeys = failedKeys
+	}
+}
+
+// WithLimit defines max number of objects to find with WithKeyPrefix or generations to find with WithWhereEq. Objects
+// are always ordered by key as they're stored (for non-versioned objects key is followed by "@" and generation) and
+// generations are ordered by generation, so objects could be paged through by using WithContinue with the token
+// returned by WithNextToken (or WithContinueAfter with the key of the last object of the previous page)
+func WithLimit(limit int) FindOpt {
+	return func(opts *FindOpts) {
+		if limit <= 0 {
+			panic("can't use WithLimit with non-positive limit")
+		}
+		if opts.limit != 0 {
+			panic("can't use WithLimit more then one time")
+		}
+
+		opts.limit = limit
+	}
+}
+
+// WithContinueAfter defines that only objects stored after the object with a given key should be found with
+// WithKeyPrefix. Object with a given key doesn't need to exist, so paging is stable even if objects are deleted
+// between requests. Leading slash is trimmed the same way as for WithKeyPrefix
+func WithContinueAfter(key runtime.Key) FindOpt {
+	return func(opts *FindOpts) {
+		if opts.continueAfter != "" {
+			panic("can't use WithContinueAfter more then one time")
+		}
+
+		opts.continueAfter = strings.TrimPrefix(key, "/")
+	}
+}
+
+// WithContinue defines that objects should be found after the position, where the previous page found with the same
+// options ended. Token is the one returned by WithNextToken, it's opaque and the same for the identical queries.
+// If token can't be decoded or it was issued for another query, InvalidContinueTokenError is returned
+func WithContinue(token string) FindOpt {
+	return func(opts *FindOpts) {
+		if opts.continueToken != "" {
+			panic("can't use WithContinue more then one time")
+		}
+
+		opts.continueToken = token
+	}
+}
+
+// WithNextToken defines that the token to find the next page of objects with WithContinue should be set to a given
+// string. It's set to empty string if there are no more pages (token could still point to the empty page if the last
+// page was exactly full)
+func WithNextToken(next *string) FindOpt {
+	return func(opts *FindOpts) {
+		if next == nil {
+			panic("can't use WithNextToken with nil token")
+		}
+		if opts.nextToken != nil {
+			panic("can't use WithNextToken more then one time")
+		}
+
+		*next = ""
+		opts.nextToken = next
+	}
+}
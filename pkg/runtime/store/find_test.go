@@ -0,0 +1,123 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindOptsValidate(t *testing.T) {
+	versioned := engine.TypeRevision
+	nonVersioned := resolve.TypeComponentInstance
+
+	valid := []struct {
+		info *runtime.TypeInfo
+		opts []store.FindOpt
+	}{
+		{versioned, []store.FindOpt{store.WithKey(engine.RevisionKey)}},
+		{versioned, []store.FindOpt{store.WithKey(engine.RevisionKey), store.WithGen(1)}},
+		{versioned, []store.FindOpt{store.WithKey(engine.RevisionKey), store.WithWhereEq("PolicyGen", 1), store.WithGetLast()}},
+		{versioned, []store.FindOpt{store.WithKey(engine.RevisionKey), store.WithWhereEq("Status", "waiting"), store.WithGetFirst()}},
+		{nonVersioned, []store.FindOpt{store.WithKey("key")}},
+		{nonVersioned, []store.FindOpt{store.WithKeyPrefix("prefix")}},
+		{nonVersioned, []store.FindOpt{store.WithKeyPrefix("prefix"), store.WithPartialResults(&[]runtime.Key{})}},
+		{versioned, []store.FindOpt{store.WithKeyPrefix("prefix")}},
+		{versioned, []store.FindOpt{store.WithKeyPrefix("prefix"), store.WithLimit(10), store.WithContinueAfter("prefix/name")}},
+		{versioned, []store.FindOpt{store.WithKey(engine.RevisionKey), store.WithWhereEq("PolicyGen", 1), store.WithLimit(10), store.WithNextToken(new(string))}},
+		{versioned, []store.FindOpt{store.WithKey(engine.RevisionKey), store.WithGenRange(10, 20)}},
+	}
+	for _, tc := range valid {
+		assert.NoError(t, store.NewFindOpts(tc.opts).Validate(tc.info), "Find options for kind %s should be valid", tc.info.Kind)
+	}
+
+	invalid := []struct {
+		name  string
+		info  *runtime.TypeInfo
+		opts  []store.FindOpt
+		error string
+	}{
+		{"no key", versioned, []store.FindOpt{store.WithGen(1)}, "either WithKey or WithKeyPrefix"},
+		{"key and key prefix", nonVersioned, []store.FindOpt{store.WithKey("key"), store.WithKeyPrefix("prefix")}, "WithKey and WithKeyPrefix together"},
+		{"gen with key prefix", versioned, []store.FindOpt{store.WithGen(1), store.WithKeyPrefix("prefix")}, "WithGen with WithKeyPrefix"},
+		{"where eq with key prefix", versioned, []store.FindOpt{store.WithKeyPrefix("prefix"), store.WithWhereEq("PolicyGen", 1)}, "WithWhereEq with WithKeyPrefix"},
+		{"get last with key prefix", versioned, []store.FindOpt{store.WithKeyPrefix("prefix"), store.WithGetLast()}, "WithGetFirst or WithGetLast with WithKeyPrefix"},
+		{"gen on non-versioned", nonVersioned, []store.FindOpt{store.WithKey("key"), store.WithGen(1)}, "WithGen to find objects of non-versioned kind"},
+		{"where eq on non-versioned", nonVersioned, []store.FindOpt{store.WithKey("key"), store.WithWhereEq("Status", "ok")}, "WithWhereEq to find objects of non-versioned kind"},
+		{"get first on non-versioned", nonVersioned, []store.FindOpt{store.WithKey("key"), store.WithGetFirst()}, "WithGetFirst or WithGetLast to find objects of non-versioned kind"},
+		{"gen with get last", versioned, []store.FindOpt{store.WithKey(engine.RevisionKey), store.WithGetLast(), store.WithGen(1)}, "WithGetFirst or WithGetLast with WithGen"},
+		{"gen with where eq", versioned, []store.FindOpt{store.WithKey(engine.RevisionKey), store.WithGen(1), store.WithWhereEq("PolicyGen", 1)}, "WithWhereEq with WithGen"},
+		{"get first with get last", versioned, []store.FindOpt{store.WithKey(engine.RevisionKey), store.WithGetFirst(), store.WithGetLast()}, "WithGetFirst and WithGetLast together"},
+		{"partial results without key prefix", nonVersioned, []store.FindOpt{store.WithKey("key"), store.WithPartialResults(&[]runtime.Key{})}, "WithPartialResults without WithKeyPrefix"},
+		{"limit without key prefix", versioned, []store.FindOpt{store.WithKey(engine.RevisionKey), store.WithLimit(10)}, "WithLimit or WithContinue without WithKeyPrefix or WithWhereEq"},
+		{"continue after without key prefix", versioned, []store.FindOpt{store.WithKey(engine.RevisionKey), store.WithWhereEq("PolicyGen", 1), store.WithContinueAfter("key")}, "WithContinueAfter without WithKeyPrefix"},
+		{"get first without where eq", versioned, []store.FindOpt{store.WithKey(engine.RevisionKey), store.WithGetFirst()}, "WithGetFirst or WithGetLast without WithWhereEq"},
+		{"limit with get last", versioned, []store.FindOpt{store.WithKey(engine.RevisionKey), store.WithWhereEq("PolicyGen", 1), store.WithGetLast(), store.WithLimit(10)}, "WithLimit or WithContinue with WithGetFirst or WithGetLast"},
+		{"continue with continue after", versioned, []store.FindOpt{store.WithKeyPrefix("prefix"), store.WithContinue("token"), store.WithContinueAfter("prefix/name")}, "WithContinue and WithContinueAfter together"},
+		{"next token without limit", versioned, []store.FindOpt{store.WithKeyPrefix("prefix"), store.WithNextToken(new(string))}, "WithNextToken without WithLimit"},
+		{"continue after key with other prefix", nonVersioned, []store.FindOpt{store.WithKeyPrefix("prefix"), store.WithContinueAfter("other")}, "WithContinueAfter with key other, which doesn't match WithKeyPrefix prefix"},
+		{"gen range with key prefix", versioned, []store.FindOpt{store.WithKeyPrefix("prefix"), store.WithGenRange(1, 2)}, "WithGenRange with WithKeyPrefix"},
+		{"gen range on non-versioned", nonVersioned, []store.FindOpt{store.WithKey("key"), store.WithGenRange(1, 2)}, "WithGenRange to find objects of non-versioned kind"},
+		{"gen range with get last", versioned, []store.FindOpt{store.WithKey(engine.RevisionKey), store.WithGenRange(1, 2), store.WithGetLast()}, "WithGenRange with WithGen, WithWhereEq, WithGetFirst or WithGetLast"},
+		{"where eq on non-indexed field", versioned, []store.FindOpt{store.WithKey(engine.RevisionKey), store.WithWhereEq("CreatedAt", 1)}, "field CreatedAt, which isn't indexed (field should be tagged with `store:\"index\"`)"},
+	}
+	for _, tc := range invalid {
+		err := store.NewFindOpts(tc.opts).Validate(tc.info)
+		if assert.Error(t, err, "Find options should be rejected: %s", tc.name) {
+			assert.Contains(t, err.Error(), tc.error, "Error should describe invalid combination: %s", tc.name)
+			assert.Contains(t, err.Error(), tc.info.Kind, "Error should mention kind: %s", tc.name)
+		}
+	}
+}
+
+func TestFindOptsValidateResult(t *testing.T) {
+	info := engine.TypeRevision
+	whereEq := []store.FindOpt{store.WithKey(engine.RevisionKey), store.WithWhereEq("PolicyGen", 1)}
+
+	assert.NoError(t, store.NewFindOpts(whereEq).ValidateResult(info, true), "List result should be allowed")
+	assert.NoError(t, store.NewFindOpts(append(whereEq, store.WithGetFirst())).ValidateResult(info, false), "Single result should be allowed with WithGetFirst")
+	err := store.NewFindOpts(append(whereEq, store.WithGetLast())).ValidateResult(info, true)
+	if assert.Error(t, err, "List result should be rejected with WithGetLast") {
+		assert.Contains(t, err.Error(), "WithGetFirst or WithGetLast to find a list of objects of kind "+info.Kind)
+	}
+}
+
+func TestFindOptsContinueToken(t *testing.T) {
+	info := engine.TypeRevision
+	whereEq := func(value interface{}) []store.FindOpt {
+		return []store.FindOpt{store.WithKey(engine.RevisionKey), store.WithWhereEq("PolicyGen", value), store.WithLimit(2)}
+	}
+
+	var next string
+	opts := store.NewFindOpts(append(whereEq(1), store.WithNextToken(&next)))
+	if !assert.NoError(t, opts.Validate(info)) {
+		t.FailNow()
+	}
+	assert.Equal(t, []runtime.Generation{1, 3}, opts.PageGens([]runtime.Generation{1, 3, 5}), "Generations should be limited")
+	assert.NotEmpty(t, next, "Token should be returned for the full page")
+
+	var again string
+	opts = store.NewFindOpts(append(whereEq(1), store.WithNextToken(&again)))
+	assert.NoError(t, opts.Validate(info))
+	opts.PageGens([]runtime.Generation{1, 3, 5})
+	assert.Equal(t, next, again, "Token should be the same for the identical queries")
+
+	opts = store.NewFindOpts(append(whereEq(1), store.WithContinue(next), store.WithNextToken(&again)))
+	if assert.NoError(t, opts.Validate(info), "Token should be accepted by the identical query") {
+		assert.Equal(t, []runtime.Generation{5}, opts.PageGens([]runtime.Generation{1, 3, 5}), "Generations after token should be returned")
+		assert.Empty(t, again, "Token shouldn't be returned for the last page")
+	}
+
+	for name, token := range map[string]string{"malformed": "%%%", "not a token": "bm90IGEgdG9rZW4", "other query": next} {
+		err := store.NewFindOpts(append(whereEq(2), store.WithContinue(token))).Validate(info)
+		assert.True(t, store.IsInvalidContinueToken(err), "Invalid token should be reported with typed error: %s", name)
+	}
+}
+
+func TestSortedUniqueGens(t *testing.T) {
+	assert.Equal(t, []runtime.Generation{1, 2, 5}, store.SortedUniqueGens([]runtime.Generation{5, 2, 1, 2, 5}))
+	assert.Empty(t, store.SortedUniqueGens([]runtime.Generation{}))
+}
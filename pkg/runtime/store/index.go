@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -42,6 +43,26 @@ func (indexes *Indexes) NameForValue(indexName string, key runtime.Key, value in
 	panic(fmt.Sprintf("trying to access non-existing indexName for key %s: %s", key, indexName))
 }
 
+// HasType returns true if there is at least one index of a given type
+func (indexes *Indexes) HasType(indexType IndexType) bool {
+	for _, index := range indexes.List {
+		if index.Type == indexType {
+			return true
+		}
+	}
+	return false
+}
+
+// HasLastOnly returns true if there is at least one list index, which holds the last generations only
+func (indexes *Indexes) HasLastOnly() bool {
+	for _, index := range indexes.List {
+		if index.LastOnly {
+			return true
+		}
+	}
+	return false
+}
+
 var noopValueTransform = func(val interface{}) interface{} {
 	return val
 }
@@ -68,20 +89,64 @@ func IndexesFor(info *runtime.TypeInfo) *Indexes {
 		}
 		for i := 0; i < t.NumField(); i++ {
 			f := t.Field(i)
-			tag := f.Tag.Get("store")
+			tag := parseIndexTag(f.Tag.Get("store"))
+			if len(tag.fields) > 0 {
+				addCompositeIndex(indexes, info, t, tag)
+				continue
+			}
+			if !tag.index {
+				if tag.uniqueNamespace {
+					panic(fmt.Sprintf("field %s of kind %s is tagged as unique across namespace without index", f.Name, info.Kind))
+				}
+				continue
+			}
+
+			// todo validate that field is accessible
+			transformer := valueTransformFor(info, f.Name)
+			indexType := tag.indexType(info, f.Name)
 
-			if strings.Contains(tag, "index") {
-				// todo validate that field is accessible
-				transformer := info.IndexValueTransforms[f.Name]
-				if transformer == nil {
-					transformer = noopValueTransform
+			if tag.group == "" {
+				if _, exist := indexes.List[f.Name]; exist {
+					panic(fmt.Sprintf("index for field %s of kind %s conflicts with the index group of the same name", f.Name, info.Kind))
 				}
 				indexes.List[f.Name] = &Index{
-					Type:           IndexTypeListGen,
+					Type:           indexType,
 					Field:          f.Name,
 					ValueTransform: transformer,
+					LastOnly:       tag.last,
 					rFieldID:       i,
 				}
+				continue
+			}
+
+			// fields with the same group form a single composite index named after the group, fields are kept in the
+			// order of declaration, so the index value is always concatenated in the same order
+			index, exist := indexes.List[tag.group]
+			if !exist {
+				index = &Index{
+					Type:  indexType,
+					Field: tag.group,
+				}
+				indexes.List[tag.group] = index
+			} else if index.Fields == nil {
+				panic(fmt.Sprintf("index group %s for kind %s conflicts with the field of the same name", tag.group, info.Kind))
+			}
+			// composite index is unique if any of its fields is tagged as unique (namespace-wide uniqueness wins)
+			if indexType == IndexTypeUniqueKey || indexType == IndexTypeUniqueGen && index.Type != IndexTypeUniqueKey {
+				index.Type = indexType
+			}
+			// composite index holds the last generations only if any of its fields is tagged so
+			index.LastOnly = index.LastOnly || tag.last
+			index.Fields = append(index.Fields, &IndexField{
+				Name:           f.Name,
+				ValueTransform: transformer,
+				rFieldID:       i,
+			})
+		}
+
+		for name, index := range indexes.List {
+			if index.LastOnly && (index.Type != IndexTypeListGen || !info.Versioned) {
+				panic(fmt.Sprintf("index %s of kind %s can't hold the last generations only, as it's not a list index of versioned kind", name, info.Kind))
 			}
 		}
 	}
@@ -89,6 +154,93 @@ func IndexesFor(info *runtime.TypeInfo) *Indexes {
 	return indexes
 }
 
+// addCompositeIndex adds composite index declared with `store:"index=<field>+<field>"` tag, which is named after the
+// tag value and built for the listed fields in the listed order
+func addCompositeIndex(indexes *Indexes, info *runtime.TypeInfo, t reflect.Type, tag indexTag) {
+	name := strings.Join(tag.fields, "+")
+	if _, exist := indexes.List[name]; exist {
+		panic(fmt.Sprintf("composite index %s of kind %s is declared more than once", name, info.Kind))
+	}
+
+	index := &Index{
+		Type:     tag.indexType(info, name),
+		Field:    name,
+		LastOnly: tag.last,
+	}
+	for _, fieldName := range tag.fields {
+		f, found := t.FieldByName(fieldName)
+		if !found || len(f.Index) != 1 {
+			panic(fmt.Sprintf("composite index %s of kind %s refers to field %s, which doesn't exist", name, info.Kind, fieldName))
+		}
+		index.Fields = append(index.Fields, &IndexField{
+			Name:           fieldName,
+			ValueTransform: valueTransformFor(info, fieldName),
+			rFieldID:       f.Index[0],
+		})
+	}
+	indexes.List[name] = index
+}
+
+func valueTransformFor(info *runtime.TypeInfo, field string) runtime.ValueTransform {
+	if transformer := info.IndexValueTransforms[field]; transformer != nil {
+		return transformer
+	}
+	return noopValueTransform
+}
+
+// indexTag represents parsed `store` struct tag, e.g. `store:"index"`, `store:"index,group=nskind"` or
+// `store:"index=ClusterName+Namespace"`, which declares composite index over the listed fields on any field. Tag
+// `store:"index,unique"` (or its shorthand `store:"unique"`) declares index with values unique across generations,
+// while `store:"index,unique=namespace"` declares index with values unique across all objects of the same kind in a
+// namespace. Tag `store:"index,last"` declares list index, which holds the last generations only
+type indexTag struct {
+	index           bool
+	unique          bool
+	uniqueNamespace bool
+	last            bool
+	group           string
+	fields          []string
+}
+
+// indexType returns type of the index declared by the tag. Unique indexes store direct value -> gen mapping instead of
+// the list of gens, while namespace-wide unique indexes store direct value -> key of the object mapping
+func (tag indexTag) indexType(info *runtime.TypeInfo, name string) IndexType {
+	if tag.uniqueNamespace {
+		if !info.Versioned {
+			panic(fmt.Sprintf("field %s of non-versioned kind %s can't have namespace-wide unique index", name, info.Kind))
+		}
+		return IndexTypeUniqueKey
+	} else if tag.unique {
+		return IndexTypeUniqueGen
+	}
+	return IndexTypeListGen
+}
+
+func parseIndexTag(tag string) indexTag {
+	result := indexTag{}
+	for _, part := range strings.Split(tag, ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "index":
+			result.index = true
+		case part == "unique":
+			result.unique = true
+		case part == "unique=namespace":
+			result.uniqueNamespace = true
+		case part == "last":
+			result.last = true
+		case strings.HasPrefix(part, "group="):
+			result.group = strings.TrimPrefix(part, "group=")
+		case strings.HasPrefix(part, "index="):
+			result.fields = strings.Split(strings.TrimPrefix(part, "index="), "+")
+		}
+	}
+	if result.unique && len(result.fields) == 0 {
+		result.index = true
+	}
+	return result
+}
+
 // IndexType is the type of index and it could be last or list
 type IndexType int
 
@@ -100,32 +252,99 @@ const (
 	IndexTypeLastGen
 	// IndexTypeListGen is index type that stores list of generations
 	IndexTypeListGen
+	// IndexTypeUniqueGen is index type that stores a single generation, it's used for fields with unique values
+	// (e.g. IDs), which makes lookups by such fields require a single read and guarantees uniqueness of values
+	IndexTypeUniqueGen
+	// IndexTypeUniqueKey is index type that stores key of a single object, which last generation holds the value. It's
+	// used for fields, which values should be unique across all objects of the same kind in a namespace (e.g. external
+	// name of the cluster), saving object with a value held by another object fails with ErrDuplicateValue
+	IndexTypeUniqueKey
 )
 
 func (indexType IndexType) String() string {
 	indexTypes := [...]string{
 		"lastgen",
 		"listgen",
+		"uniquegen",
+		"uniquekey",
 	}
 
-	if indexType < 1 || indexType > 2 {
+	if indexType < 1 || indexType > 4 {
 		panic(fmt.Sprintf("unknown index type: %d", indexType))
 	}
 
 	return indexTypes[indexType-1]
 }
 
-// Index represents store index to optimize queries
+// Index represents store index to optimize queries. Index is either built for a single field or it's a composite one
+// built for a group of fields (declared with `store:"index,group=<name>"` tag on every field of the group or with
+// `store:"index=<field>+<field>"` tag on any field), in which case Field is the group name (or the tag value) and
+// Fields are the grouped fields in the order of declaration (or in the order listed in the tag)
 type Index struct {
 	Type           IndexType
 	Field          string
 	ValueTransform runtime.ValueTransform
+	Fields         []*IndexField
+	rFieldID       int
+
+	// LastOnly is set for list index, which holds the last generation of every object only (declared with
+	// `store:"index,last"` tag), so superseded generations stop matching the value they had once the object is saved
+	// with a new generation. Other list indexes keep all generations, as superseded generations are looked up by
+	// their own values (e.g. revisions by status and policy generation)
+	LastOnly bool
+}
+
+// IndexField represents a single field of the composite index
+type IndexField struct {
+	Name           string
+	ValueTransform runtime.ValueTransform
 	rFieldID       int
 }
 
+// GroupValue represents values of all fields of the composite index keyed by field names. It should be used as a
+// value with WithWhereEq to find objects by composite index. Slice of values in the order of index fields (e.g.
+// []interface{}{"cluster", "ns"} for `store:"index=ClusterName+Namespace"`) could be used as well
+type GroupValue map[string]interface{}
+
+// IsComposite returns true if index is built for a group of fields
+func (index *Index) IsComposite() bool {
+	return index.Fields != nil
+}
+
+// GroupValue converts value of the composite index, which is either GroupValue or slice of values in the order of
+// index fields, into GroupValue. It returns an error if value doesn't define all fields of the index
+func (index *Index) GroupValue(value interface{}) (GroupValue, error) {
+	switch typedValue := value.(type) {
+	case GroupValue:
+		if len(typedValue) != len(index.Fields) {
+			return nil, fmt.Errorf("value of composite index %s should have %d values, but it has %d", index.Field, len(index.Fields), len(typedValue))
+		}
+		for _, field := range index.Fields {
+			if _, exist := typedValue[field.Name]; !exist {
+				return nil, fmt.Errorf("value of composite index %s doesn't define field %s", index.Field, field.Name)
+			}
+		}
+		return typedValue, nil
+	case []interface{}:
+		if len(typedValue) != len(index.Fields) {
+			return nil, fmt.Errorf("value of composite index %s should have %d values, but it has %d", index.Field, len(index.Fields), len(typedValue))
+		}
+		result := GroupValue{}
+		for i, field := range index.Fields {
+			result[field.Name] = typedValue[i]
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("value of composite index %s should be GroupValue or []interface{}, but it's %T", index.Field, value)
+}
+
 // NameForStorable returns index value name for specific object
 func (index *Index) NameForStorable(storable runtime.Storable, codec Codec) string {
 	key := runtime.KeyForStorable(storable)
+	if index.Type == IndexTypeUniqueKey {
+		// value is unique across all objects of the same kind in a namespace
+		key = runtime.KeyFromParts(storable.GetNamespace(), storable.GetKind(), "")
+	}
 
 	if index.Type == IndexTypeLastGen {
 		return index.NameForValue(key, nil, codec)
@@ -135,39 +354,95 @@ func (index *Index) NameForStorable(storable runtime.Storable, codec Codec) stri
 	if t.Kind() == reflect.Ptr {
 		t = t.Elem()
 	}
+
+	if index.IsComposite() {
+		value := GroupValue{}
+		for _, field := range index.Fields {
+			value[field.Name] = t.Field(field.rFieldID).Interface()
+		}
+		return index.NameForValue(key, value, codec)
+	}
+
 	f := t.Field(index.rFieldID)
 
 	return index.NameForValue(key, f.Interface(), codec)
 }
 
-// NameForValue returns index value name for specific key and value
+// NameForValue returns index value name for specific key and value. For composite index value should be GroupValue
+// or slice of values in the order of index fields and transformed values of all fields are concatenated in that order.
+// For namespace-wide unique index key should consist of namespace and kind only
 func (index *Index) NameForValue(key runtime.Key, value interface{}, codec Codec) string {
 	key = index.Type.String() + "/" + key
 	if index.Type == IndexTypeLastGen {
 		return key
 	}
 
+	key += "/" + index.Field + "="
+
+	if index.IsComposite() {
+		groupValue, err := index.GroupValue(value)
+		if err != nil {
+			panic(err.Error())
+		}
+		values := make([]string, 0, len(index.Fields))
+		for _, field := range index.Fields {
+			fieldValue := field.ValueTransform(groupValue[field.Name])
+			if fieldValue == nil {
+				return ""
+			}
+			// values are quoted, so concatenated value is unambiguous even if values contain separator
+			values = append(values, strconv.Quote(indexValueString(field.Name, fieldValue, codec)))
+		}
+		return key + strings.Join(values, ",")
+	}
+
 	value = index.ValueTransform(value)
 	if value == nil {
 		return ""
 	}
 
-	key += "/" + index.Field + "="
+	return key + indexValueString(index.Field, value, codec)
+}
 
+func indexValueString(field string, value interface{}, codec Codec) string {
 	if valueStr, ok := value.(string); ok {
-		return key + valueStr
+		return valueStr
 	}
 
 	if valueGen, ok := value.(runtime.Generation); ok {
-		return key + valueGen.String()
+		return valueGen.String()
 	}
 
 	data, err := codec.Marshal(value)
 	if err != nil {
-		panic(fmt.Sprintf("error marshalling index value %s=%v", index.Field, value))
+		panic(fmt.Sprintf("error marshalling index value %s=%v", field, value))
 	}
 
-	return key + string(data)
+	return string(data)
+}
+
+// ErrDuplicateValue is returned when object is saved with a value of the field with unique index, which is already
+// used by another generation (for unique index) or held by another object of the same kind in the namespace (for
+// namespace-wide unique index)
+type ErrDuplicateValue struct {
+	Kind      runtime.Kind
+	Key       runtime.Key
+	Field     string
+	HolderKey runtime.Key
+	HolderGen runtime.Generation
+}
+
+func (err *ErrDuplicateValue) Error() string {
+	if err.HolderKey != "" {
+		return fmt.Sprintf("can't save object %s (kind %s): value of unique field %s is already used by object %s", err.Key, err.Kind, err.Field, err.HolderKey)
+	}
+	return fmt.Sprintf("can't save object %s (kind %s): value of unique field %s is already used by generation %s", err.Key, err.Kind, err.Field, err.HolderGen)
+}
+
+// IsDuplicateValue returns true if a given error is ErrDuplicateValue
+func IsDuplicateValue(err error) bool {
+	_, ok := err.(*ErrDuplicateValue)
+	return ok
 }
 
 // IndexValueList is a helper type to provide effective Add/Remove/Contains operations on the slice of values that are
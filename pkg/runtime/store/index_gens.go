@@ -0,0 +1,102 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+// indexGenListMagic is the header of encoded IndexGenList. Neither YAML nor gob output starts with zero byte, so
+// index values stored as IndexValueList by the codec could be told apart and still read
+var indexGenListMagic = []byte{0x00, 'L'}
+
+// IndexGenList is a helper type to provide effective Add/Remove/Contains operations on the list of generations stored
+// in IndexTypeListGen indexes. It stores generations sorted and uses binary search for operations. Unlike
+// IndexValueList, it doesn't require generations to be marshaled into byte slices and it has its own compact encoding
+type IndexGenList []runtime.Generation
+
+// search returns position of a given generation in the IndexGenList or the position it should be inserted at
+func (list *IndexGenList) search(gen runtime.Generation) int {
+	return sort.Search(len(*list), func(index int) bool {
+		return (*list)[index] >= gen
+	})
+}
+
+// Add adds specified generation to the IndexGenList
+func (list *IndexGenList) Add(gen runtime.Generation) {
+	genIndex := list.search(gen)
+
+	// generation already present in the list
+	if genIndex < len(*list) && (*list)[genIndex] == gen {
+		return
+	}
+
+	// insert generation into desired position
+	*list = append(*list, 0)
+	copy((*list)[genIndex+1:], (*list)[genIndex:])
+	(*list)[genIndex] = gen
+}
+
+// Remove removes specified generation from the IndexGenList
+func (list *IndexGenList) Remove(gen runtime.Generation) {
+	genIndex := list.search(gen)
+
+	// remove generation from the list if exists
+	if genIndex < len(*list) && (*list)[genIndex] == gen {
+		copy((*list)[genIndex:], (*list)[genIndex+1:])
+		*list = (*list)[:len(*list)-1]
+	}
+}
+
+// Contains returns true if IndexGenList contains specified generation
+func (list *IndexGenList) Contains(gen runtime.Generation) bool {
+	genIndex := list.search(gen)
+	return genIndex < len(*list) && (*list)[genIndex] == gen
+}
+
+// Encode returns compact binary representation of the IndexGenList. As generations are sorted, only the difference
+// from the previous generation gets stored as uvarint, so lists of consecutive generations take a byte per generation
+func (list IndexGenList) Encode() []byte {
+	result := make([]byte, len(indexGenListMagic), len(indexGenListMagic)+len(list)*2)
+	copy(result, indexGenListMagic)
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	prev := runtime.Generation(0)
+	for _, gen := range list {
+		n := binary.PutUvarint(buf, uint64(gen-prev))
+		result = append(result, buf[:n]...)
+		prev = gen
+	}
+
+	return result
+}
+
+// IsEncodedIndexGenList returns true if a given index value has been produced by IndexGenList.Encode
+func IsEncodedIndexGenList(data []byte) bool {
+	return bytes.HasPrefix(data, indexGenListMagic)
+}
+
+// DecodeIndexGenList decodes IndexGenList from the binary representation produced by IndexGenList.Encode
+func DecodeIndexGenList(data []byte) (IndexGenList, error) {
+	if !IsEncodedIndexGenList(data) {
+		return nil, fmt.Errorf("index value isn't an encoded list of generations")
+	}
+	data = data[len(indexGenListMagic):]
+
+	result := make(IndexGenList, 0, len(data))
+	prev := runtime.Generation(0)
+	for len(data) > 0 {
+		delta, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, fmt.Errorf("index value has malformed generation at position %d", len(result))
+		}
+		prev += runtime.Generation(delta)
+		result = append(result, prev)
+		data = data[n:]
+	}
+
+	return result, nil
+}
@@ -6,6 +6,8 @@ import (
 	"github.com/Aptomi/aptomi/pkg/engine"
 	"github.com/Aptomi/aptomi/pkg/runtime"
 	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/storetest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -29,3 +31,129 @@ func TestIndexes(t *testing.T) {
 
 	assert.Equal(t, "listgen/system/revision/PolicyGen=42", indexes.NameForValue("PolicyGen", engine.RevisionKey, 42, store.NewJSONCodec()))
 }
+
+func TestCompositeIndexes(t *testing.T) {
+	indexes := store.IndexesFor(storetest.TypeItem)
+	assert.Contains(t, indexes.List, "placement")
+	assert.NotContains(t, indexes.List, "Zone", "Fields of the group shouldn't be indexed separately")
+	if assert.True(t, indexes.List["placement"].IsComposite()) && assert.Len(t, indexes.List["placement"].Fields, 2) {
+		assert.Equal(t, "Zone", indexes.List["placement"].Fields[0].Name, "Fields should be kept in the order of declaration")
+	}
+
+	item := &storetest.Item{TypeKind: storetest.TypeItem.GetTypeKind(), Name: "item", Zone: "east", Rack: "1,2"}
+	key := runtime.KeyForStorable(item)
+	assert.Equal(t, "listgen/system/conformance-item/item/placement=\"east\",\"1,2\"", indexes.NameForStorable("placement", item, store.NewJSONCodec()))
+	assert.Equal(t, indexes.NameForStorable("placement", item, store.NewJSONCodec()), indexes.NameForValue("placement", key, store.GroupValue{"Rack": "1,2", "Zone": "east"}, store.NewJSONCodec()))
+	assert.Equal(t, indexes.NameForStorable("placement", item, store.NewJSONCodec()), indexes.NameForValue("placement", key, []interface{}{"east", "1,2"}, store.NewJSONCodec()), "Values could be listed in the order of fields")
+	assert.Panics(t, func() {
+		indexes.NameForValue("placement", key, []interface{}{"east"}, store.NewJSONCodec())
+	}, "Values for all fields should be required")
+}
+
+// externalCluster is a test object with a field tagged as unique using shorthand tag
+type externalCluster struct {
+	runtime.TypeKind `yaml:",inline"`
+	Metadata         runtime.GenerationMetadata
+
+	Name     string
+	Region   string `store:"unique"`
+	Endpoint string
+}
+
+func (cluster *externalCluster) GetName() string {
+	return cluster.Name
+}
+
+func (cluster *externalCluster) GetNamespace() string {
+	return runtime.SystemNS
+}
+
+func (cluster *externalCluster) GetGeneration() runtime.Generation {
+	return cluster.Metadata.Generation
+}
+
+func (cluster *externalCluster) SetGeneration(gen runtime.Generation) {
+	cluster.Metadata.Generation = gen
+}
+
+func TestUniqueTagShorthand(t *testing.T) {
+	info := &runtime.TypeInfo{
+		Kind:        "external-cluster",
+		Storable:    true,
+		Versioned:   true,
+		Constructor: func() runtime.Object { return &externalCluster{} },
+	}
+	if assert.Contains(t, store.IndexesFor(info).List, "Region", "Unique tag should declare index") {
+		assert.Equal(t, store.IndexTypeUniqueGen, store.IndexesFor(info).List["Region"].Type, "Unique tag should declare index with values unique across generations")
+	}
+
+	s := memory.New(runtime.NewTypes().Append(info), store.NewJSONCodec())
+	_, err := s.Save(&externalCluster{TypeKind: info.GetTypeKind(), Name: "cluster", Region: "us-east", Endpoint: "10.0.0.1"})
+	assert.NoError(t, err, "Object should be saved")
+
+	_, err = s.Save(&externalCluster{TypeKind: info.GetTypeKind(), Name: "cluster", Region: "us-east", Endpoint: "10.0.0.2"})
+	if assert.True(t, store.IsDuplicateValue(err), "Value used by another generation should be reported with typed error") {
+		assert.EqualValues(t, 1, err.(*store.ErrDuplicateValue).HolderGen)
+	}
+}
+
+// lastOnlyUnique is a test object with unique field, which can't be indexed by the last generations only
+type lastOnlyUnique struct {
+	runtime.TypeKind `yaml:",inline"`
+	Metadata         runtime.GenerationMetadata
+
+	Name       string
+	ExternalID string `store:"index,unique,last"`
+}
+
+func TestLastTag(t *testing.T) {
+	indexes := store.IndexesFor(storetest.TypeTicket)
+	if assert.Contains(t, indexes.List, "Assignee") {
+		assert.Equal(t, store.IndexTypeListGen, indexes.List["Assignee"].Type, "Last tag should declare list index")
+		assert.True(t, indexes.List["Assignee"].LastOnly, "Last tag should declare index of the last generations only")
+	}
+	assert.True(t, indexes.HasLastOnly())
+	assert.False(t, store.IndexesFor(storetest.TypeItem).HasLastOnly(), "Regular list indexes should keep all generations")
+
+	assert.Panics(t, func() {
+		store.IndexesFor(&runtime.TypeInfo{
+			Kind:        "last-only-unique",
+			Storable:    true,
+			Versioned:   true,
+			Constructor: func() runtime.Object { return &lastOnlyUnique{} },
+		})
+	}, "Unique index shouldn't hold the last generations only")
+}
+
+func TestIndexGenList(t *testing.T) {
+	list := store.IndexGenList{}
+	for _, gen := range []runtime.Generation{5, 1, 300, 5, 2} {
+		list.Add(gen)
+	}
+	assert.Equal(t, store.IndexGenList{1, 2, 5, 300}, list, "Generations should be kept sorted without duplicates")
+	assert.True(t, list.Contains(300))
+	assert.False(t, list.Contains(3))
+
+	list.Remove(3)
+	assert.Equal(t, store.IndexGenList{1, 2, 5, 300}, list, "Removing missing generation shouldn't change the list")
+	list.Remove(2)
+	assert.Equal(t, store.IndexGenList{1, 5, 300}, list)
+
+	data := list.Encode()
+	assert.True(t, store.IsEncodedIndexGenList(data))
+	assert.Len(t, data, 6, "Small differences between generations should take a byte each, except for 300")
+	decoded, err := store.DecodeIndexGenList(data)
+	if assert.NoError(t, err, "List of generations should be decoded") {
+		assert.Equal(t, list, decoded)
+	}
+
+	empty, err := store.DecodeIndexGenList(store.IndexGenList{}.Encode())
+	if assert.NoError(t, err, "Empty list of generations should be decoded") {
+		assert.Empty(t, empty)
+	}
+
+	_, err = store.DecodeIndexGenList([]byte("gens"))
+	assert.Error(t, err, "Value without header shouldn't be decoded")
+	_, err = store.DecodeIndexGenList(append(list.Encode(), 0x80))
+	assert.Error(t, err, "Truncated value shouldn't be decoded")
+}
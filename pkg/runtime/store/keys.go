@@ -0,0 +1,41 @@
+package store
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+// KeysCollector collects unique keys of objects of a single kind out of keys they're stored under (key followed by "@"
+// and generation), so all store backends list keys the same way
+type KeysCollector struct {
+	kind runtime.Kind
+	keys map[runtime.Key]bool
+}
+
+// NewKeysCollector returns KeysCollector for objects of a given kind
+func NewKeysCollector(kind runtime.Kind) *KeysCollector {
+	return &KeysCollector{kind: kind, keys: make(map[runtime.Key]bool)}
+}
+
+// Add adds key of the object stored under a given key, if it's of the collected kind
+func (collector *KeysCollector) Add(objectKey string) {
+	if !ObjectKeyHasKind(objectKey, collector.kind) {
+		return
+	}
+	if idx := strings.LastIndex(objectKey, "@"); idx >= 0 {
+		objectKey = objectKey[:idx]
+	}
+	collector.keys[objectKey] = true
+}
+
+// Keys returns sorted list of collected keys
+func (collector *KeysCollector) Keys() []runtime.Key {
+	result := make([]runtime.Key, 0, len(collector.keys))
+	for key := range collector.keys {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
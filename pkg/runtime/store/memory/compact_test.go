@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStoreCompact(t *testing.T) {
+	indexes := store.IndexesFor(engine.TypeRevision)
+	s := New(runtime.NewTypes().Append(engine.TypeRevision), store.NewJSONCodec()).(*memoryStore) // nolint: errcheck
+
+	// every generation of the object points to the policy with the same generation
+	for gen := 1; gen <= 10; gen++ {
+		changed, err := s.Save(&engine.Revision{
+			TypeKind:  engine.TypeRevision.GetTypeKind(),
+			PolicyGen: runtime.Generation(gen),
+			Status:    fmt.Sprintf("status-%d", gen%2),
+		})
+		assert.NoError(t, err)
+		assert.True(t, changed)
+	}
+
+	// keep last 3 generations, as well as generations 2 and 5
+	retained := map[runtime.Generation]bool{2: true, 5: true, 8: true, 9: true, 10: true}
+	removed, err := s.Compact(engine.TypeRevision.Kind, engine.RevisionKey, 3, func(gen runtime.Generation) bool {
+		return gen == 2 || gen == 5
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []runtime.Generation{1, 3, 4, 6, 7}, removed, "Only generations which are not retained should be removed")
+
+	for gen := runtime.Generation(1); gen <= 10; gen++ {
+		var revision *engine.Revision
+		err = s.Find(engine.TypeRevision.Kind, &revision, store.WithKey(engine.RevisionKey), store.WithGen(gen))
+		if retained[gen] {
+			assert.NoError(t, err)
+			assert.NotNil(t, revision, "Retained generation %s should exist", gen)
+		} else {
+			assert.True(t, store.IsGenNotFound(err), "Removed generation %s should not be found, got: %v", gen, err)
+		}
+
+		// list index entry for a removed generation should be removed completely, as it was the only gen there
+		indexKey := "/index/" + indexes.NameForValue("PolicyGen", engine.RevisionKey, gen, s.codec)
+		assert.Equal(t, retained[gen], s.data[indexKey] != "", "Index entry for generation %s should exist only if retained", gen)
+	}
+
+	// index entries shared by multiple generations should only contain retained generations
+	var revisions []*engine.Revision
+	err = s.Find(engine.TypeRevision.Kind, &revisions, store.WithKey(engine.RevisionKey), store.WithWhereEq("Status", "status-0", "status-1"))
+	assert.NoError(t, err)
+	gens := []runtime.Generation{}
+	for _, revision := range revisions {
+		gens = append(gens, revision.GetGeneration())
+	}
+	assert.Equal(t, []runtime.Generation{2, 5, 8, 9, 10}, gens, "Only retained generations should be found via index")
+
+	// last generation index should stay intact
+	var last *engine.Revision
+	err = s.Find(engine.TypeRevision.Kind, &last, store.WithKey(engine.RevisionKey))
+	assert.NoError(t, err)
+	if assert.NotNil(t, last) {
+		assert.EqualValues(t, 10, last.GetGeneration())
+	}
+
+	// compacting again shouldn't remove anything
+	removed, err = s.Compact(engine.TypeRevision.Kind, engine.RevisionKey, 3, func(gen runtime.Generation) bool {
+		return gen == 2 || gen == 5
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, removed)
+
+	_, err = s.Compact(engine.TypeRevision.Kind, engine.RevisionKey, 0, nil)
+	assert.Error(t, err, "Last generation should always be kept")
+}
+
+func TestMemoryStoreCompactUniqueIndex(t *testing.T) {
+	indexes := store.IndexesFor(typeTicket)
+	s := New(runtime.NewTypes().Append(typeTicket), store.NewJSONCodec()).(*memoryStore) // nolint: errcheck
+	key := runtime.KeyFromParts(runtime.SystemNS, typeTicket.Kind, runtime.EmptyName)
+
+	for _, id := range []string{"a1", "b2", "c3"} {
+		_, err := s.Save(&ticket{TypeKind: typeTicket.GetTypeKind(), ID: id, Title: "ticket " + id})
+		assert.NoError(t, err)
+	}
+
+	removed, err := s.Compact(typeTicket.Kind, key, 1, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []runtime.Generation{1, 2}, removed)
+
+	assert.Empty(t, s.data["/index/"+indexes.NameForValue("ID", key, "a1", s.codec)], "Unique index entry of removed generation should be removed")
+	assert.Empty(t, s.data["/index/"+indexes.NameForValue("ID", key, "b2", s.codec)], "Unique index entry of removed generation should be removed")
+	assert.Equal(t, s.marshalGen(3), s.data["/index/"+indexes.NameForValue("ID", key, "c3", s.codec)], "Unique index entry of kept generation should stay")
+}
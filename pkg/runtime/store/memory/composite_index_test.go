@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/stretchr/testify/assert"
+)
+
+var typePlacement = &runtime.TypeInfo{
+	Kind:        "placement",
+	Storable:    true,
+	Versioned:   true,
+	Constructor: func() runtime.Object { return &placement{} },
+}
+
+// placement is a test object with a composite index over cluster and namespace
+type placement struct {
+	runtime.TypeKind `yaml:",inline"`
+	Metadata         runtime.GenerationMetadata
+
+	ClusterName string `store:"index=ClusterName+Namespace"`
+	Namespace   string
+}
+
+func (p *placement) GetName() string {
+	return runtime.EmptyName
+}
+
+func (p *placement) GetNamespace() string {
+	return runtime.SystemNS
+}
+
+func (p *placement) GetGeneration() runtime.Generation {
+	return p.Metadata.Generation
+}
+
+func (p *placement) SetGeneration(gen runtime.Generation) {
+	p.Metadata.Generation = gen
+}
+
+func TestMemoryStoreCompositeIndex(t *testing.T) {
+	indexes := store.IndexesFor(typePlacement)
+	if assert.Contains(t, indexes.List, "ClusterName+Namespace") {
+		assert.Len(t, indexes.List["ClusterName+Namespace"].Fields, 2)
+	}
+	assert.NotContains(t, indexes.List, "ClusterName", "Fields of composite index shouldn't be indexed separately")
+
+	s := New(runtime.NewTypes().Append(typePlacement), store.NewJSONCodec()).(*memoryStore) // nolint: errcheck
+	key := runtime.KeyFromParts(runtime.SystemNS, typePlacement.Kind, runtime.EmptyName)
+
+	for _, p := range [][]string{{"east", "dev"}, {"east", "prod"}, {"west", "dev"}, {"east", "dev"}} {
+		_, err := s.Save(&placement{TypeKind: typePlacement.GetTypeKind(), ClusterName: p[0], Namespace: p[1]})
+		assert.NoError(t, err)
+	}
+
+	var found []*placement
+	assert.NoError(t, s.Find(typePlacement.Kind, &found, store.WithKey(key), store.WithWhereEq("ClusterName+Namespace", []interface{}{"east", "prod"})))
+	if assert.Len(t, found, 1, "Generations matching all fields of composite index should be found") {
+		assert.Equal(t, "east", found[0].ClusterName)
+		assert.Equal(t, "prod", found[0].Namespace)
+	}
+
+	// removing index entry hides matching generations, which proves that lookup reads the index instead of scanning
+	s.del("/index/" + indexes.NameForValue("ClusterName+Namespace", key, []interface{}{"east", "prod"}, s.codec))
+	found = nil
+	assert.NoError(t, s.Find(typePlacement.Kind, &found, store.WithKey(key), store.WithWhereEq("ClusterName+Namespace", []interface{}{"east", "prod"})))
+	assert.Empty(t, found, "Composite query should be served by the index")
+
+	err := s.Find(typePlacement.Kind, &found, store.WithKey(key), store.WithWhereEq("ClusterName+Namespace", []interface{}{"east"}))
+	assert.Error(t, err, "Values for all fields of composite index should be required")
+}
@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/stretchr/testify/assert"
+)
+
+var typeTask = &runtime.TypeInfo{
+	Kind:        "task",
+	Storable:    true,
+	Versioned:   true,
+	Constructor: func() runtime.Object { return &task{} },
+}
+
+// task is a test versioned object with a list index
+type task struct {
+	runtime.TypeKind `yaml:",inline"`
+	Metadata         runtime.GenerationMetadata
+
+	Name   string
+	Status string `store:"index"`
+}
+
+func (t *task) GetName() string {
+	return t.Name
+}
+
+func (t *task) GetNamespace() string {
+	return runtime.SystemNS
+}
+
+func (t *task) GetGeneration() runtime.Generation {
+	return t.Metadata.Generation
+}
+
+func (t *task) SetGeneration(gen runtime.Generation) {
+	t.Metadata.Generation = gen
+}
+
+func TestMemoryStoreDeleteVersioned(t *testing.T) {
+	s := New(runtime.NewTypes().Append(typeTask), store.NewYAMLCodec()).(*memoryStore) // nolint: errcheck
+
+	// both tasks have generations with the same status
+	for _, name := range []string{"first", "second"} {
+		for _, status := range []string{"waiting", "done", "waiting"} {
+			_, err := s.Save(&task{TypeKind: typeTask.GetTypeKind(), Name: name, Status: status})
+			if !assert.NoError(t, err, "Task should be saved") {
+				t.FailNow()
+			}
+		}
+	}
+
+	firstKey := runtime.KeyFromParts(runtime.SystemNS, typeTask.Kind, "first")
+	secondKey := runtime.KeyFromParts(runtime.SystemNS, typeTask.Kind, "second")
+	assert.NoError(t, s.Delete(typeTask.Kind, firstKey), "Task should be deleted")
+
+	// nothing should be left for the deleted task
+	for dataKey := range s.data {
+		assert.False(t, strings.Contains(dataKey, firstKey), "Key %s should be removed with the task", dataKey)
+	}
+
+	var deleted *task
+	assert.NoError(t, s.Find(typeTask.Kind, &deleted, store.WithKey(firstKey)), "Deleted task should not fail find")
+	assert.Nil(t, deleted, "Deleted task should not be found")
+
+	var waiting []*task
+	assert.NoError(t, s.Find(typeTask.Kind, &waiting, store.WithKey(firstKey), store.WithWhereEq("Status", "waiting")), "Deleted task should not fail find by index")
+	assert.Empty(t, waiting, "Deleted task should be removed from index")
+
+	// the other task and its index entries are untouched
+	assert.NoError(t, s.Find(typeTask.Kind, &waiting, store.WithKey(secondKey), store.WithWhereEq("Status", "waiting")), "Task should be found by index")
+	if assert.Len(t, waiting, 2, "Index entries of other task should be kept") {
+		assert.EqualValues(t, 1, waiting[0].GetGeneration())
+		assert.EqualValues(t, 3, waiting[1].GetGeneration())
+	}
+	var second *task
+	assert.NoError(t, s.Find(typeTask.Kind, &second, store.WithKey(secondKey)), "Task should be found")
+	if assert.NotNil(t, second, "Other task should be kept") {
+		assert.EqualValues(t, 3, second.GetGeneration(), "Last generation of other task should be kept")
+	}
+
+	// deleted task could be created from scratch
+	_, err := s.Save(&task{TypeKind: typeTask.GetTypeKind(), Name: "first", Status: "done"})
+	assert.NoError(t, err, "Task should be saved again")
+	assert.NoError(t, s.Find(typeTask.Kind, &deleted, store.WithKey(firstKey)), "Task should be found")
+	if assert.NotNil(t, deleted, "Task should be found") {
+		assert.EqualValues(t, runtime.FirstGen, deleted.GetGeneration(), "Task should start from the first generation")
+	}
+
+	assert.NoError(t, s.Delete(typeTask.Kind, runtime.KeyFromParts(runtime.SystemNS, typeTask.Kind, "missing")), "Deleting missing task should be a no-op")
+}
@@ -0,0 +1,4 @@
+// Package memory implements in-memory store backend, which keeps all objects and indexes in memory and follows the
+// same semantics as etcd store (verified by the shared storetest conformance suite). It's safe for concurrent use and
+// intended for unit tests, which shouldn't depend on a running etcd.
+package memory
@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/lang"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStoreFindByKeyPrefixVersioned(t *testing.T) {
+	memoryStore := New(runtime.NewTypes().Append(lang.TypeClaim), store.NewYAMLCodec())
+
+	claims := []*lang.Claim{}
+	for _, name := range []string{"first", "second"} {
+		claim := &lang.Claim{
+			TypeKind: lang.TypeClaim.GetTypeKind(),
+			Metadata: lang.Metadata{Namespace: "main", Name: name},
+			User:     "alice",
+			Service:  "service",
+		}
+		claims = append(claims, claim)
+		_, err := memoryStore.Save(claim)
+		if !assert.NoError(t, err, "Claim should be saved") {
+			t.FailNow()
+		}
+	}
+
+	// second generation of the first claim
+	claims[0].Service = "another"
+	_, err := memoryStore.Save(claims[0])
+	if !assert.NoError(t, err, "Claim should be saved") {
+		t.FailNow()
+	}
+
+	var loaded []*lang.Claim
+	err = memoryStore.Find(lang.TypeClaim.Kind, &loaded, store.WithKeyPrefix("/"+runtime.KeyFromParts("main", lang.TypeClaim.Kind, "")))
+	assert.NoError(t, err, "Claims should be found by key prefix")
+	if assert.Len(t, loaded, 2, "Only last generation of every claim should be returned") {
+		assert.Equal(t, claims[0], loaded[0], "Last generation of the first claim should be returned")
+		assert.EqualValues(t, 2, loaded[0].GetGeneration(), "Last generation of the first claim should be returned")
+		assert.Equal(t, claims[1], loaded[1], "Second claim should be returned")
+	}
+
+	loaded = nil
+	err = memoryStore.Find(lang.TypeClaim.Kind, &loaded, store.WithKeyPrefix(runtime.KeyFromParts("other", lang.TypeClaim.Kind, "")))
+	assert.NoError(t, err, "Search in the empty namespace should not fail")
+	assert.Empty(t, loaded, "No claims should be found in the empty namespace")
+}
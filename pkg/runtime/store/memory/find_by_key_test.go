@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStoreFindByKeyNonVersioned(t *testing.T) {
+	memoryStore := New(runtime.NewTypes().Append(typeNote), store.NewYAMLCodec())
+
+	n := &note{TypeKind: typeNote.GetTypeKind(), Name: "first", Text: "text"}
+	_, err := memoryStore.Save(n)
+	if !assert.NoError(t, err, "Note should be saved") {
+		t.FailNow()
+	}
+
+	var loaded *note
+	err = memoryStore.Find(typeNote.Kind, &loaded, store.WithKey(runtime.KeyForStorable(n)))
+	assert.NoError(t, err, "Note should be found")
+	assert.Equal(t, n, loaded, "Note should be loaded by key")
+
+	loaded = nil
+	err = memoryStore.Find(typeNote.Kind, &loaded, store.WithKey(runtime.KeyForStorable(n)), store.WithGen(runtime.LastOrEmptyGen))
+	assert.NoError(t, err, "Note should be found with LastOrEmptyGen")
+	assert.Equal(t, n, loaded, "Note should be loaded by key with LastOrEmptyGen")
+
+	loaded = nil
+	err = memoryStore.Find(typeNote.Kind, &loaded, store.WithKey(runtime.KeyFromParts(runtime.SystemNS, typeNote.Kind, "missing")))
+	assert.NoError(t, err, "Missing note should not be an error")
+	assert.Nil(t, loaded, "Missing note should not be loaded")
+
+	err = memoryStore.Find(typeNote.Kind, &loaded, store.WithKey(runtime.KeyForStorable(n)), store.WithGen(1))
+	assert.Error(t, err, "Specific generation of non-versioned object should not be found")
+}
+
+func TestMemoryStoreFindByKeyCorruptedIndex(t *testing.T) {
+	memoryStore := New(runtime.NewTypes().Append(engine.TypeRevision), store.NewYAMLCodec()).(*memoryStore)
+
+	revision := &engine.Revision{TypeKind: engine.TypeRevision.GetTypeKind(), PolicyGen: 1}
+	_, err := memoryStore.Save(revision)
+	if !assert.NoError(t, err, "Revision should be saved") {
+		t.FailNow()
+	}
+
+	// last generation index still points to the removed object
+	delete(memoryStore.data, "/object"+"/"+engine.RevisionKey+"@"+revision.GetGeneration().String())
+
+	var loaded *engine.Revision
+	err = memoryStore.Find(engine.TypeRevision.Kind, &loaded, store.WithKey(engine.RevisionKey))
+	assert.Error(t, err, "Corrupted last generation index should be reported")
+	assert.False(t, store.IsGenNotFound(err), "Corrupted index should not be reported as missing generation")
+	assert.Nil(t, loaded, "Revision should not be loaded")
+}
@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/stretchr/testify/assert"
+)
+
+var typeNote = &runtime.TypeInfo{
+	Kind:        "note",
+	Storable:    true,
+	Versioned:   false,
+	Constructor: func() runtime.Object { return &note{} },
+}
+
+// note is a test non-versioned object
+type note struct {
+	runtime.TypeKind `yaml:",inline"`
+	Name             string
+	Text             string
+}
+
+func (n *note) GetName() string {
+	return n.Name
+}
+
+func (n *note) GetNamespace() string {
+	return runtime.SystemNS
+}
+
+// corruptingCodec is a codec, which fails to decode values containing a given marker
+type corruptingCodec struct {
+	store.Codec
+	marker []byte
+}
+
+func (c *corruptingCodec) Unmarshal(data []byte, value interface{}) error {
+	if bytes.Contains(data, c.marker) {
+		return fmt.Errorf("value is corrupted")
+	}
+	return c.Codec.Unmarshal(data, value)
+}
+
+func TestMemoryStorePartialResults(t *testing.T) {
+	s := New(runtime.NewTypes().Append(typeNote), &corruptingCodec{Codec: store.NewYAMLCodec(), marker: []byte("corrupted")})
+
+	notes := []*note{
+		{TypeKind: typeNote.GetTypeKind(), Name: "a", Text: "good"},
+		{TypeKind: typeNote.GetTypeKind(), Name: "b", Text: "corrupted"},
+		{TypeKind: typeNote.GetTypeKind(), Name: "c", Text: "good"},
+		{TypeKind: typeNote.GetTypeKind(), Name: "d", Text: "corrupted"},
+	}
+	for _, n := range notes {
+		_, err := s.Save(n)
+		assert.NoError(t, err, "Note should be saved")
+	}
+	prefix := runtime.SystemNS + "/" + typeNote.Kind
+
+	// whole find fails by default
+	var result []*note
+	err := s.Find(typeNote.Kind, &result, store.WithKeyPrefix(prefix))
+	if assert.Error(t, err, "Find should fail if some of the values can't be decoded") {
+		assert.Contains(t, err.Error(), runtime.KeyForStorable(notes[1]), "Error should mention the key which can't be decoded")
+	}
+
+	// good values are returned and corrupted keys are reported with partial results
+	result = nil
+	failedKeys := []runtime.Key{}
+	err = s.Find(typeNote.Kind, &result, store.WithKeyPrefix(prefix), store.WithPartialResults(&failedKeys))
+	assert.NoError(t, err, "Find should not fail with partial results")
+	if assert.Len(t, result, 2, "Values which can be decoded should be returned") {
+		assert.Equal(t, "a", result[0].Name, "Values should be returned in key order")
+		assert.Equal(t, "c", result[1].Name, "Values should be returned in key order")
+	}
+	assert.Equal(t, []runtime.Key{runtime.KeyForStorable(notes[1]), runtime.KeyForStorable(notes[3])}, failedKeys, "Keys of values which can't be decoded should be reported")
+}
@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStoreRebuildIndexes(t *testing.T) {
+	indexes := store.IndexesFor(typeTicket)
+	s := New(runtime.NewTypes().Append(typeTicket), store.NewJSONCodec()).(*memoryStore) // nolint: errcheck
+	key := runtime.KeyFromParts(runtime.SystemNS, typeTicket.Kind, runtime.EmptyName)
+
+	for _, id := range []string{"a1", "b2", "c3"} {
+		_, err := s.Save(&ticket{TypeKind: typeTicket.GetTypeKind(), ID: id, Title: "ticket " + id})
+		assert.NoError(t, err)
+	}
+
+	// corrupt indexes: drop an entry, point another one to the wrong generation and add a stale one
+	missing := indexes.NameForValue("ID", key, "a1", s.codec)
+	divergent := indexes.NameForValue(store.LastGenIndex, key, nil, s.codec)
+	dangling := indexes.NameForValue("ID", key, "z9", s.codec)
+	s.del("/index/" + missing)
+	s.set("/index/"+divergent, s.marshalGen(2))
+	s.set("/index/"+dangling, s.marshalGen(1))
+
+	expected := &store.IndexRebuildResult{
+		DryRun:    true,
+		Added:     1,
+		Updated:   1,
+		Removed:   1,
+		Missing:   []string{missing},
+		Divergent: []string{divergent},
+		Dangling:  []string{dangling},
+	}
+	result, err := s.RebuildIndexes(typeTicket.Kind, true)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result, "Inconsistencies should be reported in dry-run mode")
+	_, exist := s.data["/index/"+dangling]
+	assert.True(t, exist, "Dry-run shouldn't change indexes")
+
+	result, err = s.RebuildIndexes(typeTicket.Kind, false)
+	assert.NoError(t, err)
+	expected.DryRun = false
+	assert.Equal(t, expected, result)
+
+	var found *ticket
+	assert.NoError(t, s.Find(typeTicket.Kind, &found, store.WithKey(key), store.WithWhereEq("ID", "a1"), store.WithGetLast()))
+	if assert.NotNil(t, found, "Object should be found using restored index entry") {
+		assert.EqualValues(t, 1, found.GetGeneration())
+	}
+
+	var last *ticket
+	assert.NoError(t, s.Find(typeTicket.Kind, &last, store.WithKey(key)))
+	if assert.NotNil(t, last, "Last generation should be found using repaired index entry") {
+		assert.EqualValues(t, 3, last.GetGeneration())
+	}
+
+	result, err = s.RebuildIndexes(typeTicket.Kind, false)
+	assert.NoError(t, err)
+	assert.Equal(t, &store.IndexRebuildResult{}, result, "Repaired indexes shouldn't be changed again")
+}
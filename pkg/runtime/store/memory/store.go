@@ -0,0 +1,826 @@
+package memory
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+)
+
+type memoryStore struct {
+	mu       sync.Mutex
+	data     map[string]string
+	types    *runtime.Types
+	codec    store.Codec
+	watchers *store.Watchers
+
+	// undo keeps previous values of changed keys while saving multiple objects, so changes could be rolled back
+	undo map[string]*string
+}
+
+// change is a change of an object, which watchers should be notified about
+type change struct {
+	info      *runtime.TypeInfo
+	eventType store.EventType
+	key       runtime.Key
+	gen       runtime.Generation
+	data      []byte
+}
+
+// New creates in-memory store backend from provided types registry and codec. It follows the same semantics as
+// etcd store (generations, indexes), but keeps all data in memory. It's useful for tests and for embedding Aptomi
+// into other binaries, when persistence is not required
+func New(types *runtime.Types, codec store.Codec) store.Interface {
+	return &memoryStore{
+		data:     make(map[string]string),
+		types:    types,
+		codec:    codec,
+		watchers: store.NewWatchers(codec),
+	}
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+// Save saves Storable object with specified options into memory and updates indexes when appropriate.
+// Workflow is exactly the same as for etcd store, while all manipulations are done under a single lock to guarantee
+// atomic operations
+func (s *memoryStore) Save(newStorable runtime.Storable, opts ...store.SaveOpt) (bool, error) {
+	if newStorable == nil {
+		return false, fmt.Errorf("can't save nil")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	newVersion, saved, err := s.save(newStorable, store.NewSaveOpts(opts))
+	if saved != nil {
+		s.notify(saved)
+	}
+
+	return newVersion, err
+}
+
+// SaveMany saves all objects with the same options under a single lock. All changes are recorded while saving, so
+// they are rolled back if any of the objects fails to be saved. Watchers are notified only if all objects are saved
+func (s *memoryStore) SaveMany(newStorables []runtime.Storable, opts ...store.SaveOpt) ([]bool, error) {
+	for _, newStorable := range newStorables {
+		if newStorable == nil {
+			return nil, fmt.Errorf("can't save nil")
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saveOpts := store.NewSaveOpts(opts)
+	newVersions := make([]bool, len(newStorables))
+	changes := make([]*change, 0, len(newStorables))
+
+	s.undo = make(map[string]*string)
+	defer func() { s.undo = nil }()
+	for idx, newStorable := range newStorables {
+		newVersion, saved, err := s.save(newStorable, saveOpts)
+		if err != nil {
+			s.rollback()
+			return nil, err
+		}
+		newVersions[idx] = newVersion
+		if saved != nil {
+			changes = append(changes, saved)
+		}
+	}
+
+	for _, saved := range changes {
+		s.notify(saved)
+	}
+
+	return newVersions, nil
+}
+
+// save saves a single object and returns the change watchers should be notified about (nil if nothing is written).
+// It should be called under the lock
+func (s *memoryStore) save(newStorable runtime.Storable, saveOpts *store.SaveOpts) (bool, *change, error) {
+	info := s.types.Get(newStorable.GetKind())
+	indexes := store.IndexesFor(info)
+	key := "/" + runtime.KeyForStorable(newStorable)
+
+	if !info.Versioned {
+		objKey := "/object" + key + "@" + runtime.LastOrEmptyGen.String()
+		eventType := store.EventUpdated
+		if _, exist := s.data[objKey]; !exist {
+			eventType = store.EventCreated
+		}
+		data := s.marshal(newStorable)
+		s.set(objKey, string(data))
+		return false, &change{info, eventType, strings.TrimPrefix(key, "/"), runtime.LastOrEmptyGen, data}, nil
+	}
+
+	// need to remove this obj from indexes
+	var prevObj runtime.Storable
+	newObj := newStorable.(runtime.Versioned) // nolint: errcheck
+
+	if saveOpts.IsReplaceOrForceGen() {
+		newGen := newObj.GetGeneration()
+		if newGen == runtime.LastOrEmptyGen {
+			return false, nil, fmt.Errorf("error while saving object %s with replaceOrForceGen option but with empty generation", key)
+		}
+		// need to check if there is an object already exists with gen from the object, if yes - remove it from indexes
+		if oldObjRaw := s.data["/object"+key+"@"+newGen.String()]; oldObjRaw != "" {
+			prevObj = info.New().(runtime.Storable) // nolint: errcheck
+			s.unmarshal([]byte(oldObjRaw), prevObj)
+
+			if reflect.DeepEqual(prevObj, newObj) {
+				return false, nil, nil
+			}
+		}
+	} else {
+		// need to get last gen using index, if exists - compare with, if different - increment revision and delete old from indexes
+		lastGenRaw := s.data["/index/"+indexes.NameForStorable(store.LastGenIndex, newStorable, s.codec)]
+		if lastGenRaw == "" {
+			newObj.SetGeneration(runtime.FirstGen)
+		} else {
+			lastGen := s.unmarshalGen(lastGenRaw)
+			oldObjRaw := s.data["/object"+key+"@"+lastGen.String()]
+			if oldObjRaw == "" {
+				return false, nil, fmt.Errorf("last gen index for %s seems to be corrupted: generation doesn't exist", key)
+			}
+			prevObj = info.New().(runtime.Storable) // nolint: errcheck
+			s.unmarshal([]byte(oldObjRaw), prevObj)
+			newObj.SetGeneration(lastGen)
+
+			if reflect.DeepEqual(prevObj, newObj) {
+				return false, nil, nil
+			}
+
+			// objects are different
+			newObj.SetGeneration(lastGen.Next())
+		}
+	}
+
+	newGen := newObj.GetGeneration()
+	objKey := strings.TrimPrefix(key, "/")
+
+	// values of unique indexes shouldn't be used by other generations, while values of namespace-wide unique indexes
+	// shouldn't be used by other objects
+	for _, index := range indexes.List {
+		indexName := index.NameForStorable(newStorable, s.codec)
+		if indexName == "" {
+			continue
+		}
+		if index.Type == store.IndexTypeUniqueGen {
+			if genRaw := s.data["/index/"+indexName]; genRaw != "" {
+				if gen := s.unmarshalGen(genRaw); gen != newGen {
+					return false, nil, &store.ErrDuplicateValue{Kind: info.Kind, Key: objKey, Field: index.Field, HolderGen: gen}
+				}
+			}
+		} else if index.Type == store.IndexTypeUniqueKey {
+			if holder := s.data["/index/"+indexName]; holder != "" && holder != objKey {
+				return false, nil, &store.ErrDuplicateValue{Kind: info.Kind, Key: objKey, Field: index.Field, HolderKey: holder}
+			}
+		}
+	}
+
+	// saved generation becomes the last one, so values of namespace-wide unique indexes held by the previous last
+	// generation should be released and it should be removed from indexes holding the last generations only
+	lastObj := prevObj
+	if saveOpts.IsReplaceOrForceGen() && (indexes.HasType(store.IndexTypeUniqueKey) || indexes.HasLastOnly()) {
+		lastObj = nil
+		if lastGenRaw := s.data["/index/"+indexes.NameForStorable(store.LastGenIndex, newStorable, s.codec)]; lastGenRaw != "" {
+			if lastObjRaw := s.data["/object"+key+"@"+s.unmarshalGen(lastGenRaw).String()]; lastObjRaw != "" {
+				lastObj = info.New().(runtime.Storable) // nolint: errcheck
+				s.unmarshal([]byte(lastObjRaw), lastObj)
+			}
+		}
+	}
+
+	data := s.marshal(newObj)
+	s.set("/object"+key+"@"+newGen.String(), string(data))
+
+	if prevObj != nil && prevObj.(runtime.Versioned).GetGeneration() == newGen {
+		for _, index := range indexes.List {
+			indexName := index.NameForStorable(prevObj, s.codec)
+			if indexName == "" {
+				continue
+			}
+			if index.Type == store.IndexTypeListGen {
+				s.updateIndex("/index/"+indexName, newGen, true)
+			} else if index.Type == store.IndexTypeUniqueGen {
+				s.del("/index/" + indexName)
+			}
+		}
+	}
+
+	if lastObj != nil {
+		lastGen := lastObj.(runtime.Versioned).GetGeneration() // nolint: errcheck
+		for _, index := range indexes.List {
+			indexName := index.NameForStorable(lastObj, s.codec)
+			if indexName == "" {
+				continue
+			}
+			if index.Type == store.IndexTypeUniqueKey && s.data["/index/"+indexName] == objKey {
+				s.del("/index/" + indexName)
+			} else if index.LastOnly && lastGen != newGen {
+				s.updateIndex("/index/"+indexName, lastGen, true)
+			}
+		}
+	}
+
+	for _, index := range indexes.List {
+		indexName := index.NameForStorable(newStorable, s.codec)
+		if indexName == "" {
+			continue
+		}
+		indexKey := "/index/" + indexName
+		if index.Type == store.IndexTypeLastGen {
+			s.set(indexKey, s.marshalGen(newGen))
+		} else if index.Type == store.IndexTypeListGen {
+			s.updateIndex(indexKey, newGen, false)
+		} else if index.Type == store.IndexTypeUniqueGen {
+			s.set(indexKey, s.marshalGen(newGen))
+		} else if index.Type == store.IndexTypeUniqueKey {
+			s.set(indexKey, objKey)
+		} else {
+			panic(fmt.Sprintf("index type %s is not supported by memory store", index.Type))
+		}
+	}
+
+	eventType := store.EventUpdated
+	if prevObj == nil && newGen == runtime.FirstGen {
+		eventType = store.EventCreated
+	}
+
+	return true, &change{info, eventType, objKey, newGen, data}, nil
+}
+
+// set writes value for a given key, while recording the previous value if changes are being recorded
+func (s *memoryStore) set(key string, value string) {
+	s.record(key)
+	s.data[key] = value
+}
+
+// del removes a given key, while recording the previous value if changes are being recorded
+func (s *memoryStore) del(key string) {
+	s.record(key)
+	delete(s.data, key)
+}
+
+func (s *memoryStore) record(key string) {
+	if s.undo == nil {
+		return
+	}
+	if _, recorded := s.undo[key]; recorded {
+		return
+	}
+	if value, exist := s.data[key]; exist {
+		s.undo[key] = &value
+	} else {
+		s.undo[key] = nil
+	}
+}
+
+// rollback restores all values recorded since recording has been started
+func (s *memoryStore) rollback() {
+	for key, value := range s.undo {
+		if value == nil {
+			delete(s.data, key)
+		} else {
+			s.data[key] = *value
+		}
+	}
+}
+
+func (s *memoryStore) notify(saved *change) {
+	s.watchers.Notify(saved.info, saved.eventType, saved.key, saved.gen, saved.data)
+}
+
+func (s *memoryStore) updateIndex(indexKey string, gen runtime.Generation, remove bool) {
+	valueList := &store.IndexValueList{}
+	if valueListRaw := s.data[indexKey]; valueListRaw != "" {
+		s.unmarshal([]byte(valueListRaw), valueList)
+	}
+	value := []byte(s.marshalGen(gen))
+	if remove {
+		valueList.Remove(value)
+	} else {
+		valueList.Add(value)
+	}
+	if len(*valueList) == 0 {
+		s.del(indexKey)
+		return
+	}
+	s.set(indexKey, string(s.marshal(valueList)))
+}
+
+// Find supports the same use cases as etcd store: keyPrefix OR key+gen OR key + whereEq+list/first/last
+func (s *memoryStore) Find(kind runtime.Kind, result interface{}, opts ...store.FindOpt) error {
+	findOpts := store.NewFindOpts(opts)
+	info := s.types.Get(kind)
+	if err := findOpts.Validate(info); err != nil {
+		if store.IsInvalidContinueToken(err) {
+			return err
+		}
+		return fmt.Errorf("invalid find options: %s", err)
+	}
+
+	resultTypeList := reflect.PtrTo(reflect.SliceOf(reflect.TypeOf(info.New())))
+	resultList := reflect.TypeOf(result) == resultTypeList
+	if err := findOpts.ValidateResult(info, resultList); err != nil {
+		return fmt.Errorf("invalid find options: %s", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v := reflect.ValueOf(result).Elem()
+	addToResult := func(elem interface{}) {
+		if resultList {
+			if elem != nil {
+				v.Set(reflect.Append(v, reflect.ValueOf(elem)))
+			}
+		} else if elem == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(elem))
+		}
+	}
+
+	if findOpts.GetKeyPrefix() != "" {
+		return s.findByKeyPrefix(findOpts, info, addToResult)
+	} else if findOpts.GetKey() != "" && findOpts.GetFieldEqName() == "" {
+		return s.findByKey(findOpts, info, addToResult)
+	}
+	return s.findByFieldEq(findOpts, info, addToResult)
+}
+
+func (s *memoryStore) findByKeyPrefix(findOpts *store.FindOpts, info *runtime.TypeInfo, addToResult func(interface{})) error {
+	if info.Versioned {
+		return s.findLastGensByKeyPrefix(findOpts, info, addToResult)
+	}
+
+	prefix := "/object" + "/" + findOpts.GetKeyPrefix()
+	keys := make([]string, 0)
+	for key := range s.data {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	after := ""
+	if findOpts.GetContinueAfter() != "" {
+		after = "/object" + "/" + findOpts.GetContinueAfter() + "@" + runtime.LastOrEmptyGen.String()
+	}
+	pageKeys := page(keys, after, findOpts.GetLimit())
+	if len(pageKeys) > 0 && len(pageKeys) == findOpts.GetLimit() {
+		findOpts.SetNextKey(strings.TrimSuffix(strings.TrimPrefix(pageKeys[len(pageKeys)-1], "/object/"), "@"+runtime.LastOrEmptyGen.String()))
+	}
+	for _, key := range pageKeys {
+		elem := info.New()
+		if err := s.codec.Unmarshal([]byte(s.data[key]), elem); err != nil {
+			objKey := strings.TrimSuffix(strings.TrimPrefix(key, "/object/"), "@"+runtime.LastOrEmptyGen.String())
+			if err = findOpts.HandleDecodeError(objKey, err); err != nil {
+				return err
+			}
+			continue
+		}
+		addToResult(elem)
+	}
+
+	return nil
+}
+
+// findLastGensByKeyPrefix lists last generations of versioned objects with keys prefixed by a given key prefix using
+// last generation index
+func (s *memoryStore) findLastGensByKeyPrefix(findOpts *store.FindOpts, info *runtime.TypeInfo, addToResult func(interface{})) error {
+	indexes := store.IndexesFor(info)
+	indexPrefix := "/index/" + indexes.NameForValue(store.LastGenIndex, findOpts.GetKeyPrefix(), nil, s.codec)
+	indexKeyPrefix := "/index/" + indexes.NameForValue(store.LastGenIndex, "", nil, s.codec)
+
+	indexKeys := make([]string, 0)
+	for indexKey := range s.data {
+		if strings.HasPrefix(indexKey, indexPrefix) {
+			indexKeys = append(indexKeys, indexKey)
+		}
+	}
+	sort.Strings(indexKeys)
+
+	after := ""
+	if findOpts.GetContinueAfter() != "" {
+		after = indexKeyPrefix + findOpts.GetContinueAfter()
+	}
+	pageKeys := page(indexKeys, after, findOpts.GetLimit())
+	if len(pageKeys) > 0 && len(pageKeys) == findOpts.GetLimit() {
+		findOpts.SetNextKey(strings.TrimPrefix(pageKeys[len(pageKeys)-1], indexKeyPrefix))
+	}
+	for _, indexKey := range pageKeys {
+		key := strings.TrimPrefix(indexKey, indexKeyPrefix)
+		gen := s.unmarshalGen(s.data[indexKey])
+		data := s.data["/object"+"/"+key+"@"+gen.String()]
+		if data == "" {
+			return fmt.Errorf("last gen index for %s seems to be corrupted: generation %s doesn't exist", key, gen)
+		}
+
+		elem := info.New()
+		if err := s.codec.Unmarshal([]byte(data), elem); err != nil {
+			if err = findOpts.HandleDecodeError(key, err); err != nil {
+				return err
+			}
+			continue
+		}
+		addToResult(elem)
+	}
+
+	return nil
+}
+
+// page returns sorted keys stored after a given key (if set) limited to a given number of keys (if set)
+func page(keys []string, after string, limit int) []string {
+	if after != "" {
+		keys = keys[sort.SearchStrings(keys, after+"\x00"):]
+	}
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys
+}
+
+// FindIter scans over objects with a given key prefix. Keys are collected upfront, while every object is read and
+// decoded under the lock right before it's passed to fn, so fn could safely use the store
+func (s *memoryStore) FindIter(kind runtime.Kind, fn func(obj runtime.Object) error, opts ...store.FindOpt) error {
+	findOpts := store.NewFindOpts(opts)
+	info := s.types.Get(kind)
+	if err := findOpts.ValidateIter(info); err != nil {
+		return fmt.Errorf("invalid find options: %s", err)
+	}
+
+	prefix := "/object" + "/" + findOpts.GetKeyPrefix()
+	s.mu.Lock()
+	keys := make([]string, 0)
+	for key := range s.data {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	s.mu.Unlock()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s.mu.Lock()
+		data, exists := s.data[key]
+		s.mu.Unlock()
+
+		// object could be deleted while we are iterating
+		if !exists {
+			continue
+		}
+
+		elem := info.New()
+		if err := s.codec.Unmarshal([]byte(data), elem); err != nil {
+			objKey := strings.TrimSuffix(strings.TrimPrefix(key, "/object/"), "@"+runtime.LastOrEmptyGen.String())
+			if err = findOpts.HandleDecodeError(objKey, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(elem); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *memoryStore) findByKey(findOpts *store.FindOpts, info *runtime.TypeInfo, addToResult func(interface{})) error {
+	if findOpts.IsGenRange() {
+		return s.findByGenRange(findOpts, info, addToResult)
+	}
+	if !info.Versioned && findOpts.GetGen() != runtime.LastOrEmptyGen {
+		return fmt.Errorf("requested specific version for non versioned object")
+	}
+
+	gen := findOpts.GetGen()
+	if info.Versioned && gen == runtime.LastOrEmptyGen {
+		indexes := store.IndexesFor(info)
+		lastGenRaw := s.data["/index/"+indexes.NameForValue(store.LastGenIndex, findOpts.GetKey(), nil, s.codec)]
+		if lastGenRaw == "" {
+			addToResult(nil)
+			return nil
+		}
+		gen = s.unmarshalGen(lastGenRaw)
+	}
+
+	data := s.data["/object"+"/"+findOpts.GetKey()+"@"+gen.String()]
+	if data == "" {
+		if findOpts.GetGen() != runtime.LastOrEmptyGen {
+			return &store.GenNotFoundError{Kind: info.Kind, Key: findOpts.GetKey(), Gen: gen}
+		}
+		if info.Versioned {
+			return fmt.Errorf("last generation index of object %s points to generation %s, which doesn't exist", findOpts.GetKey(), gen)
+		}
+		addToResult(nil)
+		return nil
+	}
+
+	result := info.New()
+	s.unmarshal([]byte(data), result)
+	addToResult(result)
+
+	return nil
+}
+
+// findByGenRange finds existing generations of the object within the range defined by WithGenRange in ascending order
+func (s *memoryStore) findByGenRange(findOpts *store.FindOpts, info *runtime.TypeInfo, addToResult func(interface{})) error {
+	prefix := "/object" + "/" + findOpts.GetKey() + "@"
+	gens := make([]runtime.Generation, 0)
+	for dataKey := range s.data {
+		if strings.HasPrefix(dataKey, prefix) {
+			gens = append(gens, runtime.ParseGeneration(strings.TrimPrefix(dataKey, prefix)))
+		}
+	}
+
+	for _, gen := range findOpts.GensInRange(gens) {
+		result := info.New()
+		s.unmarshal([]byte(s.data[prefix+gen.String()]), result)
+		addToResult(result)
+	}
+
+	return nil
+}
+
+func (s *memoryStore) findByFieldEq(findOpts *store.FindOpts, info *runtime.TypeInfo, addToResult func(interface{})) error {
+	indexes := store.IndexesFor(info)
+	index, exist := indexes.List[findOpts.GetFieldEqName()]
+	if !exist {
+		return fmt.Errorf("can't find objects of kind %s by field %s, which isn't indexed (field should be tagged with `store:\"index\"`)", info.Kind, findOpts.GetFieldEqName())
+	}
+	resultGens := make([]runtime.Generation, 0)
+
+	for _, fieldValue := range findOpts.GetFieldEqValues() {
+		indexName := indexes.NameForValue(findOpts.GetFieldEqName(), findOpts.GetKey(), fieldValue, s.codec)
+		if indexName == "" {
+			panic(fmt.Sprintf("can't find using index for which empty index name generated"))
+		}
+		indexValue := s.data["/index/"+indexName]
+		if indexValue != "" && index.Type == store.IndexTypeUniqueGen {
+			resultGens = append(resultGens, s.unmarshalGen(indexValue))
+		} else if indexValue != "" {
+			valueList := &store.IndexValueList{}
+			s.unmarshal([]byte(indexValue), valueList)
+			for _, val := range *valueList {
+				resultGens = append(resultGens, s.unmarshalGen(string(val)))
+			}
+		}
+	}
+
+	resultGens = store.SortedUniqueGens(resultGens)
+
+	if len(resultGens) > 0 {
+		if findOpts.IsGetFirst() {
+			resultGens = []runtime.Generation{resultGens[0]}
+		} else if findOpts.IsGetLast() {
+			resultGens = []runtime.Generation{resultGens[len(resultGens)-1]}
+		}
+	}
+	resultGens = findOpts.PageGens(resultGens)
+	if len(resultGens) == 0 {
+		// single result is reset if nothing matches
+		addToResult(nil)
+	}
+
+	for _, gen := range resultGens {
+		data := s.data["/object"+"/"+findOpts.GetKey()+"@"+gen.String()]
+		if data == "" {
+			return fmt.Errorf("index is invalid, generation %s doesn't exist for key %s", gen, findOpts.GetKey())
+		}
+		result := info.New()
+		s.unmarshal([]byte(data), result)
+		addToResult(result)
+	}
+
+	return nil
+}
+
+// Delete removes object with a given key. For versioned object all generations get removed along with all index
+// entries referencing them
+func (s *memoryStore) Delete(kind runtime.Kind, key runtime.Key) error {
+	info := s.types.Get(kind)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !info.Versioned {
+		objKey := "/object" + "/" + key + "@" + runtime.LastOrEmptyGen.String()
+		if data, exist := s.data[objKey]; exist {
+			delete(s.data, objKey)
+			s.watchers.Notify(info, store.EventDeleted, key, runtime.LastOrEmptyGen, []byte(data))
+		}
+		return nil
+	}
+
+	lastGenKey := "/index/" + store.IndexesFor(info).NameForValue(store.LastGenIndex, key, nil, s.codec)
+	lastGenRaw, exist := s.data[lastGenKey]
+	if !exist {
+		return nil
+	}
+	lastGen := s.unmarshalGen(lastGenRaw)
+	lastData := s.data["/object"+"/"+key+"@"+lastGen.String()]
+
+	prefix := "/object" + "/" + key + "@"
+	for dataKey := range s.data {
+		if strings.HasPrefix(dataKey, prefix) {
+			s.removeGen(info, key, runtime.ParseGeneration(strings.TrimPrefix(dataKey, prefix)))
+		}
+	}
+	delete(s.data, lastGenKey)
+	s.watchers.Notify(info, store.EventDeleted, key, lastGen, []byte(lastData))
+
+	return nil
+}
+
+// Keys returns sorted keys of all objects of a given kind
+func (s *memoryStore) Keys(kind runtime.Kind) ([]runtime.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	collector := store.NewKeysCollector(kind)
+	for dataKey := range s.data {
+		if strings.HasPrefix(dataKey, "/object/") {
+			collector.Add(strings.TrimPrefix(dataKey, "/object/"))
+		}
+	}
+
+	return collector.Keys(), nil
+}
+
+// removeGen removes a single generation of a versioned object along with its entries in list and unique indexes.
+// Values of namespace-wide unique indexes are held by the last generation only, so they're released only when the
+// last generation is removed
+func (s *memoryStore) removeGen(info *runtime.TypeInfo, key runtime.Key, gen runtime.Generation) {
+	objKey := "/object" + "/" + key + "@" + gen.String()
+	objRaw, exist := s.data[objKey]
+	if !exist {
+		return
+	}
+	obj := info.New().(runtime.Storable) // nolint: errcheck
+	s.unmarshal([]byte(objRaw), obj)
+
+	indexes := store.IndexesFor(info)
+	lastGenRaw := s.data["/index/"+indexes.NameForValue(store.LastGenIndex, key, nil, s.codec)]
+	isLast := lastGenRaw != "" && s.unmarshalGen(lastGenRaw) == gen
+
+	for _, index := range indexes.List {
+		indexName := index.NameForStorable(obj, s.codec)
+		if indexName == "" {
+			continue
+		}
+		indexKey := "/index/" + indexName
+		if index.Type == store.IndexTypeListGen {
+			s.updateIndex(indexKey, gen, true)
+		} else if index.Type == store.IndexTypeUniqueGen {
+			if genRaw := s.data[indexKey]; genRaw != "" && s.unmarshalGen(genRaw) == gen {
+				delete(s.data, indexKey)
+			}
+		} else if index.Type == store.IndexTypeUniqueKey && isLast && s.data[indexKey] == key {
+			delete(s.data, indexKey)
+		}
+	}
+
+	delete(s.data, objKey)
+}
+
+// Compact removes old generations of a versioned object, while keeping the last keepLast generations and the ones
+// which should be retained. Removed generations get removed from all indexes as well
+func (s *memoryStore) Compact(kind runtime.Kind, key runtime.Key, keepLast int, retain func(runtime.Generation) bool) ([]runtime.Generation, error) {
+	info := s.types.Get(kind)
+	if !info.Versioned {
+		return nil, fmt.Errorf("non versioned object %s couldn't be compacted, as it has a single generation only", key)
+	}
+	if keepLast < 1 {
+		return nil, fmt.Errorf("at least the last generation of object %s should be kept while compacting", key)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefix := "/object" + "/" + key + "@"
+	gens := make([]runtime.Generation, 0)
+	for dataKey := range s.data {
+		if strings.HasPrefix(dataKey, prefix) {
+			gens = append(gens, runtime.ParseGeneration(strings.TrimPrefix(dataKey, prefix)))
+		}
+	}
+
+	removed := store.GenerationsToCompact(gens, keepLast, retain)
+	for _, gen := range removed {
+		s.removeGen(info, key, gen)
+	}
+
+	return removed, nil
+}
+
+func (s *memoryStore) marshal(value interface{}) []byte {
+	data, err := s.codec.Marshal(value)
+	if err != nil {
+		panic(fmt.Sprintf("error while marshaling value %v with error: %s", value, err))
+	}
+
+	return data
+}
+
+func (s *memoryStore) unmarshal(data []byte, value interface{}) {
+	if err := s.codec.Unmarshal(data, value); err != nil {
+		panic(fmt.Sprintf("error while unmarshaling data: %s", err))
+	}
+}
+
+// RebuildIndexes recomputes index entries of all objects of a given kind and replaces existing ones under the lock
+func (s *memoryStore) RebuildIndexes(kind runtime.Kind, dryRun bool) (*store.IndexRebuildResult, error) {
+	info := s.types.Get(kind)
+	if !info.Versioned {
+		return nil, fmt.Errorf("indexes of non versioned objects of kind %s couldn't be rebuilt, as they aren't indexed", kind)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	builder := store.NewIndexBuilder(info, s.codec)
+	for dataKey, data := range s.data {
+		if !strings.HasPrefix(dataKey, "/object/") || !store.ObjectKeyHasKind(strings.TrimPrefix(dataKey, "/object/"), kind) {
+			continue
+		}
+		obj := info.New().(runtime.Storable) // nolint: errcheck
+		if err := s.codec.Unmarshal([]byte(data), obj); err != nil {
+			return nil, fmt.Errorf("error while decoding object %s: %s", dataKey, err)
+		}
+		builder.Add(obj)
+	}
+
+	expected := map[string]string{}
+	for indexName, entry := range builder.Entries() {
+		expected["/index/"+indexName] = s.encodeIndexEntry(entry)
+	}
+
+	result := &store.IndexRebuildResult{DryRun: dryRun}
+	for dataKey := range s.data {
+		indexName := strings.TrimPrefix(dataKey, "/index/")
+		if !strings.HasPrefix(dataKey, "/index/") || !store.IndexNameHasKind(indexName, kind) {
+			continue
+		}
+		if _, exist := expected[dataKey]; !exist {
+			result.AddDangling(indexName)
+			if !dryRun {
+				s.del(dataKey)
+			}
+		}
+	}
+	for indexKey, value := range expected {
+		current, exist := s.data[indexKey]
+		if !exist {
+			result.AddMissing(strings.TrimPrefix(indexKey, "/index/"))
+		} else if current != value {
+			result.AddDivergent(strings.TrimPrefix(indexKey, "/index/"))
+		} else {
+			continue
+		}
+		if !dryRun {
+			s.set(indexKey, value)
+		}
+	}
+	result.Sort()
+
+	return result, nil
+}
+
+// encodeIndexEntry returns stored value of the index entry the same way as it's stored while saving objects
+func (s *memoryStore) encodeIndexEntry(entry *store.IndexEntry) string {
+	switch entry.Type {
+	case store.IndexTypeListGen:
+		valueList := &store.IndexValueList{}
+		for _, gen := range entry.Gens {
+			valueList.Add([]byte(s.marshalGen(gen)))
+		}
+		return string(s.marshal(valueList))
+	case store.IndexTypeUniqueKey:
+		return entry.Key
+	default:
+		return s.marshalGen(entry.Gens[0])
+	}
+}
+
+func (s *memoryStore) marshalGen(generation runtime.Generation) string {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, uint64(generation))
+
+	return string(data)
+}
+
+func (s *memoryStore) unmarshalGen(data string) runtime.Generation {
+	return runtime.Generation(binary.BigEndian.Uint64([]byte(data)))
+}
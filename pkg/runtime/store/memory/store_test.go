@@ -0,0 +1,96 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/storetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStoreBaseFunctionality(t *testing.T) {
+	types := runtime.NewTypes().Append(engine.TypeRevision, resolve.TypeComponentInstance)
+	memoryStore := memory.New(types, store.NewGobCodec(types))
+	assert.NotNil(t, memoryStore)
+
+	revision := &engine.Revision{
+		TypeKind: engine.TypeRevision.GetTypeKind(),
+		Metadata: runtime.GenerationMetadata{
+			Generation: 1,
+		},
+		PolicyGen: 42,
+		Status:    engine.RevisionStatusWaiting,
+	}
+
+	var changed bool
+	var err error
+	changed, err = memoryStore.Save(revision)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.EqualValues(t, revision.GetGeneration(), 1)
+
+	revision.Status = engine.RevisionStatusInProgress
+	changed, err = memoryStore.Save(revision)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.EqualValues(t, revision.GetGeneration(), 2)
+
+	changed, err = memoryStore.Save(revision)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.EqualValues(t, revision.GetGeneration(), 2)
+
+	var loadedRevisions []*engine.Revision
+	err = memoryStore.Find(engine.TypeRevision.Kind, &loadedRevisions, store.WithKey(engine.RevisionKey), store.WithWhereEq("Status", engine.RevisionStatusWaiting, engine.RevisionStatusInProgress))
+	assert.NoError(t, err)
+	assert.Len(t, loadedRevisions, 2)
+	assert.NotNil(t, loadedRevisions[0])
+	assert.NotNil(t, loadedRevisions[1])
+	assert.Equal(t, engine.RevisionStatusWaiting, loadedRevisions[0].Status)
+	assert.EqualValues(t, 1, loadedRevisions[0].GetGeneration())
+	assert.Equal(t, engine.RevisionStatusInProgress, loadedRevisions[1].Status)
+	assert.EqualValues(t, 2, loadedRevisions[1].GetGeneration())
+
+	var loadedRevisionByLastGen *engine.Revision
+	err = memoryStore.Find(engine.TypeRevision.Kind, &loadedRevisionByLastGen, store.WithKey(engine.RevisionKey), store.WithGen(runtime.LastOrEmptyGen))
+	assert.NoError(t, err)
+	assert.Equal(t, revision, loadedRevisionByLastGen)
+
+	var loadedRevisionBySpecificGen *engine.Revision
+	err = memoryStore.Find(engine.TypeRevision.Kind, &loadedRevisionBySpecificGen, store.WithKey(engine.RevisionKey), store.WithGen(2))
+	assert.NoError(t, err)
+	assert.Equal(t, revision, loadedRevisionBySpecificGen)
+
+	err = memoryStore.Find(engine.TypeRevision.Kind, &loadedRevisionBySpecificGen, store.WithKey(engine.RevisionKey), store.WithGen(42))
+	assert.True(t, store.IsGenNotFound(err), "Missing generation should be reported")
+
+	compInstance := &resolve.ComponentInstance{
+		TypeKind: resolve.TypeComponentInstance.GetTypeKind(),
+		Metadata: &resolve.ComponentInstanceMetadata{
+			Key: &resolve.ComponentInstanceKey{
+				ClusterNameSpace: "ns",
+			},
+		},
+		IsCode: true,
+	}
+
+	changed, err = memoryStore.Save(compInstance)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+}
+
+func TestMemoryStoreConformance(t *testing.T) {
+	storetest.RunConformanceTests(t, func(t *testing.T, types *runtime.Types) store.Interface {
+		return memory.New(types, store.NewYAMLCodec())
+	})
+}
+
+func TestMemoryStoreConformanceGob(t *testing.T) {
+	storetest.RunConformanceTests(t, func(t *testing.T, types *runtime.Types) store.Interface {
+		return memory.New(types, store.NewGobCodec(types))
+	})
+}
@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/stretchr/testify/assert"
+)
+
+var typeTicket = &runtime.TypeInfo{
+	Kind:        "ticket",
+	Storable:    true,
+	Versioned:   true,
+	Constructor: func() runtime.Object { return &ticket{} },
+}
+
+// ticket is a test object with a unique high-cardinality field
+type ticket struct {
+	runtime.TypeKind `yaml:",inline"`
+	Metadata         runtime.GenerationMetadata
+
+	ID    string `store:"index,unique"`
+	Title string
+}
+
+func (t *ticket) GetName() string {
+	return runtime.EmptyName
+}
+
+func (t *ticket) GetNamespace() string {
+	return runtime.SystemNS
+}
+
+func (t *ticket) GetGeneration() runtime.Generation {
+	return t.Metadata.Generation
+}
+
+func (t *ticket) SetGeneration(gen runtime.Generation) {
+	t.Metadata.Generation = gen
+}
+
+func TestMemoryStoreUniqueIndex(t *testing.T) {
+	indexes := store.IndexesFor(typeTicket)
+	assert.Equal(t, store.IndexTypeUniqueGen, indexes.List["ID"].Type)
+
+	s := New(runtime.NewTypes().Append(typeTicket), store.NewJSONCodec()).(*memoryStore) // nolint: errcheck
+	key := runtime.KeyFromParts(runtime.SystemNS, typeTicket.Kind, runtime.EmptyName)
+
+	for _, id := range []string{"a1", "b2", "c3"} {
+		_, err := s.Save(&ticket{TypeKind: typeTicket.GetTypeKind(), ID: id, Title: "ticket " + id})
+		assert.NoError(t, err)
+	}
+
+	// unique index should store direct value -> gen mapping, instead of the list of gens
+	indexValue := s.data["/index/"+indexes.NameForValue("ID", key, "b2", s.codec)]
+	assert.Equal(t, s.marshalGen(2), indexValue, "Unique index should point directly to the generation")
+
+	// lookup by unique field should return a single object
+	var found *ticket
+	err := s.Find(typeTicket.Kind, &found, store.WithKey(key), store.WithWhereEq("ID", "b2"), store.WithGetLast())
+	assert.NoError(t, err)
+	if assert.NotNil(t, found) {
+		assert.EqualValues(t, 2, found.GetGeneration())
+		assert.Equal(t, "ticket b2", found.Title)
+	}
+
+	// another generation with the same value of unique field should be rejected
+	_, err = s.Save(&ticket{TypeKind: typeTicket.GetTypeKind(), ID: "b2", Title: "duplicate"})
+	assert.Error(t, err, "Saving duplicate value of unique field should fail")
+
+	var all []*ticket
+	err = s.Find(typeTicket.Kind, &all, store.WithKey(key), store.WithWhereEq("ID", "a1", "b2", "c3"))
+	assert.NoError(t, err)
+	assert.Len(t, all, 3, "Rejected object shouldn't be saved")
+
+	// replacing the same generation with a different value should release the old value
+	_, err = s.Save(&ticket{TypeKind: typeTicket.GetTypeKind(), Metadata: runtime.GenerationMetadata{Generation: 2}, ID: "d4", Title: "ticket d4"}, store.WithReplaceOrForceGen())
+	assert.NoError(t, err)
+	assert.Empty(t, s.data["/index/"+indexes.NameForValue("ID", key, "b2", s.codec)])
+
+	_, err = s.Save(&ticket{TypeKind: typeTicket.GetTypeKind(), ID: "b2", Title: "ticket b2 again"})
+	assert.NoError(t, err, "Released value of unique field should be allowed again")
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+)
+
+// Watch delivers changes of objects made through this store
+func (s *memoryStore) Watch(ctx context.Context, kind runtime.Kind, keyPrefix runtime.Key) (<-chan *store.WatchEvent, error) {
+	s.types.Get(kind)
+	return s.watchers.Watch(ctx, kind, keyPrefix)
+}
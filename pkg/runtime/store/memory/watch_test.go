@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func nextEvent(t *testing.T, events <-chan *store.WatchEvent) *store.WatchEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch event wasn't delivered")
+		return nil
+	}
+}
+
+func TestMemoryStoreWatch(t *testing.T) {
+	s := New(runtime.NewTypes().Append(typeTask, typeNote), store.NewYAMLCodec())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := s.Watch(ctx, typeTask.Kind, runtime.KeyFromParts(runtime.SystemNS, typeTask.Kind, ""))
+	if !assert.NoError(t, err, "Watch should be started") {
+		t.FailNow()
+	}
+
+	key := runtime.KeyFromParts(runtime.SystemNS, typeTask.Kind, "first")
+	obj := &task{TypeKind: typeTask.GetTypeKind(), Name: "first", Status: "waiting"}
+	_, err = s.Save(obj)
+	assert.NoError(t, err)
+	// objects of other kinds and unchanged objects aren't reported
+	_, err = s.Save(&note{TypeKind: typeNote.GetTypeKind(), Name: "note"})
+	assert.NoError(t, err)
+	_, err = s.Save(obj)
+	assert.NoError(t, err)
+	obj.Status = "done"
+	_, err = s.Save(obj)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Delete(typeTask.Kind, key))
+
+	expected := []struct {
+		eventType store.EventType
+		gen       runtime.Generation
+		status    string
+	}{
+		{store.EventCreated, 1, "waiting"},
+		{store.EventUpdated, 2, "done"},
+		{store.EventDeleted, 2, "done"},
+	}
+	for _, exp := range expected {
+		event := nextEvent(t, events)
+		assert.Equal(t, exp.eventType, event.Type, "Event type should be correct")
+		assert.Equal(t, key, event.Key, "Event key should be correct")
+		assert.Equal(t, exp.gen, event.Gen, "Event generation should be correct")
+		if assert.NotNil(t, event.Object, "Event should include object") {
+			assert.Equal(t, exp.status, event.Object.(*task).Status, "Event object should be decoded")
+		}
+	}
+
+	cancel()
+	for range events {
+		// drain until closed
+	}
+	assert.Equal(t, 0, s.(*memoryStore).watchers.Len(), "Watcher should be removed once cancelled")
+}
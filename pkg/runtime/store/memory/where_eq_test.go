@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/engine"
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStoreWhereEqGenerations(t *testing.T) {
+	memoryStore := New(runtime.NewTypes().Append(engine.TypeRevision), store.NewYAMLCodec())
+
+	// generations 1-3 reference policy 1, generation 4 references policy 2
+	for idx, policyGen := range []runtime.Generation{1, 1, 1, 2} {
+		revision := &engine.Revision{
+			TypeKind:  engine.TypeRevision.GetTypeKind(),
+			Metadata:  runtime.GenerationMetadata{Generation: runtime.Generation(idx + 1)},
+			PolicyGen: policyGen,
+		}
+		_, err := memoryStore.Save(revision, store.WithReplaceOrForceGen())
+		if !assert.NoError(t, err, "Revision should be saved") {
+			t.FailNow()
+		}
+	}
+
+	var revisions []*engine.Revision
+	err := memoryStore.Find(engine.TypeRevision.Kind, &revisions, store.WithKey(engine.RevisionKey), store.WithWhereEq("PolicyGen", runtime.Generation(1)))
+	assert.NoError(t, err, "Revisions should be found by policy generation")
+	if assert.Len(t, revisions, 3, "All generations with matching field should be found") {
+		for idx, revision := range revisions {
+			assert.EqualValues(t, idx+1, revision.GetGeneration(), "Generations should be sorted")
+		}
+	}
+
+	var revision *engine.Revision
+	err = memoryStore.Find(engine.TypeRevision.Kind, &revision, store.WithKey(engine.RevisionKey), store.WithWhereEq("PolicyGen", runtime.Generation(1)), store.WithGetFirst())
+	assert.NoError(t, err, "First revision should be found")
+	if assert.NotNil(t, revision, "First revision should be found") {
+		assert.EqualValues(t, 1, revision.GetGeneration(), "Lowest generation should be returned")
+	}
+
+	err = memoryStore.Find(engine.TypeRevision.Kind, &revision, store.WithKey(engine.RevisionKey), store.WithWhereEq("PolicyGen", runtime.Generation(1)), store.WithGetLast())
+	assert.NoError(t, err, "Last revision should be found")
+	if assert.NotNil(t, revision, "Last revision should be found") {
+		assert.EqualValues(t, 3, revision.GetGeneration(), "Highest generation should be returned")
+	}
+
+	revisions = nil
+	err = memoryStore.Find(engine.TypeRevision.Kind, &revisions, store.WithKey(engine.RevisionKey), store.WithWhereEq("PolicyGen", runtime.Generation(1), runtime.Generation(2)))
+	assert.NoError(t, err, "Revisions should be found by multiple values")
+	assert.Len(t, revisions, 4, "Generations matching any of the values should be found")
+
+	revisions = nil
+	err = memoryStore.Find(engine.TypeRevision.Kind, &revisions, store.WithKey(engine.RevisionKey), store.WithWhereEq("PolicyGen", runtime.Generation(42)))
+	assert.NoError(t, err, "Search by non-matching value should not fail")
+	assert.Empty(t, revisions, "No revisions should be found by non-matching value")
+}
+
+func TestMemoryStoreWhereEqIndexedFieldChange(t *testing.T) {
+	s := New(runtime.NewTypes().Append(typeTask), store.NewYAMLCodec())
+	key := runtime.KeyFromParts(runtime.SystemNS, typeTask.Kind, "first")
+
+	obj := &task{TypeKind: typeTask.GetTypeKind(), Name: "first", Status: "waiting"}
+	_, err := s.Save(obj)
+	if !assert.NoError(t, err, "Task should be saved") {
+		t.FailNow()
+	}
+
+	// replacing the same generation moves it from the old index value to the new one
+	obj.Status = "in-progress"
+	_, err = s.Save(obj, store.WithReplaceOrForceGen())
+	if !assert.NoError(t, err, "Task should be replaced") {
+		t.FailNow()
+	}
+
+	var found []*task
+	assert.NoError(t, s.Find(typeTask.Kind, &found, store.WithKey(key), store.WithWhereEq("Status", "waiting")))
+	assert.Empty(t, found, "Old value should no longer match replaced generation")
+	assert.NoError(t, s.Find(typeTask.Kind, &found, store.WithKey(key), store.WithWhereEq("Status", "in-progress")))
+	assert.Len(t, found, 1, "New value should match replaced generation")
+
+	// new generation is indexed by the new value, while the previous generation keeps its own value
+	obj.Status = "done"
+	_, err = s.Save(obj)
+	if !assert.NoError(t, err, "Task should be saved") {
+		t.FailNow()
+	}
+
+	found = nil
+	assert.NoError(t, s.Find(typeTask.Kind, &found, store.WithKey(key), store.WithWhereEq("Status", "done")))
+	if assert.Len(t, found, 1, "New value should match new generation only") {
+		assert.EqualValues(t, 2, found[0].GetGeneration())
+	}
+	found = nil
+	assert.NoError(t, s.Find(typeTask.Kind, &found, store.WithKey(key), store.WithWhereEq("Status", "in-progress")))
+	if assert.Len(t, found, 1, "Previous generation should still match its own value") {
+		assert.EqualValues(t, 1, found[0].GetGeneration())
+	}
+}
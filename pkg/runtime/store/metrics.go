@@ -0,0 +1,148 @@
+package store
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// OperationSave is the name of the store operation saving a single object
+	OperationSave = "save"
+	// OperationSaveMany is the name of the store operation saving multiple objects in a single transaction
+	OperationSaveMany = "save-many"
+	// OperationFind is the name of the store operation searching for objects
+	OperationFind = "find"
+	// OperationFindIter is the name of the store operation iterating over objects
+	OperationFindIter = "find-iter"
+	// OperationDelete is the name of the store operation deleting a single object
+	OperationDelete = "delete"
+	// OperationCompact is the name of the store operation compacting a single object
+	OperationCompact = "compact"
+)
+
+// RetriesReporter is implemented by store backends, which retry transactions on conflicting changes (e.g. etcd STM),
+// so the number of retries could be exposed as metrics. Reporter should be set before store is used
+type RetriesReporter interface {
+	SetRetriesReporter(report func(operation string, kind runtime.Kind))
+}
+
+// metricsStore records number and duration of every store operation labeled with operation, kind and outcome
+type metricsStore struct {
+	Interface
+	operations *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+}
+
+// WithMetrics returns store, which collects metrics for all operations (number, duration and transaction retries of
+// operations) labeled with operation, kind and outcome, and registers them in a given prometheus registerer
+func WithMetrics(store Interface, registerer prometheus.Registerer) Interface {
+	operations := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "aptomi_store_operations_total",
+			Help:        "Number of store operations labeled with operation, kind and success.",
+			ConstLabels: prometheus.Labels{"service": "aptomi"},
+		},
+		[]string{"operation", "kind", "success"},
+	)
+	registerer.MustRegister(operations)
+
+	duration := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:        "aptomi_store_operation_duration_seconds",
+			Help:        "Duration of store operations labeled with operation, kind and success.",
+			ConstLabels: prometheus.Labels{"service": "aptomi"},
+			Buckets:     []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		},
+		[]string{"operation", "kind", "success"},
+	)
+	registerer.MustRegister(duration)
+
+	if reporter, ok := store.(RetriesReporter); ok {
+		retries := prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "aptomi_store_transaction_retries_total",
+				Help:        "Number of store transaction retries caused by conflicting changes labeled with operation and kind.",
+				ConstLabels: prometheus.Labels{"service": "aptomi"},
+			},
+			[]string{"operation", "kind"},
+		)
+		registerer.MustRegister(retries)
+		reporter.SetRetriesReporter(func(operation string, kind runtime.Kind) {
+			retries.WithLabelValues(operation, kind).Inc()
+		})
+	}
+
+	return &metricsStore{
+		Interface:  store,
+		operations: operations,
+		duration:   duration,
+	}
+}
+
+func (s *metricsStore) observe(operation string, kind runtime.Kind, start time.Time, err error) {
+	labels := []string{operation, kind, strconv.FormatBool(err == nil)}
+
+	s.operations.WithLabelValues(labels...).Inc()
+	s.duration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+}
+
+func (s *metricsStore) Save(storable runtime.Storable, opts ...SaveOpt) (bool, error) {
+	start := time.Now()
+	changed, err := s.Interface.Save(storable, opts...)
+	s.observe(OperationSave, KindOfAll([]runtime.Storable{storable}), start, err)
+	return changed, err
+}
+
+func (s *metricsStore) SaveMany(storables []runtime.Storable, opts ...SaveOpt) ([]bool, error) {
+	start := time.Now()
+	changed, err := s.Interface.SaveMany(storables, opts...)
+	s.observe(OperationSaveMany, KindOfAll(storables), start, err)
+	return changed, err
+}
+
+func (s *metricsStore) Find(kind runtime.Kind, result interface{}, opts ...FindOpt) error {
+	start := time.Now()
+	err := s.Interface.Find(kind, result, opts...)
+	s.observe(OperationFind, kind, start, err)
+	return err
+}
+
+func (s *metricsStore) FindIter(kind runtime.Kind, fn func(obj runtime.Object) error, opts ...FindOpt) error {
+	start := time.Now()
+	err := s.Interface.FindIter(kind, fn, opts...)
+	s.observe(OperationFindIter, kind, start, err)
+	return err
+}
+
+func (s *metricsStore) Delete(kind runtime.Kind, key runtime.Key) error {
+	start := time.Now()
+	err := s.Interface.Delete(kind, key)
+	s.observe(OperationDelete, kind, start, err)
+	return err
+}
+
+func (s *metricsStore) Compact(kind runtime.Kind, key runtime.Key, keepLast int, retain func(runtime.Generation) bool) ([]runtime.Generation, error) {
+	start := time.Now()
+	removed, err := s.Interface.Compact(kind, key, keepLast, retain)
+	s.observe(OperationCompact, kind, start, err)
+	return removed, err
+}
+
+// KindOfAll returns kind of all given objects if it's the same for all of them or "mixed" otherwise
+func KindOfAll(storables []runtime.Storable) runtime.Kind {
+	kind := ""
+	for _, storable := range storables {
+		if storable == nil {
+			continue
+		}
+		if kind == "" {
+			kind = storable.GetKind()
+		} else if kind != storable.GetKind() {
+			return "mixed"
+		}
+	}
+	return kind
+}
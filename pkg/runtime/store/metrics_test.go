@@ -0,0 +1,56 @@
+package store_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/storetest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// retryingStore is a store reporting a single retry for every save
+type retryingStore struct {
+	store.Interface
+	report func(operation string, kind runtime.Kind)
+}
+
+func (s *retryingStore) SetRetriesReporter(report func(operation string, kind runtime.Kind)) {
+	s.report = report
+}
+
+func (s *retryingStore) Save(storable runtime.Storable, opts ...store.SaveOpt) (bool, error) {
+	s.report(store.OperationSave, storable.GetKind())
+	return s.Interface.Save(storable, opts...)
+}
+
+func TestWithMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	s := store.WithMetrics(&retryingStore{Interface: memory.New(storetest.Types(), store.NewYAMLCodec())}, registry)
+
+	item := &storetest.Item{TypeKind: storetest.TypeItem.GetTypeKind(), Name: "item"}
+	for i := 0; i < 2; i++ {
+		_, err := s.Save(item)
+		assert.NoError(t, err, "Item should be saved")
+	}
+	var items []*storetest.Item
+	assert.NoError(t, s.Find(storetest.TypeItem.Kind, &items, store.WithKey(runtime.KeyForStorable(item))), "Items should be found")
+	assert.Error(t, s.Find(storetest.TypeItem.Kind, &items), "Find without options should fail")
+
+	expected := `
+# HELP aptomi_store_operations_total Number of store operations labeled with operation, kind and success.
+# TYPE aptomi_store_operations_total counter
+aptomi_store_operations_total{kind="conformance-item",operation="find",service="aptomi",success="false"} 1
+aptomi_store_operations_total{kind="conformance-item",operation="find",service="aptomi",success="true"} 1
+aptomi_store_operations_total{kind="conformance-item",operation="save",service="aptomi",success="true"} 2
+# HELP aptomi_store_transaction_retries_total Number of store transaction retries caused by conflicting changes labeled with operation and kind.
+# TYPE aptomi_store_transaction_retries_total counter
+aptomi_store_transaction_retries_total{kind="conformance-item",operation="save",service="aptomi"} 2
+`
+	err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "aptomi_store_operations_total", "aptomi_store_transaction_retries_total")
+	assert.NoError(t, err, "Operations and retries should be counted")
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+// IndexRebuildResult represents index entries changed while rebuilding indexes. In dry-run mode nothing is changed and
+// the result reports inconsistencies, which would be repaired
+type IndexRebuildResult struct {
+	// DryRun is true if inconsistencies were only reported without changing anything
+	DryRun bool `yaml:",omitempty"`
+	// Added is the number of missing index entries, which were added
+	Added int
+	// Updated is the number of index entries, which pointed to wrong generations or objects and were overwritten
+	Updated int
+	// Removed is the number of stale index entries, which don't match any stored object and were removed
+	Removed int
+
+	// Missing is the sorted list of names of missing index entries
+	Missing []string `yaml:",omitempty"`
+	// Divergent is the sorted list of names of index entries, which pointed to wrong generations or objects
+	Divergent []string `yaml:",omitempty"`
+	// Dangling is the sorted list of names of index entries, which pointed to absent objects
+	Dangling []string `yaml:",omitempty"`
+}
+
+// AddMissing records missing index entry with a given name
+func (result *IndexRebuildResult) AddMissing(indexName string) {
+	result.Added++
+	result.Missing = append(result.Missing, indexName)
+}
+
+// AddDivergent records index entry with a given name, which points to wrong generations or objects
+func (result *IndexRebuildResult) AddDivergent(indexName string) {
+	result.Updated++
+	result.Divergent = append(result.Divergent, indexName)
+}
+
+// AddDangling records index entry with a given name, which points to absent objects
+func (result *IndexRebuildResult) AddDangling(indexName string) {
+	result.Removed++
+	result.Dangling = append(result.Dangling, indexName)
+}
+
+// Sort sorts lists of recorded index entries, so results don't depend on the order stores iterate over entries
+func (result *IndexRebuildResult) Sort() {
+	sort.Strings(result.Missing)
+	sort.Strings(result.Divergent)
+	sort.Strings(result.Dangling)
+}
+
+// IndexEntry represents expected content of a single index entry. Last gen and unique indexes point to a single
+// generation, list indexes point to the sorted list of generations and namespace-wide unique indexes point to the key
+// of the object
+type IndexEntry struct {
+	Type IndexType
+	Gens []runtime.Generation
+	Key  runtime.Key
+}
+
+// IndexBuilder collects expected index entries for all stored generations of versioned objects of a single kind, so
+// store implementations could compare them with the stored index entries and repair the difference
+type IndexBuilder struct {
+	indexes *Indexes
+	codec   Codec
+	entries map[string]*IndexEntry
+	last    map[runtime.Key]runtime.Storable
+}
+
+// NewIndexBuilder creates IndexBuilder for objects of a given type
+func NewIndexBuilder(info *runtime.TypeInfo, codec Codec) *IndexBuilder {
+	return &IndexBuilder{
+		indexes: IndexesFor(info),
+		codec:   codec,
+		entries: map[string]*IndexEntry{},
+		last:    map[runtime.Key]runtime.Storable{},
+	}
+}
+
+// Add adds entries of all indexes for a given generation of the object
+func (b *IndexBuilder) Add(storable runtime.Storable) {
+	gen := storable.(runtime.Versioned).GetGeneration() // nolint: errcheck
+	key := runtime.KeyForStorable(storable)
+	if last, exist := b.last[key]; !exist || last.(runtime.Versioned).GetGeneration() < gen { // nolint: errcheck
+		b.last[key] = storable
+	}
+
+	for _, index := range b.indexes.List {
+		// last gen, namespace-wide unique indexes and list indexes holding the last generations only are built for
+		// the last generations only
+		if index.Type == IndexTypeLastGen || index.Type == IndexTypeUniqueKey || index.LastOnly {
+			continue
+		}
+		indexName := index.NameForStorable(storable, b.codec)
+		if indexName == "" {
+			continue
+		}
+		entry, exist := b.entries[indexName]
+		if !exist {
+			entry = &IndexEntry{Type: index.Type}
+			b.entries[indexName] = entry
+		}
+		if index.Type == IndexTypeListGen {
+			entry.Gens = append(entry.Gens, gen)
+		} else if len(entry.Gens) == 0 || entry.Gens[0] < gen {
+			// if unique value is used by more than one generation, the last one wins the same way as on save
+			entry.Gens = []runtime.Generation{gen}
+		}
+	}
+}
+
+// Entries returns all expected index entries keyed by index names
+func (b *IndexBuilder) Entries() map[string]*IndexEntry {
+	result := make(map[string]*IndexEntry, len(b.entries)+len(b.last))
+	for indexName, entry := range b.entries {
+		result[indexName] = &IndexEntry{Type: entry.Type, Gens: SortedUniqueGens(append([]runtime.Generation(nil), entry.Gens...))}
+	}
+
+	for key, last := range b.last {
+		gen := last.(runtime.Versioned).GetGeneration() // nolint: errcheck
+		for _, index := range b.indexes.List {
+			indexName := index.NameForStorable(last, b.codec)
+			if indexName == "" {
+				continue
+			}
+			if index.Type == IndexTypeLastGen || index.LastOnly {
+				result[indexName] = &IndexEntry{Type: index.Type, Gens: []runtime.Generation{gen}}
+			} else if index.Type == IndexTypeUniqueKey {
+				result[indexName] = &IndexEntry{Type: index.Type, Key: key}
+			}
+		}
+	}
+
+	return result
+}
+
+// IndexNameHasKind returns true if index with a given name is built for objects of a given kind. Index names start
+// with index type followed by the key (or namespace and kind for namespace-wide unique indexes)
+func IndexNameHasKind(indexName string, kind runtime.Kind) bool {
+	parts := strings.SplitN(indexName, runtime.KeySeparator, 4)
+	return len(parts) >= 3 && parts[2] == kind
+}
+
+// ObjectKeyHasKind returns true if object stored under a given key (key followed by "@" and generation) is of a given
+// kind
+func ObjectKeyHasKind(objectKey string, kind runtime.Kind) bool {
+	parts := strings.SplitN(objectKey, runtime.KeySeparator, 3)
+	return len(parts) >= 2 && strings.SplitN(parts[1], "@", 2)[0] == kind
+}
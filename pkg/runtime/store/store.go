@@ -1,6 +1,8 @@
 package store
 
 import (
+	"context"
+
 	"github.com/Aptomi/aptomi/pkg/runtime"
 )
 
@@ -8,7 +10,51 @@ import (
 type Interface interface {
 	Close() error
 
+	// Save saves object and returns true if a new generation was created or an existing one was replaced. Saving
+	// versioned object identical to the stored one doesn't write anything and returns false
 	Save(storable runtime.Storable, opts ...SaveOpt) (bool, error)
+
+	// SaveMany saves all objects with the same options along with their index updates. Batch is saved atomically, so
+	// either all of them are saved or none, only if it fits into a single transaction. Store could split batch, which
+	// exceeds its transaction limits (e.g. etcd MaxTxnOps), into several transactions executed in order, in which case
+	// atomicity only holds within each of them and objects from the already committed ones stay saved if one of the
+	// next transactions fails. It returns true for every object for which a new generation was created or an
+	// existing one was replaced, in the same order objects are passed, and sets new generations on saved objects
+	SaveMany(storables []runtime.Storable, opts ...SaveOpt) ([]bool, error)
+
+	// Find finds objects using a given options. Objects found with WithKeyPrefix are ordered by key as they're stored
+	// (the last generation of versioned objects ordered by key, non-versioned objects ordered by key followed by "@"
+	// and generation), while generations found with WithWhereEq are ordered by generation. The order is the same for
+	// all store backends, so it's stable for paging with WithLimit and WithContinueAfter
 	Find(kind runtime.Kind, result interface{}, opts ...FindOpt) error
+
+	// FindIter scans over non-versioned objects with keys prefixed by WithKeyPrefix and calls fn for every object one
+	// by one in the order of keys, without collecting all of them in memory. Iteration stops on the first error
+	// returned by fn
+	FindIter(kind runtime.Kind, fn func(obj runtime.Object) error, opts ...FindOpt) error
+
+	// Delete removes object with a given key. For versioned objects all generations get removed along with their
+	// index entries. Deleting non-existing object is a no-op
 	Delete(kind runtime.Kind, key runtime.Key) error
+
+	// Keys returns sorted keys of all stored objects of a given kind in all namespaces. Every versioned object is
+	// listed once regardless of the number of its generations
+	Keys(kind runtime.Kind) ([]runtime.Key, error)
+
+	// Watch delivers changes of objects of a given kind with keys prefixed by keyPrefix through the returned channel
+	// until ctx is cancelled, after which the channel gets closed. Only changes made after Watch is called are delivered
+	Watch(ctx context.Context, kind runtime.Kind, keyPrefix runtime.Key) (<-chan *WatchEvent, error)
+
+	// Compact removes old generations of a versioned object with a given key along with their index entries. The last
+	// keepLast generations are always kept, as well as generations for which retain returns true. It returns the list
+	// of removed generations
+	Compact(kind runtime.Kind, key runtime.Key, keepLast int, retain func(runtime.Generation) bool) ([]runtime.Generation, error)
+
+	// RebuildIndexes recomputes index entries for all stored generations of versioned objects of a given kind and
+	// replaces index entries of the kind with them, so indexes, which got out of sync with objects (e.g. after a crash),
+	// could be repaired. Store could apply changes in batches of transactions instead of a single one (etcd store
+	// changes at most rebuildTxnOps entries, i.e. 100, per transaction), so rebuild, which fails in the middle, could
+	// leave some of the entries repaired. It returns added, updated and removed index entries. If dryRun is set,
+	// nothing is changed and the index entries, which would be repaired, are returned
+	RebuildIndexes(kind runtime.Kind, dryRun bool) (*IndexRebuildResult, error)
 }
@@ -0,0 +1,1016 @@
+package storetest
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// TypeItem is a versioned object type used by conformance tests
+var TypeItem = &runtime.TypeInfo{
+	Kind:        "conformance-item",
+	Storable:    true,
+	Versioned:   true,
+	Constructor: func() runtime.Object { return &Item{} },
+}
+
+// Item is a versioned object with a list index and a composite index used by conformance tests
+type Item struct {
+	runtime.TypeKind `yaml:",inline"`
+	Metadata         runtime.GenerationMetadata
+
+	Name   string
+	Status string `store:"index"`
+	Zone   string `store:"index,group=placement"`
+	Rack   string `store:"index,group=placement"`
+}
+
+// GetName returns item name
+func (item *Item) GetName() string {
+	return item.Name
+}
+
+// GetNamespace returns item namespace
+func (item *Item) GetNamespace() string {
+	return runtime.SystemNS
+}
+
+// GetGeneration returns item generation
+func (item *Item) GetGeneration() runtime.Generation {
+	return item.Metadata.Generation
+}
+
+// SetGeneration sets item generation
+func (item *Item) SetGeneration(gen runtime.Generation) {
+	item.Metadata.Generation = gen
+}
+
+// TypeNote is a non-versioned object type used by conformance tests
+var TypeNote = &runtime.TypeInfo{
+	Kind:        "conformance-note",
+	Storable:    true,
+	Versioned:   false,
+	Constructor: func() runtime.Object { return &Note{} },
+}
+
+// Note is a non-versioned object used by conformance tests
+type Note struct {
+	runtime.TypeKind `yaml:",inline"`
+
+	Name string
+	Text string
+}
+
+// GetName returns note name
+func (note *Note) GetName() string {
+	return note.Name
+}
+
+// GetNamespace returns note namespace
+func (note *Note) GetNamespace() string {
+	return runtime.SystemNS
+}
+
+// TypeHost is a versioned object type with namespace-wide unique index used by conformance tests
+var TypeHost = &runtime.TypeInfo{
+	Kind:        "conformance-host",
+	Storable:    true,
+	Versioned:   true,
+	Constructor: func() runtime.Object { return &Host{} },
+}
+
+// Host is a versioned object, which address should be unique across all hosts in a namespace
+type Host struct {
+	runtime.TypeKind `yaml:",inline"`
+	Metadata         runtime.GenerationMetadata
+
+	Name    string
+	Address string `store:"index,unique=namespace"`
+}
+
+// GetName returns host name
+func (host *Host) GetName() string {
+	return host.Name
+}
+
+// GetNamespace returns host namespace
+func (host *Host) GetNamespace() string {
+	return runtime.SystemNS
+}
+
+// GetGeneration returns host generation
+func (host *Host) GetGeneration() runtime.Generation {
+	return host.Metadata.Generation
+}
+
+// SetGeneration sets host generation
+func (host *Host) SetGeneration(gen runtime.Generation) {
+	host.Metadata.Generation = gen
+}
+
+// TypeTicket is a versioned object type with list index holding the last generations only used by conformance tests
+var TypeTicket = &runtime.TypeInfo{
+	Kind:        "conformance-ticket",
+	Storable:    true,
+	Versioned:   true,
+	Constructor: func() runtime.Object { return &Ticket{} },
+}
+
+// Ticket is a versioned object, which is looked up by the assignee of its last generation only
+type Ticket struct {
+	runtime.TypeKind `yaml:",inline"`
+	Metadata         runtime.GenerationMetadata
+
+	Name     string
+	Assignee string `store:"index,last"`
+}
+
+// GetName returns ticket name
+func (ticket *Ticket) GetName() string {
+	return ticket.Name
+}
+
+// GetNamespace returns ticket namespace
+func (ticket *Ticket) GetNamespace() string {
+	return runtime.SystemNS
+}
+
+// GetGeneration returns ticket generation
+func (ticket *Ticket) GetGeneration() runtime.Generation {
+	return ticket.Metadata.Generation
+}
+
+// SetGeneration sets ticket generation
+func (ticket *Ticket) SetGeneration(gen runtime.Generation) {
+	ticket.Metadata.Generation = gen
+}
+
+// TypeRelease is a versioned object type with unique index used by conformance tests
+var TypeRelease = &runtime.TypeInfo{
+	Kind:        "conformance-release",
+	Storable:    true,
+	Versioned:   true,
+	Constructor: func() runtime.Object { return &Release{} },
+}
+
+// Release is a versioned object, which tag should identify a single generation
+type Release struct {
+	runtime.TypeKind `yaml:",inline"`
+	Metadata         runtime.GenerationMetadata
+
+	Name  string
+	Tag   string `store:"index,unique"`
+	Notes string
+}
+
+// GetName returns release name
+func (release *Release) GetName() string {
+	return release.Name
+}
+
+// GetNamespace returns release namespace
+func (release *Release) GetNamespace() string {
+	return runtime.SystemNS
+}
+
+// GetGeneration returns release generation
+func (release *Release) GetGeneration() runtime.Generation {
+	return release.Metadata.Generation
+}
+
+// SetGeneration sets release generation
+func (release *Release) SetGeneration(gen runtime.Generation) {
+	release.Metadata.Generation = gen
+}
+
+// Types returns types registry with all types used by conformance tests
+func Types() *runtime.Types {
+	return runtime.NewTypes().Append(TypeItem, TypeNote, TypeHost, TypeTicket, TypeRelease)
+}
+
+// NewStoreFunc creates a new empty store with a given types registry. It's called for every conformance test, so
+// tests don't interfere with each other (e.g. etcd store could use t.Name() as a prefix)
+type NewStoreFunc func(t *testing.T, types *runtime.Types) store.Interface
+
+// RunConformanceTests runs the shared test suite, which verifies that store implementation follows semantics
+// defined by store.Interface. It should be run against every store implementation
+func RunConformanceTests(t *testing.T, newStore NewStoreFunc) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.Interface)
+	}{
+		{"SaveVersioned", testSaveVersioned},
+		{"SaveNonVersioned", testSaveNonVersioned},
+		{"ReplaceOrForceGen", testReplaceOrForceGen},
+		{"SaveMany", testSaveMany},
+		{"FindByKeyPrefix", testFindByKeyPrefix},
+		{"FindWhereEq", testFindWhereEq},
+		{"FindFirstLast", testFindFirstLast},
+		{"FindWhereEqComposite", testFindWhereEqComposite},
+		{"FindWhereIn", testFindWhereIn},
+		{"FindGenRange", testFindGenRange},
+		{"FindPaged", testFindPaged},
+		{"FindPagedWithToken", testFindPagedWithToken},
+		{"LastOnlyIndex", testLastOnlyIndex},
+		{"UniqueGenIndex", testUniqueGenIndex},
+		{"UniqueKeyIndex", testUniqueKeyIndex},
+		{"UniqueKeyConcurrentSave", testUniqueKeyConcurrentSave},
+		{"Compact", testCompact},
+		{"CompactConcurrentSave", testCompactConcurrentSave},
+		{"RebuildIndexes", testRebuildIndexes},
+		{"Keys", testKeys},
+		{"BackupRoundTrip", testBackupRoundTrip},
+		{"Delete", testDelete},
+		{"ConcurrentSave", testConcurrentSave},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			s := newStore(t, Types())
+			if !assert.NotNil(t, s, "Store should be created") {
+				t.FailNow()
+			}
+			defer s.Close() // nolint: errcheck
+
+			test.fn(t, s)
+		})
+	}
+}
+
+func newItem(name string, status string) *Item {
+	return &Item{TypeKind: TypeItem.GetTypeKind(), Name: name, Status: status}
+}
+
+func itemKey(name string) runtime.Key {
+	return runtime.KeyFromParts(runtime.SystemNS, TypeItem.Kind, name)
+}
+
+func save(t *testing.T, s store.Interface, storable runtime.Storable, opts ...store.SaveOpt) bool {
+	changed, err := s.Save(storable, opts...)
+	if !assert.NoError(t, err, "Object should be saved") {
+		t.FailNow()
+	}
+	return changed
+}
+
+func testSaveVersioned(t *testing.T, s store.Interface) {
+	item := newItem("first", "waiting")
+	assert.True(t, save(t, s, item), "New object should be saved")
+	assert.EqualValues(t, 1, item.GetGeneration(), "First generation should be assigned")
+
+	item.Status = "inprogress"
+	assert.True(t, save(t, s, item), "Changed object should be saved")
+	assert.EqualValues(t, 2, item.GetGeneration(), "Generation should be incremented")
+
+	assert.False(t, save(t, s, item), "Unchanged object shouldn't be saved")
+	assert.EqualValues(t, 2, item.GetGeneration(), "Generation shouldn't be changed")
+
+	var last *Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &last, store.WithKey(itemKey("first"))))
+	assert.Equal(t, item, last, "Last generation should be found")
+
+	var first *Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &first, store.WithKey(itemKey("first")), store.WithGen(1)))
+	if assert.NotNil(t, first, "Specific generation should be found") {
+		assert.Equal(t, "waiting", first.Status)
+	}
+
+	err := s.Find(TypeItem.Kind, &first, store.WithKey(itemKey("first")), store.WithGen(42))
+	assert.True(t, store.IsGenNotFound(err), "Missing generation should be reported")
+
+	var missing *Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &missing, store.WithKey(itemKey("missing"))))
+	assert.Nil(t, missing, "Missing object shouldn't be found")
+}
+
+func testSaveNonVersioned(t *testing.T, s store.Interface) {
+	note := &Note{TypeKind: TypeNote.GetTypeKind(), Name: "note", Text: "first"}
+	assert.False(t, save(t, s, note), "Non-versioned object never creates generations")
+
+	note.Text = "second"
+	save(t, s, note)
+
+	var found *Note
+	assert.NoError(t, s.Find(TypeNote.Kind, &found, store.WithKey(runtime.KeyForStorable(note))))
+	assert.Equal(t, note, found, "Object should be replaced")
+}
+
+func testReplaceOrForceGen(t *testing.T, s store.Interface) {
+	item := newItem("first", "waiting")
+	save(t, s, item)
+	item.Status = "done"
+	save(t, s, item)
+
+	replaced := newItem("first", "replaced")
+	replaced.SetGeneration(1)
+	assert.True(t, save(t, s, replaced, store.WithReplaceOrForceGen()), "Generation should be replaced")
+	assert.False(t, save(t, s, replaced, store.WithReplaceOrForceGen()), "Identical generation shouldn't be replaced")
+
+	var items []*Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("first")), store.WithWhereEq("Status", "waiting")))
+	assert.Empty(t, items, "Replaced generation should be removed from index")
+	assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("first")), store.WithWhereEq("Status", "replaced")))
+	if assert.Len(t, items, 1, "Replaced generation should be indexed") {
+		assert.EqualValues(t, 1, items[0].GetGeneration())
+	}
+
+	_, err := s.Save(newItem("first", "empty gen"), store.WithReplaceOrForceGen())
+	assert.Error(t, err, "Replacing without generation should be reported")
+}
+
+func testSaveMany(t *testing.T, s store.Interface) {
+	unchanged := newItem("unchanged", "waiting")
+	save(t, s, unchanged)
+	updated := newItem("updated", "waiting")
+	save(t, s, updated)
+
+	updated.Status = "done"
+	note := &Note{TypeKind: TypeNote.GetTypeKind(), Name: "note", Text: "first"}
+	changed, err := s.SaveMany([]runtime.Storable{unchanged, updated, newItem("created", "waiting"), note})
+	if assert.NoError(t, err, "Objects should be saved") {
+		assert.Equal(t, []bool{false, true, true, false}, changed, "Only changed versioned objects should be reported")
+	}
+	assert.EqualValues(t, 2, updated.GetGeneration(), "Generation should be incremented")
+
+	var items []*Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKeyPrefix(itemKey(""))))
+	assert.Len(t, items, 3, "All objects should be saved")
+	var indexed []*Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &indexed, store.WithKey(itemKey("updated")), store.WithWhereEq("Status", "done")))
+	assert.Len(t, indexed, 1, "Saved objects should be indexed")
+
+	// second object is invalid, so nothing should be saved
+	replaced := newItem("updated", "replaced")
+	replaced.SetGeneration(1)
+	_, err = s.SaveMany([]runtime.Storable{replaced, newItem("invalid", "empty gen")}, store.WithReplaceOrForceGen())
+	assert.Error(t, err, "Invalid object should be reported")
+
+	var found *Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &found, store.WithKey(itemKey("updated")), store.WithGen(1)))
+	if assert.NotNil(t, found) {
+		assert.Equal(t, "waiting", found.Status, "Objects shouldn't be saved partially")
+	}
+	var replacedItems []*Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &replacedItems, store.WithKey(itemKey("updated")), store.WithWhereEq("Status", "replaced")))
+	assert.Empty(t, replacedItems, "Indexes shouldn't be updated partially")
+
+	_, err = s.SaveMany([]runtime.Storable{newItem("first", "waiting"), nil})
+	assert.Error(t, err, "Saving nil should be reported")
+}
+
+func testFindByKeyPrefix(t *testing.T, s store.Interface) {
+	for _, name := range []string{"a-first", "a-second", "b-third"} {
+		save(t, s, newItem(name, "waiting"))
+		save(t, s, newItem(name, "done"))
+	}
+
+	var items []*Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKeyPrefix(itemKey("a-"))))
+	if assert.Len(t, items, 2, "Only objects with matching keys should be found") {
+		assert.Equal(t, "a-first", items[0].Name)
+		assert.Equal(t, "a-second", items[1].Name)
+		for _, item := range items {
+			assert.EqualValues(t, 2, item.GetGeneration(), "Only last generations should be found")
+		}
+	}
+
+	items = nil
+	assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKeyPrefix("/"+itemKey(""))))
+	assert.Len(t, items, 3, "Leading slash in key prefix should be ignored")
+}
+
+func testFindWhereEq(t *testing.T, s store.Interface) {
+	for _, status := range []string{"waiting", "done", "waiting", "done"} {
+		save(t, s, newItem("first", status))
+	}
+
+	var items []*Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("first")), store.WithWhereEq("Status", "waiting")))
+	if assert.Len(t, items, 2, "All generations with matching field should be found") {
+		assert.EqualValues(t, 1, items[0].GetGeneration())
+		assert.EqualValues(t, 3, items[1].GetGeneration())
+	}
+
+	var first, last *Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &first, store.WithKey(itemKey("first")), store.WithWhereEq("Status", "done"), store.WithGetFirst()))
+	if assert.NotNil(t, first, "First matching generation should be found") {
+		assert.EqualValues(t, 2, first.GetGeneration())
+	}
+	assert.NoError(t, s.Find(TypeItem.Kind, &last, store.WithKey(itemKey("first")), store.WithWhereEq("Status", "waiting", "done"), store.WithGetLast()))
+	if assert.NotNil(t, last, "Last matching generation should be found") {
+		assert.EqualValues(t, 4, last.GetGeneration())
+	}
+
+	err := s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("first")), store.WithWhereEq("Name", "first"))
+	assert.Error(t, err, "Search by non-indexed field should be reported")
+}
+
+func testFindFirstLast(t *testing.T, s store.Interface) {
+	for _, status := range []string{"waiting", "done", "waiting", "done", "failed", "waiting"} {
+		save(t, s, newItem("first", status))
+	}
+
+	cases := []struct {
+		name     string
+		statuses []interface{}
+		opt      store.FindOpt
+		gen      runtime.Generation
+	}{
+		{"first of several", []interface{}{"waiting"}, store.WithGetFirst(), 1},
+		{"last of several", []interface{}{"waiting"}, store.WithGetLast(), 6},
+		{"first of single", []interface{}{"failed"}, store.WithGetFirst(), 5},
+		{"last of single", []interface{}{"failed"}, store.WithGetLast(), 5},
+		{"first of multiple values", []interface{}{"done", "failed"}, store.WithGetFirst(), 2},
+		{"last of multiple values", []interface{}{"done", "failed"}, store.WithGetLast(), 5},
+		{"none found", []interface{}{"unknown"}, store.WithGetLast(), 0},
+	}
+	for _, tc := range cases {
+		found := newItem("stale", "stale")
+		err := s.Find(TypeItem.Kind, &found, store.WithKey(itemKey("first")), store.WithWhereEq("Status", tc.statuses...), tc.opt)
+		if !assert.NoError(t, err, "Object should be found: %s", tc.name) {
+			continue
+		}
+		if tc.gen == 0 {
+			assert.Nil(t, found, "Nothing should be found: %s", tc.name)
+		} else if assert.NotNil(t, found, "Object should be found: %s", tc.name) {
+			assert.Equal(t, tc.gen, found.GetGeneration(), "Wrong generation found: %s", tc.name)
+		}
+	}
+
+	var items []*Item
+	err := s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("first")), store.WithWhereEq("Status", "waiting"), store.WithGetFirst())
+	assert.Error(t, err, "List result should be rejected with WithGetFirst")
+	assert.Empty(t, items, "Nothing should be added to list result")
+
+	var item *Item
+	err = s.Find(TypeItem.Kind, &item, store.WithKey(itemKey("first")), store.WithGetLast())
+	assert.Error(t, err, "WithGetLast without WithWhereEq should be rejected")
+}
+
+func testFindWhereIn(t *testing.T, s store.Interface) {
+	for _, status := range []string{"waiting", "done", "failed", "done"} {
+		save(t, s, newItem("first", status))
+	}
+
+	var items []*Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("first")), store.WithWhereIn("Status", "done", "waiting", "done")))
+	gens := make([]runtime.Generation, 0)
+	for _, item := range items {
+		gens = append(gens, item.GetGeneration())
+	}
+	assert.Equal(t, []runtime.Generation{1, 2, 4}, gens, "Generations matching overlapping values should be found once in order")
+
+	items = nil
+	assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("first")), store.WithWhereIn("Status")))
+	assert.Empty(t, items, "Empty set of values should match nothing")
+
+	var last *Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &last, store.WithKey(itemKey("first")), store.WithWhereIn("Status"), store.WithGetLast()))
+	assert.Nil(t, last, "Empty set of values should match nothing")
+}
+
+func testFindWhereEqComposite(t *testing.T, s store.Interface) {
+	placements := [][2]string{{"east", "1"}, {"east", "2"}, {"west", "1"}, {"east", "1"}}
+	for _, placement := range placements {
+		item := newItem("first", "waiting")
+		item.Zone, item.Rack = placement[0], placement[1]
+		save(t, s, item)
+	}
+
+	var items []*Item
+	eastFirst := store.GroupValue{"Zone": "east", "Rack": "1"}
+	assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("first")), store.WithWhereEq("placement", eastFirst)))
+	if assert.Len(t, items, 2, "All generations with matching group of fields should be found") {
+		assert.EqualValues(t, 1, items[0].GetGeneration())
+		assert.EqualValues(t, 4, items[1].GetGeneration())
+	}
+
+	// values could be listed in the order of fields of the group as well
+	items = nil
+	assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("first")), store.WithWhereEq("placement", []interface{}{"east", "2"})))
+	if assert.Len(t, items, 1, "Generation with matching list of values should be found") {
+		assert.EqualValues(t, 2, items[0].GetGeneration())
+	}
+
+	items = nil
+	westSecond := store.GroupValue{"Zone": "west", "Rack": "2"}
+	assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("first")), store.WithWhereEq("placement", westSecond)))
+	assert.Empty(t, items, "Only generations matching all fields of the group should be found")
+
+	err := s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("first")), store.WithWhereEq("placement", store.GroupValue{"Zone": "east"}))
+	assert.Error(t, err, "Search by incomplete group of fields should be reported")
+	err = s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("first")), store.WithWhereEq("placement", []interface{}{"east"}))
+	assert.Error(t, err, "Search by incomplete list of values should be reported")
+	err = s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("first")), store.WithWhereEq("Zone", "east"))
+	assert.Error(t, err, "Search by single field of the group should be reported")
+}
+
+func testFindGenRange(t *testing.T, s store.Interface) {
+	for _, status := range []string{"1", "2", "3", "4", "5"} {
+		save(t, s, newItem("first", status))
+	}
+
+	gensOf := func(from runtime.Generation, to runtime.Generation) []runtime.Generation {
+		var items []*Item
+		assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("first")), store.WithGenRange(from, to)))
+		gens := make([]runtime.Generation, 0)
+		for _, item := range items {
+			gens = append(gens, item.GetGeneration())
+		}
+		return gens
+	}
+
+	assert.Equal(t, []runtime.Generation{2, 3, 4}, gensOf(2, 4), "Both boundaries of the range should be inclusive")
+	assert.Equal(t, []runtime.Generation{3}, gensOf(3, 3), "Range with equal boundaries should return a single generation")
+	assert.Equal(t, []runtime.Generation{4, 5}, gensOf(4, 100), "Only existing generations should be returned")
+	assert.Empty(t, gensOf(6, 10), "Range without existing generations should be empty")
+	assert.Empty(t, gensOf(4, 2), "Range with from greater than to should be empty")
+
+	_, err := s.Compact(TypeItem.Kind, itemKey("first"), 2, func(gen runtime.Generation) bool { return gen == 1 })
+	assert.NoError(t, err, "Object should be compacted")
+	assert.Equal(t, []runtime.Generation{1, 4, 5}, gensOf(1, 5), "Compacted generations should be skipped")
+}
+
+func testFindPaged(t *testing.T, s store.Interface) {
+	names := []string{"a", "a-1", "b", "c", "d"}
+	for _, name := range names {
+		save(t, s, newItem(name, "waiting"))
+		save(t, s, &Note{TypeKind: TypeNote.GetTypeKind(), Name: name})
+	}
+
+	// versioned objects are ordered by key, while non-versioned ones are ordered by key followed by generation
+	pagedNames := map[runtime.Kind][]string{
+		TypeItem.Kind: {"a", "a-1", "b", "c", "d"},
+		TypeNote.Kind: {"a-1", "a", "b", "c", "d"},
+	}
+	for kind, expected := range pagedNames {
+		prefix := runtime.KeyFromParts(runtime.SystemNS, kind, "")
+		found := make([]string, 0)
+		after := ""
+		for page := 0; page < len(names); page++ {
+			opts := []store.FindOpt{store.WithKeyPrefix(prefix), store.WithLimit(2)}
+			if after != "" {
+				opts = append(opts, store.WithContinueAfter(after))
+			}
+
+			var pageNames []string
+			if kind == TypeItem.Kind {
+				var items []*Item
+				assert.NoError(t, s.Find(kind, &items, opts...), "Page should be found")
+				for _, item := range items {
+					pageNames = append(pageNames, item.Name)
+				}
+			} else {
+				var notes []*Note
+				assert.NoError(t, s.Find(kind, &notes, opts...), "Page should be found")
+				for _, note := range notes {
+					pageNames = append(pageNames, note.Name)
+				}
+			}
+			if len(pageNames) == 0 {
+				break
+			}
+			assert.True(t, len(pageNames) <= 2, "Page shouldn't exceed limit")
+			found = append(found, pageNames...)
+			after = runtime.KeyFromParts(runtime.SystemNS, kind, pageNames[len(pageNames)-1])
+		}
+		assert.Equal(t, expected, found, "All objects of kind %s should be paged through in order", kind)
+	}
+
+	// continuing after deleted object works as well
+	assert.NoError(t, s.Delete(TypeItem.Kind, itemKey("b")))
+	var items []*Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKeyPrefix(itemKey("")), store.WithContinueAfter(itemKey("b")), store.WithLimit(1)))
+	if assert.Len(t, items, 1, "Page after deleted object should be found") {
+		assert.Equal(t, "c", items[0].Name)
+	}
+}
+
+func testFindPagedWithToken(t *testing.T, s store.Interface) {
+	for _, name := range []string{"a", "b", "c"} {
+		save(t, s, newItem(name, "waiting"))
+	}
+	// generations with the same status differ in zone, so every save creates a new generation
+	for _, zone := range []string{"east", "west", "north"} {
+		item := newItem("a", "done")
+		item.Zone = zone
+		save(t, s, item)
+	}
+
+	// objects found with key prefix
+	found := make([]string, 0)
+	token := ""
+	for page := 0; page < 3; page++ {
+		var items []*Item
+		assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKeyPrefix(itemKey("")), store.WithLimit(2), store.WithContinue(token), store.WithNextToken(&token)), "Page should be found")
+		for _, item := range items {
+			found = append(found, item.Name)
+		}
+		if token == "" {
+			break
+		}
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, found, "All objects should be paged through with tokens")
+
+	// generations found with WithWhereEq
+	gens := make([]runtime.Generation, 0)
+	token = ""
+	for page := 0; page < 3; page++ {
+		var items []*Item
+		assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("a")), store.WithWhereEq("Status", "done"), store.WithLimit(2), store.WithContinue(token), store.WithNextToken(&token)), "Page should be found")
+		for _, item := range items {
+			gens = append(gens, item.GetGeneration())
+		}
+		if token == "" {
+			break
+		}
+	}
+	assert.Equal(t, []runtime.Generation{2, 3, 4}, gens, "All generations should be paged through with tokens")
+
+	var items []*Item
+	err := s.Find(TypeItem.Kind, &items, store.WithKeyPrefix(itemKey("")), store.WithLimit(2), store.WithContinue("invalid"))
+	assert.True(t, store.IsInvalidContinueToken(err), "Invalid token should be reported with typed error")
+}
+
+func newRelease(tag string, notes string) *Release {
+	return &Release{TypeKind: TypeRelease.GetTypeKind(), Name: "release", Tag: tag, Notes: notes}
+}
+
+func testUniqueGenIndex(t *testing.T, s store.Interface) {
+	save(t, s, newRelease("v1", "first"))
+	save(t, s, newRelease("v1", "first"))
+
+	_, err := s.Save(newRelease("v1", "second"))
+	if assert.True(t, store.IsDuplicateValue(err), "Value used by another generation should be reported with typed error") {
+		assert.EqualValues(t, 1, err.(*store.ErrDuplicateValue).HolderGen)
+	}
+
+	// new generation with a new value should be saved
+	assert.True(t, save(t, s, newRelease("v2", "second")), "Generation with unused value should be saved")
+}
+
+func newHost(name string, address string) *Host {
+	return &Host{TypeKind: TypeHost.GetTypeKind(), Name: name, Address: address}
+}
+
+func testUniqueKeyIndex(t *testing.T, s store.Interface) {
+	save(t, s, newHost("first", "10.0.0.1"))
+	save(t, s, newHost("first", "10.0.0.1"))
+	save(t, s, newHost("second", "10.0.0.2"))
+
+	_, err := s.Save(newHost("second", "10.0.0.1"))
+	if assert.True(t, store.IsDuplicateValue(err), "Value held by another object should be reported with typed error") {
+		assert.Equal(t, runtime.KeyFromParts(runtime.SystemNS, TypeHost.Kind, "first"), err.(*store.ErrDuplicateValue).HolderKey)
+	}
+	_, err = s.SaveMany([]runtime.Storable{newHost("third", "10.0.0.3"), newHost("fourth", "10.0.0.3")})
+	assert.True(t, store.IsDuplicateValue(err), "Conflicting objects shouldn't be saved together")
+
+	// new generation releases the value held by the previous one
+	save(t, s, newHost("first", "10.0.0.4"))
+	save(t, s, newHost("second", "10.0.0.1"))
+
+	// deleted object releases its value
+	assert.NoError(t, s.Delete(TypeHost.Kind, runtime.KeyFromParts(runtime.SystemNS, TypeHost.Kind, "first")))
+	save(t, s, newHost("third", "10.0.0.4"))
+
+	// compacted generations don't release the value held by the last one
+	save(t, s, newHost("second", "10.0.0.5"))
+	save(t, s, newHost("second", "10.0.0.1"))
+	_, err = s.Compact(TypeHost.Kind, runtime.KeyFromParts(runtime.SystemNS, TypeHost.Kind, "second"), 1, nil)
+	assert.NoError(t, err, "Object should be compacted")
+	_, err = s.Save(newHost("third", "10.0.0.1"))
+	assert.True(t, store.IsDuplicateValue(err), "Value held by the last generation should be kept after compaction")
+}
+
+func testUniqueKeyConcurrentSave(t *testing.T, s store.Interface) {
+	for round := 0; round < 10; round++ {
+		address := fmt.Sprintf("10.0.1.%d", round)
+
+		// two objects race for the same value, so exactly one of them should win
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = s.Save(newHost(fmt.Sprintf("racer-%d-%d", round, i), address))
+			}(i)
+		}
+		wg.Wait()
+
+		conflicts := 0
+		for _, err := range errs {
+			if err != nil {
+				assert.True(t, store.IsDuplicateValue(err), "Losing save should fail with typed error: %s", err)
+				conflicts++
+			}
+		}
+		assert.Equal(t, 1, conflicts, "Exactly one of concurrent saves should fail in round %d", round)
+	}
+}
+
+func testLastOnlyIndex(t *testing.T, s store.Interface) {
+	key := runtime.KeyFromParts(runtime.SystemNS, TypeTicket.Kind, "first")
+	find := func(assignee string) []*Ticket {
+		var tickets []*Ticket
+		assert.NoError(t, s.Find(TypeTicket.Kind, &tickets, store.WithKey(key), store.WithWhereEq("Assignee", assignee)))
+		return tickets
+	}
+
+	ticket := &Ticket{TypeKind: TypeTicket.GetTypeKind(), Name: "first", Assignee: "alice"}
+	save(t, s, ticket)
+	ticket.Assignee = "bob"
+	save(t, s, ticket)
+
+	// superseded generation shouldn't match the value it had anymore
+	assert.Empty(t, find("alice"), "Superseded generation should be removed from index")
+	if tickets := find("bob"); assert.Len(t, tickets, 1, "Last generation should be indexed") {
+		assert.EqualValues(t, 2, tickets[0].GetGeneration())
+	}
+
+	// the same value should match the last generation only
+	ticket.Assignee = "alice"
+	save(t, s, ticket)
+	assert.Empty(t, find("bob"), "Superseded generation should be removed from index")
+	if tickets := find("alice"); assert.Len(t, tickets, 1, "Only last generation should be indexed") {
+		assert.EqualValues(t, 3, tickets[0].GetGeneration())
+	}
+
+	// forced generation becomes the last one, so the previous last generation should be removed from index
+	forced := &Ticket{TypeKind: TypeTicket.GetTypeKind(), Name: "first", Assignee: "carol"}
+	forced.SetGeneration(5)
+	save(t, s, forced, store.WithReplaceOrForceGen())
+	assert.Empty(t, find("alice"), "Superseded generation should be removed from index")
+	if tickets := find("carol"); assert.Len(t, tickets, 1, "Forced generation should be indexed") {
+		assert.EqualValues(t, 5, tickets[0].GetGeneration())
+	}
+
+	// replaced last generation should be moved to the new value
+	forced.Assignee = "dave"
+	save(t, s, forced, store.WithReplaceOrForceGen())
+	assert.Empty(t, find("carol"), "Replaced generation should be removed from index")
+	assert.Len(t, find("dave"), 1, "Replaced generation should be indexed")
+
+	// superseded generations should still exist and indexes maintained by store should be consistent
+	var first *Ticket
+	assert.NoError(t, s.Find(TypeTicket.Kind, &first, store.WithKey(key), store.WithGen(1)))
+	if assert.NotNil(t, first, "Superseded generation should exist") {
+		assert.Equal(t, "alice", first.Assignee)
+	}
+	result, err := s.RebuildIndexes(TypeTicket.Kind, true)
+	assert.NoError(t, err, "Indexes should be checked")
+	assert.Equal(t, &store.IndexRebuildResult{DryRun: true}, result, "Indexes maintained by store should be consistent")
+}
+
+func testCompact(t *testing.T, s store.Interface) {
+	for _, status := range []string{"waiting", "running", "waiting", "running", "done"} {
+		save(t, s, newItem("first", status))
+	}
+
+	removed, err := s.Compact(TypeItem.Kind, itemKey("first"), 2, func(gen runtime.Generation) bool { return gen == 2 })
+	assert.NoError(t, err, "Object should be compacted")
+	assert.Equal(t, []runtime.Generation{1, 3}, removed, "Old generations, which aren't retained, should be removed")
+
+	var items []*Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("first")), store.WithWhereEq("Status", "waiting")))
+	assert.Empty(t, items, "Removed generations should be removed from indexes")
+	assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("first")), store.WithWhereEq("Status", "running")))
+	assert.Len(t, items, 2, "Kept generations should stay in indexes")
+
+	removed, err = s.Compact(TypeItem.Kind, itemKey("first"), 2, func(gen runtime.Generation) bool { return gen == 2 })
+	assert.NoError(t, err, "Object should be compacted")
+	assert.Empty(t, removed, "Compacted object shouldn't be compacted again")
+
+	removed, err = s.Compact(TypeItem.Kind, itemKey("missing"), 1, nil)
+	assert.NoError(t, err, "Missing object should be compacted as a no-op")
+	assert.Empty(t, removed, "Nothing should be removed from missing object")
+
+	_, err = s.Compact(TypeItem.Kind, itemKey("first"), 0, nil)
+	assert.Error(t, err, "The last generation should always be kept")
+	_, err = s.Compact(TypeNote.Kind, runtime.KeyFromParts(runtime.SystemNS, TypeNote.Kind, "first"), 1, nil)
+	assert.Error(t, err, "Non versioned object couldn't be compacted")
+}
+
+func testCompactConcurrentSave(t *testing.T, s store.Interface) {
+	const saves = 20
+
+	// object gets compacted while new generations are being saved, so generations listed by compaction get stale
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < saves; i++ {
+			if _, err := s.Save(newItem("first", fmt.Sprintf("status-%d", i))); err != nil {
+				assert.NoError(t, err, "Concurrent save should succeed")
+				return
+			}
+		}
+	}()
+	for i := 0; i < saves; i++ {
+		_, err := s.Compact(TypeItem.Kind, itemKey("first"), 2, nil)
+		assert.NoError(t, err, "Object should be compacted")
+	}
+	wg.Wait()
+
+	_, err := s.Compact(TypeItem.Kind, itemKey("first"), 2, nil)
+	assert.NoError(t, err, "Object should be compacted")
+
+	var items []*Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("first")), store.WithGenRange(runtime.FirstGen, saves)))
+	gens := make([]runtime.Generation, 0)
+	for _, item := range items {
+		gens = append(gens, item.GetGeneration())
+	}
+	assert.Equal(t, []runtime.Generation{saves - 1, saves}, gens, "The last generations should be kept")
+
+	var last *Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &last, store.WithKey(itemKey("first"))))
+	if assert.NotNil(t, last, "The last generation should be found") {
+		assert.EqualValues(t, saves, last.GetGeneration(), "Every save should create its own generation")
+	}
+}
+
+func testRebuildIndexes(t *testing.T, s store.Interface) {
+	for _, status := range []string{"waiting", "done", "waiting"} {
+		save(t, s, newItem("first", status))
+	}
+	save(t, s, newItem("second", "done"))
+	save(t, s, newHost("first", "10.0.0.1"))
+
+	result, err := s.RebuildIndexes(TypeItem.Kind, true)
+	assert.NoError(t, err, "Indexes should be checked")
+	assert.Equal(t, &store.IndexRebuildResult{DryRun: true}, result, "Indexes maintained by store should be consistent")
+
+	result, err = s.RebuildIndexes(TypeItem.Kind, false)
+	assert.NoError(t, err, "Indexes should be rebuilt")
+	assert.Equal(t, &store.IndexRebuildResult{}, result, "Indexes maintained by store should be already up to date")
+
+	result, err = s.RebuildIndexes(TypeHost.Kind, false)
+	assert.NoError(t, err, "Indexes should be rebuilt")
+	assert.Equal(t, &store.IndexRebuildResult{}, result, "Indexes maintained by store should be already up to date")
+
+	var items []*Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("first")), store.WithWhereEq("Status", "waiting")))
+	assert.Len(t, items, 2, "Objects should be found using rebuilt indexes")
+
+	_, err = s.RebuildIndexes(TypeNote.Kind, false)
+	assert.Error(t, err, "Rebuilding indexes of non versioned objects should be reported")
+}
+
+func testKeys(t *testing.T, s store.Interface) {
+	for _, status := range []string{"waiting", "done"} {
+		save(t, s, newItem("second", status))
+		save(t, s, newItem("first", status))
+	}
+	save(t, s, &Note{TypeKind: TypeNote.GetTypeKind(), Name: "first", Text: "text"})
+
+	keys, err := s.Keys(TypeItem.Kind)
+	assert.NoError(t, err, "Keys should be listed")
+	assert.Equal(t, []runtime.Key{itemKey("first"), itemKey("second")}, keys, "Every object should be listed once in the order of keys")
+
+	keys, err = s.Keys(TypeNote.Kind)
+	assert.NoError(t, err, "Keys should be listed")
+	assert.Equal(t, []runtime.Key{runtime.KeyFromParts(runtime.SystemNS, TypeNote.Kind, "first")}, keys, "Non versioned objects should be listed")
+
+	keys, err = s.Keys(TypeHost.Kind)
+	assert.NoError(t, err, "Keys should be listed")
+	assert.Empty(t, keys, "Objects of other kinds shouldn't be listed")
+}
+
+func testBackupRoundTrip(t *testing.T, s store.Interface) {
+	for _, status := range []string{"waiting", "done", "waiting"} {
+		save(t, s, newItem("first", status))
+	}
+	save(t, s, newItem("second", "done"))
+	save(t, s, &Note{TypeKind: TypeNote.GetTypeKind(), Name: "first", Text: "text"})
+	save(t, s, newHost("first", "10.0.0.1"))
+
+	codec := store.NewYAMLCodec()
+	backup := &bytes.Buffer{}
+	result, err := store.Export(s, Types(), codec, backup)
+	if !assert.NoError(t, err, "Store should be exported") {
+		t.FailNow()
+	}
+	assert.Equal(t, map[runtime.Kind]int{TypeItem.Kind: 4, TypeNote.Kind: 1, TypeHost.Kind: 1}, result.Objects, "All generations of all objects should be exported")
+
+	_, err = store.Import(s, Types(), codec, bytes.NewReader(backup.Bytes()), false)
+	assert.Error(t, err, "Import into non-empty store should fail unless forced")
+
+	for _, info := range []*runtime.TypeInfo{TypeItem, TypeNote, TypeHost} {
+		keys, errKeys := s.Keys(info.Kind)
+		assert.NoError(t, errKeys)
+		for _, key := range keys {
+			assert.NoError(t, s.Delete(info.Kind, key), "Object should be deleted")
+		}
+	}
+
+	result, err = store.Import(s, Types(), codec, bytes.NewReader(backup.Bytes()), false)
+	if !assert.NoError(t, err, "Backup should be imported into empty store") {
+		t.FailNow()
+	}
+	assert.Equal(t, 6, result.Total(), "All objects should be imported")
+
+	var items []*Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("first")), store.WithGenRange(1, 10)))
+	if assert.Len(t, items, 3, "All generations should be restored") {
+		for idx, status := range []string{"waiting", "done", "waiting"} {
+			assert.EqualValues(t, idx+1, items[idx].GetGeneration(), "Generations should match exported ones")
+			assert.Equal(t, status, items[idx].Status)
+		}
+	}
+
+	var last *Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &last, store.WithKey(itemKey("first"))))
+	if assert.NotNil(t, last, "Last generation should be found") {
+		assert.EqualValues(t, 3, last.GetGeneration(), "Last gen index should point to the last restored generation")
+	}
+
+	items = nil
+	assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("first")), store.WithWhereEq("Status", "waiting")))
+	assert.Len(t, items, 2, "List indexes should be restored")
+
+	var note *Note
+	assert.NoError(t, s.Find(TypeNote.Kind, &note, store.WithKey(runtime.KeyFromParts(runtime.SystemNS, TypeNote.Kind, "first"))))
+	if assert.NotNil(t, note, "Non versioned object should be restored") {
+		assert.Equal(t, "text", note.Text)
+	}
+
+	_, err = s.Save(newHost("second", "10.0.0.1"))
+	assert.Error(t, err, "Unique indexes should be restored")
+
+	result, err = store.Import(s, Types(), codec, bytes.NewReader(backup.Bytes()), true)
+	assert.NoError(t, err, "Forced import into non-empty store should succeed")
+	assert.Equal(t, 6, result.Total(), "All objects should be imported")
+
+	_, err = store.Import(s, Types(), codec, bytes.NewReader([]byte("garbage")), true)
+	assert.Error(t, err, "Data, which isn't a backup, should be rejected")
+}
+
+func testDelete(t *testing.T, s store.Interface) {
+	for _, name := range []string{"first", "second"} {
+		save(t, s, newItem(name, "waiting"))
+		save(t, s, newItem(name, "done"))
+	}
+
+	assert.NoError(t, s.Delete(TypeItem.Kind, itemKey("first")), "Object should be deleted")
+	assert.NoError(t, s.Delete(TypeItem.Kind, itemKey("first")), "Deleting missing object should be a no-op")
+
+	var deleted *Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &deleted, store.WithKey(itemKey("first"))))
+	assert.Nil(t, deleted, "Deleted object shouldn't be found")
+
+	var items []*Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKey(itemKey("first")), store.WithWhereEq("Status", "waiting", "done")))
+	assert.Empty(t, items, "Deleted object should be removed from indexes")
+
+	assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKeyPrefix(itemKey(""))))
+	if assert.Len(t, items, 1, "Other objects shouldn't be deleted") {
+		assert.Equal(t, "second", items[0].Name)
+	}
+
+	// deleted object starts from the first generation
+	item := newItem("first", "waiting")
+	save(t, s, item)
+	assert.EqualValues(t, 1, item.GetGeneration(), "Generations should start over after delete")
+}
+
+func testConcurrentSave(t *testing.T, s store.Interface) {
+	const workers = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// every worker saves its own object and a new generation of the shared one
+			if _, err := s.Save(newItem(fmt.Sprintf("worker-%d", i), "done")); err != nil {
+				errs <- err
+			}
+			if _, err := s.Save(newItem("shared", fmt.Sprintf("status-%d", i))); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err, "Concurrent save should succeed")
+	}
+
+	var items []*Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &items, store.WithKeyPrefix(itemKey("worker-"))))
+	assert.Len(t, items, workers, "All objects should be saved")
+
+	var shared *Item
+	assert.NoError(t, s.Find(TypeItem.Kind, &shared, store.WithKey(itemKey("shared"))))
+	if assert.NotNil(t, shared, "Shared object should be saved") {
+		assert.EqualValues(t, workers, shared.GetGeneration(), "Every concurrent save should create its own generation")
+	}
+}
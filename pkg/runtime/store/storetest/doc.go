@@ -0,0 +1,3 @@
+// Package storetest provides the conformance test suite shared by all store.Interface implementations, so that
+// in-memory store used in unit tests behaves exactly the same as etcd store.
+package storetest
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the tracer used by store to report tracing spans
+const TracerName = "aptomi/store"
+
+const (
+	// SpanSave is the name of the tracing span covering saving of a single object
+	SpanSave = "store.save"
+	// SpanSaveMany is the name of the tracing span covering saving of multiple objects in a single transaction
+	SpanSaveMany = "store.save-many"
+	// SpanFind is the name of the tracing span covering search for objects
+	SpanFind = "store.find"
+	// SpanFindIter is the name of the tracing span covering iteration over objects
+	SpanFindIter = "store.find-iter"
+	// SpanDelete is the name of the tracing span covering deletion of a single object
+	SpanDelete = "store.delete"
+	// SpanCompact is the name of the tracing span covering compaction of a single object
+	SpanCompact = "store.compact"
+)
+
+// tracedStore reports a tracing span for every store operation as a child of the span from a given context
+type tracedStore struct {
+	Interface
+	ctx    context.Context
+	tracer trace.Tracer
+}
+
+// WithTracing returns store, which reports tracing spans for all operations as children of the span from a given
+// context. If there is no recording span in the context (e.g. tracing is disabled), the store is returned as is, so
+// there is no overhead
+func WithTracing(ctx context.Context, store Interface) Interface {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return store
+	}
+	if traced, ok := store.(*tracedStore); ok {
+		store = traced.Interface
+	}
+	return &tracedStore{
+		Interface: store,
+		ctx:       ctx,
+		tracer:    otel.Tracer(TracerName),
+	}
+}
+
+func (s *tracedStore) start(name string, kind runtime.Kind) trace.Span {
+	_, span := s.tracer.Start(s.ctx, name, trace.WithAttributes(attribute.String("kind", kind)))
+	return span
+}
+
+// end finishes a given span. Only the type of the error gets recorded, as error messages could contain object data
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.SetAttributes(attribute.String("error.type", fmt.Sprintf("%T", err)))
+		span.SetStatus(codes.Error, "store operation failed")
+	}
+	span.End()
+}
+
+func (s *tracedStore) Save(storable runtime.Storable, opts ...SaveOpt) (bool, error) {
+	span := s.start(SpanSave, storable.GetKind())
+	span.SetAttributes(attribute.String("key", runtime.KeyForStorable(storable)))
+	changed, err := s.Interface.Save(storable, opts...)
+	end(span, err)
+	return changed, err
+}
+
+func (s *tracedStore) SaveMany(storables []runtime.Storable, opts ...SaveOpt) ([]bool, error) {
+	_, span := s.tracer.Start(s.ctx, SpanSaveMany, trace.WithAttributes(attribute.Int("count", len(storables))))
+	changed, err := s.Interface.SaveMany(storables, opts...)
+	end(span, err)
+	return changed, err
+}
+
+func (s *tracedStore) Find(kind runtime.Kind, result interface{}, opts ...FindOpt) error {
+	span := s.start(SpanFind, kind)
+	err := s.Interface.Find(kind, result, opts...)
+	end(span, err)
+	return err
+}
+
+func (s *tracedStore) FindIter(kind runtime.Kind, fn func(obj runtime.Object) error, opts ...FindOpt) error {
+	span := s.start(SpanFindIter, kind)
+	err := s.Interface.FindIter(kind, fn, opts...)
+	end(span, err)
+	return err
+}
+
+func (s *tracedStore) Delete(kind runtime.Kind, key runtime.Key) error {
+	span := s.start(SpanDelete, kind)
+	span.SetAttributes(attribute.String("key", key))
+	err := s.Interface.Delete(kind, key)
+	end(span, err)
+	return err
+}
+
+func (s *tracedStore) Compact(kind runtime.Kind, key runtime.Key, keepLast int, retain func(runtime.Generation) bool) ([]runtime.Generation, error) {
+	span := s.start(SpanCompact, kind)
+	span.SetAttributes(attribute.String("key", key))
+	removed, err := s.Interface.Compact(kind, key, keepLast, retain)
+	end(span, err)
+	return removed, err
+}
@@ -0,0 +1,53 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/memory"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestWithTracingRecordsErrorType(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prevProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(prevProvider)
+
+	ctx, span := provider.Tracer("test").Start(context.Background(), "test")
+	s := store.WithTracing(ctx, memory.New(storetest.Types(), store.NewYAMLCodec()))
+
+	// error message of the conflicting save contains the key of the object holding the value
+	newHost := func(name string) *storetest.Host {
+		return &storetest.Host{TypeKind: storetest.TypeHost.GetTypeKind(), Name: name, Address: "10.0.0.1"}
+	}
+	_, err := s.Save(newHost("holder"))
+	assert.NoError(t, err, "Host should be saved")
+	_, err = s.Save(newHost("conflicting"))
+	assert.True(t, store.IsDuplicateValue(err), "Error should be returned as is")
+	span.End()
+
+	var failed []tracetest.SpanStub
+	for _, stub := range exporter.GetSpans() {
+		if stub.Name == store.SpanSave && stub.Status.Code == codes.Error {
+			failed = append(failed, stub)
+		}
+	}
+	if !assert.Len(t, failed, 1, "Failed save should be reported") {
+		t.FailNow()
+	}
+	assert.Empty(t, failed[0].Events, "Error message shouldn't be recorded as event")
+	assert.Contains(t, failed[0].Attributes, attribute.String("error.type", "*store.ErrDuplicateValue"), "Error type should be recorded")
+	for _, attr := range failed[0].Attributes {
+		assert.NotContains(t, attr.Value.Emit(), "holder", "Error message shouldn't be recorded")
+	}
+	assert.NotContains(t, failed[0].Status.Description, "holder", "Error message shouldn't be recorded")
+}
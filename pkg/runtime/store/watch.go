@@ -0,0 +1,35 @@
+package store
+
+import (
+	"github.com/Aptomi/aptomi/pkg/runtime"
+)
+
+// EventType represents type of the object change
+type EventType string
+
+const (
+	// EventCreated is the type of event for the first save of an object
+	EventCreated EventType = "created"
+	// EventUpdated is the type of event for saving a new generation of an object or replacing the existing one
+	EventUpdated EventType = "updated"
+	// EventDeleted is the type of event for deleting an object (including all its generations for versioned objects)
+	EventDeleted EventType = "deleted"
+)
+
+// WatchEvent represents a single change of the object, observed by Watch
+type WatchEvent struct {
+	Type EventType
+	Key  runtime.Key
+	Gen  runtime.Generation
+
+	// Object is the saved object, or the last known state of the object for deletes (it could be nil, if it's unknown)
+	Object runtime.Object
+}
+
+// watchEventBufferSize is the size of the channel watch events are delivered through
+const watchEventBufferSize = 100
+
+// NewWatchChannel creates a channel for delivering watch events to watchers
+func NewWatchChannel() chan *WatchEvent {
+	return make(chan *WatchEvent, watchEventBufferSize)
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Aptomi/aptomi/pkg/runtime"
+	log "github.com/sirupsen/logrus"
+)
+
+// Watchers keeps track of watchers for store backends, which see all changes in the same process (e.g. in-memory or
+// file-backed stores), and delivers changes to them. Store operations never block on slow consumers, as every
+// watcher queues its events
+type Watchers struct {
+	codec Codec
+
+	mu   sync.Mutex
+	list []*watcher
+}
+
+// NewWatchers creates an empty list of watchers, which uses a given codec to decode changed objects
+func NewWatchers(codec Codec) *Watchers {
+	return &Watchers{codec: codec}
+}
+
+// Watch registers a new watcher for objects of a given kind with keys prefixed by keyPrefix. Watcher is removed and
+// the returned channel is closed once ctx is cancelled
+func (ws *Watchers) Watch(ctx context.Context, kind runtime.Kind, keyPrefix runtime.Key) (<-chan *WatchEvent, error) {
+	keyPrefix = strings.TrimPrefix(keyPrefix, "/")
+	if keyPrefix == "" {
+		return nil, fmt.Errorf("key prefix is required to watch objects of kind %s", kind)
+	}
+
+	w := &watcher{
+		kind:   kind,
+		prefix: keyPrefix,
+		notify: make(chan struct{}, 1),
+	}
+	ws.mu.Lock()
+	ws.list = append(ws.list, w)
+	ws.mu.Unlock()
+
+	events := NewWatchChannel()
+	go func() {
+		defer close(events)
+		defer ws.remove(w)
+		w.run(ctx, events)
+	}()
+
+	return events, nil
+}
+
+// Len returns the number of active watchers
+func (ws *Watchers) Len() int {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	return len(ws.list)
+}
+
+func (ws *Watchers) remove(w *watcher) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	for idx, existing := range ws.list {
+		if existing == w {
+			ws.list = append(ws.list[:idx], ws.list[idx+1:]...)
+			return
+		}
+	}
+}
+
+// Notify delivers change of an object to all matching watchers, each of them gets its own copy of the object decoded
+// from a given data (object is omitted if data is empty). It should be called in the same order changes are made
+func (ws *Watchers) Notify(info *runtime.TypeInfo, eventType EventType, key runtime.Key, gen runtime.Generation, data []byte) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	for _, w := range ws.list {
+		if w.kind != info.Kind || !strings.HasPrefix(key, w.prefix) {
+			continue
+		}
+		event := &WatchEvent{Type: eventType, Key: key, Gen: gen}
+		if len(data) > 0 {
+			obj := info.New()
+			if err := ws.codec.Unmarshal(data, obj); err != nil {
+				log.Warnf("Can't decode watched object %s: %s", key, err)
+			} else {
+				event.Object = obj
+			}
+		}
+		w.push(event)
+	}
+}
+
+// watcher queues events for a single Watch call
+type watcher struct {
+	kind   runtime.Kind
+	prefix runtime.Key
+
+	mu     sync.Mutex
+	queue  []*WatchEvent
+	notify chan struct{}
+}
+
+func (w *watcher) push(event *WatchEvent) {
+	w.mu.Lock()
+	w.queue = append(w.queue, event)
+	w.mu.Unlock()
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (w *watcher) run(ctx context.Context, events chan<- *WatchEvent) {
+	for {
+		w.mu.Lock()
+		queue := w.queue
+		w.queue = nil
+		w.mu.Unlock()
+
+		for _, event := range queue {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-w.notify:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
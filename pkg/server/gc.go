@@ -0,0 +1,49 @@
+package server
+
+import (
+	"time"
+
+	"github.com/Aptomi/aptomi/pkg/runtime/registry"
+	log "github.com/sirupsen/logrus"
+)
+
+func (server *Server) gcRetention() *registry.GCRetention {
+	retention := &registry.GCRetention{
+		KeepLast:        server.cfg.GC.KeepLast,
+		KeepLastHistory: server.cfg.GC.KeepLastHistory,
+		KeepLastByKind:  make(map[string]int),
+	}
+	for kind, keepLast := range server.cfg.GC.KeepLastByKind {
+		retention.KeepLastByKind[kind] = keepLast
+	}
+
+	return retention
+}
+
+func (server *Server) startGarbageCollector() {
+	if !server.cfg.GC.Disabled {
+		server.runInBackground("Garbage Collector", true, func() {
+			server.gcLoop()
+		})
+	}
+}
+
+func (server *Server) gcLoop() {
+	interval := server.cfg.GC.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	retention := server.gcRetention()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		result, err := server.registry.CollectGarbage(retention, time.Now())
+		if err != nil {
+			log.Errorf("error while collecting garbage: %s", err)
+		}
+		if result != nil && result.Total() > 0 {
+			log.Infof("Garbage collection removed %d old object generations: %v", result.Total(), result.Removed)
+		}
+	}
+}
@@ -1,18 +1,29 @@
 package server
 
 import (
+	"context"
+	"encoding/base64"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"os/signal"
 	"runtime/pprof"
 	"runtime/trace"
+	"sort"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/Aptomi/aptomi/pkg/api"
+	"github.com/Aptomi/aptomi/pkg/api/admission"
 	"github.com/Aptomi/aptomi/pkg/api/middleware"
 	"github.com/Aptomi/aptomi/pkg/config"
+	"github.com/Aptomi/aptomi/pkg/engine/apply/chaos"
+	"github.com/Aptomi/aptomi/pkg/engine/enforce"
+	"github.com/Aptomi/aptomi/pkg/engine/pipeline"
+	"github.com/Aptomi/aptomi/pkg/engine/resolve"
+	"github.com/Aptomi/aptomi/pkg/event"
 	"github.com/Aptomi/aptomi/pkg/external"
 	"github.com/Aptomi/aptomi/pkg/external/secrets"
 	"github.com/Aptomi/aptomi/pkg/external/users"
@@ -25,6 +36,7 @@ import (
 	"github.com/Aptomi/aptomi/pkg/runtime"
 	"github.com/Aptomi/aptomi/pkg/runtime/registry"
 	"github.com/Aptomi/aptomi/pkg/runtime/store"
+	"github.com/Aptomi/aptomi/pkg/runtime/store/bolt"
 	"github.com/Aptomi/aptomi/pkg/runtime/store/etcd"
 	"github.com/Aptomi/aptomi/pkg/server/ui"
 	"github.com/gorilla/handlers"
@@ -44,47 +56,62 @@ type Server struct {
 
 	externalData *external.Data
 	registry     registry.Interface
+	election     store.Election
 
 	httpServer *http.Server
 
-	runDesiredStateEnforcement    chan bool
-	desiredStateEnforcementIdx    uint
+	// metrics is the registry of metrics collected by the server, which get served at /metrics
+	metrics *prometheus.Registry
+
+	apiServer                     *api.Server
 	enforcerPluginRegistryFactory plugin.RegistryFactory
 
 	runActualStateUpdate         chan bool
 	actualStateUpdateIdx         uint
 	updaterPluginRegistryFactory plugin.RegistryFactory
-
-	desiredStateEnforcements        prometheus.Counter
-	desiredStateEnforcementDuration prometheus.Histogram
 }
 
 // NewServer creates a new Aptomi Server
 func NewServer(cfg *config.Server) *Server {
 	s := &Server{
-		cfg:                        cfg,
-		backgroundErrors:           make(chan string),
-		runDesiredStateEnforcement: make(chan bool, 2048),
-		runActualStateUpdate:       make(chan bool, 2048),
+		cfg:                  cfg,
+		backgroundErrors:     make(chan string),
+		runActualStateUpdate: make(chan bool, 2048),
+		metrics:              prometheus.NewRegistry(),
 	}
 
 	return s
 }
 
+// NewAPIServer creates an API server with exactly the same wiring as Aptomi server uses (etcd registry, users and
+// secrets from config, kubernetes or noop plugins), which allows to embed it into another binary
+func NewAPIServer(cfg *config.Server) *api.Server {
+	server := NewServer(cfg)
+	server.initRegistry()
+	server.initExternalData()
+	server.initPluginRegistryFactory()
+	server.initPolicyOnFirstRun()
+	return api.NewServer(server.newAPIOptions())
+}
+
 // Start initializes Aptomi server, starts API & UI processing, and as well as runs the required background jobs for
 // continuous policy resolution and state enforcement
 func (server *Server) Start() {
 	// Init server
 	server.initProfiling()
+	server.initTracing()
+	server.initEventLog()
+	server.initResolver()
 	server.initRegistry()
 	server.initExternalData()
 	server.initPluginRegistryFactory()
 	server.initPolicyOnFirstRun()
 
-	// Start API, UI, Enforcer and ActualStateUpdater
+	// Start API, UI, Enforcer, ActualStateUpdater and GarbageCollector
 	server.startHTTPServer()
 	server.startDesiredStateEnforcer()
 	server.startActualStateUpdater()
+	server.startGarbageCollector()
 
 	// Wait for jobs to complete (it essentially hangs forever)
 	server.wait()
@@ -106,6 +133,16 @@ func (server *Server) initPolicyOnFirstRun() {
 	}
 }
 
+func (server *Server) initEventLog() {
+	event.SetDefaultBufferConfig(server.cfg.EventLog)
+}
+
+func (server *Server) initResolver() {
+	if server.cfg.Resolver.MaxConcurrency > 0 {
+		resolve.MaxConcurrentGoRoutines = server.cfg.Resolver.MaxConcurrency
+	}
+}
+
 func (server *Server) initExternalData() {
 	userLoaders := make([]users.UserLoader, 0)
 	for _, ldap := range server.cfg.Users.LDAP {
@@ -166,12 +203,133 @@ func (server *Server) initProfiling() {
 	}
 }
 
+// NewStoreCodec creates store codec from a given DB config with all registered object types
+func NewStoreCodec(cfg config.DB) (store.Codec, error) {
+	types := runtime.NewTypes().Append(registry.Types...)
+
+	var codec store.Codec
+	switch cfg.GetCodec() {
+	case config.DBCodecYAML:
+		codec = store.NewYAMLCodec()
+	case config.DBCodecGob:
+		codec = store.NewGobCodec(types)
+	default:
+		return nil, fmt.Errorf("unsupported db codec: %s", cfg.Codec)
+	}
+
+	if cfg.Compression != "" {
+		var err error
+		codec, err = store.NewCompressedCodec(codec, cfg.Compression, cfg.CompressionLevel)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// objects are encrypted after compression, as encrypted data couldn't be compressed
+	if len(cfg.EncryptionKeys) > 0 {
+		return newEncryptedStoreCodec(codec, cfg.EncryptionKeys)
+	}
+
+	return codec, nil
+}
+
+// newEncryptedStoreCodec wraps a given codec to encrypt objects with the key of the highest version, while all keys
+// are used for decryption
+func newEncryptedStoreCodec(codec store.Codec, cfgKeys []config.DBEncryptionKey) (store.Codec, error) {
+	keys := make([]store.EncryptionKey, 0, len(cfgKeys))
+	for _, cfgKey := range cfgKeys {
+		if cfgKey.Version < 1 || cfgKey.Version > 255 {
+			return nil, fmt.Errorf("db encryption key version should be from 1 to 255: %d", cfgKey.Version)
+		}
+
+		encoded := []byte(cfgKey.Key)
+		if len(cfgKey.KeyFile) > 0 {
+			var err error
+			encoded, err = ioutil.ReadFile(cfgKey.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("error while reading db encryption key with version %d: %s", cfgKey.Version, err)
+			}
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+		if err != nil {
+			return nil, fmt.Errorf("error while decoding db encryption key with version %d: %s", cfgKey.Version, err)
+		}
+		keys = append(keys, store.EncryptionKey{Version: byte(cfgKey.Version), Key: key})
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Version > keys[j].Version
+	})
+
+	return store.NewEncryptedCodec(codec, keys[0], keys[1:]...)
+}
+
+// NewStore creates store backend from a given DB config with all registered object types
+func NewStore(cfg config.DB) (store.Interface, error) {
+	types := runtime.NewTypes().Append(registry.Types...)
+
+	codec, err := NewStoreCodec(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.GetBackend() {
+	case config.DBBackendEtcd:
+		return etcd.New(cfg.Config, types, codec)
+	case config.DBBackendBolt:
+		return bolt.New(cfg.Bolt, types, codec)
+	}
+
+	return nil, fmt.Errorf("unsupported db backend: %s", cfg.Backend)
+}
+
+// newBackupCodec returns codec for registry backups, which is the same as the one configured for the store
+func (server *Server) newBackupCodec() store.Codec {
+	codec, err := NewStoreCodec(server.cfg.DB)
+	if err != nil {
+		panic(fmt.Sprintf("can't create codec for registry backups: %s", err))
+	}
+	return codec
+}
+
 func (server *Server) initRegistry() {
-	etcdStore, err := etcd.New(server.cfg.DB, runtime.NewTypes().Append(registry.Types...), store.NewYAMLCodec())
+	dbStore, err := NewStore(server.cfg.DB)
+	if err != nil {
+		panic(fmt.Sprintf("can't create %s store: %s", server.cfg.DB.GetBackend(), err))
+	}
+	server.initLeaderElection(dbStore)
+	if server.cfg.DB.Metrics {
+		dbStore = store.WithMetrics(dbStore, server.metrics)
+	}
+	server.registry = registry.New(dbStore)
+}
+
+// initLeaderElection sets up election of the leader running desired state enforcement among servers sharing the store
+func (server *Server) initLeaderElection(dbStore store.Interface) {
+	if !server.cfg.Enforcer.LeaderElection || server.cfg.Enforcer.Disabled {
+		return
+	}
+
+	elector, ok := dbStore.(store.Elector)
+	if !ok {
+		panic(fmt.Sprintf("leader election isn't supported by %s store", server.cfg.DB.GetBackend()))
+	}
+
+	identity := server.cfg.Enforcer.LeaderIdentity
+	if len(identity) == 0 {
+		hostname, err := os.Hostname()
+		if err != nil {
+			panic(fmt.Sprintf("can't get host name for leader identity: %s", err))
+		}
+		identity = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	election, err := elector.NewElection("enforcer", identity)
 	if err != nil {
-		panic(fmt.Sprintf("can't create etcd store: %s", err))
+		panic(fmt.Sprintf("can't create leader election: %s", err))
 	}
-	server.registry = registry.New(etcdStore)
+	server.election = election
+	log.Infof("Desired state enforcer will only run while being the leader (identity: %s)", identity)
 }
 
 func (server *Server) initPluginRegistryFactory() {
@@ -211,9 +369,7 @@ func (server *Server) initPluginRegistryFactory() {
 	server.updaterPluginRegistryFactory = fn(server.cfg.Updater.Noop, server.cfg.Updater.NoopSleep)
 }
 
-func (server *Server) startHTTPServer() {
-	router := httprouter.New()
-
+func (server *Server) newAPIOptions() api.Options {
 	if len(server.cfg.Auth.Secret) == 0 {
 		// todo better handle it
 		// set some default insecure secret
@@ -221,14 +377,71 @@ func (server *Server) startHTTPServer() {
 		log.Warnf("The auth.secret not specified in config, using insecure default one")
 	}
 
-	api.Serve(router, server.registry, server.externalData, server.enforcerPluginRegistryFactory, server.cfg.Auth.Secret, server.cfg.GetLogLevel(), server.runDesiredStateEnforcement)
+	if server.cfg.Enforcer.Noop {
+		log.Infof("Desired state enforcer will apply actions in noop mode (sleep per action = %s)", server.cfg.Enforcer.NoopSleep)
+	}
+
+	eventHooks := []log.Hook{event.NewHookConsole(server.cfg.GetLogLevel())}
+
+	enforcer := enforce.NewDesiredStateEnforcer(server.registry, server.externalData, server.enforcerPluginRegistryFactory, server.cfg.Enforcer.MaxConcurrentActions, eventHooks...)
+	var failureInjector *chaos.Injector
+	if server.cfg.Enforcer.FailureInjection {
+		log.Warnf("FAILURE INJECTION IS ENABLED: failures could be injected into applied actions via admin API. Never enable it in production")
+		failureInjector = chaos.NewInjector()
+		enforcer.SetFailureInjector(failureInjector)
+	}
+
+	var revisionPipeline *pipeline.Pipeline
+	if server.cfg.Pipeline.Disabled || server.cfg.Enforcer.Disabled {
+		log.Infof("Revision pipeline isn't enabled. All policy changes will be resolved synchronously")
+	} else {
+		revisionPipeline = pipeline.NewPipeline(server.registry, server.externalData, server.enforcerPluginRegistryFactory, server.cfg.Pipeline.QueueSize, server.cfg.Pipeline.Workers, eventHooks...)
+	}
+
+	admissionChain, err := admission.NewChainFromConfig(server.cfg.Admission)
+	if err != nil {
+		panic(fmt.Sprintf("can't create admission webhooks: %s", err))
+	}
+	if admissionChain.Len() > 0 {
+		log.Infof("Policy changes will go through %d admission webhook(s)", admissionChain.Len())
+	}
+
+	return api.Options{
+		Registry:                     server.registry,
+		ExternalData:                 server.externalData,
+		PluginRegistryFactory:        server.enforcerPluginRegistryFactory,
+		EventHooks:                   eventHooks,
+		AuthProvider:                 api.NewJWTAuthProvider(server.cfg.Auth.Secret, api.SystemClock{}),
+		Enforcer:                     newDesiredStateEnforcer(enforcer, server.runActualStateUpdate, server.metrics),
+		EnforcerInterval:             server.cfg.Enforcer.Interval,
+		EnforcerMaxConcurrentActions: server.cfg.Enforcer.MaxConcurrentActions,
+		FailureInjector:              failureInjector,
+		Pipeline:                     revisionPipeline,
+		PipelineSyncMaxObjects:       server.cfg.Pipeline.SyncMaxObjects,
+		PolicyJobRetention:           server.cfg.Pipeline.JobRetention,
+		ResolutionCacheSize:          server.cfg.Resolver.CacheSize,
+		Admission:                    admissionChain,
+		GCRetention:                  server.gcRetention(),
+		BackupCodec:                  server.newBackupCodec(),
+		Election:                     server.election,
+		Metrics:                      server.metrics,
+	}
+}
+
+func (server *Server) startHTTPServer() {
+	server.apiServer = api.NewServer(server.newAPIOptions())
+	err := server.apiServer.FailInterruptedPolicyApplyJobs()
+	if err != nil {
+		panic(fmt.Sprintf("error while failing interrupted policy apply jobs: %s", err))
+	}
+	router := server.apiServer.Router()
 	server.serveUI(router)
 
 	var handler http.Handler = router
 
 	// todo write to logrus
 	handler = handlers.CombinedLoggingHandler(os.Stdout, handler) // todo(slukjanov): make it at least somehow configurable - for example, select file to write to with rotation
-	handler = middleware.NewMetricsHandler(prometheusSvcName, handler)
+	handler = middleware.NewMetricsHandler(prometheusSvcName, server.metrics, handler)
 	handler = middleware.NewPanicHandler(handler)
 	// todo(slukjanov): add configurable handlers.ProxyHeaders to f behind the nginx or any other proxy
 	// todo(slukjanov): add compression handler and compress by default in client
@@ -273,7 +486,7 @@ func (server *Server) serveUI(router *httprouter.Router) {
 func (server *Server) startDesiredStateEnforcer() {
 	if !server.cfg.Enforcer.Disabled {
 		server.runInBackground("Desired State Enforcer", true, func() {
-			panic(server.desiredStateEnforceLoop())
+			panic(server.apiServer.Run(context.Background()))
 		})
 	}
 }
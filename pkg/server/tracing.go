@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Aptomi/aptomi/pkg/version"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func (server *Server) initTracing() {
+	if !server.cfg.Tracing.Enabled {
+		return
+	}
+
+	// spans are sent to the collector as Jaeger thrift over HTTP, which doesn't depend on gRPC, so it doesn't
+	// conflict with the version of gRPC used by etcd client
+	exporter, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(server.cfg.Tracing.Endpoint)))
+	if err != nil {
+		panic(fmt.Sprintf("can't create tracing exporter for %s: %s", server.cfg.Tracing.Endpoint, err))
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if server.cfg.Tracing.SampleRatio > 0 && server.cfg.Tracing.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(server.cfg.Tracing.SampleRatio)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", prometheusSvcName),
+			attribute.String("service.version", version.GetBuildInfo().GitVersion),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	log.Infof("Exporting traces to Jaeger collector: %s", server.cfg.Tracing.Endpoint)
+
+	// Buffered spans need to be flushed when server exits
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	go func() {
+		for sig := range c {
+			log.Printf("captured %v, flushing traces", sig)
+			shutdownErr := provider.Shutdown(context.Background())
+			if shutdownErr != nil {
+				log.Errorf("error while flushing traces: %s", shutdownErr)
+			}
+		}
+	}()
+}
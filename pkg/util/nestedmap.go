@@ -1,6 +1,7 @@
 package util
 
 import (
+	"encoding/gob"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
@@ -15,6 +16,11 @@ import (
 // NestedParameterMap is a nested map of parameters, which allows to work with maps [string][string]...[string] -> string, int, bool values
 type NestedParameterMap map[string]interface{}
 
+func init() {
+	// nested maps are kept as interface values, so gob should know their type to decode them
+	gob.Register(NestedParameterMap{})
+}
+
 // UnmarshalYAML is a custom unmarshal function for NestedParameterMap to deal with interface{} -> string conversions
 func (src *NestedParameterMap) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	result := make(map[interface{}]interface{})
@@ -210,8 +216,8 @@ func processParameterTreeNode(node interface{}, parameters *template.Parameters,
 
 			result[key] = evaluatedValue
 		} else if mode == ModeCompile {
-			// just compile
-			_, err := template.NewTemplate(templateStr)
+			// just compile (and cache compiled template)
+			_, err := cache.Compile(templateStr)
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,40 @@
+package sync
+
+import (
+	"reflect"
+	"sync"
+)
+
+// ChangeNotifier is a helper to notify handlers when periodically reloaded data changes. Every time data gets loaded,
+// it's compared with the previously loaded one and registered handlers are called if it's different
+type ChangeNotifier struct {
+	m        sync.Mutex
+	loaded   bool
+	data     interface{}
+	handlers []func()
+}
+
+// OnChange registers a handler, which gets called every time loaded data changes
+func (notifier *ChangeNotifier) OnChange(handler func()) {
+	notifier.m.Lock()
+	defer notifier.m.Unlock()
+
+	notifier.handlers = append(notifier.handlers, handler)
+}
+
+// Loaded records newly loaded data and calls registered handlers if it differs from the previously loaded one. Data
+// loaded for the first time isn't considered a change, as nobody could have used it before
+func (notifier *ChangeNotifier) Loaded(data interface{}) {
+	notifier.m.Lock()
+	changed := notifier.loaded && !reflect.DeepEqual(notifier.data, data)
+	notifier.loaded = true
+	notifier.data = data
+	handlers := notifier.handlers
+	notifier.m.Unlock()
+
+	if changed {
+		for _, handler := range handlers {
+			handler()
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package sync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeNotifier(t *testing.T) {
+	notifier := &ChangeNotifier{}
+	changes := 0
+	notifier.OnChange(func() {
+		changes++
+	})
+
+	notifier.Loaded(map[string]string{"key": "value"})
+	assert.Equal(t, 0, changes, "Data loaded for the first time should not be considered a change")
+
+	notifier.Loaded(map[string]string{"key": "value"})
+	assert.Equal(t, 0, changes, "Reloading the same data should not be considered a change")
+
+	notifier.Loaded(map[string]string{"key": "updated"})
+	assert.Equal(t, 1, changes, "Reloading different data should be considered a change")
+
+	notifier.Loaded(map[string]string{"key": "updated"})
+	assert.Equal(t, 1, changes, "Reloading the same data again should not be considered a change")
+}
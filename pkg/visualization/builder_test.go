@@ -1,6 +1,7 @@
 package visualization
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Aptomi/aptomi/pkg/engine/resolve"
@@ -22,7 +23,10 @@ func TestVisualizationDiagram(t *testing.T) {
 	// unit test policy resolved revision
 	eventLog := event.NewLog(logrus.WarnLevel, "test-resolve")
 	resolver := resolve.NewPolicyResolver(b.Policy(), b.External(), eventLog)
-	resolutionNew := resolver.ResolveAllClaims()
+	resolutionNew, resolveErr := resolver.ResolveAllClaims(context.Background())
+	if !assert.NoError(t, resolveErr, "Policy should be resolved without errors") {
+		t.FailNow()
+	}
 	if !assert.Equal(t, 14, len(resolutionNew.ComponentInstanceMap), "Instances should be resolved") {
 		t.FailNow()
 	}